@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/storage"
+)
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found: %v", err)
+	}
+
+	// Initialize logger
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatal("Failed to initialize logger:", err)
+	}
+	defer logger.Sync()
+
+	// Get database URL
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		logger.Fatal("DATABASE_URL environment variable is required")
+	}
+
+	// Connect to database
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", zap.Error(err))
+	}
+	defer db.Close()
+
+	// Test connection
+	err = db.Ping(ctx)
+	if err != nil {
+		logger.Fatal("Failed to ping database", zap.Error(err))
+	}
+
+	storageService, err := storage.NewStorageService(storage.ConfigFromEnv(os.Getenv), logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize storage service", zap.Error(err))
+	}
+
+	// Remove orphaned content once and exit
+	removed, err := services.NewOrphanCleanupService(db, storageService, logger).CleanupOrphans(ctx)
+	if err != nil {
+		logger.Fatal("Failed to clean up orphaned content", zap.Error(err))
+	}
+
+	logger.Info("Orphan cleanup completed successfully", zap.Int("removed", removed))
+}
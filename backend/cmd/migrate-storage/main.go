@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/storage"
+)
+
+// Copies stored content between storage backends, both configured from the usual environment
+// (STORAGE_PATH for local, AWS_* and S3_* for s3). The application keeps serving from the source
+// meanwhile; re-run to pick up content uploaded since, then switch STORAGE_BACKEND over.
+func main() {
+	from := flag.String("from", "local", "backend to copy content from: local or s3")
+	to := flag.String("to", "s3", "backend to copy content to: local or s3")
+	userFlag := flag.String("user", "", "ID of the user whose content to migrate; all content when empty")
+	dryRun := flag.Bool("dry-run", false, "report what would be copied without writing anything")
+	flag.Parse()
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found: %v", err)
+	}
+
+	// Initialize logger
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatal("Failed to initialize logger:", err)
+	}
+	defer logger.Sync()
+
+	if *from == *to {
+		logger.Fatal("Source and destination backends must differ", zap.String("backend", *from))
+	}
+
+	var userID *uuid.UUID
+	if *userFlag != "" {
+		parsed, err := uuid.Parse(*userFlag)
+		if err != nil {
+			logger.Fatal("Invalid user ID", zap.String("user", *userFlag))
+		}
+		userID = &parsed
+	}
+
+	// Get database URL
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		logger.Fatal("DATABASE_URL environment variable is required")
+	}
+
+	// Connect to database
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", zap.Error(err))
+	}
+	defer db.Close()
+
+	// Test connection
+	err = db.Ping(ctx)
+	if err != nil {
+		logger.Fatal("Failed to ping database", zap.Error(err))
+	}
+
+	config := storage.ConfigFromEnv(os.Getenv)
+	config.Backend = *from
+	source, err := storage.NewStorageService(config, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize source storage", zap.Error(err))
+	}
+	config.Backend = *to
+	destination, err := storage.NewStorageService(config, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize destination storage", zap.Error(err))
+	}
+
+	report, err := services.NewStorageMigrationService(db, source, destination, logger).Migrate(ctx, services.StorageMigrationOptions{
+		UserID: userID,
+		DryRun: *dryRun,
+	})
+	if err != nil {
+		logger.Fatal("Failed to migrate storage", zap.Error(err))
+	}
+
+	logger.Info("Storage migration completed",
+		zap.String("from", *from),
+		zap.String("to", *to),
+		zap.Bool("dry_run", *dryRun),
+		zap.Int("copied", report.Copied),
+		zap.Int("skipped", report.Skipped),
+		zap.Int("paths_updated", report.PathsUpdated),
+		zap.Strings("failed", report.Failed))
+
+	if len(report.Failed) > 0 {
+		logger.Sync()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/services"
+)
+
+func main() {
+	userFlag := flag.String("user", "", "ID of the user to repair; every user when empty")
+	flag.Parse()
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found: %v", err)
+	}
+
+	// Initialize logger
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatal("Failed to initialize logger:", err)
+	}
+	defer logger.Sync()
+
+	var userID *uuid.UUID
+	if *userFlag != "" {
+		parsed, err := uuid.Parse(*userFlag)
+		if err != nil {
+			logger.Fatal("Invalid user ID", zap.String("user", *userFlag))
+		}
+		userID = &parsed
+	}
+
+	// Get database URL
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		logger.Fatal("DATABASE_URL environment variable is required")
+	}
+
+	// Connect to database
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", zap.Error(err))
+	}
+	defer db.Close()
+
+	// Test connection
+	err = db.Ping(ctx)
+	if err != nil {
+		logger.Fatal("Failed to ping database", zap.Error(err))
+	}
+
+	// Recompute storage usage from the files table once and exit
+	corrected, err := services.NewUserService(db).RecomputeStorageUsed(ctx, userID)
+	if err != nil {
+		logger.Fatal("Failed to recompute storage used", zap.Error(err))
+	}
+
+	logger.Info("Storage recomputation completed successfully", zap.Int64("corrected", corrected))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/services"
+)
+
+// accessLog writes one structured line per request once it has been handled, carrying the request
+// ID when requestID runs before it and the caller when an auth middleware accepted the request.
+// Only the path is logged: query strings may carry tokens. Server errors log at error level and
+// client errors at warn.
+func accessLog(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Int("bytes", c.Writer.Size()),
+		}
+		if userID, ok := c.Get(authUserIDKey); ok {
+			fields = append(fields, zap.String("user_id", userID.(uuid.UUID).String()))
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		requestLogger := services.RequestLogger(c.Request.Context(), logger)
+		switch {
+		case status >= 500:
+			requestLogger.Error("Request handled", fields...)
+		case status >= 400:
+			requestLogger.Warn("Request handled", fields...)
+		default:
+			requestLogger.Info("Request handled", fields...)
+		}
+	}
+}
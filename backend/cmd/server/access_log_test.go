@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"lokr-backend/pkg/auth"
+)
+
+// newAccessLogRouter serves an open /ping, an authenticated /files and a failing /broken behind
+// the request ID and access log middleware, logging into the returned observer
+func newAccessLogRouter(jwtManager *auth.JWTManager) (*gin.Engine, *observer.ObservedLogs) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	router := gin.New()
+	router.Use(requestID())
+	router.Use(accessLog(zap.New(core)))
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.GET("/files", authRequired(jwtManager), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/broken", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+	return router, logs
+}
+
+func TestAccessLog(t *testing.T) {
+	jwtManager := auth.NewJWTManager("test-secret")
+	router, logs := newAccessLogRouter(jwtManager)
+
+	t.Run("logs the request with the ID echoed to the client", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/ping?token=secret", nil)
+		req.Header.Set(requestIDHeader, "client-abc.123")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if got := recorder.Header().Get(requestIDHeader); got != "client-abc.123" {
+			t.Errorf("Expected the provided ID to be echoed, got %q", got)
+		}
+		entries := logs.TakeAll()
+		if len(entries) != 1 {
+			t.Fatalf("Expected one access log entry, got %d", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if fields["request_id"] != "client-abc.123" || fields["method"] != "GET" ||
+			fields["path"] != "/ping" || fields["status"] != int64(http.StatusNoContent) {
+			t.Errorf("Unexpected access log fields %v", fields)
+		}
+		if _, ok := fields["latency"]; !ok {
+			t.Error("Expected the latency to be logged")
+		}
+	})
+
+	t.Run("generates an ID when none is sent", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+		id := recorder.Header().Get(requestIDHeader)
+		if id == "" {
+			t.Fatal("Expected a generated request ID")
+		}
+		entries := logs.TakeAll()
+		if len(entries) != 1 || entries[0].ContextMap()["request_id"] != id {
+			t.Errorf("Expected the access log to carry the generated ID %s", id)
+		}
+	})
+
+	t.Run("includes the authenticated caller", func(t *testing.T) {
+		userID := uuid.New()
+		token, _ := jwtManager.GenerateToken(userID.String(), "logged@example.com", "USER")
+		req := httptest.NewRequest(http.MethodGet, "/files", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		entries := logs.TakeAll()
+		if len(entries) != 1 || entries[0].ContextMap()["user_id"] != userID.String() {
+			t.Errorf("Expected the access log to carry user_id %s", userID)
+		}
+	})
+
+	t.Run("logs server errors at error level", func(t *testing.T) {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/broken", nil))
+
+		entries := logs.TakeAll()
+		if len(entries) != 1 || entries[0].Level != zapcore.ErrorLevel {
+			t.Errorf("Expected one error-level entry, got %v", entries)
+		}
+	})
+}
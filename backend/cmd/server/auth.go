@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"lokr-backend/pkg/auth"
+)
+
+// Gin context keys under which the auth middleware stores the caller
+const (
+	authUserIDKey = "authUserID"
+	authClaimsKey = "authClaims"
+)
+
+// authRequired validates the bearer token of every request, storing the caller for authUserID and
+// authClaims and answering 401 when the token is missing or invalid
+func authRequired(jwtManager *auth.JWTManager) gin.HandlerFunc {
+	return authenticate(jwtManager, false)
+}
+
+// authRequiredWithQueryToken is authRequired also accepting the token as ?token=, for URLs loaded
+// by the browser itself, such as previews in <img> and <video> tags, which can't send headers
+func authRequiredWithQueryToken(jwtManager *auth.JWTManager) gin.HandlerFunc {
+	return authenticate(jwtManager, true)
+}
+
+func authenticate(jwtManager *auth.JWTManager, allowQueryToken bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var token string
+		if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+			token = strings.TrimPrefix(authHeader, "Bearer ")
+		} else if allowQueryToken {
+			token = c.Query("token")
+		}
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		claims, err := jwtManager.ValidateToken(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		userUUID, err := uuid.Parse(claims.UserID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		c.Set(authUserIDKey, userUUID)
+		c.Set(authClaimsKey, claims)
+		c.Next()
+	}
+}
+
+// authUserID is the caller of a request that passed the auth middleware
+func authUserID(c *gin.Context) uuid.UUID {
+	return c.MustGet(authUserIDKey).(uuid.UUID)
+}
+
+// authClaims are the token claims of a request that passed the auth middleware
+func authClaims(c *gin.Context) *auth.Claims {
+	return c.MustGet(authClaimsKey).(*auth.Claims)
+}
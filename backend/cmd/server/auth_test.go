@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"lokr-backend/pkg/auth"
+)
+
+// newAuthRouter serves /files, which requires a bearer token, and /preview, which also accepts
+// ?token=; both answer with the caller's ID
+func newAuthRouter(jwtManager *auth.JWTManager) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	whoami := func(c *gin.Context) {
+		c.String(http.StatusOK, authUserID(c).String()+" "+authClaims(c).Email)
+	}
+
+	router := gin.New()
+	router.GET("/files", authRequired(jwtManager), whoami)
+	router.GET("/preview", authRequiredWithQueryToken(jwtManager), whoami)
+	return router
+}
+
+func serveAuth(router *gin.Engine, target, bearer string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestAuthRequired(t *testing.T) {
+	jwtManager := auth.NewJWTManager("test-secret")
+	router := newAuthRouter(jwtManager)
+
+	userID := uuid.New()
+	token, err := jwtManager.GenerateToken(userID.String(), "auth@example.com", "USER")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	forged, _ := auth.NewJWTManager("other-secret").GenerateToken(userID.String(), "auth@example.com", "USER")
+
+	t.Run("a valid token passes with the caller stored", func(t *testing.T) {
+		recorder := serveAuth(router, "/files", token)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", recorder.Code)
+		}
+		if want := userID.String() + " auth@example.com"; recorder.Body.String() != want {
+			t.Errorf("Expected %q, got %q", want, recorder.Body.String())
+		}
+	})
+
+	t.Run("a missing header is rejected", func(t *testing.T) {
+		if recorder := serveAuth(router, "/files", ""); recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("an invalid token is rejected", func(t *testing.T) {
+		if recorder := serveAuth(router, "/files", forged); recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("a query token is only accepted where allowed", func(t *testing.T) {
+		if recorder := serveAuth(router, "/files?token="+token, ""); recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Expected a query token to be refused on /files, got %d", recorder.Code)
+		}
+
+		recorder := serveAuth(router, "/preview?token="+token, "")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected a query token to be accepted on /preview, got %d", recorder.Code)
+		}
+		if want := userID.String() + " auth@example.com"; recorder.Body.String() != want {
+			t.Errorf("Expected %q, got %q", want, recorder.Body.String())
+		}
+
+		if recorder := serveAuth(router, "/preview?token="+forged, ""); recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Expected an invalid query token to be refused, got %d", recorder.Code)
+		}
+	})
+}
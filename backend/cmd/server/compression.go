@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// minCompressSize is the smallest body worth compressing; below it the gzip framing eats the gain
+const minCompressSize = 1024
+
+// compressibleTypes are the non-text MIME types worth compressing. Everything under text/ and any
+// +json or +xml type is compressible too; the rest (images, archives, video) is already compressed.
+var compressibleTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+	"application/x-ndjson":   true,
+	"application/yaml":       true,
+	"application/x-yaml":     true,
+	"application/sql":        true,
+}
+
+// compressible reports whether content of the given MIME type shrinks meaningfully under gzip
+func compressible(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") ||
+		compressibleTypes[mediaType]
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows a gzip response
+func acceptsGzip(acceptEncoding string) bool {
+	for _, candidate := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(candidate), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		// q=0 means "not acceptable"
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// sendContent writes a file body with its Content-Type, gzip-compressing it when the client
+// accepts gzip and the type is compressible. Range requests are always sent uncompressed, so
+// byte offsets keep referring to the stored content. A compressed body's ETag is weakened, as it
+// is no longer byte-identical to the content the hash names. Only gzip is offered; zstd would
+// need a third-party encoder.
+func sendContent(c *gin.Context, mimeType string, content []byte) {
+	c.Header("Vary", "Accept-Encoding")
+
+	if len(content) >= minCompressSize && compressible(mimeType) &&
+		c.GetHeader("Range") == "" && acceptsGzip(c.GetHeader("Accept-Encoding")) {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		if _, err := writer.Write(content); err == nil && writer.Close() == nil {
+			if etag := c.Writer.Header().Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				c.Header("ETag", "W/"+etag)
+			}
+			c.Header("Content-Encoding", "gzip")
+			content = compressed.Bytes()
+		}
+	}
+
+	c.Header("Content-Type", mimeType)
+	c.Header("Content-Length", fmt.Sprintf("%d", len(content)))
+	c.Data(http.StatusOK, mimeType, content)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveContent sends content through sendContent with the given request headers
+func serveContent(mimeType string, content []byte, headers map[string]string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/file", func(c *gin.Context) {
+		c.Header("ETag", `"abc123"`)
+		sendContent(c, mimeType, content)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/file", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestSendContent_GzipsText(t *testing.T) {
+	content := []byte(strings.Repeat("2024-01-01 INFO request served\n", 100))
+
+	recorder := serveContent("text/plain; charset=utf-8", content, map[string]string{"Accept-Encoding": "br, gzip;q=0.8"})
+	if got := recorder.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Expected Content-Encoding gzip, got '%s'", got)
+	}
+	if got := recorder.Header().Get("ETag"); got != `W/"abc123"` {
+		t.Errorf("Expected a weakened ETag, got %s", got)
+	}
+	if recorder.Body.Len() >= len(content) {
+		t.Errorf("Expected the body to shrink, got %d bytes from %d", recorder.Body.Len(), len(content))
+	}
+
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip body: %v", err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to decompress body: %v", err)
+	}
+	if !bytes.Equal(decoded, content) {
+		t.Error("Expected the decompressed body to match the content")
+	}
+}
+
+func TestSendContent_Uncompressed(t *testing.T) {
+	text := []byte(strings.Repeat(`{"level":"info"}`+"\n", 100))
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 2048)...)
+
+	tests := []struct {
+		name     string
+		mimeType string
+		content  []byte
+		headers  map[string]string
+	}{
+		{"png", "image/png", png, map[string]string{"Accept-Encoding": "gzip"}},
+		{"not accepted", "application/json", text, nil},
+		{"refused", "application/json", text, map[string]string{"Accept-Encoding": "gzip;q=0, identity"}},
+		{"range request", "application/json", text, map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-99"}},
+		{"too small", "text/plain", []byte("short"), map[string]string{"Accept-Encoding": "gzip"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serveContent(tt.mimeType, tt.content, tt.headers)
+			if got := recorder.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("Expected no Content-Encoding, got '%s'", got)
+			}
+			if got := recorder.Header().Get("ETag"); got != `"abc123"` {
+				t.Errorf("Expected the strong ETag to be kept, got %s", got)
+			}
+			if !bytes.Equal(recorder.Body.Bytes(), tt.content) {
+				t.Error("Expected the body to be the content unchanged")
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gin-contrib/cors"
+)
+
+// defaultCORSOrigins are allowed when CORS_ALLOWED_ORIGINS is unset, for the local frontend
+var defaultCORSOrigins = []string{"http://localhost:3000", "http://127.0.0.1:3000"}
+
+// corsConfigFromEnv builds the CORS policy from CORS_ALLOWED_ORIGINS, a comma-separated list of
+// origins like "https://app.example.com", or "*" to allow any origin during development.
+// Credentials are only allowed for explicit origins: with "*" any site could otherwise make
+// requests carrying the user's credentials.
+func corsConfigFromEnv(getenv func(string) string) (cors.Config, error) {
+	config := cors.DefaultConfig()
+	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID"}
+	config.ExposeHeaders = []string{requestIDHeader, encryptionAlgorithmHeader, encryptionIVHeader, encryptionWrappedKeyHeader}
+
+	origins, err := parseCORSOrigins(getenv("CORS_ALLOWED_ORIGINS"))
+	if err != nil {
+		return cors.Config{}, err
+	}
+	if len(origins) == 1 && origins[0] == "*" {
+		config.AllowAllOrigins = true
+		config.AllowCredentials = false
+	} else {
+		config.AllowOrigins = origins
+		config.AllowCredentials = true
+	}
+
+	if err := config.Validate(); err != nil {
+		return cors.Config{}, fmt.Errorf("invalid CORS configuration: %w", err)
+	}
+	return config, nil
+}
+
+// parseCORSOrigins splits and checks a CORS_ALLOWED_ORIGINS value. Each origin must be a scheme and
+// host with an optional port and nothing else; "*" must stand alone.
+func parseCORSOrigins(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return defaultCORSOrigins, nil
+	}
+
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		if origin != "*" {
+			parsed, err := url.Parse(origin)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+				parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+				return nil, fmt.Errorf("invalid CORS origin %q: expected scheme://host[:port]", origin)
+			}
+		}
+		origins = append(origins, origin)
+	}
+
+	for _, origin := range origins {
+		if origin == "*" && len(origins) > 1 {
+			return nil, fmt.Errorf("CORS origin \"*\" can't be combined with other origins")
+		}
+	}
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS lists no origins")
+	}
+	return origins, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSConfigFromEnv_ReflectsConfiguredOrigins(t *testing.T) {
+	config, err := corsConfigFromEnv(envOf(map[string]string{
+		"CORS_ALLOWED_ORIGINS": "https://app.example.com, https://admin.example.com/",
+	}))
+	if err != nil {
+		t.Fatalf("corsConfigFromEnv failed: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(cors.New(config))
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		origin      string
+		wantAllowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://admin.example.com", true},
+		{"https://evil.example.com", false},
+		{"http://localhost:3000", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.Header.Set("Origin", tt.origin)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		allowed := recorder.Header().Get("Access-Control-Allow-Origin")
+		if tt.wantAllowed && (allowed != tt.origin || recorder.Header().Get("Access-Control-Allow-Credentials") != "true") {
+			t.Errorf("%s: expected the origin reflected with credentials, got %q", tt.origin, allowed)
+		}
+		if !tt.wantAllowed && allowed != "" {
+			t.Errorf("%s: expected no Access-Control-Allow-Origin, got %q", tt.origin, allowed)
+		}
+	}
+}
+
+func TestCORSConfigFromEnv_Origins(t *testing.T) {
+	config, err := corsConfigFromEnv(envOf(nil))
+	if err != nil || !reflect.DeepEqual(config.AllowOrigins, defaultCORSOrigins) || !config.AllowCredentials {
+		t.Errorf("Expected the local frontend with credentials by default, got %v (%v)", config.AllowOrigins, err)
+	}
+
+	config, err = corsConfigFromEnv(envOf(map[string]string{"CORS_ALLOWED_ORIGINS": "*"}))
+	if err != nil || !config.AllowAllOrigins || config.AllowCredentials {
+		t.Errorf("Expected a wildcard to allow any origin without credentials, got %+v (%v)", config, err)
+	}
+
+	for _, value := range []string{
+		"app.example.com",
+		"ftp://app.example.com",
+		"https://app.example.com/path",
+		"*, https://app.example.com",
+		" , ",
+	} {
+		if _, err := corsConfigFromEnv(envOf(map[string]string{"CORS_ALLOWED_ORIGINS": value})); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
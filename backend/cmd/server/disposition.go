@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sanitizeDownloadName makes a client-supplied name safe to offer as a download name: control
+// characters (which could inject headers) are dropped and any directory part is stripped. Returns
+// an empty string when nothing usable is left.
+func sanitizeDownloadName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSpace(name)
+	if name == "." || name == ".." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// attachmentName is the name a download is offered under: the ?filename= query parameter when it
+// sanitizes to something usable, otherwise the file's stored name
+func attachmentName(c *gin.Context, storedName string) string {
+	if name := sanitizeDownloadName(c.Query("filename")); name != "" {
+		return name
+	}
+	return storedName
+}
+
+// Content-Disposition types a download may be sent with
+const (
+	dispositionAttachment = "attachment"
+	dispositionInline     = "inline"
+)
+
+// scriptableTypes are mime types, besides the XML family, that a browser may run scripts from when
+// it displays them. Opened from our origin they could act as the signed-in user, so they are never
+// sent inline.
+var scriptableTypes = map[string]bool{
+	"text/html":                 true,
+	"text/xml":                  true,
+	"text/xsl":                  true,
+	"application/xml":           true,
+	"text/javascript":           true,
+	"application/javascript":    true,
+	"multipart/x-mixed-replace": true,
+}
+
+// inlineSafe reports whether content of mimeType may be displayed inline. Any +xml type, such as
+// image/svg+xml or application/xhtml+xml, counts as scriptable, and unparseable types as unsafe.
+func inlineSafe(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	return !scriptableTypes[mediaType] && !strings.HasSuffix(mediaType, "+xml")
+}
+
+// guardScriptable sets the headers that stop a browser running scripts from content of mimeType
+// in our origin. Nothing is type-sniffed; scriptable types also get a sandboxing CSP, and the
+// return value tells the caller they must be sent as attachments.
+func guardScriptable(c *gin.Context, mimeType string) bool {
+	c.Header("X-Content-Type-Options", "nosniff")
+	if inlineSafe(mimeType) {
+		return false
+	}
+	c.Header("Content-Security-Policy", "sandbox")
+	return true
+}
+
+// contentDisposition builds an attachment Content-Disposition header for name
+func contentDisposition(name string) string {
+	return dispositionHeader(dispositionAttachment, name)
+}
+
+// dispositionHeader builds a Content-Disposition header of the given type for name. The quoted
+// filename is an ASCII fallback with quotes and backslashes escaped; names with other characters
+// also get an RFC 5987 filename* parameter carrying the exact UTF-8 name.
+func dispositionHeader(disposition, name string) string {
+	name = sanitizeDownloadName(name)
+	if name == "" {
+		name = "download"
+	}
+
+	var fallback strings.Builder
+	ascii := true
+	for _, r := range name {
+		switch {
+		case r > unicode.MaxASCII:
+			ascii = false
+			fallback.WriteByte('_')
+		case r == '"' || r == '\\':
+			fallback.WriteByte('\\')
+			fallback.WriteRune(r)
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+
+	header := fmt.Sprintf(`%s; filename="%s"`, disposition, fallback.String())
+	if !ascii {
+		header += "; filename*=UTF-8''" + rfc5987Escape(name)
+	}
+	return header
+}
+
+// rfc5987Escape percent-encodes every byte of s outside the RFC 5987 attr-char set
+func rfc5987Escape(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+
+	var escaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || strings.IndexByte(attrChars, b) >= 0 {
+			escaped.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&escaped, "%%%02X", b)
+	}
+	return escaped.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"mime"
+	"strings"
+	"testing"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"plain ASCII", "report.txt", `attachment; filename="report.txt"`},
+		{"unicode", "résumé 履歴書.pdf", `attachment; filename="r_sum_ ___.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9%20%E5%B1%A5%E6%AD%B4%E6%9B%B8.pdf`},
+		{"quotes", `say "hi".txt`, `attachment; filename="say \"hi\".txt"`},
+		{"windows directories", `C:\reports\q1.txt`, `attachment; filename="q1.txt"`},
+		{"newlines", "evil.txt\r\nSet-Cookie: a=b", `attachment; filename="evil.txtSet-Cookie: a=b"`},
+		{"path traversal", "../../etc/passwd", `attachment; filename="passwd"`},
+		{"nothing left", "..", `attachment; filename="download"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := contentDisposition(tt.file)
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+			if strings.ContainsAny(got, "\r\n") {
+				t.Errorf("Expected no line breaks in the header, got %q", got)
+			}
+		})
+	}
+}
+
+func TestContentDisposition_RoundTrips(t *testing.T) {
+	for _, name := range []string{"résumé 履歴書.pdf", `say "hi".txt`, "50% off; really.txt"} {
+		_, params, err := mime.ParseMediaType(contentDisposition(name))
+		if err != nil {
+			t.Fatalf("%s: failed to parse header: %v", name, err)
+		}
+		if params["filename"] != name {
+			t.Errorf("Expected a client to read back %q, got %q", name, params["filename"])
+		}
+	}
+}
+
+func TestInlineSafe(t *testing.T) {
+	for _, mimeType := range []string{"application/pdf", "image/png", "text/plain; charset=utf-8", "video/mp4"} {
+		if !inlineSafe(mimeType) {
+			t.Errorf("Expected %s to be displayable inline", mimeType)
+		}
+	}
+	for _, mimeType := range []string{"text/html", "TEXT/HTML; charset=utf-8", "image/svg+xml", "application/xhtml+xml", "text/xml", "application/javascript", "not a type"} {
+		if inlineSafe(mimeType) {
+			t.Errorf("Expected %s never to be sent inline", mimeType)
+		}
+	}
+
+	if got, want := dispositionHeader(dispositionInline, "manual.pdf"), `inline; filename="manual.pdf"`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
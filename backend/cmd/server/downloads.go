@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lokr-backend/internal/converter"
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/storage"
+)
+
+// downloadHandlers serves file content over REST. Downloads count towards a file's download
+// count; previews are inline views and don't. Both are kept in the file's access history.
+type downloadHandlers struct {
+	db        *pgxpool.Pool
+	storage   storage.StorageService
+	files     *services.SimpleFileService
+	sharing   *services.FileSharingService
+	audit     *services.AuditService
+	converter converter.Converter
+	renderer  converter.PDFRenderer
+}
+
+// Cache-Control policies. Downloads revalidate every time so each one is counted; previews may be
+// reused for a few minutes. Thumbnails, the rendered first pages of ?as=image, never change for a
+// given content hash and may be kept for a day. Public shares may sit in shared caches.
+const (
+	cacheDownload        = "private, no-cache"
+	cachePreview         = "private, max-age=300"
+	cacheThumbnail       = "private, max-age=86400"
+	cacheSharedDownload  = "public, no-cache"
+	cacheSharedPreview   = "public, max-age=300"
+	cacheSharedThumbnail = "public, max-age=86400"
+)
+
+// notModified sets the caching headers for a file and, when the request's If-None-Match already
+// names its content, answers 304 Not Modified. The ETag is the stored content hash, whichever
+// algorithm produced it, so it changes whenever the bytes do. Returns true when the response has
+// been written.
+func notModified(c *gin.Context, contentHash, cacheControl string) bool {
+	etag := `"` + contentHash + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", cacheControl)
+
+	for _, candidate := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// accessibleFile loads an active file the user owns or has been shared with the required
+// permission, recording the access against the share. When the user may not access it, it writes a
+// 404 (or a 403 when their share is too weak) and returns false.
+func (h *downloadHandlers) accessibleFile(c *gin.Context, fileID, userID uuid.UUID, required domain.PermissionType) (*domain.File, bool) {
+	ownerID, err := h.sharing.CheckFileAccess(c.Request.Context(), fileID, userID, required)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPermissionDenied):
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("your share does not allow %s access", strings.ToLower(string(required)))})
+		case errors.Is(err, services.ErrFileNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found or access denied"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check file access"})
+		}
+		return nil, false
+	}
+
+	file, err := h.files.GetFileByID(c.Request.Context(), fileID, ownerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found or access denied"})
+		return nil, false
+	}
+
+	if !h.loadEncryption(c, file) {
+		return nil, false
+	}
+
+	if ownerID != userID {
+		h.sharing.RecordShareAccess(c.Request.Context(), fileID, userID)
+	}
+	return file, true
+}
+
+// Headers carrying what a client needs to decrypt a file it encrypted before uploading
+const (
+	encryptionAlgorithmHeader  = "X-Encryption-Algorithm"
+	encryptionIVHeader         = "X-Encryption-IV"
+	encryptionWrappedKeyHeader = "X-Encryption-Wrapped-Key"
+)
+
+// loadEncryption sets the encryption metadata of file when the client encrypted it. On failure it
+// writes a 500 and returns false.
+func (h *downloadHandlers) loadEncryption(c *gin.Context, file *domain.File) bool {
+	encryption, err := h.files.GetEncryption(c.Request.Context(), file.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load file encryption"})
+		return false
+	}
+	file.Encryption = encryption
+	return true
+}
+
+// contentType is the type file's content is sent as. Ciphertext is sent as opaque bytes, with the
+// metadata to decrypt it in the X-Encryption-* headers; the file's own type is what it decrypts to.
+func contentType(c *gin.Context, file *domain.File) string {
+	if file.Encryption == nil {
+		return file.MimeType
+	}
+	c.Header(encryptionAlgorithmHeader, file.Encryption.Algorithm)
+	c.Header(encryptionIVHeader, file.Encryption.IV)
+	c.Header(encryptionWrappedKeyHeader, file.Encryption.WrappedKey)
+	return "application/octet-stream"
+}
+
+// pdfName is the download name of a file converted to PDF: its name with the extension replaced
+func pdfName(name string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + ".pdf"
+}
+
+// convertedPDF returns the PDF rendering of the content at filePath. The first request converts it
+// and caches the result next to the content; later ones are served from the cache.
+func (h *downloadHandlers) convertedPDF(ctx context.Context, filePath, mimeType string) ([]byte, error) {
+	pdfPath := storage.ConvertedPDFPath(filePath)
+	if exists, err := h.storage.Exists(ctx, pdfPath); err == nil && exists {
+		return storage.ReadAll(ctx, h.storage, pdfPath)
+	}
+
+	content, err := storage.ReadAll(ctx, h.storage, filePath)
+	if err != nil {
+		return nil, err
+	}
+	pdf, err := h.converter.ConvertToPDF(ctx, content, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failing to cache only costs the next download another conversion
+	h.storage.Store(ctx, pdfPath, bytes.NewReader(pdf), "application/pdf")
+	return pdf, nil
+}
+
+// wantsImagePreview reports whether a preview of file asks for, and can get, its first page
+// rendered as an image with ?as=image. Only PDFs are rendered; other types ignore the parameter.
+func wantsImagePreview(c *gin.Context, file *domain.File) bool {
+	return c.Query("as") == "image" && file.MimeType == "application/pdf"
+}
+
+// previewETag is the ETag of a preview of file: its content hash, or a name derived from it for the
+// rendered first page
+func previewETag(c *gin.Context, file *domain.File) string {
+	if wantsImagePreview(c, file) {
+		return file.ContentHash + "-page1"
+	}
+	return file.ContentHash
+}
+
+// previewCacheControl is the Cache-Control policy for a preview of file: thumbnail for a rendered
+// first page, preview otherwise
+func previewCacheControl(c *gin.Context, file *domain.File, preview, thumbnail string) string {
+	if wantsImagePreview(c, file) {
+		return thumbnail
+	}
+	return preview
+}
+
+// previewContent returns the content a preview of file sends and its type: the stored content, or
+// for ?as=image on a PDF its first page as a PNG. On failure it writes the error response and
+// returns false.
+func (h *downloadHandlers) previewContent(c *gin.Context, file *domain.File, filePath string) ([]byte, string, bool) {
+	if !wantsImagePreview(c, file) {
+		content, err := storage.ReadAll(c.Request.Context(), h.storage, filePath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file content"})
+			return nil, "", false
+		}
+		return content, contentType(c, file), true
+	}
+
+	// The server can't read encrypted content, so can't render it either
+	if file.Encryption != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "encrypted files can't be rendered as images"})
+		return nil, "", false
+	}
+	png, err := h.renderedPreview(c.Request.Context(), filePath, file.ContentHash)
+	switch {
+	case errors.Is(err, converter.ErrUnreadablePDF):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "the PDF is corrupt or password-protected and can't be rendered"})
+		return nil, "", false
+	case errors.Is(err, converter.ErrUnsupportedConversion):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "PDF previews can't be rendered as images"})
+		return nil, "", false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render preview"})
+		return nil, "", false
+	}
+	return png, "image/png", true
+}
+
+// renderedPreview returns the first page of the PDF at filePath as a PNG. The first request renders
+// it and caches the image under previews/; later ones are served from the cache.
+func (h *downloadHandlers) renderedPreview(ctx context.Context, filePath, contentHash string) ([]byte, error) {
+	imagePath := h.storage.Key(storage.PreviewImagePath(contentHash))
+	if exists, err := h.storage.Exists(ctx, imagePath); err == nil && exists {
+		return storage.ReadAll(ctx, h.storage, imagePath)
+	}
+
+	pdf, err := storage.ReadAll(ctx, h.storage, filePath)
+	if err != nil {
+		return nil, err
+	}
+	png, err := h.renderer.RenderFirstPage(ctx, pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failing to cache only costs the next preview another rendering
+	h.storage.Store(ctx, imagePath, bytes.NewReader(png), "image/png")
+	return png, nil
+}
+
+// contentPath is where the content with the given hash is stored; it depends on the namespace the
+// content lives in
+func (h *downloadHandlers) contentPath(ctx context.Context, contentHash string) (string, error) {
+	return services.NewFileContentService(h.db).ResolveStoragePath(ctx, contentHash)
+}
+
+// download sends a file the caller owns or may download through a share as an attachment, named
+// by the optional ?filename= query parameter. With ?format=pdf, documents are converted to PDF.
+// ?disposition=inline asks for the file to be displayed rather than saved; types that could run
+// scripts are still sent as attachments.
+func (h *downloadHandlers) download(c *gin.Context) {
+	fileID := c.Param("id")
+	userUUID := authUserID(c)
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file ID"})
+		return
+	}
+
+	targetFile, ok := h.accessibleFile(c, fileUUID, userUUID, domain.PermissionDownload)
+	if !ok {
+		return
+	}
+
+	disposition := c.DefaultQuery("disposition", dispositionAttachment)
+	if disposition != dispositionAttachment && disposition != dispositionInline {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "disposition must be inline or attachment"})
+		return
+	}
+
+	format := c.Query("format")
+	if format != "" && format != "pdf" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("conversion to %s is not supported", format)})
+		return
+	}
+	// PDFs are sent as they are; other documents are converted when their type allows it. The
+	// server can't read encrypted content, so can't convert it either.
+	convert := format == "pdf" && targetFile.MimeType != "application/pdf"
+	if convert && targetFile.Encryption != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "encrypted files can't be converted to PDF"})
+		return
+	}
+	if convert && !converter.SupportsPDF(targetFile.MimeType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("%s files can't be converted to PDF", targetFile.MimeType)})
+		return
+	}
+
+	etag := targetFile.ContentHash
+	if convert {
+		etag += "_pdf"
+	}
+	if notModified(c, etag, cacheDownload) {
+		return
+	}
+
+	// Get the correct file path from file_contents table
+	filePath, err := h.contentPath(c.Request.Context(), targetFile.ContentHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file path"})
+		return
+	}
+
+	name := attachmentName(c, targetFile.OriginalName)
+	var mimeType string
+	var content []byte
+	if convert {
+		content, err = h.convertedPDF(c.Request.Context(), filePath, targetFile.MimeType)
+		switch {
+		case errors.Is(err, converter.ErrUnsupportedConversion):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "conversion to PDF is not available"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to convert file"})
+			return
+		}
+		name, mimeType = pdfName(name), "application/pdf"
+	} else {
+		// Get file content from storage using the correct path
+		content, err = storage.ReadAll(c.Request.Context(), h.storage, filePath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file content"})
+			return
+		}
+		mimeType = contentType(c, targetFile)
+	}
+
+	// Count and log successful download
+	h.files.RecordDownload(c.Request.Context(), targetFile.ID, &userUUID, c.ClientIP())
+	h.audit.LogFileDownload(c.Request.Context(), userUUID, targetFile.ID, targetFile.OriginalName, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	// Set headers for download, under the requested name if one was given. Types a browser could
+	// run scripts from are always attachments, whatever was asked for.
+	if guardScriptable(c, mimeType) {
+		disposition = dispositionAttachment
+	}
+	c.Header("Content-Disposition", dispositionHeader(disposition, name))
+
+	// Send file content, compressed when the client accepts it
+	sendContent(c, mimeType, content)
+}
+
+// preview sends a file the caller owns or may view through a share inline. With ?as=image, PDFs
+// are sent as a PNG of their first page. Types that could run scripts, such as HTML and SVG, are
+// sent as sandboxed attachments instead.
+func (h *downloadHandlers) preview(c *gin.Context) {
+	fileID := c.Param("id")
+	userUUID := authUserID(c)
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file ID"})
+		return
+	}
+
+	targetFile, ok := h.accessibleFile(c, fileUUID, userUUID, domain.PermissionView)
+	if !ok {
+		return
+	}
+
+	if notModified(c, previewETag(c, targetFile), previewCacheControl(c, targetFile, cachePreview, cacheThumbnail)) {
+		return
+	}
+
+	// Get the correct file path from file_contents table
+	filePath, err := h.contentPath(c.Request.Context(), targetFile.ContentHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file path"})
+		return
+	}
+
+	// Get file content from storage using the correct path, rendered when ?as=image asks for it
+	content, mimeType, ok := h.previewContent(c, targetFile, filePath)
+	if !ok {
+		return
+	}
+
+	// Record and log successful preview
+	h.files.RecordPreview(c.Request.Context(), targetFile.ID, &userUUID, c.ClientIP())
+	h.audit.LogFilePreview(c.Request.Context(), userUUID, targetFile.ID, targetFile.OriginalName, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	// Send file content inline, compressed when the client accepts it; content that could run
+	// scripts in our origin is only offered as a download
+	if guardScriptable(c, mimeType) {
+		c.Header("Content-Disposition", contentDisposition(targetFile.OriginalName))
+	}
+	sendContent(c, mimeType, content)
+}
+
+// publicFile looks up the publicly shared file a request names, by its share token or by the
+// short code of a short link
+func (h *downloadHandlers) publicFile(c *gin.Context) (*domain.File, error) {
+	if code := c.Param("code"); code != "" {
+		return h.sharing.GetFileByShareCode(c.Request.Context(), code)
+	}
+	return h.sharing.GetFileByShareToken(c.Request.Context(), c.Param("token"))
+}
+
+// shared sends a publicly shared file as an attachment (no auth required); ?filename= renames it
+func (h *downloadHandlers) shared(c *gin.Context) {
+	file, err := h.publicFile(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared file not found"})
+		return
+	}
+	if !h.loadEncryption(c, file) {
+		return
+	}
+
+	if notModified(c, file.ContentHash, cacheSharedDownload) {
+		return
+	}
+
+	// Get the file path from file_contents; it depends on the namespace the content lives in
+	filePath, err := h.contentPath(c.Request.Context(), file.ContentHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file path"})
+		return
+	}
+
+	// Get file content from storage
+	content, err := storage.ReadAll(c.Request.Context(), h.storage, filePath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file content"})
+		return
+	}
+
+	// Count successful download
+	h.files.RecordDownload(c.Request.Context(), file.ID, nil, c.ClientIP())
+
+	// Set headers for download, under the requested name if one was given
+	mimeType := contentType(c, file)
+	guardScriptable(c, mimeType)
+	c.Header("Content-Disposition", contentDisposition(attachmentName(c, file.OriginalName)))
+
+	// Send file content, compressed when the client accepts it
+	sendContent(c, mimeType, content)
+}
+
+// sharedPreview sends a publicly shared file inline (no auth required); ?as=image and the handling
+// of scriptable types work as for preview
+func (h *downloadHandlers) sharedPreview(c *gin.Context) {
+	file, err := h.publicFile(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared file not found"})
+		return
+	}
+	if !h.loadEncryption(c, file) {
+		return
+	}
+
+	if notModified(c, previewETag(c, file), previewCacheControl(c, file, cacheSharedPreview, cacheSharedThumbnail)) {
+		return
+	}
+
+	// Get the file path from file_contents; it depends on the namespace the content lives in
+	filePath, err := h.contentPath(c.Request.Context(), file.ContentHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file path"})
+		return
+	}
+
+	// Get file content from storage, rendered when ?as=image asks for it
+	content, mimeType, ok := h.previewContent(c, file, filePath)
+	if !ok {
+		return
+	}
+
+	// Record successful preview
+	h.files.RecordPreview(c.Request.Context(), file.ID, nil, c.ClientIP())
+
+	// Send file content inline, compressed when the client accepts it; content that could run
+	// scripts in our origin is only offered as a download
+	if guardScriptable(c, mimeType) {
+		c.Header("Content-Disposition", contentDisposition(file.OriginalName))
+	}
+	sendContent(c, mimeType, content)
+}
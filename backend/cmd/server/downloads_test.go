@@ -0,0 +1,678 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/converter"
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/storage"
+	"lokr-backend/internal/testutil"
+	"lokr-backend/pkg/auth"
+)
+
+// newDownloadRouter serves the download routes from local storage in a temporary directory
+func newDownloadRouter(t *testing.T) (*gin.Engine, *pgxpool.Pool, storage.StorageService, *auth.JWTManager) {
+	t.Helper()
+	return newConvertingDownloadRouter(t, converter.NoopConverter{}, converter.NoopRenderer{})
+}
+
+// newConvertingDownloadRouter is newDownloadRouter converting documents with documentConverter and
+// rendering PDF previews with pdfRenderer
+func newConvertingDownloadRouter(t *testing.T, documentConverter converter.Converter, pdfRenderer converter.PDFRenderer) (*gin.Engine, *pgxpool.Pool, storage.StorageService, *auth.JWTManager) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	store, _ := testutil.NewLocalStorage(t)
+	auditService := services.NewAuditService(db, zap.NewNop())
+	jwtManager := auth.NewJWTManager("test-secret")
+
+	downloads := &downloadHandlers{
+		db:        db,
+		storage:   store,
+		files:     services.NewSimpleFileService(db, store, nil, nil, zap.NewNop()),
+		sharing:   services.NewFileSharingService(db, auditService, nil, zap.NewNop()),
+		audit:     auditService,
+		converter: documentConverter,
+		renderer:  pdfRenderer,
+	}
+	router := gin.New()
+	router.GET("/files/:id/download", authRequired(jwtManager), downloads.download)
+	router.GET("/files/:id/preview", authRequiredWithQueryToken(jwtManager), downloads.preview)
+	router.GET("/shared/:token", downloads.shared)
+	router.GET("/shared/:token/preview", downloads.sharedPreview)
+	router.GET("/s/:code", downloads.shared)
+	router.GET("/s/:code/preview", downloads.sharedPreview)
+
+	return router, db, store, jwtManager
+}
+
+// createSharedFile stores a public file for a new user and returns its id, content hash, share
+// token and a bearer token for the owner
+func createSharedFile(t *testing.T, db *pgxpool.Pool, store storage.StorageService, jwtManager *auth.JWTManager) (uuid.UUID, string, string, string) {
+	t.Helper()
+
+	userID := testutil.CreateUser(t, db, "downloader", nil)
+	content := []byte("download me " + uuid.NewString())
+	fileID := testutil.CreateFile(t, db, userID, "report.txt", content)
+	hash := fmt.Sprintf("%x", sha256.Sum256(content))
+	if err := store.Store(context.Background(), storage.ContentPath("", userID.String(), hash), bytes.NewReader(content), "text/plain"); err != nil {
+		t.Fatalf("Failed to store content: %v", err)
+	}
+
+	shareToken := uuid.NewString()
+	db.Exec(context.Background(), "UPDATE files SET visibility = 'PUBLIC', share_token = $1 WHERE id = $2", shareToken, fileID)
+
+	token, err := jwtManager.GenerateToken(userID.String(), "downloader@example.com", "USER")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	return fileID, hash, shareToken, token
+}
+
+func TestDownloadRoutes_CountDownloadsOnce(t *testing.T) {
+	router, db, store, jwtManager := newDownloadRouter(t)
+	fileID, _, shareToken, token := createSharedFile(t, db, store, jwtManager)
+
+	routes := []struct {
+		name      string
+		path      string
+		wantCount int
+	}{
+		{"authenticated download", "/files/" + fileID.String() + "/download", 1},
+		{"authenticated preview", "/files/" + fileID.String() + "/preview", 1},
+		{"public share download", "/shared/" + shareToken, 2},
+		{"public share preview", "/shared/" + shareToken + "/preview", 2},
+	}
+
+	for _, route := range routes {
+		req := httptest.NewRequest(http.MethodGet, route.path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", route.name, recorder.Code, recorder.Body.String())
+		}
+
+		var count int
+		db.QueryRow(context.Background(), "SELECT download_count FROM files WHERE id = $1", fileID).Scan(&count)
+		if count != route.wantCount {
+			t.Errorf("%s: expected download count %d, got %d", route.name, route.wantCount, count)
+		}
+	}
+}
+
+func TestDownloadRoutes_ConditionalRequests(t *testing.T) {
+	router, db, store, jwtManager := newDownloadRouter(t)
+	fileID, hash, shareToken, token := createSharedFile(t, db, store, jwtManager)
+	etag := `"` + hash + `"`
+
+	routes := []struct {
+		name         string
+		path         string
+		cacheControl string
+	}{
+		{"authenticated download", "/files/" + fileID.String() + "/download", cacheDownload},
+		{"authenticated preview", "/files/" + fileID.String() + "/preview", cachePreview},
+		{"public share download", "/shared/" + shareToken, cacheSharedDownload},
+		{"public share preview", "/shared/" + shareToken + "/preview", cacheSharedPreview},
+	}
+
+	serve := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for _, route := range routes {
+		t.Run(route.name, func(t *testing.T) {
+			first := serve(route.path, "")
+			if first.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", first.Code, first.Body.String())
+			}
+			if got := first.Header().Get("ETag"); got != etag {
+				t.Errorf("Expected ETag %s, got %s", etag, got)
+			}
+			if got := first.Header().Get("Cache-Control"); got != route.cacheControl {
+				t.Errorf("Expected Cache-Control '%s', got '%s'", route.cacheControl, got)
+			}
+
+			var before int
+			db.QueryRow(context.Background(), "SELECT download_count FROM files WHERE id = $1", fileID).Scan(&before)
+
+			conditional := serve(route.path, `"stale", `+etag)
+			if conditional.Code != http.StatusNotModified {
+				t.Fatalf("Expected status 304, got %d", conditional.Code)
+			}
+			if conditional.Body.Len() != 0 {
+				t.Errorf("Expected an empty 304 body, got %d bytes", conditional.Body.Len())
+			}
+
+			var after int
+			db.QueryRow(context.Background(), "SELECT download_count FROM files WHERE id = $1", fileID).Scan(&after)
+			if after != before {
+				t.Errorf("Expected a 304 not to count as a download, count went from %d to %d", before, after)
+			}
+
+			stale := serve(route.path, `"stale"`)
+			if stale.Code != http.StatusOK {
+				t.Errorf("Expected a stale ETag to get the content, got %d", stale.Code)
+			}
+			if stale.Body.String() != first.Body.String() {
+				t.Errorf("Expected a stale ETag to get the full content, got %d bytes", stale.Body.Len())
+			}
+		})
+	}
+}
+
+func TestDownloadRoutes_FilenameOverride(t *testing.T) {
+	router, db, store, jwtManager := newDownloadRouter(t)
+	fileID, _, shareToken, token := createSharedFile(t, db, store, jwtManager)
+
+	routes := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"stored name", "/files/" + fileID.String() + "/download", `attachment; filename="report.txt"`},
+		{"renamed", "/files/" + fileID.String() + "/download?filename=" + url.QueryEscape("Q1 résumé.txt"),
+			`attachment; filename="Q1 r_sum_.txt"; filename*=UTF-8''Q1%20r%C3%A9sum%C3%A9.txt`},
+		{"header injection", "/files/" + fileID.String() + "/download?filename=" + url.QueryEscape("a.txt\r\nX-Evil: 1"),
+			`attachment; filename="a.txtX-Evil: 1"`},
+		{"path traversal", "/files/" + fileID.String() + "/download?filename=" + url.QueryEscape("../../secret.txt"),
+			`attachment; filename="secret.txt"`},
+		{"unusable name", "/files/" + fileID.String() + "/download?filename=..", `attachment; filename="report.txt"`},
+		{"public share renamed", "/shared/" + shareToken + "?filename=copy.txt", `attachment; filename="copy.txt"`},
+	}
+
+	for _, route := range routes {
+		t.Run(route.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, route.path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			if got := recorder.Header().Get("Content-Disposition"); got != route.want {
+				t.Errorf("Expected Content-Disposition %s, got %s", route.want, got)
+			}
+		})
+	}
+}
+
+func TestDownloadRoutes_Disposition(t *testing.T) {
+	router, db, store, jwtManager := newDownloadRouter(t)
+	userID := testutil.CreateUser(t, db, "disposing", nil)
+	token, _ := jwtManager.GenerateToken(userID.String(), "disposing@example.com", "USER")
+	createFile := func(name, mimeType string) uuid.UUID {
+		content := []byte(name + " " + uuid.NewString())
+		fileID := testutil.CreateFile(t, db, userID, name, content)
+		db.Exec(context.Background(), "UPDATE files SET mime_type = $1 WHERE id = $2", mimeType, fileID)
+		path := storage.ContentPath("", userID.String(), fmt.Sprintf("%x", sha256.Sum256(content)))
+		if err := store.Store(context.Background(), path, bytes.NewReader(content), mimeType); err != nil {
+			t.Fatalf("Failed to store content: %v", err)
+		}
+		return fileID
+	}
+	pdf := createFile("manual.pdf", "application/pdf")
+	svg := createFile("logo.svg", "image/svg+xml")
+	html := createFile("page.html", "text/html; charset=utf-8")
+
+	routes := []struct {
+		name   string
+		fileID uuid.UUID
+		query  string
+		want   string
+	}{
+		{"attachment by default", pdf, "", `attachment; filename="manual.pdf"`},
+		{"PDF inline", pdf, "?disposition=inline", `inline; filename="manual.pdf"`},
+		{"PDF attachment", pdf, "?disposition=attachment", `attachment; filename="manual.pdf"`},
+		{"SVG forced to attachment", svg, "?disposition=inline", `attachment; filename="logo.svg"`},
+		{"HTML forced to attachment", html, "?disposition=inline", `attachment; filename="page.html"`},
+	}
+
+	for _, route := range routes {
+		t.Run(route.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/files/"+route.fileID.String()+"/download"+route.query, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			if got := recorder.Header().Get("Content-Disposition"); got != route.want {
+				t.Errorf("Expected Content-Disposition %s, got %s", route.want, got)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/files/"+pdf.String()+"/download?disposition=open", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown disposition to be rejected with 400, got %d", recorder.Code)
+	}
+}
+
+func TestDownloadRoutes_ScriptablePreviews(t *testing.T) {
+	router, db, store, jwtManager := newDownloadRouter(t)
+	fileID, hash, shareToken, token := createSharedFile(t, db, store, jwtManager)
+
+	// Turn the shared file into an SVG that runs a script when displayed
+	svg := `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(document.cookie)</script></svg>`
+	var ownerID uuid.UUID
+	db.QueryRow(context.Background(), "SELECT user_id FROM files WHERE id = $1", fileID).Scan(&ownerID)
+	if err := store.Store(context.Background(), storage.ContentPath("", ownerID.String(), hash), strings.NewReader(svg), "image/svg+xml"); err != nil {
+		t.Fatalf("Failed to store content: %v", err)
+	}
+	db.Exec(context.Background(), "UPDATE files SET mime_type = 'image/svg+xml', original_name = 'logo.svg' WHERE id = $1", fileID)
+
+	for _, path := range []string{
+		"/files/" + fileID.String() + "/preview",
+		"/shared/" + shareToken + "/preview",
+		"/files/" + fileID.String() + "/download?disposition=inline",
+		"/shared/" + shareToken,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", path, recorder.Code, recorder.Body.String())
+		}
+		header := recorder.Header()
+		if got := header.Get("Content-Disposition"); got != `attachment; filename="logo.svg"` {
+			t.Errorf("%s: expected the SVG to be sent as an attachment, got %q", path, got)
+		}
+		if got := header.Get("Content-Security-Policy"); got != "sandbox" {
+			t.Errorf("%s: expected a sandboxing CSP, got %q", path, got)
+		}
+		if got := header.Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("%s: expected nosniff, got %q", path, got)
+		}
+	}
+
+	// Safe types are still previewed inline
+	db.Exec(context.Background(), "UPDATE files SET mime_type = 'text/plain' WHERE id = $1", fileID)
+	req := httptest.NewRequest(http.MethodGet, "/files/"+fileID.String()+"/preview", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if recorder.Header().Get("Content-Disposition") != "" || recorder.Header().Get("Content-Security-Policy") != "" {
+		t.Errorf("Expected plain text to be previewed inline, got %v", recorder.Header())
+	}
+}
+
+func TestDownloadRoutes_SharePermissions(t *testing.T) {
+	router, db, store, jwtManager := newDownloadRouter(t)
+	fileID, _, _, _ := createSharedFile(t, db, store, jwtManager)
+	var owner uuid.UUID
+	db.QueryRow(context.Background(), "SELECT user_id FROM files WHERE id = $1", fileID).Scan(&owner)
+
+	// recipient shares the file with a new user at the given permission, expiring at expiresAt
+	// (an SQL expression), and returns their token
+	recipient := func(name string, permission domain.PermissionType, expiresAt string) string {
+		userID := testutil.CreateUser(t, db, name, nil)
+		testutil.ShareFile(t, db, fileID, owner, userID, permission)
+		db.Exec(context.Background(), "UPDATE file_shares SET expires_at = "+expiresAt+" WHERE file_id = $1 AND shared_with_user_id = $2", fileID, userID)
+		token, err := jwtManager.GenerateToken(userID.String(), name+"@example.com", "USER")
+		if err != nil {
+			t.Fatalf("Failed to generate token: %v", err)
+		}
+		return token
+	}
+	viewer := recipient("viewer", domain.PermissionView, "NULL")
+	downloader := recipient("downloader", domain.PermissionDownload, "NOW() + INTERVAL '1 day'")
+	expired := recipient("expired", domain.PermissionDownload, "NOW() - INTERVAL '1 minute'")
+	stranger, err := jwtManager.GenerateToken(testutil.CreateUser(t, db, "stranger", nil).String(), "stranger@example.com", "USER")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		route    string
+		wantCode int
+	}{
+		{"viewer previews", viewer, "preview", http.StatusOK},
+		{"viewer downloads", viewer, "download", http.StatusForbidden},
+		{"downloader downloads", downloader, "download", http.StatusOK},
+		{"expired share previews", expired, "preview", http.StatusNotFound},
+		{"expired share downloads", expired, "download", http.StatusNotFound},
+		{"stranger previews", stranger, "preview", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/files/"+fileID.String()+"/"+tt.route, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantCode, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+
+	// Successful share accesses are counted against the share
+	var accessCount int
+	db.QueryRow(context.Background(), "SELECT SUM(access_count) FROM file_shares WHERE file_id = $1", fileID).Scan(&accessCount)
+	if accessCount != 2 {
+		t.Errorf("Expected 2 recorded share accesses, got %d", accessCount)
+	}
+}
+
+// fakeConverter "converts" by prefixing a PDF header, counting its conversions
+type fakeConverter struct {
+	conversions int
+}
+
+func (f *fakeConverter) ConvertToPDF(ctx context.Context, content []byte, mimeType string) ([]byte, error) {
+	f.conversions++
+	return append([]byte("%PDF-1.7 "), content...), nil
+}
+
+func TestDownloadRoutes_ConvertToPDF(t *testing.T) {
+	fake := &fakeConverter{}
+	router, db, store, jwtManager := newConvertingDownloadRouter(t, fake, converter.NoopRenderer{})
+
+	userID := testutil.CreateUser(t, db, "converting", nil)
+	token, _ := jwtManager.GenerateToken(userID.String(), "converting@example.com", "USER")
+	createDocument := func(name, mimeType string) (uuid.UUID, string) {
+		content := []byte(name + " " + uuid.NewString())
+		fileID := testutil.CreateFile(t, db, userID, name, content)
+		db.Exec(context.Background(), "UPDATE files SET mime_type = $1 WHERE id = $2", mimeType, fileID)
+		path := storage.ContentPath("", userID.String(), fmt.Sprintf("%x", sha256.Sum256(content)))
+		if err := store.Store(context.Background(), path, bytes.NewReader(content), mimeType); err != nil {
+			t.Fatalf("Failed to store content: %v", err)
+		}
+		return fileID, path
+	}
+	download := func(fileID uuid.UUID) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/files/"+fileID.String()+"/download?format=pdf", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("converts once and serves the cached copy", func(t *testing.T) {
+		fileID, path := createDocument("figures.docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+
+		first := download(fileID)
+		second := download(fileID)
+		for _, recorder := range []*httptest.ResponseRecorder{first, second} {
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			if contentType := recorder.Header().Get("Content-Type"); contentType != "application/pdf" {
+				t.Errorf("Expected application/pdf, got %s", contentType)
+			}
+			if disposition := recorder.Header().Get("Content-Disposition"); disposition != `attachment; filename="figures.pdf"` {
+				t.Errorf("Expected the download to be named figures.pdf, got %s", disposition)
+			}
+		}
+		if second.Body.String() != first.Body.String() || !bytes.HasPrefix(second.Body.Bytes(), []byte("%PDF-")) {
+			t.Errorf("Expected the same PDF twice, got %q and %q", first.Body.String(), second.Body.String())
+		}
+		if fake.conversions != 1 {
+			t.Errorf("Expected a single conversion, got %d", fake.conversions)
+		}
+		if exists, _ := store.Exists(context.Background(), storage.ConvertedPDFPath(path)); !exists {
+			t.Error("Expected the PDF to be cached in storage")
+		}
+	})
+
+	t.Run("rejects unsupported types", func(t *testing.T) {
+		fileID, _ := createDocument("photo.png", "image/png")
+		before := fake.conversions
+
+		recorder := download(fileID)
+		if recorder.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("Expected status 415, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if fake.conversions != before {
+			t.Error("Expected no conversion to be attempted")
+		}
+	})
+}
+
+// fakeRenderer renders every PDF as a blank PNG, counting renderings; PDFs containing "corrupt"
+// are unreadable
+type fakeRenderer struct {
+	renders int
+}
+
+func (f *fakeRenderer) RenderFirstPage(ctx context.Context, pdf []byte) ([]byte, error) {
+	if bytes.Contains(pdf, []byte("corrupt")) {
+		return nil, converter.ErrUnreadablePDF
+	}
+	f.renders++
+	var page bytes.Buffer
+	if err := png.Encode(&page, image.NewGray(image.Rect(0, 0, 4, 3))); err != nil {
+		return nil, err
+	}
+	return page.Bytes(), nil
+}
+
+func TestDownloadRoutes_PreviewAsImage(t *testing.T) {
+	fake := &fakeRenderer{}
+	router, db, store, jwtManager := newConvertingDownloadRouter(t, converter.NoopConverter{}, fake)
+
+	userID := testutil.CreateUser(t, db, "rendering", nil)
+	token, _ := jwtManager.GenerateToken(userID.String(), "rendering@example.com", "USER")
+	createFile := func(name, mimeType, body string) (uuid.UUID, string) {
+		content := []byte(body + " " + uuid.NewString())
+		fileID := testutil.CreateFile(t, db, userID, name, content)
+		db.Exec(context.Background(), "UPDATE files SET mime_type = $1 WHERE id = $2", mimeType, fileID)
+		hash := fmt.Sprintf("%x", sha256.Sum256(content))
+		if err := store.Store(context.Background(), storage.ContentPath("", userID.String(), hash), bytes.NewReader(content), mimeType); err != nil {
+			t.Fatalf("Failed to store content: %v", err)
+		}
+		return fileID, hash
+	}
+	previewIfNoneMatch := func(fileID uuid.UUID, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/files/"+fileID.String()+"/preview?as=image", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	preview := func(fileID uuid.UUID) *httptest.ResponseRecorder {
+		return previewIfNoneMatch(fileID, "")
+	}
+
+	t.Run("renders the first page once and serves the cached copy", func(t *testing.T) {
+		fileID, hash := createFile("slides.pdf", "application/pdf", "%PDF-1.7 slides")
+
+		first := preview(fileID)
+		second := preview(fileID)
+		for _, recorder := range []*httptest.ResponseRecorder{first, second} {
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			if contentType := recorder.Header().Get("Content-Type"); contentType != "image/png" {
+				t.Errorf("Expected image/png, got %s", contentType)
+			}
+			if _, err := png.Decode(bytes.NewReader(recorder.Body.Bytes())); err != nil {
+				t.Errorf("Expected a PNG, got %v", err)
+			}
+			if etag := recorder.Header().Get("ETag"); etag == `"`+hash+`"` {
+				t.Error("Expected the image to have an ETag of its own")
+			}
+		}
+		if fake.renders != 1 {
+			t.Errorf("Expected a single rendering, got %d", fake.renders)
+		}
+		if exists, _ := store.Exists(context.Background(), "previews/"+hash+"/page1.png"); !exists {
+			t.Error("Expected the image to be cached in storage")
+		}
+	})
+
+	t.Run("thumbnails are cached for longer and revalidated", func(t *testing.T) {
+		fileID, _ := createFile("cover.pdf", "application/pdf", "%PDF-1.7 cover")
+
+		first := preview(fileID)
+		if first.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", first.Code, first.Body.String())
+		}
+		if got := first.Header().Get("Cache-Control"); got != cacheThumbnail {
+			t.Errorf("Expected Cache-Control '%s', got '%s'", cacheThumbnail, got)
+		}
+		etag := first.Header().Get("ETag")
+		before := fake.renders
+
+		cached := previewIfNoneMatch(fileID, etag)
+		if cached.Code != http.StatusNotModified || cached.Body.Len() != 0 {
+			t.Errorf("Expected 304 with no body, got %d with %d bytes", cached.Code, cached.Body.Len())
+		}
+		if fake.renders != before {
+			t.Error("Expected a revalidated thumbnail not to be rendered again")
+		}
+
+		stale := previewIfNoneMatch(fileID, `"stale"`)
+		if stale.Code != http.StatusOK || !bytes.Equal(stale.Body.Bytes(), first.Body.Bytes()) {
+			t.Errorf("Expected a stale ETag to get the full image, got %d", stale.Code)
+		}
+	})
+
+	t.Run("other types ignore the parameter", func(t *testing.T) {
+		fileID, _ := createFile("notes.txt", "text/plain", "notes")
+		before := fake.renders
+
+		recorder := preview(fileID)
+		if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Body.String(), "notes ") {
+			t.Errorf("Expected the text as it is, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if fake.renders != before {
+			t.Error("Expected no rendering to be attempted")
+		}
+	})
+
+	t.Run("unreadable PDFs are refused", func(t *testing.T) {
+		fileID, hash := createFile("broken.pdf", "application/pdf", "%PDF-1.7 corrupt")
+
+		if recorder := preview(fileID); recorder.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status 422, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if exists, _ := store.Exists(context.Background(), "previews/"+hash+"/page1.png"); exists {
+			t.Error("Expected nothing to be cached for an unreadable PDF")
+		}
+	})
+}
+
+func TestDownloadRoutes_EncryptedFile(t *testing.T) {
+	router, db, store, jwtManager := newDownloadRouter(t)
+	userID := testutil.CreateUser(t, db, "encrypting", nil)
+	token, _ := jwtManager.GenerateToken(userID.String(), "encrypting@example.com", "USER")
+
+	ciphertext := []byte("\x03\xfeciphertext " + uuid.NewString())
+	hash := fmt.Sprintf("%x", sha256.Sum256(ciphertext))
+	t.Cleanup(func() {
+		db.Exec(context.Background(), "DELETE FROM files WHERE content_hash = $1", hash)
+		db.Exec(context.Background(), "DELETE FROM file_contents WHERE content_hash = $1", hash)
+	})
+	encryption := domain.FileEncryption{Algorithm: "AES-256-GCM", IV: "q83vEjRWeJCrze8S", WrappedKey: "c2VjcmV0LXdyYXBwZWQta2V5"}
+	file, err := services.NewSimpleFileService(db, store, nil, nil, zap.NewNop()).
+		UploadEncryptedFile(context.Background(), userID, "contract.docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", ciphertext, encryption, nil)
+	if err != nil {
+		t.Fatalf("UploadEncryptedFile failed: %v", err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for _, path := range []string{"/files/" + file.ID.String() + "/download", "/files/" + file.ID.String() + "/preview"} {
+		recorder := get(path)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", path, recorder.Code, recorder.Body.String())
+		}
+		if !bytes.Equal(recorder.Body.Bytes(), ciphertext) {
+			t.Errorf("%s: expected the ciphertext as uploaded, got %q", path, recorder.Body.String())
+		}
+		header := recorder.Header()
+		if header.Get("Content-Type") != "application/octet-stream" {
+			t.Errorf("%s: expected opaque bytes, got %s", path, header.Get("Content-Type"))
+		}
+		got := domain.FileEncryption{
+			Algorithm:  header.Get(encryptionAlgorithmHeader),
+			IV:         header.Get(encryptionIVHeader),
+			WrappedKey: header.Get(encryptionWrappedKeyHeader),
+		}
+		if got != encryption {
+			t.Errorf("%s: expected the encryption metadata in the headers, got %+v", path, got)
+		}
+	}
+
+	if recorder := get("/files/" + file.ID.String() + "/download?format=pdf"); recorder.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected converting an encrypted file to be refused with 415, got %d", recorder.Code)
+	}
+}
+
+func TestDownloadRoutes_ShortLinks(t *testing.T) {
+	router, db, store, jwtManager := newDownloadRouter(t)
+	fileID, hash, shareToken, _ := createSharedFile(t, db, store, jwtManager)
+	shareCode := strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+	db.Exec(context.Background(), "UPDATE files SET share_code = $1 WHERE id = $2", shareCode, fileID)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	for _, pair := range [][2]string{
+		{"/shared/" + shareToken, "/s/" + shareCode},
+		{"/shared/" + shareToken + "/preview", "/s/" + shareCode + "/preview"},
+	} {
+		long, short := get(pair[0]), get(pair[1])
+		if long.Code != http.StatusOK || short.Code != http.StatusOK {
+			t.Fatalf("Expected both %s and %s to succeed, got %d and %d", pair[0], pair[1], long.Code, short.Code)
+		}
+		if long.Body.String() != short.Body.String() || long.Header().Get("ETag") != `"`+hash+`"` || short.Header().Get("ETag") != long.Header().Get("ETag") {
+			t.Errorf("Expected %s and %s to serve the same file", pair[0], pair[1])
+		}
+	}
+
+	if recorder := get("/s/" + strings.ToUpper(shareCode) + "x"); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown short code to be 404, got %d", recorder.Code)
+	}
+}
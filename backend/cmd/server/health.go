@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds each dependency check so a hung dependency can't stall the probe
+const readinessTimeout = 2 * time.Second
+
+// pinger is a dependency that can report whether it is reachable
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// readinessHandler pings every dependency and responds 503 with a per-dependency status map
+// when any of them is down
+func readinessHandler(dependencies map[string]pinger, timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checks := make(map[string]string, len(dependencies))
+		ready := true
+
+		for name, dependency := range dependencies {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+			err := dependency.Ping(ctx)
+			cancel()
+
+			if err != nil {
+				checks[name] = err.Error()
+				ready = false
+			} else {
+				checks[name] = "ok"
+			}
+		}
+
+		status := http.StatusOK
+		overall := "ok"
+		if !ready {
+			status = http.StatusServiceUnavailable
+			overall = "unavailable"
+		}
+
+		c.JSON(status, gin.H{
+			"status": overall,
+			"checks": checks,
+			"time":   time.Now().UTC(),
+		})
+	}
+}
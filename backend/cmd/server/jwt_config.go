@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"lokr-backend/pkg/auth"
+)
+
+// jwtManagerFromEnv builds the token manager from the JWT_* settings:
+//
+//	JWT_ALGORITHM          HS256 (default) or RS256
+//	JWT_SECRET             HS256 signing secret
+//	JWT_PRIVATE_KEY        RS256 private key PEM, or JWT_PRIVATE_KEY_FILE naming a file holding it
+//	JWT_ACCESS_TOKEN_TTL   access token lifetime, e.g. "15m" (default 24h)
+//	JWT_REFRESH_TOKEN_TTL  refresh token lifetime, e.g. "720h" (default 168h)
+//	JWT_PREVIOUS_SECRETS   comma-separated HS256 secrets from before a rotation, verify only
+//	JWT_PREVIOUS_PUBLIC_KEY_FILES  comma-separated RS256 public key files from before a rotation
+//
+// To rotate, move the old secret or public key into the PREVIOUS settings and configure the new
+// one; tokens already issued keep working until they expire. In release mode every HS256 secret,
+// current or previous, must pass auth.CheckSecret, so the server refuses to start with the public
+// default or a short secret. Outside release mode a missing secret falls back to
+// auth.DefaultSecret so local setups work without configuration.
+func jwtManagerFromEnv(getenv func(string) string, release bool) (*auth.JWTManager, error) {
+	config := auth.JWTConfig{
+		Algorithm: strings.ToUpper(strings.TrimSpace(getenv("JWT_ALGORITHM"))),
+		Secret:    getenv("JWT_SECRET"),
+	}
+
+	for name, ttl := range map[string]*time.Duration{
+		"JWT_ACCESS_TOKEN_TTL":  &config.AccessTokenTTL,
+		"JWT_REFRESH_TOKEN_TTL": &config.RefreshTokenTTL,
+	} {
+		if value := getenv(name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid %s %q", name, value)
+			}
+			*ttl = parsed
+		}
+	}
+
+	for _, secret := range splitList(getenv("JWT_PREVIOUS_SECRETS")) {
+		if release {
+			if err := auth.CheckSecret(secret); err != nil {
+				return nil, fmt.Errorf("JWT_PREVIOUS_SECRETS: %w", err)
+			}
+		}
+		config.PreviousSecrets = append(config.PreviousSecrets, secret)
+	}
+	for _, path := range splitList(getenv("JWT_PREVIOUS_PUBLIC_KEY_FILES")) {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT_PREVIOUS_PUBLIC_KEY_FILES: %w", err)
+		}
+		config.PreviousPublicKeysPEM = append(config.PreviousPublicKeysPEM, key)
+	}
+
+	switch config.Algorithm {
+	case auth.AlgorithmRS256:
+		config.PrivateKeyPEM = []byte(getenv("JWT_PRIVATE_KEY"))
+		if path := getenv("JWT_PRIVATE_KEY_FILE"); len(config.PrivateKeyPEM) == 0 && path != "" {
+			key, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read JWT_PRIVATE_KEY_FILE: %w", err)
+			}
+			config.PrivateKeyPEM = key
+		}
+	default:
+		if release {
+			if err := auth.CheckSecret(config.Secret); err != nil {
+				return nil, err
+			}
+		} else if config.Secret == "" {
+			config.Secret = auth.DefaultSecret
+		}
+	}
+
+	return auth.NewJWTManagerFromConfig(config)
+}
+
+// splitList splits a comma-separated setting, dropping blank entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
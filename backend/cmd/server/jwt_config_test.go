@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"lokr-backend/pkg/auth"
+)
+
+// envOf serves getenv lookups from a map
+func envOf(values map[string]string) func(string) string {
+	return func(name string) string { return values[name] }
+}
+
+func TestJWTManagerFromEnv_Secrets(t *testing.T) {
+	strong := strings.Repeat("k", auth.MinSecretLength)
+
+	tests := []struct {
+		name    string
+		secret  string
+		release bool
+		wantErr error
+	}{
+		{"release without a secret", "", true, auth.ErrInsecureSecret},
+		{"release with the default secret", auth.DefaultSecret, true, auth.ErrInsecureSecret},
+		{"release with a short secret", strong[1:], true, auth.ErrInsecureSecret},
+		{"release with a strong secret", strong, true, nil},
+		{"debug without a secret", "", false, nil},
+		{"debug with a short secret", "dev", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, err := jwtManagerFromEnv(envOf(map[string]string{"JWT_SECRET": tt.secret}), tt.release)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			token, err := manager.GenerateToken("user-1", "user@example.com", "USER")
+			if err != nil {
+				t.Fatalf("GenerateToken failed: %v", err)
+			}
+			if claims, err := manager.ValidateToken(token); err != nil || claims.UserID != "user-1" {
+				t.Errorf("Expected the token to validate for user-1, got %+v, %v", claims, err)
+			}
+		})
+	}
+}
+
+func TestJWTManagerFromEnv_Settings(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"unknown algorithm", map[string]string{"JWT_ALGORITHM": "none", "JWT_SECRET": "dev"}},
+		{"malformed lifetime", map[string]string{"JWT_SECRET": "dev", "JWT_ACCESS_TOKEN_TTL": "soon"}},
+		{"negative lifetime", map[string]string{"JWT_SECRET": "dev", "JWT_REFRESH_TOKEN_TTL": "-1h"}},
+		{"RS256 without a key", map[string]string{"JWT_ALGORITHM": "RS256"}},
+		{"RS256 with a missing key file", map[string]string{"JWT_ALGORITHM": "RS256", "JWT_PRIVATE_KEY_FILE": "/nonexistent/key.pem"}},
+		{"missing previous public key file", map[string]string{"JWT_SECRET": "dev", "JWT_PREVIOUS_PUBLIC_KEY_FILES": "/nonexistent/key.pem"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := jwtManagerFromEnv(envOf(tt.env), false); err == nil {
+				t.Error("Expected the configuration to be rejected")
+			}
+		})
+	}
+}
+
+func TestJWTManagerFromEnv_Rotation(t *testing.T) {
+	oldSecret := strings.Repeat("o", auth.MinSecretLength)
+	newSecret := strings.Repeat("n", auth.MinSecretLength)
+
+	old, err := jwtManagerFromEnv(envOf(map[string]string{"JWT_SECRET": oldSecret}), true)
+	if err != nil {
+		t.Fatalf("jwtManagerFromEnv failed: %v", err)
+	}
+	oldToken, _ := old.GenerateToken("user-1", "user@example.com", "USER")
+
+	rotated, err := jwtManagerFromEnv(envOf(map[string]string{
+		"JWT_SECRET":           newSecret,
+		"JWT_PREVIOUS_SECRETS": " " + oldSecret + " ,",
+	}), true)
+	if err != nil {
+		t.Fatalf("jwtManagerFromEnv failed: %v", err)
+	}
+	if claims, err := rotated.ValidateToken(oldToken); err != nil || claims.UserID != "user-1" {
+		t.Errorf("Expected a token from before the rotation to verify, got %+v, %v", claims, err)
+	}
+	newToken, _ := rotated.GenerateToken("user-1", "user@example.com", "USER")
+	if _, err := old.ValidateToken(newToken); err == nil {
+		t.Error("Expected new tokens to be signed with the new secret")
+	}
+
+	// The public default must not sneak back in as a previous secret
+	_, err = jwtManagerFromEnv(envOf(map[string]string{
+		"JWT_SECRET":           newSecret,
+		"JWT_PREVIOUS_SECRETS": auth.DefaultSecret,
+	}), true)
+	if !errors.Is(err, auth.ErrInsecureSecret) {
+		t.Errorf("Expected the default secret to be refused as a previous secret, got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newLogger builds the production JSON logger at LOG_LEVEL (debug, info, warn or error; info by
+// default). Debug logging, which may include queries and user IDs, is only written at debug.
+func newLogger(getenv func(string) string) (*zap.Logger, error) {
+	config := zap.NewProductionConfig()
+	if raw := getenv("LOG_LEVEL"); raw != "" {
+		level, err := zapcore.ParseLevel(raw)
+		if err != nil {
+			return nil, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", raw)
+		}
+		config.Level = zap.NewAtomicLevelAt(level)
+	}
+	return config.Build()
+}
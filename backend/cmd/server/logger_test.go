@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNewLogger(t *testing.T) {
+	logger, err := newLogger(envOf(nil))
+	if err != nil {
+		t.Fatalf("newLogger failed: %v", err)
+	}
+	if logger.Core().Enabled(zap.DebugLevel) || !logger.Core().Enabled(zap.InfoLevel) {
+		t.Error("Expected info level by default, with debug logging silent")
+	}
+
+	logger, err = newLogger(envOf(map[string]string{"LOG_LEVEL": "debug"}))
+	if err != nil {
+		t.Fatalf("newLogger failed: %v", err)
+	}
+	if !logger.Core().Enabled(zap.DebugLevel) {
+		t.Error("Expected LOG_LEVEL=debug to enable debug logging")
+	}
+
+	if _, err := newLogger(envOf(map[string]string{"LOG_LEVEL": "chatty"})); err == nil {
+		t.Error("Expected an unknown LOG_LEVEL to be rejected")
+	}
+}
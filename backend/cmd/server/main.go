@@ -2,14 +2,12 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -19,12 +17,16 @@ import (
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 
+	"lokr-backend/internal/converter"
 	"lokr-backend/internal/domain"
 	"lokr-backend/internal/infrastructure"
 	"lokr-backend/internal/graphql"
 	"lokr-backend/internal/repository"
+	"lokr-backend/internal/scanner"
 	"lokr-backend/internal/services"
+	"lokr-backend/internal/storage"
 	"lokr-backend/pkg/auth"
+	"lokr-backend/pkg/hash"
 )
 
 func main() {
@@ -33,8 +35,8 @@ func main() {
 		log.Printf("Warning: .env file not found: %v", err)
 	}
 
-	// Initialize logger
-	logger, err := zap.NewProduction()
+	// Initialize logger at LOG_LEVEL
+	logger, err := newLogger(os.Getenv)
 	if err != nil {
 		log.Fatal("Failed to initialize logger:", err)
 	}
@@ -52,59 +54,177 @@ func main() {
 	}
 	defer infra.Close()
 
-	// Initialize JWT manager
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-secret-key-change-in-production" // Default for dev
+	// Initialize JWT manager; release builds refuse to start with an insecure secret
+	jwtManager, err := jwtManagerFromEnv(os.Getenv, gin.Mode() == gin.ReleaseMode)
+	if err != nil {
+		logger.Fatal("Invalid JWT configuration", zap.Error(err))
+	}
+	if os.Getenv("JWT_SECRET") == "" && jwtManager.Algorithm() == auth.AlgorithmHS256 {
+		logger.Warn("JWT_SECRET is not set, signing tokens with the development default")
 	}
-	jwtManager := auth.NewJWTManager(jwtSecret)
 
 	// Initialize repositories
 	fileReferenceRepo := repository.NewFileReferenceRepository(infra.DB, logger)
 	fileRepo := repository.NewFileRepository(infra.DB, logger)
+	fileShareRepo := repository.NewFileShareRepository(infra.DB, logger)
 	folderRepo := repository.NewFolderRepository(infra.DB, logger)
+	userRepo := repository.NewUserRepository(infra.DB, logger)
 
-	// Initialize services
+	// Initialize services; new users get DEFAULT_STORAGE_QUOTA bytes unless their enterprise sets one
 	userService := services.NewUserService(infra.DB)
+	if value := os.Getenv("DEFAULT_STORAGE_QUOTA"); value != "" {
+		quota, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || quota < 0 {
+			logger.Fatal("Invalid DEFAULT_STORAGE_QUOTA", zap.String("value", value))
+		}
+		userService.SetDefaultQuota(quota)
+	}
 
-	// Initialize S3 storage service
-	storageService, err := services.NewS3StorageService(logger)
+	// Initialize the storage backend chosen by STORAGE_BACKEND (s3 or local)
+	storageConfig := storage.ConfigFromEnv(os.Getenv)
+	storageService, err := storage.NewStorageService(storageConfig, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize storage service", zap.Error(err))
 	}
+	logger.Info("Storage service initialized", zap.String("backend", storageConfig.Backend))
+
+	// Initialize audit service, publishing entries to GraphQL subscriptions
+	events := services.NewEventBroker(logger)
+	auditService := services.NewAuditService(infra.DB, logger)
+	auditService.SetEventBroker(events)
+
+	// Background jobs stop when the server shuts down
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Purge expired audit logs in the background (disabled when AUDIT_RETENTION_DAYS is 0 or unset)
+	if retentionDays, err := strconv.Atoi(os.Getenv("AUDIT_RETENTION_DAYS")); err == nil && retentionDays > 0 {
+		go auditService.RunRetention(backgroundCtx, retentionDays, 24*time.Hour)
+	}
+
+	// Queue audit events for enterprise webhooks and deliver them every few seconds
+	webhookService := services.NewWebhookService(infra.DB, logger)
+	auditService.SetWebhookService(webhookService)
+	go webhookService.RunDeliveries(backgroundCtx, 5*time.Second)
+
+	// Initialize virus scanning (disabled unless VIRUS_SCAN_ENABLED=true)
+	scanTimeout, err := time.ParseDuration(getEnvDefault("VIRUS_SCAN_TIMEOUT", "10s"))
+	if err != nil {
+		logger.Fatal("Invalid VIRUS_SCAN_TIMEOUT", zap.Error(err))
+	}
+	fileScanner, err := scanner.NewScanner(scanner.ScannerConfig{
+		Enabled:       os.Getenv("VIRUS_SCAN_ENABLED") == "true",
+		ClamAVAddress: getEnvDefault("CLAMAV_ADDRESS", "localhost:3310"),
+		Timeout:       2 * time.Minute,
+	}, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize virus scanner", zap.Error(err))
+	}
+	scanService := services.NewScanService(infra.DB, storageService, fileScanner, auditService, logger, scanTimeout)
 
-	simpleFileService := services.NewSimpleFileService(infra.DB, storageService)
+	simpleFileService := services.NewSimpleFileService(infra.DB, storageService, scanService, auditService, logger)
+
+	// New content is hashed with CONTENT_HASH_ALGORITHM (sha256 unless set); content stored under
+	// an earlier algorithm keeps its hash and is never deduplicated against the new one
+	contentHasher, err := hash.NewHasher(os.Getenv("CONTENT_HASH_ALGORITHM"))
+	if err != nil {
+		logger.Fatal("Invalid CONTENT_HASH_ALGORITHM", zap.Error(err))
+	}
+	simpleFileService.SetHasher(contentHasher)
+
+	// Files no one may upload, by extension and type, whatever their enterprise allows
+	uploadBlocklist, err := uploadBlocklistFromEnv(os.Getenv)
+	if err != nil {
+		logger.Fatal("Invalid upload blocklist", zap.Error(err))
+	}
+	simpleFileService.SetUploadBlocklist(uploadBlocklist)
+
+	// Remove stored content that no file references any more, daily
+	orphanCleanupService := services.NewOrphanCleanupService(infra.DB, storageService, logger)
+	go orphanCleanupService.RunOrphanCleanup(backgroundCtx, 24*time.Hour)
+
+	// Scan again, every few minutes, uploads whose scan failed or never finished
+	if scanService.Enabled() {
+		go scanService.RunPendingRescan(backgroundCtx, 10*time.Minute)
+	}
+
+	// Initialize file sharing service, notifying recipients of new shares
+	notificationService := services.NewNotificationService(infra.DB, logger)
+	notificationService.SetEventBroker(events)
+	fileSharingService := services.NewFileSharingService(infra.DB, auditService, notificationService, logger)
+	shareLinks, err := shareLinksFromEnv(os.Getenv)
+	if err != nil {
+		logger.Fatal("Invalid share link configuration", zap.Error(err))
+	}
+	fileSharingService.SetShareLinks(shareLinks)
+	simpleFileService.SetShortShareCodes(shareLinks.ShortCodes)
 
-	// Initialize file sharing service
-	fileSharingService := services.NewFileSharingService(infra.DB)
+	// Drop shares past their expiry, hourly
+	go fileSharingService.RunShareExpiry(backgroundCtx, time.Hour)
 
 	// Initialize folder service
-	folderService := services.NewFolderService(infra.DB)
+	folderService := services.NewFolderService(infra.DB, auditService)
 
 	// Initialize file reference service
 	fileReferenceService := services.NewFileReferenceService(fileReferenceRepo, fileRepo, folderRepo)
 	folderFileService := services.NewFolderFileService(infra.DB)
 
-	// Initialize audit service
-	auditService := services.NewAuditService(infra.DB, logger)
+	// Initialize enterprise service and sweep expired invitations hourly
+	enterpriseService := services.NewEnterpriseService(infra.DB)
+	go enterpriseService.RunInvitationSweeper(backgroundCtx, time.Hour, logger)
 
 	// Initialize GraphQL resolver and handler
-	resolver := graphql.NewResolver(userService, simpleFileService, fileSharingService, folderService, fileReferenceService, folderFileService, auditService, jwtManager)
+	resolver := graphql.NewResolver(userService, simpleFileService, fileSharingService, folderService, fileReferenceService, folderFileService, auditService, enterpriseService, notificationService, webhookService, fileRepo, fileShareRepo, userRepo, jwtManager)
+	resolver.SetLogger(logger)
+
+	// Edit locks on files lapse after FILE_LOCK_TTL unless their holder renews them
+	fileLockTTL := services.DefaultFileLockTTL
+	if value := os.Getenv("FILE_LOCK_TTL"); value != "" {
+		fileLockTTL, err = time.ParseDuration(value)
+		if err != nil || fileLockTTL < time.Second {
+			logger.Fatal("Invalid FILE_LOCK_TTL", zap.String("value", value))
+		}
+	}
+	resolver.SetFileLockService(services.NewFileLockService(infra.DB, fileLockTTL))
 	graphqlHandler := graphql.NewHandler(resolver, jwtManager)
+	subscriptionHandler := graphql.NewSubscriptionHandler(userService, events, jwtManager, logger)
 
-	// Create Gin router
+	// Per-file upload size limits in bytes, by type
+	limits, err := uploadLimitsFromEnv(os.Getenv)
+	if err != nil {
+		logger.Fatal("Invalid upload size limits", zap.Error(err))
+	}
+	multipartMemory, err := multipartMemoryFromEnv(os.Getenv)
+	if err != nil {
+		logger.Fatal("Invalid multipart memory limit", zap.Error(err))
+	}
+
+	// Create Gin router; multipart bodies beyond MULTIPART_MEMORY_LIMIT are buffered on disk, not in
+	// memory, however many files they hold
 	router := gin.New()
+	router.MaxMultipartMemory = multipartMemory
 
-	// Add middleware
-	router.Use(gin.Logger())
+	// Add middleware: the access log sees the request ID, and the status of recovered panics
+	router.Use(requestID())
+	router.Use(accessLog(logger))
 	router.Use(gin.Recovery())
 
+	// Carry client details on the request context so services can record them in audit logs
+	router.Use(func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), "ipAddress", c.ClientIP())
+		ctx = context.WithValue(ctx, "userAgent", c.GetHeader("User-Agent"))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	})
+
 	// CORS configuration
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:3000", "http://127.0.0.1:3000"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID"}
-	config.AllowCredentials = true
+	config, err := corsConfigFromEnv(os.Getenv)
+	if err != nil {
+		logger.Fatal("Invalid CORS_ALLOWED_ORIGINS", zap.Error(err))
+	}
+	if config.AllowAllOrigins {
+		logger.Warn("CORS allows any origin; set CORS_ALLOWED_ORIGINS to the frontend's origin outside development")
+	}
 	router.Use(cors.New(config))
 
 	// Health check endpoint
@@ -117,267 +237,109 @@ func main() {
 		})
 	})
 
-	// API routes
-	api := router.Group("/api/v1")
-	{
-		api.GET("/ping", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "pong"})
-		})
-
-		// File upload endpoint
-		api.POST("/files/upload", func(c *gin.Context) {
-			// Get JWT token and validate user
-			authHeader := c.GetHeader("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-				return
-			}
-
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			claims, err := jwtManager.ValidateToken(token)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-				return
-			}
-
-			// Parse multipart form
-			form, err := c.MultipartForm()
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid form"})
-				return
-			}
-
-			files := form.File["files"]
-			if len(files) == 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "no files provided"})
-				return
-			}
-
-			userUUID, _ := uuid.Parse(claims.UserID)
-			uploadedFiles := make([]map[string]interface{}, 0)
-
-			for _, fileHeader := range files {
-				// Open the file
-				file, err := fileHeader.Open()
-				if err != nil {
-					continue
-				}
-				defer file.Close()
-
-				// Read file content
-				content, err := io.ReadAll(file)
-				if err != nil {
-					continue
-				}
-
-				// Detect MIME type
-				mimeType := fileHeader.Header.Get("Content-Type")
-				if mimeType == "" {
-					mimeType = "application/octet-stream"
-				}
-
-				// Upload file
-				uploadedFile, err := simpleFileService.UploadFile(
-					c.Request.Context(),
-					userUUID,
-					fileHeader.Filename,
-					mimeType,
-					content,
-					nil, // folderID
-					nil, // description
-					nil, // tags
-					nil, // visibility (defaults to private)
-				)
-				if err != nil {
-					// Log failed upload
-					auditService.LogFileUpload(c.Request.Context(), userUUID, uuid.Nil, fileHeader.Filename, c.ClientIP(), c.GetHeader("User-Agent"))
-					continue
-				}
-
-				// Log successful upload
-				auditService.LogFileUpload(c.Request.Context(), userUUID, uploadedFile.ID, uploadedFile.OriginalName, c.ClientIP(), c.GetHeader("User-Agent"))
-
-				uploadedFiles = append(uploadedFiles, map[string]interface{}{
-					"id":           uploadedFile.ID.String(),
-					"filename":     uploadedFile.Filename,
-					"originalName": uploadedFile.OriginalName,
-					"fileSize":     uploadedFile.FileSize,
-					"mimeType":     uploadedFile.MimeType,
-					"uploadDate":   uploadedFile.UploadDate,
-				})
-			}
-
-			c.JSON(http.StatusOK, gin.H{
-				"message": "files uploaded successfully",
-				"files":   uploadedFiles,
-			})
-		})
-
-		// File download endpoint
-		api.GET("/files/:id/download", func(c *gin.Context) {
-			// Get JWT token and validate user
-			authHeader := c.GetHeader("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-				return
-			}
-
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			claims, err := jwtManager.ValidateToken(token)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-				return
-			}
+	// Readiness probe: only healthy when the database and storage are reachable
+	router.GET("/health/ready", readinessHandler(map[string]pinger{
+		"database": infra.DB,
+		"storage":  storageService,
+	}, readinessTimeout))
 
-			fileID := c.Param("id")
-			userUUID, _ := uuid.Parse(claims.UserID)
-			fileUUID, err := uuid.Parse(fileID)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file ID"})
-				return
-			}
+	// Initialize document conversion for ?format=pdf downloads (disabled unless DOCUMENT_CONVERSION_ENABLED=true)
+	conversionTimeout, err := time.ParseDuration(getEnvDefault("DOCUMENT_CONVERSION_TIMEOUT", "60s"))
+	if err != nil {
+		logger.Fatal("Invalid DOCUMENT_CONVERSION_TIMEOUT", zap.Error(err))
+	}
+	documentConverter, err := converter.NewConverter(converter.ConverterConfig{
+		Enabled:         os.Getenv("DOCUMENT_CONVERSION_ENABLED") == "true",
+		LibreOfficePath: getEnvDefault("LIBREOFFICE_PATH", "soffice"),
+		Timeout:         conversionTimeout,
+	}, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize document converter", zap.Error(err))
+	}
 
-			// Get file metadata directly from database (works for both owned and shared files)
-			var targetFile domain.File
-			var folderID sql.NullString
-			var description sql.NullString
-			var shareToken sql.NullString
-			err = infra.DB.QueryRow(c.Request.Context(), `
-				SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
-					   content_hash, description, tags, visibility, share_token, download_count, upload_date
-				FROM files
-				WHERE id = $1 AND user_id = $2`, fileUUID, userUUID).Scan(
-				&targetFile.ID, &targetFile.UserID, &folderID, &targetFile.Filename, &targetFile.OriginalName,
-				&targetFile.MimeType, &targetFile.FileSize, &targetFile.ContentHash, &description, &targetFile.Tags,
-				&targetFile.Visibility, &shareToken, &targetFile.DownloadCount, &targetFile.UploadDate)
+	// Initialize PDF rendering for ?as=image previews (disabled unless PDF_PREVIEW_ENABLED=true)
+	renderTimeout, err := time.ParseDuration(getEnvDefault("PDF_PREVIEW_TIMEOUT", "30s"))
+	if err != nil {
+		logger.Fatal("Invalid PDF_PREVIEW_TIMEOUT", zap.Error(err))
+	}
+	pdfRenderer, err := converter.NewPDFRenderer(converter.RendererConfig{
+		Enabled:      os.Getenv("PDF_PREVIEW_ENABLED") == "true",
+		PdftoppmPath: getEnvDefault("PDFTOPPM_PATH", "pdftoppm"),
+		Timeout:      renderTimeout,
+	}, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize PDF renderer", zap.Error(err))
+	}
 
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "file not found or access denied"})
-				return
-			}
+	downloads := &downloadHandlers{
+		db:        infra.DB,
+		storage:   storageService,
+		files:     simpleFileService,
+		sharing:   fileSharingService,
+		audit:     auditService,
+		converter: documentConverter,
+		renderer:  pdfRenderer,
+	}
 
-			// Get the correct file path from file_contents table
-			var filePath string
-			err = infra.DB.QueryRow(c.Request.Context(), `
-				SELECT file_path FROM file_contents WHERE content_hash = $1`, targetFile.ContentHash).Scan(&filePath)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file path"})
-				return
-			}
+	// Resumable uploads; sessions abandoned for a day are swept hourly
+	uploadSessionService := services.NewUploadSessionService(infra.DB, storageService, simpleFileService, logger)
+	go uploadSessionService.RunSessionSweeper(backgroundCtx, time.Hour)
 
-			// Get file content from storage using the correct path
-			content, err := storageService.GetFile(c.Request.Context(), filePath)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file content"})
-				return
-			}
-
-			// Log successful download
-			auditService.LogFileDownload(c.Request.Context(), userUUID, targetFile.ID, targetFile.OriginalName, c.ClientIP(), c.GetHeader("User-Agent"))
+	uploads := &uploadHandlers{
+		storage:  storageService,
+		files:    simpleFileService,
+		sessions: uploadSessionService,
+		audit:    auditService,
+		limits:   limits,
+	}
 
-			// Set headers for download
-			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", targetFile.OriginalName))
-			c.Header("Content-Type", targetFile.MimeType)
-			c.Header("Content-Length", fmt.Sprintf("%d", len(content)))
+	// Request deadlines: routes moving file content get the longer transfer timeout
+	timeouts, err := requestTimeoutsFromEnv(os.Getenv)
+	if err != nil {
+		logger.Fatal("Invalid request timeout", zap.Error(err))
+	}
 
-			// Send file content
-			c.Data(http.StatusOK, targetFile.MimeType, content)
+	// API routes; requireAuth rejects requests without a valid bearer token and stores the caller
+	requireAuth := authRequired(jwtManager)
+	api := router.Group("/api/v1", requestTimeout(timeouts.Request))
+	transfers := router.Group("/api/v1", requestTimeout(timeouts.Transfer))
+	{
+		api.GET("/ping", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"message": "pong"})
 		})
 
-		// File preview endpoint
-		api.GET("/files/:id/preview", func(c *gin.Context) {
-			// Get JWT token from either header or query parameter
-			var token string
-			authHeader := c.GetHeader("Authorization")
-			if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
-				token = strings.TrimPrefix(authHeader, "Bearer ")
-			} else {
-				token = c.Query("token")
-			}
-
-			if token == "" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-				return
-			}
-
-			claims, err := jwtManager.ValidateToken(token)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-				return
-			}
-
-			fileID := c.Param("id")
-			userUUID, _ := uuid.Parse(claims.UserID)
-			fileUUID, err := uuid.Parse(fileID)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file ID"})
-				return
-			}
-
-			// Get file metadata directly from database (works for both owned and shared files)
-			var targetFile domain.File
-			var folderID sql.NullString
-			var description sql.NullString
-			var shareToken sql.NullString
-			err = infra.DB.QueryRow(c.Request.Context(), `
-				SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
-					   content_hash, description, tags, visibility, share_token, download_count, upload_date
-				FROM files
-				WHERE id = $1 AND user_id = $2`, fileUUID, userUUID).Scan(
-				&targetFile.ID, &targetFile.UserID, &folderID, &targetFile.Filename, &targetFile.OriginalName,
-				&targetFile.MimeType, &targetFile.FileSize, &targetFile.ContentHash, &description, &targetFile.Tags,
-				&targetFile.Visibility, &shareToken, &targetFile.DownloadCount, &targetFile.UploadDate)
+		// File upload endpoint
+		transfers.POST("/files/upload", requireAuth, uploads.upload)
 
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "file not found or access denied"})
-				return
-			}
+		// Direct-to-storage upload: get a presigned URL, PUT the content there, then finalize
+		api.POST("/files/upload-url", requireAuth, uploads.uploadURL)
+		transfers.POST("/files/finalize", requireAuth, uploads.finalize)
 
-			// Get the correct file path from file_contents table
-			var filePath string
-			err = infra.DB.QueryRow(c.Request.Context(), `
-				SELECT file_path FROM file_contents WHERE content_hash = $1`, targetFile.ContentHash).Scan(&filePath)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file path"})
-				return
-			}
+		// Resumable upload: start a session, PUT chunks by index, check what arrived, then complete
+		api.POST("/files/uploads", requireAuth, uploads.initiateUpload)
+		api.GET("/files/uploads/:id", requireAuth, uploads.uploadStatus)
+		transfers.PUT("/files/uploads/:id/chunks/:index", requireAuth, uploads.putChunk)
+		transfers.POST("/files/uploads/:id/complete", requireAuth, uploads.completeUpload)
+		api.DELETE("/files/uploads/:id", requireAuth, uploads.abortUpload)
 
-			// Get file content from storage using the correct path
-			content, err := storageService.GetFile(c.Request.Context(), filePath)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file content"})
-				return
-			}
+		// Skip uploading content the server already has: check by hash, then reference it
+		api.POST("/files/check", requireAuth, uploads.check)
+		api.POST("/files/reference", requireAuth, uploads.reference)
 
-			// Log successful preview
-			auditService.LogFilePreview(c.Request.Context(), userUUID, targetFile.ID, targetFile.OriginalName, c.ClientIP(), c.GetHeader("User-Agent"))
+		// File download endpoint
+		transfers.GET("/files/:id/download", requireAuth, downloads.download)
 
-			// Set headers for inline display
-			c.Header("Content-Type", targetFile.MimeType)
-			c.Header("Content-Length", fmt.Sprintf("%d", len(content)))
+		// Zip of every file carrying a tag, paged with ?limit= and ?offset=
+		transfers.GET("/tags/:tag/download", requireAuth, downloads.downloadTag)
 
-			// Send file content inline
-			c.Data(http.StatusOK, targetFile.MimeType, content)
-		})
+		// File preview endpoint; the token may also be sent as ?token= by <img> and <video> tags.
+		// ?as=image renders a PDF's first page as a PNG.
+		transfers.GET("/files/:id/preview", authRequiredWithQueryToken(jwtManager), downloads.preview)
 
 		// File sharing endpoints
 
 		// Create public share
-		api.POST("/files/:id/share/public", func(c *gin.Context) {
-			// Get JWT token and validate user
-			authHeader := c.GetHeader("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-				return
-			}
-
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			claims, err := jwtManager.ValidateToken(token)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-				return
-			}
-
+		api.POST("/files/:id/share/public", requireAuth, func(c *gin.Context) {
 			fileID := c.Param("id")
 			fileUUID, err := uuid.Parse(fileID)
 			if err != nil {
@@ -385,7 +347,7 @@ func main() {
 				return
 			}
 
-			userUUID, _ := uuid.Parse(claims.UserID)
+			userUUID := authUserID(c)
 
 			shareResponse, err := fileSharingService.CreatePublicShare(c.Request.Context(), fileUUID, userUUID)
 			if err != nil {
@@ -407,21 +369,7 @@ func main() {
 		})
 
 		// Remove public share
-		api.DELETE("/files/:id/share/public", func(c *gin.Context) {
-			// Get JWT token and validate user
-			authHeader := c.GetHeader("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-				return
-			}
-
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			claims, err := jwtManager.ValidateToken(token)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-				return
-			}
-
+		api.DELETE("/files/:id/share/public", requireAuth, func(c *gin.Context) {
 			fileID := c.Param("id")
 			fileUUID, err := uuid.Parse(fileID)
 			if err != nil {
@@ -429,7 +377,7 @@ func main() {
 				return
 			}
 
-			userUUID, _ := uuid.Parse(claims.UserID)
+			userUUID := authUserID(c)
 
 			err = fileSharingService.RemovePublicShare(c.Request.Context(), fileUUID, userUUID)
 			if err != nil {
@@ -441,21 +389,7 @@ func main() {
 		})
 
 		// Share with user
-		api.POST("/files/:id/share/user", func(c *gin.Context) {
-			// Get JWT token and validate user
-			authHeader := c.GetHeader("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-				return
-			}
-
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			claims, err := jwtManager.ValidateToken(token)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-				return
-			}
-
+		api.POST("/files/:id/share/user", requireAuth, func(c *gin.Context) {
 			fileID := c.Param("id")
 			fileUUID, err := uuid.Parse(fileID)
 			if err != nil {
@@ -469,30 +403,17 @@ func main() {
 				ExpiresAt        *time.Time `json:"expiresAt"`
 			}
 
-			// Debug: log request body
-			body, _ := c.GetRawData()
-			fmt.Printf("DEBUG: Raw request body: %s\n", string(body))
-
-			// Reset body for binding
-			c.Request.Body = io.NopCloser(strings.NewReader(string(body)))
-
 			if err := c.ShouldBindJSON(&shareRequest); err != nil {
-				fmt.Printf("DEBUG: JSON binding error: %v\n", err)
 				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
 				return
 			}
 
-			fmt.Printf("DEBUG: Parsed request - SharedWithUserID: '%s', PermissionType: '%s'\n", shareRequest.SharedWithUserID, shareRequest.PermissionType)
-
-			// Manual validation since binding validation might be the issue
 			if shareRequest.SharedWithUserID == "" {
-				fmt.Printf("DEBUG: SharedWithUserID is empty\n")
 				c.JSON(http.StatusBadRequest, gin.H{"error": "sharedWithUserId is required"})
 				return
 			}
 
 			if shareRequest.PermissionType == "" {
-				fmt.Printf("DEBUG: PermissionType is empty\n")
 				c.JSON(http.StatusBadRequest, gin.H{"error": "permissionType is required"})
 				return
 			}
@@ -503,7 +424,7 @@ func main() {
 				return
 			}
 
-			userUUID, _ := uuid.Parse(claims.UserID)
+			userUUID := authUserID(c)
 
 			input := domain.ShareFileInput{
 				FileID:           fileUUID,
@@ -522,21 +443,7 @@ func main() {
 		})
 
 		// Remove user share
-		api.DELETE("/files/:id/share/user/:userId", func(c *gin.Context) {
-			// Get JWT token and validate user
-			authHeader := c.GetHeader("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-				return
-			}
-
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			claims, err := jwtManager.ValidateToken(token)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-				return
-			}
-
+		api.DELETE("/files/:id/share/user/:userId", requireAuth, func(c *gin.Context) {
 			fileID := c.Param("id")
 			fileUUID, err := uuid.Parse(fileID)
 			if err != nil {
@@ -551,7 +458,7 @@ func main() {
 				return
 			}
 
-			userUUID, _ := uuid.Parse(claims.UserID)
+			userUUID := authUserID(c)
 
 			err = fileSharingService.RemoveUserShare(c.Request.Context(), fileUUID, sharedWithUserUUID, userUUID)
 			if err != nil {
@@ -563,21 +470,7 @@ func main() {
 		})
 
 		// Get file sharing info
-		api.GET("/files/:id/share", func(c *gin.Context) {
-			// Get JWT token and validate user
-			authHeader := c.GetHeader("Authorization")
-			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-				return
-			}
-
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			claims, err := jwtManager.ValidateToken(token)
-			if err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-				return
-			}
-
+		api.GET("/files/:id/share", requireAuth, func(c *gin.Context) {
 			fileID := c.Param("id")
 			fileUUID, err := uuid.Parse(fileID)
 			if err != nil {
@@ -585,7 +478,7 @@ func main() {
 				return
 			}
 
-			userUUID, _ := uuid.Parse(claims.UserID)
+			userUUID := authUserID(c)
 
 			shareInfo, err := fileSharingService.GetFileShareInfo(c.Request.Context(), fileUUID, userUUID)
 			if err != nil {
@@ -596,64 +489,96 @@ func main() {
 			c.JSON(http.StatusOK, shareInfo)
 		})
 
-		// Public file access (no auth required)
-		api.GET("/shared/:token", func(c *gin.Context) {
-			shareToken := c.Param("token")
+		// Export audit logs as CSV or NDJSON
+		transfers.GET("/audit/export", requireAuth, func(c *gin.Context) {
+			userUUID := authUserID(c)
+			filter := domain.AuditLogFilter{UserID: &userUUID}
+			var err error
+
+			// Enterprise admins can export the activity of every member
+			if c.Query("scope") == "enterprise" {
+				user, err := userService.GetUserByID(userUUID)
+				if err != nil || user.EnterpriseID == nil || user.EnterpriseRole == nil ||
+					(*user.EnterpriseRole != domain.EnterpriseRoleOwner && *user.EnterpriseRole != domain.EnterpriseRoleAdmin) {
+					c.JSON(http.StatusForbidden, gin.H{"error": "enterprise admin access required"})
+					return
+				}
+				filter.UserID = nil
+				filter.EnterpriseID = user.EnterpriseID
+			}
 
-			file, err := fileSharingService.GetFileByShareToken(c.Request.Context(), shareToken)
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Shared file not found"})
+			if filter.From, err = services.ParseAuditDateBound(c.Query("from"), false); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
 				return
 			}
-
-			// Increment download count
-			fileSharingService.IncrementDownloadCount(c.Request.Context(), file.ID)
-
-			// Get file content from storage
-			content, err := storageService.GetFile(c.Request.Context(), fmt.Sprintf("personal/users/%s/%s", file.UserID.String(), file.ContentHash))
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file content"})
+			if filter.To, err = services.ParseAuditDateBound(c.Query("to"), true); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
 				return
 			}
+			if action := c.Query("action"); action != "" {
+				auditAction := domain.AuditAction(action)
+				filter.Action = &auditAction
+			}
+			if status := c.Query("status"); status != "" {
+				auditStatus := domain.AuditStatus(status)
+				filter.Status = &auditStatus
+			}
 
-			// Set headers for download
-			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", file.OriginalName))
-			c.Header("Content-Type", file.MimeType)
-			c.Header("Content-Length", fmt.Sprintf("%d", len(content)))
-
-			// Send file content
-			c.Data(http.StatusOK, file.MimeType, content)
-		})
-
-		// Public file preview (no auth required)
-		api.GET("/shared/:token/preview", func(c *gin.Context) {
-			shareToken := c.Param("token")
-
-			file, err := fileSharingService.GetFileByShareToken(c.Request.Context(), shareToken)
-			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Shared file not found"})
+			format := c.DefaultQuery("format", services.AuditExportCSV)
+			switch format {
+			case services.AuditExportCSV:
+				c.Header("Content-Type", "text/csv; charset=utf-8")
+			case services.AuditExportNDJSON:
+				c.Header("Content-Type", "application/x-ndjson")
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or ndjson"})
 				return
 			}
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit-logs.%s\"", format))
+			c.Status(http.StatusOK)
 
-			// Get file content from storage
-			content, err := storageService.GetFile(c.Request.Context(), fmt.Sprintf("personal/users/%s/%s", file.UserID.String(), file.ContentHash))
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file content"})
-				return
+			// Rows are streamed straight to the response; once writing has begun the status can't change
+			if _, err := auditService.ExportAuditLogs(c.Request.Context(), c.Writer, format, filter); err != nil {
+				services.RequestLogger(c.Request.Context(), logger).Error("Failed to export audit logs", zap.String("user_id", userUUID.String()), zap.Error(err))
 			}
+		})
 
-			// Set headers for inline display
-			c.Header("Content-Type", file.MimeType)
-			c.Header("Content-Length", fmt.Sprintf("%d", len(content)))
+		// Public file access (no auth required), by share token or short code
+		transfers.GET("/shared/:token", downloads.shared)
+		transfers.GET("/s/:code", downloads.shared)
 
-			// Send file content inline
-			c.Data(http.StatusOK, file.MimeType, content)
-		})
+		// Public file preview (no auth required)
+		transfers.GET("/shared/:token/preview", downloads.sharedPreview)
+		transfers.GET("/s/:code/preview", downloads.sharedPreview)
+	}
+
+	// Single sign-on through an OpenID Connect provider, when one is configured
+	oidcProvider, err := oidcProviderFromEnv(context.Background(), os.Getenv, &http.Client{Timeout: oidcHTTPTimeout})
+	if err != nil {
+		logger.Fatal("Invalid OIDC configuration", zap.Error(err))
+	}
+	if oidcProvider != nil {
+		oidc := &oidcHandlers{
+			provider:          oidcProvider,
+			users:             userService,
+			audit:             auditService,
+			jwtManager:        jwtManager,
+			postLoginRedirect: os.Getenv("OIDC_POST_LOGIN_REDIRECT"),
+			logger:            logger,
+		}
+		router.GET("/auth/oidc/login", oidc.login)
+		router.GET("/auth/oidc/callback", oidc.callback)
+		logger.Info("OIDC sign-in enabled", zap.String("issuer", oidcProvider.Issuer()))
 	}
 
-	// GraphQL endpoint
-	router.POST("/graphql", graphqlHandler.ServeHTTP)
+	// GraphQL endpoint; subscriptions are served over a WebSocket on the same path. Queries get the
+	// API request deadline, while the long-lived subscription socket is left without one.
+	router.POST("/graphql", requestTimeout(timeouts.Request), graphqlHandler.ServeHTTP)
 	router.GET("/graphql", func(c *gin.Context) {
+		if c.IsWebsocket() {
+			subscriptionHandler.ServeHTTP(c)
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"message": "GraphQL endpoint",
 			"usage":   "Send POST requests with GraphQL queries",
@@ -692,8 +617,24 @@ func main() {
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Fatal("Server forced to shutdown", zap.Error(err))
+		logger.Error("Server forced to shutdown", zap.Error(err))
+	}
+
+	// Uploads still running once the grace period is over are aborted, so a multipart upload
+	// doesn't leave its parts behind
+	if drainer, ok := storageService.(storage.UploadDrainer); ok {
+		if err := drainer.Drain(ctx); err != nil {
+			logger.Error("Aborted in-flight uploads", zap.Error(err))
+		}
 	}
 
 	logger.Info("Server exited")
-}
\ No newline at end of file
+}
+
+// getEnvDefault returns the environment variable or a default value
+func getEnvDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
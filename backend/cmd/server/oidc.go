@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/services"
+	"lokr-backend/pkg/auth"
+)
+
+// Cookies carrying the state and nonce of a sign-in between the redirect and the callback
+const (
+	oidcStateCookie  = "lokr_oidc_state"
+	oidcNonceCookie  = "lokr_oidc_nonce"
+	oidcCookiePath   = "/auth/oidc"
+	oidcCookieMaxAge = 10 * 60
+)
+
+// oidcHTTPTimeout bounds each request to the identity provider
+const oidcHTTPTimeout = 10 * time.Second
+
+// oidcProviderFromEnv builds the single sign-on provider from the OIDC_* settings:
+//
+//	OIDC_ISSUER         provider issuer, e.g. https://accounts.google.com
+//	OIDC_CLIENT_ID      client ID registered with the provider
+//	OIDC_CLIENT_SECRET  client secret registered with the provider
+//	OIDC_REDIRECT_URL   this server's /auth/oidc/callback URL as registered with the provider
+//
+// Single sign-on is optional: without OIDC_ISSUER it returns nil and no error.
+func oidcProviderFromEnv(ctx context.Context, getenv func(string) string, client *http.Client) (*auth.OIDCProvider, error) {
+	issuer := getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	return auth.NewOIDCProvider(ctx, auth.OIDCConfig{
+		Issuer:       issuer,
+		ClientID:     getenv("OIDC_CLIENT_ID"),
+		ClientSecret: getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  getenv("OIDC_REDIRECT_URL"),
+	}, client)
+}
+
+// oidcHandlers sign users in through an OpenID Connect provider and issue Lokr tokens for them.
+// With a postLoginRedirect the callback redirects there with the tokens in the URL fragment;
+// otherwise it answers with them as JSON.
+type oidcHandlers struct {
+	provider          *auth.OIDCProvider
+	users             *services.UserService
+	audit             *services.AuditService
+	jwtManager        *auth.JWTManager
+	postLoginRedirect string
+	logger            *zap.Logger
+}
+
+// login sends the browser to the provider, remembering the state and nonce in short-lived cookies
+func (h *oidcHandlers) login(c *gin.Context) {
+	state, err := auth.GenerateRandomState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sign-in"})
+		return
+	}
+	nonce, err := auth.GenerateRandomState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sign-in"})
+		return
+	}
+
+	h.setCookie(c, oidcStateCookie, state, oidcCookieMaxAge)
+	h.setCookie(c, oidcNonceCookie, nonce, oidcCookieMaxAge)
+	c.Redirect(http.StatusFound, h.provider.AuthCodeURL(state, nonce))
+}
+
+// callback completes a sign-in: it checks the state, exchanges the code, verifies the ID token and
+// signs in the matching, linked or newly provisioned user
+func (h *oidcHandlers) callback(c *gin.Context) {
+	state, _ := c.Cookie(oidcStateCookie)
+	nonce, _ := c.Cookie(oidcNonceCookie)
+	h.setCookie(c, oidcStateCookie, "", -1)
+	h.setCookie(c, oidcNonceCookie, "", -1)
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("sign-in failed: %s", providerError)})
+		return
+	}
+	if !auth.ValidateState(state, c.Query("state")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired sign-in state"})
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing authorization code"})
+		return
+	}
+
+	identity, err := h.provider.Exchange(c.Request.Context(), code, nonce)
+	if err != nil {
+		services.RequestLogger(c.Request.Context(), h.logger).Warn("OIDC sign-in failed", zap.Error(err))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to verify sign-in"})
+		return
+	}
+	if !identity.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "the identity provider has not verified this email address"})
+		return
+	}
+
+	user, err := h.users.LoginWithOIDC(c.Request.Context(), h.provider.Issuer(), identity.Subject, identity.Email, identity.Name)
+	if err != nil {
+		if errors.Is(err, services.ErrOIDCAccountConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		services.RequestLogger(c.Request.Context(), h.logger).Error("Failed to sign in OIDC user", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+		return
+	}
+	h.users.UpdateLastLogin(user.ID)
+	h.audit.LogUserLogin(c.Request.Context(), user.ID, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	token, err := h.jwtManager.GenerateToken(user.ID.String(), user.Email, string(user.Role))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+	refreshToken, err := h.jwtManager.GenerateRefreshToken(user.ID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate refresh token"})
+		return
+	}
+
+	if h.postLoginRedirect != "" {
+		fragment := url.Values{"token": {token}, "refreshToken": {refreshToken}}
+		c.Redirect(http.StatusFound, h.postLoginRedirect+"#"+fragment.Encode())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        token,
+		"refreshToken": refreshToken,
+		"user": gin.H{
+			"id":    user.ID.String(),
+			"email": user.Email,
+			"name":  user.Name,
+			"role":  string(user.Role),
+		},
+	})
+}
+
+// setCookie sets an HTTP-only sign-in cookie scoped to the OIDC routes; a negative maxAge deletes it
+func (h *oidcHandlers) setCookie(c *gin.Context, name, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, oidcCookiePath, "", c.Request.TLS != nil, true)
+}
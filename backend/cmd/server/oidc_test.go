@@ -0,0 +1,205 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/testutil"
+	"lokr-backend/pkg/auth"
+)
+
+// newOIDCRouter serves the sign-in routes against a fake OIDC provider
+func newOIDCRouter(t *testing.T) (*gin.Engine, *pgxpool.Pool, *testutil.OIDCProvider, *auth.JWTManager) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	fake := testutil.NewOIDCProvider(t)
+	jwtManager := auth.NewJWTManager("test-secret")
+
+	env := map[string]string{
+		"OIDC_ISSUER":        fake.Issuer(),
+		"OIDC_CLIENT_ID":     fake.ClientID,
+		"OIDC_CLIENT_SECRET": "secret",
+		"OIDC_REDIRECT_URL":  "http://lokr.test/auth/oidc/callback",
+	}
+	provider, err := oidcProviderFromEnv(context.Background(), func(name string) string { return env[name] }, fake.Server.Client())
+	if err != nil {
+		t.Fatalf("oidcProviderFromEnv failed: %v", err)
+	}
+
+	oidc := &oidcHandlers{
+		provider:   provider,
+		users:      services.NewUserService(db),
+		jwtManager: jwtManager,
+		logger:     zap.NewNop(),
+	}
+	router := gin.New()
+	router.GET("/auth/oidc/login", oidc.login)
+	router.GET("/auth/oidc/callback", oidc.callback)
+
+	return router, db, fake, jwtManager
+}
+
+// signIn runs the sign-in flow for an identity the fake provider vouches for and returns the
+// callback response
+func signIn(t *testing.T, router *gin.Engine, fake *testutil.OIDCProvider, subject string, claims jwt.MapClaims) *httptest.ResponseRecorder {
+	t.Helper()
+
+	login := httptest.NewRecorder()
+	router.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	if login.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect to the provider, got %d", login.Code)
+	}
+	location, err := url.Parse(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Invalid redirect: %v", err)
+	}
+
+	withNonce := jwt.MapClaims{"nonce": location.Query().Get("nonce")}
+	for name, value := range claims {
+		withNonce[name] = value
+	}
+	fake.SetIDToken(fake.IDToken(t, subject, withNonce))
+
+	callback := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=the-code&state="+url.QueryEscape(location.Query().Get("state")), nil)
+	for _, cookie := range login.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, callback)
+	return recorder
+}
+
+// signedInUser checks a successful callback response and returns the user its token is for
+func signedInUser(t *testing.T, recorder *httptest.ResponseRecorder, jwtManager *auth.JWTManager) uuid.UUID {
+	t.Helper()
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var body struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	claims, err := jwtManager.ValidateToken(body.Token)
+	if err != nil {
+		t.Fatalf("Expected a valid access token, got %v", err)
+	}
+	if body.RefreshToken == "" {
+		t.Error("Expected a refresh token")
+	}
+	return uuid.MustParse(claims.UserID)
+}
+
+func TestOIDC_ProvisionsNewUser(t *testing.T) {
+	router, db, fake, jwtManager := newOIDCRouter(t)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	emailDomain := uuid.NewString()[:8] + ".example"
+	db.Exec(ctx, "UPDATE enterprises SET domain = $1, current_users = 0 WHERE id = $2", emailDomain, enterpriseID)
+
+	email := "grace@" + emailDomain
+	t.Cleanup(func() { db.Exec(context.Background(), "DELETE FROM users WHERE email = $1", email) })
+	subject := "google-" + uuid.NewString()
+
+	userID := signedInUser(t, signIn(t, router, fake, subject, jwt.MapClaims{
+		"email":          email,
+		"email_verified": true,
+		"name":           "Grace Hopper",
+	}), jwtManager)
+
+	var name, storedSubject string
+	var memberOf *uuid.UUID
+	var role *string
+	db.QueryRow(ctx, "SELECT name, oidc_subject, enterprise_id, enterprise_role FROM users WHERE id = $1", userID).
+		Scan(&name, &storedSubject, &memberOf, &role)
+	if name != "Grace Hopper" || storedSubject != subject {
+		t.Errorf("Expected Grace Hopper linked to %s, got %q linked to %q", subject, name, storedSubject)
+	}
+	if memberOf == nil || *memberOf != enterpriseID || role == nil || *role != "MEMBER" {
+		t.Errorf("Expected the user to join the enterprise for %s as MEMBER", emailDomain)
+	}
+
+	var currentUsers int
+	db.QueryRow(ctx, "SELECT current_users FROM enterprises WHERE id = $1", enterpriseID).Scan(&currentUsers)
+	if currentUsers != 1 {
+		t.Errorf("Expected the enterprise to count 1 user, got %d", currentUsers)
+	}
+
+	// Signing in again finds the same user by subject, even after the email changes
+	again := signedInUser(t, signIn(t, router, fake, subject, jwt.MapClaims{
+		"email":          "grace.hopper@" + emailDomain,
+		"email_verified": true,
+	}), jwtManager)
+	if again != userID {
+		t.Errorf("Expected the same user on a second sign-in, got %s and %s", userID, again)
+	}
+}
+
+func TestOIDC_LinksExistingUser(t *testing.T) {
+	router, db, fake, jwtManager := newOIDCRouter(t)
+	ctx := context.Background()
+
+	existing := testutil.CreateUser(t, db, "linked", nil)
+	var email string
+	db.QueryRow(ctx, "SELECT email FROM users WHERE id = $1", existing).Scan(&email)
+	subject := "google-" + uuid.NewString()
+
+	t.Run("unverified email refused", func(t *testing.T) {
+		recorder := signIn(t, router, fake, subject, jwt.MapClaims{"email": email, "email_verified": false})
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("verified email links", func(t *testing.T) {
+		userID := signedInUser(t, signIn(t, router, fake, subject, jwt.MapClaims{
+			"email":          email,
+			"email_verified": true,
+		}), jwtManager)
+		if userID != existing {
+			t.Fatalf("Expected the existing user %s, got %s", existing, userID)
+		}
+
+		var storedSubject *string
+		db.QueryRow(ctx, "SELECT oidc_subject FROM users WHERE id = $1", existing).Scan(&storedSubject)
+		if storedSubject == nil || *storedSubject != subject {
+			t.Errorf("Expected the user to be linked to %s, got %v", subject, storedSubject)
+		}
+	})
+
+	t.Run("another identity with the same email conflicts", func(t *testing.T) {
+		recorder := signIn(t, router, fake, "google-"+uuid.NewString(), jwt.MapClaims{"email": email, "email_verified": true})
+		if recorder.Code != http.StatusConflict {
+			t.Errorf("Expected status 409, got %d", recorder.Code)
+		}
+	})
+}
+
+func TestOIDC_RejectsBadState(t *testing.T) {
+	router, _, _, _ := newOIDCRouter(t)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=the-code&state=forged", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without the state cookie, got %d", recorder.Code)
+	}
+}
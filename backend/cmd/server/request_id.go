@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID limits client-supplied IDs to a short run of characters that are safe to echo in
+// a header and to write to logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID tags every request with an ID: the client's X-Request-ID when it sends a usable one,
+// otherwise a fresh UUID. The ID is echoed in the response header and stored on the request
+// context, where services.RequestLogger picks it up for every log line written for the request.
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		c.Header(requestIDHeader, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), "requestID", id))
+		c.Next()
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"lokr-backend/internal/services"
+)
+
+// newRequestIDRouter serves one route behind the request ID middleware that logs through the
+// request logger into the returned observer
+func newRequestIDRouter() (*gin.Engine, *observer.ObservedLogs) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	router := gin.New()
+	router.Use(requestID())
+	router.GET("/ping", func(c *gin.Context) {
+		services.RequestLogger(c.Request.Context(), logger).Info("handled")
+		c.Status(http.StatusNoContent)
+	})
+	return router, logs
+}
+
+func serveWithRequestID(router *gin.Engine, requestID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	if requestID != "" {
+		req.Header.Set(requestIDHeader, requestID)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRequestID_EchoesProvidedID(t *testing.T) {
+	router, logs := newRequestIDRouter()
+
+	recorder := serveWithRequestID(router, "client-abc.123")
+	if got := recorder.Header().Get(requestIDHeader); got != "client-abc.123" {
+		t.Errorf("Expected the provided ID to be echoed, got %q", got)
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("Expected one log entry, got %d", len(entries))
+	}
+	if got := entries[0].ContextMap()["request_id"]; got != "client-abc.123" {
+		t.Errorf("Expected the log entry to carry request_id client-abc.123, got %v", got)
+	}
+}
+
+func TestRequestID_GeneratesUniqueIDs(t *testing.T) {
+	router, _ := newRequestIDRouter()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		id := serveWithRequestID(router, "").Header().Get(requestIDHeader)
+		if id == "" {
+			t.Fatal("Expected a generated request ID")
+		}
+		if seen[id] {
+			t.Fatalf("Expected unique request IDs, got %s twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestRequestID_ReplacesUnusableIDs(t *testing.T) {
+	router, _ := newRequestIDRouter()
+
+	for _, provided := range []string{"has spaces", "semi;colon", strings.Repeat("a", 129)} {
+		id := serveWithRequestID(router, provided).Header().Get(requestIDHeader)
+		if id == provided || id == "" {
+			t.Errorf("Expected %q to be replaced with a generated ID, got %q", provided, id)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"lokr-backend/internal/services"
+)
+
+// shareLinksFromEnv reads how public share links look: SHARE_BASE_URL is the frontend they point at
+// (http://localhost:3000 by default) and SHARE_SHORT_LINKS=true also hands out short /s/<code>
+// links
+func shareLinksFromEnv(getenv func(string) string) (services.ShareLinkConfig, error) {
+	config := services.ShareLinkConfig{BaseURL: services.DefaultShareBaseURL}
+
+	if raw := getenv("SHARE_BASE_URL"); raw != "" {
+		base := strings.TrimSuffix(strings.TrimSpace(raw), "/")
+		parsed, err := url.Parse(base)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+			parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+			return services.ShareLinkConfig{}, fmt.Errorf("SHARE_BASE_URL must be an http or https URL such as https://lokr.example.com, got %q", raw)
+		}
+		config.BaseURL = base
+	}
+
+	if raw := getenv("SHARE_SHORT_LINKS"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return services.ShareLinkConfig{}, fmt.Errorf("SHARE_SHORT_LINKS must be true or false, got %q", raw)
+		}
+		config.ShortCodes = enabled
+	}
+	return config, nil
+}
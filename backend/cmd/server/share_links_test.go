@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"lokr-backend/internal/services"
+)
+
+func TestShareLinksFromEnv(t *testing.T) {
+	config, err := shareLinksFromEnv(envOf(nil))
+	if err != nil || config.BaseURL != services.DefaultShareBaseURL || config.ShortCodes {
+		t.Errorf("Expected the development frontend without short links, got %+v, %v", config, err)
+	}
+
+	config, err = shareLinksFromEnv(envOf(map[string]string{"SHARE_BASE_URL": "https://lokr.example.com/app/", "SHARE_SHORT_LINKS": "true"}))
+	if err != nil || config.BaseURL != "https://lokr.example.com/app" || !config.ShortCodes {
+		t.Errorf("Expected https://lokr.example.com/app with short links, got %+v, %v", config, err)
+	}
+
+	for _, env := range []map[string]string{
+		{"SHARE_BASE_URL": "lokr.example.com"},
+		{"SHARE_BASE_URL": "ftp://lokr.example.com"},
+		{"SHARE_BASE_URL": "https://lokr.example.com/?ref=share"},
+		{"SHARE_SHORT_LINKS": "sometimes"},
+	} {
+		if _, err := shareLinksFromEnv(envOf(env)); err == nil {
+			t.Errorf("Expected %v to be rejected", env)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"lokr-backend/internal/domain"
+)
+
+// Page sizes for tag archives; a tag on more files than maxTagArchiveFiles is downloaded in
+// several archives using ?offset=
+const (
+	defaultTagArchiveFiles = 100
+	maxTagArchiveFiles     = 500
+)
+
+// downloadTag streams a zip of the caller's files carrying the tag, oldest first. ?limit= (at most
+// maxTagArchiveFiles) and ?offset= page through large tags; X-Total-Count is how many files carry
+// the tag. Every file in the archive counts as downloaded.
+func (h *downloadHandlers) downloadTag(c *gin.Context) {
+	userUUID := authUserID(c)
+	tag := strings.TrimSpace(c.Param("tag"))
+	if tag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag is required"})
+		return
+	}
+
+	limit, offset := defaultTagArchiveFiles, 0
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxTagArchiveFiles {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxTagArchiveFiles)})
+			return
+		}
+		limit = parsed
+	}
+	if raw := c.Query("offset"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+			return
+		}
+		offset = parsed
+	}
+
+	ctx := c.Request.Context()
+	total, err := h.files.CountFilesByTag(ctx, userUUID, tag)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count files"})
+		return
+	}
+	files, err := h.files.GetFilesByTag(ctx, userUUID, tag, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list files"})
+		return
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no files with this tag"})
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	guardScriptable(c, "application/zip")
+	c.Header("Content-Disposition", contentDisposition(tag+".zip"))
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.Status(http.StatusOK)
+
+	// Once the archive has started the status can't change; a failure leaves it without its
+	// central directory, which clients report as a corrupt download
+	archive := zip.NewWriter(c.Writer)
+	usedNames := make(map[string]bool, len(files))
+	for _, file := range files {
+		if err := h.addToArchive(ctx, archive, file, uniqueArchiveName(usedNames, file.OriginalName)); err != nil {
+			c.Error(fmt.Errorf("failed to archive file %s: %w", file.ID, err))
+			return
+		}
+		h.files.RecordDownload(ctx, file.ID, &userUUID, c.ClientIP())
+		h.audit.LogFileDownload(ctx, userUUID, file.ID, file.OriginalName, c.ClientIP(), c.GetHeader("User-Agent"))
+	}
+	if err := archive.Close(); err != nil {
+		c.Error(fmt.Errorf("failed to finish archive: %w", err))
+	}
+}
+
+// addToArchive copies the stored content of file into the archive under name. Content that is
+// already compressed is stored as it is rather than deflated again.
+func (h *downloadHandlers) addToArchive(ctx context.Context, archive *zip.Writer, file *domain.File, name string) error {
+	filePath, err := h.contentPath(ctx, file.ContentHash)
+	if err != nil {
+		return err
+	}
+	reader, err := h.storage.Get(ctx, filePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	header := &zip.FileHeader{Name: name, Method: zip.Store, Modified: file.UploadDate}
+	if compressible(file.MimeType) {
+		header.Method = zip.Deflate
+	}
+	entry, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, reader)
+	return err
+}
+
+// uniqueArchiveName returns name, or name with " (2)", " (3)" and so on before its extension when
+// an earlier entry already took it, and marks the result as used
+func uniqueArchiveName(used map[string]bool, name string) string {
+	name = sanitizeDownloadName(name)
+	if name == "" {
+		name = "file"
+	}
+
+	candidate := name
+	ext := path.Ext(name)
+	for n := 2; used[candidate]; n++ {
+		candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
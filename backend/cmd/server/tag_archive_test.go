@@ -0,0 +1,115 @@
+//go:build integration
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/storage"
+	"lokr-backend/internal/testutil"
+	"lokr-backend/pkg/auth"
+)
+
+func TestDownloadTag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	store, _ := testutil.NewLocalStorage(t)
+	auditService := services.NewAuditService(db, zap.NewNop())
+	jwtManager := auth.NewJWTManager("test-secret")
+	downloads := &downloadHandlers{
+		db:      db,
+		storage: store,
+		files:   services.NewSimpleFileService(db, store, nil, nil, zap.NewNop()),
+		audit:   auditService,
+	}
+	router := gin.New()
+	router.GET("/tags/:tag/download", authRequired(jwtManager), downloads.downloadTag)
+
+	ctx := context.Background()
+	userID := testutil.CreateUser(t, db, "archiver", nil)
+	contents := map[string]string{}
+	for i, name := range []string{"notes.txt", "notes.txt", "other.txt"} {
+		content := fmt.Sprintf("%s %d", name, i)
+		fileID := testutil.CreateFile(t, db, userID, name, []byte(content))
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+		if err := store.Store(ctx, storage.ContentPath("", userID.String(), hash), bytes.NewReader([]byte(content)), "text/plain"); err != nil {
+			t.Fatalf("Failed to store content: %v", err)
+		}
+		tags := []string{"trip"}
+		if name == "other.txt" {
+			tags = []string{"work"}
+		}
+		db.Exec(ctx, "UPDATE files SET tags = $1, upload_date = NOW() + make_interval(secs => $2) WHERE id = $3", tags, i, fileID)
+		contents[fileID.String()] = content
+	}
+	token, err := jwtManager.GenerateToken(userID.String(), "archiver@example.com", "USER")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	recorder := serve("/tags/trip/download")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if total := recorder.Header().Get("X-Total-Count"); total != "2" {
+		t.Errorf("Expected X-Total-Count 2, got %q", total)
+	}
+	if got := recorder.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("Expected nosniff, got %q", got)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(recorder.Body.Bytes()), int64(recorder.Body.Len()))
+	if err != nil {
+		t.Fatalf("Expected a readable zip: %v", err)
+	}
+	want := map[string]string{"notes.txt": "notes.txt 0", "notes (2).txt": "notes.txt 1"}
+	if len(archive.File) != len(want) {
+		t.Fatalf("Expected %d entries, got %d", len(want), len(archive.File))
+	}
+	for _, entry := range archive.File {
+		reader, err := entry.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", entry.Name, err)
+		}
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(content) != want[entry.Name] {
+			t.Errorf("Expected %s to hold %q, got %q", entry.Name, want[entry.Name], content)
+		}
+	}
+
+	var downloaded int
+	db.QueryRow(ctx, "SELECT COALESCE(SUM(download_count), 0) FROM files WHERE user_id = $1", userID).Scan(&downloaded)
+	if downloaded != 2 {
+		t.Errorf("Expected both archived files to count as downloaded, got %d", downloaded)
+	}
+
+	if recorder := serve("/tags/trip/download?limit=1&offset=1"); recorder.Code != http.StatusOK {
+		t.Errorf("Expected the second page to download, got %d", recorder.Code)
+	}
+	if recorder := serve("/tags/missing/download"); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a tag on no files, got %d", recorder.Code)
+	}
+	if recorder := serve("/tags/trip/download?limit=1000"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an oversized page, got %d", recorder.Code)
+	}
+}
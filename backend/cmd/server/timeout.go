@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Defaults for REQUEST_TIMEOUT and TRANSFER_TIMEOUT
+const (
+	defaultRequestTimeout  = 30 * time.Second
+	defaultTransferTimeout = 10 * time.Minute
+)
+
+// requestTimeouts are the deadlines given to API requests: transfers move file content and get
+// the longer one. Zero leaves requests without a deadline.
+type requestTimeouts struct {
+	Request  time.Duration
+	Transfer time.Duration
+}
+
+// requestTimeoutsFromEnv reads REQUEST_TIMEOUT and TRANSFER_TIMEOUT as Go durations, e.g. "30s"
+func requestTimeoutsFromEnv(getenv func(string) string) (requestTimeouts, error) {
+	timeouts := requestTimeouts{Request: defaultRequestTimeout, Transfer: defaultTransferTimeout}
+	for _, setting := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"REQUEST_TIMEOUT", &timeouts.Request},
+		{"TRANSFER_TIMEOUT", &timeouts.Transfer},
+	} {
+		raw := getenv(setting.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 {
+			return requestTimeouts{}, fmt.Errorf("%s must be a non-negative duration such as 30s, got %q", setting.name, raw)
+		}
+		*setting.value = parsed
+	}
+	return timeouts, nil
+}
+
+// requestTimeout gives each request a context that is cancelled after timeout, so storage and
+// database calls made with it give up instead of holding the connection. A request that fails
+// because its deadline passed, or ends without a response, is answered 504.
+func requestTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		writer := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.timedOut || (!writer.Written() && errors.Is(ctx.Err(), context.DeadlineExceeded)) {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "request timed out"})
+		}
+	}
+}
+
+// timeoutWriter holds back the error response of a handler whose context deadline has passed, so
+// requestTimeout can answer 504 instead; responses already under way are left alone
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	timedOut bool
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if !w.Written() && code >= http.StatusInternalServerError && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if !w.timedOut {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if w.timedOut {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.timedOut {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
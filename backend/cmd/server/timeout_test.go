@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"lokr-backend/internal/storage"
+	"lokr-backend/internal/testutil"
+)
+
+// slowStorage is memory storage whose Get hangs until the caller's context is done, like an S3
+// request that never answers
+type slowStorage struct {
+	*testutil.MemoryStorage
+}
+
+func (s slowStorage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRequestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := slowStorage{testutil.NewMemoryStorage()}
+
+	router := gin.New()
+	router.GET("/content", requestTimeout(50*time.Millisecond), func(c *gin.Context) {
+		content, err := storage.ReadAll(c.Request.Context(), store, "stuck")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get file content"})
+			return
+		}
+		c.Data(http.StatusOK, "text/plain", content)
+	})
+	router.GET("/fast", requestTimeout(50*time.Millisecond), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/failing", requestTimeout(time.Minute), func(c *gin.Context) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "broken"})
+	})
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		return recorder
+	}
+
+	t.Run("a stuck storage call is cancelled at the deadline", func(t *testing.T) {
+		start := time.Now()
+		recorder := serve("/content")
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected the request to give up after 50ms, took %v", elapsed)
+		}
+		if recorder.Code != http.StatusGatewayTimeout {
+			t.Errorf("Expected 504, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+	})
+
+	t.Run("requests within the deadline are untouched", func(t *testing.T) {
+		if recorder := serve("/fast"); recorder.Code != http.StatusNoContent {
+			t.Errorf("Expected 204, got %d", recorder.Code)
+		}
+		if recorder := serve("/failing"); recorder.Code != http.StatusInternalServerError {
+			t.Errorf("Expected errors before the deadline to keep their status, got %d", recorder.Code)
+		}
+	})
+}
+
+func TestRequestTimeoutsFromEnv(t *testing.T) {
+	timeouts, err := requestTimeoutsFromEnv(envOf(nil))
+	if err != nil || timeouts.Request != defaultRequestTimeout || timeouts.Transfer != defaultTransferTimeout {
+		t.Errorf("Expected the defaults, got %+v, %v", timeouts, err)
+	}
+
+	timeouts, err = requestTimeoutsFromEnv(envOf(map[string]string{"REQUEST_TIMEOUT": "5s", "TRANSFER_TIMEOUT": "0"}))
+	if err != nil || timeouts.Request != 5*time.Second || timeouts.Transfer != 0 {
+		t.Errorf("Expected 5s and no transfer deadline, got %+v, %v", timeouts, err)
+	}
+
+	for _, value := range []string{"soon", "-1s"} {
+		if _, err := requestTimeoutsFromEnv(envOf(map[string]string{"REQUEST_TIMEOUT": value})); err == nil {
+			t.Errorf("Expected REQUEST_TIMEOUT=%q to be rejected", value)
+		}
+	}
+}
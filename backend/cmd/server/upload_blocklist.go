@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"lokr-backend/internal/services"
+)
+
+// defaultBlockedExtensions apply when BLOCKED_UPLOAD_EXTENSIONS is unset: Windows executables and
+// the script types Windows runs when they are opened
+var defaultBlockedExtensions = []string{".exe", ".bat", ".cmd", ".scr", ".msi", ".pif", ".vbs", ".js", ".jse", ".wsf", ".ps1", ".hta"}
+
+// uploadBlocklistFromEnv reads BLOCKED_UPLOAD_EXTENSIONS, comma-separated extensions such as
+// ".exe,.bat" ("none" blocks none), and BLOCKED_UPLOAD_TYPES, comma-separated types that may end
+// in "/*", such as "application/x-msdownload,text/html"
+func uploadBlocklistFromEnv(getenv func(string) string) (services.UploadBlocklist, error) {
+	blocklist := services.UploadBlocklist{Extensions: defaultBlockedExtensions}
+
+	if value := strings.TrimSpace(getenv("BLOCKED_UPLOAD_EXTENSIONS")); value != "" {
+		blocklist.Extensions = nil
+		if !strings.EqualFold(value, "none") {
+			for _, extension := range strings.Split(value, ",") {
+				extension = strings.ToLower(strings.TrimSpace(extension))
+				if extension == "" {
+					continue
+				}
+				if !strings.HasPrefix(extension, ".") {
+					extension = "." + extension
+				}
+				if extension == "." || strings.ContainsAny(extension[1:], "./\\ ") {
+					return services.UploadBlocklist{}, fmt.Errorf("BLOCKED_UPLOAD_EXTENSIONS entries must look like .ext, got %q", extension)
+				}
+				blocklist.Extensions = append(blocklist.Extensions, extension)
+			}
+		}
+	}
+
+	for _, mimeType := range strings.Split(getenv("BLOCKED_UPLOAD_TYPES"), ",") {
+		mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+		if mimeType == "" {
+			continue
+		}
+		major, minor, ok := strings.Cut(mimeType, "/")
+		if !ok || major == "" || minor == "" || major == "*" {
+			return services.UploadBlocklist{}, fmt.Errorf("BLOCKED_UPLOAD_TYPES entries must look like type/subtype, got %q", mimeType)
+		}
+		blocklist.MimeTypes = append(blocklist.MimeTypes, mimeType)
+	}
+	return blocklist, nil
+}
+
+// rejectBlocked answers 400 when err is the blocklist refusing filename, reporting whether it did
+func rejectBlocked(c *gin.Context, filename string, err error) bool {
+	var blocked *services.BlockedUploadError
+	if !errors.As(err, &blocked) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", filename, blocked.Error())})
+	return true
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"lokr-backend/internal/services"
+)
+
+func TestUploadBlocklistFromEnv(t *testing.T) {
+	blocklist, err := uploadBlocklistFromEnv(envOf(nil))
+	if err != nil || len(blocklist.Extensions) != len(defaultBlockedExtensions) || len(blocklist.MimeTypes) != 0 {
+		t.Errorf("Expected the default extensions alone, got %+v, %v", blocklist, err)
+	}
+
+	blocklist, err = uploadBlocklistFromEnv(envOf(map[string]string{
+		"BLOCKED_UPLOAD_EXTENSIONS": " .EXE, sh ,",
+		"BLOCKED_UPLOAD_TYPES":      "application/x-msdownload, Text/*",
+	}))
+	if err != nil {
+		t.Fatalf("uploadBlocklistFromEnv failed: %v", err)
+	}
+	for _, tt := range []struct {
+		filename, mimeType string
+		blocked            bool
+	}{
+		{"setup.exe", "application/octet-stream", true},
+		{"Deploy.SH", "application/octet-stream", true},
+		{"trailing.exe. ", "application/octet-stream", true},
+		{"app.js", "application/octet-stream", false},
+		{"notes", "text/plain; charset=utf-8", true},
+		{"tool", "application/exe", true},
+		{"photo.png", "image/png", false},
+	} {
+		err := blocklist.Check(tt.filename, tt.mimeType)
+		var blocked *services.BlockedUploadError
+		if errors.As(err, &blocked) != tt.blocked {
+			t.Errorf("Expected %q of %s blocked=%v, got %v", tt.filename, tt.mimeType, tt.blocked, err)
+		}
+	}
+
+	blocklist, err = uploadBlocklistFromEnv(envOf(map[string]string{"BLOCKED_UPLOAD_EXTENSIONS": "none"}))
+	if err != nil || blocklist.Check("setup.exe", "application/octet-stream") != nil {
+		t.Errorf("Expected none to allow every extension, got %+v, %v", blocklist, err)
+	}
+
+	for _, env := range []map[string]string{
+		{"BLOCKED_UPLOAD_EXTENSIONS": ".tar.gz"},
+		{"BLOCKED_UPLOAD_EXTENSIONS": "."},
+		{"BLOCKED_UPLOAD_TYPES": "executable"},
+		{"BLOCKED_UPLOAD_TYPES": "*/*"},
+	} {
+		if _, err := uploadBlocklistFromEnv(envOf(env)); err == nil {
+			t.Errorf("Expected %v to be rejected", env)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultMultipartMemory applies when MULTIPART_MEMORY_LIMIT is unset
+const defaultMultipartMemory = 32 << 20
+
+// uploadLimits are the largest files, in bytes, the server accepts: the limit for a file's exact
+// type, else for its family ("image/*"), else Default. An enterprise's max_upload_size setting
+// overrides them all for its members.
+type uploadLimits struct {
+	Default int64
+	ByType  map[string]int64
+}
+
+// uploadLimitsFromEnv reads MAX_UPLOAD_SIZE, the default limit in bytes, and UPLOAD_SIZE_LIMITS,
+// comma-separated type=bytes pairs such as "image/*=10485760,application/zip=2147483648"
+func uploadLimitsFromEnv(getenv func(string) string) (uploadLimits, error) {
+	limits := uploadLimits{Default: defaultMaxUploadSize, ByType: map[string]int64{}}
+	if value := getenv("MAX_UPLOAD_SIZE"); value != "" {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size <= 0 {
+			return uploadLimits{}, fmt.Errorf("MAX_UPLOAD_SIZE must be a positive number of bytes, got %q", value)
+		}
+		limits.Default = size
+	}
+
+	for _, rule := range strings.Split(getenv("UPLOAD_SIZE_LIMITS"), ",") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		mimeType, value, ok := strings.Cut(rule, "=")
+		mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+		major, minor, valid := strings.Cut(mimeType, "/")
+		if !ok || !valid || major == "" || minor == "" || major == "*" {
+			return uploadLimits{}, fmt.Errorf("UPLOAD_SIZE_LIMITS entries must look like type/subtype=bytes, got %q", rule)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || size <= 0 {
+			return uploadLimits{}, fmt.Errorf("UPLOAD_SIZE_LIMITS limit for %s must be a positive number of bytes, got %q", mimeType, value)
+		}
+		limits.ByType[mimeType] = size
+	}
+	return limits, nil
+}
+
+// forType returns the limit for files of mimeType
+func (l uploadLimits) forType(mimeType string) int64 {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return l.Default
+	}
+	if size, ok := l.ByType[mediaType]; ok {
+		return size
+	}
+	if major, _, ok := strings.Cut(mediaType, "/"); ok {
+		if size, ok := l.ByType[major+"/*"]; ok {
+			return size
+		}
+	}
+	return l.Default
+}
+
+// multipartMemoryFromEnv reads MULTIPART_MEMORY_LIMIT, how many bytes of a multipart upload are
+// held in memory before the rest is buffered in temporary files
+func multipartMemoryFromEnv(getenv func(string) string) (int64, error) {
+	value := getenv("MULTIPART_MEMORY_LIMIT")
+	if value == "" {
+		return defaultMultipartMemory, nil
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("MULTIPART_MEMORY_LIMIT must be a positive number of bytes, got %q", value)
+	}
+	return size, nil
+}
+
+// uploadLimit is the largest file of mimeType the user may upload
+func (h *uploadHandlers) uploadLimit(ctx context.Context, userID uuid.UUID, mimeType string) (int64, error) {
+	return h.files.MaxUploadSize(ctx, userID, h.limits.forType(mimeType))
+}
+
+// rejectTooLarge answers 413 for a file over its limit, stating the limit
+func rejectTooLarge(c *gin.Context, filename, mimeType string, limit int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":         fmt.Sprintf("%s exceeds the maximum upload size of %d bytes for %s files", filename, limit, mimeType),
+		"maxUploadSize": limit,
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestUploadLimitsFromEnv(t *testing.T) {
+	limits, err := uploadLimitsFromEnv(envOf(nil))
+	if err != nil || limits.Default != defaultMaxUploadSize || len(limits.ByType) != 0 {
+		t.Errorf("Expected the default limit alone, got %+v, %v", limits, err)
+	}
+
+	limits, err = uploadLimitsFromEnv(envOf(map[string]string{
+		"MAX_UPLOAD_SIZE":    "1000",
+		"UPLOAD_SIZE_LIMITS": " image/*=10, Application/ZIP = 5000 ,image/gif=20,",
+	}))
+	if err != nil {
+		t.Fatalf("uploadLimitsFromEnv failed: %v", err)
+	}
+	for mimeType, want := range map[string]int64{
+		"image/png":                10,
+		"image/gif":                20,
+		"application/zip":          5000,
+		"application/zip; foo=bar": 5000,
+		"text/plain":               1000,
+		"not a type":               1000,
+	} {
+		if got := limits.forType(mimeType); got != want {
+			t.Errorf("Expected the limit for %q to be %d, got %d", mimeType, want, got)
+		}
+	}
+
+	for _, rules := range []string{"image=10", "image/png", "*/*=10", "image/png=big", "image/png=0"} {
+		if _, err := uploadLimitsFromEnv(envOf(map[string]string{"UPLOAD_SIZE_LIMITS": rules})); err == nil {
+			t.Errorf("Expected UPLOAD_SIZE_LIMITS=%q to be rejected", rules)
+		}
+	}
+	if _, err := uploadLimitsFromEnv(envOf(map[string]string{"MAX_UPLOAD_SIZE": "-1"})); err == nil {
+		t.Error("Expected a negative MAX_UPLOAD_SIZE to be rejected")
+	}
+}
+
+func TestMultipartMemoryFromEnv(t *testing.T) {
+	if size, err := multipartMemoryFromEnv(envOf(nil)); err != nil || size != defaultMultipartMemory {
+		t.Errorf("Expected the default, got %d, %v", size, err)
+	}
+	if size, err := multipartMemoryFromEnv(envOf(map[string]string{"MULTIPART_MEMORY_LIMIT": "1048576"})); err != nil || size != 1<<20 {
+		t.Errorf("Expected 1MB, got %d, %v", size, err)
+	}
+	if _, err := multipartMemoryFromEnv(envOf(map[string]string{"MULTIPART_MEMORY_LIMIT": "lots"})); err == nil {
+		t.Error("Expected a malformed limit to be rejected")
+	}
+}
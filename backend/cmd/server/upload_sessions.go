@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/services"
+)
+
+// uploadSessionToMap is the response shape for a resumable upload
+func uploadSessionToMap(session *domain.UploadSession) map[string]interface{} {
+	return map[string]interface{}{
+		"uploadId":       session.ID.String(),
+		"filename":       session.Filename,
+		"fileSize":       session.FileSize,
+		"chunkSize":      session.ChunkSize,
+		"chunkCount":     session.ChunkCount,
+		"receivedChunks": session.ReceivedChunks,
+		"missingChunks":  session.MissingChunks(),
+		"expiresAt":      session.ExpiresAt.UTC(),
+	}
+}
+
+// initiateUpload opens a resumable upload. The declared size is checked against the caller's
+// limit; the response says how the file is to be split into chunks.
+func (h *uploadHandlers) initiateUpload(c *gin.Context) {
+	userUUID := authUserID(c)
+
+	var request struct {
+		Filename  string  `json:"filename"`
+		MimeType  string  `json:"mimeType"`
+		FileSize  int64   `json:"fileSize"`
+		ChunkSize int64   `json:"chunkSize"`
+		FolderID  *string `json:"folderId"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+		return
+	}
+	if request.Filename == "" || request.FileSize <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filename and fileSize are required"})
+		return
+	}
+	if request.MimeType == "" {
+		request.MimeType = "application/octet-stream"
+	}
+
+	var folderID *uuid.UUID
+	if request.FolderID != nil && *request.FolderID != "" {
+		parsed, err := uuid.Parse(*request.FolderID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+			return
+		}
+		folderID = &parsed
+	}
+
+	maxUploadSize, err := h.uploadLimit(c.Request.Context(), userUUID, request.MimeType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check upload size limit"})
+		return
+	}
+	if request.FileSize > maxUploadSize {
+		rejectTooLarge(c, request.Filename, request.MimeType, maxUploadSize)
+		return
+	}
+
+	session, err := h.sessions.Initiate(c.Request.Context(), userUUID, request.Filename, request.MimeType, request.FileSize, request.ChunkSize, folderID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidChunk) || errors.Is(err, services.ErrInvalidFileName) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if rejectBlocked(c, request.Filename, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start upload"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, uploadSessionToMap(session))
+}
+
+// uploadStatus lists the chunks received so far, so a client resuming after a dropped connection
+// only sends the missing ones
+func (h *uploadHandlers) uploadStatus(c *gin.Context) {
+	userUUID := authUserID(c)
+	sessionID, ok := uploadSessionID(c)
+	if !ok {
+		return
+	}
+
+	session, err := h.sessions.Status(c.Request.Context(), userUUID, sessionID)
+	if err != nil {
+		writeUploadSessionError(c, err, "failed to get upload")
+		return
+	}
+
+	c.JSON(http.StatusOK, uploadSessionToMap(session))
+}
+
+// putChunk stores one chunk from the raw request body. Chunks may be sent concurrently and in any
+// order; sending a chunk again replaces it.
+func (h *uploadHandlers) putChunk(c *gin.Context) {
+	userUUID := authUserID(c)
+	sessionID, ok := uploadSessionID(c)
+	if !ok {
+		return
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chunk index"})
+		return
+	}
+
+	if err := h.sessions.PutChunk(c.Request.Context(), userUUID, sessionID, index, c.Request.Body); err != nil {
+		writeUploadSessionError(c, err, "failed to store chunk")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"uploadId": sessionID.String(), "chunk": index})
+}
+
+// completeUpload assembles the received chunks into a file. An optional contentHash, the SHA-256 of
+// the whole file, guards against chunks corrupted on the way.
+func (h *uploadHandlers) completeUpload(c *gin.Context) {
+	userUUID := authUserID(c)
+	sessionID, ok := uploadSessionID(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		ContentHash string `json:"contentHash"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+			return
+		}
+	}
+	request.ContentHash = strings.ToLower(request.ContentHash)
+	if request.ContentHash != "" && !contentHashPattern.MatchString(request.ContentHash) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "contentHash must be a SHA-256"})
+		return
+	}
+
+	file, err := h.sessions.Complete(c.Request.Context(), userUUID, sessionID, request.ContentHash)
+	if err != nil {
+		var unsupported *services.UnsupportedMediaTypeError
+		if errors.As(err, &unsupported) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":    unsupported.Error(),
+				"mimeType": unsupported.MimeType,
+			})
+			return
+		}
+		var blocked *services.BlockedUploadError
+		if errors.As(err, &blocked) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": blocked.Error()})
+			return
+		}
+		writeUploadSessionError(c, err, "failed to store file")
+		return
+	}
+
+	h.audit.LogFileUpload(c.Request.Context(), userUUID, file.ID, file.OriginalName, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "file uploaded successfully",
+		"file":    uploadedFileToMap(file),
+	})
+}
+
+// abortUpload discards a resumable upload and its chunks
+func (h *uploadHandlers) abortUpload(c *gin.Context) {
+	userUUID := authUserID(c)
+	sessionID, ok := uploadSessionID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sessions.Abort(c.Request.Context(), userUUID, sessionID); err != nil {
+		writeUploadSessionError(c, err, "failed to abort upload")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// uploadSessionID parses the :id route parameter, answering 400 when it isn't a UUID
+func uploadSessionID(c *gin.Context) (uuid.UUID, bool) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid upload ID"})
+		return uuid.Nil, false
+	}
+	return sessionID, true
+}
+
+// writeUploadSessionError answers with the status for an upload session error, or 500 with message
+func writeUploadSessionError(c *gin.Context, err error, message string) {
+	var incomplete *services.IncompleteUploadError
+	switch {
+	case errors.Is(err, services.ErrUploadSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found or expired"})
+	case errors.Is(err, services.ErrInvalidChunk), errors.Is(err, services.ErrContentHashMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &incomplete):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         incomplete.Error(),
+			"missingChunks": incomplete.MissingChunks,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,263 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/testutil"
+)
+
+// uploadSessionResponse is the JSON shape of a resumable upload
+type uploadSessionResponse struct {
+	UploadID       string `json:"uploadId"`
+	ChunkSize      int64  `json:"chunkSize"`
+	ChunkCount     int    `json:"chunkCount"`
+	ReceivedChunks []int  `json:"receivedChunks"`
+	MissingChunks  []int  `json:"missingChunks"`
+}
+
+// sessionRequest sends an authenticated request to the upload routes
+func sessionRequest(router *gin.Engine, token, method, path string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// initiateSession starts a resumable upload of content in chunks of chunkSize
+func initiateSession(t *testing.T, router *gin.Engine, token string, content []byte, chunkSize int) uploadSessionResponse {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"filename":"chunked.txt","mimeType":"text/plain","fileSize":%d,"chunkSize":%d}`, len(content), chunkSize)
+	recorder := sessionRequest(router, token, http.MethodPost, "/files/uploads", strings.NewReader(body))
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var session uploadSessionResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &session); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	return session
+}
+
+// failingReader delivers part of a chunk and then fails, as a dropped connection would
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestUploadSessions_ResumeAndComplete(t *testing.T) {
+	router, db, _, storageDir, jwtManager := newUploadRouter(t, 1<<20)
+
+	userID := testutil.CreateUser(t, db, "chunked", nil)
+	token, err := jwtManager.GenerateToken(userID.String(), "chunked@example.com", "USER")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	// Three chunks of 16 bytes, the last one shorter
+	content := []byte("resumable upload " + uuid.NewString())
+	hash := fmt.Sprintf("%x", sha256.Sum256(content))
+	t.Cleanup(func() {
+		db.Exec(context.Background(), "DELETE FROM files WHERE content_hash = $1", hash)
+		db.Exec(context.Background(), "DELETE FROM file_contents WHERE content_hash = $1", hash)
+	})
+	chunk := func(index int) []byte {
+		return content[index*16 : min((index+1)*16, len(content))]
+	}
+
+	session := initiateSession(t, router, token, content, 16)
+	if session.ChunkCount != 3 || !reflect.DeepEqual(session.MissingChunks, []int{0, 1, 2}) {
+		t.Fatalf("Expected 3 missing chunks, got %+v", session)
+	}
+	chunkPath := func(index int) string {
+		return fmt.Sprintf("/files/uploads/%s/chunks/%d", session.UploadID, index)
+	}
+
+	// Chunks 0 and 2 arrive concurrently; the connection carrying chunk 1 drops midway
+	var wg sync.WaitGroup
+	codes := make([]int, 3)
+	for index := range codes {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			var body io.Reader = bytes.NewReader(chunk(index))
+			if index == 1 {
+				body = &failingReader{data: chunk(index)[:5]}
+			}
+			codes[index] = sessionRequest(router, token, http.MethodPut, chunkPath(index), body).Code
+		}(index)
+	}
+	wg.Wait()
+	if codes[0] != http.StatusOK || codes[2] != http.StatusOK {
+		t.Fatalf("Expected chunks 0 and 2 to be stored, got statuses %v", codes)
+	}
+	if codes[1] == http.StatusOK {
+		t.Fatal("Expected the interrupted chunk to be refused")
+	}
+
+	// Completing now names the missing chunk
+	recorder := sessionRequest(router, token, http.MethodPost, "/files/uploads/"+session.UploadID+"/complete", nil)
+	if recorder.Code != http.StatusConflict || !strings.Contains(recorder.Body.String(), `"missingChunks":[1]`) {
+		t.Fatalf("Expected 409 listing chunk 1 as missing, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	// Resuming: ask what arrived and re-send only the rest
+	recorder = sessionRequest(router, token, http.MethodGet, "/files/uploads/"+session.UploadID, nil)
+	var status uploadSessionResponse
+	json.Unmarshal(recorder.Body.Bytes(), &status)
+	if !reflect.DeepEqual(status.ReceivedChunks, []int{0, 2}) || !reflect.DeepEqual(status.MissingChunks, []int{1}) {
+		t.Fatalf("Expected chunks 0 and 2 received and 1 missing, got %+v", status)
+	}
+	for _, index := range status.MissingChunks {
+		if recorder := sessionRequest(router, token, http.MethodPut, chunkPath(index), bytes.NewReader(chunk(index))); recorder.Code != http.StatusOK {
+			t.Fatalf("Expected the retried chunk to be stored, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+	}
+
+	body := fmt.Sprintf(`{"contentHash":%q}`, hash)
+	recorder = sessionRequest(router, token, http.MethodPost, "/files/uploads/"+session.UploadID+"/complete", strings.NewReader(body))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var storedHash string
+	var size int64
+	if err := db.QueryRow(context.Background(), `
+		SELECT content_hash, file_size FROM files WHERE user_id = $1 AND original_name = 'chunked.txt'`,
+		userID).Scan(&storedHash, &size); err != nil {
+		t.Fatalf("Expected a file for the assembled upload: %v", err)
+	}
+	if storedHash != hash || size != int64(len(content)) {
+		t.Errorf("Expected content %s of %d bytes, got %s of %d", hash, len(content), storedHash, size)
+	}
+	stored, err := os.ReadFile(filepath.Join(storageDir, "personal/users", userID.String(), hash))
+	if err != nil || !bytes.Equal(stored, content) {
+		t.Errorf("Expected the assembled content in storage, got %q (%v)", stored, err)
+	}
+
+	// The session and its chunks are gone
+	if recorder := sessionRequest(router, token, http.MethodGet, "/files/uploads/"+session.UploadID, nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected the completed session to be gone, got %d", recorder.Code)
+	}
+	if _, err := os.Stat(filepath.Join(storageDir, "uploads/sessions", session.UploadID, "0")); !os.IsNotExist(err) {
+		t.Error("Expected the chunks to be removed")
+	}
+}
+
+func TestUploadSessions_Rejections(t *testing.T) {
+	router, db, _, _, jwtManager := newUploadRouter(t, 64)
+
+	userID := testutil.CreateUser(t, db, "chunks", nil)
+	token, _ := jwtManager.GenerateToken(userID.String(), "chunks@example.com", "USER")
+	otherID := testutil.CreateUser(t, db, "other", nil)
+	otherToken, _ := jwtManager.GenerateToken(otherID.String(), "other@example.com", "USER")
+
+	content := []byte("twenty bytes of data")
+	session := initiateSession(t, router, token, content, 8)
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"over the size limit", token, http.MethodPost, "/files/uploads", `{"filename":"big.bin","fileSize":65}`, http.StatusRequestEntityTooLarge},
+		{"index out of range", token, http.MethodPut, "/files/uploads/" + session.UploadID + "/chunks/3", "12345678", http.StatusBadRequest},
+		{"short chunk", token, http.MethodPut, "/files/uploads/" + session.UploadID + "/chunks/0", "1234", http.StatusBadRequest},
+		{"long last chunk", token, http.MethodPut, "/files/uploads/" + session.UploadID + "/chunks/2", "12345678", http.StatusBadRequest},
+		{"another user's session", otherToken, http.MethodGet, "/files/uploads/" + session.UploadID, "", http.StatusNotFound},
+		{"unknown session", token, http.MethodPut, "/files/uploads/" + uuid.NewString() + "/chunks/0", "12345678", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := sessionRequest(router, tt.token, tt.method, tt.path, strings.NewReader(tt.body))
+			if recorder.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+
+	t.Run("hash mismatch keeps the session", func(t *testing.T) {
+		for index := 0; index < 3; index++ {
+			part := content[index*8 : min((index+1)*8, len(content))]
+			sessionRequest(router, token, http.MethodPut, fmt.Sprintf("/files/uploads/%s/chunks/%d", session.UploadID, index), bytes.NewReader(part))
+		}
+		body := fmt.Sprintf(`{"contentHash":%q}`, strings.Repeat("0", 64))
+		recorder := sessionRequest(router, token, http.MethodPost, "/files/uploads/"+session.UploadID+"/complete", strings.NewReader(body))
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if recorder := sessionRequest(router, token, http.MethodGet, "/files/uploads/"+session.UploadID, nil); recorder.Code != http.StatusOK {
+			t.Errorf("Expected the session to survive, got %d", recorder.Code)
+		}
+	})
+}
+
+func TestUploadSessions_Expiry(t *testing.T) {
+	router, db, store, storageDir, jwtManager := newUploadRouter(t, 64)
+	sessions := services.NewUploadSessionService(db, store, nil, zap.NewNop())
+
+	userID := testutil.CreateUser(t, db, "abandoned", nil)
+	token, _ := jwtManager.GenerateToken(userID.String(), "abandoned@example.com", "USER")
+
+	session := initiateSession(t, router, token, []byte("abandoned upload"), 8)
+	chunkPath := fmt.Sprintf("/files/uploads/%s/chunks/0", session.UploadID)
+	if recorder := sessionRequest(router, token, http.MethodPut, chunkPath, strings.NewReader("abandone")); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the chunk to be stored, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	db.Exec(context.Background(), "UPDATE upload_sessions SET expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1", session.UploadID)
+
+	// An expired session takes no more chunks even before it is swept
+	if recorder := sessionRequest(router, token, http.MethodPut, chunkPath, strings.NewReader("abandone")); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an expired session, got %d", recorder.Code)
+	}
+
+	deleted, err := sessions.DeleteExpired(context.Background())
+	if err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	}
+	if deleted < 1 {
+		t.Errorf("Expected the expired session to be deleted, got %d", deleted)
+	}
+
+	var remaining int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM upload_sessions WHERE id = $1", session.UploadID).Scan(&remaining)
+	if remaining != 0 {
+		t.Error("Expected the session row to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(storageDir, "uploads/sessions", session.UploadID, "0")); !os.IsNotExist(err) {
+		t.Error("Expected the chunk to be removed")
+	}
+}
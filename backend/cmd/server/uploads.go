@@ -0,0 +1,473 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/storage"
+	"lokr-backend/pkg/hash"
+)
+
+// defaultMaxUploadSize applies when MAX_UPLOAD_SIZE is unset
+const defaultMaxUploadSize = 100 << 20
+
+// uploadHandlers accepts file content over REST, either through the API server, in resumable
+// chunks, or directly into storage with a presigned URL that is finalized afterwards
+type uploadHandlers struct {
+	storage  storage.StorageService
+	files    *services.SimpleFileService
+	sessions *services.UploadSessionService
+	audit    *services.AuditService
+	limits   uploadLimits
+}
+
+// presignedUploadExpiry is how long a direct upload URL stays valid
+const presignedUploadExpiry = 15 * time.Minute
+
+// uploadedFileToMap is the response shape for a newly stored file
+func uploadedFileToMap(file *domain.File) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           file.ID.String(),
+		"filename":     file.Filename,
+		"originalName": file.OriginalName,
+		"fileSize":     file.FileSize,
+		"mimeType":     file.MimeType,
+		"uploadDate":   file.UploadDate,
+		"encrypted":    file.Encryption != nil,
+	}
+}
+
+// upload stores every file in the "files" form field for the caller. A file over the caller's
+// size limit for its type fails the whole request with 413 before any content is read; a file whose type the
+// caller's enterprise doesn't allow stops it with 415, listing the files already stored.
+//
+// A client encrypting files itself uploads one ciphertext at a time, with an "encryption" field
+// holding {"algorithm", "iv", "wrappedKey"} as JSON; the file's type is the one it decrypts to.
+func (h *uploadHandlers) upload(c *gin.Context) {
+	userUUID := authUserID(c)
+
+	// Parse multipart form; parts beyond the router's MaxMultipartMemory spill to temporary files
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid form"})
+		return
+	}
+
+	files := form.File["files"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no files provided"})
+		return
+	}
+
+	encryption, err := uploadEncryption(c.PostForm("encryption"), len(files))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, fileHeader := range files {
+		mimeType := uploadedType(fileHeader)
+		if rejectBlocked(c, fileHeader.Filename, h.files.CheckUpload(fileHeader.Filename, mimeType)) {
+			return
+		}
+		limit, err := h.uploadLimit(c.Request.Context(), userUUID, mimeType)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check upload size limit"})
+			return
+		}
+		if fileHeader.Size > limit {
+			rejectTooLarge(c, fileHeader.Filename, mimeType, limit)
+			return
+		}
+	}
+
+	uploadedFiles := make([]map[string]interface{}, 0)
+
+	for _, fileHeader := range files {
+		// Open the file
+		file, err := fileHeader.Open()
+		if err != nil {
+			continue
+		}
+		defer file.Close()
+
+		// Read file content
+		content, err := io.ReadAll(file)
+		if err != nil {
+			continue
+		}
+
+		// Detect MIME type
+		mimeType := uploadedType(fileHeader)
+
+		// Upload file
+		var uploadedFile *domain.File
+		if encryption != nil {
+			uploadedFile, err = h.files.UploadEncryptedFile(c.Request.Context(), userUUID, fileHeader.Filename, mimeType, content, *encryption, nil)
+		} else {
+			uploadedFile, err = h.files.UploadFile(
+				c.Request.Context(),
+				userUUID,
+				fileHeader.Filename,
+				mimeType,
+				content,
+				nil, // folderID
+				nil, // description
+				nil, // tags
+				nil, // visibility (defaults to private)
+			)
+		}
+		if err != nil {
+			// Log failed upload
+			h.audit.LogFileUpload(c.Request.Context(), userUUID, uuid.Nil, fileHeader.Filename, c.ClientIP(), c.GetHeader("User-Agent"))
+
+			var unsupported *services.UnsupportedMediaTypeError
+			if errors.As(err, &unsupported) {
+				c.JSON(http.StatusUnsupportedMediaType, gin.H{
+					"error":    fmt.Sprintf("%s: %s", fileHeader.Filename, unsupported.Error()),
+					"mimeType": unsupported.MimeType,
+					"files":    uploadedFiles,
+				})
+				return
+			}
+			var blocked *services.BlockedUploadError
+			if errors.As(err, &blocked) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("%s: %s", fileHeader.Filename, blocked.Error()),
+					"files": uploadedFiles,
+				})
+				return
+			}
+			continue
+		}
+
+		// Log successful upload
+		h.audit.LogFileUpload(c.Request.Context(), userUUID, uploadedFile.ID, uploadedFile.OriginalName, c.ClientIP(), c.GetHeader("User-Agent"))
+
+		uploadedFiles = append(uploadedFiles, uploadedFileToMap(uploadedFile))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "files uploaded successfully",
+		"files":   uploadedFiles,
+	})
+}
+
+// uploadedType is the type a client declared for an uploaded file
+func uploadedType(fileHeader *multipart.FileHeader) string {
+	if mimeType := fileHeader.Header.Get("Content-Type"); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// uploadEncryption parses the "encryption" field of an upload of fileCount files, returning nil
+// when the files aren't encrypted
+func uploadEncryption(raw string, fileCount int) (*domain.FileEncryption, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	if fileCount != 1 {
+		return nil, errors.New("encrypted files must be uploaded one at a time")
+	}
+
+	var fields struct {
+		Algorithm  string `json:"algorithm"`
+		IV         string `json:"iv"`
+		WrappedKey string `json:"wrappedKey"`
+	}
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return nil, errors.New("encryption must be a JSON object")
+	}
+	encryption := &domain.FileEncryption{Algorithm: fields.Algorithm, IV: fields.IV, WrappedKey: fields.WrappedKey}
+	if err := services.ValidateEncryption(*encryption); err != nil {
+		return nil, err
+	}
+	return encryption, nil
+}
+
+// uploadURL hands out a presigned PUT URL and the temporary key to upload one file to. The
+// declared size is checked against the caller's limit and signed into the URL.
+func (h *uploadHandlers) uploadURL(c *gin.Context) {
+	userUUID := authUserID(c)
+
+	var request struct {
+		Filename string `json:"filename"`
+		MimeType string `json:"mimeType"`
+		FileSize int64  `json:"fileSize"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+		return
+	}
+	if request.Filename == "" || request.FileSize <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filename and fileSize are required"})
+		return
+	}
+	if request.MimeType == "" {
+		request.MimeType = "application/octet-stream"
+	}
+	if rejectBlocked(c, request.Filename, h.files.CheckUpload(request.Filename, request.MimeType)) {
+		return
+	}
+
+	maxUploadSize, err := h.uploadLimit(c.Request.Context(), userUUID, request.MimeType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check upload size limit"})
+		return
+	}
+	if request.FileSize > maxUploadSize {
+		rejectTooLarge(c, request.Filename, request.MimeType, maxUploadSize)
+		return
+	}
+
+	presigner, ok := h.storage.(storage.PresignedURLService)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "direct uploads are not available"})
+		return
+	}
+
+	key := h.storage.Key(storage.PendingUploadPath(userUUID.String(), uuid.NewString()))
+	uploadURL, err := presigner.GenerateUploadPresignedURL(c.Request.Context(), key, request.MimeType, request.FileSize, presignedUploadExpiry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create upload URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"uploadUrl": uploadURL,
+		"key":       key,
+		"expiresAt": time.Now().Add(presignedUploadExpiry).UTC(),
+	})
+}
+
+// finalize turns an object uploaded with a presigned URL into a file. The object's size is
+// checked before its content is read; the content then goes through the regular upload path for
+// hashing, deduplication, quota and type checks, and the temporary object is removed.
+func (h *uploadHandlers) finalize(c *gin.Context) {
+	userUUID := authUserID(c)
+
+	var request struct {
+		Key      string  `json:"key"`
+		Filename string  `json:"filename"`
+		MimeType string  `json:"mimeType"`
+		FolderID *string `json:"folderId"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+		return
+	}
+	if request.Filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filename is required"})
+		return
+	}
+	if request.MimeType == "" {
+		request.MimeType = "application/octet-stream"
+	}
+
+	// Only keys handed out to this caller may be finalized
+	uploadID, ok := strings.CutPrefix(request.Key, h.storage.Key(storage.PendingUploadPath(userUUID.String(), "")))
+	if _, err := uuid.Parse(uploadID); !ok || err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid upload key"})
+		return
+	}
+
+	var folderID *uuid.UUID
+	if request.FolderID != nil && *request.FolderID != "" {
+		parsed, err := uuid.Parse(*request.FolderID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+			return
+		}
+		folderID = &parsed
+	}
+
+	maxUploadSize, err := h.uploadLimit(c.Request.Context(), userUUID, request.MimeType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check upload size limit"})
+		return
+	}
+
+	info, err := h.storage.GetFileInfo(c.Request.Context(), request.Key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "uploaded object not found"})
+		return
+	}
+	if info.Size > maxUploadSize {
+		h.storage.Delete(c.Request.Context(), request.Key)
+		rejectTooLarge(c, request.Filename, request.MimeType, maxUploadSize)
+		return
+	}
+
+	content, err := storage.ReadAll(c.Request.Context(), h.storage, request.Key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read uploaded object"})
+		return
+	}
+
+	uploadedFile, err := h.files.UploadFile(
+		c.Request.Context(),
+		userUUID,
+		request.Filename,
+		request.MimeType,
+		content,
+		folderID,
+		nil, // description
+		nil, // tags
+		nil, // visibility (defaults to private)
+	)
+	if err != nil {
+		h.audit.LogFileUpload(c.Request.Context(), userUUID, uuid.Nil, request.Filename, c.ClientIP(), c.GetHeader("User-Agent"))
+
+		var unsupported *services.UnsupportedMediaTypeError
+		if errors.As(err, &unsupported) {
+			h.storage.Delete(c.Request.Context(), request.Key)
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":    fmt.Sprintf("%s: %s", request.Filename, unsupported.Error()),
+				"mimeType": unsupported.MimeType,
+			})
+			return
+		}
+		if rejectBlocked(c, request.Filename, err) {
+			h.storage.Delete(c.Request.Context(), request.Key)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store file"})
+		return
+	}
+
+	h.audit.LogFileUpload(c.Request.Context(), userUUID, uploadedFile.ID, uploadedFile.OriginalName, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	// The content now lives at its content-addressed path; a leftover is expired by the lifecycle rule
+	h.storage.Delete(c.Request.Context(), request.Key)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "file uploaded successfully",
+		"file":    uploadedFileToMap(uploadedFile),
+	})
+}
+
+// contentHashPattern matches a lowercase hex 256-bit digest, the form content hashes are stored in
+var contentHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// contentHashAlgorithm resolves the hashAlgorithm a client computed its contentHash with,
+// SHA-256 when it names none, answering 400 and false for an unsupported one
+func contentHashAlgorithm(c *gin.Context, name string) (string, bool) {
+	hasher, err := hash.NewHasher(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return hasher.Algorithm(), true
+}
+
+// check tells a client whether content it is about to upload is already stored, in which case
+// reference can create the file without the upload. Only content hashed with the same algorithm
+// matches.
+func (h *uploadHandlers) check(c *gin.Context) {
+	var request struct {
+		ContentHash   string `json:"contentHash"`
+		HashAlgorithm string `json:"hashAlgorithm"`
+		FileSize      int64  `json:"fileSize"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+		return
+	}
+	request.ContentHash = strings.ToLower(request.ContentHash)
+	if !contentHashPattern.MatchString(request.ContentHash) || request.FileSize <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a hex contentHash and fileSize are required"})
+		return
+	}
+	algorithm, ok := contentHashAlgorithm(c, request.HashAlgorithm)
+	if !ok {
+		return
+	}
+
+	exists, err := h.files.ContentExists(c.Request.Context(), algorithm, request.ContentHash, request.FileSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check content"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exists": exists})
+}
+
+// reference creates a file for the caller from content that is already stored, without an upload
+func (h *uploadHandlers) reference(c *gin.Context) {
+	userUUID := authUserID(c)
+
+	var request struct {
+		ContentHash   string  `json:"contentHash"`
+		HashAlgorithm string  `json:"hashAlgorithm"`
+		FileSize      int64   `json:"fileSize"`
+		Filename      string  `json:"filename"`
+		MimeType      string  `json:"mimeType"`
+		FolderID      *string `json:"folderId"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %v", err)})
+		return
+	}
+	request.ContentHash = strings.ToLower(request.ContentHash)
+	if !contentHashPattern.MatchString(request.ContentHash) || request.FileSize <= 0 || request.Filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a hex contentHash, fileSize and filename are required"})
+		return
+	}
+	algorithm, ok := contentHashAlgorithm(c, request.HashAlgorithm)
+	if !ok {
+		return
+	}
+	if request.MimeType == "" {
+		request.MimeType = "application/octet-stream"
+	}
+
+	var folderID *uuid.UUID
+	if request.FolderID != nil && *request.FolderID != "" {
+		parsed, err := uuid.Parse(*request.FolderID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+			return
+		}
+		folderID = &parsed
+	}
+
+	file, err := h.files.ReferenceContent(c.Request.Context(), userUUID, algorithm, request.ContentHash, request.FileSize, request.Filename, request.MimeType, folderID)
+	if err != nil {
+		var unsupported *services.UnsupportedMediaTypeError
+		var blocked *services.BlockedUploadError
+		switch {
+		case errors.Is(err, services.ErrContentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "content not found; upload the file instead"})
+		case errors.As(err, &unsupported):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":    fmt.Sprintf("%s: %s", request.Filename, unsupported.Error()),
+				"mimeType": unsupported.MimeType,
+			})
+		case errors.As(err, &blocked):
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", request.Filename, blocked.Error())})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create file"})
+		}
+		return
+	}
+
+	h.audit.LogFileUpload(c.Request.Context(), userUUID, file.ID, file.OriginalName, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "file created from existing content",
+		"file":    uploadedFileToMap(file),
+	})
+}
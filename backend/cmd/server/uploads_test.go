@@ -0,0 +1,463 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/storage"
+	"lokr-backend/internal/testutil"
+	"lokr-backend/pkg/auth"
+)
+
+// uploadRequest builds a multipart upload of one file carrying the given bearer token
+func uploadRequest(t *testing.T, token, filename string, content []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("files", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/files/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// typedUploadRequest is uploadRequest declaring the file's type as mimeType
+func typedUploadRequest(t *testing.T, token, filename, mimeType string, content []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename="%s"`, filename))
+	header.Set("Content-Type", mimeType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/files/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// newUploadRouter serves the upload routes from the local storage backend in a temporary directory
+func newUploadRouter(t *testing.T, maxUploadSize int64) (*gin.Engine, *pgxpool.Pool, storage.StorageService, string, *auth.JWTManager) {
+	t.Helper()
+	return newLimitedUploadRouter(t, uploadLimits{Default: maxUploadSize})
+}
+
+// newLimitedUploadRouter is newUploadRouter with upload size limits by type
+func newLimitedUploadRouter(t *testing.T, limits uploadLimits) (*gin.Engine, *pgxpool.Pool, storage.StorageService, string, *auth.JWTManager) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	db := testutil.NewTestDB(t)
+	storageDir := t.TempDir()
+	store, err := storage.NewStorageService(storage.StorageConfig{
+		Backend: "local",
+		Local:   storage.LocalConfig{BasePath: storageDir},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	jwtManager := auth.NewJWTManager("test-secret")
+
+	files := services.NewSimpleFileService(db, store, nil, nil, zap.NewNop())
+	files.SetUploadBlocklist(services.UploadBlocklist{Extensions: defaultBlockedExtensions})
+	uploads := &uploadHandlers{
+		storage:  store,
+		files:    files,
+		sessions: services.NewUploadSessionService(db, store, files, zap.NewNop()),
+		audit:    services.NewAuditService(db, zap.NewNop()),
+		limits:   limits,
+	}
+	router := gin.New()
+	authed := router.Group("", authRequired(jwtManager))
+	authed.POST("/files/upload", uploads.upload)
+	authed.POST("/files/finalize", uploads.finalize)
+	authed.POST("/files/uploads", uploads.initiateUpload)
+	authed.GET("/files/uploads/:id", uploads.uploadStatus)
+	authed.PUT("/files/uploads/:id/chunks/:index", uploads.putChunk)
+	authed.POST("/files/uploads/:id/complete", uploads.completeUpload)
+	authed.DELETE("/files/uploads/:id", uploads.abortUpload)
+	authed.POST("/files/check", uploads.check)
+	authed.POST("/files/reference", uploads.reference)
+
+	return router, db, store, storageDir, jwtManager
+}
+
+func TestUploadRoute_MaxUploadSize(t *testing.T) {
+	router, db, _, storageDir, jwtManager := newUploadRouter(t, 16)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	userID := testutil.CreateUser(t, db, "uploader", &enterpriseID)
+	token, err := jwtManager.GenerateToken(userID.String(), "uploader@example.com", "USER")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	content := []byte("too large for sixteen bytes " + uuid.NewString())
+	hash := fmt.Sprintf("%x", sha256.Sum256(content))
+	t.Cleanup(func() {
+		db.Exec(context.Background(), "DELETE FROM files WHERE content_hash = $1", hash)
+		db.Exec(context.Background(), "DELETE FROM file_contents WHERE content_hash = $1", hash)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, uploadRequest(t, token, "big.bin", content))
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status 413, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), "16 bytes") {
+		t.Errorf("Expected the error to state the limit, got %s", recorder.Body.String())
+	}
+
+	// Rejected on the declared size: nothing was stored or recorded
+	var fileCount int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM files WHERE user_id = $1", userID).Scan(&fileCount)
+	if fileCount != 0 {
+		t.Errorf("Expected no files to be created, got %d", fileCount)
+	}
+	if entries, _ := os.ReadDir(storageDir); len(entries) != 0 {
+		t.Errorf("Expected nothing in storage, found %d entries", len(entries))
+	}
+
+	// The enterprise setting overrides the server-wide limit
+	db.Exec(context.Background(), `
+		UPDATE enterprises SET settings = jsonb_build_object($1::text, $2::bigint) WHERE id = $3`,
+		services.MaxUploadSizeSettingKey, 1<<20, enterpriseID)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, uploadRequest(t, token, "big.bin", content))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the enterprise limit to allow the upload, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM files WHERE user_id = $1", userID).Scan(&fileCount)
+	if fileCount != 1 {
+		t.Errorf("Expected 1 file after the upload, got %d", fileCount)
+	}
+}
+
+func TestUploadRoute_SizeLimitsByType(t *testing.T) {
+	router, db, _, _, jwtManager := newLimitedUploadRouter(t, uploadLimits{
+		Default: 64,
+		ByType:  map[string]int64{"image/*": 16, "application/zip": 1024},
+	})
+	userID := testutil.CreateUser(t, db, "typed", nil)
+	token, _ := jwtManager.GenerateToken(userID.String(), "typed@example.com", "USER")
+
+	image := []byte("an image well over sixteen bytes " + uuid.NewString())
+	archive := []byte(strings.Repeat("archive ", 64) + uuid.NewString())
+	t.Cleanup(func() {
+		for _, content := range [][]byte{image, archive} {
+			hash := fmt.Sprintf("%x", sha256.Sum256(content))
+			db.Exec(context.Background(), "DELETE FROM files WHERE content_hash = $1", hash)
+			db.Exec(context.Background(), "DELETE FROM file_contents WHERE content_hash = $1", hash)
+		}
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, typedUploadRequest(t, token, "photo.png", "image/png", image))
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected the image to be rejected with 413, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if !strings.Contains(recorder.Body.String(), "16 bytes for image/png") {
+		t.Errorf("Expected the error to state the image limit, got %s", recorder.Body.String())
+	}
+
+	// The archive is over the default limit but within the one for its type
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, typedUploadRequest(t, token, "backup.zip", "application/zip", archive))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the archive to be accepted, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var fileCount int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM files WHERE user_id = $1", userID).Scan(&fileCount)
+	if fileCount != 1 {
+		t.Errorf("Expected only the archive to be stored, got %d files", fileCount)
+	}
+}
+
+func TestUploadRoute_Blocklist(t *testing.T) {
+	router, db, _, _, jwtManager := newUploadRouter(t, 1<<20)
+	userID := testutil.CreateUser(t, db, "blocked", nil)
+	token, _ := jwtManager.GenerateToken(userID.String(), "blocked@example.com", "USER")
+
+	// A script is refused by its extension, however harmless its declared type and content look
+	for _, name := range []string{"setup.exe", "payload.JS", "run.bat. "} {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, typedUploadRequest(t, token, name, "text/plain", []byte("echo hello "+uuid.NewString())))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Expected %q to be rejected with 400, got %d: %s", name, recorder.Code, recorder.Body.String())
+		}
+		if !strings.Contains(recorder.Body.String(), "files are not allowed") {
+			t.Errorf("Expected the error to say why %q was refused, got %s", name, recorder.Body.String())
+		}
+	}
+
+	body := strings.NewReader(`{"filename":"installer.msi","mimeType":"application/octet-stream","fileSize":1024}`)
+	req := httptest.NewRequest(http.MethodPost, "/files/uploads", body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected a resumable upload of a blocked file to be refused with 400, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var fileCount int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM files WHERE user_id = $1", userID).Scan(&fileCount)
+	if fileCount != 0 {
+		t.Errorf("Expected no files to be stored, got %d", fileCount)
+	}
+}
+
+func TestUploadRoute_Encrypted(t *testing.T) {
+	router, db, store, _, jwtManager := newUploadRouter(t, 1<<20)
+	userID := testutil.CreateUser(t, db, "encrypting", nil)
+	token, _ := jwtManager.GenerateToken(userID.String(), "encrypting@example.com", "USER")
+
+	ciphertext := []byte("\x9aciphertext " + uuid.NewString())
+	hash := fmt.Sprintf("%x", sha256.Sum256(ciphertext))
+	t.Cleanup(func() {
+		db.Exec(context.Background(), "DELETE FROM files WHERE content_hash = $1", hash)
+		db.Exec(context.Background(), "DELETE FROM file_contents WHERE content_hash = $1", hash)
+	})
+	encryptedUpload := func(encryption string, copies int) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for i := 0; i < copies; i++ {
+			part, _ := writer.CreateFormFile("files", "diary.txt")
+			part.Write(ciphertext)
+		}
+		writer.WriteField("encryption", encryption)
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/files/upload", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	metadata := `{"algorithm": "AES-256-GCM", "iv": "q83vEjRWeJCrze8S", "wrappedKey": "c2VjcmV0LXdyYXBwZWQta2V5"}`
+	recorder := encryptedUpload(metadata, 1)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"encrypted":true`) {
+		t.Fatalf("Expected the encrypted upload to succeed, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	stored, err := storage.ReadAll(context.Background(), store, storage.ContentPath("", userID.String(), hash))
+	if err != nil || !bytes.Equal(stored, ciphertext) {
+		t.Errorf("Expected the ciphertext to be stored as it was uploaded, got %q (%v)", stored, err)
+	}
+	var algorithm string
+	db.QueryRow(context.Background(), `
+		SELECT fe.algorithm FROM file_encryption fe JOIN files f ON f.id = fe.file_id
+		WHERE f.user_id = $1`, userID).Scan(&algorithm)
+	if algorithm != "AES-256-GCM" {
+		t.Errorf("Expected the encryption metadata to be recorded, got algorithm %q", algorithm)
+	}
+
+	for name, rejected := range map[string]*httptest.ResponseRecorder{
+		"malformed metadata": encryptedUpload(`{"algorithm": "AES-256-GCM", "iv": "???"}`, 1),
+		"several files":      encryptedUpload(metadata, 2),
+	} {
+		if rejected.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d: %s", name, rejected.Code, rejected.Body.String())
+		}
+	}
+}
+
+func TestUploadRoutes_Finalize(t *testing.T) {
+	router, db, _, storageDir, jwtManager := newUploadRouter(t, 64)
+
+	userID := testutil.CreateUser(t, db, "direct", nil)
+	token, err := jwtManager.GenerateToken(userID.String(), "direct@example.com", "USER")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	// placeObject puts content where a presigned PUT would have, returning its key
+	placeObject := func(content []byte) string {
+		key := storage.PendingUploadPath(userID.String(), uuid.NewString())
+		path := filepath.Join(storageDir, key)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to place object: %v", err)
+		}
+		return key
+	}
+
+	finalize := func(key string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"key":%q,"filename":"notes.txt","mimeType":"text/plain"}`, key)
+		req := httptest.NewRequest(http.MethodPost, "/files/finalize", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("pre-placed object", func(t *testing.T) {
+		content := []byte("direct " + uuid.NewString())
+		hash := fmt.Sprintf("%x", sha256.Sum256(content))
+		t.Cleanup(func() {
+			db.Exec(context.Background(), "DELETE FROM files WHERE content_hash = $1", hash)
+			db.Exec(context.Background(), "DELETE FROM file_contents WHERE content_hash = $1", hash)
+		})
+		key := placeObject(content)
+
+		recorder := finalize(key)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+
+		var size int64
+		if err := db.QueryRow(context.Background(), `
+			SELECT file_size FROM files WHERE user_id = $1 AND content_hash = $2`, userID, hash).Scan(&size); err != nil {
+			t.Fatalf("Expected a file record for the content: %v", err)
+		}
+		if size != int64(len(content)) {
+			t.Errorf("Expected file size %d, got %d", len(content), size)
+		}
+		if _, err := os.Stat(filepath.Join(storageDir, key)); !os.IsNotExist(err) {
+			t.Error("Expected the temporary object to be removed")
+		}
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		recorder := finalize(storage.PendingUploadPath(userID.String(), uuid.NewString()))
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+	})
+
+	t.Run("oversized object", func(t *testing.T) {
+		key := placeObject(bytes.Repeat([]byte("x"), 65))
+
+		recorder := finalize(key)
+		if recorder.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if _, err := os.Stat(filepath.Join(storageDir, key)); !os.IsNotExist(err) {
+			t.Error("Expected the oversized object to be removed")
+		}
+	})
+
+	t.Run("another user's key", func(t *testing.T) {
+		key := storage.PendingUploadPath(uuid.NewString(), uuid.NewString())
+		if recorder := finalize(key); recorder.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+	})
+}
+
+func TestUploadRoutes_CheckAndReference(t *testing.T) {
+	router, db, _, _, jwtManager := newUploadRouter(t, 1<<20)
+
+	owner := testutil.CreateUser(t, db, "original", nil)
+	content := []byte("already stored " + uuid.NewString())
+	testutil.CreateFile(t, db, owner, "original.txt", content)
+	hash := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	userID := testutil.CreateUser(t, db, "second", nil)
+	token, err := jwtManager.GenerateToken(userID.String(), "second@example.com", "USER")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	checks := []struct {
+		name       string
+		hash       string
+		size       int
+		algorithm  string
+		wantExists bool
+	}{
+		{"exists", hash, len(content), "", true},
+		{"exists with the algorithm named", hash, len(content), "SHA256", true},
+		{"unknown hash", fmt.Sprintf("%x", sha256.Sum256([]byte(uuid.NewString()))), len(content), "", false},
+		{"wrong size", hash, len(content) + 1, "", false},
+		{"another algorithm", hash, len(content), "blake2b-256", false},
+	}
+	for _, check := range checks {
+		t.Run(check.name, func(t *testing.T) {
+			recorder := post("/files/check", fmt.Sprintf(`{"contentHash":%q,"hashAlgorithm":%q,"fileSize":%d}`, check.hash, check.algorithm, check.size))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			want := fmt.Sprintf(`{"exists":%t}`, check.wantExists)
+			if recorder.Body.String() != want {
+				t.Errorf("Expected %s, got %s", want, recorder.Body.String())
+			}
+		})
+	}
+
+	if recorder := post("/files/check", fmt.Sprintf(`{"contentHash":%q,"hashAlgorithm":"md5","fileSize":%d}`, hash, len(content))); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unsupported algorithm, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	// Claiming the content with the wrong size creates nothing
+	if recorder := post("/files/reference", fmt.Sprintf(`{"contentHash":%q,"fileSize":%d,"filename":"mine.txt"}`, hash, len(content)+1)); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a size mismatch, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var before int
+	db.QueryRow(context.Background(), "SELECT reference_count FROM file_contents WHERE content_hash = $1", hash).Scan(&before)
+
+	recorder := post("/files/reference", fmt.Sprintf(`{"contentHash":%q,"fileSize":%d,"filename":"mine.txt"}`, hash, len(content)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var name string
+	if err := db.QueryRow(context.Background(), `
+		SELECT original_name FROM files WHERE user_id = $1 AND content_hash = $2`, userID, hash).Scan(&name); err != nil {
+		t.Fatalf("Expected a file record for the referencing user: %v", err)
+	}
+	if name != "mine.txt" {
+		t.Errorf("Expected the file to be named mine.txt, got %s", name)
+	}
+
+	var after int
+	db.QueryRow(context.Background(), "SELECT reference_count FROM file_contents WHERE content_hash = $1", hash).Scan(&after)
+	if after != before+1 {
+		t.Errorf("Expected the reference count to go from %d to %d, got %d", before, before+1, after)
+	}
+}
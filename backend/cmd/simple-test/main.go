@@ -107,17 +107,27 @@ func main() {
 	// Test 5: List Files
 	logger.Info("Test 5: List Files")
 
-	files, err := s3Storage.ListFiles(ctx, "test-enterprise/")
-	if err != nil {
-		logger.Fatal("Failed to list files", zap.Error(err))
-	}
-
-	logger.Info("✅ Files listed successfully", zap.Int("count", len(files)))
-	for _, file := range files {
-		logger.Info("File found",
-			zap.String("path", file.Path),
-			zap.Int64("size", file.Size))
-	}
+	// Page through the prefix so a bucket with many objects isn't listed in one go
+	var fileCount int
+	token := ""
+	for {
+		files, next, err := s3Storage.ListFilesPage(ctx, "test-enterprise/", token, storage.DefaultListPageSize)
+		if err != nil {
+			logger.Fatal("Failed to list files", zap.Error(err))
+		}
+		fileCount += len(files)
+		for _, file := range files {
+			logger.Info("File found",
+				zap.String("path", file.Path),
+				zap.Int64("size", file.Size))
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	logger.Info("✅ Files listed successfully", zap.Int("count", fileCount))
 
 	// Test 6: Presigned URL Generation
 	logger.Info("Test 6: Generate Presigned URL")
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/storage"
+)
+
+func main() {
+	userFlag := flag.String("user", "", "ID of the user whose content to check; all content when empty")
+	sample := flag.Float64("sample", 100, "percentage of stored content to check")
+	concurrency := flag.Int("concurrency", 4, "number of objects checked at once")
+	record := flag.Bool("record", false, "write the issues found to the integrity_issues table")
+	fix := flag.Bool("fix", false, "quarantine the files whose content is missing or corrupted")
+	flag.Parse()
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found: %v", err)
+	}
+
+	// Initialize logger
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatal("Failed to initialize logger:", err)
+	}
+	defer logger.Sync()
+
+	var userID *uuid.UUID
+	if *userFlag != "" {
+		parsed, err := uuid.Parse(*userFlag)
+		if err != nil {
+			logger.Fatal("Invalid user ID", zap.String("user", *userFlag))
+		}
+		userID = &parsed
+	}
+
+	// Get database URL
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		logger.Fatal("DATABASE_URL environment variable is required")
+	}
+
+	// Connect to database
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", zap.Error(err))
+	}
+	defer db.Close()
+
+	// Test connection
+	err = db.Ping(ctx)
+	if err != nil {
+		logger.Fatal("Failed to ping database", zap.Error(err))
+	}
+
+	storageService, err := storage.NewStorageService(storage.ConfigFromEnv(os.Getenv), logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize storage service", zap.Error(err))
+	}
+
+	// Verify stored content against its hashes once and exit
+	integrity := services.NewIntegrityService(db, storageService, services.NewAuditService(db, logger), logger)
+	report, err := integrity.Verify(ctx, services.IntegrityOptions{
+		UserID:        userID,
+		SamplePercent: *sample,
+		Concurrency:   *concurrency,
+		Record:        *record,
+		Fix:           *fix,
+	})
+	if err != nil {
+		logger.Fatal("Failed to verify stored content", zap.Error(err))
+	}
+
+	for _, issue := range report.Issues {
+		logger.Warn("Integrity issue found",
+			zap.String("content_hash", issue.ContentHash),
+			zap.String("file_path", issue.FilePath),
+			zap.String("issue", issue.Issue),
+			zap.String("actual_hash", issue.ActualHash))
+	}
+
+	logger.Info("Integrity verification completed",
+		zap.Int("checked", report.Checked),
+		zap.Int("issues", len(report.Issues)),
+		zap.Int("failed", report.Failed),
+		zap.Int("quarantined", report.Quarantined))
+
+	// A non-zero exit lets scheduled runs alert on problems
+	if len(report.Issues) > 0 || report.Failed > 0 {
+		logger.Sync()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrUnsupportedConversion is returned when content of a type can't be converted
+var ErrUnsupportedConversion = errors.New("unsupported conversion")
+
+// Converter defines the interface for converting documents to other formats
+type Converter interface {
+	// ConvertToPDF returns content of the given mime type rendered as a PDF
+	ConvertToPDF(ctx context.Context, content []byte, mimeType string) ([]byte, error)
+}
+
+// ConverterConfig contains configuration for the conversion backend
+type ConverterConfig struct {
+	Enabled         bool          `json:"enabled"`
+	LibreOfficePath string        `json:"libreoffice_path"` // soffice binary to run headless
+	Timeout         time.Duration `json:"timeout"`          // How long a single conversion may take
+}
+
+// pdfSources maps the mime types that can be converted to PDF to the file extension LibreOffice
+// expects for them
+var pdfSources = map[string]string{
+	"application/msword": ".doc",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": ".docx",
+	"application/vnd.ms-excel": ".xls",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.ms-powerpoint":                                             ".ppt",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+	"application/vnd.oasis.opendocument.text":                                   ".odt",
+	"application/vnd.oasis.opendocument.spreadsheet":                            ".ods",
+	"application/vnd.oasis.opendocument.presentation":                           ".odp",
+	"application/rtf": ".rtf",
+	"text/plain":      ".txt",
+	"text/csv":        ".csv",
+}
+
+// SupportsPDF reports whether content of mimeType can be converted to PDF
+func SupportsPDF(mimeType string) bool {
+	_, ok := pdfSources[mimeType]
+	return ok
+}
+
+// NoopConverter converts nothing; used when conversion is disabled
+type NoopConverter struct{}
+
+// ConvertToPDF always reports the conversion as unsupported
+func (NoopConverter) ConvertToPDF(ctx context.Context, content []byte, mimeType string) ([]byte, error) {
+	return nil, ErrUnsupportedConversion
+}
+
+// NewConverter creates a converter based on the configuration
+func NewConverter(config ConverterConfig, logger *zap.Logger) (Converter, error) {
+	if !config.Enabled {
+		return NoopConverter{}, nil
+	}
+
+	if config.LibreOfficePath == "" {
+		return nil, fmt.Errorf("LibreOffice path is required when conversion is enabled")
+	}
+
+	logger.Info("Document conversion enabled", zap.String("libreoffice_path", config.LibreOfficePath))
+	return NewLibreOfficeConverter(config.LibreOfficePath, config.Timeout), nil
+}
@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// LibreOfficeConverter converts documents by running LibreOffice headless on a temporary copy
+type LibreOfficeConverter struct {
+	path    string
+	timeout time.Duration
+}
+
+// NewLibreOfficeConverter creates a converter running the soffice binary at path
+func NewLibreOfficeConverter(path string, timeout time.Duration) *LibreOfficeConverter {
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+
+	return &LibreOfficeConverter{
+		path:    path,
+		timeout: timeout,
+	}
+}
+
+// ConvertToPDF writes content to a temporary directory and has LibreOffice convert it there. Each
+// run gets its own profile directory, since LibreOffice refuses to share one between processes.
+func (c *LibreOfficeConverter) ConvertToPDF(ctx context.Context, content []byte, mimeType string) ([]byte, error) {
+	extension, ok := pdfSources[mimeType]
+	if !ok {
+		return nil, ErrUnsupportedConversion
+	}
+
+	dir, err := os.MkdirTemp("", "lokr-convert-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversion directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "document"+extension)
+	if err := os.WriteFile(input, content, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write document: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.path,
+		"-env:UserInstallation=file://"+filepath.ToSlash(filepath.Join(dir, "profile")),
+		"--headless", "--convert-to", "pdf", "--outdir", dir, input)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("LibreOffice conversion failed: %w: %s", err, stderr.String())
+	}
+
+	pdf, err := os.ReadFile(filepath.Join(dir, "document.pdf"))
+	if err != nil {
+		return nil, fmt.Errorf("LibreOffice produced no PDF: %w", err)
+	}
+	return pdf, nil
+}
@@ -0,0 +1,53 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// fakeSoffice writes a script standing in for soffice that "converts" by copying its input
+func fakeSoffice(t *testing.T) string {
+	t.Helper()
+
+	script := filepath.Join(t.TempDir(), "soffice")
+	// Arguments: -env:UserInstallation=... --headless --convert-to pdf --outdir DIR INPUT
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncp \"$7\" \"$6/document.pdf\"\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake soffice: %v", err)
+	}
+	return script
+}
+
+func TestLibreOfficeConverter_ConvertToPDF(t *testing.T) {
+	converter := NewLibreOfficeConverter(fakeSoffice(t), 0)
+
+	pdf, err := converter.ConvertToPDF(context.Background(), []byte("quarterly figures"), "text/csv")
+	if err != nil {
+		t.Fatalf("ConvertToPDF failed: %v", err)
+	}
+	if string(pdf) != "quarterly figures" {
+		t.Errorf("Expected the converted output, got %q", pdf)
+	}
+
+	if _, err := converter.ConvertToPDF(context.Background(), []byte{0x89, 'P', 'N', 'G'}, "image/png"); !errors.Is(err, ErrUnsupportedConversion) {
+		t.Errorf("Expected ErrUnsupportedConversion for an image, got %v", err)
+	}
+}
+
+func TestNewConverter(t *testing.T) {
+	disabled, err := NewConverter(ConverterConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewConverter failed: %v", err)
+	}
+	if _, err := disabled.ConvertToPDF(context.Background(), []byte("text"), "text/plain"); !errors.Is(err, ErrUnsupportedConversion) {
+		t.Errorf("Expected a disabled converter to convert nothing, got %v", err)
+	}
+
+	if _, err := NewConverter(ConverterConfig{Enabled: true}, zap.NewNop()); err == nil {
+		t.Error("Expected an error when enabled without a LibreOffice path")
+	}
+}
@@ -0,0 +1,83 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// previewSize bounds the longer side, in pixels, of a rendered page
+const previewSize = 1024
+
+// pdftoppm exit codes for a PDF it couldn't open and one whose permissions forbid rendering
+const (
+	pdftoppmOpenFailed       = 1
+	pdftoppmPermissionDenied = 3
+)
+
+// PopplerRenderer renders PDFs by running poppler's pdftoppm on a temporary copy
+type PopplerRenderer struct {
+	path    string
+	timeout time.Duration
+}
+
+// NewPopplerRenderer creates a renderer running the pdftoppm binary at path
+func NewPopplerRenderer(path string, timeout time.Duration) *PopplerRenderer {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &PopplerRenderer{
+		path:    path,
+		timeout: timeout,
+	}
+}
+
+// RenderFirstPage writes pdf to a temporary directory and has pdftoppm render its first page
+// there. A file that isn't a PDF, or that pdftoppm can't open or may not render, is
+// ErrUnreadablePDF.
+func (r *PopplerRenderer) RenderFirstPage(ctx context.Context, pdf []byte) ([]byte, error) {
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		return nil, ErrUnreadablePDF
+	}
+
+	dir, err := os.MkdirTemp("", "lokr-render-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rendering directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "document.pdf")
+	if err := os.WriteFile(input, pdf, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write document: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.path,
+		"-png", "-f", "1", "-l", "1", "-singlefile", "-scale-to", strconv.Itoa(previewSize),
+		input, filepath.Join(dir, "page"))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil &&
+			(exitErr.ExitCode() == pdftoppmOpenFailed || exitErr.ExitCode() == pdftoppmPermissionDenied) {
+			return nil, fmt.Errorf("%w: %s", ErrUnreadablePDF, stderr.String())
+		}
+		return nil, fmt.Errorf("pdftoppm rendering failed: %w: %s", err, stderr.String())
+	}
+
+	png, err := os.ReadFile(filepath.Join(dir, "page.png"))
+	if err != nil {
+		return nil, fmt.Errorf("pdftoppm produced no image: %w", err)
+	}
+	return png, nil
+}
@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// fakePdftoppm writes a script standing in for pdftoppm that "renders" by copying its input, and
+// fails like pdftoppm on a PDF it can't open when the input mentions "locked"
+func fakePdftoppm(t *testing.T) string {
+	t.Helper()
+
+	script := filepath.Join(t.TempDir(), "pdftoppm")
+	// Arguments: -png -f 1 -l 1 -singlefile -scale-to SIZE INPUT PREFIX
+	body := "#!/bin/sh\ngrep -q locked \"$9\" && { echo 'Incorrect password' >&2; exit 1; }\ncp \"$9\" \"${10}.png\"\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatalf("Failed to write fake pdftoppm: %v", err)
+	}
+	return script
+}
+
+// minimalPDF is a one-page PDF with an empty page
+const minimalPDF = `%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] >> endobj
+trailer << /Root 1 0 R >>
+%%EOF
+`
+
+func TestPopplerRenderer_RenderFirstPage(t *testing.T) {
+	renderer := NewPopplerRenderer(fakePdftoppm(t), 0)
+
+	png, err := renderer.RenderFirstPage(context.Background(), []byte("%PDF-1.7 page one"))
+	if err != nil {
+		t.Fatalf("RenderFirstPage failed: %v", err)
+	}
+	if string(png) != "%PDF-1.7 page one" {
+		t.Errorf("Expected the rendered output, got %q", png)
+	}
+
+	for name, content := range map[string]string{
+		"a password-protected PDF": "%PDF-1.7 locked",
+		"a file that isn't a PDF":  "plain text",
+	} {
+		if _, err := renderer.RenderFirstPage(context.Background(), []byte(content)); !errors.Is(err, ErrUnreadablePDF) {
+			t.Errorf("Expected ErrUnreadablePDF for %s, got %v", name, err)
+		}
+	}
+}
+
+func TestPopplerRenderer_RealPdftoppm(t *testing.T) {
+	path, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		t.Skip("pdftoppm is not installed")
+	}
+	renderer := NewPopplerRenderer(path, 0)
+
+	png, err := renderer.RenderFirstPage(context.Background(), []byte(minimalPDF))
+	if err != nil {
+		t.Fatalf("RenderFirstPage failed: %v", err)
+	}
+	if !bytes.HasPrefix(png, []byte("\x89PNG\r\n\x1a\n")) {
+		t.Errorf("Expected a PNG, got %q", png[:min(len(png), 16)])
+	}
+
+	if _, err := renderer.RenderFirstPage(context.Background(), []byte("%PDF-1.4 truncated")); !errors.Is(err, ErrUnreadablePDF) {
+		t.Errorf("Expected ErrUnreadablePDF for a corrupt PDF, got %v", err)
+	}
+}
+
+func TestNewPDFRenderer(t *testing.T) {
+	disabled, err := NewPDFRenderer(RendererConfig{}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewPDFRenderer failed: %v", err)
+	}
+	if _, err := disabled.RenderFirstPage(context.Background(), []byte(minimalPDF)); !errors.Is(err, ErrUnsupportedConversion) {
+		t.Errorf("Expected a disabled renderer to render nothing, got %v", err)
+	}
+
+	if _, err := NewPDFRenderer(RendererConfig{Enabled: true}, zap.NewNop()); err == nil {
+		t.Error("Expected an error when enabled without a pdftoppm path")
+	}
+}
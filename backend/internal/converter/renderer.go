@@ -0,0 +1,48 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrUnreadablePDF is returned when a PDF is corrupt or password-protected and can't be rendered
+var ErrUnreadablePDF = errors.New("unreadable PDF")
+
+// PDFRenderer defines the interface for rendering PDFs as images
+type PDFRenderer interface {
+	// RenderFirstPage returns the first page of pdf as a PNG
+	RenderFirstPage(ctx context.Context, pdf []byte) ([]byte, error)
+}
+
+// RendererConfig contains configuration for the PDF rendering backend
+type RendererConfig struct {
+	Enabled      bool          `json:"enabled"`
+	PdftoppmPath string        `json:"pdftoppm_path"` // poppler's pdftoppm binary
+	Timeout      time.Duration `json:"timeout"`       // How long rendering a single page may take
+}
+
+// NoopRenderer renders nothing; used when rendering is disabled
+type NoopRenderer struct{}
+
+// RenderFirstPage always reports the rendering as unsupported
+func (NoopRenderer) RenderFirstPage(ctx context.Context, pdf []byte) ([]byte, error) {
+	return nil, ErrUnsupportedConversion
+}
+
+// NewPDFRenderer creates a PDF renderer based on the configuration
+func NewPDFRenderer(config RendererConfig, logger *zap.Logger) (PDFRenderer, error) {
+	if !config.Enabled {
+		return NoopRenderer{}, nil
+	}
+
+	if config.PdftoppmPath == "" {
+		return nil, fmt.Errorf("pdftoppm path is required when PDF rendering is enabled")
+	}
+
+	logger.Info("PDF preview rendering enabled", zap.String("pdftoppm_path", config.PdftoppmPath))
+	return NewPopplerRenderer(config.PdftoppmPath, config.Timeout), nil
+}
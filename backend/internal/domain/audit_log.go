@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,12 +18,16 @@ const (
 	ActionFileDelete    AuditAction = "FILE_DELETE"
 	ActionFileMove      AuditAction = "FILE_MOVE"
 	ActionFileRename    AuditAction = "FILE_RENAME"
+	ActionFileQuarantine AuditAction = "FILE_QUARANTINE"
 
 	// Sharing operations
 	ActionFileShare     AuditAction = "FILE_SHARE"
 	ActionFileUnshare   AuditAction = "FILE_UNSHARE"
 	ActionPublicShare   AuditAction = "PUBLIC_SHARE"
 	ActionPublicUnshare AuditAction = "PUBLIC_UNSHARE"
+	ActionFolderShare   AuditAction = "FOLDER_SHARE"
+	ActionUserShare     AuditAction = "USER_SHARE"
+	ActionShareRevoked  AuditAction = "SHARE_REVOKED"
 
 	// Folder operations
 	ActionFolderCreate  AuditAction = "FOLDER_CREATE"
@@ -34,8 +39,31 @@ const (
 	ActionUserLogin     AuditAction = "USER_LOGIN"
 	ActionUserLogout    AuditAction = "USER_LOGOUT"
 	ActionUserRegister  AuditAction = "USER_REGISTER"
+
+	// Administration
+	ActionQuotaUpdate   AuditAction = "QUOTA_UPDATE"
+	ActionMemberRemove  AuditAction = "MEMBER_REMOVE"
 )
 
+// AuditActions lists every action that is logged
+var AuditActions = []AuditAction{
+	ActionFileUpload, ActionFileDownload, ActionFilePreview, ActionFileDelete, ActionFileMove,
+	ActionFileRename, ActionFileQuarantine,
+	ActionFileShare, ActionFileUnshare, ActionPublicShare, ActionPublicUnshare, ActionFolderShare,
+	ActionUserShare, ActionShareRevoked,
+	ActionFolderCreate, ActionFolderDelete, ActionFolderMove, ActionFolderRename,
+	ActionUserLogin, ActionUserLogout, ActionUserRegister,
+	ActionQuotaUpdate, ActionMemberRemove,
+}
+
+// FileAccessActions are the actions that read a file's content, the ones its access history lists
+var FileAccessActions = []AuditAction{ActionFileDownload, ActionFilePreview}
+
+// IsKnownAuditAction reports whether action is one of AuditActions
+func IsKnownAuditAction(action AuditAction) bool {
+	return slices.Contains(AuditActions, action)
+}
+
 // AuditStatus represents the result of the action
 type AuditStatus string
 
@@ -45,6 +73,14 @@ const (
 	StatusPending AuditStatus = "PENDING"
 )
 
+// AuditAnomaly is a reason an audit entry was flagged as unusual for its user
+type AuditAnomaly string
+
+const (
+	AnomalyNewLocation      AuditAnomaly = "NEW_LOCATION"      // From an address or city the user hasn't used recently
+	AnomalyImpossibleTravel AuditAnomaly = "IMPOSSIBLE_TRAVEL" // Too far from the user's previous location to have got there since
+)
+
 // AuditLog represents a single audit log entry
 type AuditLog struct {
 	ID           uuid.UUID  `json:"id"`
@@ -76,6 +112,34 @@ type AuditLogEntry struct {
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// AuditLogFilter narrows an audit log query; nil fields are not applied
+type AuditLogFilter struct {
+	UserID       *uuid.UUID
+	EnterpriseID *uuid.UUID // All members of the enterprise, used for admin exports
+	ResourceID   *uuid.UUID // A single file, folder or user the logs are about
+	From         *time.Time // Inclusive
+	To           *time.Time // Exclusive
+	Action       *AuditAction
+	Actions      []AuditAction // Any of these actions
+	Status       *AuditStatus
+	Flagged      bool // Only entries flagged with anomalies
+}
+
+// ActivityBucket is the width of one point in an activity time series
+type ActivityBucket string
+
+const (
+	ActivityBucketDay  ActivityBucket = "day"
+	ActivityBucketHour ActivityBucket = "hour"
+)
+
+// ActivityTimePoint holds the per-action counts for one bucket of an activity time series
+type ActivityTimePoint struct {
+	Bucket time.Time      `json:"bucket"`
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"` // Keyed by action; empty for buckets without activity
+}
+
 // FormatDescription creates a human-readable description for common actions
 func (entry *AuditLogEntry) FormatDescription() string {
 	switch entry.Action {
@@ -87,6 +151,12 @@ func (entry *AuditLogEntry) FormatDescription() string {
 		return "Previewed file: " + entry.ResourceName
 	case ActionFileDelete:
 		return "Deleted file: " + entry.ResourceName
+	case ActionFileMove:
+		return "Moved file: " + entry.ResourceName
+	case ActionFileRename:
+		return "Renamed file: " + entry.ResourceName
+	case ActionFileQuarantine:
+		return "Quarantined infected file: " + entry.ResourceName
 	case ActionFileShare:
 		return "Shared file: " + entry.ResourceName
 	case ActionFileUnshare:
@@ -95,16 +165,30 @@ func (entry *AuditLogEntry) FormatDescription() string {
 		return "Made file public: " + entry.ResourceName
 	case ActionPublicUnshare:
 		return "Made file private: " + entry.ResourceName
+	case ActionFolderShare:
+		return "Shared folder: " + entry.ResourceName
+	case ActionUserShare:
+		return "Shared file with a user: " + entry.ResourceName
+	case ActionShareRevoked:
+		return "Revoked a user's share of file: " + entry.ResourceName
 	case ActionFolderCreate:
 		return "Created folder: " + entry.ResourceName
 	case ActionFolderDelete:
 		return "Deleted folder: " + entry.ResourceName
+	case ActionFolderRename:
+		return "Renamed folder: " + entry.ResourceName
+	case ActionFolderMove:
+		return "Moved folder: " + entry.ResourceName
 	case ActionUserLogin:
 		return "User logged in"
 	case ActionUserLogout:
 		return "User logged out"
 	case ActionUserRegister:
 		return "User registered"
+	case ActionQuotaUpdate:
+		return "Changed storage quota for: " + entry.ResourceName
+	case ActionMemberRemove:
+		return "Removed enterprise member: " + entry.ResourceName
 	default:
 		return entry.Description
 	}
@@ -13,6 +13,7 @@ type Enterprise struct {
 	Name                string                 `json:"name" db:"name" validate:"required,min=2,max=255"`
 	Slug                string                 `json:"slug" db:"slug" validate:"required,min=2,max=100,alpha_dash"`
 	Domain              *string                `json:"domain" db:"domain" validate:"omitempty,hostname"`
+	DomainVerified      bool                   `json:"domain_verified" db:"domain_verified"`
 	StorageQuota        int64                  `json:"storage_quota" db:"storage_quota" validate:"min=0"`
 	StorageUsed         int64                  `json:"storage_used" db:"storage_used"`
 	MaxUsers            int                    `json:"max_users" db:"max_users" validate:"min=1"`
@@ -93,6 +94,28 @@ type InviteUserRequest struct {
 	Role  EnterpriseRole `json:"role" validate:"required,oneof=ADMIN MEMBER"`
 }
 
+// MemberFilePolicy decides what happens to a member's files when they are removed from an enterprise
+type MemberFilePolicy string
+
+const (
+	// MemberFilesReassign hands the member's files and folders to the admin removing them, gathered
+	// in a new root folder named after the member
+	MemberFilesReassign MemberFilePolicy = "REASSIGN"
+	// MemberFilesRetain leaves the files with the departing member and revokes the shares they made
+	// of them, since shares only reach members of the same enterprise
+	MemberFilesRetain MemberFilePolicy = "RETAIN"
+)
+
+// MemberRemoval describes a completed removal of a member from an enterprise
+type MemberRemoval struct {
+	EnterpriseID  uuid.UUID
+	UserID        uuid.UUID
+	Email         string
+	FilePolicy    MemberFilePolicy
+	FilesAffected int64
+	ReassignedTo  *uuid.UUID // Set under MemberFilesReassign
+}
+
 // EnterpriseStats represents statistics for an enterprise
 type EnterpriseStats struct {
 	TotalUsers       int     `json:"total_users"`
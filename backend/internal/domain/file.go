@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"encoding/base64"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +19,31 @@ const (
 	VisibilitySharedWithUsers FileVisibility = "SHARED_WITH_USERS"
 )
 
+// FileStatus represents whether a file can be served: its malware scan state, or a failed integrity check
+type FileStatus string
+
+const (
+	FileStatusActive      FileStatus = "ACTIVE"
+	FileStatusPendingScan FileStatus = "PENDING_SCAN" // Listed but not downloadable until scanned
+	FileStatusInfected    FileStatus = "INFECTED"     // Quarantined: hidden and not downloadable
+	FileStatusCorrupted   FileStatus = "CORRUPTED"    // Content failed integrity verification: listed but not downloadable
+)
+
+// FileAccessType is the kind of access recorded in a file's access history
+type FileAccessType string
+
+const (
+	FileAccessDownload FileAccessType = "DOWNLOAD"
+	FileAccessPreview  FileAccessType = "PREVIEW"
+)
+
+// FileAccessDay counts the downloads and previews of a file on one UTC day
+type FileAccessDay struct {
+	Date      time.Time `json:"date"`
+	Downloads int       `json:"downloads"`
+	Previews  int       `json:"previews"`
+}
+
 // PermissionType represents sharing permission types
 type PermissionType string
 
@@ -44,6 +72,16 @@ type File struct {
 	UploadDate    time.Time      `json:"upload_date" db:"upload_date"`
 	UpdatedAt     time.Time      `json:"updated_at" db:"updated_at"`
 
+	// IsStarred is whether the requesting user has the file in their favorites
+	IsStarred bool `json:"is_starred" db:"-"`
+	// Lock is the file's edit lock while one is held
+	Lock *FileLock `json:"lock,omitempty" db:"-"`
+	// Encryption is set when the client encrypted the content before uploading it
+	Encryption *FileEncryption `json:"encryption,omitempty" db:"-"`
+	// LastAccessedAt is when the requesting user last downloaded or previewed the file, where a
+	// listing reports it
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty" db:"-"`
+
 	// Relations (populated by joins or separate queries)
 	User    *User        `json:"user,omitempty"`
 	Folder  *Folder      `json:"folder,omitempty"`
@@ -51,9 +89,27 @@ type File struct {
 	Shares  []*FileShare `json:"shares,omitempty"`
 }
 
+// FileLock is an edit lock on a file: while it lasts, only its holder may change the file
+type FileLock struct {
+	FileID     uuid.UUID `json:"file_id"`
+	HolderID   uuid.UUID `json:"holder_id"`
+	HolderName string    `json:"holder_name"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// FileEncryption is what a client needs to decrypt a file it encrypted before uploading. The
+// server stores it as given and never sees the plaintext or the unwrapped key.
+type FileEncryption struct {
+	Algorithm  string `json:"algorithm"`
+	IV         string `json:"iv"`          // Base64
+	WrappedKey string `json:"wrapped_key"` // Base64, wrapped by a key only the client holds
+}
+
 // FileContent represents deduplicated file content
 type FileContent struct {
 	ContentHash    string     `json:"content_hash" db:"content_hash"`
+	HashAlgorithm  string     `json:"hash_algorithm" db:"hash_algorithm"`
 	FilePath       string     `json:"file_path" db:"file_path"`
 	FileSize       int64      `json:"file_size" db:"file_size"`
 	ReferenceCount int        `json:"reference_count" db:"reference_count"`
@@ -76,6 +132,16 @@ type Folder struct {
 	Files    []*File `json:"files,omitempty"`
 }
 
+// FolderStats summarises a folder and everything beneath it. FolderID is nil for the root,
+// which covers all of the user's files.
+type FolderStats struct {
+	FolderID    *uuid.UUID `json:"folder_id"`
+	FolderCount int        `json:"folder_count"` // Subfolders at any depth
+	FileCount   int        `json:"file_count"`
+	TotalSize   int64      `json:"total_size"`   // Logical bytes, counted once per file
+	DedupedSize int64      `json:"deduped_size"` // Bytes of distinct content, counted once per content hash
+}
+
 // FileShare represents file sharing permissions
 type FileShare struct {
 	ID               uuid.UUID      `json:"id" db:"id"`
@@ -94,6 +160,19 @@ type FileShare struct {
 	SharedWith   *User `json:"shared_with,omitempty"`
 }
 
+// FolderShare grants a user access to a folder, its subfolders at any depth and every file in them
+type FolderShare struct {
+	ID               uuid.UUID      `json:"id" db:"id"`
+	FolderID         uuid.UUID      `json:"folder_id" db:"folder_id"`
+	SharedByUserID   uuid.UUID      `json:"shared_by_user_id" db:"shared_by_user_id"`
+	SharedWithUserID uuid.UUID      `json:"shared_with_user_id" db:"shared_with_user_id"`
+	PermissionType   PermissionType `json:"permission_type" db:"permission_type"`
+	CreatedAt        time.Time      `json:"created_at" db:"created_at"`
+
+	// Relations
+	Folder *Folder `json:"folder,omitempty"`
+}
+
 // FileUploadRequest represents a file upload request
 type FileUploadRequest struct {
 	UserID      uuid.UUID      `json:"user_id" validate:"required"`
@@ -142,6 +221,14 @@ type FileShareRequest struct {
 	ExpiresAt      *time.Time       `json:"expires_at"`
 }
 
+// FileShareResult is the outcome of a FileShareRequest for one of its users: the share when it
+// was made, otherwise Error saying why the user was skipped
+type FileShareResult struct {
+	UserID uuid.UUID  `json:"user_id"`
+	Share  *FileShare `json:"share,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
 type ShareFileInput struct {
 	FileID         uuid.UUID       `json:"fileId"`
 	SharedWithUserID uuid.UUID     `json:"sharedWithUserId"`
@@ -152,16 +239,110 @@ type ShareFileInput struct {
 type PublicShareResponse struct {
 	ShareToken string `json:"shareToken"`
 	ShareURL   string `json:"shareUrl"`
+	// ShortCode and ShortURL are only set when short links are enabled
+	ShortCode string `json:"shortCode,omitempty"`
+	ShortURL  string `json:"shortUrl,omitempty"`
 }
 
 type FileShareInfo struct {
 	IsShared       bool            `json:"isShared"`
 	ShareToken     string          `json:"shareToken,omitempty"`
 	ShareURL       string          `json:"shareUrl,omitempty"`
+	ShortURL       string          `json:"shortUrl,omitempty"`
 	SharedWithUsers []FileShare     `json:"sharedWithUsers"`
 	DownloadCount   int            `json:"downloadCount"`
 }
 
+// DuplicateGroup is a set of a user's files that share the same content
+type DuplicateGroup struct {
+	ContentHash string  `json:"content_hash"`
+	FileSize    int64   `json:"file_size"`
+	Files       []*File `json:"files"` // Oldest first
+}
+
+// FileCursor marks a position in a list of files ordered newest first. The id breaks ties
+// between files uploaded at the same instant.
+type FileCursor struct {
+	UploadDate time.Time
+	ID         uuid.UUID
+}
+
+// Encode returns the cursor as an opaque string for clients
+func (c FileCursor) Encode() string {
+	raw := c.UploadDate.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeFileCursor parses a cursor produced by FileCursor.Encode
+func DecodeFileCursor(cursor string) (*FileCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	uploadDate, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errors.New("invalid cursor")
+	}
+
+	parsedDate, err := time.Parse(time.RFC3339Nano, uploadDate)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &FileCursor{UploadDate: parsedDate, ID: parsedID}, nil
+}
+
+// TagCount is a tag and the number of files carrying it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// UploadSession is a resumable upload in progress. The file arrives in ChunkCount chunks of
+// ChunkSize bytes, the last one possibly shorter, in any order and over any number of requests.
+type UploadSession struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	Filename       string     `json:"filename" db:"filename"`
+	MimeType       string     `json:"mime_type" db:"mime_type"`
+	FolderID       *uuid.UUID `json:"folder_id" db:"folder_id"`
+	FileSize       int64      `json:"file_size" db:"file_size"`
+	ChunkSize      int64      `json:"chunk_size" db:"chunk_size"`
+	ChunkCount     int        `json:"chunk_count" db:"chunk_count"`
+	ReceivedChunks []int      `json:"received_chunks" db:"-"` // Ascending
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+}
+
+// ChunkLength returns how many bytes the chunk at index must hold
+func (s *UploadSession) ChunkLength(index int) int64 {
+	if index == s.ChunkCount-1 {
+		return s.FileSize - int64(index)*s.ChunkSize
+	}
+	return s.ChunkSize
+}
+
+// MissingChunks returns the indexes of the chunks not received yet, in ascending order
+func (s *UploadSession) MissingChunks() []int {
+	received := make(map[int]bool, len(s.ReceivedChunks))
+	for _, index := range s.ReceivedChunks {
+		received[index] = true
+	}
+
+	missing := make([]int, 0, s.ChunkCount-len(received))
+	for index := 0; index < s.ChunkCount; index++ {
+		if !received[index] {
+			missing = append(missing, index)
+		}
+	}
+	return missing
+}
+
 // FileRepository defines the interface for file data operations
 type FileRepository interface {
 	Create(file *File) error
@@ -174,6 +355,7 @@ type FileRepository interface {
 	GetPublicFile(shareToken string) (*File, error)
 	IncrementDownloadCount(id uuid.UUID) error
 	GetSharedWithUser(userID uuid.UUID, limit, offset int) ([]*File, error)
+	GetByFolder(userID uuid.UUID, folderID *uuid.UUID, sortBy, sortOrder string, limit, offset int) ([]*File, int, error)
 }
 
 // FileContentRepository defines the interface for file content operations
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFileCursor_RoundTrip(t *testing.T) {
+	cursor := FileCursor{
+		UploadDate: time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC),
+		ID:         uuid.New(),
+	}
+
+	decoded, err := DecodeFileCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("DecodeFileCursor failed: %v", err)
+	}
+
+	if !decoded.UploadDate.Equal(cursor.UploadDate) {
+		t.Errorf("Expected upload date %v, got %v", cursor.UploadDate, decoded.UploadDate)
+	}
+	if decoded.ID != cursor.ID {
+		t.Errorf("Expected id %s, got %s", cursor.ID, decoded.ID)
+	}
+}
+
+func TestDecodeFileCursor_Invalid(t *testing.T) {
+	for _, cursor := range []string{"", "not base64!", "bm8tc2VwYXJhdG9y", "bm90LWEtZGF0ZXx4"} {
+		if _, err := DecodeFileCursor(cursor); err == nil {
+			t.Errorf("Expected cursor %q to be rejected", cursor)
+		}
+	}
+}
+
+func TestUploadSession_Chunks(t *testing.T) {
+	session := &UploadSession{FileSize: 10, ChunkSize: 4, ChunkCount: 3, ReceivedChunks: []int{1}}
+
+	if got := session.MissingChunks(); !reflect.DeepEqual(got, []int{0, 2}) {
+		t.Errorf("Expected chunks [0 2] missing, got %v", got)
+	}
+	if got := session.ChunkLength(0); got != 4 {
+		t.Errorf("Expected a full chunk of 4 bytes, got %d", got)
+	}
+	if got := session.ChunkLength(2); got != 2 {
+		t.Errorf("Expected the last chunk to hold the remaining 2 bytes, got %d", got)
+	}
+
+	session.ReceivedChunks = []int{0, 1, 2}
+	if got := session.MissingChunks(); len(got) != 0 {
+		t.Errorf("Expected no chunks missing, got %v", got)
+	}
+}
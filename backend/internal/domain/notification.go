@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationType identifies what a notification is about
+type NotificationType string
+
+const (
+	NotificationFileShared NotificationType = "FILE_SHARED"
+)
+
+// Notification is an in-app message for a single user
+type Notification struct {
+	ID           uuid.UUID        `json:"id"`
+	UserID       uuid.UUID        `json:"userId"`
+	ActorID      *uuid.UUID       `json:"actorId,omitempty"` // The user whose action caused it
+	Actor        *User            `json:"actor,omitempty"`
+	Type         NotificationType `json:"type"`
+	ResourceType string           `json:"resourceType"`
+	ResourceID   *uuid.UUID       `json:"resourceId,omitempty"`
+	Message      string           `json:"message"`
+	ReadAt       *time.Time       `json:"readAt,omitempty"`
+	CreatedAt    time.Time        `json:"createdAt"`
+}
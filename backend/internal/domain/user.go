@@ -60,7 +60,7 @@ type UserRepository interface {
 	Update(user *User) error
 	Delete(id uuid.UUID) error
 	UpdateStorageUsed(userID uuid.UUID, storageUsed int64) error
-	List(limit, offset int) ([]*User, error)
+	List(search string, limit, offset int) ([]*User, int, error)
 	GetStorageStats(userID uuid.UUID) (*StorageStats, error)
 }
 
@@ -74,4 +74,20 @@ type StorageStats struct {
 	TotalUsedFormatted  string    `json:"total_used_formatted"`
 	OriginalSizeFormatted string  `json:"original_size_formatted"`
 	SavingsFormatted    string    `json:"savings_formatted"`
-}
\ No newline at end of file
+}
+
+// SystemStats is the platform-wide usage shown to platform admins. StorageUsed counts every file
+// at its full size, as quotas do; StoredSize counts each distinct content once, and the difference
+// is what deduplication saves.
+type SystemStats struct {
+	TotalUsers                     int     `json:"total_users"`
+	TotalFiles                     int     `json:"total_files"`
+	StorageUsed                    int64   `json:"storage_used"`
+	StoredSize                     int64   `json:"stored_size"`
+	DeduplicationSavings           int64   `json:"deduplication_savings"`
+	DeduplicationSavingsPercentage float64 `json:"deduplication_savings_percentage"`
+	UploadsLast24Hours             int     `json:"uploads_last_24_hours"`
+	UploadsLast7Days               int     `json:"uploads_last_7_days"`
+	UploadsLast30Days              int     `json:"uploads_last_30_days"`
+	TopStorageConsumers            []*User `json:"top_storage_consumers"`
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookDeliveryStatus tracks a queued webhook event through delivery
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryDead      WebhookDeliveryStatus = "DEAD" // Out of attempts; kept for inspection
+)
+
+// Webhook is an enterprise endpoint sent its members' audit events as signed JSON POSTs
+type Webhook struct {
+	ID           uuid.UUID     `json:"id"`
+	EnterpriseID uuid.UUID     `json:"enterpriseId"`
+	URL          string        `json:"url"`
+	Secret       string        `json:"secret,omitempty"` // HMAC key; only returned when the webhook is created
+	Events       []AuditAction `json:"events"`           // Empty delivers every action
+	CreatedBy    *uuid.UUID    `json:"createdBy,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+// WebhookEvent is the JSON body delivered to a webhook endpoint
+type WebhookEvent struct {
+	ID           uuid.UUID              `json:"id"`
+	Type         AuditAction            `json:"type"`
+	UserID       uuid.UUID              `json:"userId"`
+	Status       AuditStatus            `json:"status"`
+	ResourceType string                 `json:"resourceType"`
+	ResourceID   *uuid.UUID             `json:"resourceId,omitempty"`
+	ResourceName string                 `json:"resourceName"`
+	Description  string                 `json:"description"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	OccurredAt   time.Time              `json:"occurredAt"`
+}
@@ -0,0 +1,87 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"lokr-backend/internal/services"
+	"lokr-backend/pkg/auth"
+)
+
+// Error codes set in extensions.code so clients can branch on the kind of failure
+const (
+	CodeUnauthenticated = "UNAUTHENTICATED"
+	CodeForbidden       = "FORBIDDEN"
+	CodeNotFound        = "NOT_FOUND"
+	CodeValidation      = "VALIDATION"
+	CodeQuotaExceeded   = "QUOTA_EXCEEDED"
+	CodeFileLocked      = "FILE_LOCKED"
+	CodeInternal        = "INTERNAL"
+)
+
+var (
+	// errUnauthorized is returned by resolvers called without an authenticated user
+	errUnauthorized = errors.New("unauthorized")
+	// errUserNotFound is returned when a user looked up by ID doesn't exist
+	errUserNotFound = errors.New("user not found")
+)
+
+// inputError is a resolver error caused by bad arguments rather than by the server
+type inputError struct {
+	err error
+}
+
+func (e *inputError) Error() string { return e.err.Error() }
+
+func (e *inputError) Unwrap() error { return e.err }
+
+// invalidInput formats an error reporting bad arguments, coded VALIDATION
+func invalidInput(format string, args ...interface{}) error {
+	return &inputError{err: fmt.Errorf(format, args...)}
+}
+
+// errorCode maps a resolver error to its extensions.code by the sentinel errors it wraps
+func errorCode(err error) string {
+	var input *inputError
+	var unsupported *services.UnsupportedMediaTypeError
+	var blocked *services.BlockedUploadError
+	var locked *services.FileLockedError
+	switch {
+	case errors.Is(err, errUnauthorized), errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrExpiredToken):
+		return CodeUnauthenticated
+	case errors.Is(err, services.ErrPermissionDenied), errors.Is(err, services.ErrLastOwner), errors.Is(err, services.ErrNoEnterprise):
+		return CodeForbidden
+	case errors.Is(err, services.ErrFileNotFound), errors.Is(err, services.ErrFolderNotFound), errors.Is(err, services.ErrContentNotFound),
+		errors.Is(err, services.ErrWebhookNotFound), errors.Is(err, errUserNotFound), errors.Is(err, pgx.ErrNoRows):
+		return CodeNotFound
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return CodeQuotaExceeded
+	case errors.As(err, &locked):
+		return CodeFileLocked
+	case errors.As(err, &input), errors.As(err, &unsupported), errors.As(err, &blocked), errors.Is(err, services.ErrInvalidFileName),
+		errors.Is(err, services.ErrInvalidVisibility), errors.Is(err, services.ErrFolderCycle),
+		errors.Is(err, services.ErrInvalidWebhookURL), errors.Is(err, services.ErrWebhookAddressBlocked),
+		errors.Is(err, services.ErrInvalidWebhookEvent):
+		return CodeValidation
+	default:
+		return CodeInternal
+	}
+}
+
+// toGraphQLError wraps a resolver error for the response, coded by errorCode
+func toGraphQLError(err error) GraphQLError {
+	return GraphQLError{
+		Message:    err.Error(),
+		Extensions: map[string]interface{}{"code": errorCode(err)},
+	}
+}
+
+// validationError reports a malformed request, such as a missing variable
+func validationError(message string) GraphQLError {
+	return GraphQLError{
+		Message:    message,
+		Extensions: map[string]interface{}{"code": CodeValidation},
+	}
+}
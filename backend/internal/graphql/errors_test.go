@@ -0,0 +1,51 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/services"
+	"lokr-backend/pkg/auth"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unauthenticated", errUnauthorized, CodeUnauthenticated},
+		{"expired token", fmt.Errorf("failed to refresh: %w", auth.ErrExpiredToken), CodeUnauthenticated},
+		{"share too weak", fmt.Errorf("failed to delete file: %w", services.ErrPermissionDenied), CodeForbidden},
+		{"last owner", fmt.Errorf("failed to remove user from enterprise: %w", services.ErrLastOwner), CodeForbidden},
+		{"no enterprise", fmt.Errorf("failed to search users: %w", services.ErrNoEnterprise), CodeForbidden},
+		{"no such file", fmt.Errorf("failed to get file: %w", services.ErrFileNotFound), CodeNotFound},
+		{"no such folder", fmt.Errorf("failed to share folder: %w", services.ErrFolderNotFound), CodeNotFound},
+		{"no such row", fmt.Errorf("file not found or access denied: %w", pgx.ErrNoRows), CodeNotFound},
+		{"no such user", errUserNotFound, CodeNotFound},
+		{"enterprise quota", fmt.Errorf("failed to upload file: enterprise %w", services.ErrQuotaExceeded), CodeQuotaExceeded},
+		{"locked file", fmt.Errorf("failed to rename file: %w", &services.FileLockedError{Lock: &domain.FileLock{HolderName: "Ada"}}), CodeFileLocked},
+		{"bad argument", invalidInput("invalid folder ID: %w", errors.New("invalid UUID length: 3")), CodeValidation},
+		{"bad file name", fmt.Errorf("failed to rename file: %w", services.ErrInvalidFileName), CodeValidation},
+		{"bad visibility", fmt.Errorf("failed to update file: %w: EVERYONE", services.ErrInvalidVisibility), CodeValidation},
+		{"folder cycle", fmt.Errorf("failed to move folder: %w", services.ErrFolderCycle), CodeValidation},
+		{"blocked type", fmt.Errorf("failed to upload file: %w", &services.UnsupportedMediaTypeError{MimeType: "application/zip"}), CodeValidation},
+		{"anything else", errors.New("connection reset"), CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graphQLError := toGraphQLError(tt.err)
+			if graphQLError.Message != tt.err.Error() {
+				t.Errorf("Expected message %q, got %q", tt.err.Error(), graphQLError.Message)
+			}
+			if code := graphQLError.Extensions["code"]; code != tt.want {
+				t.Errorf("Expected code %s, got %v", tt.want, code)
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package graphql
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 
 	"lokr-backend/internal/domain"
 	"lokr-backend/pkg/auth"
@@ -39,11 +44,38 @@ func NewHandler(resolver *Resolver, jwtManager *auth.JWTManager) *Handler {
 	}
 }
 
+// maxBatchSize bounds the number of operations in one batched request
+const maxBatchSize = 20
+
+// ServeHTTP executes a single operation, or a batch of them sent as a JSON array. A batch runs its
+// operations in order and answers with an array of their responses in the same order.
 func (h *Handler) ServeHTTP(c *gin.Context) {
-	var req GraphQLRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GraphQLResponse{
+			Errors: []GraphQLError{validationError("Invalid request body")},
+		})
+		return
+	}
+
+	var requests []GraphQLRequest
+	batch := isBatch(body)
+	if batch {
+		err = json.Unmarshal(body, &requests)
+	} else {
+		var req GraphQLRequest
+		err = json.Unmarshal(body, &req)
+		requests = []GraphQLRequest{req}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GraphQLResponse{
+			Errors: []GraphQLError{validationError("Invalid request body")},
+		})
+		return
+	}
+	if batch && (len(requests) == 0 || len(requests) > maxBatchSize) {
 		c.JSON(http.StatusBadRequest, GraphQLResponse{
-			Errors: []GraphQLError{{Message: "Invalid request body"}},
+			Errors: []GraphQLError{validationError(fmt.Sprintf("A batch must hold between 1 and %d operations", maxBatchSize))},
 		})
 		return
 	}
@@ -60,10 +92,30 @@ func (h *Handler) ServeHTTP(c *gin.Context) {
 		}
 	}
 
-	// Process the GraphQL query
-	response := h.processQuery(ctx, req.Query, req.Variables)
+	// Carry client details so resolvers can record them in audit logs
+	ctx = context.WithValue(ctx, "ipAddress", c.ClientIP())
+	ctx = context.WithValue(ctx, "userAgent", c.GetHeader("User-Agent"))
+
+	// One user loader per request so nested user fields are fetched in a single batch
+	ctx = context.WithValue(ctx, "userLoader", h.resolver.newUserLoader())
+
+	// Process the GraphQL queries
+	responses := make([]GraphQLResponse, len(requests))
+	for i, req := range requests {
+		responses[i] = h.processQuery(ctx, req.Query, req.Variables)
+	}
+
+	if batch {
+		c.JSON(http.StatusOK, responses)
+		return
+	}
+	c.JSON(http.StatusOK, responses[0])
+}
 
-	c.JSON(http.StatusOK, response)
+// isBatch reports whether a request body is a JSON array of operations
+func isBatch(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
 }
 
 func (h *Handler) processQuery(ctx context.Context, query string, variables map[string]interface{}) GraphQLResponse {
@@ -96,7 +148,7 @@ func (h *Handler) processQuery(ctx context.Context, query string, variables map[
 	}
 
 	return GraphQLResponse{
-		Errors: []GraphQLError{{Message: "Unsupported operation"}},
+		Errors: []GraphQLError{validationError("Unsupported operation")},
 	}
 }
 
@@ -106,13 +158,13 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		email, ok := variables["email"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Email is required"}},
+				Errors: []GraphQLError{validationError("Email is required")},
 			}
 		}
 		password, ok := variables["password"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Password is required"}},
+				Errors: []GraphQLError{validationError("Password is required")},
 			}
 		}
 
@@ -143,9 +195,9 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 						"storageQuota":    result.User.StorageQuota,
 						"emailVerified":   result.User.EmailVerified,
 						"lastLoginAt":     result.User.LastLoginAt,
-						"enterpriseId":    nil,
-						"enterpriseRole":  nil,
-						"enterprise":      nil,
+						"enterpriseId":    enterpriseIDValue(result.User),
+						"enterpriseRole":  result.User.EnterpriseRole,
+						"enterprise":      enterpriseToMap(result.User.Enterprise),
 						"createdAt":       result.User.CreatedAt,
 						"updatedAt":       result.User.UpdatedAt,
 					},
@@ -159,7 +211,7 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		input, ok := variables["input"].(map[string]interface{})
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Input is required"}},
+				Errors: []GraphQLError{validationError("Input is required")},
 			}
 		}
 
@@ -172,7 +224,7 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		result, err := h.resolver.Register(ctx, createUserInput)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -191,9 +243,9 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 						"storageQuota":    result.User.StorageQuota,
 						"emailVerified":   result.User.EmailVerified,
 						"lastLoginAt":     result.User.LastLoginAt,
-						"enterpriseId":    nil,
-						"enterpriseRole":  nil,
-						"enterprise":      nil,
+						"enterpriseId":    enterpriseIDValue(result.User),
+						"enterpriseRole":  result.User.EnterpriseRole,
+						"enterprise":      enterpriseToMap(result.User.Enterprise),
 						"createdAt":       result.User.CreatedAt,
 						"updatedAt":       result.User.UpdatedAt,
 					},
@@ -202,12 +254,316 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		}
 	}
 
+	// Create enterprise mutation
+	if strings.Contains(query, "createEnterprise(") {
+		input, ok := variables["input"].(map[string]interface{})
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Input is required")},
+			}
+		}
+
+		createInput := CreateEnterpriseInput{}
+		if name, ok := input["name"].(string); ok {
+			createInput.Name = name
+		}
+		if slug, ok := input["slug"].(string); ok {
+			createInput.Slug = slug
+		}
+		if domainName, ok := input["domain"].(string); ok {
+			createInput.Domain = &domainName
+		}
+		if billingEmail, ok := input["billingEmail"].(string); ok {
+			createInput.BillingEmail = &billingEmail
+		}
+		if settings, ok := input["settings"].(map[string]interface{}); ok {
+			createInput.Settings = settings
+		}
+
+		result, err := h.resolver.CreateEnterprise(ctx, createInput)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"createEnterprise": enterpriseToMap(result),
+			},
+		}
+	}
+
+	// Update enterprise mutation
+	if strings.Contains(query, "updateEnterprise(") {
+		id, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Enterprise ID is required")},
+			}
+		}
+
+		input, ok := variables["input"].(map[string]interface{})
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Input is required")},
+			}
+		}
+
+		updateInput := UpdateEnterpriseInput{}
+		if name, ok := input["name"].(string); ok {
+			updateInput.Name = &name
+		}
+		if domainName, ok := input["domain"].(string); ok {
+			updateInput.Domain = &domainName
+		}
+		if billingEmail, ok := input["billingEmail"].(string); ok {
+			updateInput.BillingEmail = &billingEmail
+		}
+		if settings, ok := input["settings"].(map[string]interface{}); ok {
+			updateInput.Settings = settings
+		}
+		if maxUsers, ok := input["maxUsers"].(float64); ok {
+			maxUsersInt := int(maxUsers)
+			updateInput.MaxUsers = &maxUsersInt
+		}
+		if storageQuota, ok := input["storageQuota"].(float64); ok {
+			storageQuotaInt := int64(storageQuota)
+			updateInput.StorageQuota = &storageQuotaInt
+		}
+
+		result, err := h.resolver.UpdateEnterprise(ctx, id, updateInput)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"updateEnterprise": enterpriseToMap(result),
+			},
+		}
+	}
+
+	// Invite user mutation
+	if strings.Contains(query, "inviteUser(") {
+		enterpriseID, ok := variables["enterpriseId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Enterprise ID is required")},
+			}
+		}
+
+		input, ok := variables["input"].(map[string]interface{})
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Input is required")},
+			}
+		}
+
+		inviteInput := InviteUserInput{}
+		if email, ok := input["email"].(string); ok {
+			inviteInput.Email = email
+		}
+		if role, ok := input["role"].(string); ok {
+			inviteInput.Role = role
+		}
+
+		result, err := h.resolver.InviteUser(ctx, enterpriseID, inviteInput)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"inviteUser": map[string]interface{}{
+					"id":           result.ID.String(),
+					"enterpriseId": result.EnterpriseID.String(),
+					"email":        result.Email,
+					"role":         result.Role,
+					"token":        result.Token,
+					"expiresAt":    result.ExpiresAt,
+					"acceptedAt":   result.AcceptedAt,
+					"createdAt":    result.CreatedAt,
+				},
+			},
+		}
+	}
+
+	// Accept invitation mutation
+	if strings.Contains(query, "acceptInvitation(") {
+		token, ok := variables["token"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Token is required")},
+			}
+		}
+
+		var moveFiles *bool
+		if move, ok := variables["moveFiles"].(bool); ok {
+			moveFiles = &move
+		}
+
+		result, err := h.resolver.AcceptInvitation(ctx, token, moveFiles)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"acceptInvitation": result,
+			},
+		}
+	}
+
+	// Remove user from enterprise mutation
+	if strings.Contains(query, "removeUserFromEnterprise(") {
+		enterpriseID, ok := variables["enterpriseId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Enterprise ID is required")},
+			}
+		}
+		userID, ok := variables["userId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("User ID is required")},
+			}
+		}
+		var filePolicy *string
+		if p, ok := variables["filePolicy"].(string); ok {
+			filePolicy = &p
+		}
+
+		result, err := h.resolver.RemoveUserFromEnterprise(ctx, enterpriseID, userID, filePolicy)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"removeUserFromEnterprise": result,
+			},
+		}
+	}
+
+	// Create webhook mutation
+	if strings.Contains(query, "createWebhook(") {
+		url, ok := variables["url"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("URL is required")},
+			}
+		}
+
+		result, err := h.resolver.CreateWebhook(ctx, url, stringList(variables["events"]))
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"createWebhook": webhookToMap(result),
+			},
+		}
+	}
+
+	// Delete webhook mutation
+	if strings.Contains(query, "deleteWebhook(") {
+		id, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Webhook ID is required")},
+			}
+		}
+
+		result, err := h.resolver.DeleteWebhook(ctx, id)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"deleteWebhook": result,
+			},
+		}
+	}
+
+	// Set user quota mutation
+	if strings.Contains(query, "setUserQuota(") {
+		userID, ok := variables["userId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("User ID is required")},
+			}
+		}
+
+		quotaBytes, ok := variables["quotaBytes"].(float64)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("quotaBytes is required")},
+			}
+		}
+
+		user, err := h.resolver.SetUserQuota(ctx, userID, int64(quotaBytes))
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"setUserQuota": map[string]interface{}{
+					"id":           user.ID.String(),
+					"email":        user.Email,
+					"name":         user.Name,
+					"storageUsed":  user.StorageUsed,
+					"storageQuota": user.StorageQuota,
+					"updatedAt":    user.UpdatedAt,
+				},
+			},
+		}
+	}
+
+	// Recompute storage usage mutation; userId is optional
+	if strings.Contains(query, "recomputeStorageUsed") {
+		var userID *string
+		if id, ok := variables["userId"].(string); ok {
+			userID = &id
+		}
+
+		corrected, err := h.resolver.RecomputeStorageUsed(ctx, userID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"recomputeStorageUsed": corrected,
+			},
+		}
+	}
+
 	// Upload file mutation
 	if strings.Contains(query, "uploadFile(") {
 		input, ok := variables["input"].(map[string]interface{})
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Input is required"}},
+				Errors: []GraphQLError{validationError("Input is required")},
 			}
 		}
 
@@ -231,7 +587,7 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		result, err := h.resolver.UploadFile(ctx, nil, fileUploadInput)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -264,14 +620,14 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		fileID, ok := variables["fileId"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "File ID is required"}},
+				Errors: []GraphQLError{validationError("File ID is required")},
 			}
 		}
 
 		result, err := h.resolver.CreatePublicShare(ctx, fileID)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -280,6 +636,8 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 				"createPublicShare": map[string]interface{}{
 					"shareToken": result.ShareToken,
 					"shareUrl":   result.ShareURL,
+					"shortCode":  optionalString(result.ShortCode),
+					"shortUrl":   optionalString(result.ShortURL),
 				},
 			},
 		}
@@ -289,14 +647,14 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		fileID, ok := variables["fileId"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "File ID is required"}},
+				Errors: []GraphQLError{validationError("File ID is required")},
 			}
 		}
 
 		result, err := h.resolver.RemovePublicShare(ctx, fileID)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -311,7 +669,7 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		input, ok := variables["input"].(map[string]interface{})
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Input is required"}},
+				Errors: []GraphQLError{validationError("Input is required")},
 			}
 		}
 
@@ -324,10 +682,20 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		result, err := h.resolver.ShareFileWithUser(ctx, shareInput)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
+		loader := h.resolver.userLoader(ctx)
+		loader.Queue(result.SharedByUserID, result.SharedWithUserID)
+		var sharedBy, sharedWith interface{}
+		if user, err := loader.Load(result.SharedByUserID); err == nil && user != nil {
+			sharedBy = userSummaryToMap(user)
+		}
+		if user, err := loader.Load(result.SharedWithUserID); err == nil && user != nil {
+			sharedWith = userSummaryToMap(user)
+		}
+
 		return GraphQLResponse{
 			Data: map[string]interface{}{
 				"shareFileWithUser": map[string]interface{}{
@@ -341,97 +709,215 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 					"accessCount":       result.AccessCount,
 					"createdAt":         result.CreatedAt,
 					"file":              nil,
-					"sharedBy":          nil,
-					"sharedWith":        nil,
+					"sharedBy":          sharedBy,
+					"sharedWith":        sharedWith,
 				},
 			},
 		}
 	}
 
-	if strings.Contains(query, "removeFileShare(") {
-		fileID, ok := variables["fileId"].(string)
+	if strings.Contains(query, "shareFileWithUsers(") {
+		input, ok := variables["input"].(map[string]interface{})
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "File ID is required"}},
+				Errors: []GraphQLError{validationError("Input is required")},
 			}
 		}
-		sharedWithUserID, ok := variables["sharedWithUserId"].(string)
-		if !ok {
-			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Shared with user ID is required"}},
+		fileID, _ := input["fileId"].(string)
+		permission, _ := input["permissionType"].(string)
+		rawUserIDs, _ := input["userIds"].([]interface{})
+		userIDs := make([]string, 0, len(rawUserIDs))
+		for _, raw := range rawUserIDs {
+			id, _ := raw.(string)
+			userIDs = append(userIDs, id)
+		}
+
+		shareInput := ShareFileWithUsersInput{
+			FileID:         fileID,
+			UserIDs:        userIDs,
+			PermissionType: permission,
+		}
+		if raw, ok := input["expiresAt"].(string); ok {
+			expiresAt, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return GraphQLResponse{
+					Errors: []GraphQLError{validationError("expiresAt must be an RFC 3339 time")},
+				}
 			}
+			shareInput.ExpiresAt = &expiresAt
 		}
 
-		result, err := h.resolver.RemoveFileShare(ctx, fileID, sharedWithUserID)
+		result, err := h.resolver.ShareFileWithUsers(ctx, shareInput)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		loader := h.resolver.userLoader(ctx)
+		for _, share := range result {
+			if share.Share != nil {
+				loader.Queue(share.Share.SharedWithUserID)
+			}
+		}
+		results := make([]map[string]interface{}, len(result))
+		for i, share := range result {
+			var fileShare, errorMessage interface{}
+			if share.Share != nil {
+				var sharedWith interface{}
+				if user, err := loader.Load(share.Share.SharedWithUserID); err == nil && user != nil {
+					sharedWith = userSummaryToMap(user)
+				}
+				fileShare = map[string]interface{}{
+					"id":               share.Share.ID.String(),
+					"fileId":           share.Share.FileID.String(),
+					"sharedByUserId":   share.Share.SharedByUserID.String(),
+					"sharedWithUserId": share.Share.SharedWithUserID.String(),
+					"permissionType":   share.Share.PermissionType,
+					"expiresAt":        share.Share.ExpiresAt,
+					"lastAccessedAt":   share.Share.LastAccessedAt,
+					"accessCount":      share.Share.AccessCount,
+					"createdAt":        share.Share.CreatedAt,
+					"file":             nil,
+					"sharedBy":         nil,
+					"sharedWith":       sharedWith,
+				}
+			} else {
+				errorMessage = share.Error
+			}
+			results[i] = map[string]interface{}{
+				"userId":  share.UserID,
+				"success": share.Share != nil,
+				"share":   fileShare,
+				"error":   errorMessage,
 			}
 		}
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"removeFileShare": result,
+				"shareFileWithUsers": results,
 			},
 		}
 	}
 
-	// Folder mutations
-	if strings.Contains(query, "createFolder(") {
-		input, ok := variables["input"].(map[string]interface{})
+	if strings.Contains(query, "shareFolderWithUser(") {
+		folderID, ok := variables["folderId"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Input is required"}},
+				Errors: []GraphQLError{validationError("Folder ID is required")},
 			}
 		}
-
-		createFolderInput := CreateFolderInput{
-			Name: input["name"].(string),
+		sharedWithUserID, ok := variables["userId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("User ID is required")},
+			}
 		}
-		if parentId, ok := input["parentId"].(string); ok && parentId != "" {
-			createFolderInput.ParentID = &parentId
+		permission, ok := variables["permission"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Permission is required")},
+			}
 		}
 
-		result, err := h.resolver.CreateFolder(ctx, createFolderInput)
+		result, err := h.resolver.ShareFolderWithUser(ctx, folderID, sharedWithUserID, permission)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"createFolder": map[string]interface{}{
-					"id":        result.ID.String(),
-					"userId":    result.UserID.String(),
-					"name":      result.Name,
-					"parentId":  nil,
-					"createdAt": result.CreatedAt,
-					"updatedAt": result.UpdatedAt,
-					"parent":    nil,
-					"children":  []interface{}{},
-					"files":     []interface{}{},
-				},
+				"shareFolderWithUser": folderSharesToMaps(h.resolver.userLoader(ctx), []*domain.FolderShare{result})[0],
 			},
 		}
 	}
 
-	if strings.Contains(query, "updateFolder(") {
-		folderID, ok := variables["id"].(string)
+	if strings.Contains(query, "removeFileShare(") {
+		fileID, ok := variables["fileId"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Folder ID is required"}},
+				Errors: []GraphQLError{validationError("File ID is required")},
 			}
 		}
-
-		input, ok := variables["input"].(map[string]interface{})
+		sharedWithUserID, ok := variables["sharedWithUserId"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Input is required"}},
+				Errors: []GraphQLError{validationError("Shared with user ID is required")},
 			}
 		}
 
-		updateInput := UpdateFolderInput{}
+		result, err := h.resolver.RemoveFileShare(ctx, fileID, sharedWithUserID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"removeFileShare": result,
+			},
+		}
+	}
+
+	// Folder mutations
+	if strings.Contains(query, "createFolder(") {
+		input, ok := variables["input"].(map[string]interface{})
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Input is required")},
+			}
+		}
+
+		createFolderInput := CreateFolderInput{
+			Name: input["name"].(string),
+		}
+		if parentId, ok := input["parentId"].(string); ok && parentId != "" {
+			createFolderInput.ParentID = &parentId
+		}
+
+		result, err := h.resolver.CreateFolder(ctx, createFolderInput)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"createFolder": map[string]interface{}{
+					"id":        result.ID.String(),
+					"userId":    result.UserID.String(),
+					"name":      result.Name,
+					"parentId":  nil,
+					"createdAt": result.CreatedAt,
+					"updatedAt": result.UpdatedAt,
+					"parent":    nil,
+					"children":  []interface{}{},
+					"files":     []interface{}{},
+				},
+			},
+		}
+	}
+
+	if strings.Contains(query, "updateFolder(") {
+		folderID, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Folder ID is required")},
+			}
+		}
+
+		input, ok := variables["input"].(map[string]interface{})
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Input is required")},
+			}
+		}
+
+		updateInput := UpdateFolderInput{}
 		if name, ok := input["name"].(string); ok {
 			updateInput.Name = &name
 		}
@@ -442,7 +928,7 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		result, err := h.resolver.UpdateFolder(ctx, folderID, updateInput)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -467,7 +953,7 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		folderID, ok := variables["id"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Folder ID is required"}},
+				Errors: []GraphQLError{validationError("Folder ID is required")},
 			}
 		}
 
@@ -479,7 +965,7 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		result, err := h.resolver.DeleteFolder(ctx, folderID, force)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -494,7 +980,7 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		folderID, ok := variables["id"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Folder ID is required"}},
+				Errors: []GraphQLError{validationError("Folder ID is required")},
 			}
 		}
 
@@ -506,7 +992,7 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		result, err := h.resolver.MoveFolder(ctx, folderID, newParentID)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -531,7 +1017,7 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		fileID, ok := variables["id"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "File ID is required"}},
+				Errors: []GraphQLError{validationError("File ID is required")},
 			}
 		}
 
@@ -543,207 +1029,1333 @@ func (h *Handler) processMutation(ctx context.Context, query string, variables m
 		result, err := h.resolver.MoveFile(ctx, fileID, folderID)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"moveFile": map[string]interface{}{
+					"id":           result.ID.String(),
+					"userId":       result.UserID.String(),
+					"folderId":     nil,
+					"filename":     result.Filename,
+					"originalName": result.OriginalName,
+					"mimeType":     result.MimeType,
+					"fileSize":     result.FileSize,
+					"contentHash":  result.ContentHash,
+					"description":  result.Description,
+					"tags":         result.Tags,
+					"visibility":   result.Visibility,
+					"shareToken":   result.ShareToken,
+					"downloadCount": result.DownloadCount,
+					"uploadDate":   result.UploadDate,
+					"updatedAt":    result.UpdatedAt,
+					"user":         nil,
+					"folder":       nil,
+				},
+			},
+		}
+	}
+
+	if strings.Contains(query, "copyFile(") {
+		fileID, ok := variables["fileId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		var folderID *string
+		if destFolderID, ok := variables["destFolderId"].(string); ok {
+			folderID = &destFolderID
+		}
+
+		result, err := h.resolver.CopyFile(ctx, fileID, folderID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"copyFile": fileToMap(result),
+			},
+		}
+	}
+
+	if strings.Contains(query, "renameFile(") {
+		fileID, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+		name, _ := variables["name"].(string)
+
+		result, err := h.resolver.RenameFile(ctx, fileID, name)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"renameFile": fileToMap(result),
+			},
+		}
+	}
+
+	if strings.Contains(query, "updateFile(") {
+		fileID, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		input, ok := variables["input"].(map[string]interface{})
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Input is required")},
+			}
+		}
+
+		updateInput := UpdateFileInput{}
+		if filename, ok := input["filename"].(string); ok {
+			updateInput.Filename = &filename
+		}
+		if description, ok := input["description"].(string); ok {
+			updateInput.Description = &description
+		}
+		if rawTags, ok := input["tags"].([]interface{}); ok {
+			tags := make([]string, 0, len(rawTags))
+			for _, tag := range rawTags {
+				if str, ok := tag.(string); ok {
+					tags = append(tags, str)
+				}
+			}
+			updateInput.Tags = &tags
+		}
+		if visibility, ok := input["visibility"].(string); ok {
+			vis := domain.FileVisibility(visibility)
+			updateInput.Visibility = &vis
+		}
+		if folderId, ok := input["folderId"].(string); ok {
+			updateInput.FolderID = &folderId
+		}
+
+		result, err := h.resolver.UpdateFile(ctx, fileID, updateInput)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"updateFile": fileToMap(result),
+			},
+		}
+	}
+
+	if strings.Contains(query, "addTags(") || strings.Contains(query, "removeTags(") {
+		fileID, ok := variables["fileId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		tags := stringList(variables["tags"])
+
+		field := "addTags"
+		var result *domain.File
+		var err error
+		if strings.Contains(query, "removeTags(") {
+			field = "removeTags"
+			result, err = h.resolver.RemoveTags(ctx, fileID, tags)
+		} else {
+			result, err = h.resolver.AddTags(ctx, fileID, tags)
+		}
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				field: fileToMap(result),
+			},
+		}
+	}
+
+	if strings.Contains(query, "renameTag(") {
+		oldTag, _ := variables["oldTag"].(string)
+		newTag, _ := variables["newTag"].(string)
+
+		result, err := h.resolver.RenameTag(ctx, oldTag, newTag)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"renameTag": result,
+			},
+		}
+	}
+
+	if strings.Contains(query, "deleteFilesByTag(") {
+		tag, _ := variables["tag"].(string)
+		confirm, _ := variables["confirm"].(bool)
+
+		result, err := h.resolver.DeleteFilesByTag(ctx, tag, confirm)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"deleteFilesByTag": result,
+			},
+		}
+	}
+
+	if strings.Contains(query, "markNotificationRead(") {
+		notificationID, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Notification ID is required")},
+			}
+		}
+
+		result, err := h.resolver.MarkNotificationRead(ctx, notificationID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"markNotificationRead": notificationToMap(result),
+			},
+		}
+	}
+
+	// Delete file mutation
+	if strings.Contains(query, "deleteFile(") {
+		fileID, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		result, err := h.resolver.DeleteFile(ctx, fileID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"deleteFile": result,
+			},
+		}
+	}
+
+	// Favorite mutations (check unstarFile first since it contains "starFile(")
+	if strings.Contains(query, "unlockFile(") {
+		fileID, ok := variables["fileId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		result, err := h.resolver.UnlockFile(ctx, fileID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"unlockFile": result,
+			},
+		}
+	}
+
+	if strings.Contains(query, "lockFile(") {
+		fileID, ok := variables["fileId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		result, err := h.resolver.LockFile(ctx, fileID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"lockFile": fileLockToMap(result),
+			},
+		}
+	}
+
+	if strings.Contains(query, "unstarFile(") {
+		fileID, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		result, err := h.resolver.UnstarFile(ctx, fileID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"unstarFile": result,
+			},
+		}
+	}
+
+	if strings.Contains(query, "starFile(") {
+		fileID, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		result, err := h.resolver.StarFile(ctx, fileID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"starFile": fileToMap(result),
+			},
+		}
+	}
+
+	// File reference mutations
+	if strings.Contains(query, "createFileReference(") {
+		input, ok := variables["input"].(map[string]interface{})
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Input is required")},
+			}
+		}
+
+		createFileRefInput := CreateFileReferenceInput{
+			FileID:   input["fileId"].(string),
+			FolderID: input["folderId"].(string),
+		}
+		if name, ok := input["name"].(string); ok && name != "" {
+			createFileRefInput.Name = &name
+		}
+
+		result, err := h.resolver.CreateFileReference(ctx, createFileRefInput)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"createFileReference": map[string]interface{}{
+					"id":        result.ID.String(),
+					"folderId":  result.FolderID.String(),
+					"fileId":    result.FileID.String(),
+					"userId":    result.UserID.String(),
+					"name":      result.Name,
+					"createdAt": result.CreatedAt,
+					"file":      nil,
+					"folder":    nil,
+					"user":      nil,
+				},
+			},
+		}
+	}
+
+	if strings.Contains(query, "deleteFileReference(") {
+		referenceID, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Reference ID is required")},
+			}
+		}
+
+		result, err := h.resolver.DeleteFileReference(ctx, referenceID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"deleteFileReference": result,
+			},
+		}
+	}
+
+	return GraphQLResponse{
+		Errors: []GraphQLError{validationError("Unknown mutation")},
+	}
+}
+
+func (h *Handler) processQueryOperation(ctx context.Context, query string, variables map[string]interface{}) GraphQLResponse {
+	h.resolver.requestLogger(ctx).Debug("Processing GraphQL query", zap.String("query", query))
+
+	// sharedFoldersWithMe query (check before "me", which its folder fields contain)
+	if strings.Contains(query, "sharedFoldersWithMe") {
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+
+		result, err := h.resolver.SharedFoldersWithMe(ctx, limit, offset)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"sharedFoldersWithMe": folderSharesToMaps(h.resolver.userLoader(ctx), result),
+			},
+		}
+	}
+
+	// myFolders query (check before "me" since it contains "me")
+	if strings.Contains(query, "myFolders") {
+		var limit, offset *int
+		if variables != nil {
+			if l, ok := variables["limit"].(float64); ok {
+				limitInt := int(l)
+				limit = &limitInt
+			}
+			if o, ok := variables["offset"].(float64); ok {
+				offsetInt := int(o)
+				offset = &offsetInt
+			}
+		}
+
+		result, err := h.resolver.GetMyFolders(ctx, limit, offset)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		folders := make([]map[string]interface{}, len(result.Items))
+		for i, folder := range result.Items {
+			folders[i] = map[string]interface{}{
+				"id":        folder.ID.String(),
+				"userId":    folder.UserID.String(),
+				"name":      folder.Name,
+				"parentId":  nil,
+				"createdAt": folder.CreatedAt,
+				"updatedAt": folder.UpdatedAt,
+				"parent":    nil,
+				"children":  []interface{}{},
+				"files":     []interface{}{},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"myFolders": map[string]interface{}{
+					"items":       folders,
+					"totalCount":  result.TotalCount,
+					"hasNextPage": result.HasNextPage,
+				},
+			},
+		}
+	}
+
+	// filesInFolder query (check before "me", which its user fields contain)
+	if strings.Contains(query, "filesInFolder") {
+		var folderID, sortBy, sortOrder *string
+		if f, ok := variables["folderId"].(string); ok {
+			folderID = &f
+		}
+		if s, ok := variables["sortBy"].(string); ok {
+			sortBy = &s
+		}
+		if o, ok := variables["sortOrder"].(string); ok {
+			sortOrder = &o
+		}
+
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+
+		result, err := h.resolver.FilesInFolder(ctx, folderID, limit, offset, sortBy, sortOrder)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		fileData := filesToMaps(h.resolver.userLoader(ctx), result.Items)
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"filesInFolder": map[string]interface{}{
+					"items":       fileData,
+					"totalCount":  result.TotalCount,
+					"hasNextPage": result.HasNextPage,
+				},
+			},
+		}
+	}
+
+	// myFiles query (check before "me" since "myFiles" contains "me")
+	if strings.Contains(query, "myFiles") {
+		var limit, offset *int
+		if variables != nil {
+			if l, ok := variables["limit"].(float64); ok {
+				limitInt := int(l)
+				limit = &limitInt
+			}
+			if o, ok := variables["offset"].(float64); ok {
+				offsetInt := int(o)
+				offset = &offsetInt
+			}
+		}
+
+		var after *string
+		if a, ok := variables["after"].(string); ok {
+			after = &a
+		}
+
+		result, err := h.resolver.GetMyFiles(ctx, limit, offset, after)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		fileData := filesToMaps(h.resolver.userLoader(ctx), result.Items)
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"myFiles": map[string]interface{}{
+					"items":       fileData,
+					"totalCount":  result.TotalCount,
+					"hasNextPage": result.HasNextPage,
+					"pageInfo":    pageInfoToMap(result.PageInfo),
+				},
+			},
+		}
+	}
+
+	// recentFiles query
+	if strings.Contains(query, "recentFiles") {
+		var limit *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+
+		result, err := h.resolver.RecentFiles(ctx, limit)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"recentFiles": filesToMaps(h.resolver.userLoader(ctx), result),
+			},
+		}
+	}
+
+	// popularFiles query
+	if strings.Contains(query, "popularFiles") {
+		var limit *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+
+		result, err := h.resolver.PopularFiles(ctx, limit)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"popularFiles": filesToMaps(h.resolver.userLoader(ctx), result),
+			},
+		}
+	}
+
+	// myFavorites query
+	if strings.Contains(query, "myFavorites") {
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+
+		result, err := h.resolver.MyFavorites(ctx, limit, offset)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"myFavorites": map[string]interface{}{
+					"items":       filesToMaps(h.resolver.userLoader(ctx), result.Items),
+					"totalCount":  result.TotalCount,
+					"hasNextPage": result.HasNextPage,
+				},
+			},
+		}
+	}
+
+	// enterpriseFiles query
+	if strings.Contains(query, "enterpriseFiles") {
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+		var search *string
+		if q, ok := variables["search"].(string); ok {
+			search = &q
+		}
+
+		result, err := h.resolver.EnterpriseFiles(ctx, search, limit, offset)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"enterpriseFiles": map[string]interface{}{
+					"items":       filesToMaps(h.resolver.userLoader(ctx), result.Items),
+					"totalCount":  result.TotalCount,
+					"hasNextPage": result.HasNextPage,
+				},
+			},
+		}
+	}
+
+	// sharedByMe query
+	if strings.Contains(query, "sharedByMe") {
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+
+		result, err := h.resolver.SharedByMe(ctx, limit, offset)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		loader := h.resolver.userLoader(ctx)
+		sharedFiles := make([]map[string]interface{}, len(result))
+		for i, sharedFile := range result {
+			shares := make([]map[string]interface{}, len(sharedFile.Shares))
+			for j, share := range sharedFile.Shares {
+				shares[j] = map[string]interface{}{
+					"id":               share.ID.String(),
+					"fileId":           share.FileID.String(),
+					"sharedByUserId":   share.SharedByUserID.String(),
+					"sharedWithUserId": share.SharedWithUserID.String(),
+					"permissionType":   share.PermissionType,
+					"expiresAt":        share.ExpiresAt,
+					"lastAccessedAt":   share.LastAccessedAt,
+					"accessCount":      share.AccessCount,
+					"createdAt":        share.CreatedAt,
+					"sharedWith":       userSummaryToMap(share.SharedWith),
+				}
+			}
+			sharedFiles[i] = map[string]interface{}{
+				"file":   filesToMaps(loader, []*domain.File{sharedFile.File})[0],
+				"shares": shares,
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"sharedByMe": sharedFiles,
+			},
+		}
+	}
+
+	// enterpriseAuditLogs query (check before "auditLogs", which it contains)
+	if strings.Contains(query, "enterpriseAuditLogs") {
+		var limit, offset *int
+		var enterpriseID, action, status, from, to *string
+
+		if variables != nil {
+			if l, ok := variables["limit"].(float64); ok {
+				limitInt := int(l)
+				limit = &limitInt
+			}
+			if o, ok := variables["offset"].(float64); ok {
+				offsetInt := int(o)
+				offset = &offsetInt
+			}
+			if id, ok := variables["enterpriseId"].(string); ok && id != "" {
+				enterpriseID = &id
+			}
+			if a, ok := variables["action"].(string); ok && a != "" {
+				action = &a
+			}
+			if s, ok := variables["status"].(string); ok && s != "" {
+				status = &s
+			}
+			if f, ok := variables["from"].(string); ok && f != "" {
+				from = &f
+			}
+			if t, ok := variables["to"].(string); ok && t != "" {
+				to = &t
+			}
+		}
+
+		result, err := h.resolver.EnterpriseAuditLogs(ctx, enterpriseID, limit, offset, action, status, from, to)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"enterpriseAuditLogs": auditLogsToMaps(result),
+			},
+		}
+	}
+
+	// folderPath query (check before "me", which "name" contains)
+	if strings.Contains(query, "folderPath") {
+		folderID, ok := variables["folderId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("Folder ID is required")},
+			}
+		}
+
+		result, err := h.resolver.FolderPath(ctx, folderID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		path := make([]map[string]interface{}, len(result))
+		for i, folder := range result {
+			path[i] = map[string]interface{}{
+				"id":   folder.ID.String(),
+				"name": folder.Name,
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"folderPath": path,
+			},
+		}
+	}
+
+	// folderStats query (check before "folder(" and "me")
+	if strings.Contains(query, "folderStats") {
+		var folderID *string
+		if f, ok := variables["folderId"].(string); ok {
+			folderID = &f
+		}
+
+		result, err := h.resolver.FolderStats(ctx, folderID)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		var statsFolderID interface{}
+		if result.FolderID != nil {
+			statsFolderID = result.FolderID.String()
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"folderStats": map[string]interface{}{
+					"folderId":    statsFolderID,
+					"folderCount": result.FolderCount,
+					"fileCount":   result.FileCount,
+					"totalSize":   result.TotalSize,
+					"dedupedSize": result.DedupedSize,
+				},
+			},
+		}
+	}
+
+	// webhooks query
+	if strings.Contains(query, "webhooks") {
+		result, err := h.resolver.Webhooks(ctx)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		webhooks := make([]map[string]interface{}, len(result))
+		for i, webhook := range result {
+			webhooks[i] = webhookToMap(webhook)
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"webhooks": webhooks,
+			},
+		}
+	}
+
+	// fileDownloadStats query (check before "me", which "downloads" contains)
+	if strings.Contains(query, "fileDownloadStats") {
+		fileID, ok := variables["fileId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		var days *int
+		if d, ok := variables["days"].(float64); ok {
+			daysInt := int(d)
+			days = &daysInt
+		}
+
+		result, err := h.resolver.FileDownloadStats(ctx, fileID, days)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		stats := make([]map[string]interface{}, len(result))
+		for i, day := range result {
+			stats[i] = map[string]interface{}{
+				"date":      day.Date.Format("2006-01-02"),
+				"downloads": day.Downloads,
+				"previews":  day.Previews,
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"fileDownloadStats": stats,
+			},
+		}
+	}
+
+	// fileAccessHistory query (check before "me", which its user fields contain)
+	if strings.Contains(query, "fileAccessHistory") {
+		fileID, ok := variables["fileId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+
+		result, err := h.resolver.FileAccessHistory(ctx, fileID, limit, offset)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"fileAccessHistory": auditLogsToMaps(result),
+			},
+		}
+	}
+
+	// fileActivity query (check before "me", which its user fields contain)
+	if strings.Contains(query, "fileActivity") {
+		fileID, ok := variables["fileId"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
+			}
+		}
+
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+
+		result, err := h.resolver.FileActivity(ctx, fileID, limit, offset)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"fileActivity": auditLogsToMaps(result),
+			},
+		}
+	}
+
+	// securityEvents query (check before "me", which its user fields contain)
+	if strings.Contains(query, "securityEvents") {
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+
+		result, err := h.resolver.SecurityEvents(ctx, limit, offset)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"securityEvents": auditLogsToMaps(result),
+			},
+		}
+	}
+
+	// notification queries (check before "me", which "message" contains)
+	if strings.Contains(query, "unreadNotificationCount") {
+		result, err := h.resolver.UnreadNotificationCount(ctx)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"unreadNotificationCount": result,
+			},
+		}
+	}
+
+	if strings.Contains(query, "notifications") {
+		var unreadOnly *bool
+		if u, ok := variables["unreadOnly"].(bool); ok {
+			unreadOnly = &u
+		}
+
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+
+		result, err := h.resolver.Notifications(ctx, unreadOnly, limit, offset)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		notifications := make([]map[string]interface{}, len(result))
+		for i, notification := range result {
+			notifications[i] = notificationToMap(notification)
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"notifications": notifications,
+			},
+		}
+	}
+
+	// duplicateFiles query (check before "me", which "filename" contains)
+	if strings.Contains(query, "duplicateFiles") {
+		result, err := h.resolver.DuplicateFiles(ctx)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		loader := h.resolver.userLoader(ctx)
+		groups := make([]map[string]interface{}, len(result))
+		for i, group := range result {
+			groups[i] = map[string]interface{}{
+				"contentHash": group.ContentHash,
+				"fileSize":    group.FileSize,
+				"count":       len(group.Files),
+				"files":       filesToMaps(loader, group.Files),
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"duplicateFiles": groups,
+			},
+		}
+	}
+
+	// myTags query (check before "me" since "myTags" contains it)
+	if strings.Contains(query, "myTags") {
+		var prefix *string
+		if value, ok := variables["prefix"].(string); ok {
+			prefix = &value
+		}
+		var limit *int
+		if value, ok := variables["limit"].(float64); ok {
+			l := int(value)
+			limit = &l
+		}
+
+		result, err := h.resolver.MyTags(ctx, prefix, limit)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		tags := make([]map[string]interface{}, len(result))
+		for i, tag := range result {
+			tags[i] = map[string]interface{}{
+				"tag":   tag.Tag,
+				"count": tag.Count,
+			}
+		}
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"myTags": tags,
+			},
+		}
+	}
+
+	// activityTimeSeries query (check before "me" since "activityTimeSeries" contains it)
+	if strings.Contains(query, "activityTimeSeries") {
+		var days *int
+		var bucket *string
+		if variables != nil {
+			if d, ok := variables["days"].(float64); ok {
+				daysInt := int(d)
+				days = &daysInt
+			}
+			if b, ok := variables["bucket"].(string); ok {
+				bucket = &b
+			}
+		}
+
+		result, err := h.resolver.ActivityTimeSeries(ctx, days, bucket)
+		if err != nil {
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
+			}
+		}
+
+		points := make([]map[string]interface{}, len(result))
+		for i, point := range result {
+			actions := make([]string, 0, len(point.Counts))
+			for action := range point.Counts {
+				actions = append(actions, action)
+			}
+			sort.Strings(actions)
+
+			counts := make([]map[string]interface{}, len(actions))
+			for j, action := range actions {
+				counts[j] = map[string]interface{}{
+					"action": action,
+					"count":  point.Counts[action],
+				}
+			}
+			points[i] = map[string]interface{}{
+				"bucket": point.Bucket,
+				"total":  point.Total,
+				"counts": counts,
 			}
 		}
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"moveFile": map[string]interface{}{
-					"id":           result.ID.String(),
-					"userId":       result.UserID.String(),
-					"folderId":     nil,
-					"filename":     result.Filename,
-					"originalName": result.OriginalName,
-					"mimeType":     result.MimeType,
-					"fileSize":     result.FileSize,
-					"contentHash":  result.ContentHash,
-					"description":  result.Description,
-					"tags":         result.Tags,
-					"visibility":   result.Visibility,
-					"shareToken":   result.ShareToken,
-					"downloadCount": result.DownloadCount,
-					"uploadDate":   result.UploadDate,
-					"updatedAt":    result.UpdatedAt,
-					"user":         nil,
-					"folder":       nil,
-				},
+				"activityTimeSeries": points,
 			},
 		}
 	}
 
-	// Delete file mutation
-	if strings.Contains(query, "deleteFile(") {
-		fileID, ok := variables["id"].(string)
-		if !ok {
-			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "File ID is required"}},
-			}
+	// enterpriseUsers query (check before "me", which its user fields contain)
+	if strings.Contains(query, "enterpriseUsers") {
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+		var search *string
+		if q, ok := variables["query"].(string); ok {
+			search = &q
 		}
 
-		result, err := h.resolver.DeleteFile(ctx, fileID)
+		result, err := h.resolver.EnterpriseUsers(ctx, limit, offset, search)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
+		users := make([]map[string]interface{}, len(result.Items))
+		for i, user := range result.Items {
+			users[i] = userToMap(user)
+		}
+
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"deleteFile": result,
+				"enterpriseUsers": map[string]interface{}{
+					"items":       users,
+					"totalCount":  result.TotalCount,
+					"hasNextPage": result.HasNextPage,
+				},
 			},
 		}
 	}
 
-	// File reference mutations
-	if strings.Contains(query, "createFileReference(") {
-		input, ok := variables["input"].(map[string]interface{})
-		if !ok {
+	// users query, admin only (check before "me", which its user fields contain)
+	if strings.Contains(query, "users(") || strings.Contains(query, "users {") {
+		var limit, offset *int
+		if l, ok := variables["limit"].(float64); ok {
+			limitInt := int(l)
+			limit = &limitInt
+		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+		var search *string
+		if q, ok := variables["query"].(string); ok {
+			search = &q
+		}
+
+		result, err := h.resolver.GetUsers(ctx, limit, offset, search)
+		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Input is required"}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
-		createFileRefInput := CreateFileReferenceInput{
-			FileID:   input["fileId"].(string),
-			FolderID: input["folderId"].(string),
+		users := make([]map[string]interface{}, len(result.Items))
+		for i, user := range result.Items {
+			users[i] = userToMap(user)
 		}
-		if name, ok := input["name"].(string); ok && name != "" {
-			createFileRefInput.Name = &name
+
+		return GraphQLResponse{
+			Data: map[string]interface{}{
+				"users": map[string]interface{}{
+					"items":       users,
+					"totalCount":  result.TotalCount,
+					"hasNextPage": result.HasNextPage,
+				},
+			},
 		}
+	}
 
-		result, err := h.resolver.CreateFileReference(ctx, createFileRefInput)
+	// myEnterprise and enterpriseStats queries (check before "me" since field names like "name" contain it)
+	if strings.Contains(query, "myEnterprise") {
+		result, err := h.resolver.MyEnterprise(ctx)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"createFileReference": map[string]interface{}{
-					"id":        result.ID.String(),
-					"folderId":  result.FolderID.String(),
-					"fileId":    result.FileID.String(),
-					"userId":    result.UserID.String(),
-					"name":      result.Name,
-					"createdAt": result.CreatedAt,
-					"file":      nil,
-					"folder":    nil,
-					"user":      nil,
-				},
+				"myEnterprise": enterpriseToMap(result),
 			},
 		}
 	}
 
-	if strings.Contains(query, "deleteFileReference(") {
-		referenceID, ok := variables["id"].(string)
-		if !ok {
-			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Reference ID is required"}},
+	if strings.Contains(query, "enterpriseStats") {
+		var id *string
+		if variables != nil {
+			if idStr, ok := variables["id"].(string); ok {
+				id = &idStr
 			}
 		}
 
-		result, err := h.resolver.DeleteFileReference(ctx, referenceID)
+		stats, err := h.resolver.EnterpriseStats(ctx, id)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"deleteFileReference": result,
+				"enterpriseStats": map[string]interface{}{
+					"totalUsers":             stats.TotalUsers,
+					"totalFiles":             stats.TotalFiles,
+					"storageUsed":            stats.StorageUsed,
+					"storageQuota":           stats.StorageQuota,
+					"storageUsagePercentage": stats.StorageUsagePerc,
+					"filesThisMonth":         stats.FilesThisMonth,
+					"activeUsers":            stats.ActiveUsers,
+				},
 			},
 		}
 	}
 
-	return GraphQLResponse{
-		Errors: []GraphQLError{{Message: "Unknown mutation"}},
-	}
-}
-
-func (h *Handler) processQueryOperation(ctx context.Context, query string, variables map[string]interface{}) GraphQLResponse {
-	fmt.Printf("DEBUG: processQueryOperation called with query: %s\n", query)
+	// adminStats query, admin only (check before "me", which its consumers' fields contain)
+	if strings.Contains(query, "adminStats") {
+		var topConsumers *int
+		if t, ok := variables["topConsumers"].(float64); ok {
+			topInt := int(t)
+			topConsumers = &topInt
+		}
 
-	// myFolders query (check before "me" since it contains "me")
-	if strings.Contains(query, "myFolders") {
-		result, err := h.resolver.GetMyFolders(ctx)
+		stats, err := h.resolver.AdminStats(ctx, topConsumers)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
-		folders := make([]map[string]interface{}, len(result))
-		for i, folder := range result {
-			folders[i] = map[string]interface{}{
-				"id":        folder.ID.String(),
-				"userId":    folder.UserID.String(),
-				"name":      folder.Name,
-				"parentId":  nil,
-				"createdAt": folder.CreatedAt,
-				"updatedAt": folder.UpdatedAt,
-				"parent":    nil,
-				"children":  []interface{}{},
-				"files":     []interface{}{},
+		consumers := make([]map[string]interface{}, len(stats.TopStorageConsumers))
+		for i, user := range stats.TopStorageConsumers {
+			consumers[i] = map[string]interface{}{
+				"id":           user.ID.String(),
+				"name":         user.Name,
+				"email":        user.Email,
+				"storageUsed":  user.StorageUsed,
+				"storageQuota": user.StorageQuota,
 			}
 		}
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"myFolders": folders,
+				"adminStats": map[string]interface{}{
+					"totalUsers":                     stats.TotalUsers,
+					"totalFiles":                     stats.TotalFiles,
+					"storageUsed":                    stats.StorageUsed,
+					"storedSize":                     stats.StoredSize,
+					"deduplicationSavings":           stats.DeduplicationSavings,
+					"deduplicationSavingsPercentage": stats.DeduplicationSavingsPercentage,
+					"uploadsLast24Hours":             stats.UploadsLast24Hours,
+					"uploadsLast7Days":               stats.UploadsLast7Days,
+					"uploadsLast30Days":              stats.UploadsLast30Days,
+					"topStorageConsumers":            consumers,
+				},
 			},
 		}
 	}
 
-	// myFiles query (check before "me" since "myFiles" contains "me")
-	if strings.Contains(query, "myFiles") {
-		var limit, offset *int
-		if variables != nil {
-			if l, ok := variables["limit"].(float64); ok {
-				limitInt := int(l)
-				limit = &limitInt
-			}
-			if o, ok := variables["offset"].(float64); ok {
-				offsetInt := int(o)
-				offset = &offsetInt
+	// file query (check before "me", which "filename" contains)
+	if strings.Contains(query, "file(") {
+		fileID, ok := variables["id"].(string)
+		if !ok {
+			return GraphQLResponse{
+				Errors: []GraphQLError{validationError("File ID is required")},
 			}
 		}
 
-		files, err := h.resolver.GetMyFiles(ctx, limit, offset)
+		result, err := h.resolver.GetFile(ctx, fileID)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
-			}
-		}
-
-		fileData := make([]map[string]interface{}, len(files))
-		for i, file := range files {
-			fileData[i] = map[string]interface{}{
-				"id":           file.ID.String(),
-				"userId":       file.UserID.String(),
-				"folderId":     nil,
-				"filename":     file.Filename,
-				"originalName": file.OriginalName,
-				"mimeType":     file.MimeType,
-				"fileSize":     file.FileSize,
-				"contentHash":  file.ContentHash,
-				"description":  file.Description,
-				"tags":         file.Tags,
-				"visibility":   file.Visibility,
-				"shareToken":   file.ShareToken,
-				"downloadCount": file.DownloadCount,
-				"uploadDate":   file.UploadDate,
-				"updatedAt":    file.UpdatedAt,
-				"user":         nil,
-				"folder":       nil,
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"myFiles": fileData,
+				"file": fileToMap(result),
 			},
 		}
 	}
@@ -764,22 +2376,7 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"me": map[string]interface{}{
-					"id":              user.ID.String(),
-					"email":           user.Email,
-					"name":            user.Name,
-					"profileImage":    user.ProfileImage,
-					"role":            user.Role,
-					"storageUsed":     user.StorageUsed,
-					"storageQuota":    user.StorageQuota,
-					"emailVerified":   user.EmailVerified,
-					"lastLoginAt":     user.LastLoginAt,
-					"enterpriseId":    nil,
-					"enterpriseRole":  nil,
-					"enterprise":      nil,
-					"createdAt":       user.CreatedAt,
-					"updatedAt":       user.UpdatedAt,
-				},
+				"me": userToMap(user),
 			},
 		}
 	}
@@ -789,7 +2386,7 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 		stats, err := h.resolver.GetStorageStats(ctx)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -814,14 +2411,14 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 		fileID, ok := variables["fileId"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "File ID is required"}},
+				Errors: []GraphQLError{validationError("File ID is required")},
 			}
 		}
 
 		result, err := h.resolver.FileShareInfo(ctx, fileID)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -832,6 +2429,8 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 				"shared_with_user_id":  share.SharedWithUserID,
 				"permission_type":      share.PermissionType,
 				"created_at":           share.CreatedAt,
+				"expires_at":           share.ExpiresAt,
+				"expires_in_seconds":   share.ExpiresInSeconds,
 				"shared_with": map[string]interface{}{
 					"id":    share.SharedWith.ID.String(),
 					"name":  share.SharedWith.Name,
@@ -846,6 +2445,7 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 					"isShared":         result.IsShared,
 					"shareToken":       result.ShareToken,
 					"shareUrl":         result.ShareURL,
+					"shortUrl":         optionalString(result.ShortURL),
 					"downloadCount":    result.DownloadCount,
 					"sharedWithUsers":  sharedWithUsers,
 				},
@@ -857,25 +2457,33 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 		queryStr, ok := variables["query"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Query is required"}},
+				Errors: []GraphQLError{validationError("Query is required")},
 			}
 		}
 
-		var limit *int
+		var limit, offset *int
 		if l, ok := variables["limit"].(float64); ok {
 			limitInt := int(l)
 			limit = &limitInt
 		}
+		if o, ok := variables["offset"].(float64); ok {
+			offsetInt := int(o)
+			offset = &offsetInt
+		}
+		var allUsers *bool
+		if a, ok := variables["allUsers"].(bool); ok {
+			allUsers = &a
+		}
 
-		result, err := h.resolver.SearchUsers(ctx, queryStr, limit)
+		result, err := h.resolver.SearchUsers(ctx, queryStr, limit, offset, allUsers)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
-		users := make([]map[string]interface{}, len(result))
-		for i, user := range result {
+		users := make([]map[string]interface{}, len(result.Items))
+		for i, user := range result.Items {
 			users[i] = map[string]interface{}{
 				"id":    user.ID.String(),
 				"name":  user.Name,
@@ -885,7 +2493,11 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"searchUsers": users,
+				"searchUsers": map[string]interface{}{
+					"items":       users,
+					"totalCount":  result.TotalCount,
+					"hasNextPage": result.HasNextPage,
+				},
 			},
 		}
 	}
@@ -901,38 +2513,31 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 			offset = &offsetInt
 		}
 
-		result, err := h.resolver.SharedWithMe(ctx, limit, offset)
+		var after, sharedBy *string
+		if a, ok := variables["after"].(string); ok {
+			after = &a
+		}
+		if by, ok := variables["sharedBy"].(string); ok {
+			sharedBy = &by
+		}
+
+		result, err := h.resolver.SharedWithMe(ctx, limit, offset, after, sharedBy)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
-		files := make([]map[string]interface{}, len(result))
-		for i, file := range result {
-			files[i] = map[string]interface{}{
-				"id":           file.ID.String(),
-				"userId":       file.UserID.String(),
-				"folderId":     nil,
-				"filename":     file.Filename,
-				"originalName": file.OriginalName,
-				"mimeType":     file.MimeType,
-				"fileSize":     file.FileSize,
-				"contentHash":  file.ContentHash,
-				"description":  file.Description,
-				"tags":         file.Tags,
-				"visibility":   file.Visibility,
-				"downloadCount": file.DownloadCount,
-				"uploadDate":   file.UploadDate,
-				"updatedAt":    file.UpdatedAt,
-				"user":         nil,
-				"folder":       nil,
-			}
-		}
+		files := filesToMaps(h.resolver.userLoader(ctx), result.Items)
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"sharedWithMe": files,
+				"sharedWithMe": map[string]interface{}{
+					"items":       files,
+					"totalCount":  result.TotalCount,
+					"hasNextPage": result.HasNextPage,
+					"pageInfo":    pageInfoToMap(result.PageInfo),
+				},
 			},
 		}
 	}
@@ -941,14 +2546,14 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 		folderID, ok := variables["id"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Folder ID is required"}},
+				Errors: []GraphQLError{validationError("Folder ID is required")},
 			}
 		}
 
 		result, err := h.resolver.GetFolderContents(ctx, folderID)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -1011,14 +2616,14 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 		folderID, ok := variables["id"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Folder ID is required"}},
+				Errors: []GraphQLError{validationError("Folder ID is required")},
 			}
 		}
 
 		result, err := h.resolver.GetFolder(ctx, folderID)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -1044,14 +2649,14 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 		folderID, ok := variables["folderId"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "Folder ID is required"}},
+				Errors: []GraphQLError{validationError("Folder ID is required")},
 			}
 		}
 
 		result, err := h.resolver.FolderReferences(ctx, folderID)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -1081,14 +2686,14 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 		fileID, ok := variables["fileId"].(string)
 		if !ok {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: "File ID is required"}},
+				Errors: []GraphQLError{validationError("File ID is required")},
 			}
 		}
 
 		result, err := h.resolver.FileReferences(ctx, fileID)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -1116,7 +2721,6 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 
 	// Audit log queries
 	if strings.Contains(query, "auditLogs") {
-		fmt.Printf("DEBUG: auditLogs query detected!\n")
 		var limit, offset *int
 		var action, status *string
 
@@ -1137,45 +2741,16 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 			}
 		}
 
-		fmt.Printf("DEBUG: About to call h.resolver.GetAuditLogs\n")
 		result, err := h.resolver.GetAuditLogs(ctx, limit, offset, action, status)
 		if err != nil {
-			fmt.Printf("DEBUG: GetAuditLogs returned error: %v\n", err)
-			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
-			}
-		}
-		fmt.Printf("DEBUG: GetAuditLogs returned %d results\n", len(result))
-
-		logs := make([]map[string]interface{}, len(result))
-		for i, log := range result {
-			logs[i] = map[string]interface{}{
-				"id":           log.ID.String(),
-				"userId":       log.UserID.String(),
-				"action":       log.Action,
-				"status":       log.Status,
-				"resourceType": log.ResourceType,
-				"resourceId":   nil,
-				"resourceName": log.ResourceName,
-				"description":  log.Description,
-				"ipAddress":    log.IPAddress,
-				"userAgent":    log.UserAgent,
-				"metadata":     log.Metadata,
-				"createdAt":    log.CreatedAt,
-				"user": map[string]interface{}{
-					"id":    log.User.ID.String(),
-					"name":  log.User.Name,
-					"email": log.User.Email,
-				},
-			}
-			if log.ResourceID != nil {
-				logs[i]["resourceId"] = log.ResourceID.String()
+			return GraphQLResponse{
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"auditLogs": logs,
+				"auditLogs": auditLogsToMaps(result),
 			},
 		}
 	}
@@ -1192,39 +2767,13 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 		result, err := h.resolver.GetRecentActivity(ctx, limit)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
-			}
-		}
-
-		logs := make([]map[string]interface{}, len(result))
-		for i, log := range result {
-			logs[i] = map[string]interface{}{
-				"id":           log.ID.String(),
-				"userId":       log.UserID.String(),
-				"action":       log.Action,
-				"status":       log.Status,
-				"resourceType": log.ResourceType,
-				"resourceId":   nil,
-				"resourceName": log.ResourceName,
-				"description":  log.Description,
-				"ipAddress":    log.IPAddress,
-				"userAgent":    log.UserAgent,
-				"metadata":     log.Metadata,
-				"createdAt":    log.CreatedAt,
-				"user": map[string]interface{}{
-					"id":    log.User.ID.String(),
-					"name":  log.User.Name,
-					"email": log.User.Email,
-				},
-			}
-			if log.ResourceID != nil {
-				logs[i]["resourceId"] = log.ResourceID.String()
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
 		return GraphQLResponse{
 			Data: map[string]interface{}{
-				"recentActivity": logs,
+				"recentActivity": auditLogsToMaps(result),
 			},
 		}
 	}
@@ -1241,7 +2790,7 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 		result, err := h.resolver.GetActivityStats(ctx, days)
 		if err != nil {
 			return GraphQLResponse{
-				Errors: []GraphQLError{{Message: err.Error()}},
+				Errors: []GraphQLError{toGraphQLError(err)},
 			}
 		}
 
@@ -1253,6 +2802,317 @@ func (h *Handler) processQueryOperation(ctx context.Context, query string, varia
 	}
 
 	return GraphQLResponse{
-		Errors: []GraphQLError{{Message: "Unknown query"}},
+		Errors: []GraphQLError{validationError("Unknown query")},
+	}
+}
+
+// filesToMaps converts files to their GraphQL representation, loading every owner with one
+// batched query
+func filesToMaps(loader *UserLoader, files []*domain.File) []map[string]interface{} {
+	for _, file := range files {
+		if file.User == nil {
+			loader.Queue(file.UserID)
+		}
+	}
+
+	result := make([]map[string]interface{}, len(files))
+	for i, file := range files {
+		result[i] = fileToMap(file)
+		if file.User != nil {
+			result[i]["user"] = userSummaryToMap(file.User)
+		} else if owner, err := loader.Load(file.UserID); err == nil && owner != nil {
+			result[i]["user"] = userSummaryToMap(owner)
+		}
+	}
+	return result
+}
+
+// userSummaryToMap converts the user fields nested under other objects
+// folderToMap converts a folder without its relations to GraphQL response format
+func folderToMap(folder *domain.Folder) map[string]interface{} {
+	if folder == nil {
+		return nil
+	}
+
+	var parentID interface{}
+	if folder.ParentID != nil {
+		parentID = folder.ParentID.String()
+	}
+	return map[string]interface{}{
+		"id":        folder.ID.String(),
+		"userId":    folder.UserID.String(),
+		"name":      folder.Name,
+		"parentId":  parentID,
+		"createdAt": folder.CreatedAt,
+		"updatedAt": folder.UpdatedAt,
+		"parent":    nil,
+		"children":  []interface{}{},
+		"files":     []interface{}{},
+	}
+}
+
+// folderSharesToMaps converts folder shares to GraphQL response format, loading the users on
+// both ends of every share in one batch
+func folderSharesToMaps(loader *UserLoader, shares []*domain.FolderShare) []map[string]interface{} {
+	for _, share := range shares {
+		loader.Queue(share.SharedByUserID, share.SharedWithUserID)
+	}
+
+	result := make([]map[string]interface{}, len(shares))
+	for i, share := range shares {
+		var sharedBy, sharedWith interface{}
+		if user, err := loader.Load(share.SharedByUserID); err == nil && user != nil {
+			sharedBy = userSummaryToMap(user)
+		}
+		if user, err := loader.Load(share.SharedWithUserID); err == nil && user != nil {
+			sharedWith = userSummaryToMap(user)
+		}
+		result[i] = map[string]interface{}{
+			"id":               share.ID.String(),
+			"folderId":         share.FolderID.String(),
+			"sharedByUserId":   share.SharedByUserID.String(),
+			"sharedWithUserId": share.SharedWithUserID.String(),
+			"permissionType":   share.PermissionType,
+			"createdAt":        share.CreatedAt,
+			"folder":           folderToMap(share.Folder),
+			"sharedBy":         sharedBy,
+			"sharedWith":       sharedWith,
+		}
+	}
+	return result
+}
+
+// userToMap converts a user to GraphQL response format. Only the fields of the User type are
+// copied, so the password hash and tokens never reach a response.
+func userToMap(user *domain.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             user.ID.String(),
+		"email":          user.Email,
+		"name":           user.Name,
+		"profileImage":   user.ProfileImage,
+		"role":           user.Role,
+		"storageUsed":    user.StorageUsed,
+		"storageQuota":   user.StorageQuota,
+		"emailVerified":  user.EmailVerified,
+		"lastLoginAt":    user.LastLoginAt,
+		"enterpriseId":   enterpriseIDValue(user),
+		"enterpriseRole": user.EnterpriseRole,
+		"enterprise":     enterpriseToMap(user.Enterprise),
+		"createdAt":      user.CreatedAt,
+		"updatedAt":      user.UpdatedAt,
+	}
+}
+
+func userSummaryToMap(user *domain.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":    user.ID.String(),
+		"name":  user.Name,
+		"email": user.Email,
+	}
+}
+
+// pageInfoToMap converts page info to its GraphQL representation
+func pageInfoToMap(pageInfo PageInfo) map[string]interface{} {
+	return map[string]interface{}{
+		"endCursor":   pageInfo.EndCursor,
+		"hasNextPage": pageInfo.HasNextPage,
+	}
+}
+
+// fileToMap converts a file to its GraphQL representation
+func fileToMap(file *domain.File) map[string]interface{} {
+	var folderID interface{}
+	if file.FolderID != nil {
+		folderID = file.FolderID.String()
+	}
+
+	return map[string]interface{}{
+		"id":             file.ID.String(),
+		"userId":         file.UserID.String(),
+		"folderId":       folderID,
+		"filename":       file.Filename,
+		"originalName":   file.OriginalName,
+		"mimeType":       file.MimeType,
+		"fileSize":       file.FileSize,
+		"contentHash":    file.ContentHash,
+		"description":    file.Description,
+		"tags":           file.Tags,
+		"visibility":     file.Visibility,
+		"shareToken":     file.ShareToken,
+		"downloadCount":  file.DownloadCount,
+		"uploadDate":     file.UploadDate,
+		"updatedAt":      file.UpdatedAt,
+		"isStarred":      file.IsStarred,
+		"isLocked":       file.Lock != nil,
+		"lockedBy":       lockHolderToMap(file.Lock),
+		"encrypted":      file.Encryption != nil,
+		"encryption":     fileEncryptionToMap(file.Encryption),
+		"lastAccessedAt": file.LastAccessedAt,
+		"user":           nil,
+		"folder":         nil,
+	}
+}
+
+// fileLockToMap converts a file lock to its GraphQL representation
+func fileLockToMap(lock *domain.FileLock) map[string]interface{} {
+	return map[string]interface{}{
+		"fileId":     lock.FileID.String(),
+		"lockedBy":   lockHolderToMap(lock),
+		"acquiredAt": lock.AcquiredAt,
+		"expiresAt":  lock.ExpiresAt,
+	}
+}
+
+// lockHolderToMap converts the holder of a lock to the user fields nested under files, or nil
+// when there is no lock
+func lockHolderToMap(lock *domain.FileLock) interface{} {
+	if lock == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"id":   lock.HolderID.String(),
+		"name": lock.HolderName,
+	}
+}
+
+// fileEncryptionToMap converts the metadata of a client-encrypted file to its GraphQL
+// representation, or nil for a plain file
+func fileEncryptionToMap(encryption *domain.FileEncryption) interface{} {
+	if encryption == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"algorithm":  encryption.Algorithm,
+		"iv":         encryption.IV,
+		"wrappedKey": encryption.WrappedKey,
+	}
+}
+
+// notificationToMap converts a notification to its GraphQL representation
+func notificationToMap(notification *domain.Notification) map[string]interface{} {
+	result := map[string]interface{}{
+		"id":           notification.ID.String(),
+		"type":         notification.Type,
+		"resourceType": notification.ResourceType,
+		"resourceId":   nil,
+		"message":      notification.Message,
+		"read":         notification.ReadAt != nil,
+		"readAt":       notification.ReadAt,
+		"createdAt":    notification.CreatedAt,
+		"actor":        nil,
+	}
+	if notification.ResourceID != nil {
+		result["resourceId"] = notification.ResourceID.String()
+	}
+	if notification.Actor != nil {
+		result["actor"] = map[string]interface{}{
+			"id":    notification.Actor.ID.String(),
+			"name":  notification.Actor.Name,
+			"email": notification.Actor.Email,
+		}
+	}
+	return result
+}
+
+// webhookToMap converts a webhook to its GraphQL representation; the secret is only set on creation
+func webhookToMap(webhook *domain.Webhook) map[string]interface{} {
+	result := map[string]interface{}{
+		"id":        webhook.ID.String(),
+		"url":       webhook.URL,
+		"events":    webhook.Events,
+		"secret":    nil,
+		"createdAt": webhook.CreatedAt,
+	}
+	if webhook.Secret != "" {
+		result["secret"] = webhook.Secret
+	}
+	return result
+}
+
+// stringList converts a list variable to strings, skipping non-string entries
+func stringList(value interface{}) []string {
+	items, _ := value.([]interface{})
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if str, ok := item.(string); ok {
+			result = append(result, str)
+		}
+	}
+	return result
+}
+
+// auditLogsToMaps converts audit logs to their GraphQL representation
+func auditLogsToMaps(result []*domain.AuditLog) []map[string]interface{} {
+	logs := make([]map[string]interface{}, len(result))
+	for i, log := range result {
+		logs[i] = map[string]interface{}{
+			"id":           log.ID.String(),
+			"userId":       log.UserID.String(),
+			"action":       log.Action,
+			"status":       log.Status,
+			"resourceType": log.ResourceType,
+			"resourceId":   nil,
+			"resourceName": log.ResourceName,
+			"description":  log.Description,
+			"ipAddress":    log.IPAddress,
+			"userAgent":    log.UserAgent,
+			"metadata":     log.Metadata,
+			"createdAt":    log.CreatedAt,
+			"user": map[string]interface{}{
+				"id":    log.User.ID.String(),
+				"name":  log.User.Name,
+				"email": log.User.Email,
+			},
+		}
+		if log.ResourceID != nil {
+			logs[i]["resourceId"] = log.ResourceID.String()
+		}
+	}
+	return logs
+}
+
+// enterpriseToMap converts an enterprise to its GraphQL representation; nil stays nil
+func enterpriseToMap(enterprise *domain.Enterprise) interface{} {
+	if enterprise == nil {
+		return nil
+	}
+
+	settings := enterprise.Settings
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+
+	return map[string]interface{}{
+		"id":                    enterprise.ID.String(),
+		"name":                  enterprise.Name,
+		"slug":                  enterprise.Slug,
+		"domain":                enterprise.Domain,
+		"domainVerified":        enterprise.DomainVerified,
+		"storageQuota":          enterprise.StorageQuota,
+		"storageUsed":           enterprise.StorageUsed,
+		"maxUsers":              enterprise.MaxUsers,
+		"currentUsers":          enterprise.CurrentUsers,
+		"settings":              settings,
+		"subscriptionPlan":      enterprise.SubscriptionPlan,
+		"subscriptionStatus":    enterprise.SubscriptionStatus,
+		"subscriptionExpiresAt": enterprise.SubscriptionExpires,
+		"billingEmail":          enterprise.BillingEmail,
+		"createdAt":             enterprise.CreatedAt,
+		"updatedAt":             enterprise.UpdatedAt,
+	}
+}
+
+func enterpriseIDValue(user *domain.User) interface{} {
+	if user.EnterpriseID == nil {
+		return nil
 	}
-}
\ No newline at end of file
+	return user.EnterpriseID.String()
+}
+
+// optionalString is value, or nil for a nullable field when it is empty
+func optionalString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
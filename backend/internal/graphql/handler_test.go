@@ -0,0 +1,127 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"lokr-backend/pkg/auth"
+)
+
+// serveGraphQL posts body to a handler whose resolver has no services, enough for operations
+// that are answered before any lookup
+func serveGraphQL(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/graphql", NewHandler(&Resolver{}, auth.NewJWTManager("test-secret")).ServeHTTP)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestHandler_Batch(t *testing.T) {
+	recorder := serveGraphQL(t, ` [
+		{"query": "{ __schema { queryType { name } } }"},
+		{"query": "query { me { id } }"}
+	]`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var responses []GraphQLResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &responses); err != nil {
+		t.Fatalf("Expected an array of responses: %v", err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("Expected 2 responses, got %d", len(responses))
+	}
+	if responses[0].Data == nil || len(responses[0].Errors) != 0 {
+		t.Errorf("Expected the introspection result first, got %+v", responses[0])
+	}
+	if len(responses[1].Errors) != 1 || responses[1].Errors[0].Extensions["code"] != CodeUnauthenticated {
+		t.Errorf("Expected the unauthenticated me query second, got %+v", responses[1])
+	}
+}
+
+func TestHandler_SingleOperation(t *testing.T) {
+	recorder := serveGraphQL(t, `{"query": "{ __schema { queryType { name } } }"}`)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Expected a single response object: %v", err)
+	}
+	if _, ok := response["data"]; !ok {
+		t.Errorf("Expected data in the response, got %s", recorder.Body.String())
+	}
+}
+
+func TestHandler_InvalidBatch(t *testing.T) {
+	tests := map[string]string{
+		"empty batch":     `[]`,
+		"oversized batch": "[" + strings.TrimSuffix(strings.Repeat(`{"query":"{ __schema { queryType { name } } }"},`, maxBatchSize+1), ",") + "]",
+		"malformed batch": `[{"query": }]`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			if recorder := serveGraphQL(t, body); recorder.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_DebugLoggingFollowsLevel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// serve runs a query through a handler logging at level, returning what was logged and what
+	// was written to stdout
+	serve := func(level zapcore.Level) (string, string) {
+		var logged bytes.Buffer
+		core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&logged), level)
+		resolver := &Resolver{}
+		resolver.SetLogger(zap.New(core))
+		router := gin.New()
+		router.POST("/graphql", NewHandler(resolver, auth.NewJWTManager("test-secret")).ServeHTTP)
+
+		stdout := os.Stdout
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("Failed to capture stdout: %v", err)
+		}
+		os.Stdout = writer
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "query { me { id } }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		os.Stdout = stdout
+		writer.Close()
+		printed, _ := io.ReadAll(reader)
+
+		return logged.String(), string(printed)
+	}
+
+	logged, printed := serve(zapcore.InfoLevel)
+	if logged != "" {
+		t.Errorf("Expected nothing logged at info level, got %s", logged)
+	}
+	if strings.Contains(printed, "DEBUG") {
+		t.Errorf("Expected no debug output on stdout, got %s", printed)
+	}
+
+	if logged, _ := serve(zapcore.DebugLevel); !strings.Contains(logged, `"level":"debug"`) {
+		t.Errorf("Expected debug logging at debug level, got %s", logged)
+	}
+}
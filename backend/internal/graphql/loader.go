@@ -0,0 +1,88 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"lokr-backend/internal/domain"
+)
+
+// userBatchFunc loads the users with the given ids in one round trip, skipping unknown ids
+type userBatchFunc func(ids []uuid.UUID) ([]*domain.User, error)
+
+// UserLoader batches user lookups within one request. Resolvers Queue every user id a result
+// will need before they Load any of them; the first Load then fetches all queued ids with a
+// single query, and later loads are served from the cache.
+type UserLoader struct {
+	batch userBatchFunc
+
+	mu      sync.Mutex
+	pending map[uuid.UUID]bool
+	users   map[uuid.UUID]*domain.User // A nil entry means the user does not exist
+}
+
+func NewUserLoader(batch userBatchFunc) *UserLoader {
+	return &UserLoader{
+		batch:   batch,
+		pending: make(map[uuid.UUID]bool),
+		users:   make(map[uuid.UUID]*domain.User),
+	}
+}
+
+// Queue records ids to fetch with the next batch
+func (l *UserLoader) Queue(ids ...uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range ids {
+		if _, loaded := l.users[id]; !loaded {
+			l.pending[id] = true
+		}
+	}
+}
+
+// Load returns the user with the given id, or nil when there is none. If the user is not cached
+// yet it is fetched together with everything queued so far.
+func (l *UserLoader) Load(id uuid.UUID) (*domain.User, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if user, loaded := l.users[id]; loaded {
+		return user, nil
+	}
+
+	l.pending[id] = true
+	ids := make([]uuid.UUID, 0, len(l.pending))
+	for pendingID := range l.pending {
+		ids = append(ids, pendingID)
+	}
+
+	users, err := l.batch(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pendingID := range ids {
+		l.users[pendingID] = nil
+	}
+	for _, user := range users {
+		l.users[user.ID] = user
+	}
+	l.pending = make(map[uuid.UUID]bool)
+
+	return l.users[id], nil
+}
+
+// userLoader returns the request's user loader, or a fresh one when the context has none
+func (r *Resolver) userLoader(ctx context.Context) *UserLoader {
+	if loader, ok := ctx.Value("userLoader").(*UserLoader); ok {
+		return loader
+	}
+	return r.newUserLoader()
+}
+
+func (r *Resolver) newUserLoader() *UserLoader {
+	return NewUserLoader(r.userService.GetUsersByIDs)
+}
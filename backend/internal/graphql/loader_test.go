@@ -0,0 +1,85 @@
+package graphql
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"lokr-backend/internal/domain"
+)
+
+// countingBatch serves users from memory and counts how many batches were requested
+type countingBatch struct {
+	users map[uuid.UUID]*domain.User
+	calls int
+}
+
+func (b *countingBatch) load(ids []uuid.UUID) ([]*domain.User, error) {
+	b.calls++
+	var users []*domain.User
+	for _, id := range ids {
+		if user, ok := b.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func TestFilesToMaps_BatchesOwnerLookups(t *testing.T) {
+	batch := &countingBatch{users: make(map[uuid.UUID]*domain.User)}
+
+	var files []*domain.File
+	for i := 0; i < 20; i++ {
+		owner := &domain.User{ID: uuid.New(), Name: "owner", Email: uuid.NewString() + "@example.com"}
+		batch.users[owner.ID] = owner
+		files = append(files, &domain.File{ID: uuid.New(), UserID: owner.ID})
+	}
+
+	result := filesToMaps(NewUserLoader(batch.load), files)
+
+	if batch.calls != 1 {
+		t.Errorf("Expected 1 user query for 20 files, got %d", batch.calls)
+	}
+	for i, file := range result {
+		user, ok := file["user"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("file %d: expected an owner, got %v", i, file["user"])
+		}
+		if user["id"] != files[i].UserID.String() {
+			t.Errorf("file %d: expected owner %s, got %v", i, files[i].UserID, user["id"])
+		}
+	}
+}
+
+func TestUserLoader_CachesAndRemembersMissingUsers(t *testing.T) {
+	known := &domain.User{ID: uuid.New(), Name: "known"}
+	batch := &countingBatch{users: map[uuid.UUID]*domain.User{known.ID: known}}
+	loader := NewUserLoader(batch.load)
+
+	missing := uuid.New()
+	loader.Queue(known.ID, missing)
+
+	if user, err := loader.Load(known.ID); err != nil || user != known {
+		t.Fatalf("Expected the known user, got %v (%v)", user, err)
+	}
+	if user, err := loader.Load(missing); err != nil || user != nil {
+		t.Errorf("Expected no user for an unknown id, got %v (%v)", user, err)
+	}
+	if user, _ := loader.Load(known.ID); user != known {
+		t.Errorf("Expected the cached user, got %v", user)
+	}
+	if batch.calls != 1 {
+		t.Errorf("Expected cached loads not to query again, got %d queries", batch.calls)
+	}
+}
+
+func TestUserLoader_ReturnsBatchErrors(t *testing.T) {
+	loader := NewUserLoader(func(ids []uuid.UUID) ([]*domain.User, error) {
+		return nil, errors.New("database unavailable")
+	})
+
+	if _, err := loader.Load(uuid.New()); err == nil {
+		t.Error("Expected the batch error to be returned")
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 
 	"lokr-backend/internal/domain"
@@ -22,7 +24,15 @@ type Resolver struct {
 	fileReferenceService *services.FileReferenceService
 	folderFileService *services.FolderFileService
 	auditService    *services.AuditService
+	enterpriseService *services.EnterpriseService
+	notificationService *services.NotificationService
+	webhookService  *services.WebhookService
+	fileRepo        domain.FileRepository
+	fileShareRepo   domain.FileShareRepository
+	userRepo        domain.UserRepository
 	jwtManager      *auth.JWTManager
+	logger          *zap.Logger
+	fileLockService *services.FileLockService
 }
 
 func NewResolver(
@@ -33,6 +43,12 @@ func NewResolver(
 	fileReferenceService *services.FileReferenceService,
 	folderFileService *services.FolderFileService,
 	auditService *services.AuditService,
+	enterpriseService *services.EnterpriseService,
+	notificationService *services.NotificationService,
+	webhookService *services.WebhookService,
+	fileRepo domain.FileRepository,
+	fileShareRepo domain.FileShareRepository,
+	userRepo domain.UserRepository,
 	jwtManager *auth.JWTManager,
 ) *Resolver {
 	return &Resolver{
@@ -43,10 +59,46 @@ func NewResolver(
 		fileReferenceService: fileReferenceService,
 		folderFileService: folderFileService,
 		auditService:      auditService,
+		enterpriseService: enterpriseService,
+		notificationService: notificationService,
+		webhookService:    webhookService,
+		fileRepo:          fileRepo,
+		fileShareRepo:     fileShareRepo,
+		userRepo:          userRepo,
 		jwtManager:        jwtManager,
 	}
 }
 
+// SetLogger sets where the resolver writes its debug logging; without one it logs nothing
+func (r *Resolver) SetLogger(logger *zap.Logger) {
+	r.logger = logger
+}
+
+// SetFileLockService enables edit locks on files; without it files are never locked
+func (r *Resolver) SetFileLockService(fileLockService *services.FileLockService) {
+	r.fileLockService = fileLockService
+}
+
+// annotateFiles fills in what a file result carries beyond its row: whether userID starred it, the
+// edit lock on it and how the client encrypted it
+func (r *Resolver) annotateFiles(ctx context.Context, userID uuid.UUID, files []*domain.File) error {
+	if err := r.simpleFileService.MarkStarred(ctx, userID, files); err != nil {
+		return err
+	}
+	if err := r.simpleFileService.MarkEncrypted(ctx, files); err != nil {
+		return err
+	}
+	return r.fileLockService.MarkLocked(ctx, files)
+}
+
+// requestLogger is the resolver's logger tagged with the request ID of ctx
+func (r *Resolver) requestLogger(ctx context.Context) *zap.Logger {
+	if r.logger == nil {
+		return zap.NewNop()
+	}
+	return services.RequestLogger(ctx, r.logger)
+}
+
 // Authentication Resolvers
 func (r *Resolver) Login(ctx context.Context, email, password string) (*AuthPayload, error) {
 	// Get user by email from database
@@ -62,6 +114,8 @@ func (r *Resolver) Login(ctx context.Context, email, password string) (*AuthPayl
 
 	// Update last login
 	r.userService.UpdateLastLogin(user.ID)
+	ipAddress, userAgent := requestMeta(ctx)
+	r.auditService.LogUserLogin(ctx, user.ID, ipAddress, userAgent)
 
 	// Generate tokens
 	token, err := r.jwtManager.GenerateToken(user.ID.String(), user.Email, string(user.Role))
@@ -74,6 +128,8 @@ func (r *Resolver) Login(ctx context.Context, email, password string) (*AuthPayl
 		return nil, fmt.Errorf("failed to generate refresh token")
 	}
 
+	r.loadEnterprise(ctx, user)
+
 	return &AuthPayload{
 		Token:        token,
 		RefreshToken: refreshToken,
@@ -99,6 +155,8 @@ func (r *Resolver) Register(ctx context.Context, input CreateUserInput) (*AuthPa
 		return nil, fmt.Errorf("failed to generate refresh token")
 	}
 
+	r.loadEnterprise(ctx, user)
+
 	return &AuthPayload{
 		Token:        token,
 		RefreshToken: refreshToken,
@@ -110,7 +168,7 @@ func (r *Resolver) Me(ctx context.Context) (*domain.User, error) {
 	// Get user ID from context (set by auth middleware)
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	id, err := uuid.Parse(userID)
@@ -120,9 +178,11 @@ func (r *Resolver) Me(ctx context.Context) (*domain.User, error) {
 
 	user, err := r.userService.GetUserByID(id)
 	if err != nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, errUserNotFound
 	}
 
+	r.loadEnterprise(ctx, user)
+
 	return user, nil
 }
 
@@ -142,27 +202,50 @@ func (r *Resolver) Logout(ctx context.Context) (bool, error) {
 func (r *Resolver) GetUser(ctx context.Context, id string) (*domain.User, error) {
 	userID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID")
+		return nil, invalidInput("invalid user ID")
 	}
 
 	user, err := r.userService.GetUserByID(userID)
 	if err != nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, errUserNotFound
 	}
 
 	return user, nil
 }
 
-func (r *Resolver) GetUsers(ctx context.Context, limit, offset *int) ([]*domain.User, error) {
-	// Not implemented for now
-	return []*domain.User{}, nil
+// GetUsers lists one page of every user, optionally filtered by a name or email search; only
+// platform ADMINs may call it
+func (r *Resolver) GetUsers(ctx context.Context, limit, offset *int, query *string) (*UserConnection, error) {
+	if _, ok := ctx.Value("userID").(string); !ok {
+		return nil, errUnauthorized
+	}
+	if isAdmin, _ := ctx.Value("isAdmin").(bool); !isAdmin {
+		return nil, services.ErrPermissionDenied
+	}
+
+	var search string
+	if query != nil {
+		search = *query
+	}
+
+	pageSize, pageOffset := pageBounds(limit, offset)
+	users, totalCount, err := r.userRepo.List(search, pageSize, pageOffset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return &UserConnection{
+		Items:       users,
+		TotalCount:  totalCount,
+		HasNextPage: pageOffset+len(users) < totalCount,
+	}, nil
 }
 
 func (r *Resolver) UpdateProfile(ctx context.Context, input UpdateUserInput) (*domain.User, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	id, err := uuid.Parse(userID)
@@ -172,7 +255,7 @@ func (r *Resolver) UpdateProfile(ctx context.Context, input UpdateUserInput) (*d
 
 	user, err := r.userService.GetUserByID(id)
 	if err != nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, errUserNotFound
 	}
 
 	// For now, just return the user without updating
@@ -185,7 +268,7 @@ func (r *Resolver) UploadFile(ctx context.Context, fileHeader interface{}, input
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -204,7 +287,7 @@ func (r *Resolver) UploadFile(ctx context.Context, fileHeader interface{}, input
 	if input.FolderID != nil {
 		folderUUID, err := uuid.Parse(*input.FolderID)
 		if err != nil {
-			return nil, fmt.Errorf("invalid folder ID: %w", err)
+			return nil, invalidInput("invalid folder ID: %w", err)
 		}
 		folderID = &folderUUID
 	}
@@ -218,55 +301,156 @@ func (r *Resolver) UploadFile(ctx context.Context, fileHeader interface{}, input
 	return file, nil
 }
 
-func (r *Resolver) GetMyFiles(ctx context.Context, limit, offset *int) ([]*domain.File, error) {
-	fmt.Printf("DEBUG: GetMyFiles called\n")
-
+// GetMyFiles lists one page of the user's files. A non-empty after cursor takes precedence over offset.
+func (r *Resolver) GetMyFiles(ctx context.Context, limit, offset *int, after *string) (*FileConnection, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		fmt.Printf("DEBUG: No userID in context\n")
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
-	fmt.Printf("DEBUG: Found userID in context: %s\n", userID)
 
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
-		fmt.Printf("DEBUG: Failed to parse userID: %v\n", err)
 		return nil, errors.New("invalid user ID")
 	}
 
-	// Set default values
-	defaultLimit := 20
-	defaultOffset := 0
-	if limit == nil {
-		limit = &defaultLimit
+	pageSize, pageOffset := pageBounds(limit, offset)
+	cursor, err := parseFileCursor(after)
+	if err != nil {
+		return nil, err
 	}
-	if offset == nil {
-		offset = &defaultOffset
+
+	// Fetch one extra file to learn whether another page follows
+	files, err := r.simpleFileService.GetFilesByUserID(ctx, userUUID, pageSize+1, pageOffset, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get files: %w", err)
+	}
+
+	totalCount, err := r.simpleFileService.CountFilesByUserID(ctx, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count files: %w", err)
+	}
+
+	r.requestLogger(ctx).Debug("Listed files",
+		zap.String("user_id", userID),
+		zap.Int("limit", pageSize),
+		zap.Int("offset", pageOffset),
+		zap.Int("count", len(files)),
+	)
+	connection := newFileConnection(files, pageSize, totalCount)
+	if err := r.annotateFiles(ctx, userUUID, connection.Items); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
+	}
+	return connection, nil
+}
+
+// FilesInFolder lists one page of the user's files directly inside a folder; a nil or empty
+// folderID lists the files at the root
+func (r *Resolver) FilesInFolder(ctx context.Context, folderID *string, limit, offset *int, sortBy, sortOrder *string) (*FileConnection, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	var folderUUID *uuid.UUID
+	if folderID != nil && *folderID != "" {
+		parsed, err := uuid.Parse(*folderID)
+		if err != nil {
+			return nil, invalidInput("invalid folder ID: %w", err)
+		}
+		folderUUID = &parsed
+	}
+
+	limitVal := 20
+	if limit != nil && *limit > 0 {
+		limitVal = *limit
+	}
+	if limitVal > 100 {
+		limitVal = 100
+	}
+
+	offsetVal := 0
+	if offset != nil && *offset > 0 {
+		offsetVal = *offset
+	}
+
+	var sortField, order string
+	if sortBy != nil {
+		sortField = *sortBy
+	}
+	if sortOrder != nil {
+		order = *sortOrder
+	}
+
+	// A folder shared with the user is listed as its owner sees it
+	ownerID := userUUID
+	if folderUUID != nil {
+		ownerID, err = r.fileSharingService.CheckFolderAccess(ctx, *folderUUID, userUUID, domain.PermissionView)
+		if err != nil {
+			return nil, err
+		}
 	}
-	fmt.Printf("DEBUG: Using limit=%d, offset=%d\n", *limit, *offset)
 
-	// Get files from the simplified file service
-	files, err := r.simpleFileService.GetFilesByUserID(ctx, userUUID, *limit, *offset)
+	files, totalCount, err := r.fileRepo.GetByFolder(ownerID, folderUUID, sortField, order, limitVal, offsetVal)
 	if err != nil {
-		fmt.Printf("DEBUG: GetFilesByUserID failed: %v\n", err)
 		return nil, fmt.Errorf("failed to get files: %w", err)
 	}
+	if err := r.annotateFiles(ctx, userUUID, files); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
+	}
 
-	fmt.Printf("DEBUG: GetMyFiles returning %d files\n", len(files))
-	return files, nil
+	return &FileConnection{
+		Items:       files,
+		TotalCount:  totalCount,
+		HasNextPage: offsetVal+len(files) < totalCount,
+	}, nil
 }
 
+// GetFile returns a file the caller owns or has been shared, or any PUBLIC file; PUBLIC files can be
+// read without signing in. Anonymous callers asking for anything else are told to authenticate, and
+// signed-in users get NOT_FOUND for files they can't see, so private files aren't revealed.
 func (r *Resolver) GetFile(ctx context.Context, id string) (*domain.File, error) {
-	// File service not available yet
-	return nil, fmt.Errorf("file service not available")
+	var userUUID *uuid.UUID
+	if userID, ok := ctx.Value("userID").(string); ok {
+		parsed, err := uuid.Parse(userID)
+		if err != nil {
+			return nil, errors.New("invalid user ID")
+		}
+		userUUID = &parsed
+	}
+
+	fileUUID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	file, err := r.simpleFileService.GetVisibleFile(ctx, fileUUID, userUUID)
+	if err != nil {
+		if userUUID == nil && errors.Is(err, services.ErrFileNotFound) {
+			return nil, errUnauthorized
+		}
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	if userUUID != nil {
+		if err := r.annotateFiles(ctx, *userUUID, []*domain.File{file}); err != nil {
+			return nil, fmt.Errorf("failed to load file details: %w", err)
+		}
+	}
+
+	return file, nil
 }
 
 func (r *Resolver) DeleteFile(ctx context.Context, id string) (bool, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return false, errors.New("unauthorized")
+		return false, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -276,11 +460,20 @@ func (r *Resolver) DeleteFile(ctx context.Context, id string) (bool, error) {
 
 	fileUUID, err := uuid.Parse(id)
 	if err != nil {
-		return false, fmt.Errorf("invalid file ID")
+		return false, invalidInput("invalid file ID")
+	}
+
+	// Owners and recipients of a DELETE share may remove the file
+	ownerID, err := r.fileSharingService.CheckFileAccess(ctx, fileUUID, userUUID, domain.PermissionDelete)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete file: %w", err)
+	}
+	if err := r.fileLockService.CheckUnlocked(ctx, fileUUID, userUUID); err != nil {
+		return false, fmt.Errorf("failed to delete file: %w", err)
 	}
 
 	// Use the file service to delete the file (handles both RDS and S3 cleanup)
-	err = r.simpleFileService.DeleteFile(ctx, fileUUID, userUUID)
+	err = r.simpleFileService.DeleteFile(ctx, fileUUID, ownerID)
 	if err != nil {
 		return false, fmt.Errorf("failed to delete file: %w", err)
 	}
@@ -288,11 +481,133 @@ func (r *Resolver) DeleteFile(ctx context.Context, id string) (bool, error) {
 	return true, nil
 }
 
+// SetUserQuota changes a user's storage quota. Global admins may change anyone's quota;
+// enterprise OWNERs and ADMINs only their own members'.
+func (r *Resolver) SetUserQuota(ctx context.Context, targetUserID string, quotaBytes int64) (*domain.User, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	callerUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	targetUUID, err := uuid.Parse(targetUserID)
+	if err != nil {
+		return nil, invalidInput("invalid target user ID")
+	}
+
+	caller, err := r.userService.GetUserByID(callerUUID)
+	if err != nil {
+		return nil, errUserNotFound
+	}
+
+	target, err := r.userService.GetUserByID(targetUUID)
+	if err != nil {
+		return nil, errUserNotFound
+	}
+
+	if !canManageUser(caller, target) {
+		return nil, services.ErrPermissionDenied
+	}
+
+	updated, err := r.userService.UpdateQuota(targetUUID, quotaBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set quota: %w", err)
+	}
+
+	ipAddress, userAgent := requestMeta(ctx)
+	r.auditService.LogQuotaUpdate(ctx, callerUUID, targetUUID, target.Email, target.StorageQuota, updated.StorageQuota, ipAddress, userAgent)
+
+	return updated, nil
+}
+
+// RecomputeStorageUsed repairs the recorded storage usage of one user, or of every user when
+// targetUserID is nil, and returns how many users needed correcting; only platform ADMINs may call it
+func (r *Resolver) RecomputeStorageUsed(ctx context.Context, targetUserID *string) (int, error) {
+	if _, ok := ctx.Value("userID").(string); !ok {
+		return 0, errUnauthorized
+	}
+	if isAdmin, _ := ctx.Value("isAdmin").(bool); !isAdmin {
+		return 0, services.ErrPermissionDenied
+	}
+
+	var targetUUID *uuid.UUID
+	if targetUserID != nil {
+		parsed, err := uuid.Parse(*targetUserID)
+		if err != nil {
+			return 0, invalidInput("invalid target user ID")
+		}
+		if _, err := r.userService.GetUserByID(parsed); err != nil {
+			return 0, errUserNotFound
+		}
+		targetUUID = &parsed
+	}
+
+	corrected, err := r.userService.RecomputeStorageUsed(ctx, targetUUID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to recompute storage: %w", err)
+	}
+
+	return int(corrected), nil
+}
+
+// AdminStats returns platform-wide usage with the topConsumers users storing the most (10 by
+// default, at most 100); only platform ADMINs may call it
+func (r *Resolver) AdminStats(ctx context.Context, topConsumers *int) (*domain.SystemStats, error) {
+	if _, ok := ctx.Value("userID").(string); !ok {
+		return nil, errUnauthorized
+	}
+	if isAdmin, _ := ctx.Value("isAdmin").(bool); !isAdmin {
+		return nil, services.ErrPermissionDenied
+	}
+
+	top := 10
+	if topConsumers != nil {
+		if *topConsumers < 0 || *topConsumers > 100 {
+			return nil, invalidInput("topConsumers must be between 0 and 100")
+		}
+		top = *topConsumers
+	}
+
+	stats, err := r.userService.GetSystemStats(ctx, top)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// canManageUser reports whether caller has administrative rights over target. Below platform
+// admins nobody manages themselves, and an enterprise ADMIN does not manage its OWNERs.
+func canManageUser(caller, target *domain.User) bool {
+	if caller.Role == domain.RoleAdmin {
+		return true
+	}
+
+	if caller.ID == target.ID {
+		return false
+	}
+	if caller.EnterpriseRole == nil || caller.EnterpriseID == nil || target.EnterpriseID == nil {
+		return false
+	}
+	if *caller.EnterpriseRole != domain.EnterpriseRoleOwner && *caller.EnterpriseRole != domain.EnterpriseRoleAdmin {
+		return false
+	}
+	if *caller.EnterpriseRole == domain.EnterpriseRoleAdmin && target.EnterpriseRole != nil && *target.EnterpriseRole == domain.EnterpriseRoleOwner {
+		return false
+	}
+
+	return *caller.EnterpriseID == *target.EnterpriseID
+}
+
 // Storage Stats
 func (r *Resolver) GetStorageStats(ctx context.Context) (*domain.StorageStats, error) {
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	id, err := uuid.Parse(userID)
@@ -317,11 +632,13 @@ func (r *Resolver) GetStorageStats(ctx context.Context) (*domain.StorageStats, e
 
 // File Sharing Resolvers
 
-func (r *Resolver) SearchUsers(ctx context.Context, query string, limit *int) ([]*domain.User, error) {
+// SearchUsers pages through the users the caller could share with whose name or email contains
+// query. allUsers searches beyond the caller's enterprise and is reserved for platform ADMINs.
+func (r *Resolver) SearchUsers(ctx context.Context, query string, limit, offset *int, allUsers *bool) (*UserConnection, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -329,25 +646,36 @@ func (r *Resolver) SearchUsers(ctx context.Context, query string, limit *int) ([
 		return nil, errors.New("invalid user ID")
 	}
 
-	// Default limit
-	defaultLimit := 10
-	if limit == nil {
-		limit = &defaultLimit
+	searchAll := allUsers != nil && *allUsers
+	if isAdmin, _ := ctx.Value("isAdmin").(bool); searchAll && !isAdmin {
+		return nil, services.ErrPermissionDenied
+	}
+
+	var pageSize, pageOffset int
+	if limit != nil {
+		pageSize = *limit
+	}
+	if offset != nil && *offset > 0 {
+		pageOffset = *offset
 	}
 
-	users, err := r.fileSharingService.SearchUsers(ctx, query, *limit, userUUID)
+	users, totalCount, err := r.fileSharingService.SearchUsers(ctx, userUUID, query, searchAll, pageSize, pageOffset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search users: %w", err)
 	}
 
-	return users, nil
+	return &UserConnection{
+		Items:       users,
+		TotalCount:  totalCount,
+		HasNextPage: pageOffset+len(users) < totalCount,
+	}, nil
 }
 
 func (r *Resolver) FileShareInfo(ctx context.Context, fileID string) (*FileShareInfo, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -357,7 +685,7 @@ func (r *Resolver) FileShareInfo(ctx context.Context, fileID string) (*FileShare
 
 	fileUUID, err := uuid.Parse(fileID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file ID")
+		return nil, invalidInput("invalid file ID")
 	}
 
 	shareInfo, err := r.fileSharingService.GetFileShareInfo(ctx, fileUUID, userUUID)
@@ -368,19 +696,26 @@ func (r *Resolver) FileShareInfo(ctx context.Context, fileID string) (*FileShare
 	// Convert to GraphQL type
 	var sharedWithUsers []*FileShareWithUser
 	for _, share := range shareInfo.SharedWithUsers {
-		sharedWithUsers = append(sharedWithUsers, &FileShareWithUser{
+		shareWithUser := &FileShareWithUser{
 			ID:                share.ID.String(),
 			SharedWithUserID:  share.SharedWithUserID.String(),
 			PermissionType:    string(share.PermissionType),
 			CreatedAt:         share.CreatedAt,
 			SharedWith:        share.SharedWith,
-		})
+			ExpiresAt:         share.ExpiresAt,
+		}
+		if share.ExpiresAt != nil {
+			remaining := int(time.Until(*share.ExpiresAt).Seconds())
+			shareWithUser.ExpiresInSeconds = &remaining
+		}
+		sharedWithUsers = append(sharedWithUsers, shareWithUser)
 	}
 
 	return &FileShareInfo{
 		IsShared:        shareInfo.IsShared,
 		ShareToken:      shareInfo.ShareToken,
 		ShareURL:        shareInfo.ShareURL,
+		ShortURL:        shareInfo.ShortURL,
 		SharedWithUsers: sharedWithUsers,
 		DownloadCount:   shareInfo.DownloadCount,
 	}, nil
@@ -390,7 +725,7 @@ func (r *Resolver) ShareFileWithUser(ctx context.Context, input ShareFileInput)
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -400,12 +735,12 @@ func (r *Resolver) ShareFileWithUser(ctx context.Context, input ShareFileInput)
 
 	fileUUID, err := uuid.Parse(input.FileID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file ID")
+		return nil, invalidInput("invalid file ID")
 	}
 
 	sharedWithUserUUID, err := uuid.Parse(input.SharedWithUserID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid shared with user ID")
+		return nil, invalidInput("invalid shared with user ID")
 	}
 
 	shareInput := domain.ShareFileInput{
@@ -423,11 +758,66 @@ func (r *Resolver) ShareFileWithUser(ctx context.Context, input ShareFileInput)
 	return fileShare, nil
 }
 
+// ShareFileWithUsers shares one of the user's files with each of input.UserIDs. A user that
+// can't be shared with, including a malformed ID, fails on its own without stopping the others.
+func (r *Resolver) ShareFileWithUsers(ctx context.Context, input ShareFileWithUsersInput) ([]*ShareResult, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(input.FileID)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+	if len(input.UserIDs) == 0 {
+		return nil, invalidInput("at least one user is required")
+	}
+
+	results := make([]*ShareResult, len(input.UserIDs))
+	request := domain.FileShareRequest{
+		FileID:         fileUUID,
+		PermissionType: domain.PermissionType(input.PermissionType),
+		ExpiresAt:      input.ExpiresAt,
+	}
+	// requested[i] is the position in results of request.UserIDs[i]
+	var requested []int
+	for i, id := range input.UserIDs {
+		results[i] = &ShareResult{UserID: id}
+		recipient, err := uuid.Parse(id)
+		if err != nil {
+			results[i].Error = "invalid user ID"
+			continue
+		}
+		request.UserIDs = append(request.UserIDs, recipient)
+		requested = append(requested, i)
+	}
+	if len(request.UserIDs) == 0 {
+		return results, nil
+	}
+
+	shared, err := r.fileSharingService.ShareWithUsers(ctx, request, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to share file: %w", err)
+	}
+	for i, result := range shared {
+		results[requested[i]].Share = result.Share
+		results[requested[i]].Error = result.Error
+	}
+
+	return results, nil
+}
+
 func (r *Resolver) RemoveFileShare(ctx context.Context, fileID, sharedWithUserID string) (bool, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return false, errors.New("unauthorized")
+		return false, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -437,12 +827,12 @@ func (r *Resolver) RemoveFileShare(ctx context.Context, fileID, sharedWithUserID
 
 	fileUUID, err := uuid.Parse(fileID)
 	if err != nil {
-		return false, fmt.Errorf("invalid file ID")
+		return false, invalidInput("invalid file ID")
 	}
 
 	sharedWithUserUUID, err := uuid.Parse(sharedWithUserID)
 	if err != nil {
-		return false, fmt.Errorf("invalid shared with user ID")
+		return false, invalidInput("invalid shared with user ID")
 	}
 
 	err = r.fileSharingService.RemoveUserShare(ctx, fileUUID, sharedWithUserUUID, userUUID)
@@ -453,11 +843,49 @@ func (r *Resolver) RemoveFileShare(ctx context.Context, fileID, sharedWithUserID
 	return true, nil
 }
 
+// ShareFolderWithUser shares one of the user's folders, with everything beneath it, with another
+// user in their enterprise
+func (r *Resolver) ShareFolderWithUser(ctx context.Context, folderID, sharedWithUserID, permission string) (*domain.FolderShare, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	folderUUID, err := uuid.Parse(folderID)
+	if err != nil {
+		return nil, invalidInput("invalid folder ID")
+	}
+
+	sharedWithUserUUID, err := uuid.Parse(sharedWithUserID)
+	if err != nil {
+		return nil, invalidInput("invalid shared with user ID")
+	}
+
+	permissionType := domain.PermissionType(permission)
+	switch permissionType {
+	case domain.PermissionView, domain.PermissionDownload, domain.PermissionEdit, domain.PermissionDelete:
+	default:
+		return nil, invalidInput("invalid permission %q", permission)
+	}
+
+	share, err := r.fileSharingService.ShareFolderWithUser(ctx, folderUUID, sharedWithUserUUID, userUUID, permissionType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to share folder: %w", err)
+	}
+
+	return share, nil
+}
+
 func (r *Resolver) CreatePublicShare(ctx context.Context, fileID string) (*PublicShareResponse, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -467,7 +895,7 @@ func (r *Resolver) CreatePublicShare(ctx context.Context, fileID string) (*Publi
 
 	fileUUID, err := uuid.Parse(fileID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file ID")
+		return nil, invalidInput("invalid file ID")
 	}
 
 	shareResponse, err := r.fileSharingService.CreatePublicShare(ctx, fileUUID, userUUID)
@@ -478,6 +906,8 @@ func (r *Resolver) CreatePublicShare(ctx context.Context, fileID string) (*Publi
 	return &PublicShareResponse{
 		ShareToken: shareResponse.ShareToken,
 		ShareURL:   shareResponse.ShareURL,
+		ShortCode:  shareResponse.ShortCode,
+		ShortURL:   shareResponse.ShortURL,
 	}, nil
 }
 
@@ -485,7 +915,7 @@ func (r *Resolver) RemovePublicShare(ctx context.Context, fileID string) (bool,
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return false, errors.New("unauthorized")
+		return false, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -495,7 +925,7 @@ func (r *Resolver) RemovePublicShare(ctx context.Context, fileID string) (bool,
 
 	fileUUID, err := uuid.Parse(fileID)
 	if err != nil {
-		return false, fmt.Errorf("invalid file ID")
+		return false, invalidInput("invalid file ID")
 	}
 
 	err = r.fileSharingService.RemovePublicShare(ctx, fileUUID, userUUID)
@@ -506,21 +936,13 @@ func (r *Resolver) RemovePublicShare(ctx context.Context, fileID string) (bool,
 	return true, nil
 }
 
-func (r *Resolver) SharedWithMe(ctx context.Context, limit, offset *int) ([]*domain.File, error) {
+// SharedWithMe lists one page of the files shared with the user, only those shared by sharedBy
+// when it is given. A non-empty after cursor takes precedence over offset.
+func (r *Resolver) SharedWithMe(ctx context.Context, limit, offset *int, after, sharedBy *string) (*FileConnection, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
-	}
-
-	// Set default values
-	defaultLimit := 20
-	defaultOffset := 0
-	if limit == nil {
-		limit = &defaultLimit
-	}
-	if offset == nil {
-		offset = &defaultOffset
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -528,22 +950,44 @@ func (r *Resolver) SharedWithMe(ctx context.Context, limit, offset *int) ([]*dom
 		return nil, errors.New("invalid user ID")
 	}
 
-	// Get shared files from database
-	files, err := r.fileSharingService.GetSharedWithMeFiles(ctx, userUUID, *limit, *offset)
+	pageSize, pageOffset := pageBounds(limit, offset)
+	cursor, err := parseFileCursor(after)
+	if err != nil {
+		return nil, err
+	}
+	var sharerUUID *uuid.UUID
+	if sharedBy != nil && *sharedBy != "" {
+		parsed, err := uuid.Parse(*sharedBy)
+		if err != nil {
+			return nil, invalidInput("invalid sharer ID: %w", err)
+		}
+		sharerUUID = &parsed
+	}
+
+	// Get shared files from database, plus one to learn whether another page follows
+	files, err := r.fileSharingService.GetSharedWithMeFiles(ctx, userUUID, sharerUUID, pageSize+1, pageOffset, cursor)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get shared files: %w", err)
 	}
 
-	return files, nil
-}
+	totalCount, err := r.fileSharingService.CountSharedWithMeFiles(ctx, userUUID, sharerUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count shared files: %w", err)
+	}
 
-// Folder Resolvers
+	connection := newFileConnection(files, pageSize, totalCount)
+	if err := r.annotateFiles(ctx, userUUID, connection.Items); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
+	}
+	return connection, nil
+}
 
-func (r *Resolver) CreateFolder(ctx context.Context, input CreateFolderInput) (*domain.Folder, error) {
-	// Get user ID from context
+// SharedFoldersWithMe lists one page of the folders shared directly with the user, most recently
+// shared first
+func (r *Resolver) SharedFoldersWithMe(ctx context.Context, limit, offset *int) ([]*domain.FolderShare, error) {
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -551,29 +995,39 @@ func (r *Resolver) CreateFolder(ctx context.Context, input CreateFolderInput) (*
 		return nil, errors.New("invalid user ID")
 	}
 
-	// Convert parent ID if provided
-	var parentID *uuid.UUID
-	if input.ParentID != nil {
-		parentUUID, err := uuid.Parse(*input.ParentID)
-		if err != nil {
-			return nil, fmt.Errorf("invalid parent ID: %w", err)
-		}
-		parentID = &parentUUID
+	pageSize, pageOffset := pageBounds(limit, offset)
+	shares, err := r.fileSharingService.GetSharedFoldersWithMe(ctx, userUUID, pageSize, pageOffset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared folders: %w", err)
 	}
 
-	folder, err := r.folderService.CreateFolder(ctx, userUUID, input.Name, parentID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create folder: %w", err)
+	return shares, nil
+}
+
+// pageBounds applies the defaults and the 100 item cap to list pagination arguments
+func pageBounds(limit, offset *int) (int, int) {
+	pageSize := 20
+	if limit != nil && *limit > 0 {
+		pageSize = *limit
+	}
+	if pageSize > 100 {
+		pageSize = 100
 	}
 
-	return folder, nil
+	pageOffset := 0
+	if offset != nil && *offset > 0 {
+		pageOffset = *offset
+	}
+
+	return pageSize, pageOffset
 }
 
-func (r *Resolver) GetFolder(ctx context.Context, id string) (*domain.Folder, error) {
-	// Get user ID from context
+// SharedByMe lists one page of the user's files that are shared with other users, each with its
+// recipients, most recently shared first
+func (r *Resolver) SharedByMe(ctx context.Context, limit, offset *int) ([]*SharedFile, error) {
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -581,9 +1035,108 @@ func (r *Resolver) GetFolder(ctx context.Context, id string) (*domain.Folder, er
 		return nil, errors.New("invalid user ID")
 	}
 
-	folderUUID, err := uuid.Parse(id)
+	pageSize, pageOffset := pageBounds(limit, offset)
+	shares, err := r.fileShareRepo.GetSharedByUser(userUUID, pageSize, pageOffset)
 	if err != nil {
-		return nil, fmt.Errorf("invalid folder ID")
+		return nil, fmt.Errorf("failed to get shared files: %w", err)
+	}
+
+	// Shares of one file are adjacent, so a new file starts a new group
+	sharedFiles := []*SharedFile{}
+	var files []*domain.File
+	for _, share := range shares {
+		if len(sharedFiles) == 0 || sharedFiles[len(sharedFiles)-1].File.ID != share.FileID {
+			sharedFiles = append(sharedFiles, &SharedFile{File: share.File})
+			files = append(files, share.File)
+		}
+		group := sharedFiles[len(sharedFiles)-1]
+		group.Shares = append(group.Shares, share)
+	}
+
+	if err := r.annotateFiles(ctx, userUUID, files); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
+	}
+
+	return sharedFiles, nil
+}
+
+// parseFileCursor decodes an optional after cursor; nil and empty mean no cursor
+func parseFileCursor(after *string) (*domain.FileCursor, error) {
+	if after == nil || *after == "" {
+		return nil, nil
+	}
+	return domain.DecodeFileCursor(*after)
+}
+
+// newFileConnection builds a page from files fetched with one extra row beyond pageSize
+func newFileConnection(files []*domain.File, pageSize, totalCount int) *FileConnection {
+	hasNextPage := len(files) > pageSize
+	if hasNextPage {
+		files = files[:pageSize]
+	}
+
+	pageInfo := PageInfo{HasNextPage: hasNextPage}
+	if len(files) > 0 {
+		last := files[len(files)-1]
+		endCursor := domain.FileCursor{UploadDate: last.UploadDate, ID: last.ID}.Encode()
+		pageInfo.EndCursor = &endCursor
+	}
+
+	return &FileConnection{
+		Items:       files,
+		TotalCount:  totalCount,
+		HasNextPage: hasNextPage,
+		PageInfo:    pageInfo,
+	}
+}
+
+// Folder Resolvers
+
+func (r *Resolver) CreateFolder(ctx context.Context, input CreateFolderInput) (*domain.Folder, error) {
+	// Get user ID from context
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	// Convert parent ID if provided
+	var parentID *uuid.UUID
+	if input.ParentID != nil {
+		parentUUID, err := uuid.Parse(*input.ParentID)
+		if err != nil {
+			return nil, invalidInput("invalid parent ID: %w", err)
+		}
+		parentID = &parentUUID
+	}
+
+	folder, err := r.folderService.CreateFolder(ctx, userUUID, input.Name, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+func (r *Resolver) GetFolder(ctx context.Context, id string) (*domain.Folder, error) {
+	// Get user ID from context
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	folderUUID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, invalidInput("invalid folder ID")
 	}
 
 	folder, err := r.folderService.GetFolderByID(ctx, folderUUID, userUUID)
@@ -594,11 +1147,13 @@ func (r *Resolver) GetFolder(ctx context.Context, id string) (*domain.Folder, er
 	return folder, nil
 }
 
-func (r *Resolver) GetMyFolders(ctx context.Context) ([]*domain.Folder, error) {
+// GetMyFolders pages through the caller's root folders, each with its subtree. Without a limit
+// every root folder is returned.
+func (r *Resolver) GetMyFolders(ctx context.Context, limit, offset *int) (*FolderConnection, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -611,14 +1166,84 @@ func (r *Resolver) GetMyFolders(ctx context.Context) ([]*domain.Folder, error) {
 		return nil, fmt.Errorf("failed to get user folders: %w", err)
 	}
 
-	return folders, nil
+	// The tree is built in memory from every folder, so its length is the total count
+	totalCount := len(folders)
+	start := 0
+	if offset != nil && *offset > 0 {
+		start = min(*offset, totalCount)
+	}
+	end := totalCount
+	if limit != nil && *limit >= 0 {
+		end = min(start+*limit, totalCount)
+	}
+
+	return &FolderConnection{
+		Items:       folders[start:end],
+		TotalCount:  totalCount,
+		HasNextPage: end < totalCount,
+	}, nil
+}
+
+// FolderPath returns the breadcrumb for a folder, from the root down to the folder itself
+func (r *Resolver) FolderPath(ctx context.Context, folderID string) ([]*domain.Folder, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	folderUUID, err := uuid.Parse(folderID)
+	if err != nil {
+		return nil, invalidInput("invalid folder ID")
+	}
+
+	path, err := r.folderService.GetFolderPath(ctx, folderUUID, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folder path: %w", err)
+	}
+
+	return path, nil
+}
+
+// FolderStats returns file and storage totals for a folder and its subfolders; a nil or empty
+// folderID covers all of the user's files
+func (r *Resolver) FolderStats(ctx context.Context, folderID *string) (*domain.FolderStats, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	var folderUUID *uuid.UUID
+	if folderID != nil && *folderID != "" {
+		parsed, err := uuid.Parse(*folderID)
+		if err != nil {
+			return nil, invalidInput("invalid folder ID")
+		}
+		folderUUID = &parsed
+	}
+
+	stats, err := r.folderService.GetFolderStats(ctx, folderUUID, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folder stats: %w", err)
+	}
+
+	return stats, nil
 }
 
 func (r *Resolver) GetFolderContents(ctx context.Context, id string) (*domain.Folder, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -631,12 +1256,21 @@ func (r *Resolver) GetFolderContents(ctx context.Context, id string) (*domain.Fo
 	if id != "" {
 		folderUUID, err := uuid.Parse(id)
 		if err != nil {
-			return nil, fmt.Errorf("invalid folder ID")
+			return nil, invalidInput("invalid folder ID")
 		}
 		folderID = &folderUUID
 	}
 
-	folders, files, err := r.folderService.GetFolderContents(ctx, folderID, userUUID)
+	// A folder shared with the user is listed as its owner sees it
+	ownerID := userUUID
+	if folderID != nil {
+		ownerID, err = r.fileSharingService.CheckFolderAccess(ctx, *folderID, userUUID, domain.PermissionView)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	folders, files, err := r.folderService.GetFolderContents(ctx, folderID, ownerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get folder contents: %w", err)
 	}
@@ -644,7 +1278,7 @@ func (r *Resolver) GetFolderContents(ctx context.Context, id string) (*domain.Fo
 	// Create a folder object to return
 	var folder *domain.Folder
 	if folderID != nil {
-		folder, err = r.folderService.GetFolderByID(ctx, *folderID, userUUID)
+		folder, err = r.folderService.GetFolderByID(ctx, *folderID, ownerID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get folder: %w", err)
 		}
@@ -668,7 +1302,7 @@ func (r *Resolver) UpdateFolder(ctx context.Context, id string, input UpdateFold
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -678,11 +1312,27 @@ func (r *Resolver) UpdateFolder(ctx context.Context, id string, input UpdateFold
 
 	folderUUID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid folder ID")
+		return nil, invalidInput("invalid folder ID")
 	}
 
 	var folder *domain.Folder
 
+	var newParentID *uuid.UUID
+	if input.ParentID != nil && *input.ParentID != "" {
+		parentUUID, err := uuid.Parse(*input.ParentID)
+		if err != nil {
+			return nil, invalidInput("invalid parent ID: %w", err)
+		}
+		newParentID = &parentUUID
+	}
+
+	// Reject a bad parent before renaming, so a refused move leaves the folder untouched
+	if input.ParentID != nil {
+		if err := r.folderService.ValidateParent(ctx, folderUUID, userUUID, newParentID); err != nil {
+			return nil, fmt.Errorf("failed to move folder: %w", err)
+		}
+	}
+
 	// Handle rename
 	if input.Name != nil {
 		folder, err = r.folderService.RenameFolder(ctx, folderUUID, userUUID, *input.Name)
@@ -693,15 +1343,6 @@ func (r *Resolver) UpdateFolder(ctx context.Context, id string, input UpdateFold
 
 	// Handle move
 	if input.ParentID != nil {
-		var newParentID *uuid.UUID
-		if *input.ParentID != "" {
-			parentUUID, err := uuid.Parse(*input.ParentID)
-			if err != nil {
-				return nil, fmt.Errorf("invalid parent ID: %w", err)
-			}
-			newParentID = &parentUUID
-		}
-
 		folder, err = r.folderService.MoveFolder(ctx, folderUUID, userUUID, newParentID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to move folder: %w", err)
@@ -723,7 +1364,7 @@ func (r *Resolver) DeleteFolder(ctx context.Context, id string, force *bool) (bo
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return false, errors.New("unauthorized")
+		return false, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -733,7 +1374,7 @@ func (r *Resolver) DeleteFolder(ctx context.Context, id string, force *bool) (bo
 
 	folderUUID, err := uuid.Parse(id)
 	if err != nil {
-		return false, fmt.Errorf("invalid folder ID")
+		return false, invalidInput("invalid folder ID")
 	}
 
 	forceDelete := false
@@ -753,7 +1394,7 @@ func (r *Resolver) MoveFolder(ctx context.Context, id string, newParentID *strin
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -763,14 +1404,14 @@ func (r *Resolver) MoveFolder(ctx context.Context, id string, newParentID *strin
 
 	folderUUID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid folder ID")
+		return nil, invalidInput("invalid folder ID")
 	}
 
 	var parentID *uuid.UUID
 	if newParentID != nil && *newParentID != "" {
 		parentUUID, err := uuid.Parse(*newParentID)
 		if err != nil {
-			return nil, fmt.Errorf("invalid parent ID: %w", err)
+			return nil, invalidInput("invalid parent ID: %w", err)
 		}
 		parentID = &parentUUID
 	}
@@ -787,7 +1428,7 @@ func (r *Resolver) MoveFile(ctx context.Context, id string, folderID *string) (*
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -797,34 +1438,40 @@ func (r *Resolver) MoveFile(ctx context.Context, id string, folderID *string) (*
 
 	fileUUID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file ID")
+		return nil, invalidInput("invalid file ID")
 	}
 
 	var newFolderID *uuid.UUID
 	if folderID != nil && *folderID != "" {
 		folderUUID, err := uuid.Parse(*folderID)
 		if err != nil {
-			return nil, fmt.Errorf("invalid folder ID: %w", err)
+			return nil, invalidInput("invalid folder ID: %w", err)
 		}
 		newFolderID = &folderUUID
 	}
 
+	if err := r.fileLockService.CheckUnlocked(ctx, fileUUID, userUUID); err != nil {
+		return nil, fmt.Errorf("failed to move file: %w", err)
+	}
+
 	// Update file's folder_id in the file service
 	file, err := r.simpleFileService.MoveFile(ctx, fileUUID, userUUID, newFolderID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to move file: %w", err)
 	}
 
+	ipAddress, userAgent := requestMeta(ctx)
+	r.auditService.LogFileMove(ctx, userUUID, file.ID, file.OriginalName, newFolderID, ipAddress, userAgent)
+
 	return file, nil
 }
 
-// File Reference Resolvers
-
-func (r *Resolver) CreateFileReference(ctx context.Context, input CreateFileReferenceInput) (*domain.FileReference, error) {
-	// Get user ID from context
+// CopyFile copies one of the user's files into folderID, or the root when it's empty. The copy
+// shares the original's stored content rather than duplicating it.
+func (r *Resolver) CopyFile(ctx context.Context, fileID string, folderID *string) (*domain.File, error) {
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -832,47 +1479,34 @@ func (r *Resolver) CreateFileReference(ctx context.Context, input CreateFileRefe
 		return nil, errors.New("invalid user ID")
 	}
 
-	fileUUID, err := uuid.Parse(input.FileID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid file ID")
-	}
-
-	folderUUID, err := uuid.Parse(input.FolderID)
+	fileUUID, err := uuid.Parse(fileID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid folder ID")
+		return nil, invalidInput("invalid file ID")
 	}
 
-	var customName *string
-	if input.Name != nil && *input.Name != "" {
-		customName = input.Name
+	var destFolderID *uuid.UUID
+	if folderID != nil && *folderID != "" {
+		folderUUID, err := uuid.Parse(*folderID)
+		if err != nil {
+			return nil, invalidInput("invalid folder ID: %w", err)
+		}
+		destFolderID = &folderUUID
 	}
 
-	// Use the new folder file service that works like file sharing
-	copiedFile, err := r.folderFileService.AddFileToFolder(ctx, fileUUID, folderUUID, userUUID)
+	file, err := r.folderFileService.CopyFile(ctx, fileUUID, userUUID, destFolderID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to add file to folder: %w", err)
-	}
-
-	// Convert the copied file to a FileReference-like structure for compatibility
-	reference := &domain.FileReference{
-		ID:        uuid.New(),  // Generate a new ID for compatibility
-		FolderID:  folderUUID,
-		FileID:    copiedFile.ID, // Use the copied file's ID
-		UserID:    userUUID,
-		Name:      customName,
-		CreatedAt: copiedFile.UploadDate, // Use the copy creation time
-		// The File field will be populated by the GraphQL resolver
+		return nil, fmt.Errorf("failed to copy file: %w", err)
 	}
 
-	return reference, nil
+	return file, nil
 }
 
+// Notification Resolvers
 
-func (r *Resolver) FolderReferences(ctx context.Context, folderID string) ([]*domain.FileReference, error) {
-	// Get user ID from context
+func (r *Resolver) Notifications(ctx context.Context, unreadOnly *bool, limit, offset *int) ([]*domain.Notification, error) {
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -880,92 +1514,93 @@ func (r *Resolver) FolderReferences(ctx context.Context, folderID string) ([]*do
 		return nil, errors.New("invalid user ID")
 	}
 
-	folderUUID, err := uuid.Parse(folderID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid folder ID")
+	limitVal := 20
+	if limit != nil && *limit > 0 {
+		limitVal = *limit
+	}
+	if limitVal > 100 {
+		limitVal = 100
 	}
 
-	// Get files in the folder using the new service
-	files, err := r.folderFileService.GetFolderFiles(ctx, folderUUID, userUUID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get folder files: %w", err)
+	offsetVal := 0
+	if offset != nil && *offset > 0 {
+		offsetVal = *offset
 	}
 
-	// Convert files to FileReference-like objects for compatibility
-	var references []*domain.FileReference
-	for _, file := range files {
-		reference := &domain.FileReference{
-			ID:        uuid.New(),  // Generate a new ID for compatibility
-			FolderID:  folderUUID,
-			FileID:    file.ID,
-			UserID:    userUUID,
-			Name:      nil,  // No custom name for these "references"
-			CreatedAt: file.UploadDate,
-			// The File field will be populated by the GraphQL resolver
-		}
-		references = append(references, reference)
+	notifications, err := r.notificationService.GetNotifications(ctx, userUUID, unreadOnly != nil && *unreadOnly, limitVal, offsetVal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notifications: %w", err)
 	}
 
-	return references, nil
+	return notifications, nil
 }
 
-func (r *Resolver) FileReferences(ctx context.Context, fileID string) ([]*domain.FileReference, error) {
-	// Get user ID from context
+func (r *Resolver) UnreadNotificationCount(ctx context.Context) (int, error) {
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return 0, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
-		return nil, errors.New("invalid user ID")
+		return 0, errors.New("invalid user ID")
 	}
 
-	fileUUID, err := uuid.Parse(fileID)
+	return r.notificationService.CountUnread(ctx, userUUID)
+}
+
+func (r *Resolver) MarkNotificationRead(ctx context.Context, id string) (*domain.Notification, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file ID")
+		return nil, errors.New("invalid user ID")
 	}
 
-	references, err := r.fileReferenceService.GetFileReferences(ctx, userUUID, fileUUID)
+	notificationUUID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file references: %w", err)
+		return nil, invalidInput("invalid notification ID")
 	}
 
-	return references, nil
+	return r.notificationService.MarkRead(ctx, notificationUUID, userUUID)
 }
 
-func (r *Resolver) DeleteFileReference(ctx context.Context, id string) (bool, error) {
-	// Get user ID from context
+// DuplicateFiles reports groups of the user's files that share identical content
+func (r *Resolver) DuplicateFiles(ctx context.Context) ([]*domain.DuplicateGroup, error) {
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return false, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
-		return false, errors.New("invalid user ID")
+		return nil, errors.New("invalid user ID")
 	}
 
-	referenceUUID, err := uuid.Parse(id)
+	groups, err := r.simpleFileService.GetDuplicateFiles(ctx, userUUID)
 	if err != nil {
-		return false, fmt.Errorf("invalid reference ID")
+		return nil, fmt.Errorf("failed to get duplicate files: %w", err)
 	}
 
-	err = r.fileReferenceService.DeleteFileReference(ctx, userUUID, referenceUUID)
-	if err != nil {
-		return false, fmt.Errorf("failed to delete file reference: %w", err)
+	var files []*domain.File
+	for _, group := range groups {
+		files = append(files, group.Files...)
+	}
+	if err := r.annotateFiles(ctx, userUUID, files); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
 	}
 
-	return true, nil
+	return groups, nil
 }
 
-// Audit Log Resolvers
-
-func (r *Resolver) GetAuditLogs(ctx context.Context, limit, offset *int, action *string, status *string) ([]*domain.AuditLog, error) {
-	// Get user ID from context
+// RecentFiles lists the files the user uploaded, downloaded or previewed most recently
+func (r *Resolver) RecentFiles(ctx context.Context, limit *int) ([]*domain.File, error) {
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -973,46 +1608,49 @@ func (r *Resolver) GetAuditLogs(ctx context.Context, limit, offset *int, action
 		return nil, errors.New("invalid user ID")
 	}
 
-	fmt.Printf("DEBUG: GetAuditLogs called with userID=%s, limit=%v, offset=%v\n", userID, limit, offset)
-
-	// Set default values
-	defaultLimit := 50
-	defaultOffset := 0
-	if limit == nil {
-		limit = &defaultLimit
+	pageSize, _ := pageBounds(limit, nil)
+	files, err := r.simpleFileService.GetRecentFiles(ctx, userUUID, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent files: %w", err)
 	}
-	if offset == nil {
-		offset = &defaultOffset
+	if err := r.annotateFiles(ctx, userUUID, files); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
 	}
 
-	// Convert action and status filters
-	var actionFilter *domain.AuditAction
-	var statusFilter *domain.AuditStatus
+	return files, nil
+}
 
-	if action != nil && *action != "" {
-		auditAction := domain.AuditAction(*action)
-		actionFilter = &auditAction
+// PopularFiles lists the user's most downloaded files
+func (r *Resolver) PopularFiles(ctx context.Context, limit *int) ([]*domain.File, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
 	}
 
-	if status != nil && *status != "" {
-		auditStatus := domain.AuditStatus(*status)
-		statusFilter = &auditStatus
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
 	}
 
-	logs, err := r.auditService.GetAuditLogs(ctx, userUUID, *limit, *offset, actionFilter, statusFilter)
+	pageSize, _ := pageBounds(limit, nil)
+	files, err := r.simpleFileService.GetPopularFiles(ctx, userUUID, pageSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get audit logs: %w", err)
+		return nil, fmt.Errorf("failed to get popular files: %w", err)
+	}
+	if err := r.annotateFiles(ctx, userUUID, files); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
 	}
 
-	fmt.Printf("DEBUG: GetAuditLogs returning %d logs\n", len(logs))
-	return logs, nil
+	return files, nil
 }
 
-func (r *Resolver) GetRecentActivity(ctx context.Context, limit *int) ([]*domain.AuditLog, error) {
-	// Get user ID from context
+// Favorite Resolvers
+
+// MyFavorites lists one page of the files the user has starred, most recently starred first
+func (r *Resolver) MyFavorites(ctx context.Context, limit, offset *int) (*FileConnection, error) {
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -1020,25 +1658,874 @@ func (r *Resolver) GetRecentActivity(ctx context.Context, limit *int) ([]*domain
 		return nil, errors.New("invalid user ID")
 	}
 
-	// Set default limit
-	defaultLimit := 20
-	if limit == nil {
-		limit = &defaultLimit
+	pageSize, pageOffset := pageBounds(limit, offset)
+	files, err := r.simpleFileService.GetFavoriteFiles(ctx, userUUID, pageSize, pageOffset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorite files: %w", err)
 	}
 
-	logs, err := r.auditService.GetRecentActivity(ctx, userUUID, *limit)
+	totalCount, err := r.simpleFileService.CountFavoriteFiles(ctx, userUUID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get recent activity: %w", err)
+		return nil, fmt.Errorf("failed to count favorite files: %w", err)
 	}
 
-	return logs, nil
+	return &FileConnection{
+		Items:       files,
+		TotalCount:  totalCount,
+		HasNextPage: pageOffset+len(files) < totalCount,
+	}, nil
 }
 
-func (r *Resolver) GetActivityStats(ctx context.Context, days *int) (map[string]interface{}, error) {
+// EnterpriseFiles pages through files whose name contains search, newest first. An enterprise
+// OWNER or ADMIN searches the files of every member, each with its owner; anyone else gets only
+// their own files.
+func (r *Resolver) EnterpriseFiles(ctx context.Context, search *string, limit, offset *int) (*FileConnection, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	var query string
+	if search != nil {
+		query = *search
+	}
+
+	pageSize, pageOffset := pageBounds(limit, offset)
+	files, totalCount, err := r.enterpriseService.SearchFiles(ctx, userUUID, query, pageSize, pageOffset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search enterprise files: %w", err)
+	}
+	if err := r.annotateFiles(ctx, userUUID, files); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
+	}
+
+	return &FileConnection{
+		Items:       files,
+		TotalCount:  totalCount,
+		HasNextPage: pageOffset+len(files) < totalCount,
+	}, nil
+}
+
+func (r *Resolver) StarFile(ctx context.Context, fileID string) (*domain.File, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	file, err := r.simpleFileService.StarFile(ctx, fileUUID, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to star file: %w", err)
+	}
+
+	return file, nil
+}
+
+func (r *Resolver) UnstarFile(ctx context.Context, fileID string) (bool, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return false, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		return false, invalidInput("invalid file ID")
+	}
+
+	if err := r.simpleFileService.UnstarFile(ctx, fileUUID, userUUID); err != nil {
+		return false, fmt.Errorf("failed to unstar file: %w", err)
+	}
+
+	return true, nil
+}
+
+// RenameFile changes a file's display name; recipients of an EDIT share may rename it too
+func (r *Resolver) RenameFile(ctx context.Context, id, name string) (*domain.File, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	ownerID, err := r.fileSharingService.CheckFileAccess(ctx, fileUUID, userUUID, domain.PermissionEdit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename file: %w", err)
+	}
+	if err := r.fileLockService.CheckUnlocked(ctx, fileUUID, userUUID); err != nil {
+		return nil, fmt.Errorf("failed to rename file: %w", err)
+	}
+
+	file, err := r.simpleFileService.RenameFile(ctx, fileUUID, ownerID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename file: %w", err)
+	}
+	if err := r.annotateFiles(ctx, userUUID, []*domain.File{file}); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
+	}
+
+	return file, nil
+}
+
+// UpdateFile applies the fields set in the input. Editors may change the name, description and
+// tags; visibility and folder belong to the owner. An empty folderId moves the file to the root.
+func (r *Resolver) UpdateFile(ctx context.Context, id string, input UpdateFileInput) (*domain.File, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	ownerID, err := r.fileSharingService.CheckFileAccess(ctx, fileUUID, userUUID, domain.PermissionEdit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update file: %w", err)
+	}
+	if err := r.fileLockService.CheckUnlocked(ctx, fileUUID, userUUID); err != nil {
+		return nil, fmt.Errorf("failed to update file: %w", err)
+	}
+	if ownerID != userUUID && (input.Visibility != nil || input.FolderID != nil) {
+		return nil, fmt.Errorf("failed to update file: %w", services.ErrPermissionDenied)
+	}
+
+	req := domain.FileUpdateRequest{
+		Filename:    input.Filename,
+		Description: input.Description,
+		Tags:        input.Tags,
+		Visibility:  input.Visibility,
+	}
+	moveToRoot := false
+	if input.FolderID != nil {
+		if *input.FolderID == "" {
+			moveToRoot = true
+		} else {
+			folderUUID, err := uuid.Parse(*input.FolderID)
+			if err != nil {
+				return nil, invalidInput("invalid folder ID: %w", err)
+			}
+			req.FolderID = &folderUUID
+		}
+	}
+
+	file, err := r.simpleFileService.UpdateFile(ctx, fileUUID, ownerID, req)
+	if err == nil && moveToRoot {
+		file, err = r.simpleFileService.MoveFile(ctx, fileUUID, ownerID, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update file: %w", err)
+	}
+
+	if input.FolderID != nil {
+		ipAddress, userAgent := requestMeta(ctx)
+		r.auditService.LogFileMove(ctx, userUUID, file.ID, file.OriginalName, file.FolderID, ipAddress, userAgent)
+	}
+	if err := r.annotateFiles(ctx, userUUID, []*domain.File{file}); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
+	}
+
+	return file, nil
+}
+
+// LockFile takes the edit lock on a file the user may edit, or renews the lock they hold. While
+// it lasts, other editors' changes to the file are refused.
+func (r *Resolver) LockFile(ctx context.Context, fileID string) (*domain.FileLock, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+	if r.fileLockService == nil {
+		return nil, errors.New("file locking is not enabled")
+	}
+
+	if _, err := r.fileSharingService.CheckFileAccess(ctx, fileUUID, userUUID, domain.PermissionEdit); err != nil {
+		return nil, fmt.Errorf("failed to lock file: %w", err)
+	}
+
+	lock, err := r.fileLockService.Lock(ctx, fileUUID, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock file: %w", err)
+	}
+
+	return lock, nil
+}
+
+// UnlockFile releases the user's lock on a file. The file's owner may release anyone's lock.
+func (r *Resolver) UnlockFile(ctx context.Context, fileID string) (bool, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return false, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		return false, invalidInput("invalid file ID")
+	}
+	if r.fileLockService == nil {
+		return false, errors.New("file locking is not enabled")
+	}
+
+	ownerID, err := r.fileSharingService.CheckFileAccess(ctx, fileUUID, userUUID, domain.PermissionEdit)
+	if err != nil {
+		return false, fmt.Errorf("failed to unlock file: %w", err)
+	}
+
+	if err := r.fileLockService.Unlock(ctx, fileUUID, userUUID, ownerID == userUUID); err != nil {
+		return false, fmt.Errorf("failed to unlock file: %w", err)
+	}
+
+	return true, nil
+}
+
+// Tag Resolvers
+
+func (r *Resolver) AddTags(ctx context.Context, fileID string, tags []string) (*domain.File, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	// Tags are metadata, so recipients of an EDIT share may change them too
+	ownerID, err := r.fileSharingService.CheckFileAccess(ctx, fileUUID, userUUID, domain.PermissionEdit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add tags: %w", err)
+	}
+	if err := r.fileLockService.CheckUnlocked(ctx, fileUUID, userUUID); err != nil {
+		return nil, fmt.Errorf("failed to add tags: %w", err)
+	}
+
+	file, err := r.simpleFileService.AddTags(ctx, fileUUID, ownerID, tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add tags: %w", err)
+	}
+	if err := r.annotateFiles(ctx, userUUID, []*domain.File{file}); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
+	}
+
+	return file, nil
+}
+
+func (r *Resolver) RemoveTags(ctx context.Context, fileID string, tags []string) (*domain.File, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	// As with AddTags, an EDIT share is enough
+	ownerID, err := r.fileSharingService.CheckFileAccess(ctx, fileUUID, userUUID, domain.PermissionEdit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove tags: %w", err)
+	}
+	if err := r.fileLockService.CheckUnlocked(ctx, fileUUID, userUUID); err != nil {
+		return nil, fmt.Errorf("failed to remove tags: %w", err)
+	}
+
+	file, err := r.simpleFileService.RemoveTags(ctx, fileUUID, ownerID, tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove tags: %w", err)
+	}
+	if err := r.annotateFiles(ctx, userUUID, []*domain.File{file}); err != nil {
+		return nil, fmt.Errorf("failed to load file details: %w", err)
+	}
+
+	return file, nil
+}
+
+// RenameTag renames a tag across all of the user's files and returns how many files changed
+func (r *Resolver) RenameTag(ctx context.Context, oldTag, newTag string) (int, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return 0, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, errors.New("invalid user ID")
+	}
+
+	updated, err := r.simpleFileService.RenameTag(ctx, userUUID, oldTag, newTag)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rename tag: %w", err)
+	}
+
+	return int(updated), nil
+}
+
+// DeleteFilesByTag deletes every file the user owns that carries tag. confirm must be true, so a
+// client can't remove a whole tag's files by accident.
+func (r *Resolver) DeleteFilesByTag(ctx context.Context, tag string, confirm bool) (int, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return 0, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, errors.New("invalid user ID")
+	}
+
+	if strings.TrimSpace(tag) == "" {
+		return 0, invalidInput("tag is required")
+	}
+	if !confirm {
+		return 0, invalidInput("confirm must be true to delete every file with the tag")
+	}
+
+	deleted, err := r.simpleFileService.DeleteFilesByTag(ctx, userUUID, tag)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to delete files by tag: %w", err)
+	}
+
+	return deleted, nil
+}
+
+func (r *Resolver) MyTags(ctx context.Context, prefix *string, limit *int) ([]*domain.TagCount, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	tagPrefix := ""
+	if prefix != nil {
+		tagPrefix = strings.TrimSpace(*prefix)
+	}
+
+	// Autocomplete wants a short list; without a limit every tag is returned
+	tagLimit := 0
+	if limit != nil {
+		tagLimit = *limit
+		if tagLimit < 1 || tagLimit > 100 {
+			tagLimit = 100
+		}
+	}
+
+	tags, err := r.simpleFileService.GetUserTags(ctx, userUUID, tagPrefix, tagLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// File Reference Resolvers
+
+func (r *Resolver) CreateFileReference(ctx context.Context, input CreateFileReferenceInput) (*domain.FileReference, error) {
+	// Get user ID from context
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(input.FileID)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	folderUUID, err := uuid.Parse(input.FolderID)
+	if err != nil {
+		return nil, invalidInput("invalid folder ID")
+	}
+
+	var customName *string
+	if input.Name != nil && *input.Name != "" {
+		customName = input.Name
+	}
+
+	// Use the new folder file service that works like file sharing
+	copiedFile, err := r.folderFileService.AddFileToFolder(ctx, fileUUID, folderUUID, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add file to folder: %w", err)
+	}
+
+	// Convert the copied file to a FileReference-like structure for compatibility
+	reference := &domain.FileReference{
+		ID:        uuid.New(),  // Generate a new ID for compatibility
+		FolderID:  folderUUID,
+		FileID:    copiedFile.ID, // Use the copied file's ID
+		UserID:    userUUID,
+		Name:      customName,
+		CreatedAt: copiedFile.UploadDate, // Use the copy creation time
+		// The File field will be populated by the GraphQL resolver
+	}
+
+	return reference, nil
+}
+
+
+func (r *Resolver) FolderReferences(ctx context.Context, folderID string) ([]*domain.FileReference, error) {
+	// Get user ID from context
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	folderUUID, err := uuid.Parse(folderID)
+	if err != nil {
+		return nil, invalidInput("invalid folder ID")
+	}
+
+	// Get files in the folder using the new service
+	files, err := r.folderFileService.GetFolderFiles(ctx, folderUUID, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folder files: %w", err)
+	}
+
+	// Convert files to FileReference-like objects for compatibility
+	var references []*domain.FileReference
+	for _, file := range files {
+		reference := &domain.FileReference{
+			ID:        uuid.New(),  // Generate a new ID for compatibility
+			FolderID:  folderUUID,
+			FileID:    file.ID,
+			UserID:    userUUID,
+			Name:      nil,  // No custom name for these "references"
+			CreatedAt: file.UploadDate,
+			// The File field will be populated by the GraphQL resolver
+		}
+		references = append(references, reference)
+	}
+
+	return references, nil
+}
+
+func (r *Resolver) FileReferences(ctx context.Context, fileID string) ([]*domain.FileReference, error) {
+	// Get user ID from context
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	references, err := r.fileReferenceService.GetFileReferences(ctx, userUUID, fileUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file references: %w", err)
+	}
+
+	return references, nil
+}
+
+func (r *Resolver) DeleteFileReference(ctx context.Context, id string) (bool, error) {
+	// Get user ID from context
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return false, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, errors.New("invalid user ID")
+	}
+
+	referenceUUID, err := uuid.Parse(id)
+	if err != nil {
+		return false, invalidInput("invalid reference ID")
+	}
+
+	err = r.fileReferenceService.DeleteFileReference(ctx, userUUID, referenceUUID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete file reference: %w", err)
+	}
+
+	return true, nil
+}
+
+// Audit Log Resolvers
+
+func (r *Resolver) GetAuditLogs(ctx context.Context, limit, offset *int, action *string, status *string) ([]*domain.AuditLog, error) {
+	// Get user ID from context
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	// Set default values
+	defaultLimit := 50
+	defaultOffset := 0
+	if limit == nil {
+		limit = &defaultLimit
+	}
+	if offset == nil {
+		offset = &defaultOffset
+	}
+
+	// Convert action and status filters
+	var actionFilter *domain.AuditAction
+	var statusFilter *domain.AuditStatus
+
+	if action != nil && *action != "" {
+		auditAction := domain.AuditAction(*action)
+		actionFilter = &auditAction
+	}
+
+	if status != nil && *status != "" {
+		auditStatus := domain.AuditStatus(*status)
+		statusFilter = &auditStatus
+	}
+
+	logs, err := r.auditService.GetAuditLogs(ctx, userUUID, *limit, *offset, actionFilter, statusFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit logs: %w", err)
+	}
+
+	r.requestLogger(ctx).Debug("Listed audit logs",
+		zap.String("user_id", userID),
+		zap.Int("limit", *limit),
+		zap.Int("offset", *offset),
+		zap.Int("count", len(logs)),
+	)
+	return logs, nil
+}
+
+// FileActivity returns the history of a single file. Only the owner and users the file is
+// shared with may see it.
+func (r *Resolver) FileActivity(ctx context.Context, fileID string, limit, offset *int) ([]*domain.AuditLog, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	if _, err := r.simpleFileService.GetFileByID(ctx, fileUUID, userUUID); err != nil {
+		share, err := r.fileSharingService.GetFileShare(ctx, fileUUID, userUUID)
+		if err != nil || (share.ExpiresAt != nil && share.ExpiresAt.Before(time.Now())) {
+			return nil, services.ErrPermissionDenied
+		}
+	}
+
+	pageLimit, pageOffset := 50, 0
+	if limit != nil {
+		pageLimit = *limit
+	}
+	if offset != nil {
+		pageOffset = *offset
+	}
+
+	logs, err := r.auditService.GetFileActivity(ctx, fileUUID, pageLimit, pageOffset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file activity: %w", err)
+	}
+
+	return logs, nil
+}
+
+// FileAccessHistory returns who downloaded or previewed a file and when, including users it is
+// shared with. Only the file's owner may see it.
+func (r *Resolver) FileAccessHistory(ctx context.Context, fileID string, limit, offset *int) ([]*domain.AuditLog, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	pageLimit, pageOffset := 50, 0
+	if limit != nil {
+		pageLimit = *limit
+	}
+	if offset != nil {
+		pageOffset = *offset
+	}
+	if pageLimit < 1 || pageLimit > 500 || pageOffset < 0 {
+		return nil, invalidInput("limit must be between 1 and 500 and offset not negative")
+	}
+
+	if _, err := r.simpleFileService.GetFileByID(ctx, fileUUID, userUUID); err != nil {
+		return nil, services.ErrPermissionDenied
+	}
+
+	logs, err := r.auditService.GetFileAccessHistory(ctx, fileUUID, pageLimit, pageOffset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file access history: %w", err)
+	}
+
+	return logs, nil
+}
+
+// FileDownloadStats returns a file's downloads and previews per day over the last `days` days,
+// 30 by default. Only the file's owner may see them.
+func (r *Resolver) FileDownloadStats(ctx context.Context, fileID string, days *int) ([]*domain.FileAccessDay, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	fileUUID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, invalidInput("invalid file ID")
+	}
+
+	statDays := 30
+	if days != nil {
+		statDays = *days
+	}
+	if statDays < 1 || statDays > 365 {
+		return nil, invalidInput("days must be between 1 and 365")
+	}
+
+	if _, err := r.simpleFileService.GetFileByID(ctx, fileUUID, userUUID); err != nil {
+		return nil, services.ErrPermissionDenied
+	}
+
+	stats, err := r.simpleFileService.GetDownloadStats(ctx, fileUUID, statDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get download stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// EnterpriseAuditLogs returns the audit logs of every member of an enterprise. Only enterprise
+// owners and admins may see them; enterpriseID defaults to the caller's own enterprise.
+func (r *Resolver) EnterpriseAuditLogs(ctx context.Context, enterpriseID *string, limit, offset *int, action, status, from, to *string) ([]*domain.AuditLog, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	caller, err := r.userService.GetUserByID(userUUID)
+	if err != nil {
+		return nil, errUserNotFound
+	}
+	if caller.EnterpriseID == nil {
+		return nil, errors.New("user does not belong to an enterprise")
+	}
+
+	enterpriseUUID := *caller.EnterpriseID
+	if enterpriseID != nil && *enterpriseID != "" {
+		enterpriseUUID, err = uuid.Parse(*enterpriseID)
+		if err != nil {
+			return nil, invalidInput("invalid enterprise ID")
+		}
+	}
+
+	if enterpriseUUID != *caller.EnterpriseID || caller.EnterpriseRole == nil ||
+		(*caller.EnterpriseRole != domain.EnterpriseRoleOwner && *caller.EnterpriseRole != domain.EnterpriseRoleAdmin) {
+		return nil, services.ErrPermissionDenied
+	}
+
+	filter := domain.AuditLogFilter{}
+	if action != nil && *action != "" {
+		auditAction := domain.AuditAction(*action)
+		filter.Action = &auditAction
+	}
+	if status != nil && *status != "" {
+		auditStatus := domain.AuditStatus(*status)
+		filter.Status = &auditStatus
+	}
+	var fromValue, toValue string
+	if from != nil {
+		fromValue = *from
+	}
+	if to != nil {
+		toValue = *to
+	}
+	if filter.From, err = services.ParseAuditDateBound(fromValue, false); err != nil {
+		return nil, invalidInput("invalid from date")
+	}
+	if filter.To, err = services.ParseAuditDateBound(toValue, true); err != nil {
+		return nil, invalidInput("invalid to date")
+	}
+
+	pageLimit, pageOffset := 50, 0
+	if limit != nil {
+		pageLimit = *limit
+	}
+	if offset != nil {
+		pageOffset = *offset
+	}
+
+	logs, err := r.auditService.GetEnterpriseAuditLogs(ctx, enterpriseUUID, filter, pageLimit, pageOffset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get enterprise audit logs: %w", err)
+	}
+
+	return logs, nil
+}
+
+// SecurityEvents returns the caller's activity flagged as coming from an unusual location
+func (r *Resolver) SecurityEvents(ctx context.Context, limit, offset *int) ([]*domain.AuditLog, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	pageLimit, pageOffset := 20, 0
+	if limit != nil {
+		pageLimit = *limit
+	}
+	if offset != nil {
+		pageOffset = *offset
+	}
+
+	logs, err := r.auditService.GetSecurityEvents(ctx, userUUID, pageLimit, pageOffset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get security events: %w", err)
+	}
+
+	return logs, nil
+}
+
+func (r *Resolver) GetRecentActivity(ctx context.Context, limit *int) ([]*domain.AuditLog, error) {
+	// Get user ID from context
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	// Set default limit
+	defaultLimit := 20
+	if limit == nil {
+		limit = &defaultLimit
+	}
+
+	logs, err := r.auditService.GetRecentActivity(ctx, userUUID, *limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent activity: %w", err)
+	}
+
+	return logs, nil
+}
+
+func (r *Resolver) GetActivityStats(ctx context.Context, days *int) (map[string]interface{}, error) {
 	// Get user ID from context
 	userID, ok := ctx.Value("userID").(string)
 	if !ok {
-		return nil, errors.New("unauthorized")
+		return nil, errUnauthorized
 	}
 
 	userUUID, err := uuid.Parse(userID)
@@ -1065,4 +2552,373 @@ func (r *Resolver) GetActivityStats(ctx context.Context, days *int) (map[string]
 	}
 
 	return result, nil
-}
\ No newline at end of file
+}
+
+// ActivityTimeSeries returns chartable activity counts; days defaults to 7 and bucket to "day"
+func (r *Resolver) ActivityTimeSeries(ctx context.Context, days *int, bucket *string) ([]*domain.ActivityTimePoint, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	defaultDays := 7
+	if days == nil {
+		days = &defaultDays
+	}
+	if *days < 1 || *days > 365 {
+		return nil, invalidInput("days must be between 1 and 365")
+	}
+
+	activityBucket := domain.ActivityBucketDay
+	if bucket != nil {
+		activityBucket = domain.ActivityBucket(*bucket)
+	}
+
+	series, err := r.auditService.GetActivityTimeSeries(ctx, userUUID, *days, activityBucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get activity time series: %w", err)
+	}
+
+	return series, nil
+}
+
+// Enterprise Resolvers
+
+func (r *Resolver) CreateEnterprise(ctx context.Context, input CreateEnterpriseInput) (*domain.Enterprise, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	enterprise, err := r.enterpriseService.CreateEnterprise(ctx, userUUID, domain.CreateEnterpriseRequest{
+		Name:         input.Name,
+		Slug:         input.Slug,
+		Domain:       input.Domain,
+		BillingEmail: input.BillingEmail,
+		Settings:     input.Settings,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create enterprise: %w", err)
+	}
+
+	return enterprise, nil
+}
+
+func (r *Resolver) UpdateEnterprise(ctx context.Context, id string, input UpdateEnterpriseInput) (*domain.Enterprise, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	enterpriseUUID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, invalidInput("invalid enterprise ID")
+	}
+
+	enterprise, err := r.enterpriseService.UpdateEnterprise(ctx, userUUID, enterpriseUUID, domain.UpdateEnterpriseRequest{
+		Name:         input.Name,
+		Domain:       input.Domain,
+		BillingEmail: input.BillingEmail,
+		Settings:     input.Settings,
+		MaxUsers:     input.MaxUsers,
+		StorageQuota: input.StorageQuota,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update enterprise: %w", err)
+	}
+
+	return enterprise, nil
+}
+
+func (r *Resolver) MyEnterprise(ctx context.Context) (*domain.Enterprise, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	enterprise, err := r.enterpriseService.GetUserEnterprise(ctx, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get enterprise: %w", err)
+	}
+
+	return enterprise, nil
+}
+
+func (r *Resolver) InviteUser(ctx context.Context, enterpriseID string, input InviteUserInput) (*domain.EnterpriseInvitation, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	enterpriseUUID, err := uuid.Parse(enterpriseID)
+	if err != nil {
+		return nil, invalidInput("invalid enterprise ID")
+	}
+
+	invitation, err := r.enterpriseService.InviteUser(ctx, userUUID, enterpriseUUID, domain.InviteUserRequest{
+		Email: input.Email,
+		Role:  domain.EnterpriseRole(input.Role),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to invite user: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// AcceptInvitation joins the invitation's enterprise. moveFiles, true unless set, brings the
+// storage of the user's existing files under the enterprise's quota.
+func (r *Resolver) AcceptInvitation(ctx context.Context, token string, moveFiles *bool) (bool, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return false, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, errors.New("invalid user ID")
+	}
+
+	if token == "" {
+		return false, invalidInput("invitation token is required")
+	}
+
+	if _, err := r.enterpriseService.AcceptInvitation(ctx, token, userUUID, moveFiles == nil || *moveFiles); err != nil {
+		return false, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	return true, nil
+}
+
+// RemoveUserFromEnterprise removes a member from the enterprise; filePolicy decides what happens to
+// their files and defaults to reassigning them to the caller
+func (r *Resolver) RemoveUserFromEnterprise(ctx context.Context, enterpriseID, targetUserID string, filePolicy *string) (bool, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return false, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, errors.New("invalid user ID")
+	}
+
+	enterpriseUUID, err := uuid.Parse(enterpriseID)
+	if err != nil {
+		return false, invalidInput("invalid enterprise ID")
+	}
+
+	targetUUID, err := uuid.Parse(targetUserID)
+	if err != nil {
+		return false, invalidInput("invalid target user ID")
+	}
+
+	policy := domain.MemberFilesReassign
+	if filePolicy != nil {
+		policy = domain.MemberFilePolicy(*filePolicy)
+		if policy != domain.MemberFilesReassign && policy != domain.MemberFilesRetain {
+			return false, invalidInput("invalid file policy %q", *filePolicy)
+		}
+	}
+
+	removal, err := r.enterpriseService.RemoveMember(ctx, userUUID, enterpriseUUID, targetUUID, policy)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove user from enterprise: %w", err)
+	}
+
+	ipAddress, userAgent := requestMeta(ctx)
+	r.auditService.LogMemberRemove(ctx, userUUID, removal, ipAddress, userAgent)
+
+	return true, nil
+}
+
+// EnterpriseUsers lists one page of the members of the caller's enterprise with their role and
+// storage usage; only the enterprise's OWNER or ADMIN may list them. query filters by name or email.
+func (r *Resolver) EnterpriseUsers(ctx context.Context, limit, offset *int, query *string) (*UserConnection, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	enterprise, err := r.enterpriseService.GetUserEnterprise(ctx, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get enterprise: %w", err)
+	}
+	if enterprise == nil {
+		return nil, services.ErrPermissionDenied
+	}
+
+	var search string
+	if query != nil {
+		search = *query
+	}
+
+	pageSize, pageOffset := pageBounds(limit, offset)
+	members, totalCount, err := r.enterpriseService.ListMembers(ctx, userUUID, enterprise.ID, search, pageSize, pageOffset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list enterprise users: %w", err)
+	}
+
+	return &UserConnection{
+		Items:       members,
+		TotalCount:  totalCount,
+		HasNextPage: pageOffset+len(members) < totalCount,
+	}, nil
+}
+
+// EnterpriseStats returns stats for the given enterprise, defaulting to the caller's own
+func (r *Resolver) EnterpriseStats(ctx context.Context, id *string) (*domain.EnterpriseStats, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	var enterpriseUUID uuid.UUID
+	if id != nil {
+		enterpriseUUID, err = uuid.Parse(*id)
+		if err != nil {
+			return nil, invalidInput("invalid enterprise ID")
+		}
+	} else {
+		enterprise, err := r.enterpriseService.GetUserEnterprise(ctx, userUUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get enterprise: %w", err)
+		}
+		if enterprise == nil {
+			return nil, errors.New("user does not belong to an enterprise")
+		}
+		enterpriseUUID = enterprise.ID
+	}
+
+	stats, err := r.enterpriseService.GetEnterpriseStats(ctx, userUUID, enterpriseUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get enterprise stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// CreateWebhook registers an endpoint sent the audit events of the caller's enterprise; only its
+// OWNER or ADMIN may. events limits delivery to those audit actions.
+func (r *Resolver) CreateWebhook(ctx context.Context, url string, events []string) (*domain.Webhook, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	actions := make([]domain.AuditAction, len(events))
+	for i, event := range events {
+		actions[i] = domain.AuditAction(event)
+		if !domain.IsKnownAuditAction(actions[i]) {
+			return nil, invalidInput("unknown event %q", event)
+		}
+	}
+
+	webhook, err := r.webhookService.CreateWebhook(ctx, userUUID, url, actions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// Webhooks lists the webhooks of the caller's enterprise
+func (r *Resolver) Webhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return nil, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	webhooks, err := r.webhookService.ListWebhooks(ctx, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook of the caller's enterprise along with its undelivered events
+func (r *Resolver) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	userID, ok := ctx.Value("userID").(string)
+	if !ok {
+		return false, errUnauthorized
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, errors.New("invalid user ID")
+	}
+
+	webhookUUID, err := uuid.Parse(id)
+	if err != nil {
+		return false, invalidInput("invalid webhook ID")
+	}
+
+	if err := r.webhookService.DeleteWebhook(ctx, userUUID, webhookUUID); err != nil {
+		return false, fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	return true, nil
+}
+
+// loadEnterprise attaches the user's enterprise for auth payloads; a lookup failure leaves it empty
+func (r *Resolver) loadEnterprise(ctx context.Context, user *domain.User) {
+	if r.enterpriseService == nil || user.EnterpriseID == nil {
+		return
+	}
+
+	enterprise, err := r.enterpriseService.GetEnterpriseByID(ctx, *user.EnterpriseID)
+	if err == nil {
+		user.Enterprise = enterprise
+	}
+}
+
+// requestMeta returns the client IP and user agent stored on the context by the handler
+func requestMeta(ctx context.Context) (string, string) {
+	return services.RequestMeta(ctx)
+}
@@ -0,0 +1,1566 @@
+//go:build integration
+
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/repository"
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/testutil"
+)
+
+func newTestResolver(db *pgxpool.Pool) *Resolver {
+	auditService := services.NewAuditService(db, zap.NewNop())
+	notificationService := services.NewNotificationService(db, zap.NewNop())
+
+	return NewResolver(
+		services.NewUserService(db),
+		services.NewSimpleFileService(db, nil, nil, auditService, zap.NewNop()),
+		services.NewFileSharingService(db, auditService, notificationService, zap.NewNop()),
+		services.NewFolderService(db, auditService),
+		nil,
+		services.NewFolderFileService(db),
+		auditService,
+		services.NewEnterpriseService(db),
+		notificationService,
+		services.NewWebhookService(db, zap.NewNop()),
+		repository.NewFileRepository(db, zap.NewNop()),
+		repository.NewFileShareRepository(db, zap.NewNop()),
+		repository.NewUserRepository(db, zap.NewNop()),
+		nil,
+	)
+}
+
+func TestResolver_ShareFileWithUserWritesAuditLog(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	recipient := testutil.CreateUser(t, db, "recipient", &enterpriseID)
+	fileID := testutil.CreateFile(t, db, owner, "report.pdf", []byte("audit share "+uuid.NewString()))
+
+	_, err := resolver.ShareFileWithUser(testutil.UserContext(owner), ShareFileInput{
+		FileID:           fileID.String(),
+		SharedWithUserID: recipient.String(),
+		PermissionType:   string(domain.PermissionView),
+	})
+	if err != nil {
+		t.Fatalf("ShareFileWithUser failed: %v", err)
+	}
+
+	rows, err := db.Query(context.Background(), `
+		SELECT action, resource_name FROM audit_logs
+		WHERE user_id = $1 AND resource_id = $2`, owner, fileID)
+	if err != nil {
+		t.Fatalf("Failed to query audit logs: %v", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var action, resourceName string
+		if err := rows.Scan(&action, &resourceName); err != nil {
+			t.Fatalf("Failed to scan audit log: %v", err)
+		}
+		count++
+
+		if action != string(domain.ActionUserShare) {
+			t.Errorf("Expected action to be '%s', got '%s'", domain.ActionUserShare, action)
+		}
+		if resourceName != "report.pdf" {
+			t.Errorf("Expected resource name to be 'report.pdf', got '%s'", resourceName)
+		}
+	}
+
+	if count != 1 {
+		t.Errorf("Expected exactly 1 audit log entry, got %d", count)
+	}
+}
+
+func TestResolver_ShareFileWithUsers(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	first := testutil.CreateUser(t, db, "first", &enterpriseID)
+	second := testutil.CreateUser(t, db, "second", &enterpriseID)
+	fileID := testutil.CreateFile(t, db, owner, "batch.txt", []byte("batch "+uuid.NewString()))
+
+	results, err := resolver.ShareFileWithUsers(testutil.UserContext(owner), ShareFileWithUsersInput{
+		FileID:         fileID.String(),
+		UserIDs:        []string{first.String(), "not-a-uuid", second.String()},
+		PermissionType: string(domain.PermissionView),
+	})
+	if err != nil {
+		t.Fatalf("ShareFileWithUsers failed: %v", err)
+	}
+	if len(results) != 3 || results[0].Share == nil || results[2].Share == nil {
+		t.Fatalf("Expected both valid users shared with, got %+v", results)
+	}
+	if results[1].UserID != "not-a-uuid" || results[1].Share != nil || results[1].Error == "" {
+		t.Errorf("Expected the malformed ID to fail on its own, got %+v", results[1])
+	}
+
+	var logged int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM audit_logs WHERE user_id = $1 AND action = $2", owner, domain.ActionUserShare).Scan(&logged)
+	if logged != 2 {
+		t.Errorf("Expected an audit entry per share, got %d", logged)
+	}
+}
+
+func TestResolver_SharePermissions(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	content := []byte("shared directly " + uuid.NewString())
+	fileID := testutil.CreateFile(t, db, owner, "minutes.txt", content)
+	// A second file of the same content keeps it referenced, so deleting minutes.txt leaves storage alone
+	testutil.CreateFile(t, db, owner, "minutes-copy.txt", content)
+
+	recipient := func(name string, permission domain.PermissionType) context.Context {
+		userID := testutil.CreateUser(t, db, name, nil)
+		testutil.ShareFile(t, db, fileID, owner, userID, permission)
+		return testutil.UserContext(userID)
+	}
+	viewer := recipient("viewer", domain.PermissionView)
+	editor := recipient("editor", domain.PermissionEdit)
+	deleter := recipient("deleter", domain.PermissionDelete)
+
+	t.Run("recipients see the original file", func(t *testing.T) {
+		result, err := resolver.SharedWithMe(viewer, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("SharedWithMe failed: %v", err)
+		}
+		if len(result.Items) != 1 || result.Items[0].ID != fileID {
+			t.Errorf("Expected only the original file %s, got %+v", fileID, result.Items)
+		}
+	})
+
+	t.Run("viewer cannot edit", func(t *testing.T) {
+		if _, err := resolver.AddTags(viewer, fileID.String(), []string{"draft"}); !errors.Is(err, services.ErrPermissionDenied) {
+			t.Errorf("Expected permission denied, got %v", err)
+		}
+	})
+
+	t.Run("editor can edit", func(t *testing.T) {
+		file, err := resolver.AddTags(editor, fileID.String(), []string{"reviewed"})
+		if err != nil {
+			t.Fatalf("AddTags failed: %v", err)
+		}
+		if file.UserID != owner || strings.Join(file.Tags, ",") != "reviewed" {
+			t.Errorf("Expected the owner's file to be tagged, got owner %s with tags %v", file.UserID, file.Tags)
+		}
+	})
+
+	t.Run("viewer cannot rename", func(t *testing.T) {
+		if _, err := resolver.RenameFile(viewer, fileID.String(), "renamed.txt"); !errors.Is(err, services.ErrPermissionDenied) {
+			t.Errorf("Expected permission denied, got %v", err)
+		}
+	})
+
+	t.Run("editor can rename", func(t *testing.T) {
+		file, err := resolver.RenameFile(editor, fileID.String(), "final-minutes.txt")
+		if err != nil {
+			t.Fatalf("RenameFile failed: %v", err)
+		}
+		if file.UserID != owner || file.OriginalName != "final-minutes.txt" {
+			t.Errorf("Expected the owner's file to be renamed, got owner %s named %s", file.UserID, file.OriginalName)
+		}
+	})
+
+	t.Run("editor cannot change visibility", func(t *testing.T) {
+		public := domain.VisibilityPublic
+		if _, err := resolver.UpdateFile(editor, fileID.String(), UpdateFileInput{Visibility: &public}); !errors.Is(err, services.ErrPermissionDenied) {
+			t.Errorf("Expected permission denied, got %v", err)
+		}
+	})
+
+	t.Run("editor can update the description", func(t *testing.T) {
+		description := "Approved"
+		file, err := resolver.UpdateFile(editor, fileID.String(), UpdateFileInput{Description: &description})
+		if err != nil {
+			t.Fatalf("UpdateFile failed: %v", err)
+		}
+		if file.Description == nil || *file.Description != "Approved" {
+			t.Errorf("Expected description 'Approved', got %v", file.Description)
+		}
+	})
+
+	t.Run("editor cannot delete", func(t *testing.T) {
+		if _, err := resolver.DeleteFile(editor, fileID.String()); !errors.Is(err, services.ErrPermissionDenied) {
+			t.Errorf("Expected permission denied, got %v", err)
+		}
+	})
+
+	t.Run("deleter can delete", func(t *testing.T) {
+		if _, err := resolver.DeleteFile(deleter, fileID.String()); err != nil {
+			t.Fatalf("DeleteFile failed: %v", err)
+		}
+		var exists bool
+		db.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM files WHERE id = $1)", fileID).Scan(&exists)
+		if exists {
+			t.Error("Expected the file to be deleted")
+		}
+	})
+}
+
+func TestResolver_OwnerManagesUnservableFiles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	ownerCtx := testutil.UserContext(owner)
+	deleter := testutil.CreateUser(t, db, "deleter", nil)
+
+	for _, status := range []domain.FileStatus{domain.FileStatusPendingScan, domain.FileStatusCorrupted} {
+		t.Run(string(status), func(t *testing.T) {
+			content := []byte("unservable " + string(status) + " " + uuid.NewString())
+			fileID := testutil.CreateFile(t, db, owner, "held.txt", content)
+			// A second file of the same content keeps it referenced, so deleting held.txt leaves storage alone
+			testutil.CreateFile(t, db, owner, "held-copy.txt", content)
+			db.Exec(context.Background(), "UPDATE files SET status = $1 WHERE id = $2", status, fileID)
+			testutil.ShareFile(t, db, fileID, owner, deleter, domain.PermissionDelete)
+
+			if _, err := resolver.DeleteFile(testutil.UserContext(deleter), fileID.String()); errorCode(err) != CodeNotFound {
+				t.Errorf("Expected NOT_FOUND for a recipient deleting a %s file, got %v", status, err)
+			}
+			if _, err := resolver.RenameFile(ownerCtx, fileID.String(), "renamed.txt"); err != nil {
+				t.Errorf("Expected the owner to rename a %s file, got: %v", status, err)
+			}
+			if _, err := resolver.DeleteFile(ownerCtx, fileID.String()); err != nil {
+				t.Fatalf("Expected the owner to delete a %s file, got: %v", status, err)
+			}
+			var exists bool
+			db.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM files WHERE id = $1)", fileID).Scan(&exists)
+			if exists {
+				t.Error("Expected the file to be deleted")
+			}
+		})
+	}
+}
+
+func TestResolver_SharedWithMeFollowsShares(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	recipient := testutil.CreateUser(t, db, "recipient", nil)
+	alice := testutil.CreateUser(t, db, "alice", nil)
+	bob := testutil.CreateUser(t, db, "bob", nil)
+	fromAlice := testutil.CreateFile(t, db, alice, "budget.xlsx", []byte("from alice "+uuid.NewString()))
+	fromBob := testutil.CreateFile(t, db, bob, "roadmap.pdf", []byte("from bob "+uuid.NewString()))
+	testutil.ShareFile(t, db, fromAlice, alice, recipient, domain.PermissionView)
+	testutil.ShareFile(t, db, fromBob, bob, recipient, domain.PermissionView)
+
+	// Named like the copies shares used to make, but nobody shared it
+	testutil.CreateFile(t, db, recipient, "[Shared from alice] budget.xlsx", []byte("own file "+uuid.NewString()))
+	testutil.CreateFile(t, db, alice, "[Shared from alice] plans.txt", []byte("unshared "+uuid.NewString()))
+
+	ctx := testutil.UserContext(recipient)
+	ids := func(sharedBy *string) map[uuid.UUID]bool {
+		t.Helper()
+		result, err := resolver.SharedWithMe(ctx, nil, nil, nil, sharedBy)
+		if err != nil {
+			t.Fatalf("SharedWithMe failed: %v", err)
+		}
+		if result.TotalCount != len(result.Items) {
+			t.Errorf("Expected the total count %d to match the items, got %d", result.TotalCount, len(result.Items))
+		}
+		found := make(map[uuid.UUID]bool)
+		for _, file := range result.Items {
+			found[file.ID] = true
+		}
+		return found
+	}
+
+	t.Run("only shared files are listed", func(t *testing.T) {
+		found := ids(nil)
+		if len(found) != 2 || !found[fromAlice] || !found[fromBob] {
+			t.Errorf("Expected exactly the files alice and bob shared, got %v", found)
+		}
+	})
+
+	t.Run("filtered by sharer", func(t *testing.T) {
+		sharer := alice.String()
+		found := ids(&sharer)
+		if len(found) != 1 || !found[fromAlice] {
+			t.Errorf("Expected only alice's file, got %v", found)
+		}
+
+		malformed := "alice"
+		if _, err := resolver.SharedWithMe(ctx, nil, nil, nil, &malformed); errorCode(err) != CodeValidation {
+			t.Errorf("Expected VALIDATION for a malformed sharer, got %v", err)
+		}
+	})
+}
+
+func TestResolver_SharedByMe(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	ctx := testutil.UserContext(owner)
+	plan := testutil.CreateFile(t, db, owner, "plan.txt", []byte("shared by me plan "+uuid.NewString()))
+	budget := testutil.CreateFile(t, db, owner, "budget.txt", []byte("shared by me budget "+uuid.NewString()))
+	testutil.CreateFile(t, db, owner, "private.txt", []byte("shared by me private "+uuid.NewString()))
+
+	alice := testutil.CreateUser(t, db, "alice", nil)
+	bob := testutil.CreateUser(t, db, "bob", nil)
+	carol := testutil.CreateUser(t, db, "carol", nil)
+	testutil.ShareFile(t, db, plan, owner, alice, domain.PermissionView)
+	testutil.ShareFile(t, db, plan, owner, bob, domain.PermissionEdit)
+	testutil.ShareFile(t, db, budget, owner, carol, domain.PermissionDownload)
+	db.Exec(context.Background(), "UPDATE file_shares SET created_at = NOW() - INTERVAL '1 hour' WHERE file_id = $1", plan)
+	db.Exec(context.Background(), "UPDATE file_shares SET access_count = 3, last_accessed_at = NOW() WHERE shared_with_user_id = $1", carol)
+
+	result, err := resolver.SharedByMe(ctx, nil, nil)
+	if err != nil {
+		t.Fatalf("SharedByMe failed: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 shared files, got %d", len(result))
+	}
+
+	// budget was shared most recently, so it comes first
+	if result[0].File.ID != budget || result[1].File.ID != plan {
+		t.Fatalf("Expected budget then plan, got %s then %s", result[0].File.OriginalName, result[1].File.OriginalName)
+	}
+
+	recipients := func(shares []*domain.FileShare) string {
+		var names []string
+		for _, share := range shares {
+			names = append(names, fmt.Sprintf("%s:%s", share.SharedWith.Name, share.PermissionType))
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+	if got := recipients(result[0].Shares); got != "carol:DOWNLOAD" {
+		t.Errorf("Expected budget to be shared with carol, got %s", got)
+	}
+	if got := recipients(result[1].Shares); got != "alice:VIEW,bob:EDIT" {
+		t.Errorf("Expected plan to be shared with alice and bob, got %s", got)
+	}
+
+	carolShare := result[0].Shares[0]
+	if carolShare.AccessCount != 3 || carolShare.LastAccessedAt == nil {
+		t.Errorf("Expected carol's access count and last access, got %d and %v", carolShare.AccessCount, carolShare.LastAccessedAt)
+	}
+	if carolShare.SharedWith.Email == "" {
+		t.Error("Expected the recipient's email")
+	}
+
+	t.Run("pages by file", func(t *testing.T) {
+		limit, offset := 1, 1
+		page, err := resolver.SharedByMe(ctx, &limit, &offset)
+		if err != nil {
+			t.Fatalf("SharedByMe failed: %v", err)
+		}
+		if len(page) != 1 || page[0].File.ID != plan || len(page[0].Shares) != 2 {
+			t.Errorf("Expected the second page to hold plan with both its shares, got %+v", page)
+		}
+	})
+}
+
+func TestResolver_GetFile(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	recipient := testutil.CreateUser(t, db, "recipient", &enterpriseID)
+	stranger := testutil.CreateUser(t, db, "stranger", nil)
+
+	private := testutil.CreateFile(t, db, owner, "private.txt", []byte("get file private "+uuid.NewString()))
+	testutil.ShareFile(t, db, private, owner, recipient, domain.PermissionView)
+	public := testutil.CreateFile(t, db, owner, "public.txt", []byte("get file public "+uuid.NewString()))
+	db.Exec(context.Background(), "UPDATE files SET visibility = 'PUBLIC' WHERE id = $1", public)
+
+	t.Run("owner", func(t *testing.T) {
+		file, err := resolver.GetFile(testutil.UserContext(owner), private.String())
+		if err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+		if file.ID != private || file.OriginalName != "private.txt" || file.ContentHash == "" {
+			t.Errorf("Expected the full private.txt record, got %+v", file)
+		}
+	})
+
+	t.Run("shared user", func(t *testing.T) {
+		file, err := resolver.GetFile(testutil.UserContext(recipient), private.String())
+		if err != nil {
+			t.Fatalf("Expected the recipient to read a shared file: %v", err)
+		}
+		if file.UserID != owner {
+			t.Errorf("Expected the owner's file, got one owned by %s", file.UserID)
+		}
+	})
+
+	t.Run("public without auth", func(t *testing.T) {
+		file, err := resolver.GetFile(context.Background(), public.String())
+		if err != nil {
+			t.Fatalf("Expected a public file to be readable anonymously: %v", err)
+		}
+		if file.ID != public {
+			t.Errorf("Expected public.txt, got %s", file.ID)
+		}
+		if _, err := resolver.GetFile(testutil.UserContext(stranger), public.String()); err != nil {
+			t.Errorf("Expected a public file to be readable by anyone: %v", err)
+		}
+	})
+
+	t.Run("private denied to stranger", func(t *testing.T) {
+		if _, err := resolver.GetFile(testutil.UserContext(stranger), private.String()); errorCode(err) != CodeNotFound {
+			t.Errorf("Expected NOT_FOUND for a stranger, got %v", err)
+		}
+		if _, err := resolver.GetFile(context.Background(), private.String()); errorCode(err) != CodeUnauthenticated {
+			t.Errorf("Expected UNAUTHENTICATED for an anonymous caller, got %v", err)
+		}
+	})
+
+	t.Run("missing and quarantined files", func(t *testing.T) {
+		if _, err := resolver.GetFile(testutil.UserContext(owner), uuid.NewString()); errorCode(err) != CodeNotFound {
+			t.Errorf("Expected NOT_FOUND for an unknown file, got %v", err)
+		}
+		db.Exec(context.Background(), "UPDATE files SET status = 'INFECTED' WHERE id = $1", public)
+		if _, err := resolver.GetFile(testutil.UserContext(owner), public.String()); errorCode(err) != CodeNotFound {
+			t.Errorf("Expected NOT_FOUND for a quarantined file, got %v", err)
+		}
+	})
+}
+
+func TestResolver_SetUserQuota(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	admin := testutil.CreateUser(t, db, "admin", nil)
+	db.Exec(context.Background(), "UPDATE users SET role = 'ADMIN' WHERE id = $1", admin)
+	regular := testutil.CreateUser(t, db, "regular", nil)
+	target := testutil.CreateUser(t, db, "target", nil)
+	db.Exec(context.Background(), "UPDATE users SET storage_used = 1000, storage_quota = 2000 WHERE id = $1", target)
+
+	t.Run("admin raises quota", func(t *testing.T) {
+		user, err := resolver.SetUserQuota(testutil.UserContext(admin), target.String(), 5000)
+		if err != nil {
+			t.Fatalf("SetUserQuota failed: %v", err)
+		}
+		if user.StorageQuota != 5000 {
+			t.Errorf("Expected quota to be 5000, got %d", user.StorageQuota)
+		}
+
+		var auditCount int
+		db.QueryRow(context.Background(), `
+			SELECT COUNT(*) FROM audit_logs WHERE user_id = $1 AND resource_id = $2 AND action = $3`,
+			admin, target, domain.ActionQuotaUpdate).Scan(&auditCount)
+		if auditCount != 1 {
+			t.Errorf("Expected 1 quota audit entry, got %d", auditCount)
+		}
+	})
+
+	t.Run("non-admin denied", func(t *testing.T) {
+		if _, err := resolver.SetUserQuota(testutil.UserContext(regular), target.String(), 9000); err == nil {
+			t.Error("Expected a non-admin to be denied")
+		}
+	})
+
+	t.Run("enterprise admins manage members but not themselves or owners", func(t *testing.T) {
+		enterpriseID := testutil.CreateEnterprise(t, db)
+		owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+		entAdmin := testutil.CreateUser(t, db, "ent-admin", &enterpriseID)
+		member := testutil.CreateUser(t, db, "member", &enterpriseID)
+		db.Exec(context.Background(), "UPDATE users SET enterprise_role = 'OWNER' WHERE id = $1", owner)
+		db.Exec(context.Background(), "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", entAdmin)
+
+		if _, err := resolver.SetUserQuota(testutil.UserContext(entAdmin), member.String(), 9000); err != nil {
+			t.Errorf("Expected an enterprise ADMIN to manage a member, got: %v", err)
+		}
+		if _, err := resolver.SetUserQuota(testutil.UserContext(entAdmin), entAdmin.String(), 9000); errorCode(err) != CodeForbidden {
+			t.Errorf("Expected FORBIDDEN for an enterprise ADMIN raising their own quota, got %v", err)
+		}
+		if _, err := resolver.SetUserQuota(testutil.UserContext(owner), owner.String(), 9000); errorCode(err) != CodeForbidden {
+			t.Errorf("Expected FORBIDDEN for an OWNER raising their own quota, got %v", err)
+		}
+		if _, err := resolver.SetUserQuota(testutil.UserContext(entAdmin), owner.String(), 9000); errorCode(err) != CodeForbidden {
+			t.Errorf("Expected FORBIDDEN for an enterprise ADMIN changing an OWNER's quota, got %v", err)
+		}
+		if _, err := resolver.SetUserQuota(testutil.UserContext(owner), entAdmin.String(), 9000); err != nil {
+			t.Errorf("Expected an OWNER to manage an enterprise ADMIN, got: %v", err)
+		}
+	})
+
+	t.Run("below current usage rejected", func(t *testing.T) {
+		if _, err := resolver.SetUserQuota(testutil.UserContext(admin), target.String(), 500); err == nil {
+			t.Error("Expected a quota below current usage to be rejected")
+		}
+
+		var quota int64
+		db.QueryRow(context.Background(), "SELECT storage_quota FROM users WHERE id = $1", target).Scan(&quota)
+		if quota != 5000 {
+			t.Errorf("Expected quota to remain 5000, got %d", quota)
+		}
+	})
+}
+
+func TestResolver_GetUsers(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	admin := testutil.CreateUser(t, db, "admin", nil)
+	regular := testutil.CreateUser(t, db, "regular", nil)
+	needle := testutil.CreateUser(t, db, "needle_in_haystack", nil)
+	adminCtx := context.WithValue(testutil.UserContext(admin), "isAdmin", true)
+
+	t.Run("admin lists users", func(t *testing.T) {
+		limit := 2
+		result, err := resolver.GetUsers(adminCtx, &limit, nil, nil)
+		if err != nil {
+			t.Fatalf("GetUsers failed: %v", err)
+		}
+		if len(result.Items) != 2 || result.TotalCount < 3 || !result.HasNextPage {
+			t.Errorf("Expected a first page of 2 with more to come, got %d of %d", len(result.Items), result.TotalCount)
+		}
+		for _, user := range result.Items {
+			if user.PasswordHash != "" {
+				t.Errorf("Expected no password hash for %s", user.Name)
+			}
+		}
+	})
+
+	t.Run("search narrows results", func(t *testing.T) {
+		query := "NEEDLE_IN"
+		result, err := resolver.GetUsers(adminCtx, nil, nil, &query)
+		if err != nil {
+			t.Fatalf("GetUsers failed: %v", err)
+		}
+		if result.TotalCount != 1 || len(result.Items) != 1 || result.Items[0].ID != needle {
+			t.Errorf("Expected only the needle user, got %d", result.TotalCount)
+		}
+	})
+
+	t.Run("regular user forbidden", func(t *testing.T) {
+		ctx := context.WithValue(testutil.UserContext(regular), "isAdmin", false)
+		if _, err := resolver.GetUsers(ctx, nil, nil, nil); errorCode(err) != CodeForbidden {
+			t.Errorf("Expected FORBIDDEN, got %v", err)
+		}
+	})
+}
+
+func TestResolver_SearchUsers(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	otherEnterpriseID := testutil.CreateEnterprise(t, db)
+	searcher := testutil.CreateUser(t, db, "searcher", &enterpriseID)
+	// A run of verified colleagues sharing a name prefix, named so they sort in creation order
+	prefix := "colleague-" + uuid.NewString()[:8]
+	var colleagues []uuid.UUID
+	for i := 0; i < 5; i++ {
+		colleagues = append(colleagues, testutil.CreateUser(t, db, fmt.Sprintf("%s-%d", prefix, i), &enterpriseID))
+	}
+	outsider := testutil.CreateUser(t, db, prefix+"-outsider", &otherEnterpriseID)
+	loner := testutil.CreateUser(t, db, prefix+"-loner", nil)
+	unverified := testutil.CreateUser(t, db, prefix+"-unverified", &enterpriseID)
+	for _, id := range append([]uuid.UUID{outsider, loner}, colleagues...) {
+		db.Exec(ctx, "UPDATE users SET email_verified = true WHERE id = $1", id)
+	}
+
+	userCtx := context.WithValue(testutil.UserContext(searcher), "isAdmin", false)
+
+	t.Run("pages through enterprise members", func(t *testing.T) {
+		limit := 2
+		var seen []uuid.UUID
+		for offset := 0; ; offset += limit {
+			page, err := resolver.SearchUsers(userCtx, prefix, &limit, &offset, nil)
+			if err != nil {
+				t.Fatalf("SearchUsers failed: %v", err)
+			}
+			if page.TotalCount != len(colleagues) {
+				t.Errorf("Expected a total of %d colleagues, got %d", len(colleagues), page.TotalCount)
+			}
+			for _, user := range page.Items {
+				seen = append(seen, user.ID)
+			}
+			if !page.HasNextPage {
+				break
+			}
+			if offset > len(colleagues) {
+				t.Fatal("Expected paging to finish")
+			}
+		}
+		if fmt.Sprint(seen) != fmt.Sprint(colleagues) {
+			t.Errorf("Expected each colleague once in name order, got %v", seen)
+		}
+	})
+
+	t.Run("admins may search every user", func(t *testing.T) {
+		allUsers := true
+		if _, err := resolver.SearchUsers(userCtx, prefix, nil, nil, &allUsers); errorCode(err) != CodeForbidden {
+			t.Errorf("Expected FORBIDDEN for a non-admin, got %v", err)
+		}
+
+		adminCtx := context.WithValue(testutil.UserContext(searcher), "isAdmin", true)
+		result, err := resolver.SearchUsers(adminCtx, prefix, nil, nil, &allUsers)
+		if err != nil {
+			t.Fatalf("SearchUsers failed: %v", err)
+		}
+		if result.TotalCount != len(colleagues)+2 {
+			t.Errorf("Expected colleagues, the outsider and the loner, got %d users", result.TotalCount)
+		}
+		for _, user := range result.Items {
+			if user.ID == unverified {
+				t.Error("Expected unverified users to stay hidden")
+			}
+		}
+	})
+
+	t.Run("users outside an enterprise are told so", func(t *testing.T) {
+		lonerCtx := context.WithValue(testutil.UserContext(loner), "isAdmin", false)
+		_, err := resolver.SearchUsers(lonerCtx, prefix, nil, nil, nil)
+		if !errors.Is(err, services.ErrNoEnterprise) || errorCode(err) != CodeForbidden {
+			t.Errorf("Expected a FORBIDDEN ErrNoEnterprise, got %v", err)
+		}
+	})
+
+	t.Run("handler returns a connection", func(t *testing.T) {
+		response := NewHandler(resolver, nil).processQuery(userCtx,
+			`query SearchUsers($query: String!, $limit: Int, $offset: Int) { searchUsers(query: $query, limit: $limit, offset: $offset) { items { id name email } totalCount hasNextPage } }`,
+			map[string]interface{}{"query": prefix, "limit": float64(3), "offset": float64(3)})
+		if len(response.Errors) > 0 {
+			t.Fatalf("Expected no errors, got %+v", response.Errors)
+		}
+		connection := response.Data.(map[string]interface{})["searchUsers"].(map[string]interface{})
+		if items := connection["items"].([]map[string]interface{}); len(items) != 2 || connection["hasNextPage"] != false {
+			t.Errorf("Expected the last 2 colleagues, got %+v", connection)
+		}
+	})
+}
+
+func TestResolver_FileLocks(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+	resolver.SetFileLockService(services.NewFileLockService(db, time.Minute))
+
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	editor := testutil.CreateUser(t, db, "editor", nil)
+	deleter := testutil.CreateUser(t, db, "deleter", nil)
+	fileID := testutil.CreateFile(t, db, owner, "plan.txt", []byte("locked "+uuid.NewString()))
+	testutil.ShareFile(t, db, fileID, owner, editor, domain.PermissionEdit)
+	testutil.ShareFile(t, db, fileID, owner, deleter, domain.PermissionDelete)
+	ownerCtx, editorCtx := testutil.UserContext(owner), testutil.UserContext(editor)
+
+	lock, err := resolver.LockFile(editorCtx, fileID.String())
+	if err != nil {
+		t.Fatalf("LockFile failed: %v", err)
+	}
+	if lock.HolderID != editor {
+		t.Errorf("Expected the editor to hold the lock, got %+v", lock)
+	}
+
+	t.Run("others cannot change a locked file", func(t *testing.T) {
+		if _, err := resolver.RenameFile(ownerCtx, fileID.String(), "renamed.txt"); errorCode(err) != CodeFileLocked {
+			t.Errorf("Expected FILE_LOCKED, got %v", err)
+		}
+		if _, err := resolver.RenameFile(editorCtx, fileID.String(), "edited.txt"); err != nil {
+			t.Errorf("Expected the holder to rename the file, got %v", err)
+		}
+	})
+
+	t.Run("others cannot delete or move a locked file", func(t *testing.T) {
+		if _, err := resolver.DeleteFile(testutil.UserContext(deleter), fileID.String()); errorCode(err) != CodeFileLocked {
+			t.Errorf("Expected FILE_LOCKED for a DELETE recipient, got %v", err)
+		}
+		if _, err := resolver.DeleteFile(ownerCtx, fileID.String()); errorCode(err) != CodeFileLocked {
+			t.Errorf("Expected FILE_LOCKED for the owner, got %v", err)
+		}
+		if _, err := resolver.MoveFile(ownerCtx, fileID.String(), nil); errorCode(err) != CodeFileLocked {
+			t.Errorf("Expected FILE_LOCKED moving the file, got %v", err)
+		}
+		var exists bool
+		db.QueryRow(context.Background(), "SELECT EXISTS(SELECT 1 FROM files WHERE id = $1)", fileID).Scan(&exists)
+		if !exists {
+			t.Error("Expected the locked file to be kept")
+		}
+	})
+
+	t.Run("files report their lock", func(t *testing.T) {
+		file, err := resolver.GetFile(ownerCtx, fileID.String())
+		if err != nil {
+			t.Fatalf("GetFile failed: %v", err)
+		}
+		if file.Lock == nil || file.Lock.HolderName != "editor" {
+			t.Errorf("Expected the file to report the editor's lock, got %+v", file.Lock)
+		}
+	})
+
+	t.Run("the owner can release anyone's lock", func(t *testing.T) {
+		if released, err := resolver.UnlockFile(ownerCtx, fileID.String()); err != nil || !released {
+			t.Fatalf("Expected the owner to release the lock, got %v, %v", released, err)
+		}
+		if _, err := resolver.RenameFile(ownerCtx, fileID.String(), "renamed.txt"); err != nil {
+			t.Errorf("Expected renaming to work once unlocked, got %v", err)
+		}
+	})
+}
+
+func TestResolver_AdminStats(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+	ctx := context.Background()
+
+	admin := testutil.CreateUser(t, db, "admin", nil)
+	adminCtx := context.WithValue(testutil.UserContext(admin), "isAdmin", true)
+
+	t.Run("admins only", func(t *testing.T) {
+		if _, err := resolver.AdminStats(ctx, nil); errorCode(err) != CodeUnauthenticated {
+			t.Errorf("Expected UNAUTHENTICATED, got %v", err)
+		}
+		userCtx := context.WithValue(testutil.UserContext(admin), "isAdmin", false)
+		if _, err := resolver.AdminStats(userCtx, nil); errorCode(err) != CodeForbidden {
+			t.Errorf("Expected FORBIDDEN, got %v", err)
+		}
+		response := NewHandler(resolver, nil).processQuery(userCtx, `query { adminStats { totalUsers } }`, nil)
+		if len(response.Errors) != 1 || response.Errors[0].Extensions["code"] != CodeForbidden {
+			t.Errorf("Expected the handler to answer FORBIDDEN, got %+v", response)
+		}
+	})
+
+	before, err := resolver.AdminStats(adminCtx, nil)
+	if err != nil {
+		t.Fatalf("AdminStats failed: %v", err)
+	}
+
+	// The same content uploaded by two users is stored once; uploads are spread over the windows
+	hoarder := testutil.CreateUser(t, db, "hoarder", nil)
+	sharer := testutil.CreateUser(t, db, "sharer", nil)
+	shared := []byte("shared " + uuid.NewString())
+	recent := testutil.CreateFile(t, db, hoarder, "shared.txt", shared)
+	lastWeek := testutil.CreateFile(t, db, sharer, "shared-copy.txt", shared)
+	lastMonth := testutil.CreateFile(t, db, hoarder, "old.txt", []byte("old "+uuid.NewString()))
+	ancient := testutil.CreateFile(t, db, sharer, "ancient.txt", []byte("ancient "+uuid.NewString()))
+	for fileID, age := range map[uuid.UUID]string{recent: "1 hour", lastWeek: "3 days", lastMonth: "20 days", ancient: "60 days"} {
+		db.Exec(ctx, "UPDATE files SET upload_date = NOW() - $1::interval WHERE id = $2", age, fileID)
+	}
+	var seededSize, seededStored int64
+	db.QueryRow(ctx, "SELECT SUM(file_size) FROM files WHERE id IN ($1, $2, $3, $4)", recent, lastWeek, lastMonth, ancient).Scan(&seededSize)
+	seededStored = seededSize - int64(len(shared))
+	// Far more than anyone else stores, so the hoarder tops the list
+	db.Exec(ctx, "UPDATE users SET storage_used = $1 WHERE id = $2", int64(1)<<50, hoarder)
+
+	after, err := resolver.AdminStats(adminCtx, nil)
+	if err != nil {
+		t.Fatalf("AdminStats failed: %v", err)
+	}
+
+	for _, check := range []struct {
+		name      string
+		got, want int64
+	}{
+		{"users", int64(after.TotalUsers - before.TotalUsers), 2},
+		{"files", int64(after.TotalFiles - before.TotalFiles), 4},
+		{"storage used", after.StorageUsed - before.StorageUsed, seededSize},
+		{"stored size", after.StoredSize - before.StoredSize, seededStored},
+		{"deduplication savings", after.DeduplicationSavings - before.DeduplicationSavings, int64(len(shared))},
+		{"uploads in 24 hours", int64(after.UploadsLast24Hours - before.UploadsLast24Hours), 1},
+		{"uploads in 7 days", int64(after.UploadsLast7Days - before.UploadsLast7Days), 2},
+		{"uploads in 30 days", int64(after.UploadsLast30Days - before.UploadsLast30Days), 3},
+	} {
+		if check.got != check.want {
+			t.Errorf("Expected %s to grow by %d, got %d", check.name, check.want, check.got)
+		}
+	}
+
+	if len(after.TopStorageConsumers) == 0 || after.TopStorageConsumers[0].ID != hoarder {
+		t.Errorf("Expected the hoarder to top the storage consumers, got %+v", after.TopStorageConsumers)
+	}
+	top := 1
+	if limited, err := resolver.AdminStats(adminCtx, &top); err != nil || len(limited.TopStorageConsumers) != 1 {
+		t.Errorf("Expected a single top consumer, got %v", err)
+	}
+}
+
+func TestHandler_EnterpriseFiles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	handler := NewHandler(newTestResolver(db), nil)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	admin := testutil.CreateUser(t, db, "admin", &enterpriseID)
+	db.Exec(context.Background(), "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", admin)
+	member := testutil.CreateUser(t, db, "colleague", &enterpriseID)
+	marker := "audit-" + uuid.NewString()[:8]
+	testutil.CreateFile(t, db, member, marker+".txt", []byte("audit "+uuid.NewString()))
+
+	query := `query EnterpriseFiles($search: String) { enterpriseFiles(search: $search) { items { id filename user { name } } totalCount hasNextPage } }`
+	search := func(userID uuid.UUID) []map[string]interface{} {
+		response := handler.processQuery(testutil.UserContext(userID), query, map[string]interface{}{"search": marker})
+		if len(response.Errors) > 0 {
+			t.Fatalf("Expected no errors, got %+v", response.Errors)
+		}
+		connection := response.Data.(map[string]interface{})["enterpriseFiles"].(map[string]interface{})
+		return connection["items"].([]map[string]interface{})
+	}
+
+	items := search(admin)
+	if len(items) != 1 {
+		t.Fatalf("Expected the admin to find the colleague's file, got %d", len(items))
+	}
+	if owner, _ := items[0]["user"].(map[string]interface{}); owner == nil || owner["name"] != "colleague" {
+		t.Errorf("Expected the owner's name on the result, got %v", items[0]["user"])
+	}
+
+	other := testutil.CreateUser(t, db, "bystander", &enterpriseID)
+	if items := search(other); len(items) != 0 {
+		t.Errorf("Expected a plain member not to see a colleague's file, got %d", len(items))
+	}
+}
+
+func TestResolver_EnterpriseAuditLogs(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	admin := testutil.CreateUser(t, db, "admin", &enterpriseID)
+	db.Exec(context.Background(), "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", admin)
+	member := testutil.CreateUser(t, db, "member", &enterpriseID)
+	outsider := testutil.CreateUser(t, db, "outsider", nil)
+
+	for _, userID := range []uuid.UUID{admin, member, outsider} {
+		if err := resolver.auditService.LogAction(context.Background(), &domain.AuditLogEntry{
+			UserID:       userID,
+			Action:       domain.ActionFileUpload,
+			Status:       domain.StatusSuccess,
+			ResourceType: "file",
+			ResourceName: "report.txt",
+		}); err != nil {
+			t.Fatalf("Failed to seed audit log: %v", err)
+		}
+	}
+
+	t.Run("admin sees every member", func(t *testing.T) {
+		logs, err := resolver.EnterpriseAuditLogs(testutil.UserContext(admin), nil, nil, nil, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("EnterpriseAuditLogs failed: %v", err)
+		}
+
+		seen := map[uuid.UUID]bool{}
+		for _, log := range logs {
+			seen[log.UserID] = true
+		}
+		if !seen[admin] || !seen[member] {
+			t.Errorf("Expected logs for both admin and member, got %v", seen)
+		}
+		if seen[outsider] {
+			t.Error("Expected logs outside the enterprise to be excluded")
+		}
+	})
+
+	t.Run("date range excludes older logs", func(t *testing.T) {
+		from := time.Now().Add(time.Hour).Format(time.RFC3339)
+		logs, err := resolver.EnterpriseAuditLogs(testutil.UserContext(admin), nil, nil, nil, nil, nil, &from, nil)
+		if err != nil {
+			t.Fatalf("EnterpriseAuditLogs failed: %v", err)
+		}
+		if len(logs) != 0 {
+			t.Errorf("Expected no logs after %s, got %d", from, len(logs))
+		}
+	})
+
+	t.Run("member only sees their own", func(t *testing.T) {
+		if _, err := resolver.EnterpriseAuditLogs(testutil.UserContext(member), nil, nil, nil, nil, nil, nil, nil); err == nil {
+			t.Error("Expected a MEMBER to be denied the enterprise audit log")
+		}
+
+		logs, err := resolver.GetAuditLogs(testutil.UserContext(member), nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("GetAuditLogs failed: %v", err)
+		}
+		for _, log := range logs {
+			if log.UserID != member {
+				t.Errorf("Expected a member to only see their own logs, got one for %s", log.UserID)
+			}
+		}
+		if len(logs) == 0 {
+			t.Error("Expected the member to see their own log")
+		}
+	})
+}
+
+func TestResolver_ListPagination(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	userID := testutil.CreateUser(t, db, "pager", nil)
+	ctx := testutil.UserContext(userID)
+	for i := 0; i < 5; i++ {
+		testutil.CreateFile(t, db, userID, fmt.Sprintf("file-%d.txt", i), []byte(fmt.Sprintf("page %d %s", i, uuid.NewString())))
+	}
+	owner := testutil.CreateUser(t, db, "sharer", nil)
+	for i := 0; i < 3; i++ {
+		sharedID := testutil.CreateFile(t, db, owner, fmt.Sprintf("shared-%d.txt", i), []byte(fmt.Sprintf("shared %d %s", i, uuid.NewString())))
+		testutil.ShareFile(t, db, sharedID, owner, userID, domain.PermissionView)
+		if _, err := resolver.CreateFolder(ctx, CreateFolderInput{Name: fmt.Sprintf("folder-%d", i)}); err != nil {
+			t.Fatalf("CreateFolder failed: %v", err)
+		}
+	}
+
+	limit := 2
+	pages := []struct {
+		offset      int
+		wantItems   int
+		hasNextPage bool
+	}{
+		{0, 2, true},
+		{2, 2, true},
+		{4, 1, false},
+	}
+
+	t.Run("myFiles", func(t *testing.T) {
+		for _, page := range pages {
+			offset := page.offset
+			result, err := resolver.GetMyFiles(ctx, &limit, &offset, nil)
+			if err != nil {
+				t.Fatalf("GetMyFiles failed: %v", err)
+			}
+			if result.TotalCount != 5 {
+				t.Errorf("offset %d: expected total count 5, got %d", offset, result.TotalCount)
+			}
+			if len(result.Items) != page.wantItems || result.HasNextPage != page.hasNextPage {
+				t.Errorf("offset %d: expected %d items and hasNextPage=%v, got %d and %v",
+					offset, page.wantItems, page.hasNextPage, len(result.Items), result.HasNextPage)
+			}
+		}
+	})
+
+	t.Run("sharedWithMe", func(t *testing.T) {
+		for _, page := range []struct {
+			offset      int
+			hasNextPage bool
+		}{{0, true}, {2, false}} {
+			offset := page.offset
+			result, err := resolver.SharedWithMe(ctx, &limit, &offset, nil, nil)
+			if err != nil {
+				t.Fatalf("SharedWithMe failed: %v", err)
+			}
+			if result.TotalCount != 3 {
+				t.Errorf("offset %d: expected total count 3, got %d", offset, result.TotalCount)
+			}
+			if result.HasNextPage != page.hasNextPage {
+				t.Errorf("offset %d: expected hasNextPage=%v, got %v", offset, page.hasNextPage, result.HasNextPage)
+			}
+		}
+	})
+
+	t.Run("myFolders", func(t *testing.T) {
+		for _, page := range []struct {
+			offset      int
+			wantItems   int
+			hasNextPage bool
+		}{{0, 2, true}, {2, 1, false}} {
+			offset := page.offset
+			result, err := resolver.GetMyFolders(ctx, &limit, &offset)
+			if err != nil {
+				t.Fatalf("GetMyFolders failed: %v", err)
+			}
+			if result.TotalCount != 3 {
+				t.Errorf("offset %d: expected total count 3, got %d", offset, result.TotalCount)
+			}
+			if len(result.Items) != page.wantItems || result.HasNextPage != page.hasNextPage {
+				t.Errorf("offset %d: expected %d items and hasNextPage=%v, got %d and %v",
+					offset, page.wantItems, page.hasNextPage, len(result.Items), result.HasNextPage)
+			}
+		}
+
+		all, err := resolver.GetMyFolders(ctx, nil, nil)
+		if err != nil {
+			t.Fatalf("GetMyFolders failed: %v", err)
+		}
+		if len(all.Items) != 3 || all.HasNextPage {
+			t.Errorf("Expected every folder without a limit, got %d (hasNextPage=%v)", len(all.Items), all.HasNextPage)
+		}
+	})
+}
+
+func TestResolver_FileActivity(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	recipient := testutil.CreateUser(t, db, "recipient", nil)
+	stranger := testutil.CreateUser(t, db, "stranger", nil)
+	fileID := testutil.CreateFile(t, db, owner, "history.txt", []byte("history "+uuid.NewString()))
+
+	resolver.auditService.LogFileUpload(context.Background(), owner, fileID, "history.txt", "", "")
+	resolver.auditService.LogFileDownload(context.Background(), owner, fileID, "history.txt", "", "")
+
+	t.Run("owner sees upload and download", func(t *testing.T) {
+		logs, err := resolver.FileActivity(testutil.UserContext(owner), fileID.String(), nil, nil)
+		if err != nil {
+			t.Fatalf("FileActivity failed: %v", err)
+		}
+		if len(logs) != 2 {
+			t.Fatalf("Expected 2 entries, got %d", len(logs))
+		}
+		if logs[0].Action != domain.ActionFileDownload || logs[1].Action != domain.ActionFileUpload {
+			t.Errorf("Expected download then upload, got %s then %s", logs[0].Action, logs[1].Action)
+		}
+		if logs[0].User == nil || logs[0].User.ID != owner || logs[0].User.Email == "" {
+			t.Error("Expected each entry to include the acting user")
+		}
+	})
+
+	t.Run("recipient of a share allowed", func(t *testing.T) {
+		db.Exec(context.Background(), `
+			INSERT INTO file_shares (file_id, shared_by_user_id, shared_with_user_id)
+			VALUES ($1, $2, $3)`, fileID, owner, recipient)
+		if _, err := resolver.FileActivity(testutil.UserContext(recipient), fileID.String(), nil, nil); err != nil {
+			t.Errorf("Expected a share recipient to see the activity, got: %v", err)
+		}
+	})
+
+	t.Run("stranger denied", func(t *testing.T) {
+		if _, err := resolver.FileActivity(testutil.UserContext(stranger), fileID.String(), nil, nil); err == nil {
+			t.Error("Expected a user without a share to be denied")
+		}
+	})
+}
+
+func TestResolver_FileAccessHistory(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	recipient := testutil.CreateUser(t, db, "recipient", nil)
+	fileID := testutil.CreateFile(t, db, owner, "accessed.txt", []byte("accessed "+uuid.NewString()))
+	testutil.ShareFile(t, db, fileID, owner, recipient, domain.PermissionDownload)
+
+	resolver.auditService.LogFileUpload(context.Background(), owner, fileID, "accessed.txt", "", "")
+	resolver.auditService.LogFileDownload(context.Background(), recipient, fileID, "accessed.txt", "203.0.113.7", "")
+	resolver.auditService.LogFileRename(context.Background(), owner, fileID, "accessed.txt", "read.txt", "", "")
+	resolver.auditService.LogFilePreview(context.Background(), owner, fileID, "read.txt", "198.51.100.2", "")
+
+	logs, err := resolver.FileAccessHistory(testutil.UserContext(owner), fileID.String(), nil, nil)
+	if err != nil {
+		t.Fatalf("FileAccessHistory failed: %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("Expected the preview and the download alone, got %d entries", len(logs))
+	}
+	if logs[0].Action != domain.ActionFilePreview || logs[1].Action != domain.ActionFileDownload {
+		t.Errorf("Expected preview then download, got %s then %s", logs[0].Action, logs[1].Action)
+	}
+	download := logs[1]
+	if download.User == nil || download.User.ID != recipient {
+		t.Errorf("Expected the download to name the recipient who made it, got %+v", download.User)
+	}
+	if download.IPAddress != "203.0.113.7" {
+		t.Errorf("Expected the download's address, got %q", download.IPAddress)
+	}
+
+	// The history is the owner's alone, even for a recipient whose own download it lists
+	if _, err := resolver.FileAccessHistory(testutil.UserContext(recipient), fileID.String(), nil, nil); errorCode(err) != CodeForbidden {
+		t.Errorf("Expected a share recipient to be forbidden, got %v", err)
+	}
+	limit := 0
+	if _, err := resolver.FileAccessHistory(testutil.UserContext(owner), fileID.String(), &limit, nil); errorCode(err) != CodeValidation {
+		t.Errorf("Expected an empty page to be rejected, got %v", err)
+	}
+}
+
+func TestResolver_FileDownloadStats(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	recipient := testutil.CreateUser(t, db, "recipient", nil)
+	fileID := testutil.CreateFile(t, db, owner, "stats.txt", []byte("stats "+uuid.NewString()))
+	testutil.ShareFile(t, db, fileID, owner, recipient, domain.PermissionDownload)
+	resolver.simpleFileService.RecordDownload(context.Background(), fileID, &recipient, "")
+
+	stats, err := resolver.FileDownloadStats(testutil.UserContext(owner), fileID.String(), nil)
+	if err != nil {
+		t.Fatalf("FileDownloadStats failed: %v", err)
+	}
+	if len(stats) != 30 || stats[29].Downloads != 1 {
+		t.Errorf("Expected 30 days ending with today's download, got %d days", len(stats))
+	}
+
+	if _, err := resolver.FileDownloadStats(testutil.UserContext(recipient), fileID.String(), nil); errorCode(err) != CodeForbidden {
+		t.Errorf("Expected a share recipient to be forbidden, got %v", err)
+	}
+	days := 0
+	if _, err := resolver.FileDownloadStats(testutil.UserContext(owner), fileID.String(), &days); errorCode(err) != CodeValidation {
+		t.Errorf("Expected an empty range to be rejected, got %v", err)
+	}
+}
+
+func TestResolver_UpdateFolderRejectsCycles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	userID := testutil.CreateUser(t, db, "organizer", nil)
+	ctx := testutil.UserContext(userID)
+
+	parent, err := resolver.CreateFolder(ctx, CreateFolderInput{Name: "parent"})
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	parentID := parent.ID.String()
+	child, err := resolver.CreateFolder(ctx, CreateFolderInput{Name: "child", ParentID: &parentID})
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	childID := child.ID.String()
+	newName := "renamed"
+
+	tests := []struct {
+		name     string
+		parentID string
+	}{
+		{"own parent", parentID},
+		{"into child", childID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolver.UpdateFolder(ctx, parentID, UpdateFolderInput{Name: &newName, ParentID: &tt.parentID})
+			if !errors.Is(err, services.ErrFolderCycle) {
+				t.Fatalf("Expected ErrFolderCycle, got %v", err)
+			}
+			if code := errorCode(err); code != CodeValidation {
+				t.Errorf("Expected code %s, got %s", CodeValidation, code)
+			}
+
+			// The rejected move must not leave the rename half-applied
+			var name string
+			var folderParent *uuid.UUID
+			err = db.QueryRow(ctx, "SELECT name, parent_id FROM folders WHERE id = $1", parent.ID).Scan(&name, &folderParent)
+			if err != nil {
+				t.Fatalf("Failed to read folder: %v", err)
+			}
+			if name != "parent" || folderParent != nil {
+				t.Errorf("Expected folder to stay 'parent' at the root, got '%s' under %v", name, folderParent)
+			}
+		})
+	}
+}
+
+func TestResolver_SharedFolders(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	recipient := testutil.CreateUser(t, db, "recipient", &enterpriseID)
+	ownerCtx := testutil.UserContext(owner)
+	recipientCtx := testutil.UserContext(recipient)
+
+	parent, err := resolver.CreateFolder(ownerCtx, CreateFolderInput{Name: "team"})
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	parentID := parent.ID.String()
+	child, err := resolver.CreateFolder(ownerCtx, CreateFolderInput{Name: "notes", ParentID: &parentID})
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	childID := child.ID.String()
+	fileID := testutil.CreateFile(t, db, owner, "agenda.txt", []byte("shared folder "+uuid.NewString()))
+	if _, err := resolver.MoveFile(ownerCtx, fileID.String(), &childID); err != nil {
+		t.Fatalf("MoveFile failed: %v", err)
+	}
+
+	if _, err := resolver.GetFolderContents(recipientCtx, childID); !errors.Is(err, services.ErrFolderNotFound) {
+		t.Fatalf("Expected the folder to be hidden before sharing, got %v", err)
+	}
+
+	if _, err := resolver.ShareFolderWithUser(ownerCtx, parentID, recipient.String(), "SUPERUSER"); errorCode(err) != CodeValidation {
+		t.Errorf("Expected an unknown permission to be a validation error, got %v", err)
+	}
+	if _, err := resolver.ShareFolderWithUser(ownerCtx, parentID, recipient.String(), string(domain.PermissionDownload)); err != nil {
+		t.Fatalf("ShareFolderWithUser failed: %v", err)
+	}
+
+	shares, err := resolver.SharedFoldersWithMe(recipientCtx, nil, nil)
+	if err != nil {
+		t.Fatalf("SharedFoldersWithMe failed: %v", err)
+	}
+	if len(shares) != 1 || shares[0].FolderID != parent.ID {
+		t.Fatalf("Expected the team folder to be shared, got %+v", shares)
+	}
+
+	// The share on the parent reaches the file in the subfolder
+	file, err := resolver.GetFile(recipientCtx, fileID.String())
+	if err != nil {
+		t.Fatalf("Expected the recipient to read a file in a shared subfolder: %v", err)
+	}
+	if file.UserID != owner {
+		t.Errorf("Expected the owner's file, got one owned by %s", file.UserID)
+	}
+
+	contents, err := resolver.GetFolderContents(recipientCtx, childID)
+	if err != nil {
+		t.Fatalf("GetFolderContents failed: %v", err)
+	}
+	if len(contents.Files) != 1 || contents.Files[0].ID != fileID {
+		t.Errorf("Expected the subfolder to list agenda.txt, got %+v", contents.Files)
+	}
+
+	listed, err := resolver.FilesInFolder(recipientCtx, &childID, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("FilesInFolder failed: %v", err)
+	}
+	if listed.TotalCount != 1 {
+		t.Errorf("Expected 1 file in the shared subfolder, got %d", listed.TotalCount)
+	}
+
+	if _, err := resolver.DeleteFile(recipientCtx, fileID.String()); !errors.Is(err, services.ErrPermissionDenied) {
+		t.Errorf("Expected a DOWNLOAD share not to allow deleting, got %v", err)
+	}
+}
+
+func TestResolver_FilesInFolder(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	userID := testutil.CreateUser(t, db, "browser", nil)
+	ctx := testutil.UserContext(userID)
+
+	folder, err := resolver.CreateFolder(ctx, CreateFolderInput{Name: "docs"})
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+
+	// Root files differ in size so size ordering is distinguishable from name ordering
+	rootNames := []string{"b.txt", "c.txt", "a.txt"}
+	for i, name := range rootNames {
+		fileID := testutil.CreateFile(t, db, userID, name, []byte("root "+name+uuid.NewString()))
+		db.Exec(context.Background(), "UPDATE files SET file_size = $1 WHERE id = $2", (i+1)*10, fileID)
+	}
+	for _, name := range []string{"z.txt", "y.txt"} {
+		fileID := testutil.CreateFile(t, db, userID, name, []byte("in folder "+name+uuid.NewString()))
+		db.Exec(context.Background(), "UPDATE files SET folder_id = $1 WHERE id = $2", folder.ID, fileID)
+	}
+	other := testutil.CreateUser(t, db, "stranger", nil)
+	testutil.CreateFile(t, db, other, "theirs.txt", []byte("other root "+uuid.NewString()))
+
+	names := func(result *FileConnection) string {
+		var names []string
+		for _, file := range result.Items {
+			names = append(names, file.OriginalName)
+		}
+		return strings.Join(names, ",")
+	}
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name      string
+		folderID  *string
+		sortBy    *string
+		sortOrder *string
+		want      string
+	}{
+		{"root by name", nil, str("name"), str("asc"), "a.txt,b.txt,c.txt"},
+		{"root by size descending", nil, str("size"), str("desc"), "a.txt,c.txt,b.txt"},
+		{"folder by name", str(folder.ID.String()), str("name"), str("asc"), "y.txt,z.txt"},
+		{"folder by name descending", str(folder.ID.String()), str("name"), str("desc"), "z.txt,y.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resolver.FilesInFolder(ctx, tt.folderID, nil, nil, tt.sortBy, tt.sortOrder)
+			if err != nil {
+				t.Fatalf("FilesInFolder failed: %v", err)
+			}
+			if got := names(result); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+			if result.TotalCount != len(result.Items) || result.HasNextPage {
+				t.Errorf("Expected a single complete page, got total %d and hasNextPage=%v", result.TotalCount, result.HasNextPage)
+			}
+		})
+	}
+
+	t.Run("paginates", func(t *testing.T) {
+		limit, offset := 2, 0
+		result, err := resolver.FilesInFolder(ctx, nil, &limit, &offset, str("name"), nil)
+		if err != nil {
+			t.Fatalf("FilesInFolder failed: %v", err)
+		}
+		if result.TotalCount != 3 || len(result.Items) != 2 || !result.HasNextPage {
+			t.Errorf("Expected 2 of 3 root files with a next page, got %d of %d (hasNextPage=%v)",
+				len(result.Items), result.TotalCount, result.HasNextPage)
+		}
+	})
+
+	t.Run("rejects unknown sort field", func(t *testing.T) {
+		if _, err := resolver.FilesInFolder(ctx, nil, nil, nil, str("user_id; DROP TABLE files"), nil); err == nil {
+			t.Error("Expected an unknown sort field to be rejected")
+		}
+	})
+}
+
+func TestResolver_CursorPagination(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+
+	userID := testutil.CreateUser(t, db, "scroller", nil)
+	owner := testutil.CreateUser(t, db, "sharer", nil)
+	ctx := testutil.UserContext(userID)
+
+	createOwn := func(name string, content []byte) uuid.UUID {
+		return testutil.CreateFile(t, db, userID, name, content)
+	}
+	createShared := func(name string, content []byte) uuid.UUID {
+		fileID := testutil.CreateFile(t, db, owner, name, content)
+		testutil.ShareFile(t, db, fileID, owner, userID, domain.PermissionView)
+		return fileID
+	}
+
+	// Seed files an hour apart, with a pair sharing a timestamp so the id tie-break is exercised
+	seed := func(prefix string, create func(string, []byte) uuid.UUID) []uuid.UUID {
+		base := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+		var ids []uuid.UUID
+		for i := 0; i < 7; i++ {
+			fileID := create(fmt.Sprintf("%s-%d.txt", prefix, i), []byte(fmt.Sprintf("%s %d %s", prefix, i, uuid.NewString())))
+			uploaded := base.Add(-time.Duration(i) * time.Hour)
+			if i == 4 {
+				uploaded = base.Add(-3 * time.Hour)
+			}
+			db.Exec(context.Background(), "UPDATE files SET upload_date = $1 WHERE id = $2", uploaded, fileID)
+			ids = append(ids, fileID)
+		}
+
+		var ordered []uuid.UUID
+		rows, err := db.Query(context.Background(), `
+			SELECT id FROM files WHERE id = ANY($1) ORDER BY upload_date DESC, id DESC`, ids)
+		if err != nil {
+			t.Fatalf("Failed to load seeded order: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id uuid.UUID
+			rows.Scan(&id)
+			ordered = append(ordered, id)
+		}
+		return ordered
+	}
+
+	lists := []struct {
+		name   string
+		prefix string
+		create func(name string, content []byte) uuid.UUID
+		fetch  func(limit *int, after *string) (*FileConnection, error)
+	}{
+		{"myFiles", "mine", createOwn, func(limit *int, after *string) (*FileConnection, error) {
+			return resolver.GetMyFiles(ctx, limit, nil, after)
+		}},
+		{"sharedWithMe", "shared", createShared, func(limit *int, after *string) (*FileConnection, error) {
+			return resolver.SharedWithMe(ctx, limit, nil, after, nil)
+		}},
+	}
+
+	for _, list := range lists {
+		t.Run(list.name, func(t *testing.T) {
+			db.Exec(context.Background(), "DELETE FROM files WHERE user_id = ANY($1)", []uuid.UUID{userID, owner})
+			want := seed(list.prefix, list.create)
+
+			limit := 3
+			var got []uuid.UUID
+			var after *string
+			for page := 0; ; page++ {
+				result, err := list.fetch(&limit, after)
+				if err != nil {
+					t.Fatalf("page %d: fetch failed: %v", page, err)
+				}
+				for _, file := range result.Items {
+					got = append(got, file.ID)
+				}
+				if !result.PageInfo.HasNextPage {
+					break
+				}
+				if page > len(want) {
+					t.Fatal("Pagination did not terminate")
+				}
+				after = result.PageInfo.EndCursor
+
+				// A new file arriving between pages sorts ahead of the cursor and must not shift the rest
+				list.create(fmt.Sprintf("%s-new-%d.txt", list.prefix, page), []byte(fmt.Sprintf("new %d %s", page, uuid.NewString())))
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("Expected pages to cover %v exactly once in order, got %v", want, got)
+			}
+		})
+	}
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		bad := "garbage"
+		if _, err := resolver.GetMyFiles(ctx, nil, nil, &bad); err == nil {
+			t.Error("Expected an invalid cursor to be rejected")
+		}
+	})
+}
+
+func TestHandler_ErrorCodes(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+	store, _ := testutil.NewLocalStorage(t)
+	resolver.simpleFileService = services.NewSimpleFileService(db, store, nil, nil, zap.NewNop())
+	handler := NewHandler(resolver, nil)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	userID := testutil.CreateUser(t, db, "coded", &enterpriseID)
+	ctx := testutil.UserContext(userID)
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      string
+	}{
+		{"missing file", `query($id: ID!) { file(id: $id) { id filename } }`,
+			map[string]interface{}{"id": uuid.NewString()}, CodeNotFound},
+		{"malformed file ID", `query($id: ID!) { file(id: $id) { id filename } }`,
+			map[string]interface{}{"id": "not-a-uuid"}, CodeValidation},
+		{"missing variable", `query { file { id } }`, nil, CodeValidation},
+		{"quota exceeded", `mutation($input: FileUploadInput!) { uploadFile(input: $input) { id } }`,
+			map[string]interface{}{"input": map[string]interface{}{}}, CodeQuotaExceeded},
+	}
+
+	// Any new content takes the enterprise over its quota
+	db.Exec(context.Background(), "UPDATE enterprises SET storage_quota = 0, storage_used = 0 WHERE id = $1", enterpriseID)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := handler.processQuery(ctx, tt.query, tt.variables)
+			if len(response.Errors) != 1 {
+				t.Fatalf("Expected one error, got %+v", response)
+			}
+			if code := response.Errors[0].Extensions["code"]; code != tt.want {
+				t.Errorf("Expected code %s, got %v (%s)", tt.want, code, response.Errors[0].Message)
+			}
+		})
+	}
+
+	if response := handler.processQuery(context.Background(), `query { file(id: "x") { id } }`, map[string]interface{}{"id": uuid.NewString()}); len(response.Errors) != 1 || response.Errors[0].Extensions["code"] != CodeUnauthenticated {
+		t.Errorf("Expected an anonymous file query to be UNAUTHENTICATED, got %+v", response)
+	}
+}
+
+func TestResolver_RecomputeStorageUsed(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	resolver := newTestResolver(db)
+	ctx := context.Background()
+
+	admin := testutil.CreateUser(t, db, "admin", nil)
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	adminCtx := context.WithValue(testutil.UserContext(admin), "isAdmin", true)
+
+	first := []byte("first file " + uuid.NewString())
+	second := []byte("second file " + uuid.NewString())
+	testutil.CreateFile(t, db, owner, "first.txt", first)
+	testutil.CreateFile(t, db, owner, "second.txt", second)
+	want := int64(len(first) + len(second))
+
+	db.Exec(ctx, "UPDATE users SET storage_used = 999999 WHERE id = $1", owner)
+
+	t.Run("regular user forbidden", func(t *testing.T) {
+		userCtx := context.WithValue(testutil.UserContext(owner), "isAdmin", false)
+		if _, err := resolver.RecomputeStorageUsed(userCtx, nil); errorCode(err) != CodeForbidden {
+			t.Errorf("Expected FORBIDDEN, got %v", err)
+		}
+	})
+
+	t.Run("corrupted user repaired", func(t *testing.T) {
+		target := owner.String()
+		corrected, err := resolver.RecomputeStorageUsed(adminCtx, &target)
+		if err != nil {
+			t.Fatalf("RecomputeStorageUsed failed: %v", err)
+		}
+		if corrected != 1 {
+			t.Errorf("Expected 1 user corrected, got %d", corrected)
+		}
+
+		var storageUsed int64
+		db.QueryRow(ctx, "SELECT storage_used FROM users WHERE id = $1", owner).Scan(&storageUsed)
+		if storageUsed != want {
+			t.Errorf("Expected storage used %d, got %d", want, storageUsed)
+		}
+	})
+
+	t.Run("correct value left alone", func(t *testing.T) {
+		target := owner.String()
+		corrected, err := resolver.RecomputeStorageUsed(adminCtx, &target)
+		if err != nil {
+			t.Fatalf("RecomputeStorageUsed failed: %v", err)
+		}
+		if corrected != 0 {
+			t.Errorf("Expected nothing to correct, got %d", corrected)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		target := uuid.NewString()
+		if _, err := resolver.RecomputeStorageUsed(adminCtx, &target); errorCode(err) != CodeNotFound {
+			t.Errorf("Expected NOT_FOUND, got %v", err)
+		}
+	})
+}
+
+func TestHandler_CopyFile(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	handler := NewHandler(newTestResolver(db), nil)
+
+	userID := testutil.CreateUser(t, db, "copier", nil)
+	other := testutil.CreateUser(t, db, "onlooker", nil)
+	fileID := testutil.CreateFile(t, db, userID, "plan.txt", []byte("copy plan "+uuid.NewString()))
+	folder, err := handler.resolver.folderService.CreateFolder(context.Background(), userID, "Plans", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+
+	const mutation = `mutation($fileId: ID!, $destFolderId: ID) { copyFile(fileId: $fileId, destFolderId: $destFolderId) { id folderId contentHash } }`
+	response := handler.processQuery(testutil.UserContext(userID), mutation,
+		map[string]interface{}{"fileId": fileID.String(), "destFolderId": folder.ID.String()})
+	if len(response.Errors) > 0 {
+		t.Fatalf("copyFile failed: %+v", response.Errors)
+	}
+	copied := response.Data.(map[string]interface{})["copyFile"].(map[string]interface{})
+	if copied["id"] == fileID.String() || copied["folderId"] != folder.ID.String() {
+		t.Errorf("Expected a new file in %s, got %+v", folder.ID, copied)
+	}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		variables map[string]interface{}
+		want      string
+	}{
+		{"someone else's file", testutil.UserContext(other), map[string]interface{}{"fileId": fileID.String()}, CodeForbidden},
+		{"missing file", testutil.UserContext(userID), map[string]interface{}{"fileId": uuid.NewString()}, CodeNotFound},
+		{"malformed folder ID", testutil.UserContext(userID), map[string]interface{}{"fileId": fileID.String(), "destFolderId": "nope"}, CodeValidation},
+		{"missing variable", testutil.UserContext(userID), nil, CodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := handler.processQuery(tt.ctx, mutation, tt.variables)
+			if len(response.Errors) != 1 || response.Errors[0].Extensions["code"] != tt.want {
+				t.Errorf("Expected %s, got %+v", tt.want, response)
+			}
+		})
+	}
+}
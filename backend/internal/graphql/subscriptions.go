@@ -0,0 +1,271 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/services"
+	"lokr-backend/pkg/auth"
+)
+
+// subscriptionProtocol is the graphql-ws WebSocket subprotocol
+// (https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md)
+const subscriptionProtocol = "graphql-transport-ws"
+
+// connectionInitTimeout is how long a client has to send connection_init after connecting
+const connectionInitTimeout = 10 * time.Second
+
+// Close codes defined by the protocol
+const (
+	closeBadRequest       = 4400
+	closeUnauthorized     = 4401
+	closeForbidden        = 4403
+	closeInitTimeout      = 4408
+	closeSubscriberExists = 4409
+	closeTooManyInits     = 4429
+)
+
+// Subscription fields, matched in the subscribe message's query
+const (
+	subscriptionActivityAdded     = "activityAdded"
+	subscriptionNotificationAdded = "notificationAdded"
+)
+
+// subscriptionMessage is one graphql-ws protocol message
+type subscriptionMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// outgoingMessage is a protocol message sent to the client
+type outgoingMessage struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// SubscriptionHandler serves GraphQL subscriptions over WebSocket, pushing the authenticated
+// user's audit events and notifications as they happen
+type SubscriptionHandler struct {
+	userService *services.UserService
+	events      *services.EventBroker
+	jwtManager  *auth.JWTManager
+	logger      *zap.Logger
+}
+
+func NewSubscriptionHandler(userService *services.UserService, events *services.EventBroker, jwtManager *auth.JWTManager, logger *zap.Logger) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		userService: userService,
+		events:      events,
+		jwtManager:  jwtManager,
+		logger:      logger,
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket speaking graphql-transport-ws. The connection
+// is authenticated by the token in the connection_init payload rather than by cookies, so
+// connections from any origin are accepted.
+func (h *SubscriptionHandler) ServeHTTP(c *gin.Context) {
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			for _, protocol := range config.Protocol {
+				if protocol == subscriptionProtocol {
+					config.Protocol = []string{subscriptionProtocol}
+					return nil
+				}
+			}
+			return fmt.Errorf("subprotocol %s is required", subscriptionProtocol)
+		},
+		Handler: h.serve,
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// serve runs one connection: connection_init and its ack, then subscribe, complete and ping
+// messages until the client goes away
+func (h *SubscriptionHandler) serve(ws *websocket.Conn) {
+	defer ws.Close()
+
+	ws.SetReadDeadline(time.Now().Add(connectionInitTimeout))
+	var init subscriptionMessage
+	if err := websocket.JSON.Receive(ws, &init); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			closeWithStatus(ws, closeInitTimeout, "Connection initialisation timeout")
+		}
+		return
+	}
+	if init.Type != "connection_init" {
+		closeWithStatus(ws, closeUnauthorized, "Unauthorized")
+		return
+	}
+	user, err := h.authenticate(init.Payload)
+	if err != nil {
+		closeWithStatus(ws, closeForbidden, "Forbidden")
+		return
+	}
+	ws.SetReadDeadline(time.Time{})
+
+	events, unsubscribe := h.events.Subscribe(user.ID)
+	var mu sync.Mutex
+	active := make(map[string]string) // Operation ID to subscription field
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for event := range events {
+			field, data := subscriptionData(event, user)
+			mu.Lock()
+			var ids []string
+			for id, subscribed := range active {
+				if subscribed == field {
+					ids = append(ids, id)
+				}
+			}
+			mu.Unlock()
+
+			for _, id := range ids {
+				websocket.JSON.Send(ws, outgoingMessage{
+					ID:      id,
+					Type:    "next",
+					Payload: GraphQLResponse{Data: map[string]interface{}{field: data}},
+				})
+			}
+		}
+	}()
+	defer func() {
+		unsubscribe()
+		wg.Wait()
+	}()
+
+	if err := websocket.JSON.Send(ws, outgoingMessage{Type: "connection_ack"}); err != nil {
+		return
+	}
+
+	for {
+		var message subscriptionMessage
+		if err := websocket.JSON.Receive(ws, &message); err != nil {
+			// The client went away or sent something that isn't JSON
+			return
+		}
+
+		switch message.Type {
+		case "ping":
+			websocket.JSON.Send(ws, outgoingMessage{Type: "pong"})
+		case "pong":
+		case "subscribe":
+			var request GraphQLRequest
+			if message.ID == "" || json.Unmarshal(message.Payload, &request) != nil {
+				closeWithStatus(ws, closeBadRequest, "Invalid subscribe message")
+				return
+			}
+			field := subscriptionField(request.Query)
+			if field == "" {
+				websocket.JSON.Send(ws, outgoingMessage{
+					ID:      message.ID,
+					Type:    "error",
+					Payload: []GraphQLError{validationError("Unsupported subscription")},
+				})
+				continue
+			}
+
+			mu.Lock()
+			_, exists := active[message.ID]
+			if !exists {
+				active[message.ID] = field
+			}
+			mu.Unlock()
+			if exists {
+				closeWithStatus(ws, closeSubscriberExists, fmt.Sprintf("Subscriber for %s already exists", message.ID))
+				return
+			}
+		case "complete":
+			mu.Lock()
+			delete(active, message.ID)
+			mu.Unlock()
+		case "connection_init":
+			closeWithStatus(ws, closeTooManyInits, "Too many initialisation requests")
+			return
+		default:
+			closeWithStatus(ws, closeBadRequest, fmt.Sprintf("Unknown message type %q", message.Type))
+			return
+		}
+	}
+}
+
+// authenticate resolves the user from the connection_init payload, which carries the access
+// token as "token" or as an "Authorization" bearer header
+func (h *SubscriptionHandler) authenticate(payload json.RawMessage) (*domain.User, error) {
+	var params struct {
+		Token         string `json:"token"`
+		Authorization string `json:"Authorization"`
+	}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &params); err != nil {
+			return nil, err
+		}
+	}
+	token := params.Token
+	if token == "" {
+		token = strings.TrimPrefix(params.Authorization, "Bearer ")
+	}
+	if token == "" {
+		return nil, errUnauthorized
+	}
+
+	claims, err := h.jwtManager.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return h.userService.GetUserByID(userID)
+}
+
+// subscriptionField returns the subscription a query asks for, or "" when it is unsupported
+func subscriptionField(query string) string {
+	query = strings.TrimSpace(query)
+	if !strings.HasPrefix(query, "subscription") {
+		return ""
+	}
+	for _, field := range []string{subscriptionActivityAdded, subscriptionNotificationAdded} {
+		if strings.Contains(query, field) {
+			return field
+		}
+	}
+	return ""
+}
+
+// subscriptionData returns the subscription field an event is delivered to and its response value.
+// Audit events are the user's own actions, so the user is attached as the acting user.
+func subscriptionData(event services.Event, user *domain.User) (string, interface{}) {
+	if event.Notification != nil {
+		return subscriptionNotificationAdded, notificationToMap(event.Notification)
+	}
+
+	log := *event.AuditLog
+	log.User = user
+	return subscriptionActivityAdded, auditLogsToMaps([]*domain.AuditLog{&log})[0]
+}
+
+// closeWithStatus sends a close frame carrying a protocol close code, which ws.Close can't
+func closeWithStatus(ws *websocket.Conn, code int, reason string) {
+	ws.PayloadType = websocket.CloseFrame
+	ws.Write(append([]byte{byte(code >> 8), byte(code)}, reason...))
+}
@@ -0,0 +1,140 @@
+//go:build integration
+
+package graphql
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"lokr-backend/internal/services"
+	"lokr-backend/internal/testutil"
+	"lokr-backend/pkg/auth"
+)
+
+// receivedMessage is a protocol message as the client sees it
+type receivedMessage struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Payload struct {
+		Data map[string]map[string]interface{} `json:"data"`
+	} `json:"payload"`
+}
+
+// dialSubscriptions connects to the subscription endpoint of server speaking graphql-transport-ws
+func dialSubscriptions(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/graphql", server.URL)
+	if err != nil {
+		t.Fatalf("Invalid WebSocket config: %v", err)
+	}
+	config.Protocol = []string{subscriptionProtocol}
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return ws
+}
+
+// receive reads the next protocol message, failing the test if none arrives
+func receive(t *testing.T, ws *websocket.Conn) receivedMessage {
+	t.Helper()
+
+	var message receivedMessage
+	if err := websocket.JSON.Receive(ws, &message); err != nil {
+		t.Fatalf("Failed to receive a message: %v", err)
+	}
+	return message
+}
+
+func newSubscriptionServer(t *testing.T) (*httptest.Server, *services.AuditService, *auth.JWTManager, *pgxpool.Pool) {
+	t.Helper()
+
+	db := testutil.NewTestDB(t)
+	events := services.NewEventBroker(zap.NewNop())
+	auditService := services.NewAuditService(db, zap.NewNop())
+	auditService.SetEventBroker(events)
+	jwtManager := auth.NewJWTManager("test-secret")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/graphql", NewSubscriptionHandler(services.NewUserService(db), events, jwtManager, zap.NewNop()).ServeHTTP)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, auditService, jwtManager, db
+}
+
+func TestSubscriptions_ActivityAdded(t *testing.T) {
+	server, auditService, jwtManager, db := newSubscriptionServer(t)
+
+	userID := testutil.CreateUser(t, db, "listening", nil)
+	otherID := testutil.CreateUser(t, db, "elsewhere", nil)
+	token, err := jwtManager.GenerateToken(userID.String(), "listening@example.com", "USER")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	ws := dialSubscriptions(t, server)
+	websocket.JSON.Send(ws, map[string]interface{}{"type": "connection_init", "payload": map[string]string{"token": token}})
+	if message := receive(t, ws); message.Type != "connection_ack" {
+		t.Fatalf("Expected connection_ack, got %+v", message)
+	}
+
+	websocket.JSON.Send(ws, map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"payload": map[string]string{"query": "subscription { activityAdded { action resourceName } }"},
+	})
+	// The pong shows the subscription was registered before anything is logged
+	websocket.JSON.Send(ws, map[string]string{"type": "ping"})
+	if message := receive(t, ws); message.Type != "pong" {
+		t.Fatalf("Expected pong, got %+v", message)
+	}
+
+	ctx := context.Background()
+	auditService.LogFileUpload(ctx, otherID, uuid.New(), "not-mine.txt", "", "")
+	auditService.LogFileUpload(ctx, userID, uuid.New(), "live.txt", "", "")
+
+	message := receive(t, ws)
+	if message.Type != "next" || message.ID != "1" {
+		t.Fatalf("Expected next for subscription 1, got %+v", message)
+	}
+	activity := message.Payload.Data["activityAdded"]
+	if activity["action"] != "FILE_UPLOAD" || activity["resourceName"] != "live.txt" {
+		t.Errorf("Expected the user's own upload, got %v", activity)
+	}
+
+	// A second subscription with the same ID closes the connection
+	websocket.JSON.Send(ws, map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"payload": map[string]string{"query": "subscription { notificationAdded { id } }"},
+	})
+	var closed receivedMessage
+	if err := websocket.JSON.Receive(ws, &closed); err == nil {
+		t.Errorf("Expected the connection to close, got %+v", closed)
+	}
+}
+
+func TestSubscriptions_RejectsInvalidToken(t *testing.T) {
+	server, _, _, _ := newSubscriptionServer(t)
+
+	ws := dialSubscriptions(t, server)
+	websocket.JSON.Send(ws, map[string]interface{}{"type": "connection_init", "payload": map[string]string{"token": "not-a-token"}})
+
+	var message receivedMessage
+	if err := websocket.JSON.Receive(ws, &message); err == nil {
+		t.Errorf("Expected the connection to close, got %+v", message)
+	}
+}
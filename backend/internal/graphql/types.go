@@ -30,6 +30,14 @@ type FileUploadInput struct {
 	Visibility  *domain.FileVisibility   `json:"visibility"`
 }
 
+type UpdateFileInput struct {
+	Filename    *string                `json:"filename"`
+	Description *string                `json:"description"`
+	Tags        *[]string              `json:"tags"`
+	Visibility  *domain.FileVisibility `json:"visibility"`
+	FolderID    *string                `json:"folderId"`
+}
+
 type ShareFileInput struct {
 	FileID           string     `json:"fileId"`
 	SharedWithUserID string     `json:"sharedWithUserId"`
@@ -37,6 +45,22 @@ type ShareFileInput struct {
 	ExpiresAt        *time.Time `json:"expiresAt"`
 }
 
+// ShareFileWithUsersInput shares one file with several users at the same permission
+type ShareFileWithUsersInput struct {
+	FileID         string     `json:"fileId"`
+	UserIDs        []string   `json:"userIds"`
+	PermissionType string     `json:"permissionType"`
+	ExpiresAt      *time.Time `json:"expiresAt"`
+}
+
+// ShareResult is the outcome of shareFileWithUsers for one requested user: the share, or Error
+// saying why that user was skipped
+type ShareResult struct {
+	UserID string            `json:"userId"`
+	Share  *domain.FileShare `json:"share"`
+	Error  string            `json:"error,omitempty"`
+}
+
 type CreateFolderInput struct {
 	Name     string  `json:"name"`
 	ParentID *string `json:"parentId"`
@@ -53,7 +77,58 @@ type CreateFileReferenceInput struct {
 	Name     *string `json:"name"`
 }
 
+type CreateEnterpriseInput struct {
+	Name         string                 `json:"name"`
+	Slug         string                 `json:"slug"`
+	Domain       *string                `json:"domain"`
+	BillingEmail *string                `json:"billingEmail"`
+	Settings     map[string]interface{} `json:"settings"`
+}
+
+type UpdateEnterpriseInput struct {
+	Name         *string                `json:"name"`
+	Domain       *string                `json:"domain"`
+	BillingEmail *string                `json:"billingEmail"`
+	Settings     map[string]interface{} `json:"settings"`
+	MaxUsers     *int                   `json:"maxUsers"`
+	StorageQuota *int64                 `json:"storageQuota"`
+}
+
+type InviteUserInput struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
 // GraphQL Response Types
+
+// FileConnection is one page of files along with what the client needs to paginate
+type FileConnection struct {
+	Items       []*domain.File `json:"items"`
+	TotalCount  int            `json:"totalCount"`
+	HasNextPage bool           `json:"hasNextPage"`
+	PageInfo    PageInfo       `json:"pageInfo"`
+}
+
+// PageInfo carries the cursor for the page after this one
+type PageInfo struct {
+	EndCursor   *string `json:"endCursor"`
+	HasNextPage bool    `json:"hasNextPage"`
+}
+
+// FolderConnection is one page of folders along with what the client needs to paginate
+type FolderConnection struct {
+	Items       []*domain.Folder `json:"items"`
+	TotalCount  int              `json:"totalCount"`
+	HasNextPage bool             `json:"hasNextPage"`
+}
+
+// UserConnection is a page of users; TotalCount counts every user matching the query
+type UserConnection struct {
+	Items       []*domain.User `json:"items"`
+	TotalCount  int            `json:"totalCount"`
+	HasNextPage bool           `json:"hasNextPage"`
+}
+
 type AuthPayload struct {
 	Token        string      `json:"token"`
 	RefreshToken string      `json:"refreshToken"`
@@ -127,6 +202,7 @@ type FileShareInfo struct {
 	IsShared        bool                   `json:"isShared"`
 	ShareToken      string                 `json:"shareToken,omitempty"`
 	ShareURL        string                 `json:"shareUrl,omitempty"`
+	ShortURL        string                 `json:"shortUrl,omitempty"`
 	SharedWithUsers []*FileShareWithUser   `json:"sharedWithUsers"`
 	DownloadCount   int                    `json:"downloadCount"`
 }
@@ -137,9 +213,22 @@ type FileShareWithUser struct {
 	PermissionType   string        `json:"permission_type"`
 	CreatedAt        time.Time     `json:"created_at"`
 	SharedWith       *domain.User  `json:"shared_with"`
+
+	// ExpiresAt and ExpiresInSeconds are nil for a share that never expires
+	ExpiresAt        *time.Time `json:"expires_at"`
+	ExpiresInSeconds *int       `json:"expires_in_seconds"`
+}
+
+// SharedFile is one of the user's files with the shares giving other users access to it
+type SharedFile struct {
+	File   *domain.File        `json:"file"`
+	Shares []*domain.FileShare `json:"shares"`
 }
 
 type PublicShareResponse struct {
 	ShareToken string `json:"shareToken"`
 	ShareURL   string `json:"shareUrl"`
+	// ShortCode and ShortURL are empty unless short links are enabled
+	ShortCode string `json:"shortCode,omitempty"`
+	ShortURL  string `json:"shortUrl,omitempty"`
 }
\ No newline at end of file
@@ -25,12 +25,12 @@ func NewFileContentRepository(db *pgxpool.Pool, logger *zap.Logger) *FileContent
 
 func (r *FileContentRepository) Create(content *domain.FileContent) error {
 	query := `
-		INSERT INTO file_contents (content_hash, file_path, file_size, reference_count, enterprise_id, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`
+		INSERT INTO file_contents (content_hash, hash_algorithm, file_path, file_size, reference_count, enterprise_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
 	ctx := context.Background()
 	_, err := r.db.Exec(ctx, query,
-		content.ContentHash, content.FilePath, content.FileSize, content.ReferenceCount,
+		content.ContentHash, content.HashAlgorithm, content.FilePath, content.FileSize, content.ReferenceCount,
 		content.EnterpriseID, content.CreatedAt)
 
 	if err != nil {
@@ -43,7 +43,7 @@ func (r *FileContentRepository) Create(content *domain.FileContent) error {
 
 func (r *FileContentRepository) GetByHash(hash string) (*domain.FileContent, error) {
 	query := `
-		SELECT content_hash, file_path, file_size, reference_count, enterprise_id, created_at
+		SELECT content_hash, hash_algorithm, file_path, file_size, reference_count, enterprise_id, created_at
 		FROM file_contents WHERE content_hash = $1`
 
 	content := &domain.FileContent{}
@@ -51,7 +51,7 @@ func (r *FileContentRepository) GetByHash(hash string) (*domain.FileContent, err
 	row := r.db.QueryRow(ctx, query, hash)
 
 	err := row.Scan(
-		&content.ContentHash, &content.FilePath, &content.FileSize, &content.ReferenceCount,
+		&content.ContentHash, &content.HashAlgorithm, &content.FilePath, &content.FileSize, &content.ReferenceCount,
 		&content.EnterpriseID, &content.CreatedAt)
 
 	if err != nil {
@@ -124,7 +124,7 @@ func (r *FileContentRepository) Delete(hash string) error {
 
 func (r *FileContentRepository) GetOrphaned() ([]*domain.FileContent, error) {
 	query := `
-		SELECT content_hash, file_path, file_size, reference_count, enterprise_id, created_at
+		SELECT content_hash, hash_algorithm, file_path, file_size, reference_count, enterprise_id, created_at
 		FROM file_contents
 		WHERE reference_count = 0`
 
@@ -140,7 +140,7 @@ func (r *FileContentRepository) GetOrphaned() ([]*domain.FileContent, error) {
 	for rows.Next() {
 		content := &domain.FileContent{}
 		err := rows.Scan(
-			&content.ContentHash, &content.FilePath, &content.FileSize, &content.ReferenceCount,
+			&content.ContentHash, &content.HashAlgorithm, &content.FilePath, &content.FileSize, &content.ReferenceCount,
 			&content.EnterpriseID, &content.CreatedAt)
 
 		if err != nil {
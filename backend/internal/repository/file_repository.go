@@ -401,4 +401,83 @@ func (r *FileRepository) GetSharedWithUser(userID uuid.UUID, limit, offset int)
 	}
 
 	return files, nil
-}
\ No newline at end of file
+}
+// folderSortColumns maps the sort fields accepted by GetByFolder to their columns
+var folderSortColumns = map[string]string{
+	"name":           "original_name",
+	"size":           "file_size",
+	"upload_date":    "upload_date",
+	"download_count": "download_count",
+}
+
+// GetByFolder lists a user's files directly inside folderID, or at the root when folderID is nil,
+// along with the total number of such files. sortBy is one of name, size, upload_date or
+// download_count and sortOrder is asc or desc; empty values mean newest first.
+func (r *FileRepository) GetByFolder(userID uuid.UUID, folderID *uuid.UUID, sortBy, sortOrder string, limit, offset int) ([]*domain.File, int, error) {
+	if sortBy == "" {
+		sortBy = "upload_date"
+	}
+	orderBy, ok := folderSortColumns[strings.ToLower(sortBy)]
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid sort field: %s", sortBy)
+	}
+
+	direction := "DESC"
+	switch strings.ToLower(sortOrder) {
+	case "":
+	case "asc":
+		direction = "ASC"
+	case "desc":
+	default:
+		return nil, 0, fmt.Errorf("invalid sort order: %s", sortOrder)
+	}
+
+	// folder_id = NULL is never true, so the root needs its own condition
+	condition := "user_id = $1 AND status <> 'INFECTED' AND folder_id IS NULL"
+	args := []interface{}{userID}
+	if folderID != nil {
+		condition = "user_id = $1 AND status <> 'INFECTED' AND folder_id = $2"
+		args = append(args, *folderID)
+	}
+
+	ctx := context.Background()
+	var totalCount int
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM files WHERE "+condition, args...).Scan(&totalCount); err != nil {
+		r.logger.Error("Failed to count files in folder", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to count files: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
+		       content_hash, description, tags, visibility, share_token, download_count,
+		       upload_date, updated_at
+		FROM files
+		WHERE %s
+		ORDER BY %s %s, id
+		LIMIT $%d OFFSET $%d`, condition, orderBy, direction, len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to get files in folder", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to get files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []*domain.File{}
+	for rows.Next() {
+		file := &domain.File{}
+		err := rows.Scan(
+			&file.ID, &file.UserID, &file.FolderID, &file.Filename, &file.OriginalName, &file.MimeType,
+			&file.FileSize, &file.ContentHash, &file.Description, pq.Array(&file.Tags), &file.Visibility,
+			&file.ShareToken, &file.DownloadCount, &file.UploadDate, &file.UpdatedAt)
+		if err != nil {
+			r.logger.Error("Failed to scan file", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan file: %w", err)
+		}
+
+		files = append(files, file)
+	}
+
+	return files, totalCount, rows.Err()
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+)
+
+type FileShareRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewFileShareRepository(db *pgxpool.Pool, logger *zap.Logger) *FileShareRepository {
+	return &FileShareRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// fileShareColumns is the file_shares column list (aliased fs) scanned by scanFileShare
+const fileShareColumns = `fs.id, fs.file_id, fs.shared_by_user_id, fs.shared_with_user_id, fs.permission_type,
+		       fs.expires_at, fs.last_accessed_at, fs.access_count, fs.created_at`
+
+func scanFileShare(row pgx.Row, extra ...interface{}) (*domain.FileShare, error) {
+	share := &domain.FileShare{}
+	dest := []interface{}{
+		&share.ID, &share.FileID, &share.SharedByUserID, &share.SharedWithUserID, &share.PermissionType,
+		&share.ExpiresAt, &share.LastAccessedAt, &share.AccessCount, &share.CreatedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	return share, err
+}
+
+// listShares runs a query selecting fileShareColumns and collects the shares
+func (r *FileShareRepository) listShares(query string, args ...interface{}) ([]*domain.FileShare, error) {
+	ctx := context.Background()
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to query file shares", zap.Error(err))
+		return nil, fmt.Errorf("failed to query file shares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []*domain.FileShare
+	for rows.Next() {
+		share, err := scanFileShare(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file share: %w", err)
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
+}
+
+func (r *FileShareRepository) Create(share *domain.FileShare) error {
+	query := `
+		INSERT INTO file_shares (id, file_id, shared_by_user_id, shared_with_user_id, permission_type,
+		                         expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	ctx := context.Background()
+	_, err := r.db.Exec(ctx, query,
+		share.ID, share.FileID, share.SharedByUserID, share.SharedWithUserID, share.PermissionType,
+		share.ExpiresAt, share.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create file share", zap.Error(err))
+		return fmt.Errorf("failed to create file share: %w", err)
+	}
+
+	return nil
+}
+
+func (r *FileShareRepository) GetByID(id uuid.UUID) (*domain.FileShare, error) {
+	query := `SELECT ` + fileShareColumns + ` FROM file_shares fs WHERE fs.id = $1`
+
+	ctx := context.Background()
+	share, err := scanFileShare(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		r.logger.Error("Failed to get file share by ID", zap.Error(err), zap.String("id", id.String()))
+		return nil, fmt.Errorf("file share not found: %w", err)
+	}
+
+	return share, nil
+}
+
+func (r *FileShareRepository) GetByFileID(fileID uuid.UUID) ([]*domain.FileShare, error) {
+	return r.listShares(`
+		SELECT `+fileShareColumns+`
+		FROM file_shares fs
+		WHERE fs.file_id = $1
+		ORDER BY fs.created_at DESC`, fileID)
+}
+
+func (r *FileShareRepository) GetSharedWithUser(userID uuid.UUID, limit, offset int) ([]*domain.FileShare, error) {
+	return r.listShares(`
+		SELECT `+fileShareColumns+`
+		FROM file_shares fs
+		WHERE fs.shared_with_user_id = $1
+		ORDER BY fs.created_at DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+}
+
+// GetSharedByUser returns the unexpired shares of the user's active files with the file and the
+// recipient attached. limit and offset page through the shared files, most recently shared first,
+// and every share of each file in the page is returned, so a file's shares are never split across
+// pages. Shares of one file are adjacent, newest first.
+func (r *FileShareRepository) GetSharedByUser(userID uuid.UUID, limit, offset int) ([]*domain.FileShare, error) {
+	query := `
+		WITH active_shares AS (
+			SELECT fs.*
+			FROM file_shares fs
+			JOIN files f ON f.id = fs.file_id
+			WHERE f.user_id = $1 AND f.status = 'ACTIVE'
+			AND (fs.expires_at IS NULL OR fs.expires_at > NOW())
+		), shared_files AS (
+			SELECT file_id, MAX(created_at) AS last_shared_at
+			FROM active_shares
+			GROUP BY file_id
+			ORDER BY last_shared_at DESC, file_id
+			LIMIT $2 OFFSET $3
+		)
+		SELECT ` + fileShareColumns + `,
+		       f.id, f.user_id, f.folder_id, f.filename, f.original_name, f.mime_type, f.file_size,
+		       f.content_hash, f.description, f.tags, f.visibility, f.share_token, f.download_count,
+		       f.upload_date, f.updated_at,
+		       u.id, u.email, u.name
+		FROM active_shares fs
+		JOIN shared_files sf ON sf.file_id = fs.file_id
+		JOIN files f ON f.id = fs.file_id
+		JOIN users u ON u.id = fs.shared_with_user_id
+		ORDER BY sf.last_shared_at DESC, fs.file_id, fs.created_at DESC, fs.id`
+
+	ctx := context.Background()
+	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		r.logger.Error("Failed to get files shared by user", zap.Error(err))
+		return nil, fmt.Errorf("failed to get files shared by user: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []*domain.FileShare
+	for rows.Next() {
+		file := &domain.File{}
+		recipient := &domain.User{}
+		share, err := scanFileShare(rows,
+			&file.ID, &file.UserID, &file.FolderID, &file.Filename, &file.OriginalName, &file.MimeType,
+			&file.FileSize, &file.ContentHash, &file.Description, pq.Array(&file.Tags), &file.Visibility,
+			&file.ShareToken, &file.DownloadCount, &file.UploadDate, &file.UpdatedAt,
+			&recipient.ID, &recipient.Email, &recipient.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file share: %w", err)
+		}
+
+		// Shares of the same file point at one File
+		if len(shares) > 0 && shares[len(shares)-1].FileID == share.FileID {
+			file = shares[len(shares)-1].File
+		}
+		share.File = file
+		share.SharedWith = recipient
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
+}
+
+func (r *FileShareRepository) Update(share *domain.FileShare) error {
+	query := `
+		UPDATE file_shares
+		SET permission_type = $2, expires_at = $3
+		WHERE id = $1`
+
+	ctx := context.Background()
+	result, err := r.db.Exec(ctx, query, share.ID, share.PermissionType, share.ExpiresAt)
+	if err != nil {
+		r.logger.Error("Failed to update file share", zap.Error(err))
+		return fmt.Errorf("failed to update file share: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("file share not found")
+	}
+
+	return nil
+}
+
+func (r *FileShareRepository) Delete(id uuid.UUID) error {
+	ctx := context.Background()
+	result, err := r.db.Exec(ctx, `DELETE FROM file_shares WHERE id = $1`, id)
+	if err != nil {
+		r.logger.Error("Failed to delete file share", zap.Error(err))
+		return fmt.Errorf("failed to delete file share: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("file share not found")
+	}
+
+	return nil
+}
+
+func (r *FileShareRepository) DeleteByFileID(fileID uuid.UUID) error {
+	ctx := context.Background()
+	_, err := r.db.Exec(ctx, `DELETE FROM file_shares WHERE file_id = $1`, fileID)
+	if err != nil {
+		r.logger.Error("Failed to delete file shares", zap.Error(err))
+		return fmt.Errorf("failed to delete file shares: %w", err)
+	}
+
+	return nil
+}
@@ -127,14 +127,28 @@ func (r *FolderRepository) GetChildren(parentID uuid.UUID) ([]*domain.Folder, er
 	return folders, nil
 }
 
+// Update saves a folder's name and parent. A parent that is the folder itself, lies inside it or
+// belongs to another user is refused, so the folder tree can't gain a cycle through this path.
 func (r *FolderRepository) Update(folder *domain.Folder) error {
 	query := `
 		UPDATE folders
 		SET name = $2, parent_id = $3, updated_at = $4
-		WHERE id = $1`
+		WHERE id = $1
+		AND ($3::uuid IS NULL OR (
+			$3 <> $1
+			AND EXISTS (SELECT 1 FROM folders p WHERE p.id = $3 AND p.user_id = folders.user_id)
+			AND NOT EXISTS (
+				WITH RECURSIVE subtree AS (
+					SELECT id FROM folders WHERE parent_id = $1
+					UNION ALL
+					SELECT f.id FROM folders f INNER JOIN subtree s ON f.parent_id = s.id
+				)
+				SELECT 1 FROM subtree WHERE id = $3
+			)
+		))`
 
 	ctx := context.Background()
-	_, err := r.db.Exec(ctx, query,
+	result, err := r.db.Exec(ctx, query,
 		folder.ID, folder.Name, folder.ParentID, folder.UpdatedAt,
 	)
 
@@ -142,6 +156,9 @@ func (r *FolderRepository) Update(folder *domain.Folder) error {
 		r.logger.Error("Failed to update folder", zap.Error(err))
 		return fmt.Errorf("failed to update folder: %w", err)
 	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("folder not found or invalid parent")
+	}
 
 	return nil
 }
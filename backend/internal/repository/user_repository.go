@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -168,19 +169,33 @@ func (r *UserRepository) UpdateStorageUsed(userID uuid.UUID, storageUsed int64)
 	return nil
 }
 
-func (r *UserRepository) List(limit, offset int) ([]*domain.User, error) {
+// userSearchCondition matches users whose name or email contains $1 (LIKE-escaped), or everyone when $1 is empty
+const userSearchCondition = `$1 = '' OR name ILIKE '%' || $1 || '%' ESCAPE '\' OR email ILIKE '%' || $1 || '%' ESCAPE '\'`
+
+// List returns one page of users, newest first, and how many users match in total. A non-empty
+// search keeps users whose name or email contains it, ignoring case. Password hashes are never loaded.
+func (r *UserRepository) List(search string, limit, offset int) ([]*domain.User, int, error) {
+	ctx := context.Background()
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.TrimSpace(search))
+
+	var total int
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM users WHERE "+userSearchCondition, pattern).Scan(&total); err != nil {
+		r.logger.Error("Failed to count users", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
 	query := `
-		SELECT id, email, name, profile_image, password_hash, role, storage_used, storage_quota,
-		       email_verified, enterprise_id, enterprise_role, created_at, updated_at
+		SELECT id, email, name, profile_image, role, storage_used, storage_quota,
+		       email_verified, last_login_at, enterprise_id, enterprise_role, created_at, updated_at
 		FROM users
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2`
+		WHERE ` + userSearchCondition + `
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3`
 
-	ctx := context.Background()
-	rows, err := r.db.Query(ctx, query, limit, offset)
+	rows, err := r.db.Query(ctx, query, pattern, limit, offset)
 	if err != nil {
 		r.logger.Error("Failed to list users", zap.Error(err))
-		return nil, fmt.Errorf("failed to list users: %w", err)
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
 	}
 	defer rows.Close()
 
@@ -188,19 +203,18 @@ func (r *UserRepository) List(limit, offset int) ([]*domain.User, error) {
 	for rows.Next() {
 		user := &domain.User{}
 		err := rows.Scan(
-			&user.ID, &user.Email, &user.Name, &user.ProfileImage, &user.PasswordHash, &user.Role,
-			&user.StorageUsed, &user.StorageQuota, &user.EmailVerified, &user.EnterpriseID,
-			&user.EnterpriseRole, &user.CreatedAt, &user.UpdatedAt)
-
+			&user.ID, &user.Email, &user.Name, &user.ProfileImage, &user.Role,
+			&user.StorageUsed, &user.StorageQuota, &user.EmailVerified, &user.LastLoginAt,
+			&user.EnterpriseID, &user.EnterpriseRole, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
 			r.logger.Error("Failed to scan user", zap.Error(err))
-			continue
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
 		}
 
 		users = append(users, user)
 	}
 
-	return users, nil
+	return users, total, rows.Err()
 }
 
 func (r *UserRepository) GetStorageStats(userID uuid.UUID) (*domain.StorageStats, error) {
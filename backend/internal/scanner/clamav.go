@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const clamAVChunkSize = 64 * 1024
+
+// ClamAVScanner scans content by streaming it to clamd over TCP using the INSTREAM command
+type ClamAVScanner struct {
+	address string
+	timeout time.Duration
+}
+
+// NewClamAVScanner creates a scanner talking to clamd at address (host:port)
+func NewClamAVScanner(address string, timeout time.Duration) *ClamAVScanner {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &ClamAVScanner{
+		address: address,
+		timeout: timeout,
+	}
+}
+
+// Scan streams content to clamd and parses its verdict
+func (s *ClamAVScanner) Scan(ctx context.Context, content io.Reader) (bool, string, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return false, "", fmt.Errorf("failed to send scan command: %w", err)
+	}
+
+	// Each chunk is prefixed with its length as a 4-byte big-endian integer
+	buf := make([]byte, clamAVChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := content.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return false, "", fmt.Errorf("failed to stream content: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return false, "", fmt.Errorf("failed to stream content: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return false, "", fmt.Errorf("failed to read content: %w", readErr)
+		}
+	}
+
+	// A zero-length chunk terminates the stream
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return false, "", fmt.Errorf("failed to finish stream: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return false, "", fmt.Errorf("failed to read scan result: %w", err)
+	}
+
+	return parseClamAVReply(reply)
+}
+
+// parseClamAVReply interprets replies like "stream: OK" or "stream: Eicar-Signature FOUND"
+func parseClamAVReply(reply string) (bool, string, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+
+	switch {
+	case result == "OK":
+		return true, "", nil
+	case strings.HasSuffix(result, "FOUND"):
+		return false, strings.TrimSpace(strings.TrimSuffix(result, "FOUND")), nil
+	default:
+		return false, "", fmt.Errorf("unexpected clamd response: %q", reply)
+	}
+}
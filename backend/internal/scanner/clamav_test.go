@@ -0,0 +1,113 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd accepts one INSTREAM session and answers with reply
+func fakeClamd(t *testing.T, reply string) (string, <-chan []byte) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		command, _ := reader.ReadString(0)
+		if command != "zINSTREAM\x00" {
+			conn.Write([]byte("UNKNOWN COMMAND\x00"))
+			return
+		}
+
+		var content []byte
+		size := make([]byte, 4)
+		for {
+			if _, err := io.ReadFull(reader, size); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size)
+			if n == 0 {
+				break
+			}
+			chunk := make([]byte, n)
+			if _, err := io.ReadFull(reader, chunk); err != nil {
+				return
+			}
+			content = append(content, chunk...)
+		}
+
+		received <- content
+		conn.Write([]byte(reply + "\x00"))
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestClamAVScanner_Clean(t *testing.T) {
+	address, received := fakeClamd(t, "stream: OK")
+	scanner := NewClamAVScanner(address, 5*time.Second)
+
+	clean, reason, err := scanner.Scan(context.Background(), strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if !clean {
+		t.Errorf("Expected content to be clean, got reason '%s'", reason)
+	}
+	if got := string(<-received); got != "hello world" {
+		t.Errorf("Expected clamd to receive 'hello world', got '%s'", got)
+	}
+}
+
+func TestClamAVScanner_Infected(t *testing.T) {
+	address, _ := fakeClamd(t, "stream: Eicar-Test-Signature FOUND")
+	scanner := NewClamAVScanner(address, 5*time.Second)
+
+	clean, reason, err := scanner.Scan(context.Background(), strings.NewReader("X5O!P%@AP"))
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if clean {
+		t.Error("Expected content to be reported as infected")
+	}
+	if reason != "Eicar-Test-Signature" {
+		t.Errorf("Expected reason to be 'Eicar-Test-Signature', got '%s'", reason)
+	}
+}
+
+func TestClamAVScanner_ErrorReply(t *testing.T) {
+	address, _ := fakeClamd(t, "INSTREAM size limit exceeded. ERROR")
+	scanner := NewClamAVScanner(address, 5*time.Second)
+
+	if _, _, err := scanner.Scan(context.Background(), strings.NewReader("data")); err == nil {
+		t.Error("Expected an error for an ERROR reply")
+	}
+}
+
+func TestNewScanner_DisabledIsNoop(t *testing.T) {
+	scanner, err := NewScanner(ScannerConfig{Enabled: false}, nil)
+	if err != nil {
+		t.Fatalf("NewScanner failed: %v", err)
+	}
+
+	if _, ok := scanner.(NoopScanner); !ok {
+		t.Errorf("Expected NoopScanner when scanning is disabled, got %T", scanner)
+	}
+}
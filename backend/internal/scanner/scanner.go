@@ -0,0 +1,45 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Scanner defines the interface for malware scanning of uploaded content
+type Scanner interface {
+	// Scan reads content and reports whether it is clean; reason names the detected threat
+	Scan(ctx context.Context, content io.Reader) (clean bool, reason string, err error)
+}
+
+// ScannerConfig contains configuration for the scanning backend
+type ScannerConfig struct {
+	Enabled       bool          `json:"enabled"`
+	ClamAVAddress string        `json:"clamav_address"` // host:port of clamd
+	Timeout       time.Duration `json:"timeout"`        // Connection and read timeout for clamd
+}
+
+// NoopScanner reports every file as clean; used when scanning is disabled
+type NoopScanner struct{}
+
+// Scan always reports content as clean
+func (NoopScanner) Scan(ctx context.Context, content io.Reader) (bool, string, error) {
+	return true, "", nil
+}
+
+// NewScanner creates a scanner based on the configuration
+func NewScanner(config ScannerConfig, logger *zap.Logger) (Scanner, error) {
+	if !config.Enabled {
+		return NoopScanner{}, nil
+	}
+
+	if config.ClamAVAddress == "" {
+		return nil, fmt.Errorf("ClamAV address is required when scanning is enabled")
+	}
+
+	logger.Info("Virus scanning enabled", zap.String("clamav_address", config.ClamAVAddress))
+	return NewClamAVScanner(config.ClamAVAddress, config.Timeout), nil
+}
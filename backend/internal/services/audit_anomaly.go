@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+)
+
+const (
+	// anomalyHistoryWindow is how far back a user's addresses count as familiar
+	anomalyHistoryWindow = 90 * 24 * time.Hour
+
+	// anomalyHistoryLimit bounds how many recent entries an address is compared against
+	anomalyHistoryLimit = 200
+
+	// maxTravelSpeed is the fastest plausible travel between two sign-ins, in km/h: roughly an airliner
+	maxTravelSpeed = 1000.0
+
+	// minTravelDistance ignores jumps shorter than this many km, which geo lookups produce on their own
+	minTravelDistance = 500.0
+)
+
+// Metadata keys under which anomaly detection records its findings on an audit entry
+const (
+	AuditMetadataAnomalies = "anomalies"
+	AuditMetadataLocation  = "location"
+)
+
+// anomalyCheckedActions are the actions checked for unusual origins: sign-ins and actions that
+// expose data or change who has access to it
+var anomalyCheckedActions = map[domain.AuditAction]bool{
+	domain.ActionUserLogin:    true,
+	domain.ActionFileShare:    true,
+	domain.ActionUserShare:    true,
+	domain.ActionFolderShare:  true,
+	domain.ActionPublicShare:  true,
+	domain.ActionFileDelete:   true,
+	domain.ActionMemberRemove: true,
+}
+
+// GeoLocation is where an IP address is located
+type GeoLocation struct {
+	Country   string  `json:"country"`
+	City      string  `json:"city,omitempty"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// GeoLocator looks up where IP addresses are, e.g. from a GeoIP database
+type GeoLocator interface {
+	// Locate returns the location of ip, or nil when it is unknown
+	Locate(ctx context.Context, ip string) (*GeoLocation, error)
+}
+
+// SecurityAlerter tells users about flagged activity on their account, e.g. by email
+type SecurityAlerter interface {
+	AlertAnomaly(ctx context.Context, userID uuid.UUID, log *domain.AuditLog) error
+}
+
+// SetGeoLocator locates the addresses of checked actions, enabling location-based anomalies.
+// Without one, only addresses never seen before are flagged.
+func (s *AuditService) SetGeoLocator(geo GeoLocator) {
+	s.geo = geo
+}
+
+// SetSecurityAlerter alerts users of entries flagged as anomalous
+func (s *AuditService) SetSecurityAlerter(alerter SecurityAlerter) {
+	s.alerter = alerter
+}
+
+// originHistory is a recent entry of the user's: the address it came from and where that was
+type originHistory struct {
+	ipAddress string
+	location  *GeoLocation
+	createdAt time.Time
+}
+
+// detectAnomalies compares the origin of a checked entry against the user's recent history and
+// returns the anomalies it shows, recording the entry's location in its metadata. A user without
+// history has nothing to compare against, so their first entries are never flagged.
+func (s *AuditService) detectAnomalies(ctx context.Context, entry *domain.AuditLogEntry) []domain.AuditAnomaly {
+	if !anomalyCheckedActions[entry.Action] || entry.IPAddress == "" {
+		return nil
+	}
+
+	var location *GeoLocation
+	if s.geo != nil {
+		var err error
+		location, err = s.geo.Locate(ctx, entry.IPAddress)
+		if err != nil {
+			RequestLogger(ctx, s.logger).Warn("Failed to locate IP address", zap.String("ip_address", entry.IPAddress), zap.Error(err))
+		}
+		if location != nil {
+			if entry.Metadata == nil {
+				entry.Metadata = map[string]interface{}{}
+			}
+			entry.Metadata[AuditMetadataLocation] = location
+		}
+	}
+
+	history, err := s.originHistory(ctx, entry.UserID)
+	if err != nil {
+		RequestLogger(ctx, s.logger).Warn("Failed to load origin history", zap.Error(err))
+		return nil
+	}
+	if len(history) == 0 {
+		return nil
+	}
+
+	var anomalies []domain.AuditAnomaly
+	if !familiarOrigin(history, entry.IPAddress, location) {
+		anomalies = append(anomalies, domain.AnomalyNewLocation)
+	}
+	if location != nil {
+		for _, previous := range history {
+			if previous.location == nil {
+				continue
+			}
+			if impossibleTravel(previous.location, location, time.Since(previous.createdAt)) {
+				anomalies = append(anomalies, domain.AnomalyImpossibleTravel)
+			}
+			// Only the latest located entry matters: earlier ones had more time to travel
+			break
+		}
+	}
+	return anomalies
+}
+
+// originHistory loads the user's recent entries that have an address, newest first
+func (s *AuditService) originHistory(ctx context.Context, userID uuid.UUID) ([]originHistory, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT host(ip_address), metadata->'location', created_at
+		FROM audit_logs
+		WHERE user_id = $1 AND ip_address IS NOT NULL AND created_at > $2
+		ORDER BY created_at DESC
+		LIMIT $3`,
+		userID, time.Now().Add(-anomalyHistoryWindow), anomalyHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query origin history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []originHistory
+	for rows.Next() {
+		var origin originHistory
+		if err := rows.Scan(&origin.ipAddress, &origin.location, &origin.createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan origin history: %w", err)
+		}
+		history = append(history, origin)
+	}
+	return history, rows.Err()
+}
+
+// familiarOrigin reports whether the address, or the city it is in, appears in the history
+func familiarOrigin(history []originHistory, ipAddress string, location *GeoLocation) bool {
+	for _, previous := range history {
+		if previous.ipAddress == ipAddress {
+			return true
+		}
+		if location != nil && previous.location != nil &&
+			previous.location.Country == location.Country && previous.location.City == location.City {
+			return true
+		}
+	}
+	return false
+}
+
+// impossibleTravel reports whether getting from one location to the other in elapsed would take
+// an implausible speed
+func impossibleTravel(from, to *GeoLocation, elapsed time.Duration) bool {
+	distance := distanceKm(from, to)
+	if distance < minTravelDistance {
+		return false
+	}
+	hours := math.Max(elapsed.Hours(), 1.0/60)
+	return distance/hours > maxTravelSpeed
+}
+
+// distanceKm is the great-circle distance between two locations
+func distanceKm(from, to *GeoLocation) float64 {
+	const earthRadiusKm = 6371.0
+	lat1, lat2 := from.Latitude*math.Pi/180, to.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (to.Longitude - from.Longitude) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
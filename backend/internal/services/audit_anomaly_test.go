@@ -0,0 +1,123 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/testutil"
+)
+
+// fakeGeoLocator locates the addresses it was given and nothing else
+type fakeGeoLocator map[string]*GeoLocation
+
+func (g fakeGeoLocator) Locate(ctx context.Context, ip string) (*GeoLocation, error) {
+	return g[ip], nil
+}
+
+// recordingAlerter collects the entries it is alerted of
+type recordingAlerter chan *domain.AuditLog
+
+func (a recordingAlerter) AlertAnomaly(ctx context.Context, userID uuid.UUID, log *domain.AuditLog) error {
+	a <- log
+	return nil
+}
+
+func TestAuditService_Anomalies(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+
+	audit := NewAuditService(db, zap.NewNop())
+
+	// latestAnomalies returns the anomalies of the user's latest entry
+	latestAnomalies := func(t *testing.T, userID uuid.UUID) []interface{} {
+		t.Helper()
+		logs, err := audit.GetAuditLogs(ctx, userID, 1, 0, nil, nil)
+		if err != nil || len(logs) != 1 {
+			t.Fatalf("GetAuditLogs failed: %v", err)
+		}
+		anomalies, _ := logs[0].Metadata[AuditMetadataAnomalies].([]interface{})
+		return anomalies
+	}
+
+	t.Run("flags a never-seen address but not a repeat one", func(t *testing.T) {
+		userID := testutil.CreateUser(t, db, "anomaly-ip", nil)
+
+		audit.LogUserLogin(ctx, userID, "203.0.113.10", "")
+		if anomalies := latestAnomalies(t, userID); len(anomalies) != 0 {
+			t.Errorf("Expected a first sign-in not to be flagged, got %v", anomalies)
+		}
+
+		audit.LogUserLogin(ctx, userID, "203.0.113.10", "")
+		if anomalies := latestAnomalies(t, userID); len(anomalies) != 0 {
+			t.Errorf("Expected a repeat address not to be flagged, got %v", anomalies)
+		}
+
+		audit.LogUserLogin(ctx, userID, "198.51.100.20", "")
+		anomalies := latestAnomalies(t, userID)
+		if !slices.Contains(anomalies, interface{}(string(domain.AnomalyNewLocation))) {
+			t.Errorf("Expected a new address to be flagged %s, got %v", domain.AnomalyNewLocation, anomalies)
+		}
+
+		// Unchecked actions aren't flagged wherever they come from
+		audit.LogFileDownload(ctx, userID, uuid.New(), "report.pdf", "192.0.2.30", "")
+		if anomalies := latestAnomalies(t, userID); len(anomalies) != 0 {
+			t.Errorf("Expected a download not to be flagged, got %v", anomalies)
+		}
+
+		events, err := audit.GetSecurityEvents(ctx, userID, 10, 0)
+		if err != nil {
+			t.Fatalf("GetSecurityEvents failed: %v", err)
+		}
+		if len(events) != 1 || events[0].IPAddress != "198.51.100.20" {
+			t.Errorf("Expected only the sign-in from the new address, got %d events", len(events))
+		}
+	})
+
+	t.Run("flags impossible travel and alerts the user", func(t *testing.T) {
+		userID := testutil.CreateUser(t, db, "anomaly-geo", nil)
+
+		alerts := make(recordingAlerter, 1)
+		located := NewAuditService(db, zap.NewNop())
+		located.SetGeoLocator(fakeGeoLocator{
+			"203.0.113.10":  {Country: "GB", City: "London", Latitude: 51.51, Longitude: -0.13},
+			"203.0.113.11":  {Country: "GB", City: "London", Latitude: 51.50, Longitude: -0.12},
+			"198.51.100.20": {Country: "AU", City: "Sydney", Latitude: -33.87, Longitude: 151.21},
+		})
+		located.SetSecurityAlerter(alerts)
+
+		located.LogUserLogin(ctx, userID, "203.0.113.10", "")
+
+		// Another address in the same city is familiar
+		located.LogUserLogin(ctx, userID, "203.0.113.11", "")
+		if anomalies := latestAnomalies(t, userID); len(anomalies) != 0 {
+			t.Errorf("Expected a new address in a known city not to be flagged, got %v", anomalies)
+		}
+
+		located.LogUserLogin(ctx, userID, "198.51.100.20", "")
+		anomalies := latestAnomalies(t, userID)
+		if !slices.Contains(anomalies, interface{}(string(domain.AnomalyNewLocation))) ||
+			!slices.Contains(anomalies, interface{}(string(domain.AnomalyImpossibleTravel))) {
+			t.Errorf("Expected London to Sydney in moments to be flagged, got %v", anomalies)
+		}
+
+		alerted := <-alerts
+		if alerted.UserID != userID || alerted.IPAddress != "198.51.100.20" {
+			t.Errorf("Unexpected alert %+v", alerted)
+		}
+	})
+}
+
+func TestDistanceKm(t *testing.T) {
+	london := &GeoLocation{Latitude: 51.5074, Longitude: -0.1278}
+	paris := &GeoLocation{Latitude: 48.8566, Longitude: 2.3522}
+	if distance := distanceKm(london, paris); distance < 330 || distance > 350 {
+		t.Errorf("Expected London to Paris to be about 340km, got %.0f", distance)
+	}
+}
@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,47 +17,136 @@ import (
 	"lokr-backend/internal/domain"
 )
 
+// Supported audit export formats
+const (
+	AuditExportCSV    = "csv"
+	AuditExportNDJSON = "ndjson"
+)
+
+// defaultPurgeBatchSize limits how many rows a single purge DELETE touches so locks stay short
+const defaultPurgeBatchSize = 1000
+
+// AuditRetentionSettingKey is the enterprise settings key overriding the default retention in days
+const AuditRetentionSettingKey = "audit_retention_days"
+
+// maxAuditRetentionDays bounds the retention setting; a century outlasts any log there is
+const maxAuditRetentionDays = 36525
+
+// parseAuditRetentionDays parses an audit_retention_days setting: a whole number of days up to a
+// century, 0 meaning logs are kept forever
+func parseAuditRetentionDays(setting string) (int, error) {
+	days, err := strconv.Atoi(strings.TrimSpace(setting))
+	if err != nil || days < 0 || days > maxAuditRetentionDays {
+		return 0, fmt.Errorf("%s must be a whole number of days from 0 to %d, got %q", AuditRetentionSettingKey, maxAuditRetentionDays, setting)
+	}
+	return days, nil
+}
+
 type AuditService struct {
-	db     *pgxpool.Pool
-	logger *zap.Logger
+	db             *pgxpool.Pool
+	logger         *zap.Logger
+	purgeBatchSize int
+	events         *EventBroker
+	webhooks       *WebhookService
+	geo            GeoLocator
+	alerter        SecurityAlerter
 }
 
 func NewAuditService(db *pgxpool.Pool, logger *zap.Logger) *AuditService {
 	return &AuditService{
-		db:     db,
-		logger: logger,
+		db:             db,
+		logger:         logger,
+		purgeBatchSize: defaultPurgeBatchSize,
+	}
+}
+
+// SetEventBroker publishes every entry logged from now on to events, for real-time subscriptions
+func (s *AuditService) SetEventBroker(events *EventBroker) {
+	s.events = events
+}
+
+// SetWebhookService queues every entry logged from now on for the acting user's enterprise webhooks
+func (s *AuditService) SetWebhookService(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+// RequestMeta returns the client IP and user agent stored on the context by the HTTP layer
+func RequestMeta(ctx context.Context) (ipAddress, userAgent string) {
+	ipAddress, _ = ctx.Value("ipAddress").(string)
+	userAgent, _ = ctx.Value("userAgent").(string)
+	return ipAddress, userAgent
+}
+
+// RequestID returns the ID the HTTP layer assigned to the request ctx belongs to, or "" outside a
+// request
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value("requestID").(string)
+	return requestID
+}
+
+// RequestLogger returns logger with the request ID of ctx attached, so everything logged while
+// serving a request can be correlated; outside a request it returns logger unchanged
+func RequestLogger(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if requestID := RequestID(ctx); requestID != "" {
+		return logger.With(zap.String("request_id", requestID))
 	}
+	return logger
 }
 
-// LogAction logs an audit entry to the database
+// LogAction logs an audit entry to the database; a nil service logs nothing
 func (s *AuditService) LogAction(ctx context.Context, entry *domain.AuditLogEntry) error {
+	if s == nil {
+		return nil
+	}
+
 	// Use formatted description if no description provided
 	description := entry.Description
 	if description == "" {
 		description = entry.FormatDescription()
 	}
 
+	anomalies := s.detectAnomalies(ctx, entry)
+	if len(anomalies) > 0 {
+		if entry.Metadata == nil {
+			entry.Metadata = map[string]interface{}{}
+		}
+		entry.Metadata[AuditMetadataAnomalies] = anomalies
+		RequestLogger(ctx, s.logger).Warn("Anomalous activity detected",
+			zap.String("user_id", entry.UserID.String()),
+			zap.String("action", string(entry.Action)),
+			zap.String("ip_address", entry.IPAddress),
+			zap.Any("anomalies", anomalies),
+		)
+	}
+
 	// Convert metadata to JSON
 	var metadataJSON []byte
 	var err error
 	if entry.Metadata != nil {
 		metadataJSON, err = json.Marshal(entry.Metadata)
 		if err != nil {
-			s.logger.Error("Failed to marshal audit metadata", zap.Error(err))
+			RequestLogger(ctx, s.logger).Error("Failed to marshal audit metadata", zap.Error(err))
 			metadataJSON = []byte("{}")
 		}
 	} else {
 		metadataJSON = []byte("{}")
 	}
 
+	// ip_address is an INET column, so an unknown address must be stored as NULL
+	var ipAddress interface{}
+	if entry.IPAddress != "" {
+		ipAddress = entry.IPAddress
+	}
+
 	// Insert audit log entry
 	query := `
 		INSERT INTO audit_logs (id, user_id, action, status, resource_type, resource_id,
 		                       resource_name, description, ip_address, user_agent, metadata, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 
+	id, createdAt := uuid.New(), time.Now()
 	_, err = s.db.Exec(ctx, query,
-		uuid.New(),
+		id,
 		entry.UserID,
 		entry.Action,
 		entry.Status,
@@ -61,19 +154,19 @@ func (s *AuditService) LogAction(ctx context.Context, entry *domain.AuditLogEntr
 		entry.ResourceID,
 		entry.ResourceName,
 		description,
-		entry.IPAddress,
+		ipAddress,
 		entry.UserAgent,
 		metadataJSON,
-		time.Now(),
+		createdAt,
 	)
 
 	if err != nil {
-		s.logger.Error("Failed to insert audit log", zap.Error(err))
+		RequestLogger(ctx, s.logger).Error("Failed to insert audit log", zap.Error(err))
 		return fmt.Errorf("failed to log audit entry: %w", err)
 	}
 
 	// Log to application logs as well for debugging
-	s.logger.Info("Audit log entry created",
+	RequestLogger(ctx, s.logger).Info("Audit log entry created",
 		zap.String("user_id", entry.UserID.String()),
 		zap.String("action", string(entry.Action)),
 		zap.String("status", string(entry.Status)),
@@ -82,35 +175,101 @@ func (s *AuditService) LogAction(ctx context.Context, entry *domain.AuditLogEntr
 		zap.String("description", description),
 	)
 
+	log := &domain.AuditLog{
+		ID:           id,
+		UserID:       entry.UserID,
+		Action:       entry.Action,
+		Status:       entry.Status,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		ResourceName: entry.ResourceName,
+		Description:  description,
+		IPAddress:    entry.IPAddress,
+		UserAgent:    entry.UserAgent,
+		Metadata:     entry.Metadata,
+		CreatedAt:    createdAt,
+	}
+	s.events.Publish(entry.UserID, Event{AuditLog: log})
+
+	if len(anomalies) > 0 && s.alerter != nil {
+		// Alerting may be slow, e.g. sending email, and must not hold up the action being logged
+		go func() {
+			if err := s.alerter.AlertAnomaly(context.WithoutCancel(ctx), entry.UserID, log); err != nil {
+				RequestLogger(ctx, s.logger).Error("Failed to send security alert", zap.Error(err))
+			}
+		}()
+	}
+
+	// Only queued here; delivery happens in the background
+	if err := s.webhooks.Enqueue(ctx, &domain.WebhookEvent{
+		ID:           id,
+		Type:         entry.Action,
+		UserID:       entry.UserID,
+		Status:       entry.Status,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		ResourceName: entry.ResourceName,
+		Description:  description,
+		Metadata:     entry.Metadata,
+		OccurredAt:   createdAt,
+	}); err != nil {
+		RequestLogger(ctx, s.logger).Error("Failed to queue webhook deliveries", zap.Error(err))
+	}
+
 	return nil
 }
 
 // GetAuditLogs retrieves audit logs with pagination and filtering
 func (s *AuditService) GetAuditLogs(ctx context.Context, userID uuid.UUID, limit, offset int, action *domain.AuditAction, status *domain.AuditStatus) ([]*domain.AuditLog, error) {
+	return s.queryAuditLogs(ctx, domain.AuditLogFilter{
+		UserID: &userID,
+		Action: action,
+		Status: status,
+	}, limit, offset)
+}
+
+// GetEnterpriseAuditLogs retrieves the audit logs of every member of an enterprise, newest first.
+// Callers are responsible for checking that the requester may see the whole enterprise.
+func (s *AuditService) GetEnterpriseAuditLogs(ctx context.Context, enterpriseID uuid.UUID, filter domain.AuditLogFilter, limit, offset int) ([]*domain.AuditLog, error) {
+	filter.EnterpriseID = &enterpriseID
+	return s.queryAuditLogs(ctx, filter, limit, offset)
+}
+
+// GetSecurityEvents retrieves the user's audit logs flagged with anomalies, newest first
+func (s *AuditService) GetSecurityEvents(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.AuditLog, error) {
+	return s.queryAuditLogs(ctx, domain.AuditLogFilter{
+		UserID:  &userID,
+		Flagged: true,
+	}, limit, offset)
+}
+
+// GetFileActivity retrieves the audit logs about a single file, newest first.
+// Callers are responsible for checking that the requester may see the file.
+func (s *AuditService) GetFileActivity(ctx context.Context, fileID uuid.UUID, limit, offset int) ([]*domain.AuditLog, error) {
+	return s.queryAuditLogs(ctx, domain.AuditLogFilter{ResourceID: &fileID}, limit, offset)
+}
+
+// GetFileAccessHistory retrieves the downloads and previews of a single file by anyone, newest
+// first. Callers are responsible for checking that the requester owns the file.
+func (s *AuditService) GetFileAccessHistory(ctx context.Context, fileID uuid.UUID, limit, offset int) ([]*domain.AuditLog, error) {
+	return s.queryAuditLogs(ctx, domain.AuditLogFilter{
+		ResourceID: &fileID,
+		Actions:    domain.FileAccessActions,
+	}, limit, offset)
+}
+
+// queryAuditLogs loads one page of audit logs matching filter along with the acting user
+func (s *AuditService) queryAuditLogs(ctx context.Context, filter domain.AuditLogFilter, limit, offset int) ([]*domain.AuditLog, error) {
+	conditions, args := auditFilterConditions(filter)
 	query := `
 		SELECT a.id, a.user_id, a.action, a.status, a.resource_type, a.resource_id,
-		       a.resource_name, a.description, a.ip_address, a.user_agent, a.metadata, a.created_at,
+		       a.resource_name, a.description, COALESCE(host(a.ip_address), ''), COALESCE(a.user_agent, ''), a.metadata, a.created_at,
 		       u.id, u.email, u.name, u.profile_image
 		FROM audit_logs a
 		LEFT JOIN users u ON a.user_id = u.id
-		WHERE a.user_id = $1`
-
-	args := []interface{}{userID}
-	argIndex := 2
-
-	if action != nil {
-		query += fmt.Sprintf(" AND a.action = $%d", argIndex)
-		args = append(args, *action)
-		argIndex++
-	}
-
-	if status != nil {
-		query += fmt.Sprintf(" AND a.status = $%d", argIndex)
-		args = append(args, *status)
-		argIndex++
-	}
+		WHERE 1 = 1` + conditions
 
-	query += fmt.Sprintf(" ORDER BY a.created_at DESC LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
+	query += fmt.Sprintf(" ORDER BY a.created_at DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
 	args = append(args, limit, offset)
 
 	rows, err := s.db.Query(ctx, query, args...)
@@ -139,7 +298,7 @@ func (s *AuditService) GetAuditLogs(ctx context.Context, userID uuid.UUID, limit
 		if len(metadataJSON) > 0 {
 			var metadata map[string]interface{}
 			if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
-				s.logger.Warn("Failed to unmarshal audit metadata", zap.Error(err))
+				RequestLogger(ctx, s.logger).Warn("Failed to unmarshal audit metadata", zap.Error(err))
 			} else {
 				log.Metadata = metadata
 			}
@@ -151,11 +310,77 @@ func (s *AuditService) GetAuditLogs(ctx context.Context, userID uuid.UUID, limit
 	return logs, nil
 }
 
+// ParseAuditDateBound parses a bound of an audit log date range: an RFC 3339 timestamp or a
+// YYYY-MM-DD date. A bare date used as the upper bound covers the whole day. An empty value is no
+// bound.
+func ParseAuditDateBound(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// auditFilterConditions renders filter as " AND ..." clauses over audit_logs (aliased a) with numbered arguments
+func auditFilterConditions(filter domain.AuditLogFilter) (string, []interface{}) {
+	var conditions string
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions += fmt.Sprintf(condition, len(args))
+	}
+
+	if filter.UserID != nil {
+		addCondition(" AND a.user_id = $%d", *filter.UserID)
+	}
+	if filter.EnterpriseID != nil {
+		addCondition(" AND a.user_id IN (SELECT id FROM users WHERE enterprise_id = $%d)", *filter.EnterpriseID)
+	}
+	if filter.ResourceID != nil {
+		addCondition(" AND a.resource_id = $%d", *filter.ResourceID)
+	}
+	if filter.From != nil {
+		addCondition(" AND a.created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition(" AND a.created_at < $%d", *filter.To)
+	}
+	if filter.Action != nil {
+		addCondition(" AND a.action = $%d", *filter.Action)
+	}
+	if len(filter.Actions) > 0 {
+		actions := make([]string, len(filter.Actions))
+		for i, action := range filter.Actions {
+			actions[i] = string(action)
+		}
+		addCondition(" AND a.action = ANY($%d)", actions)
+	}
+	if filter.Status != nil {
+		addCondition(" AND a.status = $%d", *filter.Status)
+	}
+	if filter.Flagged {
+		addCondition(" AND a.metadata -> $%d IS NOT NULL", AuditMetadataAnomalies)
+	}
+
+	return conditions, args
+}
+
 // GetRecentActivity gets recent activity for the user (last 24 hours)
 func (s *AuditService) GetRecentActivity(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.AuditLog, error) {
 	query := `
 		SELECT a.id, a.user_id, a.action, a.status, a.resource_type, a.resource_id,
-		       a.resource_name, a.description, a.ip_address, a.user_agent, a.metadata, a.created_at,
+		       a.resource_name, a.description, COALESCE(host(a.ip_address), ''), COALESCE(a.user_agent, ''), a.metadata, a.created_at,
 		       u.id, u.email, u.name, u.profile_image
 		FROM audit_logs a
 		LEFT JOIN users u ON a.user_id = u.id
@@ -190,7 +415,7 @@ func (s *AuditService) GetRecentActivity(ctx context.Context, userID uuid.UUID,
 		if len(metadataJSON) > 0 {
 			var metadata map[string]interface{}
 			if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
-				s.logger.Warn("Failed to unmarshal audit metadata", zap.Error(err))
+				RequestLogger(ctx, s.logger).Warn("Failed to unmarshal audit metadata", zap.Error(err))
 			} else {
 				log.Metadata = metadata
 			}
@@ -230,6 +455,275 @@ func (s *AuditService) GetActivityStats(ctx context.Context, userID uuid.UUID, s
 	return stats, nil
 }
 
+// GetActivityTimeSeries returns per-bucket activity counts grouped by action for the last `days` days.
+// Buckets are generated in SQL so periods without activity are included with zero counts.
+func (s *AuditService) GetActivityTimeSeries(ctx context.Context, userID uuid.UUID, days int, bucket domain.ActivityBucket) ([]*domain.ActivityTimePoint, error) {
+	if bucket != domain.ActivityBucketDay && bucket != domain.ActivityBucketHour {
+		return nil, fmt.Errorf("invalid bucket '%s'", bucket)
+	}
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1")
+	}
+
+	query := `
+		WITH buckets AS (
+			SELECT generate_series(
+				date_trunc($3::text, NOW() - make_interval(days => $2::int)) + ('1 ' || $3::text)::interval,
+				date_trunc($3::text, NOW()),
+				('1 ' || $3::text)::interval
+			) AS bucket
+		)
+		SELECT b.bucket, a.action, COUNT(a.id)
+		FROM buckets b
+		LEFT JOIN audit_logs a
+			ON a.user_id = $1 AND date_trunc($3::text, a.created_at) = b.bucket
+		GROUP BY b.bucket, a.action
+		ORDER BY b.bucket`
+
+	rows, err := s.db.Query(ctx, query, userID, days, string(bucket))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activity time series: %w", err)
+	}
+	defer rows.Close()
+
+	var series []*domain.ActivityTimePoint
+	for rows.Next() {
+		var bucketStart time.Time
+		var action *string
+		var count int
+		if err := rows.Scan(&bucketStart, &action, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan activity time series: %w", err)
+		}
+
+		if len(series) == 0 || !series[len(series)-1].Bucket.Equal(bucketStart) {
+			series = append(series, &domain.ActivityTimePoint{Bucket: bucketStart, Counts: make(map[string]int)})
+		}
+		if action != nil {
+			point := series[len(series)-1]
+			point.Counts[*action] = count
+			point.Total += count
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read activity time series: %w", err)
+	}
+
+	return series, nil
+}
+
+// StreamAuditLogs walks the matching audit logs oldest first, handing each row to fn as it
+// is read from the connection so large histories are never held in memory at once
+func (s *AuditService) StreamAuditLogs(ctx context.Context, filter domain.AuditLogFilter, fn func(*domain.AuditLog) error) error {
+	query := `
+		SELECT a.id, a.user_id, a.action, a.status, a.resource_type, a.resource_id,
+		       a.resource_name, a.description, COALESCE(host(a.ip_address), ''), COALESCE(a.user_agent, ''), a.created_at
+		FROM audit_logs a
+		WHERE 1 = 1`
+
+	conditions, args := auditFilterConditions(filter)
+	query += conditions + " ORDER BY a.created_at ASC, a.id ASC"
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		log := &domain.AuditLog{}
+		err := rows.Scan(
+			&log.ID, &log.UserID, &log.Action, &log.Status, &log.ResourceType, &log.ResourceID,
+			&log.ResourceName, &log.Description, &log.IPAddress, &log.UserAgent, &log.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan audit log: %w", err)
+		}
+
+		if err := fn(log); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ExportAuditLogs writes the matching audit logs to w as CSV or NDJSON and returns the number of rows written
+func (s *AuditService) ExportAuditLogs(ctx context.Context, w io.Writer, format string, filter domain.AuditLogFilter) (int, error) {
+	count := 0
+
+	switch format {
+	case AuditExportCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"timestamp", "action", "status", "resource_type", "resource_name", "ip_address", "user_agent"}); err != nil {
+			return 0, fmt.Errorf("failed to write csv header: %w", err)
+		}
+
+		err := s.StreamAuditLogs(ctx, filter, func(log *domain.AuditLog) error {
+			count++
+			err := writer.Write([]string{
+				log.CreatedAt.UTC().Format(time.RFC3339),
+				string(log.Action),
+				string(log.Status),
+				log.ResourceType,
+				log.ResourceName,
+				log.IPAddress,
+				log.UserAgent,
+			})
+			// Flush periodically so the client starts receiving data early
+			if err == nil && count%100 == 0 {
+				writer.Flush()
+				err = writer.Error()
+			}
+			return err
+		})
+		writer.Flush()
+		if err != nil {
+			return count, fmt.Errorf("failed to export audit logs: %w", err)
+		}
+		return count, writer.Error()
+
+	case AuditExportNDJSON:
+		encoder := json.NewEncoder(w)
+		err := s.StreamAuditLogs(ctx, filter, func(log *domain.AuditLog) error {
+			count++
+			return encoder.Encode(map[string]interface{}{
+				"timestamp":    log.CreatedAt.UTC().Format(time.RFC3339),
+				"action":       log.Action,
+				"status":       log.Status,
+				"resourceType": log.ResourceType,
+				"resourceName": log.ResourceName,
+				"ipAddress":    log.IPAddress,
+				"userAgent":    log.UserAgent,
+			})
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to export audit logs: %w", err)
+		}
+		return count, nil
+
+	default:
+		return 0, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// PurgeOlderThan deletes audit logs created before cutoff in batches and returns the number of rows deleted
+func (s *AuditService) PurgeOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	return s.purgeBatches(ctx, "created_at < $1", cutoff)
+}
+
+// ApplyRetention purges audit logs older than the default retention window. Enterprises can keep
+// their members' logs for a different number of days via the audit_retention_days setting, where
+// 0 keeps them forever. A setting too large to be a retention window is ignored with a warning,
+// and the enterprise's logs are kept until it is fixed.
+func (s *AuditService) ApplyRetention(ctx context.Context, defaultDays int) (int64, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, settings->>'`+AuditRetentionSettingKey+`'
+		FROM enterprises
+		WHERE settings->>'`+AuditRetentionSettingKey+`' ~ '^[0-9]+$'`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load enterprise retention settings: %w", err)
+	}
+
+	overrides := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var enterpriseID uuid.UUID
+		var setting string
+		if err := rows.Scan(&enterpriseID, &setting); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan enterprise retention: %w", err)
+		}
+		days, err := parseAuditRetentionDays(setting)
+		if err != nil {
+			s.logger.Warn("Ignoring enterprise audit retention setting",
+				zap.String("enterprise_id", enterpriseID.String()),
+				zap.Error(err))
+			continue
+		}
+		if days > 0 {
+			overrides[enterpriseID] = days
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read enterprise retention settings: %w", err)
+	}
+
+	now := time.Now()
+	var total int64
+
+	// Everyone without an enterprise override gets the default window; enterprises keeping their
+	// logs forever, or with a setting to fix, are left out here too
+	deleted, err := s.purgeBatches(ctx, `created_at < $1 AND user_id NOT IN (
+		SELECT u.id FROM users u
+		JOIN enterprises e ON u.enterprise_id = e.id
+		WHERE e.settings->>'`+AuditRetentionSettingKey+`' ~ '^[0-9]+$')`,
+		now.AddDate(0, 0, -defaultDays))
+	total += deleted
+	if err != nil {
+		return total, err
+	}
+
+	for enterpriseID, days := range overrides {
+		deleted, err := s.purgeBatches(ctx, "created_at < $1 AND user_id IN (SELECT id FROM users WHERE enterprise_id = $2)",
+			now.AddDate(0, 0, -days), enterpriseID)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// RunRetention applies the retention policy immediately and then on every interval until ctx is cancelled
+func (s *AuditService) RunRetention(ctx context.Context, defaultDays int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.ApplyRetention(ctx, defaultDays)
+		if err != nil {
+			s.logger.Error("Audit log retention failed", zap.Error(err))
+		} else if deleted > 0 {
+			s.logger.Info("Purged expired audit logs", zap.Int64("deleted", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeBatches repeatedly deletes up to purgeBatchSize rows matching condition until none remain.
+// The batch size is always the last query argument.
+func (s *AuditService) purgeBatches(ctx context.Context, condition string, args ...interface{}) (int64, error) {
+	query := fmt.Sprintf(`
+		DELETE FROM audit_logs
+		WHERE id IN (
+			SELECT id FROM audit_logs
+			WHERE %s
+			LIMIT $%d
+		)`, condition, len(args)+1)
+	args = append(args, s.purgeBatchSize)
+
+	var total int64
+	for {
+		result, err := s.db.Exec(ctx, query, args...)
+		if err != nil {
+			return total, fmt.Errorf("failed to purge audit logs: %w", err)
+		}
+
+		deleted := result.RowsAffected()
+		total += deleted
+		if deleted < int64(s.purgeBatchSize) {
+			return total, nil
+		}
+	}
+}
+
 // Helper methods for common audit logging patterns
 
 func (s *AuditService) LogFileUpload(ctx context.Context, userID, fileID uuid.UUID, fileName, ipAddress, userAgent string) {
@@ -288,10 +782,10 @@ func (s *AuditService) LogFileDelete(ctx context.Context, userID, fileID uuid.UU
 	s.LogAction(ctx, entry)
 }
 
-func (s *AuditService) LogFileShare(ctx context.Context, userID, fileID uuid.UUID, fileName, sharedWithUserID, ipAddress, userAgent string) {
+func (s *AuditService) LogUserShare(ctx context.Context, userID, fileID uuid.UUID, fileName, sharedWithUserID, ipAddress, userAgent string) {
 	entry := &domain.AuditLogEntry{
 		UserID:       userID,
-		Action:       domain.ActionFileShare,
+		Action:       domain.ActionUserShare,
 		Status:       domain.StatusSuccess,
 		ResourceType: "file",
 		ResourceID:   &fileID,
@@ -305,6 +799,25 @@ func (s *AuditService) LogFileShare(ctx context.Context, userID, fileID uuid.UUI
 	s.LogAction(ctx, entry)
 }
 
+// LogFolderShare records that the user shared a folder, and everything beneath it, with another user
+func (s *AuditService) LogFolderShare(ctx context.Context, userID, folderID uuid.UUID, folderName, sharedWithUserID string, permission domain.PermissionType, ipAddress, userAgent string) {
+	entry := &domain.AuditLogEntry{
+		UserID:       userID,
+		Action:       domain.ActionFolderShare,
+		Status:       domain.StatusSuccess,
+		ResourceType: "folder",
+		ResourceID:   &folderID,
+		ResourceName: folderName,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		Metadata: map[string]interface{}{
+			"shared_with_user_id": sharedWithUserID,
+			"permission_type":     permission,
+		},
+	}
+	s.LogAction(ctx, entry)
+}
+
 func (s *AuditService) LogPublicShare(ctx context.Context, userID, fileID uuid.UUID, fileName, shareToken, ipAddress, userAgent string) {
 	entry := &domain.AuditLogEntry{
 		UserID:       userID,
@@ -320,4 +833,197 @@ func (s *AuditService) LogPublicShare(ctx context.Context, userID, fileID uuid.U
 		},
 	}
 	s.LogAction(ctx, entry)
-}
\ No newline at end of file
+}
+func (s *AuditService) LogShareRevoked(ctx context.Context, userID, fileID uuid.UUID, fileName, sharedWithUserID, ipAddress, userAgent string) {
+	entry := &domain.AuditLogEntry{
+		UserID:       userID,
+		Action:       domain.ActionShareRevoked,
+		Status:       domain.StatusSuccess,
+		ResourceType: "file",
+		ResourceID:   &fileID,
+		ResourceName: fileName,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		Metadata: map[string]interface{}{
+			"shared_with_user_id": sharedWithUserID,
+		},
+	}
+	s.LogAction(ctx, entry)
+}
+
+func (s *AuditService) LogFileMove(ctx context.Context, userID, fileID uuid.UUID, fileName string, folderID *uuid.UUID, ipAddress, userAgent string) {
+	var targetFolder interface{}
+	if folderID != nil {
+		targetFolder = folderID.String()
+	}
+
+	entry := &domain.AuditLogEntry{
+		UserID:       userID,
+		Action:       domain.ActionFileMove,
+		Status:       domain.StatusSuccess,
+		ResourceType: "file",
+		ResourceID:   &fileID,
+		ResourceName: fileName,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		Metadata: map[string]interface{}{
+			"folder_id": targetFolder,
+		},
+	}
+	s.LogAction(ctx, entry)
+}
+
+func (s *AuditService) LogFileRename(ctx context.Context, userID, fileID uuid.UUID, oldName, newName, ipAddress, userAgent string) {
+	entry := &domain.AuditLogEntry{
+		UserID:       userID,
+		Action:       domain.ActionFileRename,
+		Status:       domain.StatusSuccess,
+		ResourceType: "file",
+		ResourceID:   &fileID,
+		ResourceName: newName,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		Metadata: map[string]interface{}{
+			"old_name": oldName,
+			"new_name": newName,
+		},
+	}
+	s.LogAction(ctx, entry)
+}
+
+func (s *AuditService) LogFolderCreate(ctx context.Context, userID, folderID uuid.UUID, folderName, ipAddress, userAgent string) {
+	entry := &domain.AuditLogEntry{
+		UserID:       userID,
+		Action:       domain.ActionFolderCreate,
+		Status:       domain.StatusSuccess,
+		ResourceType: "folder",
+		ResourceID:   &folderID,
+		ResourceName: folderName,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+	}
+	s.LogAction(ctx, entry)
+}
+
+func (s *AuditService) LogFolderRename(ctx context.Context, userID, folderID uuid.UUID, oldName, newName, ipAddress, userAgent string) {
+	entry := &domain.AuditLogEntry{
+		UserID:       userID,
+		Action:       domain.ActionFolderRename,
+		Status:       domain.StatusSuccess,
+		ResourceType: "folder",
+		ResourceID:   &folderID,
+		ResourceName: newName,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		Metadata: map[string]interface{}{
+			"old_name": oldName,
+			"new_name": newName,
+		},
+	}
+	s.LogAction(ctx, entry)
+}
+
+func (s *AuditService) LogFolderMove(ctx context.Context, userID, folderID uuid.UUID, folderName string, oldParentID, newParentID *uuid.UUID, ipAddress, userAgent string) {
+	entry := &domain.AuditLogEntry{
+		UserID:       userID,
+		Action:       domain.ActionFolderMove,
+		Status:       domain.StatusSuccess,
+		ResourceType: "folder",
+		ResourceID:   &folderID,
+		ResourceName: folderName,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		Metadata: map[string]interface{}{
+			"old_parent_id": oldParentID,
+			"new_parent_id": newParentID,
+		},
+	}
+	s.LogAction(ctx, entry)
+}
+
+func (s *AuditService) LogFolderDelete(ctx context.Context, userID, folderID uuid.UUID, folderName, ipAddress, userAgent string) {
+	entry := &domain.AuditLogEntry{
+		UserID:       userID,
+		Action:       domain.ActionFolderDelete,
+		Status:       domain.StatusSuccess,
+		ResourceType: "folder",
+		ResourceID:   &folderID,
+		ResourceName: folderName,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+	}
+	s.LogAction(ctx, entry)
+}
+
+func (s *AuditService) LogFileQuarantine(ctx context.Context, userID, fileID uuid.UUID, fileName, reason string) {
+	entry := &domain.AuditLogEntry{
+		UserID:       userID,
+		Action:       domain.ActionFileQuarantine,
+		Status:       domain.StatusSuccess,
+		ResourceType: "file",
+		ResourceID:   &fileID,
+		ResourceName: fileName,
+		Metadata: map[string]interface{}{
+			"reason": reason,
+		},
+	}
+	s.LogAction(ctx, entry)
+}
+
+func (s *AuditService) LogQuotaUpdate(ctx context.Context, adminID, targetUserID uuid.UUID, targetName string, oldQuota, newQuota int64, ipAddress, userAgent string) {
+	entry := &domain.AuditLogEntry{
+		UserID:       adminID,
+		Action:       domain.ActionQuotaUpdate,
+		Status:       domain.StatusSuccess,
+		ResourceType: "user",
+		ResourceID:   &targetUserID,
+		ResourceName: targetName,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		Metadata: map[string]interface{}{
+			"old_quota": oldQuota,
+			"new_quota": newQuota,
+		},
+	}
+	s.LogAction(ctx, entry)
+}
+
+// LogUserLogin records a successful sign-in
+func (s *AuditService) LogUserLogin(ctx context.Context, userID uuid.UUID, ipAddress, userAgent string) {
+	entry := &domain.AuditLogEntry{
+		UserID:       userID,
+		Action:       domain.ActionUserLogin,
+		Status:       domain.StatusSuccess,
+		ResourceType: "user",
+		ResourceID:   &userID,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+	}
+	s.LogAction(ctx, entry)
+}
+
+// LogMemberRemove records that an enterprise OWNER or ADMIN removed a member, and what became of
+// the member's files
+func (s *AuditService) LogMemberRemove(ctx context.Context, adminID uuid.UUID, removal *domain.MemberRemoval, ipAddress, userAgent string) {
+	metadata := map[string]interface{}{
+		"enterprise_id":  removal.EnterpriseID.String(),
+		"file_policy":    removal.FilePolicy,
+		"files_affected": removal.FilesAffected,
+	}
+	if removal.ReassignedTo != nil {
+		metadata["reassigned_to"] = removal.ReassignedTo.String()
+	}
+
+	entry := &domain.AuditLogEntry{
+		UserID:       adminID,
+		Action:       domain.ActionMemberRemove,
+		Status:       domain.StatusSuccess,
+		ResourceType: "user",
+		ResourceID:   &removal.UserID,
+		ResourceName: removal.Email,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		Metadata:     metadata,
+	}
+	s.LogAction(ctx, entry)
+}
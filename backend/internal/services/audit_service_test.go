@@ -0,0 +1,226 @@
+//go:build integration
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/testutil"
+)
+
+// seedAuditLog inserts an audit row with a fixed timestamp
+func seedAuditLog(t *testing.T, db *pgxpool.Pool, userID uuid.UUID, action domain.AuditAction, createdAt time.Time) {
+	t.Helper()
+
+	_, err := db.Exec(context.Background(), `
+		INSERT INTO audit_logs (id, user_id, action, status, resource_type, resource_name, description, ip_address, created_at)
+		VALUES ($1, $2, $3, 'SUCCESS', 'file', 'seed.txt', 'seeded', '127.0.0.1', $4)`,
+		uuid.New(), userID, action, createdAt)
+	if err != nil {
+		t.Fatalf("Failed to seed audit log: %v", err)
+	}
+}
+
+func TestAuditService_ExportAuditLogsCSV(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewAuditService(db, zap.NewNop())
+	userID := testutil.CreateUser(t, db, "exporter", nil)
+
+	base := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	seedAuditLog(t, db, userID, domain.ActionFileUpload, base.AddDate(0, 0, -5)) // before range
+	seedAuditLog(t, db, userID, domain.ActionFileUpload, base)
+	seedAuditLog(t, db, userID, domain.ActionFileDownload, base.Add(time.Hour))
+	seedAuditLog(t, db, userID, domain.ActionFileUpload, base.Add(2*time.Hour))
+	seedAuditLog(t, db, userID, domain.ActionFileUpload, base.AddDate(0, 0, 5)) // after range
+
+	from := base.Add(-time.Minute)
+	to := base.AddDate(0, 0, 1)
+
+	var buf bytes.Buffer
+	count, err := service.ExportAuditLogs(context.Background(), &buf, AuditExportCSV, domain.AuditLogFilter{
+		UserID: &userID,
+		From:   &from,
+		To:     &to,
+	})
+	if err != nil {
+		t.Fatalf("ExportAuditLogs failed: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse exported csv: %v", err)
+	}
+
+	if count != 3 {
+		t.Errorf("Expected 3 exported rows, got %d", count)
+	}
+	if len(records) != count+1 {
+		t.Errorf("Expected %d csv records including header, got %d", count+1, len(records))
+	}
+	for _, record := range records[1:] {
+		ts, err := time.Parse(time.RFC3339, record[0])
+		if err != nil {
+			t.Fatalf("Invalid timestamp %q: %v", record[0], err)
+		}
+		if ts.Before(from) || !ts.Before(to) {
+			t.Errorf("Exported row at %s is outside the requested range", ts)
+		}
+	}
+
+	action := domain.ActionFileDownload
+	buf.Reset()
+	count, err = service.ExportAuditLogs(context.Background(), &buf, AuditExportCSV, domain.AuditLogFilter{
+		UserID: &userID,
+		From:   &from,
+		To:     &to,
+		Action: &action,
+	})
+	if err != nil {
+		t.Fatalf("ExportAuditLogs with action filter failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 exported row for action filter, got %d", count)
+	}
+}
+
+func TestParseAuditDateBound(t *testing.T) {
+	day := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    string
+		endOfDay bool
+		want     *time.Time
+		wantErr  bool
+	}{
+		{"empty is no bound", "", true, nil, false},
+		{"date as lower bound", "2024-03-05", false, &day, false},
+		{"date as upper bound covers the day", "2024-03-05", true, func() *time.Time { t := day.AddDate(0, 0, 1); return &t }(), false},
+		{"timestamp is taken as is", "2024-03-05T12:30:00Z", true, func() *time.Time { t := day.Add(12*time.Hour + 30*time.Minute); return &t }(), false},
+		{"garbage", "last tuesday", false, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAuditDateBound(tt.value, tt.endOfDay)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuditService_PurgeOlderThan(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewAuditService(db, zap.NewNop())
+	service.purgeBatchSize = 2
+	userID := testutil.CreateUser(t, db, "retention", nil)
+
+	cutoff := time.Date(2001, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		seedAuditLog(t, db, userID, domain.ActionFileUpload, cutoff.AddDate(0, 0, -(i+1)))
+	}
+	seedAuditLog(t, db, userID, domain.ActionFileUpload, cutoff.AddDate(0, 0, 1))
+	seedAuditLog(t, db, userID, domain.ActionFileDownload, cutoff.AddDate(0, 0, 2))
+
+	deleted, err := service.PurgeOlderThan(context.Background(), cutoff)
+	if err != nil {
+		t.Fatalf("PurgeOlderThan failed: %v", err)
+	}
+	if deleted < 5 {
+		t.Errorf("Expected at least 5 rows deleted across batches, got %d", deleted)
+	}
+
+	var older, newer int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM audit_logs WHERE user_id = $1 AND created_at < $2", userID, cutoff).Scan(&older)
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM audit_logs WHERE user_id = $1 AND created_at >= $2", userID, cutoff).Scan(&newer)
+	if older != 0 {
+		t.Errorf("Expected no rows older than the cutoff, got %d", older)
+	}
+	if newer != 2 {
+		t.Errorf("Expected 2 rows newer than the cutoff to remain, got %d", newer)
+	}
+}
+
+func TestAuditService_ApplyRetentionOverrides(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewAuditService(db, zap.NewNop())
+	ctx := context.Background()
+
+	keepForever := testutil.CreateEnterprise(t, db)
+	db.Exec(ctx, "UPDATE enterprises SET settings = jsonb_build_object($1::text, 0) WHERE id = $2", AuditRetentionSettingKey, keepForever)
+	overflowing := testutil.CreateEnterprise(t, db)
+	db.Exec(ctx, "UPDATE enterprises SET settings = jsonb_build_object($1::text, '99999999999') WHERE id = $2", AuditRetentionSettingKey, overflowing)
+
+	keptUser := testutil.CreateUser(t, db, "retention-forever", &keepForever)
+	overflowUser := testutil.CreateUser(t, db, "retention-overflow", &overflowing)
+	defaultUser := testutil.CreateUser(t, db, "retention-default", nil)
+
+	old := time.Now().AddDate(-5, 0, 0)
+	for _, userID := range []uuid.UUID{keptUser, overflowUser, defaultUser} {
+		seedAuditLog(t, db, userID, domain.ActionFileUpload, old)
+	}
+
+	if _, err := service.ApplyRetention(ctx, 30); err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+
+	count := func(userID uuid.UUID) int {
+		var n int
+		db.QueryRow(ctx, "SELECT COUNT(*) FROM audit_logs WHERE user_id = $1", userID).Scan(&n)
+		return n
+	}
+	if n := count(keptUser); n != 1 {
+		t.Errorf("Expected a retention of 0 to keep logs forever, got %d rows", n)
+	}
+	if n := count(overflowUser); n != 1 {
+		t.Errorf("Expected logs under an invalid retention setting to be kept, got %d rows", n)
+	}
+	if n := count(defaultUser); n != 0 {
+		t.Errorf("Expected logs past the default window to be purged, got %d rows", n)
+	}
+}
+
+func TestAuditService_GetActivityTimeSeries(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewAuditService(db, zap.NewNop())
+	userID := testutil.CreateUser(t, db, "charts", nil)
+
+	now := time.Now()
+	seedAuditLog(t, db, userID, domain.ActionFileUpload, now.AddDate(0, 0, -2))
+	seedAuditLog(t, db, userID, domain.ActionFileUpload, now.AddDate(0, 0, -2))
+	seedAuditLog(t, db, userID, domain.ActionFileDownload, now)
+
+	series, err := service.GetActivityTimeSeries(context.Background(), userID, 3, domain.ActivityBucketDay)
+	if err != nil {
+		t.Fatalf("GetActivityTimeSeries failed: %v", err)
+	}
+
+	if len(series) != 3 {
+		t.Fatalf("Expected 3 daily buckets, got %d", len(series))
+	}
+
+	if got := series[0].Counts[string(domain.ActionFileUpload)]; got != 2 {
+		t.Errorf("Expected 2 uploads on the first day, got %d", got)
+	}
+	if series[1].Total != 0 || len(series[1].Counts) != 0 {
+		t.Errorf("Expected the middle day to be present with zero counts, got total %d and counts %v", series[1].Total, series[1].Counts)
+	}
+	if got := series[2].Counts[string(domain.ActionFileDownload)]; got != 1 {
+		t.Errorf("Expected 1 download on the last day, got %d", got)
+	}
+	if !series[0].Bucket.Before(series[1].Bucket) || !series[1].Bucket.Before(series[2].Bucket) {
+		t.Error("Expected buckets in ascending order")
+	}
+}
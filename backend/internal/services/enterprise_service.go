@@ -0,0 +1,913 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+)
+
+type EnterpriseService struct {
+	db *pgxpool.Pool
+}
+
+func NewEnterpriseService(db *pgxpool.Pool) *EnterpriseService {
+	return &EnterpriseService{db: db}
+}
+
+// invitationTTL is how long an enterprise invitation stays valid
+const invitationTTL = 7 * 24 * time.Hour
+
+const enterpriseColumns = `
+	id, name, slug, domain, domain_verified, storage_quota, storage_used, max_users, current_users, settings,
+	subscription_plan, subscription_status, subscription_expires_at, billing_email, created_at, updated_at`
+
+// CreateEnterprise creates a new enterprise and makes the creator its OWNER. Its domain starts out
+// unverified, and only a platform admin may set its restricted settings.
+func (s *EnterpriseService) CreateEnterprise(ctx context.Context, ownerID uuid.UUID, req domain.CreateEnterpriseRequest) (*domain.Enterprise, error) {
+	name := strings.TrimSpace(req.Name)
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if len(name) < 2 {
+		return nil, fmt.Errorf("enterprise name must be at least 2 characters")
+	}
+	if len(slug) < 2 {
+		return nil, fmt.Errorf("enterprise slug must be at least 2 characters")
+	}
+
+	var currentEnterpriseID *uuid.UUID
+	var currentRole *domain.EnterpriseRole
+	err := s.db.QueryRow(ctx, "SELECT enterprise_id, enterprise_role FROM users WHERE id = $1", ownerID).Scan(&currentEnterpriseID, &currentRole)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if currentRole != nil && *currentRole == domain.EnterpriseRoleOwner {
+		return nil, fmt.Errorf("user already owns an enterprise")
+	}
+
+	var slugTaken bool
+	err = s.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM enterprises WHERE slug = $1)", slug).Scan(&slugTaken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check enterprise slug: %w", err)
+	}
+	if slugTaken {
+		return nil, fmt.Errorf("enterprise slug '%s' is already taken", slug)
+	}
+
+	settings := req.Settings
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+	platformAdmin, err := s.isPlatformAdmin(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRestrictedSettings(nil, settings, platformAdmin); err != nil {
+		return nil, err
+	}
+
+	enterprise := &domain.Enterprise{
+		ID:                 uuid.New(),
+		Name:               name,
+		Slug:               slug,
+		Domain:             req.Domain,
+		CurrentUsers:       1,
+		Settings:           settings,
+		SubscriptionPlan:   domain.SubscriptionPlanBasic,
+		SubscriptionStatus: domain.SubscriptionStatusActive,
+		BillingEmail:       req.BillingEmail,
+		CreatedAt:          time.Now(),
+		UpdatedAt:          time.Now(),
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO enterprises (id, name, slug, domain, current_users, settings, subscription_plan,
+		                         subscription_status, billing_email, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING storage_quota, max_users`,
+		enterprise.ID, enterprise.Name, enterprise.Slug, enterprise.Domain, enterprise.CurrentUsers,
+		enterprise.Settings, enterprise.SubscriptionPlan, enterprise.SubscriptionStatus,
+		enterprise.BillingEmail, enterprise.CreatedAt, enterprise.UpdatedAt,
+	).Scan(&enterprise.StorageQuota, &enterprise.MaxUsers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create enterprise: %w", err)
+	}
+
+	// The creator leaves their previous enterprise (usually the default one)
+	if currentEnterpriseID != nil {
+		_, err = tx.Exec(ctx, `
+			UPDATE enterprises SET current_users = GREATEST(current_users - 1, 0), updated_at = NOW()
+			WHERE id = $1`, *currentEnterpriseID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update previous enterprise: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET enterprise_id = $1, enterprise_role = $2, updated_at = NOW()
+		WHERE id = $3`, enterprise.ID, domain.EnterpriseRoleOwner, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign enterprise owner: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit enterprise: %w", err)
+	}
+
+	return enterprise, nil
+}
+
+// GetEnterpriseByID gets an enterprise by ID
+func (s *EnterpriseService) GetEnterpriseByID(ctx context.Context, enterpriseID uuid.UUID) (*domain.Enterprise, error) {
+	enterprise := &domain.Enterprise{}
+	err := s.db.QueryRow(ctx, "SELECT "+enterpriseColumns+" FROM enterprises WHERE id = $1", enterpriseID).Scan(
+		&enterprise.ID, &enterprise.Name, &enterprise.Slug, &enterprise.Domain, &enterprise.DomainVerified, &enterprise.StorageQuota,
+		&enterprise.StorageUsed, &enterprise.MaxUsers, &enterprise.CurrentUsers, &enterprise.Settings,
+		&enterprise.SubscriptionPlan, &enterprise.SubscriptionStatus, &enterprise.SubscriptionExpires,
+		&enterprise.BillingEmail, &enterprise.CreatedAt, &enterprise.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("enterprise not found: %w", err)
+	}
+
+	return enterprise, nil
+}
+
+// GetUserEnterprise returns the enterprise the user belongs to, or nil if they have none
+func (s *EnterpriseService) GetUserEnterprise(ctx context.Context, userID uuid.UUID) (*domain.Enterprise, error) {
+	var enterpriseID *uuid.UUID
+	err := s.db.QueryRow(ctx, "SELECT enterprise_id FROM users WHERE id = $1", userID).Scan(&enterpriseID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if enterpriseID == nil {
+		return nil, nil
+	}
+
+	return s.GetEnterpriseByID(ctx, *enterpriseID)
+}
+
+// UpdateEnterprise applies the non-nil fields of req; only the enterprise's OWNER or ADMIN, or a
+// platform admin, may update it. The seat and storage limits, the domain, which is verified once
+// set, and the restricted settings are for platform admins alone.
+func (s *EnterpriseService) UpdateEnterprise(ctx context.Context, userID, enterpriseID uuid.UUID, req domain.UpdateEnterpriseRequest) (*domain.Enterprise, error) {
+	platformAdmin, err := s.isPlatformAdmin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !platformAdmin {
+		if err := s.requireRole(ctx, userID, enterpriseID, domain.EnterpriseRoleOwner, domain.EnterpriseRoleAdmin); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Name != nil && len(strings.TrimSpace(*req.Name)) < 2 {
+		return nil, fmt.Errorf("enterprise name must be at least 2 characters")
+	}
+	if req.MaxUsers != nil && *req.MaxUsers < 1 {
+		return nil, fmt.Errorf("max users must be at least 1")
+	}
+	if req.StorageQuota != nil && *req.StorageQuota < 0 {
+		return nil, fmt.Errorf("storage quota cannot be negative")
+	}
+	if !platformAdmin && (req.MaxUsers != nil || req.StorageQuota != nil || req.Domain != nil) {
+		return nil, ErrPermissionDenied
+	}
+	if req.Settings != nil {
+		var current map[string]interface{}
+		if err := s.db.QueryRow(ctx, "SELECT settings FROM enterprises WHERE id = $1", enterpriseID).Scan(&current); err != nil {
+			return nil, fmt.Errorf("enterprise not found: %w", err)
+		}
+		if err := checkRestrictedSettings(current, req.Settings, platformAdmin); err != nil {
+			return nil, err
+		}
+	}
+
+	var setParts []string
+	var args []interface{}
+	argIndex := 1
+
+	addField := func(column string, value interface{}) {
+		setParts = append(setParts, fmt.Sprintf("%s = $%d", column, argIndex))
+		args = append(args, value)
+		argIndex++
+	}
+
+	if req.Name != nil {
+		addField("name", strings.TrimSpace(*req.Name))
+	}
+	if req.Domain != nil {
+		addField("domain", *req.Domain)
+		addField("domain_verified", true)
+	}
+	if req.BillingEmail != nil {
+		addField("billing_email", *req.BillingEmail)
+	}
+	if req.Settings != nil {
+		addField("settings", req.Settings)
+	}
+	if req.MaxUsers != nil {
+		addField("max_users", *req.MaxUsers)
+	}
+	if req.StorageQuota != nil {
+		addField("storage_quota", *req.StorageQuota)
+	}
+
+	if len(setParts) > 0 {
+		setParts = append(setParts, "updated_at = NOW()")
+		args = append(args, enterpriseID)
+		query := fmt.Sprintf("UPDATE enterprises SET %s WHERE id = $%d", strings.Join(setParts, ", "), argIndex)
+
+		if _, err := s.db.Exec(ctx, query, args...); err != nil {
+			return nil, fmt.Errorf("failed to update enterprise: %w", err)
+		}
+	}
+
+	return s.GetEnterpriseByID(ctx, enterpriseID)
+}
+
+// GetEnterpriseStats returns usage statistics; any member of the enterprise may view them
+func (s *EnterpriseService) GetEnterpriseStats(ctx context.Context, userID, enterpriseID uuid.UUID) (*domain.EnterpriseStats, error) {
+	if err := s.requireRole(ctx, userID, enterpriseID); err != nil {
+		return nil, err
+	}
+
+	stats := &domain.EnterpriseStats{}
+	err := s.db.QueryRow(ctx, `
+		SELECT e.storage_quota,
+		       (SELECT COUNT(*) FROM users u WHERE u.enterprise_id = e.id),
+		       (SELECT COUNT(*) FROM users u
+		        WHERE u.enterprise_id = e.id AND u.last_login_at > NOW() - INTERVAL '30 days'),
+		       (SELECT COUNT(*) FROM files f JOIN users u ON f.user_id = u.id WHERE u.enterprise_id = e.id),
+		       (SELECT COUNT(*) FROM files f JOIN users u ON f.user_id = u.id
+		        WHERE u.enterprise_id = e.id AND f.upload_date >= date_trunc('month', NOW())),
+		       (SELECT COALESCE(SUM(f.file_size), 0) FROM files f JOIN users u ON f.user_id = u.id
+		        WHERE u.enterprise_id = e.id)
+		FROM enterprises e
+		WHERE e.id = $1`, enterpriseID).Scan(
+		&stats.StorageQuota, &stats.TotalUsers, &stats.ActiveUsers,
+		&stats.TotalFiles, &stats.FilesThisMonth, &stats.StorageUsed,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get enterprise stats: %w", err)
+	}
+
+	if stats.StorageQuota > 0 {
+		stats.StorageUsagePerc = float64(stats.StorageUsed) / float64(stats.StorageQuota) * 100
+	}
+
+	return stats, nil
+}
+
+// memberSearchCondition matches the members of enterprise $1 whose name or email contains $2,
+// an already escaped LIKE pattern; an empty $2 matches every member
+const memberSearchCondition = `enterprise_id = $1
+		AND ($2 = '' OR name ILIKE '%' || $2 || '%' ESCAPE '\' OR email ILIKE '%' || $2 || '%' ESCAPE '\')`
+
+// ListMembers returns one page of the enterprise's members, ordered by name, and how many members
+// match in total; only its OWNER or ADMIN may list them. A non-empty search keeps members whose name
+// or email contains it, ignoring case. Password hashes are never loaded.
+func (s *EnterpriseService) ListMembers(ctx context.Context, userID, enterpriseID uuid.UUID, search string, limit, offset int) ([]*domain.User, int, error) {
+	if err := s.requireRole(ctx, userID, enterpriseID, domain.EnterpriseRoleOwner, domain.EnterpriseRoleAdmin); err != nil {
+		return nil, 0, err
+	}
+
+	pattern := escapeLike(strings.TrimSpace(search))
+
+	var total int
+	err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM users WHERE "+memberSearchCondition, enterpriseID, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count enterprise members: %w", err)
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id, email, name, profile_image, role, storage_used, storage_quota, email_verified,
+		       last_login_at, enterprise_id, enterprise_role, created_at, updated_at
+		FROM users
+		WHERE `+memberSearchCondition+`
+		ORDER BY name, id
+		LIMIT $3 OFFSET $4`,
+		enterpriseID, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list enterprise members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*domain.User
+	for rows.Next() {
+		user := &domain.User{}
+		err := rows.Scan(
+			&user.ID, &user.Email, &user.Name, &user.ProfileImage, &user.Role, &user.StorageUsed,
+			&user.StorageQuota, &user.EmailVerified, &user.LastLoginAt, &user.EnterpriseID,
+			&user.EnterpriseRole, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan enterprise member: %w", err)
+		}
+		members = append(members, user)
+	}
+
+	return members, total, rows.Err()
+}
+
+// SearchFiles returns one page of files whose name contains search, ignoring case, newest first,
+// and how many match in total. An OWNER or ADMIN of an enterprise searches the files of every
+// member; anyone else searches only their own files. Each file carries its owner's name and email
+// in User. Quarantined files are left out.
+func (s *EnterpriseService) SearchFiles(ctx context.Context, userID uuid.UUID, search string, limit, offset int) ([]*domain.File, int, error) {
+	var enterpriseID *uuid.UUID
+	var role *domain.EnterpriseRole
+	err := s.db.QueryRow(ctx, "SELECT enterprise_id, enterprise_role FROM users WHERE id = $1", userID).Scan(&enterpriseID, &role)
+	if err != nil {
+		return nil, 0, fmt.Errorf("user not found: %w", err)
+	}
+
+	// $1 is the caller and $2 an escaped LIKE pattern; $3 is the enterprise whose members' files
+	// are searched, or NULL to search only the caller's
+	var scope *uuid.UUID
+	if enterpriseID != nil && role != nil && (*role == domain.EnterpriseRoleOwner || *role == domain.EnterpriseRoleAdmin) {
+		scope = enterpriseID
+	}
+	const condition = `f.status <> 'INFECTED'
+		AND ($2 = '' OR f.original_name ILIKE '%' || $2 || '%' ESCAPE '\')
+		AND (u.enterprise_id = $3 OR ($3::uuid IS NULL AND f.user_id = $1))`
+	pattern := escapeLike(strings.TrimSpace(search))
+
+	var total int
+	err = s.db.QueryRow(ctx, "SELECT COUNT(*) FROM files f JOIN users u ON u.id = f.user_id WHERE "+condition,
+		userID, pattern, scope).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count enterprise files: %w", err)
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT f.id, f.user_id, f.folder_id, f.filename, f.original_name, f.mime_type, f.file_size,
+		       f.content_hash, f.description, f.tags, f.visibility, f.share_token, f.download_count,
+		       f.upload_date, f.updated_at, u.name, u.email
+		FROM files f
+		JOIN users u ON u.id = f.user_id
+		WHERE `+condition+`
+		ORDER BY f.upload_date DESC, f.id DESC
+		LIMIT $4 OFFSET $5`,
+		userID, pattern, scope, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search enterprise files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []*domain.File{}
+	for rows.Next() {
+		file := &domain.File{}
+		owner := &domain.User{}
+		err := rows.Scan(
+			&file.ID, &file.UserID, &file.FolderID, &file.Filename, &file.OriginalName,
+			&file.MimeType, &file.FileSize, &file.ContentHash, &file.Description,
+			&file.Tags, &file.Visibility, &file.ShareToken, &file.DownloadCount,
+			&file.UploadDate, &file.UpdatedAt, &owner.Name, &owner.Email)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan enterprise file: %w", err)
+		}
+		owner.ID = file.UserID
+		file.User = owner
+		files = append(files, file)
+	}
+
+	return files, total, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// InviteUser creates an invitation to the enterprise; only its OWNER or ADMIN may invite
+func (s *EnterpriseService) InviteUser(ctx context.Context, inviterID, enterpriseID uuid.UUID, req domain.InviteUserRequest) (*domain.EnterpriseInvitation, error) {
+	if err := s.requireRole(ctx, inviterID, enterpriseID, domain.EnterpriseRoleOwner, domain.EnterpriseRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" || !strings.Contains(email, "@") {
+		return nil, fmt.Errorf("a valid email is required")
+	}
+	if req.Role != domain.EnterpriseRoleAdmin && req.Role != domain.EnterpriseRoleMember {
+		return nil, fmt.Errorf("invitation role must be ADMIN or MEMBER")
+	}
+
+	enterprise, err := s.GetEnterpriseByID(ctx, enterpriseID)
+	if err != nil {
+		return nil, err
+	}
+	if !enterprise.CanAddUser() {
+		return nil, fmt.Errorf("enterprise has reached its user limit of %d", enterprise.MaxUsers)
+	}
+
+	var alreadyMember bool
+	err = s.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = $1 AND enterprise_id = $2)", email, enterpriseID).Scan(&alreadyMember)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing membership: %w", err)
+	}
+	if alreadyMember {
+		return nil, fmt.Errorf("user with email '%s' is already a member", email)
+	}
+
+	// One invitation per email; an expired or accepted one can be replaced
+	var existingID uuid.UUID
+	var existingExpires time.Time
+	var existingAccepted *time.Time
+	err = s.db.QueryRow(ctx, `
+		SELECT id, expires_at, accepted_at FROM enterprise_invitations
+		WHERE enterprise_id = $1 AND email = $2`, enterpriseID, email).Scan(&existingID, &existingExpires, &existingAccepted)
+	if err == nil {
+		if existingAccepted == nil && time.Now().Before(existingExpires) {
+			return nil, fmt.Errorf("an invitation for '%s' is already pending", email)
+		}
+		if _, err := s.db.Exec(ctx, "DELETE FROM enterprise_invitations WHERE id = $1", existingID); err != nil {
+			return nil, fmt.Errorf("failed to replace previous invitation: %w", err)
+		}
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to check existing invitation: %w", err)
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &domain.EnterpriseInvitation{
+		ID:           uuid.New(),
+		EnterpriseID: enterpriseID,
+		Email:        email,
+		InvitedByID:  inviterID,
+		Role:         req.Role,
+		Token:        token,
+		ExpiresAt:    time.Now().Add(invitationTTL),
+		CreatedAt:    time.Now(),
+	}
+
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO enterprise_invitations (id, enterprise_id, email, invited_by_user_id, role, token, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		invitation.ID, invitation.EnterpriseID, invitation.Email, invitation.InvitedByID,
+		invitation.Role, invitation.Token, invitation.ExpiresAt, invitation.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// AcceptInvitation adds the user to the invitation's enterprise with the invited role. With
+// moveFiles, the content only the user's files use moves to the enterprise too (see
+// migrateUserContent); joining fails with ErrQuotaExceeded if it doesn't fit the enterprise quota.
+func (s *EnterpriseService) AcceptInvitation(ctx context.Context, token string, userID uuid.UUID, moveFiles bool) (*domain.Enterprise, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var invitation domain.EnterpriseInvitation
+	err = tx.QueryRow(ctx, `
+		SELECT id, enterprise_id, email, role, expires_at, accepted_at
+		FROM enterprise_invitations
+		WHERE token = $1
+		FOR UPDATE`, token).Scan(
+		&invitation.ID, &invitation.EnterpriseID, &invitation.Email, &invitation.Role,
+		&invitation.ExpiresAt, &invitation.AcceptedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("invitation not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invitation: %w", err)
+	}
+
+	if invitation.AcceptedAt != nil {
+		return nil, fmt.Errorf("invitation has already been accepted")
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, fmt.Errorf("invitation has expired")
+	}
+
+	var email string
+	var currentEnterpriseID *uuid.UUID
+	var currentRole *domain.EnterpriseRole
+	err = tx.QueryRow(ctx, "SELECT email, enterprise_id, enterprise_role FROM users WHERE id = $1", userID).Scan(&email, &currentEnterpriseID, &currentRole)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if !strings.EqualFold(email, invitation.Email) {
+		return nil, fmt.Errorf("invitation was issued to a different email address")
+	}
+	if currentEnterpriseID != nil && *currentEnterpriseID == invitation.EnterpriseID {
+		return nil, fmt.Errorf("user is already a member of this enterprise")
+	}
+	if currentRole != nil && *currentRole == domain.EnterpriseRoleOwner {
+		return nil, fmt.Errorf("enterprise owners cannot join another enterprise")
+	}
+
+	// Lock the enterprise so concurrent accepts can't exceed max_users
+	var currentUsers, maxUsers int
+	err = tx.QueryRow(ctx, "SELECT current_users, max_users FROM enterprises WHERE id = $1 FOR UPDATE", invitation.EnterpriseID).Scan(&currentUsers, &maxUsers)
+	if err != nil {
+		return nil, fmt.Errorf("enterprise not found: %w", err)
+	}
+	if currentUsers >= maxUsers {
+		return nil, fmt.Errorf("enterprise has reached its user limit of %d", maxUsers)
+	}
+
+	if currentEnterpriseID != nil {
+		_, err = tx.Exec(ctx, `
+			UPDATE enterprises SET current_users = GREATEST(current_users - 1, 0), updated_at = NOW()
+			WHERE id = $1`, *currentEnterpriseID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update previous enterprise: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET enterprise_id = $1, enterprise_role = $2, updated_at = NOW()
+		WHERE id = $3`, invitation.EnterpriseID, invitation.Role, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join enterprise: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE enterprises SET current_users = current_users + 1, updated_at = NOW() WHERE id = $1", invitation.EnterpriseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update enterprise user count: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE enterprise_invitations SET accepted_at = NOW() WHERE id = $1", invitation.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark invitation accepted: %w", err)
+	}
+
+	if moveFiles {
+		if _, err := migrateUserContent(ctx, tx, userID, invitation.EnterpriseID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit invitation: %w", err)
+	}
+
+	return s.GetEnterpriseByID(ctx, invitation.EnterpriseID)
+}
+
+// migrateUserContent moves the storage charge for content that only userID's files reference to
+// enterpriseID: it is released from the enterprise it was counted against, if any, and reserved
+// against enterpriseID's quota. The objects stay where they are, since files find their bytes
+// through file_contents rather than by path, so nothing is copied. Content other users' files
+// also reference is left alone. Running it again moves nothing. It returns the bytes moved.
+func migrateUserContent(ctx context.Context, tx pgx.Tx, userID, enterpriseID uuid.UUID) (int64, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT fc.content_hash, fc.file_size, fc.enterprise_id
+		FROM file_contents fc
+		WHERE fc.enterprise_id IS DISTINCT FROM $2
+		  AND EXISTS (SELECT 1 FROM files f WHERE f.content_hash = fc.content_hash AND f.user_id = $1)
+		  AND NOT EXISTS (SELECT 1 FROM files f WHERE f.content_hash = fc.content_hash AND f.user_id <> $1)
+		FOR UPDATE`, userID, enterpriseID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find user content: %w", err)
+	}
+
+	var hashes []string
+	var total int64
+	released := make(map[uuid.UUID]int64)
+	for rows.Next() {
+		var hash string
+		var size int64
+		var previous *uuid.UUID
+		if err := rows.Scan(&hash, &size, &previous); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan user content: %w", err)
+		}
+		hashes = append(hashes, hash)
+		total += size
+		if previous != nil {
+			released[*previous] += size
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find user content: %w", err)
+	}
+	if len(hashes) == 0 {
+		return 0, nil
+	}
+
+	for previous, size := range released {
+		if err := releaseEnterpriseStorage(ctx, tx, &previous, size); err != nil {
+			return 0, err
+		}
+	}
+	if err := reserveEnterpriseStorage(ctx, tx, &enterpriseID, total); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE file_contents SET enterprise_id = $1 WHERE content_hash = ANY($2)", enterpriseID, hashes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to move user content: %w", err)
+	}
+
+	return total, nil
+}
+
+// ErrLastOwner is returned when removing a member would leave the enterprise without an OWNER
+var ErrLastOwner = errors.New("cannot remove the enterprise's last owner")
+
+// RemoveMember takes userID out of the enterprise: their enterprise role is cleared, the
+// enterprise's user count drops, and whatever the enterprise shared with them is revoked. policy
+// decides what happens to their own files; see MemberFilePolicy. Only the enterprise's OWNER or
+// ADMIN may remove members, only an OWNER may remove another OWNER, and the last OWNER can't be
+// removed. Retained files stay in the enterprise's storage until they are next moved.
+func (s *EnterpriseService) RemoveMember(ctx context.Context, removerID, enterpriseID, userID uuid.UUID, policy domain.MemberFilePolicy) (*domain.MemberRemoval, error) {
+	if policy != domain.MemberFilesReassign && policy != domain.MemberFilesRetain {
+		return nil, fmt.Errorf("unknown file policy %q", policy)
+	}
+	if err := s.requireRole(ctx, removerID, enterpriseID, domain.EnterpriseRoleOwner, domain.EnterpriseRoleAdmin); err != nil {
+		return nil, err
+	}
+	if policy == domain.MemberFilesReassign && removerID == userID {
+		return nil, fmt.Errorf("cannot reassign files to the member being removed")
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the enterprise so concurrent removals can't both pass the last owner check
+	if _, err := tx.Exec(ctx, "SELECT 1 FROM enterprises WHERE id = $1 FOR UPDATE", enterpriseID); err != nil {
+		return nil, fmt.Errorf("enterprise not found: %w", err)
+	}
+
+	var email, name string
+	var memberOf *uuid.UUID
+	var role *domain.EnterpriseRole
+	err = tx.QueryRow(ctx, "SELECT email, name, enterprise_id, enterprise_role FROM users WHERE id = $1", userID).Scan(&email, &name, &memberOf, &role)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	if memberOf == nil || *memberOf != enterpriseID {
+		return nil, fmt.Errorf("user is not a member of this enterprise")
+	}
+
+	if role != nil && *role == domain.EnterpriseRoleOwner {
+		var removerRole *domain.EnterpriseRole
+		if err := tx.QueryRow(ctx, "SELECT enterprise_role FROM users WHERE id = $1", removerID).Scan(&removerRole); err != nil {
+			return nil, fmt.Errorf("failed to check remover role: %w", err)
+		}
+		if removerRole == nil || *removerRole != domain.EnterpriseRoleOwner {
+			return nil, ErrPermissionDenied
+		}
+
+		var owners int
+		err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM users WHERE enterprise_id = $1 AND enterprise_role = $2",
+			enterpriseID, domain.EnterpriseRoleOwner).Scan(&owners)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count owners: %w", err)
+		}
+		if owners <= 1 {
+			return nil, ErrLastOwner
+		}
+	}
+
+	removal := &domain.MemberRemoval{
+		EnterpriseID: enterpriseID,
+		UserID:       userID,
+		Email:        email,
+		FilePolicy:   policy,
+	}
+
+	// Shares only reach members of the same enterprise
+	if err := revokeFileShares(ctx, tx, "shared_with_user_id = $1", userID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM folder_shares WHERE shared_with_user_id = $1", userID); err != nil {
+		return nil, fmt.Errorf("failed to revoke folder shares: %w", err)
+	}
+
+	switch policy {
+	case domain.MemberFilesReassign:
+		removal.FilesAffected, err = reassignMemberFiles(ctx, tx, userID, removerID, name)
+		if err != nil {
+			return nil, err
+		}
+		removal.ReassignedTo = &removerID
+	case domain.MemberFilesRetain:
+		if err := revokeFileShares(ctx, tx, "file_id IN (SELECT id FROM files WHERE user_id = $1)", userID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM folder_shares WHERE shared_by_user_id = $1", userID); err != nil {
+			return nil, fmt.Errorf("failed to revoke folder shares: %w", err)
+		}
+		if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM files WHERE user_id = $1", userID).Scan(&removal.FilesAffected); err != nil {
+			return nil, fmt.Errorf("failed to count member files: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE users SET enterprise_id = NULL, enterprise_role = NULL, updated_at = NOW() WHERE id = $1", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE enterprises SET current_users = GREATEST(current_users - 1, 0), updated_at = NOW()
+		WHERE id = $1`, enterpriseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update enterprise user count: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit member removal: %w", err)
+	}
+
+	return removal, nil
+}
+
+// reassignMemberFiles gives every file and folder of memberID to recipientID, gathered under a new
+// root folder named after the member so they don't mix with the recipient's own. Shares the member
+// made move with them. Returns how many files changed hands.
+func reassignMemberFiles(ctx context.Context, tx pgx.Tx, memberID, recipientID uuid.UUID, memberName string) (int64, error) {
+	var folderID uuid.UUID
+	err := tx.QueryRow(ctx, `
+		INSERT INTO folders (user_id, name, parent_id)
+		VALUES ($1, $2, NULL)
+		RETURNING id`, recipientID, fmt.Sprintf("Files from %s", memberName)).Scan(&folderID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create folder for reassigned files: %w", err)
+	}
+
+	statements := []string{
+		"UPDATE folders SET parent_id = $3 WHERE user_id = $1 AND parent_id IS NULL",
+		"UPDATE folders SET user_id = $2, updated_at = NOW() WHERE user_id = $1",
+		"UPDATE files SET folder_id = $3 WHERE user_id = $1 AND folder_id IS NULL",
+		"UPDATE file_references SET user_id = $2 WHERE user_id = $1",
+		// The recipient doesn't need a share of what they now own
+		"DELETE FROM file_shares WHERE shared_with_user_id = $2 AND file_id IN (SELECT id FROM files WHERE user_id = $1)",
+		"DELETE FROM folder_shares WHERE shared_with_user_id = $2 AND shared_by_user_id = $1",
+		"UPDATE file_shares SET shared_by_user_id = $2 WHERE shared_by_user_id = $1",
+		"UPDATE folder_shares SET shared_by_user_id = $2 WHERE shared_by_user_id = $1",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(ctx, statement, memberID, recipientID, folderID); err != nil {
+			return 0, fmt.Errorf("failed to reassign member files: %w", err)
+		}
+	}
+
+	result, err := tx.Exec(ctx, "UPDATE files SET user_id = $2, updated_at = NOW() WHERE user_id = $1", memberID, recipientID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reassign member files: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// revokeFileShares deletes the file shares matching where (with $1 bound to arg) and, like
+// RemoveUserShare, makes files left with no share and no public link PRIVATE again
+func revokeFileShares(ctx context.Context, tx pgx.Tx, where string, arg interface{}) error {
+	rows, err := tx.Query(ctx, "DELETE FROM file_shares WHERE "+where+" RETURNING file_id", arg)
+	if err != nil {
+		return fmt.Errorf("failed to revoke file shares: %w", err)
+	}
+	var fileIDs []uuid.UUID
+	for rows.Next() {
+		var fileID uuid.UUID
+		if err := rows.Scan(&fileID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan revoked share: %w", err)
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to revoke file shares: %w", err)
+	}
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE files f SET visibility = 'PRIVATE', updated_at = NOW()
+		WHERE f.id = ANY($1) AND f.visibility = 'SHARED_WITH_USERS' AND f.share_token IS NULL
+		AND NOT EXISTS (SELECT 1 FROM file_shares fs WHERE fs.file_id = f.id)`, fileIDs)
+	if err != nil {
+		return fmt.Errorf("failed to update file visibility: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredInvitations removes invitations that expired without being accepted
+func (s *EnterpriseService) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	result, err := s.db.Exec(ctx, "DELETE FROM enterprise_invitations WHERE accepted_at IS NULL AND expires_at < NOW()")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired invitations: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// RunInvitationSweeper deletes expired invitations immediately and then on every interval until ctx is cancelled
+func (s *EnterpriseService) RunInvitationSweeper(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.DeleteExpiredInvitations(ctx)
+		if err != nil {
+			logger.Error("Failed to delete expired invitations", zap.Error(err))
+		} else if deleted > 0 {
+			logger.Info("Deleted expired invitations", zap.Int64("deleted", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func generateInvitationToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// restrictedSettings are the enterprise settings only a platform admin may change, since they
+// decide how long the enterprise's audit trail is kept
+var restrictedSettings = []string{AuditRetentionSettingKey}
+
+// checkRestrictedSettings refuses a change from current to requested settings that touches a
+// restricted setting unless the caller is a platform admin, and validates the retention it sets
+func checkRestrictedSettings(current, requested map[string]interface{}, platformAdmin bool) error {
+	for _, key := range restrictedSettings {
+		was, had := current[key]
+		value, has := requested[key]
+		if had == has && fmt.Sprint(was) == fmt.Sprint(value) {
+			continue
+		}
+		if !platformAdmin {
+			return ErrPermissionDenied
+		}
+		if key == AuditRetentionSettingKey && has {
+			if _, err := parseAuditRetentionDays(fmt.Sprint(value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isPlatformAdmin reports whether the user administers the whole platform rather than one enterprise
+func (s *EnterpriseService) isPlatformAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var role domain.Role
+	if err := s.db.QueryRow(ctx, "SELECT role FROM users WHERE id = $1", userID).Scan(&role); err != nil {
+		return false, fmt.Errorf("user not found: %w", err)
+	}
+	return role == domain.RoleAdmin, nil
+}
+
+// requireRole checks that the user belongs to the enterprise and, when roles are given, holds one of them
+func (s *EnterpriseService) requireRole(ctx context.Context, userID, enterpriseID uuid.UUID, roles ...domain.EnterpriseRole) error {
+	var memberOf *uuid.UUID
+	var role *domain.EnterpriseRole
+	err := s.db.QueryRow(ctx, "SELECT enterprise_id, enterprise_role FROM users WHERE id = $1", userID).Scan(&memberOf, &role)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+
+	if memberOf == nil || *memberOf != enterpriseID {
+		return ErrPermissionDenied
+	}
+	if len(roles) == 0 {
+		return nil
+	}
+
+	for _, allowed := range roles {
+		if role != nil && *role == allowed {
+			return nil
+		}
+	}
+
+	return ErrPermissionDenied
+}
@@ -0,0 +1,660 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/testutil"
+)
+
+func TestEnterpriseService_CreateEnterprise(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+	ownerID := testutil.CreateUser(t, db, "founder", nil)
+
+	slug := "acme-" + uuid.NewString()[:8]
+	enterprise, err := service.CreateEnterprise(context.Background(), ownerID, domain.CreateEnterpriseRequest{
+		Name: "Acme " + slug,
+		Slug: slug,
+	})
+	if err != nil {
+		t.Fatalf("CreateEnterprise failed: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(context.Background(), "DELETE FROM enterprises WHERE id = $1", enterprise.ID)
+	})
+
+	if enterprise.Slug != slug {
+		t.Errorf("Expected slug to be '%s', got '%s'", slug, enterprise.Slug)
+	}
+	if enterprise.CurrentUsers != 1 {
+		t.Errorf("Expected 1 current user, got %d", enterprise.CurrentUsers)
+	}
+
+	var enterpriseID uuid.UUID
+	var role string
+	db.QueryRow(context.Background(), "SELECT enterprise_id, enterprise_role FROM users WHERE id = $1", ownerID).Scan(&enterpriseID, &role)
+	if enterpriseID != enterprise.ID {
+		t.Errorf("Expected creator to join enterprise %s, got %s", enterprise.ID, enterpriseID)
+	}
+	if role != string(domain.EnterpriseRoleOwner) {
+		t.Errorf("Expected creator role to be OWNER, got '%s'", role)
+	}
+
+	if _, err := service.CreateEnterprise(context.Background(), ownerID, domain.CreateEnterpriseRequest{
+		Name: "Second " + slug,
+		Slug: slug + "-2",
+	}); err == nil {
+		t.Error("Expected an owner creating a second enterprise to fail")
+	}
+}
+
+func TestEnterpriseService_GetEnterpriseStats(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	alice := testutil.CreateUser(t, db, "alice", &enterpriseID)
+	bob := testutil.CreateUser(t, db, "bob", &enterpriseID)
+	testutil.CreateFile(t, db, alice, "a.txt", []byte("stats a "+uuid.NewString()))
+	testutil.CreateFile(t, db, bob, "b.txt", []byte("stats b "+uuid.NewString()))
+
+	stats, err := service.GetEnterpriseStats(context.Background(), alice, enterpriseID)
+	if err != nil {
+		t.Fatalf("GetEnterpriseStats failed: %v", err)
+	}
+
+	if stats.TotalUsers != 2 {
+		t.Errorf("Expected 2 users, got %d", stats.TotalUsers)
+	}
+	if stats.TotalFiles != 2 {
+		t.Errorf("Expected 2 files, got %d", stats.TotalFiles)
+	}
+	if stats.FilesThisMonth != 2 {
+		t.Errorf("Expected 2 files this month, got %d", stats.FilesThisMonth)
+	}
+	if stats.StorageUsed <= 0 {
+		t.Errorf("Expected positive storage used, got %d", stats.StorageUsed)
+	}
+
+	outsider := testutil.CreateUser(t, db, "outsider", nil)
+	if _, err := service.GetEnterpriseStats(context.Background(), outsider, enterpriseID); err == nil {
+		t.Error("Expected a non-member to be denied enterprise stats")
+	}
+}
+
+func TestEnterpriseService_UpdateEnterpriseDeniedForMember(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	member := testutil.CreateUser(t, db, "member", &enterpriseID)
+
+	name := "Renamed by member"
+	if _, err := service.UpdateEnterprise(context.Background(), member, enterpriseID, domain.UpdateEnterpriseRequest{Name: &name}); err == nil {
+		t.Fatal("Expected a MEMBER to be denied updating the enterprise")
+	}
+
+	db.Exec(context.Background(), "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", member)
+	enterprise, err := service.UpdateEnterprise(context.Background(), member, enterpriseID, domain.UpdateEnterpriseRequest{Name: &name})
+	if err != nil {
+		t.Fatalf("Expected an ADMIN to update the enterprise, got: %v", err)
+	}
+	if enterprise.Name != name {
+		t.Errorf("Expected name to be '%s', got '%s'", name, enterprise.Name)
+	}
+}
+
+func TestEnterpriseService_UpdateEnterpriseRestrictedFields(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	admin := testutil.CreateUser(t, db, "admin", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", admin)
+	platformAdmin := testutil.CreateUser(t, db, "platform-admin", nil)
+	db.Exec(ctx, "UPDATE users SET role = 'ADMIN' WHERE id = $1", platformAdmin)
+
+	maxUsers := 1000
+	storageQuota := int64(1 << 50)
+	emailDomain := "restricted-" + uuid.NewString()[:8] + ".test"
+	refused := map[string]domain.UpdateEnterpriseRequest{
+		"storageQuota": {StorageQuota: &storageQuota},
+		"maxUsers":     {MaxUsers: &maxUsers},
+		"domain":       {Domain: &emailDomain},
+		"retention":    {Settings: map[string]interface{}{AuditRetentionSettingKey: 1}},
+	}
+	for field, req := range refused {
+		if _, err := service.UpdateEnterprise(ctx, admin, enterpriseID, req); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Expected an enterprise ADMIN changing %s to be denied, got: %v", field, err)
+		}
+	}
+	enterprise, err := service.GetEnterpriseByID(ctx, enterpriseID)
+	if err != nil {
+		t.Fatalf("GetEnterpriseByID failed: %v", err)
+	}
+	if enterprise.MaxUsers == maxUsers || enterprise.StorageQuota == storageQuota || enterprise.Domain != nil {
+		t.Errorf("Expected the enterprise to be unchanged, got %d users, %d bytes, domain %v", enterprise.MaxUsers, enterprise.StorageQuota, enterprise.Domain)
+	}
+
+	// Other settings stay the enterprise's own
+	if _, err := service.UpdateEnterprise(ctx, admin, enterpriseID, domain.UpdateEnterpriseRequest{
+		Settings: map[string]interface{}{DefaultMemberQuotaSettingKey: 2048},
+	}); err != nil {
+		t.Errorf("Expected an enterprise ADMIN to change other settings, got: %v", err)
+	}
+
+	invalid := map[string]interface{}{AuditRetentionSettingKey: 99999999999}
+	if _, err := service.UpdateEnterprise(ctx, platformAdmin, enterpriseID, domain.UpdateEnterpriseRequest{Settings: invalid}); err == nil {
+		t.Error("Expected an out-of-range retention to be rejected")
+	}
+
+	enterprise, err = service.UpdateEnterprise(ctx, platformAdmin, enterpriseID, domain.UpdateEnterpriseRequest{
+		MaxUsers:     &maxUsers,
+		StorageQuota: &storageQuota,
+		Domain:       &emailDomain,
+		Settings:     map[string]interface{}{AuditRetentionSettingKey: 0},
+	})
+	if err != nil {
+		t.Fatalf("Expected a platform admin to update the enterprise, got: %v", err)
+	}
+	if enterprise.MaxUsers != maxUsers || enterprise.StorageQuota != storageQuota {
+		t.Errorf("Expected %d users and %d bytes, got %d and %d", maxUsers, storageQuota, enterprise.MaxUsers, enterprise.StorageQuota)
+	}
+	if !enterprise.DomainVerified || enterprise.Domain == nil || *enterprise.Domain != emailDomain {
+		t.Errorf("Expected %s to be verified, got %v (verified %v)", emailDomain, enterprise.Domain, enterprise.DomainVerified)
+	}
+}
+
+func TestEnterpriseService_ListMembers(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	admin := testutil.CreateUser(t, db, "admin", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", admin)
+	member := testutil.CreateUser(t, db, "member", &enterpriseID)
+	carol := testutil.CreateUser(t, db, "carol_100%", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET storage_used = 4096 WHERE id = $1", carol)
+
+	otherEnterprise := testutil.CreateEnterprise(t, db)
+	testutil.CreateUser(t, db, "carol", &otherEnterprise)
+
+	t.Run("admin lists every member", func(t *testing.T) {
+		members, total, err := service.ListMembers(ctx, admin, enterpriseID, "", 20, 0)
+		if err != nil {
+			t.Fatalf("ListMembers failed: %v", err)
+		}
+		if total != 3 || len(members) != 3 {
+			t.Fatalf("Expected 3 members, got %d of %d", len(members), total)
+		}
+		for _, user := range members {
+			if user.PasswordHash != "" {
+				t.Errorf("Expected no password hash for %s", user.Name)
+			}
+			if user.EnterpriseRole == nil {
+				t.Errorf("Expected a role for %s", user.Name)
+			}
+		}
+	})
+
+	t.Run("pages keep the total", func(t *testing.T) {
+		members, total, err := service.ListMembers(ctx, admin, enterpriseID, "", 2, 2)
+		if err != nil {
+			t.Fatalf("ListMembers failed: %v", err)
+		}
+		if total != 3 || len(members) != 1 {
+			t.Errorf("Expected the last member of 3, got %d of %d", len(members), total)
+		}
+	})
+
+	t.Run("search filters by name and email", func(t *testing.T) {
+		members, total, err := service.ListMembers(ctx, admin, enterpriseID, "CAROL", 20, 0)
+		if err != nil {
+			t.Fatalf("ListMembers failed: %v", err)
+		}
+		if total != 1 || len(members) != 1 || members[0].ID != carol {
+			t.Fatalf("Expected only carol, got %d members", total)
+		}
+		if members[0].StorageUsed != 4096 {
+			t.Errorf("Expected storage used 4096, got %d", members[0].StorageUsed)
+		}
+
+		if _, total, _ := service.ListMembers(ctx, admin, enterpriseID, userEmail(t, db, member), 20, 0); total != 1 {
+			t.Errorf("Expected an email search to find the member, got %d", total)
+		}
+		// Wildcards match literally
+		if _, total, _ := service.ListMembers(ctx, admin, enterpriseID, "100%", 20, 0); total != 1 {
+			t.Errorf("Expected '100%%' to match one member, got %d", total)
+		}
+		if _, total, _ := service.ListMembers(ctx, admin, enterpriseID, "%", 20, 0); total != 1 {
+			t.Errorf("Expected '%%' to match only the name containing it, got %d", total)
+		}
+	})
+
+	t.Run("member denied", func(t *testing.T) {
+		if _, _, err := service.ListMembers(ctx, member, enterpriseID, "", 20, 0); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Expected ErrPermissionDenied, got %v", err)
+		}
+	})
+
+	t.Run("admin of another enterprise denied", func(t *testing.T) {
+		if _, _, err := service.ListMembers(ctx, admin, otherEnterprise, "", 20, 0); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Expected ErrPermissionDenied, got %v", err)
+		}
+	})
+}
+
+func userEmail(t *testing.T, db *pgxpool.Pool, userID uuid.UUID) string {
+	t.Helper()
+
+	var email string
+	if err := db.QueryRow(context.Background(), "SELECT email FROM users WHERE id = $1", userID).Scan(&email); err != nil {
+		t.Fatalf("Failed to load user email: %v", err)
+	}
+	return email
+}
+
+func TestEnterpriseService_SearchFiles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	admin := testutil.CreateUser(t, db, "admin", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", admin)
+	member := testutil.CreateUser(t, db, "member", &enterpriseID)
+	outsider := testutil.CreateUser(t, db, "outsider", nil)
+
+	marker := "compliance-" + uuid.NewString()[:8]
+	colleagueFile := testutil.CreateFile(t, db, member, marker+"-contract.pdf", []byte("contract "+uuid.NewString()))
+	adminFile := testutil.CreateFile(t, db, admin, marker+"-policy.pdf", []byte("policy "+uuid.NewString()))
+	testutil.CreateFile(t, db, outsider, marker+"-private.pdf", []byte("private "+uuid.NewString()))
+
+	ids := func(files []*domain.File) map[uuid.UUID]*domain.File {
+		found := make(map[uuid.UUID]*domain.File, len(files))
+		for _, file := range files {
+			found[file.ID] = file
+		}
+		return found
+	}
+
+	t.Run("admin sees every member's files", func(t *testing.T) {
+		files, total, err := service.SearchFiles(ctx, admin, strings.ToUpper(marker), 20, 0)
+		if err != nil {
+			t.Fatalf("SearchFiles failed: %v", err)
+		}
+		found := ids(files)
+		if total != 2 || len(found) != 2 || found[adminFile] == nil {
+			t.Fatalf("Expected the admin's and the colleague's files, got %d of %d", len(found), total)
+		}
+		if owner := found[colleagueFile].User; owner == nil || owner.Name != "member" {
+			t.Errorf("Expected the colleague's file to carry its owner, got %+v", owner)
+		}
+	})
+
+	t.Run("member sees only their own files", func(t *testing.T) {
+		files, total, err := service.SearchFiles(ctx, member, marker, 20, 0)
+		if err != nil {
+			t.Fatalf("SearchFiles failed: %v", err)
+		}
+		if found := ids(files); total != 1 || found[colleagueFile] == nil {
+			t.Errorf("Expected only the member's own file, got %d of %d", len(found), total)
+		}
+	})
+
+	t.Run("users outside an enterprise see only their own files", func(t *testing.T) {
+		if _, total, err := service.SearchFiles(ctx, outsider, marker, 20, 0); err != nil || total != 1 {
+			t.Errorf("Expected only the outsider's file, got %d, %v", total, err)
+		}
+	})
+}
+
+func TestEnterpriseService_InviteAndAccept(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	admin := testutil.CreateUser(t, db, "admin", &enterpriseID)
+	db.Exec(context.Background(), "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", admin)
+	invitee := testutil.CreateUser(t, db, "invitee", nil)
+
+	invitation, err := service.InviteUser(context.Background(), admin, enterpriseID, domain.InviteUserRequest{
+		Email: userEmail(t, db, invitee),
+		Role:  domain.EnterpriseRoleMember,
+	})
+	if err != nil {
+		t.Fatalf("InviteUser failed: %v", err)
+	}
+	if invitation.Token == "" {
+		t.Fatal("Expected invitation to have a token")
+	}
+
+	if _, err := service.InviteUser(context.Background(), admin, enterpriseID, domain.InviteUserRequest{
+		Email: userEmail(t, db, invitee),
+		Role:  domain.EnterpriseRoleMember,
+	}); err == nil {
+		t.Error("Expected a duplicate pending invitation to be rejected")
+	}
+
+	enterprise, err := service.AcceptInvitation(context.Background(), invitation.Token, invitee, false)
+	if err != nil {
+		t.Fatalf("AcceptInvitation failed: %v", err)
+	}
+	if enterprise.CurrentUsers != 1 {
+		t.Errorf("Expected current users to be 1 after accepting, got %d", enterprise.CurrentUsers)
+	}
+
+	var joinedID uuid.UUID
+	var role string
+	db.QueryRow(context.Background(), "SELECT enterprise_id, enterprise_role FROM users WHERE id = $1", invitee).Scan(&joinedID, &role)
+	if joinedID != enterpriseID || role != string(domain.EnterpriseRoleMember) {
+		t.Errorf("Expected invitee to join as MEMBER of %s, got %s as %s", enterpriseID, joinedID, role)
+	}
+
+	if _, err := service.AcceptInvitation(context.Background(), invitation.Token, invitee, false); err == nil {
+		t.Error("Expected re-accepting an invitation to be rejected")
+	}
+}
+
+func TestEnterpriseService_AcceptExpiredInvitation(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	db.Exec(context.Background(), "UPDATE users SET enterprise_role = 'OWNER' WHERE id = $1", owner)
+	invitee := testutil.CreateUser(t, db, "late", nil)
+
+	invitation, err := service.InviteUser(context.Background(), owner, enterpriseID, domain.InviteUserRequest{
+		Email: userEmail(t, db, invitee),
+		Role:  domain.EnterpriseRoleMember,
+	})
+	if err != nil {
+		t.Fatalf("InviteUser failed: %v", err)
+	}
+	db.Exec(context.Background(), "UPDATE enterprise_invitations SET expires_at = NOW() - INTERVAL '1 day' WHERE id = $1", invitation.ID)
+
+	if _, err := service.AcceptInvitation(context.Background(), invitation.Token, invitee, false); err == nil {
+		t.Error("Expected an expired invitation to be rejected")
+	}
+
+	deleted, err := service.DeleteExpiredInvitations(context.Background())
+	if err != nil {
+		t.Fatalf("DeleteExpiredInvitations failed: %v", err)
+	}
+	if deleted < 1 {
+		t.Errorf("Expected the expired invitation to be swept, got %d deleted", deleted)
+	}
+}
+
+func TestEnterpriseService_InviteAtMaxUsers(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	db.Exec(context.Background(), "UPDATE users SET enterprise_role = 'OWNER' WHERE id = $1", owner)
+	db.Exec(context.Background(), "UPDATE enterprises SET max_users = 2, current_users = 1 WHERE id = $1", enterpriseID)
+
+	first := testutil.CreateUser(t, db, "first", nil)
+	second := testutil.CreateUser(t, db, "second", nil)
+
+	// One seat left: the invitation can be created and accepted
+	invitation, err := service.InviteUser(context.Background(), owner, enterpriseID, domain.InviteUserRequest{
+		Email: userEmail(t, db, first),
+		Role:  domain.EnterpriseRoleMember,
+	})
+	if err != nil {
+		t.Fatalf("Expected an invitation below max users to succeed, got: %v", err)
+	}
+	if _, err := service.AcceptInvitation(context.Background(), invitation.Token, first, false); err != nil {
+		t.Fatalf("Expected accepting the last seat to succeed, got: %v", err)
+	}
+
+	// Now full
+	if _, err := service.InviteUser(context.Background(), owner, enterpriseID, domain.InviteUserRequest{
+		Email: userEmail(t, db, second),
+		Role:  domain.EnterpriseRoleMember,
+	}); err == nil {
+		t.Error("Expected an invitation at max users to be rejected")
+	}
+}
+
+func TestEnterpriseService_RemoveMember(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	admin := testutil.CreateUser(t, db, "admin", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", admin)
+	leaver := testutil.CreateUser(t, db, "leaver", &enterpriseID)
+	colleague := testutil.CreateUser(t, db, "colleague", &enterpriseID)
+	db.Exec(ctx, "UPDATE enterprises SET current_users = 3 WHERE id = $1", enterpriseID)
+
+	report := testutil.CreateFile(t, db, leaver, "report.txt", []byte("remove member report"))
+	testutil.ShareFile(t, db, report, leaver, colleague, domain.PermissionView)
+	shared := testutil.CreateFile(t, db, colleague, "shared.txt", []byte("remove member shared"))
+	testutil.ShareFile(t, db, shared, colleague, leaver, domain.PermissionView)
+
+	t.Run("member denied", func(t *testing.T) {
+		if _, err := service.RemoveMember(ctx, colleague, enterpriseID, leaver, domain.MemberFilesReassign); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Expected ErrPermissionDenied, got %v", err)
+		}
+	})
+
+	t.Run("admin removes and takes the files", func(t *testing.T) {
+		removal, err := service.RemoveMember(ctx, admin, enterpriseID, leaver, domain.MemberFilesReassign)
+		if err != nil {
+			t.Fatalf("RemoveMember failed: %v", err)
+		}
+		if removal.FilesAffected != 1 || removal.ReassignedTo == nil || *removal.ReassignedTo != admin {
+			t.Errorf("Expected 1 file reassigned to the admin, got %d", removal.FilesAffected)
+		}
+
+		var memberOf *uuid.UUID
+		var role *string
+		db.QueryRow(ctx, "SELECT enterprise_id, enterprise_role FROM users WHERE id = $1", leaver).Scan(&memberOf, &role)
+		if memberOf != nil || role != nil {
+			t.Error("Expected the removed member's enterprise fields to be cleared")
+		}
+
+		var currentUsers int
+		db.QueryRow(ctx, "SELECT current_users FROM enterprises WHERE id = $1", enterpriseID).Scan(&currentUsers)
+		if currentUsers != 2 {
+			t.Errorf("Expected current users to drop to 2, got %d", currentUsers)
+		}
+
+		var owner uuid.UUID
+		var folderName string
+		db.QueryRow(ctx, `
+			SELECT f.user_id, fo.name FROM files f JOIN folders fo ON fo.id = f.folder_id
+			WHERE f.id = $1`, report).Scan(&owner, &folderName)
+		if owner != admin || folderName != "Files from leaver" {
+			t.Errorf("Expected the file in the admin's 'Files from leaver' folder, got %s in %q", owner, folderName)
+		}
+
+		var sharesWithLeaver, sharesByAdmin int
+		db.QueryRow(ctx, "SELECT COUNT(*) FROM file_shares WHERE shared_with_user_id = $1", leaver).Scan(&sharesWithLeaver)
+		db.QueryRow(ctx, "SELECT COUNT(*) FROM file_shares WHERE file_id = $1 AND shared_by_user_id = $2", report, admin).Scan(&sharesByAdmin)
+		if sharesWithLeaver != 0 {
+			t.Errorf("Expected shares with the removed member to be revoked, got %d", sharesWithLeaver)
+		}
+		if sharesByAdmin != 1 {
+			t.Errorf("Expected the member's share to move to the admin, got %d", sharesByAdmin)
+		}
+	})
+
+	t.Run("not a member", func(t *testing.T) {
+		if _, err := service.RemoveMember(ctx, admin, enterpriseID, leaver, domain.MemberFilesRetain); err == nil {
+			t.Error("Expected removing a non-member to fail")
+		}
+	})
+}
+
+func TestEnterpriseService_RemoveMemberRetainsFiles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'OWNER' WHERE id = $1", owner)
+	leaver := testutil.CreateUser(t, db, "leaver", &enterpriseID)
+
+	notes := testutil.CreateFile(t, db, leaver, "notes.txt", []byte("retain member notes"))
+	db.Exec(ctx, "UPDATE files SET visibility = 'SHARED_WITH_USERS' WHERE id = $1", notes)
+	testutil.ShareFile(t, db, notes, leaver, owner, domain.PermissionView)
+
+	removal, err := service.RemoveMember(ctx, owner, enterpriseID, leaver, domain.MemberFilesRetain)
+	if err != nil {
+		t.Fatalf("RemoveMember failed: %v", err)
+	}
+	if removal.FilesAffected != 1 || removal.ReassignedTo != nil {
+		t.Errorf("Expected 1 retained file, got %d", removal.FilesAffected)
+	}
+
+	var fileOwner uuid.UUID
+	var visibility string
+	db.QueryRow(ctx, "SELECT user_id, visibility FROM files WHERE id = $1", notes).Scan(&fileOwner, &visibility)
+	if fileOwner != leaver {
+		t.Errorf("Expected the member to keep the file, got owner %s", fileOwner)
+	}
+	if visibility != string(domain.VisibilityPrivate) {
+		t.Errorf("Expected the unshared file to be PRIVATE, got %s", visibility)
+	}
+
+	var shares int
+	db.QueryRow(ctx, "SELECT COUNT(*) FROM file_shares WHERE file_id = $1", notes).Scan(&shares)
+	if shares != 0 {
+		t.Errorf("Expected the member's shares to be revoked, got %d", shares)
+	}
+}
+
+func TestEnterpriseService_RemoveLastOwner(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewEnterpriseService(db)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'OWNER' WHERE id = $1", owner)
+	admin := testutil.CreateUser(t, db, "admin", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", admin)
+
+	if _, err := service.RemoveMember(ctx, owner, enterpriseID, owner, domain.MemberFilesRetain); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("Expected ErrLastOwner, got %v", err)
+	}
+	if _, err := service.RemoveMember(ctx, admin, enterpriseID, owner, domain.MemberFilesReassign); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected an ADMIN to be denied removing an OWNER, got %v", err)
+	}
+
+	// With a second owner the first can leave
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'OWNER' WHERE id = $1", admin)
+	if _, err := service.RemoveMember(ctx, admin, enterpriseID, owner, domain.MemberFilesReassign); err != nil {
+		t.Errorf("Expected removing one of two owners to succeed, got %v", err)
+	}
+}
+
+func TestEnterpriseService_AcceptInvitationMovesFiles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	service := NewEnterpriseService(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	admin := testutil.CreateUser(t, db, "admin", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", admin)
+	db.Exec(ctx, "UPDATE enterprises SET storage_used = 0, storage_quota = 1000000 WHERE id = $1", enterpriseID)
+
+	invite := func(userID uuid.UUID) string {
+		t.Helper()
+		invitation, err := service.InviteUser(ctx, admin, enterpriseID, domain.InviteUserRequest{
+			Email: userEmail(t, db, userID),
+			Role:  domain.EnterpriseRoleMember,
+		})
+		if err != nil {
+			t.Fatalf("InviteUser failed: %v", err)
+		}
+		return invitation.Token
+	}
+	contentEnterprise := func(content []byte) *uuid.UUID {
+		var id *uuid.UUID
+		db.QueryRow(ctx, "SELECT enterprise_id FROM file_contents WHERE content_hash = $1", fmt.Sprintf("%x", sha256.Sum256(content))).Scan(&id)
+		return id
+	}
+
+	invitee := testutil.CreateUser(t, db, "mover", nil)
+	own := []byte("personal notes " + uuid.NewString())
+	more := []byte("personal photos " + uuid.NewString())
+	shared := []byte("deduplicated " + uuid.NewString())
+	testutil.CreateFile(t, db, invitee, "notes.txt", own)
+	testutil.CreateFile(t, db, invitee, "photos.txt", more)
+	testutil.CreateFile(t, db, invitee, "common.txt", shared)
+	testutil.CreateFile(t, db, testutil.CreateUser(t, db, "stranger", nil), "common.txt", shared)
+
+	if _, err := service.AcceptInvitation(ctx, invite(invitee), invitee, true); err != nil {
+		t.Fatalf("AcceptInvitation failed: %v", err)
+	}
+
+	// Content someone else's files also use isn't the new member's to bring along
+	var used int64
+	db.QueryRow(ctx, "SELECT storage_used FROM enterprises WHERE id = $1", enterpriseID).Scan(&used)
+	if want := int64(len(own) + len(more)); used != want {
+		t.Errorf("Expected the enterprise to count %d migrated bytes, got %d", want, used)
+	}
+	for _, content := range [][]byte{own, more} {
+		if got := contentEnterprise(content); got == nil || *got != enterpriseID {
+			t.Errorf("Expected the content to belong to %s, got %v", enterpriseID, got)
+		}
+	}
+	if got := contentEnterprise(shared); got != nil {
+		t.Errorf("Expected shared content to stay personal, got %v", got)
+	}
+
+	t.Run("migrating again moves nothing", func(t *testing.T) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			t.Fatalf("Begin failed: %v", err)
+		}
+		defer tx.Rollback(ctx)
+
+		moved, err := migrateUserContent(ctx, tx, invitee, enterpriseID)
+		if err != nil || moved != 0 {
+			t.Errorf("Expected nothing to move, got %d (%v)", moved, err)
+		}
+	})
+
+	t.Run("files that don't fit the quota", func(t *testing.T) {
+		latecomer := testutil.CreateUser(t, db, "latecomer", nil)
+		testutil.CreateFile(t, db, latecomer, "large.txt", []byte("too much "+uuid.NewString()))
+		db.Exec(ctx, "UPDATE enterprises SET storage_quota = storage_used WHERE id = $1", enterpriseID)
+
+		token := invite(latecomer)
+		if _, err := service.AcceptInvitation(ctx, token, latecomer, true); !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("Expected ErrQuotaExceeded, got %v", err)
+		}
+		var joined *uuid.UUID
+		db.QueryRow(ctx, "SELECT enterprise_id FROM users WHERE id = $1", latecomer).Scan(&joined)
+		if joined != nil {
+			t.Errorf("Expected a rejected migration to leave the user outside the enterprise, got %v", joined)
+		}
+
+		// Joining without the files still works and leaves their content personal
+		if _, err := service.AcceptInvitation(ctx, token, latecomer, false); err != nil {
+			t.Fatalf("AcceptInvitation without files failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+)
+
+// eventBufferSize is how many events a subscriber may fall behind before further ones are dropped
+const eventBufferSize = 32
+
+// Event is a real-time update for one user: exactly one of AuditLog and Notification is set
+type Event struct {
+	AuditLog     *domain.AuditLog
+	Notification *domain.Notification
+}
+
+// EventBroker fans out audit entries and notifications to the subscriptions of the user they
+// belong to. It is in-process: with several server instances, a client only hears about events
+// logged by the instance it is connected to.
+type EventBroker struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan Event]struct{}
+	logger      *zap.Logger
+}
+
+func NewEventBroker(logger *zap.Logger) *EventBroker {
+	return &EventBroker{
+		subscribers: make(map[uuid.UUID]map[chan Event]struct{}),
+		logger:      logger,
+	}
+}
+
+// Subscribe returns a channel receiving the user's events until the returned cancel func is called
+func (b *EventBroker) Subscribe(userID uuid.UUID) (<-chan Event, func()) {
+	events := make(chan Event, eventBufferSize)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	b.subscribers[userID][events] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[userID], events)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+			b.mu.Unlock()
+			close(events)
+		})
+	}
+	return events, cancel
+}
+
+// Publish hands an event to every subscription of the user without blocking; a subscriber whose
+// buffer is full misses it. A nil broker publishes nothing.
+func (b *EventBroker) Publish(userID uuid.UUID, event Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for events := range b.subscribers[userID] {
+		select {
+		case events <- event:
+		default:
+			b.logger.Warn("Dropped event for a slow subscriber", zap.String("user_id", userID.String()))
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// contentQuerier is what FileContentService needs of the database: the pool, or a transaction the
+// caller has begun
+type contentQuerier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// FileContentService manages the deduplicated content rows files point at. The stored path of a
+// content is recorded once in file_contents when it is uploaded; it depends on the namespace the
+// content was uploaded in and is never reconstructed.
+type FileContentService struct {
+	db contentQuerier
+}
+
+func NewFileContentService(db *pgxpool.Pool) *FileContentService {
+	return &FileContentService{db: db}
+}
+
+// inTx returns the service working within tx
+func (s *FileContentService) inTx(tx pgx.Tx) *FileContentService {
+	return &FileContentService{db: tx}
+}
+
+// AddReference counts one more file pointing at the content. Content that isn't stored is
+// ErrContentNotFound.
+func (s *FileContentService) AddReference(ctx context.Context, contentHash string) error {
+	result, err := s.db.Exec(ctx, `
+		UPDATE file_contents SET reference_count = reference_count + 1 WHERE content_hash = $1`, contentHash)
+	if err != nil {
+		return fmt.Errorf("failed to add content reference: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrContentNotFound
+	}
+	return nil
+}
+
+// ResolveStoragePath returns where the content is stored. Content that isn't stored is
+// ErrContentNotFound.
+func (s *FileContentService) ResolveStoragePath(ctx context.Context, contentHash string) (string, error) {
+	var filePath string
+	err := s.db.QueryRow(ctx, "SELECT file_path FROM file_contents WHERE content_hash = $1", contentHash).Scan(&filePath)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrContentNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve content path: %w", err)
+	}
+	return filePath, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"lokr-backend/internal/domain"
+)
+
+// ErrInvalidEncryption is returned for client encryption metadata with a missing or malformed field
+var ErrInvalidEncryption = errors.New("invalid encryption metadata")
+
+// Limits on client encryption metadata; an IV or a wrapped key is a few dozen bytes
+const (
+	maxEncryptionAlgorithmLength = 50
+	maxEncryptionFieldLength     = 1024
+)
+
+// ValidateEncryption checks client encryption metadata: an algorithm name and a base64 IV and
+// wrapped key. The server can't check that they decrypt the content, only that they are usable.
+func ValidateEncryption(encryption domain.FileEncryption) error {
+	if encryption.Algorithm == "" || len(encryption.Algorithm) > maxEncryptionAlgorithmLength {
+		return fmt.Errorf("%w: algorithm must be 1 to %d characters", ErrInvalidEncryption, maxEncryptionAlgorithmLength)
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"iv", encryption.IV},
+		{"wrappedKey", encryption.WrappedKey},
+	} {
+		if field.value == "" || len(field.value) > maxEncryptionFieldLength {
+			return fmt.Errorf("%w: %s must be 1 to %d characters", ErrInvalidEncryption, field.name, maxEncryptionFieldLength)
+		}
+		if _, err := base64.StdEncoding.DecodeString(field.value); err != nil {
+			return fmt.Errorf("%w: %s must be base64", ErrInvalidEncryption, field.name)
+		}
+	}
+	return nil
+}
+
+// UploadEncryptedFile stores content the client encrypted before uploading, with the metadata it
+// needs to decrypt it again. The content hash is taken over the ciphertext, so identical
+// ciphertext is still stored once. Encrypted files skip type detection and malware scanning, and
+// downloads return the ciphertext as it was uploaded.
+func (s *SimpleFileService) UploadEncryptedFile(ctx context.Context, userID uuid.UUID, filename, mimeType string, content []byte, encryption domain.FileEncryption, folderID *uuid.UUID) (*domain.File, error) {
+	if err := ValidateEncryption(encryption); err != nil {
+		return nil, err
+	}
+	return s.uploadContent(ctx, userID, filename, mimeType, content, folderID, nil, nil, nil, &encryption)
+}
+
+// insertFileEncryption records the encryption metadata of a new file inside tx
+func insertFileEncryption(ctx context.Context, tx pgx.Tx, fileID uuid.UUID, encryption *domain.FileEncryption) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO file_encryption (file_id, algorithm, iv, wrapped_key)
+		VALUES ($1, $2, $3, $4)`,
+		fileID, encryption.Algorithm, encryption.IV, encryption.WrappedKey)
+	if err != nil {
+		return fmt.Errorf("failed to record file encryption: %w", err)
+	}
+	return nil
+}
+
+// GetEncryption returns the encryption metadata of a file, or nil when the file isn't encrypted
+func (s *SimpleFileService) GetEncryption(ctx context.Context, fileID uuid.UUID) (*domain.FileEncryption, error) {
+	encryption := &domain.FileEncryption{}
+	err := s.db.QueryRow(ctx, `
+		SELECT algorithm, iv, wrapped_key FROM file_encryption WHERE file_id = $1`, fileID).Scan(
+		&encryption.Algorithm, &encryption.IV, &encryption.WrappedKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load file encryption: %w", err)
+	}
+	return encryption, nil
+}
+
+// MarkEncrypted sets Encryption on the files the client encrypted
+func (s *SimpleFileService) MarkEncrypted(ctx context.Context, files []*domain.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	byID := make(map[uuid.UUID]*domain.File, len(files))
+	ids := make([]uuid.UUID, 0, len(files))
+	for _, file := range files {
+		byID[file.ID] = file
+		ids = append(ids, file.ID)
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT file_id, algorithm, iv, wrapped_key FROM file_encryption WHERE file_id = ANY($1)`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to load file encryption: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileID uuid.UUID
+		encryption := &domain.FileEncryption{}
+		if err := rows.Scan(&fileID, &encryption.Algorithm, &encryption.IV, &encryption.WrappedKey); err != nil {
+			return fmt.Errorf("failed to scan file encryption: %w", err)
+		}
+		if file := byID[fileID]; file != nil {
+			file.Encryption = encryption
+		}
+	}
+	return rows.Err()
+}
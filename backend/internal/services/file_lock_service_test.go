@@ -0,0 +1,103 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/testutil"
+)
+
+func TestFileLockService(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewFileLockService(db, time.Minute)
+	ctx := context.Background()
+
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	editor := testutil.CreateUser(t, db, "editor", nil)
+	fileID := testutil.CreateFile(t, db, owner, "draft.docx", []byte("lock "+uuid.NewString()))
+
+	lock, err := service.Lock(ctx, fileID, editor)
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if lock.HolderID != editor || lock.HolderName != "editor" || !lock.ExpiresAt.After(lock.AcquiredAt) {
+		t.Errorf("Expected the editor to hold a lock that expires later, got %+v", lock)
+	}
+
+	t.Run("a second lock is refused", func(t *testing.T) {
+		var locked *FileLockedError
+		if _, err := service.Lock(ctx, fileID, owner); !errors.As(err, &locked) || locked.Lock.HolderID != editor {
+			t.Fatalf("Expected a FileLockedError naming the editor, got %v", err)
+		}
+		if err.Error() != "file is locked by editor" {
+			t.Errorf("Expected the holder in the message, got %q", err.Error())
+		}
+		if err := service.CheckUnlocked(ctx, fileID, owner); !errors.As(err, &locked) {
+			t.Errorf("Expected changes by others to be refused, got %v", err)
+		}
+		if err := service.CheckUnlocked(ctx, fileID, editor); err != nil {
+			t.Errorf("Expected the holder's changes to be allowed, got %v", err)
+		}
+		if err := service.Unlock(ctx, fileID, owner, false); !errors.As(err, &locked) {
+			t.Errorf("Expected releasing someone else's lock to be refused, got %v", err)
+		}
+	})
+
+	t.Run("the holder renews the lock", func(t *testing.T) {
+		renewed, err := service.Lock(ctx, fileID, editor)
+		if err != nil {
+			t.Fatalf("Renewing failed: %v", err)
+		}
+		if renewed.AcquiredAt.Before(lock.AcquiredAt) {
+			t.Errorf("Expected renewing to restart the lock, got %v after %v", renewed.AcquiredAt, lock.AcquiredAt)
+		}
+	})
+
+	t.Run("files carry their lock", func(t *testing.T) {
+		other := testutil.CreateFile(t, db, owner, "free.docx", []byte("free "+uuid.NewString()))
+		files := []*domain.File{{ID: fileID}, {ID: other}}
+		if err := service.MarkLocked(ctx, files); err != nil {
+			t.Fatalf("MarkLocked failed: %v", err)
+		}
+		if files[0].Lock == nil || files[0].Lock.HolderID != editor || files[1].Lock != nil {
+			t.Errorf("Expected only the locked file to carry a lock, got %+v and %+v", files[0].Lock, files[1].Lock)
+		}
+	})
+
+	t.Run("an expired lock frees the file", func(t *testing.T) {
+		db.Exec(ctx, "UPDATE file_locks SET acquired_at = NOW() - INTERVAL '2 minutes' WHERE file_id = $1", fileID)
+
+		if current, err := service.GetLock(ctx, fileID); err != nil || current != nil {
+			t.Fatalf("Expected the lapsed lock to be gone, got %+v, %v", current, err)
+		}
+		if err := service.CheckUnlocked(ctx, fileID, owner); err != nil {
+			t.Errorf("Expected changes to be allowed once the lock lapsed, got %v", err)
+		}
+		taken, err := service.Lock(ctx, fileID, owner)
+		if err != nil || taken.HolderID != owner {
+			t.Fatalf("Expected the owner to take the lapsed lock, got %+v, %v", taken, err)
+		}
+	})
+
+	t.Run("the owner may force a release", func(t *testing.T) {
+		if err := service.Unlock(ctx, fileID, owner, false); err != nil {
+			t.Fatalf("Unlock failed: %v", err)
+		}
+		if _, err := service.Lock(ctx, fileID, editor); err != nil {
+			t.Fatalf("Lock failed: %v", err)
+		}
+		if err := service.Unlock(ctx, fileID, owner, true); err != nil {
+			t.Errorf("Expected a forced release to succeed, got %v", err)
+		}
+		if current, _ := service.GetLock(ctx, fileID); current != nil {
+			t.Errorf("Expected no lock after the forced release, got %+v", current)
+		}
+	})
+}
@@ -3,7 +3,6 @@ package services
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -16,6 +15,7 @@ import (
 
 	"lokr-backend/internal/domain"
 	"lokr-backend/internal/storage"
+	"lokr-backend/pkg/hash"
 )
 
 // FileService handles file operations with deduplication
@@ -26,6 +26,7 @@ type FileService struct {
 	enterpriseRepo  domain.EnterpriseRepository
 	storageService  storage.StorageService
 	presignedService storage.PresignedURLService
+	hasher          hash.Hasher
 	logger          *zap.Logger
 }
 
@@ -46,13 +47,19 @@ func NewFileService(
 		enterpriseRepo:   enterpriseRepo,
 		storageService:   storageService,
 		presignedService: presignedService,
+		hasher:           hash.SHA256,
 		logger:           logger,
 	}
 }
 
+// SetHasher sets the algorithm new content is hashed with, SHA-256 by default
+func (s *FileService) SetHasher(hasher hash.Hasher) {
+	s.hasher = hasher
+}
+
 // UploadFile handles file upload with deduplication
 func (s *FileService) UploadFile(ctx context.Context, request *domain.FileUploadRequest) (*domain.File, error) {
-	s.logger.Info("Starting file upload",
+	RequestLogger(ctx, s.logger).Info("Starting file upload",
 		zap.String("filename", request.Filename),
 		zap.String("user_id", request.UserID.String()),
 		zap.Int64("size", request.FileSize))
@@ -65,7 +72,7 @@ func (s *FileService) UploadFile(ctx context.Context, request *domain.FileUpload
 
 	// Check user storage quota
 	if user.StorageUsed+request.FileSize > user.StorageQuota {
-		return nil, fmt.Errorf("storage quota exceeded")
+		return nil, ErrQuotaExceeded
 	}
 
 	// Check enterprise storage quota if user belongs to an enterprise
@@ -77,19 +84,22 @@ func (s *FileService) UploadFile(ctx context.Context, request *domain.FileUpload
 		}
 
 		if !enterprise.CanUseStorage(request.FileSize) {
-			return nil, fmt.Errorf("enterprise storage quota exceeded")
+			return nil, fmt.Errorf("enterprise %w", ErrQuotaExceeded)
 		}
 	}
 
-	// Calculate content hash (SHA-256)
-	contentHash := s.calculateSHA256(request.Content)
-	s.logger.Info("Calculated content hash", zap.String("hash", contentHash))
+	// Calculate content hash with the configured algorithm
+	contentHash := hash.Sum(s.hasher, request.Content)
+	RequestLogger(ctx, s.logger).Info("Calculated content hash", zap.String("hash", contentHash))
 
 	// Check if content already exists (deduplication)
 	existingContent, err := s.fileContentRepo.GetByHash(contentHash)
 	if err != nil && !isNotFoundError(err) {
 		return nil, fmt.Errorf("failed to check existing content: %w", err)
 	}
+	if existingContent != nil && existingContent.HashAlgorithm != s.hasher.Algorithm() {
+		return nil, fmt.Errorf("%w: %s hash %s is stored under another algorithm", ErrContentHashConflict, s.hasher.Algorithm(), contentHash)
+	}
 
 	var storagePath string
 	var shouldStore bool = existingContent == nil
@@ -112,6 +122,7 @@ func (s *FileService) UploadFile(ctx context.Context, request *domain.FileUpload
 		// Create file content record
 		fileContent := &domain.FileContent{
 			ContentHash:    contentHash,
+			HashAlgorithm:  s.hasher.Algorithm(),
 			FilePath:       storagePath,
 			FileSize:       request.FileSize,
 			ReferenceCount: 1,
@@ -172,11 +183,11 @@ func (s *FileService) UploadFile(ctx context.Context, request *domain.FileUpload
 	// Update user storage usage
 	err = s.userRepo.UpdateStorageUsed(user.ID, user.StorageUsed+request.FileSize)
 	if err != nil {
-		s.logger.Error("Failed to update user storage usage", zap.Error(err))
+		RequestLogger(ctx, s.logger).Error("Failed to update user storage usage", zap.Error(err))
 		// Don't fail the upload for this, but log the error
 	}
 
-	s.logger.Info("File upload completed successfully",
+	RequestLogger(ctx, s.logger).Info("File upload completed successfully",
 		zap.String("file_id", file.ID.String()),
 		zap.String("content_hash", contentHash),
 		zap.Bool("deduplicated", !shouldStore))
@@ -212,7 +223,7 @@ func (s *FileService) DownloadFile(ctx context.Context, fileID uuid.UUID, userID
 	// Increment download count
 	go func() {
 		if err := s.fileRepo.IncrementDownloadCount(fileID); err != nil {
-			s.logger.Error("Failed to increment download count", zap.Error(err))
+			RequestLogger(ctx, s.logger).Error("Failed to increment download count", zap.Error(err))
 		}
 	}()
 
@@ -241,7 +252,7 @@ func (s *FileService) DeleteFile(ctx context.Context, fileID uuid.UUID, userID u
 	// Decrement reference count
 	err = s.fileContentRepo.DecrementReference(file.ContentHash)
 	if err != nil {
-		s.logger.Error("Failed to decrement reference count", zap.Error(err))
+		RequestLogger(ctx, s.logger).Error("Failed to decrement reference count", zap.Error(err))
 		return nil // Don't fail the deletion for this
 	}
 
@@ -250,11 +261,11 @@ func (s *FileService) DeleteFile(ctx context.Context, fileID uuid.UUID, userID u
 	if err == nil && content.ReferenceCount == 0 {
 		// No more references, delete physical file and content record
 		if err := s.storageService.Delete(ctx, content.FilePath); err != nil {
-			s.logger.Error("Failed to delete file from storage", zap.Error(err))
+			RequestLogger(ctx, s.logger).Error("Failed to delete file from storage", zap.Error(err))
 		}
 
 		if err := s.fileContentRepo.Delete(file.ContentHash); err != nil {
-			s.logger.Error("Failed to delete content record", zap.Error(err))
+			RequestLogger(ctx, s.logger).Error("Failed to delete content record", zap.Error(err))
 		}
 	}
 
@@ -266,7 +277,7 @@ func (s *FileService) DeleteFile(ctx context.Context, fileID uuid.UUID, userID u
 			newStorageUsed = 0
 		}
 		if err := s.userRepo.UpdateStorageUsed(userID, newStorageUsed); err != nil {
-			s.logger.Error("Failed to update user storage usage", zap.Error(err))
+			RequestLogger(ctx, s.logger).Error("Failed to update user storage usage", zap.Error(err))
 		}
 	}
 
@@ -341,12 +352,6 @@ func (s *FileService) ProcessMultipartUpload(ctx context.Context, userID uuid.UU
 	return s.UploadFile(ctx, request)
 }
 
-// calculateSHA256 calculates SHA-256 hash of content
-func (s *FileService) calculateSHA256(content []byte) string {
-	hash := sha256.Sum256(content)
-	return fmt.Sprintf("%x", hash)
-}
-
 // generateEnterprisePath generates storage path for enterprise files
 func (s *FileService) generateEnterprisePath(enterpriseSlug string, userID uuid.UUID, contentHash string) string {
 	return filepath.Join(enterpriseSlug, userID.String(), contentHash)
@@ -5,24 +5,211 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
 
 	"lokr-backend/internal/domain"
 )
 
 type FileSharingService struct {
-	db *pgxpool.Pool
+	db                  *pgxpool.Pool
+	auditService        *AuditService
+	notificationService *NotificationService
+	logger              *zap.Logger
+	shareLinks          ShareLinkConfig
+	newShareCode        func() (string, error) // Replaced in tests to force collisions
 }
 
-func NewFileSharingService(db *pgxpool.Pool) *FileSharingService {
+// NewFileSharingService creates the sharing service; auditService and notificationService may be
+// nil to skip audit logging and share notifications
+func NewFileSharingService(db *pgxpool.Pool, auditService *AuditService, notificationService *NotificationService, logger *zap.Logger) *FileSharingService {
 	return &FileSharingService{
-		db: db,
+		db:                  db,
+		auditService:        auditService,
+		notificationService: notificationService,
+		logger:              logger,
+		shareLinks:          ShareLinkConfig{BaseURL: DefaultShareBaseURL},
+		newShareCode:        generateShareCode,
 	}
 }
 
+// DefaultShareBaseURL is where public share links point when no base URL is configured: the
+// frontend in development
+const DefaultShareBaseURL = "http://localhost:3000"
+
+// ShareLinkConfig shapes the public share links handed out. Links are BaseURL/shared/<token>;
+// with ShortCodes each public share also gets a short code, linked as BaseURL/s/<code>.
+type ShareLinkConfig struct {
+	BaseURL    string
+	ShortCodes bool
+}
+
+// SetShareLinks configures the public share links handed out from now on
+func (s *FileSharingService) SetShareLinks(config ShareLinkConfig) {
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultShareBaseURL
+	}
+	s.shareLinks = config
+}
+
+// shareURL is the public link for a share token
+func (s *FileSharingService) shareURL(shareToken string) string {
+	return s.shareLinks.BaseURL + "/shared/" + shareToken
+}
+
+// shortShareURL is the public link for a share code
+func (s *FileSharingService) shortShareURL(shareCode string) string {
+	return s.shareLinks.BaseURL + "/s/" + shareCode
+}
+
+// Errors returned by CheckFileAccess. A user with no share of a file gets ErrFileNotFound, so a
+// file's existence isn't revealed to people it wasn't shared with.
+var (
+	ErrFileNotFound     = errors.New("file not found")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// permissionRank orders share permissions; each one grants everything the ones below it do
+var permissionRank = map[domain.PermissionType]int{
+	domain.PermissionView:     1,
+	domain.PermissionDownload: 2,
+	domain.PermissionEdit:     3,
+	domain.PermissionDelete:   4,
+}
+
+// ErrFolderNotFound is returned by CheckFolderAccess and ShareFolderWithUser. Like
+// ErrFileNotFound it is also what a user with no share of the folder gets.
+var ErrFolderNotFound = errors.New("folder not found")
+
+// CheckFileAccess verifies that userID may act on a file with the required permission and
+// returns the file's owner. Owners may do anything, though only an active file's content may be
+// previewed or downloaded; anyone else needs an active file and an unexpired share of it, or a
+// share of its folder or of any folder above it, and the strongest of those must be at least the
+// required permission. Callers act on the file on the owner's behalf.
+func (s *FileSharingService) CheckFileAccess(ctx context.Context, fileID, userID uuid.UUID, required domain.PermissionType) (uuid.UUID, error) {
+	var ownerID uuid.UUID
+	var folderID *uuid.UUID
+	var status domain.FileStatus
+	var permission *domain.PermissionType
+	err := s.db.QueryRow(ctx, `
+		SELECT f.user_id, f.folder_id, f.status, fs.permission_type
+		FROM files f
+		LEFT JOIN file_shares fs ON fs.file_id = f.id
+			AND fs.shared_with_user_id = $2
+			AND (fs.expires_at IS NULL OR fs.expires_at > NOW())
+		WHERE f.id = $1`,
+		fileID, userID).Scan(&ownerID, &folderID, &status, &permission)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, ErrFileNotFound
+		}
+		return uuid.Nil, fmt.Errorf("failed to check file access: %w", err)
+	}
+
+	// Content awaiting a scan, or found bad, is not served to anyone
+	readsContent := required == domain.PermissionView || required == domain.PermissionDownload
+	if status != domain.FileStatusActive && (ownerID != userID || readsContent) {
+		return uuid.Nil, ErrFileNotFound
+	}
+	if ownerID == userID {
+		return ownerID, nil
+	}
+	if folderID != nil {
+		folderPermission, err := s.folderSharePermission(ctx, *folderID, ownerID, userID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if folderPermission != nil {
+			permission = strongerPermission(permission, *folderPermission)
+		}
+	}
+	if permission == nil {
+		return uuid.Nil, ErrFileNotFound
+	}
+	if permissionRank[*permission] < permissionRank[required] {
+		return uuid.Nil, ErrPermissionDenied
+	}
+	return ownerID, nil
+}
+
+// CheckFolderAccess verifies that userID may act on a folder with the required permission and
+// returns the folder's owner. Owners may do anything; anyone else needs a share of the folder or of
+// any folder above it. Callers act on the folder on the owner's behalf.
+func (s *FileSharingService) CheckFolderAccess(ctx context.Context, folderID, userID uuid.UUID, required domain.PermissionType) (uuid.UUID, error) {
+	var ownerID uuid.UUID
+	err := s.db.QueryRow(ctx, "SELECT user_id FROM folders WHERE id = $1", folderID).Scan(&ownerID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, ErrFolderNotFound
+		}
+		return uuid.Nil, fmt.Errorf("failed to check folder access: %w", err)
+	}
+
+	if ownerID == userID {
+		return ownerID, nil
+	}
+	permission, err := s.folderSharePermission(ctx, folderID, ownerID, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if permission == nil {
+		return uuid.Nil, ErrFolderNotFound
+	}
+	if permissionRank[*permission] < permissionRank[required] {
+		return uuid.Nil, ErrPermissionDenied
+	}
+	return ownerID, nil
+}
+
+// folderSharePermission returns the strongest permission that ownerID's shares of folderID, or of
+// any folder above it, give userID, or nil when there are none. Resolving shares through the
+// folder's ancestors is what makes a share cover subfolders at any depth, including ones created
+// after the share, and what ends it for anything moved out of the shared folder.
+func (s *FileSharingService) folderSharePermission(ctx context.Context, folderID, ownerID, userID uuid.UUID) (*domain.PermissionType, error) {
+	rows, err := s.db.Query(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM folders WHERE id = $1
+			UNION
+			SELECT p.id, p.parent_id FROM folders p JOIN ancestors a ON p.id = a.parent_id
+		)
+		SELECT fsh.permission_type
+		FROM folder_shares fsh
+		JOIN ancestors a ON a.id = fsh.folder_id
+		WHERE fsh.shared_by_user_id = $2 AND fsh.shared_with_user_id = $3`,
+		folderID, ownerID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check folder shares: %w", err)
+	}
+	defer rows.Close()
+
+	var best *domain.PermissionType
+	for rows.Next() {
+		var permission domain.PermissionType
+		if err := rows.Scan(&permission); err != nil {
+			return nil, fmt.Errorf("failed to scan folder share: %w", err)
+		}
+		best = strongerPermission(best, permission)
+	}
+	return best, rows.Err()
+}
+
+// strongerPermission returns whichever of current and candidate grants more; current may be nil
+func strongerPermission(current *domain.PermissionType, candidate domain.PermissionType) *domain.PermissionType {
+	if current == nil || permissionRank[candidate] > permissionRank[*current] {
+		return &candidate
+	}
+	return current
+}
+
 // GenerateShareToken creates a random secure token for public file sharing
 func (s *FileSharingService) generateShareToken() (string, error) {
 	bytes := make([]byte, 32)
@@ -32,6 +219,39 @@ func (s *FileSharingService) generateShareToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
 
+// Short share codes are shareCodeLength characters from shareCodeAlphabet: 62^8 codes make
+// collisions rare, and a colliding code is replaced up to maxShareCodeAttempts times
+const (
+	shareCodeAlphabet    = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	shareCodeLength      = 8
+	maxShareCodeAttempts = 5
+)
+
+// generateShareCode returns a random short share code
+func generateShareCode() (string, error) {
+	code := make([]byte, 0, shareCodeLength)
+	buffer := make([]byte, shareCodeLength*2)
+	for len(code) < shareCodeLength {
+		if _, err := rand.Read(buffer); err != nil {
+			return "", err
+		}
+		for _, b := range buffer {
+			// Bytes past the last whole multiple of the alphabet would favor its first letters
+			if int(b) >= 256-256%len(shareCodeAlphabet) || len(code) == shareCodeLength {
+				continue
+			}
+			code = append(code, shareCodeAlphabet[int(b)%len(shareCodeAlphabet)])
+		}
+	}
+	return string(code), nil
+}
+
+// isShareCodeCollision reports whether err is a short code already taken by another file
+func isShareCodeCollision(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_files_share_code"
+}
+
 // CreatePublicShare enables public sharing for a file
 func (s *FileSharingService) CreatePublicShare(ctx context.Context, fileID uuid.UUID, userID uuid.UUID) (*domain.PublicShareResponse, error) {
 	// Check if user owns the file
@@ -54,22 +274,41 @@ func (s *FileSharingService) CreatePublicShare(ctx context.Context, fileID uuid.
 		return nil, fmt.Errorf("failed to generate share token: %w", err)
 	}
 
-	// Update file to make it publicly shareable
-	_, err = s.db.Exec(ctx, `
-		UPDATE files
-		SET visibility = 'PUBLIC', share_token = $1, updated_at = NOW()
-		WHERE id = $2`,
-		shareToken, fileID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create public share: %w", err)
-	}
+	// Update file to make it publicly shareable, with a short code when short links are enabled.
+	// A code another file already holds is replaced by a fresh one.
+	var shareCode *string
+	for attempt := 1; ; attempt++ {
+		if s.shareLinks.ShortCodes {
+			code, err := s.newShareCode()
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate share code: %w", err)
+			}
+			shareCode = &code
+		}
 
-	shareURL := fmt.Sprintf("http://localhost:3000/shared/%s", shareToken)
+		_, err = s.db.Exec(ctx, `
+			UPDATE files
+			SET visibility = 'PUBLIC', share_token = $1, share_code = $2, updated_at = NOW()
+			WHERE id = $3`,
+			shareToken, shareCode, fileID)
+		if isShareCodeCollision(err) && attempt < maxShareCodeAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create public share: %w", err)
+		}
+		break
+	}
 
-	return &domain.PublicShareResponse{
+	response := &domain.PublicShareResponse{
 		ShareToken: shareToken,
-		ShareURL:   shareURL,
-	}, nil
+		ShareURL:   s.shareURL(shareToken),
+	}
+	if shareCode != nil {
+		response.ShortCode = *shareCode
+		response.ShortURL = s.shortShareURL(*shareCode)
+	}
+	return response, nil
 }
 
 // RemovePublicShare disables public sharing for a file
@@ -91,7 +330,7 @@ func (s *FileSharingService) RemovePublicShare(ctx context.Context, fileID uuid.
 	// Update file to make it private
 	_, err = s.db.Exec(ctx, `
 		UPDATE files
-		SET visibility = 'PRIVATE', share_token = NULL, updated_at = NOW()
+		SET visibility = 'PRIVATE', share_token = NULL, share_code = NULL, updated_at = NOW()
 		WHERE id = $1`,
 		fileID)
 	if err != nil {
@@ -101,68 +340,268 @@ func (s *FileSharingService) RemovePublicShare(ctx context.Context, fileID uuid.
 	return nil
 }
 
+// errShareWithSelf is returned, or reported per user by ShareWithUsers, for a share with the owner
+var errShareWithSelf = errors.New("cannot share a file with yourself")
+
+// checkFileShare holds the checks ShareWithUser and ShareWithUsers have in common: the permission
+// and expiry must be valid and the file must be active and owned by sharedByUserID. Returns the
+// file's name.
+func (s *FileSharingService) checkFileShare(ctx context.Context, fileID, sharedByUserID uuid.UUID, permission domain.PermissionType, expiresAt *time.Time) (string, error) {
+	if _, ok := permissionRank[permission]; !ok {
+		return "", fmt.Errorf("invalid permission type %q", permission)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", fmt.Errorf("share expiry must be in the future")
+	}
+
+	var ownerID uuid.UUID
+	var fileName string
+	err := s.db.QueryRow(ctx, "SELECT user_id, original_name FROM files WHERE id = $1 AND status = 'ACTIVE'", fileID).Scan(&ownerID, &fileName)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrFileNotFound
+		}
+		return "", fmt.Errorf("failed to check file ownership: %w", err)
+	}
+	if ownerID != sharedByUserID {
+		return "", ErrPermissionDenied
+	}
+	return fileName, nil
+}
+
 // ShareWithUser shares a file with a specific user
 func (s *FileSharingService) ShareWithUser(ctx context.Context, input domain.ShareFileInput, sharedByUserID uuid.UUID) (*domain.FileShare, error) {
-	// Check if user owns the file
-	var ownerID uuid.UUID
-	err := s.db.QueryRow(ctx, "SELECT user_id FROM files WHERE id = $1", input.FileID).Scan(&ownerID)
+	fileName, err := s.checkFileShare(ctx, input.FileID, sharedByUserID, input.PermissionType, input.ExpiresAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("file not found")
+		return nil, err
+	}
+	if input.SharedWithUserID == sharedByUserID {
+		return nil, errShareWithSelf
+	}
+
+	if err := s.checkShareRecipient(ctx, sharedByUserID, input.SharedWithUserID, "files"); err != nil {
+		return nil, err
+	}
+
+	// Insert the file share record; the recipient reaches the original through it
+	shareID := uuid.New()
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO file_shares (id, file_id, shared_by_user_id, shared_with_user_id, permission_type, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (file_id, shared_with_user_id)
+		DO UPDATE SET permission_type = $5, expires_at = $6, created_at = NOW()`,
+		shareID, input.FileID, sharedByUserID, input.SharedWithUserID, input.PermissionType, input.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to share file: %w", err)
+	}
+
+	ipAddress, userAgent := RequestMeta(ctx)
+	s.auditService.LogUserShare(ctx, sharedByUserID, input.FileID, fileName, input.SharedWithUserID.String(), ipAddress, userAgent)
+	s.notificationService.NotifyFileShared(ctx, input.SharedWithUserID, sharedByUserID, input.FileID, fileName)
+
+	// Return the created share
+	return s.GetFileShare(ctx, input.FileID, input.SharedWithUserID)
+}
+
+// ShareWithUsers shares a file the user owns with every user in request.UserIDs, in one
+// transaction and with the same permission and expiry. Users who can't be given the file, such as
+// unknown users, users outside the owner's enterprise, the owner or a repeated ID, are skipped
+// with an error in their result instead of failing the batch. Results follow request.UserIDs.
+func (s *FileSharingService) ShareWithUsers(ctx context.Context, request domain.FileShareRequest, sharedByUserID uuid.UUID) ([]domain.FileShareResult, error) {
+	if len(request.UserIDs) == 0 {
+		return nil, fmt.Errorf("at least one user is required")
+	}
+	fileName, err := s.checkFileShare(ctx, request.FileID, sharedByUserID, request.PermissionType, request.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var ownerEnterpriseID *uuid.UUID
+	if err := tx.QueryRow(ctx, "SELECT enterprise_id FROM users WHERE id = $1", sharedByUserID).Scan(&ownerEnterpriseID); err != nil {
+		return nil, fmt.Errorf("failed to check owner enterprise: %w", err)
+	}
+
+	results := make([]domain.FileShareResult, len(request.UserIDs))
+	seen := make(map[uuid.UUID]bool, len(request.UserIDs))
+	for i, userID := range request.UserIDs {
+		results[i].UserID = userID
+		if seen[userID] {
+			results[i].Error = "user is listed more than once"
+			continue
 		}
-		return nil, fmt.Errorf("failed to check file ownership: %w", err)
+		seen[userID] = true
+		if userID == sharedByUserID {
+			results[i].Error = errShareWithSelf.Error()
+			continue
+		}
+
+		var enterpriseID *uuid.UUID
+		err := tx.QueryRow(ctx, "SELECT enterprise_id FROM users WHERE id = $1", userID).Scan(&enterpriseID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			results[i].Error = "user not found"
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check user %s: %w", userID, err)
+		}
+		if ownerEnterpriseID == nil || enterpriseID == nil || *enterpriseID != *ownerEnterpriseID {
+			results[i].Error = "can only share files with users in the same enterprise"
+			continue
+		}
+
+		// As in ShareWithUser, the recipient reaches the original through the share
+		share := &domain.FileShare{}
+		err = tx.QueryRow(ctx, `
+			INSERT INTO file_shares (id, file_id, shared_by_user_id, shared_with_user_id, permission_type, expires_at, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
+			ON CONFLICT (file_id, shared_with_user_id)
+			DO UPDATE SET permission_type = $5, expires_at = $6, created_at = NOW()
+			RETURNING id, file_id, shared_by_user_id, shared_with_user_id, permission_type,
+				expires_at, last_accessed_at, access_count, created_at`,
+			uuid.New(), request.FileID, sharedByUserID, userID, request.PermissionType, request.ExpiresAt).Scan(
+			&share.ID, &share.FileID, &share.SharedByUserID, &share.SharedWithUserID, &share.PermissionType,
+			&share.ExpiresAt, &share.LastAccessedAt, &share.AccessCount, &share.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to share file with %s: %w", userID, err)
+		}
+		results[i].Share = share
 	}
 
-	if ownerID != sharedByUserID {
-		return nil, fmt.Errorf("permission denied")
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit shares: %w", err)
+	}
+
+	ipAddress, userAgent := RequestMeta(ctx)
+	for _, result := range results {
+		if result.Share == nil {
+			continue
+		}
+		s.auditService.LogUserShare(ctx, sharedByUserID, request.FileID, fileName, result.UserID.String(), ipAddress, userAgent)
+		s.notificationService.NotifyFileShared(ctx, result.UserID, sharedByUserID, request.FileID, fileName)
 	}
 
-	// Check if target user exists and is in the same enterprise
+	return results, nil
+}
+
+// checkShareRecipient makes sure recipientID exists and belongs to the same enterprise as ownerID.
+// what names the kind of thing being shared in the error.
+func (s *FileSharingService) checkShareRecipient(ctx context.Context, ownerID, recipientID uuid.UUID, what string) error {
 	var targetUserEnterpriseID *uuid.UUID
 	var ownerEnterpriseID *uuid.UUID
 
-	err = s.db.QueryRow(ctx, "SELECT enterprise_id FROM users WHERE id = $1", input.SharedWithUserID).Scan(&targetUserEnterpriseID)
+	err := s.db.QueryRow(ctx, "SELECT enterprise_id FROM users WHERE id = $1", recipientID).Scan(&targetUserEnterpriseID)
 	if err != nil {
-		return nil, fmt.Errorf("target user not found")
+		return fmt.Errorf("target user not found")
 	}
 
-	err = s.db.QueryRow(ctx, "SELECT enterprise_id FROM users WHERE id = $1", sharedByUserID).Scan(&ownerEnterpriseID)
+	err = s.db.QueryRow(ctx, "SELECT enterprise_id FROM users WHERE id = $1", ownerID).Scan(&ownerEnterpriseID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check file owner enterprise: %w", err)
+		return fmt.Errorf("failed to check owner enterprise: %w", err)
 	}
 
 	// Ensure both users are in the same enterprise
 	if targetUserEnterpriseID == nil || ownerEnterpriseID == nil || *targetUserEnterpriseID != *ownerEnterpriseID {
-		return nil, fmt.Errorf("can only share files with users in the same enterprise")
+		return fmt.Errorf("can only share %s with users in the same enterprise", what)
 	}
+	return nil
+}
 
-	// Create a copy of the file for the shared user
-	copiedFileID, err := s.copyFileForUser(ctx, input.FileID, input.SharedWithUserID, sharedByUserID)
+// ShareFolderWithUser shares one of the owner's folders with another user in their enterprise.
+// The share covers the folder, its subfolders at any depth and every file in them, including
+// files and subfolders added later. Sharing the folder with the same user again replaces the
+// permission.
+func (s *FileSharingService) ShareFolderWithUser(ctx context.Context, folderID, sharedWithUserID, sharedByUserID uuid.UUID, permission domain.PermissionType) (*domain.FolderShare, error) {
+	var ownerID uuid.UUID
+	var folderName string
+	err := s.db.QueryRow(ctx, "SELECT user_id, name FROM folders WHERE id = $1", folderID).Scan(&ownerID, &folderName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to copy file for sharing: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrFolderNotFound
+		}
+		return nil, fmt.Errorf("failed to check folder ownership: %w", err)
+	}
+	if ownerID != sharedByUserID {
+		return nil, ErrFolderNotFound
+	}
+	if sharedWithUserID == sharedByUserID {
+		return nil, fmt.Errorf("cannot share a folder with yourself")
 	}
 
-	// Insert the file share record
-	shareID := uuid.New()
-	_, err = s.db.Exec(ctx, `
-		INSERT INTO file_shares (id, file_id, shared_by_user_id, shared_with_user_id, permission_type, expires_at, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW())
-		ON CONFLICT (file_id, shared_with_user_id)
-		DO UPDATE SET permission_type = $5, expires_at = $6, created_at = NOW()`,
-		shareID, copiedFileID, sharedByUserID, input.SharedWithUserID, input.PermissionType, input.ExpiresAt)
+	if err := s.checkShareRecipient(ctx, sharedByUserID, sharedWithUserID, "folders"); err != nil {
+		return nil, err
+	}
+
+	share := &domain.FolderShare{}
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO folder_shares (id, folder_id, shared_by_user_id, shared_with_user_id, permission_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (folder_id, shared_with_user_id)
+		DO UPDATE SET permission_type = $5, created_at = NOW()
+		RETURNING id, folder_id, shared_by_user_id, shared_with_user_id, permission_type, created_at`,
+		uuid.New(), folderID, sharedByUserID, sharedWithUserID, permission).Scan(
+		&share.ID, &share.FolderID, &share.SharedByUserID, &share.SharedWithUserID, &share.PermissionType, &share.CreatedAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to share file: %w", err)
+		return nil, fmt.Errorf("failed to share folder: %w", err)
 	}
 
-	// Return the created share
-	return s.GetFileShare(ctx, copiedFileID, input.SharedWithUserID)
+	ipAddress, userAgent := RequestMeta(ctx)
+	s.auditService.LogFolderShare(ctx, sharedByUserID, folderID, folderName, sharedWithUserID.String(), permission, ipAddress, userAgent)
+
+	return share, nil
+}
+
+// GetSharedFoldersWithMe lists the folders other users have shared directly with the user, most
+// recently shared first, each share with its folder attached. Subfolders reached through a shared
+// folder aren't listed separately; they're browsed through the folder itself.
+func (s *FileSharingService) GetSharedFoldersWithMe(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.FolderShare, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT fsh.id, fsh.folder_id, fsh.shared_by_user_id, fsh.shared_with_user_id, fsh.permission_type, fsh.created_at,
+		       f.id, f.user_id, f.name, f.parent_id, f.created_at, f.updated_at
+		FROM folder_shares fsh
+		JOIN folders f ON f.id = fsh.folder_id
+		WHERE fsh.shared_with_user_id = $1
+		ORDER BY fsh.created_at DESC, fsh.id
+		LIMIT $2 OFFSET $3`,
+		userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shared folders: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []*domain.FolderShare
+	for rows.Next() {
+		share := &domain.FolderShare{Folder: &domain.Folder{}}
+		err := rows.Scan(
+			&share.ID, &share.FolderID, &share.SharedByUserID, &share.SharedWithUserID, &share.PermissionType, &share.CreatedAt,
+			&share.Folder.ID, &share.Folder.UserID, &share.Folder.Name, &share.Folder.ParentID, &share.Folder.CreatedAt, &share.Folder.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shared folder: %w", err)
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
 }
 
 // RemoveUserShare removes sharing with a specific user
 func (s *FileSharingService) RemoveUserShare(ctx context.Context, fileID uuid.UUID, sharedWithUserID uuid.UUID, sharedByUserID uuid.UUID) error {
 	// Check if user owns the file
 	var ownerID uuid.UUID
-	err := s.db.QueryRow(ctx, "SELECT user_id FROM files WHERE id = $1", fileID).Scan(&ownerID)
+	var fileName string
+	err := s.db.QueryRow(ctx, "SELECT user_id, original_name FROM files WHERE id = $1", fileID).Scan(&ownerID, &fileName)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("file not found")
@@ -188,6 +627,9 @@ func (s *FileSharingService) RemoveUserShare(ctx context.Context, fileID uuid.UU
 		return fmt.Errorf("file share not found")
 	}
 
+	ipAddress, userAgent := RequestMeta(ctx)
+	s.auditService.LogShareRevoked(ctx, sharedByUserID, fileID, fileName, sharedWithUserID.String(), ipAddress, userAgent)
+
 	// Check if there are any remaining user shares
 	var hasUserShares bool
 	err = s.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM file_shares WHERE file_id = $1)", fileID).Scan(&hasUserShares)
@@ -249,7 +691,7 @@ func (s *FileSharingService) GetFileShare(ctx context.Context, fileID uuid.UUID,
 	return &share, nil
 }
 
-// GetFileShares retrieves all shares for a file
+// GetFileShares retrieves the unexpired shares of a file
 func (s *FileSharingService) GetFileShares(ctx context.Context, fileID uuid.UUID, ownerID uuid.UUID) ([]domain.FileShare, error) {
 	// Check if user owns the file
 	var actualOwnerID uuid.UUID
@@ -272,6 +714,7 @@ func (s *FileSharingService) GetFileShares(ctx context.Context, fileID uuid.UUID
 		FROM file_shares fs
 		JOIN users u ON fs.shared_with_user_id = u.id
 		WHERE fs.file_id = $1
+		AND (fs.expires_at IS NULL OR fs.expires_at > NOW())
 		ORDER BY fs.created_at DESC`,
 		fileID)
 	if err != nil {
@@ -312,15 +755,15 @@ func (s *FileSharingService) GetFileShares(ctx context.Context, fileID uuid.UUID
 // GetFileShareInfo gets comprehensive sharing information for a file
 func (s *FileSharingService) GetFileShareInfo(ctx context.Context, fileID uuid.UUID, ownerID uuid.UUID) (*domain.FileShareInfo, error) {
 	// Get file details
-	var shareToken sql.NullString
+	var shareToken, shareCode sql.NullString
 	var visibility string
 	var downloadCount int
 
 	err := s.db.QueryRow(ctx, `
-		SELECT share_token, visibility, download_count
+		SELECT share_token, share_code, visibility, download_count
 		FROM files
 		WHERE id = $1 AND user_id = $2`,
-		fileID, ownerID).Scan(&shareToken, &visibility, &downloadCount)
+		fileID, ownerID).Scan(&shareToken, &shareCode, &visibility, &downloadCount)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("file not found or permission denied")
@@ -342,7 +785,10 @@ func (s *FileSharingService) GetFileShareInfo(ctx context.Context, fileID uuid.U
 
 	if shareToken.Valid {
 		info.ShareToken = shareToken.String
-		info.ShareURL = fmt.Sprintf("http://localhost:3000/shared/%s", shareToken.String)
+		info.ShareURL = s.shareURL(shareToken.String)
+	}
+	if shareCode.Valid {
+		info.ShortURL = s.shortShareURL(shareCode.String)
 	}
 
 	return info, nil
@@ -350,6 +796,16 @@ func (s *FileSharingService) GetFileShareInfo(ctx context.Context, fileID uuid.U
 
 // GetFileByShareToken retrieves a file by its public share token
 func (s *FileSharingService) GetFileByShareToken(ctx context.Context, shareToken string) (*domain.File, error) {
+	return s.getPublicFile(ctx, "share_token", shareToken)
+}
+
+// GetFileByShareCode retrieves a file by the short code of its public share
+func (s *FileSharingService) GetFileByShareCode(ctx context.Context, shareCode string) (*domain.File, error) {
+	return s.getPublicFile(ctx, "share_code", shareCode)
+}
+
+// getPublicFile retrieves an active public file by its share token or share code column
+func (s *FileSharingService) getPublicFile(ctx context.Context, column, value string) (*domain.File, error) {
 	var file domain.File
 	var folderID sql.NullString
 	var description, shareTokenDB sql.NullString
@@ -360,8 +816,8 @@ func (s *FileSharingService) GetFileByShareToken(ctx context.Context, shareToken
 			   content_hash, description, tags, visibility, share_token, download_count,
 			   upload_date, updated_at
 		FROM files
-		WHERE share_token = $1 AND visibility = 'PUBLIC'`,
-		shareToken).Scan(
+		WHERE `+column+` = $1 AND visibility = 'PUBLIC' AND status = 'ACTIVE'`,
+		value).Scan(
 		&file.ID, &file.UserID, &folderID, &file.Filename, &file.OriginalName,
 		&file.MimeType, &file.FileSize, &file.ContentHash, &description,
 		&tags, &file.Visibility, &shareTokenDB, &file.DownloadCount,
@@ -394,17 +850,35 @@ func (s *FileSharingService) GetFileByShareToken(ctx context.Context, shareToken
 	return &file, nil
 }
 
-// IncrementDownloadCount increments the download counter for a file
-func (s *FileSharingService) IncrementDownloadCount(ctx context.Context, fileID uuid.UUID) error {
-	_, err := s.db.Exec(ctx, `
-		UPDATE files
-		SET download_count = download_count + 1, updated_at = NOW()
-		WHERE id = $1`,
-		fileID)
+// DeleteExpiredShares removes every share whose expiry has passed and returns how many it removed.
+// Access checks already ignore expired shares; this keeps them from piling up.
+func (s *FileSharingService) DeleteExpiredShares(ctx context.Context) (int64, error) {
+	result, err := s.db.Exec(ctx, "DELETE FROM file_shares WHERE expires_at <= NOW()")
 	if err != nil {
-		return fmt.Errorf("failed to increment download count: %w", err)
+		return 0, fmt.Errorf("failed to delete expired shares: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// RunShareExpiry deletes expired shares immediately and then on every interval until ctx is cancelled
+func (s *FileSharingService) RunShareExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.DeleteExpiredShares(ctx)
+		if err != nil {
+			s.logger.Error("Failed to delete expired shares", zap.Error(err))
+		} else if deleted > 0 {
+			s.logger.Info("Deleted expired shares", zap.Int64("deleted", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
-	return nil
 }
 
 // RecordShareAccess records when a shared file is accessed
@@ -420,39 +894,67 @@ func (s *FileSharingService) RecordShareAccess(ctx context.Context, fileID uuid.
 	return nil
 }
 
-// SearchUsers searches for users by name or email within the same enterprise
-func (s *FileSharingService) SearchUsers(ctx context.Context, query string, limit int, userID uuid.UUID) ([]*domain.User, error) {
-	if limit <= 0 || limit > 50 {
-		limit = 10
+// Page sizes for SearchUsers
+const (
+	defaultUserSearchResults = 10
+	maxUserSearchResults     = 50
+)
+
+// ErrNoEnterprise is returned by SearchUsers to a user outside any enterprise, who has nobody to
+// share with
+var ErrNoEnterprise = errors.New("user does not belong to an enterprise")
+
+// SearchUsers pages through the verified users other than userID whose name or email contains
+// query, ordered by name, and counts every match. Only members of the caller's enterprise are
+// searched unless allUsers is set, which callers must reserve for platform admins. limit defaults
+// to 10 and is capped at 50.
+func (s *FileSharingService) SearchUsers(ctx context.Context, userID uuid.UUID, query string, allUsers bool, limit, offset int) ([]*domain.User, int, error) {
+	if limit <= 0 {
+		limit = defaultUserSearchResults
+	}
+	if limit > maxUserSearchResults {
+		limit = maxUserSearchResults
+	}
+	if offset < 0 {
+		offset = 0
 	}
 
-	// Get the user's enterprise ID
 	var enterpriseID *uuid.UUID
-	err := s.db.QueryRow(ctx, "SELECT enterprise_id FROM users WHERE id = $1", userID).Scan(&enterpriseID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user enterprise: %w", err)
+	if !allUsers {
+		err := s.db.QueryRow(ctx, "SELECT enterprise_id FROM users WHERE id = $1", userID).Scan(&enterpriseID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to get user enterprise: %w", err)
+		}
+		if enterpriseID == nil {
+			return nil, 0, ErrNoEnterprise
+		}
 	}
 
-	if enterpriseID == nil {
-		return []*domain.User{}, nil
+	// $3 is NULL for a search of every user
+	const condition = `(name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%')
+		AND email_verified = true
+		AND id != $2
+		AND ($3::uuid IS NULL OR enterprise_id = $3)`
+
+	var total int
+	err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM users WHERE "+condition, query, userID, enterpriseID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
 	}
 
 	rows, err := s.db.Query(ctx, `
 		SELECT id, email, name, profile_image, role, created_at
 		FROM users
-		WHERE (name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%')
-		AND email_verified = true
-		AND enterprise_id = $3
-		AND id != $4
-		ORDER BY name ASC
-		LIMIT $2`,
-		query, limit, enterpriseID, userID)
+		WHERE `+condition+`
+		ORDER BY name ASC, id ASC
+		LIMIT $4 OFFSET $5`,
+		query, userID, enterpriseID, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search users: %w", err)
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
 	}
 	defer rows.Close()
 
-	var users []*domain.User
+	users := []*domain.User{}
 	for rows.Next() {
 		var user domain.User
 		var profileImage sql.NullString
@@ -460,7 +962,7 @@ func (s *FileSharingService) SearchUsers(ctx context.Context, query string, limi
 		err := rows.Scan(
 			&user.ID, &user.Email, &user.Name, &profileImage, &user.Role, &user.CreatedAt)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan user: %w", err)
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
 		}
 
 		if profileImage.Valid {
@@ -470,29 +972,59 @@ func (s *FileSharingService) SearchUsers(ctx context.Context, query string, limi
 		users = append(users, &user)
 	}
 
-	return users, nil
+	return users, total, rows.Err()
 }
 
-// GetSharedWithMeFiles gets files that have been shared with the user (copied files owned by the user)
-func (s *FileSharingService) GetSharedWithMeFiles(ctx context.Context, userID uuid.UUID, limit int, offset int) ([]*domain.File, error) {
-	if limit <= 0 || limit > 100 {
+// sharedWithUserCondition matches the active files (aliased f) shared with user $1 through an
+// unexpired share (aliased fs), by user $2 unless $2 is NULL. Shares are the only source: a file
+// is shared because a share row says so, whatever it is named.
+const sharedWithUserCondition = `fs.shared_with_user_id = $1
+		AND ($2::uuid IS NULL OR fs.shared_by_user_id = $2)
+		AND (fs.expires_at IS NULL OR fs.expires_at > NOW())
+		AND f.status = 'ACTIVE'`
+
+// CountSharedWithMeFiles counts the files GetSharedWithMeFiles pages through
+func (s *FileSharingService) CountSharedWithMeFiles(ctx context.Context, userID uuid.UUID, sharedBy *uuid.UUID) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM files f
+		JOIN file_shares fs ON fs.file_id = f.id
+		WHERE `+sharedWithUserCondition, userID, sharedBy).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count shared files: %w", err)
+	}
+	return count, nil
+}
+
+// GetSharedWithMeFiles gets the files other users have shared with the user through an unexpired
+// share, newest first, only those shared by sharedBy when it is set. When after is set the page
+// starts just past that cursor and offset is ignored.
+func (s *FileSharingService) GetSharedWithMeFiles(ctx context.Context, userID uuid.UUID, sharedBy *uuid.UUID, limit int, offset int, after *domain.FileCursor) ([]*domain.File, error) {
+	if limit <= 0 {
 		limit = 20
 	}
 	if offset < 0 {
 		offset = 0
 	}
 
-	// Get files owned by the user that were shared (have "Shared from" in the filename)
-	rows, err := s.db.Query(ctx, `
+	query := `
 		SELECT f.id, f.user_id, f.folder_id, f.filename, f.original_name,
 		       f.mime_type, f.file_size, f.content_hash, f.description, f.tags,
 		       f.visibility, f.share_token, f.download_count, f.upload_date, f.updated_at
 		FROM files f
-		WHERE f.user_id = $1
-		AND f.filename LIKE '[Shared from %'
-		ORDER BY f.upload_date DESC
-		LIMIT $2 OFFSET $3`,
-		userID, limit, offset)
+		JOIN file_shares fs ON fs.file_id = f.id
+		WHERE ` + sharedWithUserCondition
+	args := []interface{}{userID, sharedBy}
+	if after != nil {
+		query += " AND (f.upload_date, f.id) < ($3, $4)"
+		args = append(args, after.UploadDate, after.ID)
+		offset = 0
+	}
+	query += fmt.Sprintf(" ORDER BY f.upload_date DESC, f.id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query shared files: %w", err)
 	}
@@ -531,66 +1063,3 @@ func (s *FileSharingService) GetSharedWithMeFiles(ctx context.Context, userID uu
 
 	return files, nil
 }
-
-// copyFileForUser creates a copy of the file metadata for the target user
-func (s *FileSharingService) copyFileForUser(ctx context.Context, originalFileID uuid.UUID, targetUserID uuid.UUID, sharedByUserID uuid.UUID) (uuid.UUID, error) {
-	// Get original file information
-	var originalFile domain.File
-	var folderID sql.NullString
-	var description sql.NullString
-	var shareToken sql.NullString
-
-	err := s.db.QueryRow(ctx, `
-		SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
-		       content_hash, description, tags, visibility, share_token, download_count, upload_date
-		FROM files WHERE id = $1`, originalFileID).Scan(
-		&originalFile.ID, &originalFile.UserID, &folderID, &originalFile.Filename, &originalFile.OriginalName,
-		&originalFile.MimeType, &originalFile.FileSize, &originalFile.ContentHash, &description, &originalFile.Tags,
-		&originalFile.Visibility, &shareToken, &originalFile.DownloadCount, &originalFile.UploadDate)
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("failed to get original file: %w", err)
-	}
-
-	// Create new file ID for the copy
-	copiedFileID := uuid.New()
-
-	// Create new filename with "Shared from [owner]" prefix
-	var ownerName string
-	err = s.db.QueryRow(ctx, "SELECT name FROM users WHERE id = $1", sharedByUserID).Scan(&ownerName)
-	if err != nil {
-		ownerName = "Unknown User"
-	}
-
-	newFilename := fmt.Sprintf("[Shared from %s] %s", ownerName, originalFile.OriginalName)
-
-	// Insert the copied file record
-	_, err = s.db.Exec(ctx, `
-		INSERT INTO files (id, user_id, folder_id, filename, original_name, mime_type, file_size,
-		                  content_hash, description, tags, visibility, share_token, download_count, upload_date, updated_at)
-		VALUES ($1, $2, NULL, $3, $4, $5, $6, $7, $8, $9, 'PRIVATE', NULL, 0, NOW(), NOW())`,
-		copiedFileID, targetUserID, newFilename, newFilename, originalFile.MimeType, originalFile.FileSize,
-		originalFile.ContentHash, description, originalFile.Tags)
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("failed to create file copy: %w", err)
-	}
-
-	// Update the reference count in file_contents (since we're sharing the same physical file)
-	_, err = s.db.Exec(ctx, `
-		UPDATE file_contents
-		SET reference_count = reference_count + 1
-		WHERE content_hash = $1`, originalFile.ContentHash)
-	if err != nil {
-		// If the file_contents record doesn't exist, create it
-		// This might happen if the original file was uploaded via REST API
-		_, err = s.db.Exec(ctx, `
-			INSERT INTO file_contents (content_hash, file_path, file_size, reference_count, created_at)
-			VALUES ($1, $2, $3, 1, NOW())
-			ON CONFLICT (content_hash) DO UPDATE SET reference_count = file_contents.reference_count + 1`,
-			originalFile.ContentHash, fmt.Sprintf("personal/users/%s/%s", originalFile.UserID.String(), originalFile.ContentHash), originalFile.FileSize)
-		if err != nil {
-			return uuid.Nil, fmt.Errorf("failed to update file contents reference: %w", err)
-		}
-	}
-
-	return copiedFileID, nil
-}
\ No newline at end of file
@@ -0,0 +1,415 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/testutil"
+)
+
+func TestFileSharingService_CheckFileAccess(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	sharingService := NewFileSharingService(db, nil, nil, zap.NewNop())
+	ctx := context.Background()
+
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	fileID := testutil.CreateFile(t, db, owner, "budget.xlsx", []byte("access "+uuid.NewString()))
+
+	viewer := testutil.CreateUser(t, db, "viewer", nil)
+	testutil.ShareFile(t, db, fileID, owner, viewer, domain.PermissionView)
+	editor := testutil.CreateUser(t, db, "editor", nil)
+	testutil.ShareFile(t, db, fileID, owner, editor, domain.PermissionEdit)
+	expired := testutil.CreateUser(t, db, "expired", nil)
+	testutil.ShareFile(t, db, fileID, owner, expired, domain.PermissionDelete)
+	db.Exec(ctx, "UPDATE file_shares SET expires_at = NOW() - INTERVAL '1 hour' WHERE file_id = $1 AND shared_with_user_id = $2", fileID, expired)
+	stranger := testutil.CreateUser(t, db, "stranger", nil)
+
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		required domain.PermissionType
+		wantErr  error
+	}{
+		{"owner may delete", owner, domain.PermissionDelete, nil},
+		{"viewer may view", viewer, domain.PermissionView, nil},
+		{"viewer may not download", viewer, domain.PermissionDownload, ErrPermissionDenied},
+		{"editor may download", editor, domain.PermissionDownload, nil},
+		{"editor may edit", editor, domain.PermissionEdit, nil},
+		{"editor may not delete", editor, domain.PermissionDelete, ErrPermissionDenied},
+		{"expired share grants nothing", expired, domain.PermissionView, ErrFileNotFound},
+		{"stranger sees nothing", stranger, domain.PermissionView, ErrFileNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ownerID, err := sharingService.CheckFileAccess(ctx, fileID, tt.userID, tt.required)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && ownerID != owner {
+				t.Errorf("Expected owner %s, got %s", owner, ownerID)
+			}
+		})
+	}
+}
+
+func TestFileSharingService_DeleteExpiredShares(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	sharingService := NewFileSharingService(db, nil, nil, zap.NewNop())
+	ctx := context.Background()
+
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	fileID := testutil.CreateFile(t, db, owner, "roadmap.md", []byte("expiry "+uuid.NewString()))
+
+	expiries := map[string]string{
+		"expired":   "NOW() - INTERVAL '1 minute'",
+		"upcoming":  "NOW() + INTERVAL '1 day'",
+		"permanent": "NULL",
+	}
+	recipients := make(map[string]uuid.UUID)
+	for name, expiry := range expiries {
+		recipients[name] = testutil.CreateUser(t, db, name, nil)
+		testutil.ShareFile(t, db, fileID, owner, recipients[name], domain.PermissionView)
+		db.Exec(ctx, "UPDATE file_shares SET expires_at = "+expiry+" WHERE file_id = $1 AND shared_with_user_id = $2", fileID, recipients[name])
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if _, err := sharingService.ShareWithUser(ctx, domain.ShareFileInput{
+		FileID:           fileID,
+		SharedWithUserID: recipients["upcoming"],
+		PermissionType:   domain.PermissionView,
+		ExpiresAt:        &past,
+	}, owner); err == nil || !strings.Contains(err.Error(), "expiry") {
+		t.Errorf("Expected an expiry in the past to be rejected, got %v", err)
+	}
+
+	deleted, err := sharingService.DeleteExpiredShares(ctx)
+	if err != nil {
+		t.Fatalf("DeleteExpiredShares failed: %v", err)
+	}
+	if deleted < 1 {
+		t.Errorf("Expected at least the expired share to be deleted, got %d", deleted)
+	}
+
+	for name, want := range map[string]bool{"expired": false, "upcoming": true, "permanent": true} {
+		var exists bool
+		db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM file_shares WHERE file_id = $1 AND shared_with_user_id = $2)", fileID, recipients[name]).Scan(&exists)
+		if exists != want {
+			t.Errorf("%s share: expected exists=%v, got %v", name, want, exists)
+		}
+	}
+}
+
+func TestFileSharingService_FolderShares(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	sharingService := NewFileSharingService(db, nil, nil, zap.NewNop())
+	folderService := NewFolderService(db, nil)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	recipient := testutil.CreateUser(t, db, "recipient", &enterpriseID)
+	stranger := testutil.CreateUser(t, db, "stranger", &enterpriseID)
+	outsider := testutil.CreateUser(t, db, "outsider", nil)
+
+	projects, err := folderService.CreateFolder(ctx, owner, "projects", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	design, err := folderService.CreateFolder(ctx, owner, "design", &projects.ID)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	fileID := testutil.CreateFile(t, db, owner, "mockup.png", []byte("folder share "+uuid.NewString()))
+	if _, err := db.Exec(ctx, "UPDATE files SET folder_id = $1 WHERE id = $2", design.ID, fileID); err != nil {
+		t.Fatalf("Failed to file the file: %v", err)
+	}
+
+	if _, err := sharingService.ShareFolderWithUser(ctx, projects.ID, outsider, owner, domain.PermissionView); err == nil {
+		t.Error("Expected sharing outside the enterprise to fail")
+	}
+	if _, err := sharingService.ShareFolderWithUser(ctx, projects.ID, recipient, stranger, domain.PermissionView); !errors.Is(err, ErrFolderNotFound) {
+		t.Errorf("Expected a non-owner share to fail with ErrFolderNotFound, got %v", err)
+	}
+
+	share, err := sharingService.ShareFolderWithUser(ctx, projects.ID, recipient, owner, domain.PermissionView)
+	if err != nil {
+		t.Fatalf("ShareFolderWithUser failed: %v", err)
+	}
+	if share.FolderID != projects.ID || share.PermissionType != domain.PermissionView {
+		t.Errorf("Unexpected share %+v", share)
+	}
+
+	t.Run("subfolder file reachable", func(t *testing.T) {
+		ownerID, err := sharingService.CheckFileAccess(ctx, fileID, recipient, domain.PermissionView)
+		if err != nil {
+			t.Fatalf("Expected the recipient to view a file in a subfolder: %v", err)
+		}
+		if ownerID != owner {
+			t.Errorf("Expected owner %s, got %s", owner, ownerID)
+		}
+		if _, err := sharingService.CheckFolderAccess(ctx, design.ID, recipient, domain.PermissionView); err != nil {
+			t.Errorf("Expected the recipient to open the subfolder: %v", err)
+		}
+	})
+
+	t.Run("permission limited by the share", func(t *testing.T) {
+		if _, err := sharingService.CheckFileAccess(ctx, fileID, recipient, domain.PermissionDownload); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Expected ErrPermissionDenied, got %v", err)
+		}
+	})
+
+	t.Run("stronger direct share wins", func(t *testing.T) {
+		testutil.ShareFile(t, db, fileID, owner, recipient, domain.PermissionEdit)
+		defer db.Exec(ctx, "DELETE FROM file_shares WHERE file_id = $1", fileID)
+
+		if _, err := sharingService.CheckFileAccess(ctx, fileID, recipient, domain.PermissionEdit); err != nil {
+			t.Errorf("Expected the direct EDIT share to apply: %v", err)
+		}
+	})
+
+	t.Run("stranger sees nothing", func(t *testing.T) {
+		if _, err := sharingService.CheckFileAccess(ctx, fileID, stranger, domain.PermissionView); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("Expected ErrFileNotFound, got %v", err)
+		}
+		if _, err := sharingService.CheckFolderAccess(ctx, design.ID, stranger, domain.PermissionView); !errors.Is(err, ErrFolderNotFound) {
+			t.Errorf("Expected ErrFolderNotFound, got %v", err)
+		}
+	})
+
+	t.Run("listed for the recipient", func(t *testing.T) {
+		shares, err := sharingService.GetSharedFoldersWithMe(ctx, recipient, 20, 0)
+		if err != nil {
+			t.Fatalf("GetSharedFoldersWithMe failed: %v", err)
+		}
+		if len(shares) != 1 || shares[0].Folder == nil || shares[0].Folder.Name != "projects" {
+			t.Fatalf("Expected the projects folder to be listed, got %+v", shares)
+		}
+	})
+
+	t.Run("moving out ends access", func(t *testing.T) {
+		if _, err := db.Exec(ctx, "UPDATE files SET folder_id = NULL WHERE id = $1", fileID); err != nil {
+			t.Fatalf("Failed to move the file: %v", err)
+		}
+		if _, err := sharingService.CheckFileAccess(ctx, fileID, recipient, domain.PermissionView); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("Expected ErrFileNotFound once the file left the folder, got %v", err)
+		}
+	})
+}
+
+func TestFileSharingService_ShareWithUsers(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	sharingService := NewFileSharingService(db, nil, nil, zap.NewNop())
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	first := testutil.CreateUser(t, db, "first", &enterpriseID)
+	second := testutil.CreateUser(t, db, "second", &enterpriseID)
+	outsider := testutil.CreateUser(t, db, "outsider", nil)
+	fileID := testutil.CreateFile(t, db, owner, "plan.txt", []byte("batch "+uuid.NewString()))
+
+	results, err := sharingService.ShareWithUsers(ctx, domain.FileShareRequest{
+		FileID:         fileID,
+		UserIDs:        []uuid.UUID{first, outsider, second},
+		PermissionType: domain.PermissionDownload,
+	}, owner)
+	if err != nil {
+		t.Fatalf("ShareWithUsers failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected a result per user, got %d", len(results))
+	}
+
+	for _, i := range []int{0, 2} {
+		result := results[i]
+		if result.Share == nil || result.Error != "" {
+			t.Errorf("Expected sharing with %s to succeed, got %q", result.UserID, result.Error)
+			continue
+		}
+		if result.Share.SharedWithUserID != result.UserID || result.Share.PermissionType != domain.PermissionDownload {
+			t.Errorf("Expected a DOWNLOAD share with %s, got %+v", result.UserID, result.Share)
+		}
+		if _, err := sharingService.CheckFileAccess(ctx, fileID, result.UserID, domain.PermissionDownload); err != nil {
+			t.Errorf("Expected %s to reach the original file, got %v", result.UserID, err)
+		}
+	}
+
+	if results[1].UserID != outsider || results[1].Share != nil || !strings.Contains(results[1].Error, "same enterprise") {
+		t.Errorf("Expected the outsider to be skipped, got %+v", results[1])
+	}
+	if _, err := sharingService.CheckFileAccess(ctx, fileID, outsider, domain.PermissionView); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected the outsider to have no access, got %v", err)
+	}
+
+	t.Run("only the owner may share", func(t *testing.T) {
+		_, err := sharingService.ShareWithUsers(ctx, domain.FileShareRequest{
+			FileID:         fileID,
+			UserIDs:        []uuid.UUID{second},
+			PermissionType: domain.PermissionView,
+		}, first)
+		if !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("Expected ErrPermissionDenied, got %v", err)
+		}
+	})
+
+	t.Run("single shares are checked the same way", func(t *testing.T) {
+		share := func(fileID, recipient uuid.UUID, permission domain.PermissionType) error {
+			_, err := sharingService.ShareWithUser(ctx, domain.ShareFileInput{
+				FileID:           fileID,
+				SharedWithUserID: recipient,
+				PermissionType:   permission,
+			}, owner)
+			return err
+		}
+
+		if err := share(fileID, first, "OWN"); err == nil || !strings.Contains(err.Error(), "invalid permission type") {
+			t.Errorf("Expected an unknown permission to be rejected, got %v", err)
+		}
+		if err := share(fileID, owner, domain.PermissionView); err == nil || !strings.Contains(err.Error(), "yourself") {
+			t.Errorf("Expected sharing with yourself to be rejected, got %v", err)
+		}
+
+		pendingID := testutil.CreateFile(t, db, owner, "pending.txt", []byte("pending "+uuid.NewString()))
+		db.Exec(ctx, "UPDATE files SET status = 'PENDING_SCAN' WHERE id = $1", pendingID)
+		if err := share(pendingID, first, domain.PermissionView); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("Expected a file awaiting its scan to be unshareable, got %v", err)
+		}
+		if _, err := sharingService.ShareWithUsers(ctx, domain.FileShareRequest{
+			FileID:         pendingID,
+			UserIDs:        []uuid.UUID{first},
+			PermissionType: domain.PermissionView,
+		}, owner); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("Expected a file awaiting its scan to be unshareable in a batch, got %v", err)
+		}
+	})
+}
+
+func TestFileSharingService_UserShareAuditActions(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	sharingService := NewFileSharingService(db, NewAuditService(db, zap.NewNop()), nil, zap.NewNop())
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	owner := testutil.CreateUser(t, db, "owner", &enterpriseID)
+	recipient := testutil.CreateUser(t, db, "recipient", &enterpriseID)
+	fileID := testutil.CreateFile(t, db, owner, "audited.txt", []byte("audited "+uuid.NewString()))
+
+	if _, err := sharingService.ShareWithUser(ctx, domain.ShareFileInput{
+		FileID:           fileID,
+		SharedWithUserID: recipient,
+		PermissionType:   domain.PermissionView,
+	}, owner); err != nil {
+		t.Fatalf("ShareWithUser failed: %v", err)
+	}
+	if err := sharingService.RemoveUserShare(ctx, fileID, recipient, owner); err != nil {
+		t.Fatalf("RemoveUserShare failed: %v", err)
+	}
+
+	for _, action := range []domain.AuditAction{domain.ActionUserShare, domain.ActionShareRevoked} {
+		var logged int
+		db.QueryRow(ctx, "SELECT COUNT(*) FROM audit_logs WHERE user_id = $1 AND resource_id = $2 AND action = $3", owner, fileID, action).Scan(&logged)
+		if logged != 1 {
+			t.Errorf("Expected one %s entry, got %d", action, logged)
+		}
+	}
+}
+
+func TestFileSharingService_ShortShareLinks(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewFileSharingService(db, nil, nil, zap.NewNop())
+	service.SetShareLinks(ShareLinkConfig{BaseURL: "https://lokr.example.com/", ShortCodes: true})
+	ctx := context.Background()
+
+	owner := testutil.CreateUser(t, db, "publisher", nil)
+	first := testutil.CreateFile(t, db, owner, "first.txt", []byte("first "+uuid.NewString()))
+	second := testutil.CreateFile(t, db, owner, "second.txt", []byte("second "+uuid.NewString()))
+
+	// Codes unique to this run, handed out in order
+	taken := strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+	fresh := strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+	codes := []string{taken, taken, fresh}
+	service.newShareCode = func() (string, error) {
+		code := codes[0]
+		codes = codes[1:]
+		return code, nil
+	}
+
+	firstShare, err := service.CreatePublicShare(ctx, first, owner)
+	if err != nil {
+		t.Fatalf("CreatePublicShare failed: %v", err)
+	}
+	if firstShare.ShortCode != taken || firstShare.ShortURL != "https://lokr.example.com/s/"+taken {
+		t.Errorf("Expected the short link https://lokr.example.com/s/%s, got %+v", taken, firstShare)
+	}
+	if firstShare.ShareURL != "https://lokr.example.com/shared/"+firstShare.ShareToken {
+		t.Errorf("Expected the long link on the configured base, got %s", firstShare.ShareURL)
+	}
+
+	t.Run("a colliding code is replaced", func(t *testing.T) {
+		share, err := service.CreatePublicShare(ctx, second, owner)
+		if err != nil {
+			t.Fatalf("CreatePublicShare failed: %v", err)
+		}
+		if share.ShortCode != fresh {
+			t.Errorf("Expected the colliding code to be replaced by %s, got %s", fresh, share.ShortCode)
+		}
+	})
+
+	t.Run("both links resolve to the file", func(t *testing.T) {
+		byToken, err := service.GetFileByShareToken(ctx, firstShare.ShareToken)
+		if err != nil {
+			t.Fatalf("GetFileByShareToken failed: %v", err)
+		}
+		byCode, err := service.GetFileByShareCode(ctx, firstShare.ShortCode)
+		if err != nil {
+			t.Fatalf("GetFileByShareCode failed: %v", err)
+		}
+		if byToken.ID != first || byCode.ID != first {
+			t.Errorf("Expected both links to resolve to %s, got %s and %s", first, byToken.ID, byCode.ID)
+		}
+	})
+
+	t.Run("a code that keeps colliding fails", func(t *testing.T) {
+		service.newShareCode = func() (string, error) { return taken, nil }
+		third := testutil.CreateFile(t, db, owner, "third.txt", []byte("third "+uuid.NewString()))
+		if _, err := service.CreatePublicShare(ctx, third, owner); err == nil {
+			t.Error("Expected sharing to fail once every code collided")
+		}
+	})
+
+	t.Run("making the file private retires the short link", func(t *testing.T) {
+		if err := service.RemovePublicShare(ctx, first, owner); err != nil {
+			t.Fatalf("RemovePublicShare failed: %v", err)
+		}
+		if _, err := service.GetFileByShareCode(ctx, taken); err == nil {
+			t.Error("Expected the short link to stop resolving")
+		}
+	})
+
+	t.Run("generated codes", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for i := 0; i < 1000; i++ {
+			code, err := generateShareCode()
+			if err != nil {
+				t.Fatalf("generateShareCode failed: %v", err)
+			}
+			if len(code) != shareCodeLength || strings.Trim(code, shareCodeAlphabet) != "" {
+				t.Fatalf("Expected %d alphanumeric characters, got %q", shareCodeLength, code)
+			}
+			if seen[code] {
+				t.Fatalf("Expected unique codes, got %q twice", code)
+			}
+			seen[code] = true
+		}
+	})
+}
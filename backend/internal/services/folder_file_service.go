@@ -3,118 +3,106 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"lokr-backend/internal/domain"
 )
 
 type FolderFileService struct {
-	db *pgxpool.Pool
+	db       *pgxpool.Pool
+	contents *FileContentService
 }
 
 func NewFolderFileService(db *pgxpool.Pool) *FolderFileService {
 	return &FolderFileService{
-		db: db,
+		db:       db,
+		contents: NewFileContentService(db),
 	}
 }
 
 // AddFileToFolder creates a copy of the file metadata for the target folder (like file sharing)
 func (s *FolderFileService) AddFileToFolder(ctx context.Context, fileID uuid.UUID, folderID uuid.UUID, userID uuid.UUID) (*domain.File, error) {
-	// Check if user owns the file
-	var ownerID uuid.UUID
-	err := s.db.QueryRow(ctx, "SELECT user_id FROM files WHERE id = $1", fileID).Scan(&ownerID)
+	return s.CopyFile(ctx, fileID, userID, &folderID)
+}
+
+// CopyFile creates a private copy of one of the user's files in destFolderID, or at the root when
+// it is nil. The copy is a new file row pointing at the same stored content, which takes one more
+// reference; no bytes are written and, like a deduplicated upload, it costs no storage. Missing
+// files and folders are ErrFileNotFound and ErrFolderNotFound, others' are ErrPermissionDenied.
+func (s *FolderFileService) CopyFile(ctx context.Context, fileID, userID uuid.UUID, destFolderID *uuid.UUID) (*domain.File, error) {
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("file not found")
-		}
-		return nil, fmt.Errorf("failed to check file ownership: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	if ownerID != userID {
-		return nil, fmt.Errorf("permission denied")
+	// Only files with a clean verdict can be copied, so a copy never escapes a pending scan
+	var original domain.File
+	err = tx.QueryRow(ctx, `
+		SELECT user_id, original_name, mime_type, file_size, content_hash, description, tags
+		FROM files WHERE id = $1 AND status = 'ACTIVE'`, fileID).Scan(
+		&original.UserID, &original.OriginalName, &original.MimeType, &original.FileSize,
+		&original.ContentHash, &original.Description, &original.Tags)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrFileNotFound
 	}
-
-	// Check if user owns the folder
-	var folderOwnerID uuid.UUID
-	err = s.db.QueryRow(ctx, "SELECT user_id FROM folders WHERE id = $1", folderID).Scan(&folderOwnerID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("folder not found")
-		}
-		return nil, fmt.Errorf("failed to check folder ownership: %w", err)
+		return nil, fmt.Errorf("failed to get file: %w", err)
 	}
-
-	if folderOwnerID != userID {
-		return nil, fmt.Errorf("permission denied - folder not owned by user")
+	if original.UserID != userID {
+		return nil, ErrPermissionDenied
 	}
 
-	// Create a copy of the file for the folder
-	copiedFileID, err := s.copyFileToFolder(ctx, fileID, folderID, userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to copy file to folder: %w", err)
+	if destFolderID != nil {
+		var folderOwnerID uuid.UUID
+		err = tx.QueryRow(ctx, "SELECT user_id FROM folders WHERE id = $1", *destFolderID).Scan(&folderOwnerID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrFolderNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check folder ownership: %w", err)
+		}
+		if folderOwnerID != userID {
+			return nil, ErrPermissionDenied
+		}
 	}
 
-	// Return the copied file
-	return s.getFileByID(ctx, copiedFileID)
-}
-
-// copyFileToFolder creates a copy of the file metadata for the target folder
-func (s *FolderFileService) copyFileToFolder(ctx context.Context, originalFileID uuid.UUID, folderID uuid.UUID, userID uuid.UUID) (uuid.UUID, error) {
-	// Get original file information
-	var originalFile domain.File
-	var originalFolderID sql.NullString
-	var description sql.NullString
-	var shareToken sql.NullString
-
-	err := s.db.QueryRow(ctx, `
-		SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
-		       content_hash, description, tags, visibility, share_token, download_count, upload_date
-		FROM files WHERE id = $1`, originalFileID).Scan(
-		&originalFile.ID, &originalFile.UserID, &originalFolderID, &originalFile.Filename, &originalFile.OriginalName,
-		&originalFile.MimeType, &originalFile.FileSize, &originalFile.ContentHash, &description, &originalFile.Tags,
-		&originalFile.Visibility, &shareToken, &originalFile.DownloadCount, &originalFile.UploadDate)
-	if err != nil {
-		return uuid.Nil, fmt.Errorf("failed to get original file: %w", err)
+	// The copy shares the stored content, wherever it was uploaded
+	if err := s.contents.inTx(tx).AddReference(ctx, original.ContentHash); err != nil {
+		return nil, err
 	}
 
-	// Create new file ID for the copy
-	copiedFileID := uuid.New()
-
 	// Keep the original filename - no need to modify it like in file sharing
-	newFilename := originalFile.OriginalName
-
-	// Insert the copied file record with the target folder ID
-	_, err = s.db.Exec(ctx, `
+	copiedFileID := uuid.New()
+	_, err = tx.Exec(ctx, `
 		INSERT INTO files (id, user_id, folder_id, filename, original_name, mime_type, file_size,
 		                  content_hash, description, tags, visibility, share_token, download_count, upload_date, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 'PRIVATE', NULL, 0, NOW(), NOW())`,
-		copiedFileID, userID, folderID, newFilename, newFilename, originalFile.MimeType, originalFile.FileSize,
-		originalFile.ContentHash, description, originalFile.Tags)
+		copiedFileID, userID, destFolderID, original.OriginalName, original.OriginalName, original.MimeType,
+		original.FileSize, original.ContentHash, original.Description, original.Tags)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("failed to create file copy: %w", err)
+		return nil, fmt.Errorf("failed to create file copy: %w", err)
 	}
 
-	// Update the reference count in file_contents (since we're sharing the same physical file)
-	_, err = s.db.Exec(ctx, `
-		UPDATE file_contents
-		SET reference_count = reference_count + 1
-		WHERE content_hash = $1`, originalFile.ContentHash)
+	// Encrypted content needs its metadata to stay readable
+	_, err = tx.Exec(ctx, `
+		INSERT INTO file_encryption (file_id, algorithm, iv, wrapped_key)
+		SELECT $1, algorithm, iv, wrapped_key FROM file_encryption WHERE file_id = $2`,
+		copiedFileID, fileID)
 	if err != nil {
-		// If the file_contents record doesn't exist, create it
-		_, err = s.db.Exec(ctx, `
-			INSERT INTO file_contents (content_hash, file_path, file_size, reference_count, created_at)
-			VALUES ($1, $2, $3, 1, NOW())
-			ON CONFLICT (content_hash) DO UPDATE SET reference_count = file_contents.reference_count + 1`,
-			originalFile.ContentHash, fmt.Sprintf("personal/users/%s/%s", originalFile.UserID.String(), originalFile.ContentHash), originalFile.FileSize)
-		if err != nil {
-			return uuid.Nil, fmt.Errorf("failed to update file contents reference: %w", err)
-		}
+		return nil, fmt.Errorf("failed to copy file encryption: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit file copy: %w", err)
 	}
 
-	return copiedFileID, nil
+	return s.getFileByID(ctx, copiedFileID)
 }
 
 // getFileByID retrieves a file by its ID
@@ -181,7 +169,7 @@ func (s *FolderFileService) GetFolderFiles(ctx context.Context, folderID uuid.UU
 		       content_hash, description, tags, visibility, share_token, download_count,
 		       upload_date, updated_at
 		FROM files
-		WHERE folder_id = $1 AND user_id = $2
+		WHERE folder_id = $1 AND user_id = $2 AND status <> 'INFECTED'
 		ORDER BY original_name ASC`, folderID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get folder files: %w", err)
@@ -0,0 +1,171 @@
+//go:build integration
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/storage"
+	"lokr-backend/internal/testutil"
+)
+
+func TestFolderFileService_CopiesEnterpriseContent(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	store := testutil.NewMemoryStorage()
+	service := NewFolderFileService(db)
+	contents := NewFileContentService(db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	userID := testutil.CreateUser(t, db, "copier", &enterpriseID)
+	content := []byte("enterprise copy " + uuid.NewString())
+	fileID := testutil.CreateFile(t, db, userID, "handbook.txt", content)
+
+	// Content uploaded within the enterprise lives under its namespace, not the personal one
+	var contentHash string
+	db.QueryRow(ctx, "SELECT content_hash FROM files WHERE id = $1", fileID).Scan(&contentHash)
+	enterprisePath := storage.ContentPath("acme", userID.String(), contentHash)
+	db.Exec(ctx, "UPDATE file_contents SET file_path = $1 WHERE content_hash = $2", enterprisePath, contentHash)
+	if err := store.Store(ctx, enterprisePath, bytes.NewReader(content), "text/plain"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	folder, err := NewFolderService(db, NewAuditService(db, zap.NewNop())).CreateFolder(ctx, userID, "Policies", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+
+	copied, err := service.AddFileToFolder(ctx, fileID, folder.ID, userID)
+	if err != nil {
+		t.Fatalf("AddFileToFolder failed: %v", err)
+	}
+
+	path, err := contents.ResolveStoragePath(ctx, copied.ContentHash)
+	if err != nil || path != enterprisePath {
+		t.Fatalf("Expected the copy to resolve to %s, got %q (%v)", enterprisePath, path, err)
+	}
+	downloaded, err := storage.ReadAll(ctx, store, path)
+	if err != nil || !bytes.Equal(downloaded, content) {
+		t.Errorf("Expected the copy to download the original content, got %q (%v)", downloaded, err)
+	}
+
+	var references int
+	db.QueryRow(ctx, "SELECT reference_count FROM file_contents WHERE content_hash = $1", contentHash).Scan(&references)
+	if references != 2 {
+		t.Errorf("Expected the copy to add a reference, got %d", references)
+	}
+
+	t.Run("content that isn't stored", func(t *testing.T) {
+		if err := contents.AddReference(ctx, "missing"); !errors.Is(err, ErrContentNotFound) {
+			t.Errorf("Expected ErrContentNotFound, got %v", err)
+		}
+		if _, err := contents.ResolveStoragePath(ctx, "missing"); !errors.Is(err, ErrContentNotFound) {
+			t.Errorf("Expected ErrContentNotFound, got %v", err)
+		}
+	})
+}
+
+func TestFolderFileService_CopyFile(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	service := NewFolderFileService(db)
+	folders := NewFolderService(db, NewAuditService(db, zap.NewNop()))
+
+	userID := testutil.CreateUser(t, db, "duplicator", nil)
+	fileID := testutil.CreateFile(t, db, userID, "notes.txt", []byte("copy me "+uuid.NewString()))
+	db.Exec(ctx, "UPDATE files SET visibility = 'PUBLIC', share_token = $1 WHERE id = $2", uuid.NewString(), fileID)
+
+	var contentHash, filePath string
+	db.QueryRow(ctx, "SELECT content_hash FROM files WHERE id = $1", fileID).Scan(&contentHash)
+	db.QueryRow(ctx, "SELECT file_path FROM file_contents WHERE content_hash = $1", contentHash).Scan(&filePath)
+	references := func() int {
+		var count int
+		db.QueryRow(ctx, "SELECT reference_count FROM file_contents WHERE content_hash = $1", contentHash).Scan(&count)
+		return count
+	}
+
+	folder, err := folders.CreateFolder(ctx, userID, "Archive", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+
+	intoFolder, err := service.CopyFile(ctx, fileID, userID, &folder.ID)
+	if err != nil {
+		t.Fatalf("CopyFile into a folder failed: %v", err)
+	}
+	if intoFolder.ID == fileID || intoFolder.FolderID == nil || *intoFolder.FolderID != folder.ID {
+		t.Errorf("Expected a new file in %s, got %+v", folder.ID, intoFolder)
+	}
+	if intoFolder.ContentHash != contentHash || intoFolder.OriginalName != "notes.txt" {
+		t.Errorf("Expected the copy to keep the name and content, got %+v", intoFolder)
+	}
+	if intoFolder.Visibility != domain.VisibilityPrivate || intoFolder.ShareToken != nil {
+		t.Errorf("Expected the copy to start private and unshared, got %s %v", intoFolder.Visibility, intoFolder.ShareToken)
+	}
+	if got := references(); got != 2 {
+		t.Errorf("Expected 2 references after one copy, got %d", got)
+	}
+
+	atRoot, err := service.CopyFile(ctx, intoFolder.ID, userID, nil)
+	if err != nil {
+		t.Fatalf("CopyFile to the root failed: %v", err)
+	}
+	if atRoot.FolderID != nil {
+		t.Errorf("Expected the copy at the root, got folder %v", atRoot.FolderID)
+	}
+	if got := references(); got != 3 {
+		t.Errorf("Expected 3 references after two copies, got %d", got)
+	}
+
+	// No new content is stored for a copy
+	var contentRows int
+	db.QueryRow(ctx, "SELECT COUNT(*) FROM file_contents WHERE file_path = $1", filePath).Scan(&contentRows)
+	if contentRows != 1 {
+		t.Errorf("Expected the copies to share one content row, got %d", contentRows)
+	}
+
+	t.Run("rejected copies don't take references", func(t *testing.T) {
+		other := testutil.CreateUser(t, db, "bystander", nil)
+		otherFolder, err := folders.CreateFolder(ctx, other, "Theirs", nil)
+		if err != nil {
+			t.Fatalf("CreateFolder failed: %v", err)
+		}
+		missingFolder := uuid.New()
+
+		tests := []struct {
+			name   string
+			fileID uuid.UUID
+			userID uuid.UUID
+			folder *uuid.UUID
+			want   error
+		}{
+			{"someone else's file", fileID, other, nil, ErrPermissionDenied},
+			{"someone else's folder", fileID, userID, &otherFolder.ID, ErrPermissionDenied},
+			{"missing file", uuid.New(), userID, nil, ErrFileNotFound},
+			{"missing folder", fileID, userID, &missingFolder, ErrFolderNotFound},
+		}
+		for _, tt := range tests {
+			if _, err := service.CopyFile(ctx, tt.fileID, tt.userID, tt.folder); !errors.Is(err, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+			}
+		}
+		if got := references(); got != 3 {
+			t.Errorf("Expected the references to stay at 3, got %d", got)
+		}
+	})
+
+	t.Run("files awaiting a scan", func(t *testing.T) {
+		db.Exec(ctx, "UPDATE files SET status = 'PENDING_SCAN' WHERE id = $1", fileID)
+		defer db.Exec(ctx, "UPDATE files SET status = 'ACTIVE' WHERE id = $1", fileID)
+		if _, err := service.CopyFile(ctx, fileID, userID, nil); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("Expected ErrFileNotFound, got %v", err)
+		}
+	})
+}
@@ -2,21 +2,25 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"lokr-backend/internal/domain"
 )
 
 type FolderService struct {
-	db *pgxpool.Pool
+	db           *pgxpool.Pool
+	auditService *AuditService
 }
 
-func NewFolderService(db *pgxpool.Pool) *FolderService {
-	return &FolderService{db: db}
+// NewFolderService creates the folder service; auditService may be nil to skip audit logging
+func NewFolderService(db *pgxpool.Pool, auditService *AuditService) *FolderService {
+	return &FolderService{db: db, auditService: auditService}
 }
 
 // Create creates a new folder
@@ -69,6 +73,9 @@ func (s *FolderService) CreateFolder(ctx context.Context, userID uuid.UUID, name
 		return nil, fmt.Errorf("failed to create folder: %w", err)
 	}
 
+	ipAddress, userAgent := RequestMeta(ctx)
+	s.auditService.LogFolderCreate(ctx, userID, folder.ID, folder.Name, ipAddress, userAgent)
+
 	return folder, nil
 }
 
@@ -198,6 +205,9 @@ func (s *FolderService) RenameFolder(ctx context.Context, folderID, userID uuid.
 		return nil, fmt.Errorf("failed to rename folder: %w", err)
 	}
 
+	ipAddress, userAgent := RequestMeta(ctx)
+	s.auditService.LogFolderRename(ctx, userID, folderID, folder.Name, newName, ipAddress, userAgent)
+
 	// Return updated folder
 	folder.Name = newName
 	folder.UpdatedAt = time.Now()
@@ -205,7 +215,12 @@ func (s *FolderService) RenameFolder(ctx context.Context, folderID, userID uuid.
 	return folder, nil
 }
 
-// MoveFolder moves a folder to a new parent
+// ErrFolderCycle rejects a parent change that would put a folder inside itself or its own subtree
+var ErrFolderCycle = errors.New("folder cannot be moved into itself or its own subfolder")
+
+// MoveFolder moves a folder to a new parent, or to the root when newParentID is nil. Every parent
+// change goes through checkFolderParent, and moves of one user's folders are serialized, so two
+// concurrent moves can't combine into a cycle that neither would create alone.
 func (s *FolderService) MoveFolder(ctx context.Context, folderID, userID uuid.UUID, newParentID *uuid.UUID) (*domain.Folder, error) {
 	// Get the folder to ensure user ownership
 	folder, err := s.GetFolderByID(ctx, folderID, userID)
@@ -213,20 +228,18 @@ func (s *FolderService) MoveFolder(ctx context.Context, folderID, userID uuid.UU
 		return nil, err
 	}
 
-	// Prevent moving folder into itself or its descendants
-	if newParentID != nil && *newParentID == folderID {
-		return nil, fmt.Errorf("cannot move folder into itself")
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	// Check for circular reference by ensuring new parent is not a descendant
-	if newParentID != nil {
-		isDescendant, err := s.isDescendant(ctx, folderID, *newParentID)
-		if err != nil {
-			return nil, err
-		}
-		if isDescendant {
-			return nil, fmt.Errorf("cannot move folder into its own descendant")
-		}
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('folders:' || $1::text))", userID); err != nil {
+		return nil, fmt.Errorf("failed to lock folders: %w", err)
+	}
+
+	if err := checkFolderParent(ctx, tx, folderID, userID, newParentID); err != nil {
+		return nil, err
 	}
 
 	// Check if folder with same name already exists in new parent
@@ -242,7 +255,7 @@ func (s *FolderService) MoveFolder(ctx context.Context, folderID, userID uuid.UU
 		checkArgs = []interface{}{userID, *newParentID, folder.Name, folderID}
 	}
 
-	err = s.db.QueryRow(ctx, checkQuery, checkArgs...).Scan(&existingCount)
+	err = tx.QueryRow(ctx, checkQuery, checkArgs...).Scan(&existingCount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check existing folder: %w", err)
 	}
@@ -257,11 +270,18 @@ func (s *FolderService) MoveFolder(ctx context.Context, folderID, userID uuid.UU
 		SET parent_id = $1, updated_at = $2
 		WHERE id = $3 AND user_id = $4`
 
-	_, err = s.db.Exec(ctx, query, newParentID, time.Now(), folderID, userID)
+	_, err = tx.Exec(ctx, query, newParentID, time.Now(), folderID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to move folder: %w", err)
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit move: %w", err)
+	}
+
+	ipAddress, userAgent := RequestMeta(ctx)
+	s.auditService.LogFolderMove(ctx, userID, folderID, folder.Name, folder.ParentID, newParentID, ipAddress, userAgent)
+
 	// Return updated folder
 	folder.ParentID = newParentID
 	folder.UpdatedAt = time.Now()
@@ -269,10 +289,51 @@ func (s *FolderService) MoveFolder(ctx context.Context, folderID, userID uuid.UU
 	return folder, nil
 }
 
+// ValidateParent reports whether parentID may become the folder's parent without changing
+// anything, so callers that apply several changes can reject a bad move before making the others
+func (s *FolderService) ValidateParent(ctx context.Context, folderID, userID uuid.UUID, parentID *uuid.UUID) error {
+	return checkFolderParent(ctx, s.db, folderID, userID, parentID)
+}
+
+// rowQuerier is satisfied by both the pool and a transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// checkFolderParent is the guard for giving a folder a new parent: the parent must be another of
+// the user's folders and must not sit inside the folder being moved. A nil parent (the root) is
+// always allowed.
+func checkFolderParent(ctx context.Context, q rowQuerier, folderID, userID uuid.UUID, parentID *uuid.UUID) error {
+	if parentID == nil {
+		return nil
+	}
+	if *parentID == folderID {
+		return fmt.Errorf("%w: a folder cannot be its own parent", ErrFolderCycle)
+	}
+
+	var ownsParent bool
+	err := q.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM folders WHERE id = $1 AND user_id = $2)", *parentID, userID).Scan(&ownsParent)
+	if err != nil {
+		return fmt.Errorf("failed to check parent folder: %w", err)
+	}
+	if !ownsParent {
+		return fmt.Errorf("parent folder not found or access denied")
+	}
+
+	inside, err := isDescendant(ctx, q, folderID, *parentID)
+	if err != nil {
+		return err
+	}
+	if inside {
+		return fmt.Errorf("%w: the new parent is inside the folder", ErrFolderCycle)
+	}
+	return nil
+}
+
 // DeleteFolder deletes a folder and optionally its contents
 func (s *FolderService) DeleteFolder(ctx context.Context, folderID, userID uuid.UUID, force bool) error {
 	// Get the folder to ensure user ownership
-	_, err := s.GetFolderByID(ctx, folderID, userID)
+	folder, err := s.GetFolderByID(ctx, folderID, userID)
 	if err != nil {
 		return err
 	}
@@ -309,6 +370,9 @@ func (s *FolderService) DeleteFolder(ctx context.Context, folderID, userID uuid.
 		return fmt.Errorf("folder not found")
 	}
 
+	ipAddress, userAgent := RequestMeta(ctx)
+	s.auditService.LogFolderDelete(ctx, userID, folderID, folder.Name, ipAddress, userAgent)
+
 	return nil
 }
 
@@ -353,7 +417,7 @@ func (s *FolderService) GetFolderContents(ctx context.Context, folderID *uuid.UU
 			SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
 				   content_hash, description, tags, visibility, share_token, download_count, upload_date, updated_at
 			FROM files
-			WHERE user_id = $1 AND folder_id IS NULL
+			WHERE user_id = $1 AND folder_id IS NULL AND status <> 'INFECTED'
 			ORDER BY upload_date DESC`
 		fileArgs = []interface{}{userID}
 	} else {
@@ -362,7 +426,7 @@ func (s *FolderService) GetFolderContents(ctx context.Context, folderID *uuid.UU
 			SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
 				   content_hash, description, tags, visibility, share_token, download_count, upload_date, updated_at
 			FROM files
-			WHERE user_id = $1 AND folder_id = $2
+			WHERE user_id = $1 AND folder_id = $2 AND status <> 'INFECTED'
 			ORDER BY upload_date DESC`
 		fileArgs = []interface{}{userID, *folderID}
 	}
@@ -390,8 +454,116 @@ func (s *FolderService) GetFolderContents(ctx context.Context, folderID *uuid.UU
 	return folders, files, nil
 }
 
+// maxFolderDepth bounds walks up the folder tree so corrupt parent links cannot loop forever
+const maxFolderDepth = 64
+
+// GetFolderPath returns the breadcrumb for a folder: its ancestors from the root down, ending with
+// the folder itself. The walk stops at a cycle or after maxFolderDepth levels.
+func (s *FolderService) GetFolderPath(ctx context.Context, folderID, userID uuid.UUID) ([]*domain.Folder, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, user_id, name, parent_id, created_at, updated_at, 0 AS depth, ARRAY[id] AS visited
+			FROM folders
+			WHERE id = $1 AND user_id = $2
+
+			UNION ALL
+
+			SELECT f.id, f.user_id, f.name, f.parent_id, f.created_at, f.updated_at, a.depth + 1, a.visited || f.id
+			FROM folders f
+			INNER JOIN ancestors a ON f.id = a.parent_id
+			WHERE f.user_id = $2 AND a.depth < $3 AND NOT f.id = ANY(a.visited)
+		)
+		SELECT id, user_id, name, parent_id, created_at, updated_at
+		FROM ancestors
+		ORDER BY depth DESC`
+
+	rows, err := s.db.Query(ctx, query, folderID, userID, maxFolderDepth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folder path: %w", err)
+	}
+	defer rows.Close()
+
+	var path []*domain.Folder
+	for rows.Next() {
+		var folder domain.Folder
+		err := rows.Scan(
+			&folder.ID, &folder.UserID, &folder.Name, &folder.ParentID, &folder.CreatedAt, &folder.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan folder: %w", err)
+		}
+		path = append(path, &folder)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get folder path: %w", err)
+	}
+
+	if len(path) == 0 {
+		return nil, fmt.Errorf("folder not found")
+	}
+
+	return path, nil
+}
+
+// GetFolderStats counts the files and bytes in a folder and all of its subfolders, or in all of
+// the user's files when folderID is nil. Adding a file to a folder copies its metadata, so the
+// same content can sit in several folders: FileCount and TotalSize count every copy, the way the
+// user sees them, while DedupedSize counts each distinct content once, the way it is stored.
+// Infected files are left out, matching the file listings.
+func (s *FolderService) GetFolderStats(ctx context.Context, folderID *uuid.UUID, userID uuid.UUID) (*domain.FolderStats, error) {
+	// The root has no folder row, so its tree starts at the top-level folders and also takes in
+	// files with no folder
+	treeBase := "SELECT id FROM folders WHERE user_id = $1 AND parent_id IS NULL"
+	inTree := "(fi.folder_id IS NULL OR fi.folder_id IN (SELECT id FROM tree))"
+	subfolders := "(SELECT COUNT(*) FROM tree)"
+	args := []interface{}{userID}
+	if folderID != nil {
+		if _, err := s.GetFolderByID(ctx, *folderID, userID); err != nil {
+			return nil, err
+		}
+		treeBase = "SELECT id FROM folders WHERE user_id = $1 AND id = $2"
+		inTree = "fi.folder_id IN (SELECT id FROM tree)"
+		subfolders = "(SELECT COUNT(*) - 1 FROM tree)"
+		args = append(args, *folderID)
+	}
+
+	query := `
+		WITH RECURSIVE tree AS (
+			` + treeBase + `
+
+			UNION ALL
+
+			SELECT f.id
+			FROM folders f
+			INNER JOIN tree t ON f.parent_id = t.id
+			WHERE f.user_id = $1
+		),
+		tree_files AS (
+			SELECT fi.file_size, fi.content_hash
+			FROM files fi
+			WHERE fi.user_id = $1 AND fi.status <> 'INFECTED' AND ` + inTree + `
+		)
+		SELECT
+			` + subfolders + `,
+			(SELECT COUNT(*) FROM tree_files),
+			(SELECT COALESCE(SUM(file_size), 0) FROM tree_files),
+			(SELECT COALESCE(SUM(fc.file_size), 0)
+			 FROM file_contents fc
+			 WHERE fc.content_hash IN (SELECT content_hash FROM tree_files))`
+
+	stats := &domain.FolderStats{FolderID: folderID}
+	err := s.db.QueryRow(ctx, query, args...).Scan(
+		&stats.FolderCount, &stats.FileCount, &stats.TotalSize, &stats.DedupedSize,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute folder stats: %w", err)
+	}
+
+	return stats, nil
+}
+
 // isDescendant checks if targetID is a descendant of ancestorID
-func (s *FolderService) isDescendant(ctx context.Context, ancestorID, targetID uuid.UUID) (bool, error) {
+func isDescendant(ctx context.Context, q rowQuerier, ancestorID, targetID uuid.UUID) (bool, error) {
 	query := `
 		WITH RECURSIVE folder_tree AS (
 			-- Base case: direct children of ancestor
@@ -409,7 +581,7 @@ func (s *FolderService) isDescendant(ctx context.Context, ancestorID, targetID u
 		SELECT EXISTS(SELECT 1 FROM folder_tree WHERE id = $2)`
 
 	var exists bool
-	err := s.db.QueryRow(ctx, query, ancestorID, targetID).Scan(&exists)
+	err := q.QueryRow(ctx, query, ancestorID, targetID).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check descendant relationship: %w", err)
 	}
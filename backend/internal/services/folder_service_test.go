@@ -0,0 +1,276 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/testutil"
+)
+
+func TestFolderService_RenameFolderWritesAuditLog(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewFolderService(db, NewAuditService(db, zap.NewNop()))
+	userID := testutil.CreateUser(t, db, "renamer", nil)
+
+	folder, err := service.CreateFolder(context.Background(), userID, "Drafts", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+
+	if _, err := service.RenameFolder(context.Background(), folder.ID, userID, "Final"); err != nil {
+		t.Fatalf("RenameFolder failed: %v", err)
+	}
+
+	var resourceName string
+	var metadataJSON []byte
+	err = db.QueryRow(context.Background(), `
+		SELECT resource_name, metadata FROM audit_logs
+		WHERE user_id = $1 AND resource_id = $2 AND action = $3`,
+		userID, folder.ID, domain.ActionFolderRename).Scan(&resourceName, &metadataJSON)
+	if err != nil {
+		t.Fatalf("Expected a rename audit entry: %v", err)
+	}
+
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+		t.Fatalf("Failed to decode metadata: %v", err)
+	}
+
+	if resourceName != "Final" {
+		t.Errorf("Expected resource name to be 'Final', got '%s'", resourceName)
+	}
+	if metadata["old_name"] != "Drafts" {
+		t.Errorf("Expected old_name to be 'Drafts', got %v", metadata["old_name"])
+	}
+	if metadata["new_name"] != "Final" {
+		t.Errorf("Expected new_name to be 'Final', got %v", metadata["new_name"])
+	}
+}
+
+func TestFolderService_MoveFolderRecordsParents(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewFolderService(db, NewAuditService(db, zap.NewNop()))
+	userID := testutil.CreateUser(t, db, "mover", nil)
+
+	parent, err := service.CreateFolder(context.Background(), userID, "Archive", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	child, err := service.CreateFolder(context.Background(), userID, "2023", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+
+	if _, err := service.MoveFolder(context.Background(), child.ID, userID, &parent.ID); err != nil {
+		t.Fatalf("MoveFolder failed: %v", err)
+	}
+
+	var metadataJSON []byte
+	err = db.QueryRow(context.Background(), `
+		SELECT metadata FROM audit_logs
+		WHERE user_id = $1 AND resource_id = $2 AND action = $3`,
+		userID, child.ID, domain.ActionFolderMove).Scan(&metadataJSON)
+	if err != nil {
+		t.Fatalf("Expected a move audit entry: %v", err)
+	}
+
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+		t.Fatalf("Failed to decode metadata: %v", err)
+	}
+
+	if metadata["old_parent_id"] != nil {
+		t.Errorf("Expected old_parent_id to be null for a root folder, got %v", metadata["old_parent_id"])
+	}
+	if metadata["new_parent_id"] != parent.ID.String() {
+		t.Errorf("Expected new_parent_id to be %s, got %v", parent.ID, metadata["new_parent_id"])
+	}
+}
+
+func TestFolderService_MoveFolderRejectsCycles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewFolderService(db, NewAuditService(db, zap.NewNop()))
+	ctx := context.Background()
+	userID := testutil.CreateUser(t, db, "cycler", nil)
+	otherID := testutil.CreateUser(t, db, "bystander", nil)
+
+	top, err := service.CreateFolder(ctx, userID, "top", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	middle, err := service.CreateFolder(ctx, userID, "middle", &top.ID)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	leaf, err := service.CreateFolder(ctx, userID, "leaf", &middle.ID)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	foreign, err := service.CreateFolder(ctx, otherID, "foreign", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		folderID  uuid.UUID
+		parentID  uuid.UUID
+		wantCycle bool
+	}{
+		{"own parent", top.ID, top.ID, true},
+		{"into child", top.ID, middle.ID, true},
+		{"into grandchild", top.ID, leaf.ID, true},
+		{"into another user's folder", leaf.ID, foreign.ID, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.MoveFolder(ctx, tt.folderID, userID, &tt.parentID)
+			if err == nil {
+				t.Fatal("Expected the move to be rejected")
+			}
+			if errors.Is(err, ErrFolderCycle) != tt.wantCycle {
+				t.Errorf("Expected ErrFolderCycle=%v, got %v", tt.wantCycle, err)
+			}
+		})
+	}
+
+	// Nothing moved
+	var parentID *uuid.UUID
+	if err := db.QueryRow(ctx, "SELECT parent_id FROM folders WHERE id = $1", top.ID).Scan(&parentID); err != nil {
+		t.Fatalf("Failed to read folder: %v", err)
+	}
+	if parentID != nil {
+		t.Errorf("Expected top to stay at the root, got parent %s", parentID)
+	}
+}
+
+func TestFolderService_GetFolderStats(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewFolderService(db, nil)
+	ctx := context.Background()
+	userID := testutil.CreateUser(t, db, "counter", nil)
+
+	// projects/ holds a file and design/, which holds another file plus a copy of the first; empty/
+	// sits beside projects/ with nothing in it
+	projects, err := service.CreateFolder(ctx, userID, "projects", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	design, err := service.CreateFolder(ctx, userID, "design", &projects.ID)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	empty, err := service.CreateFolder(ctx, userID, "empty", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+
+	shared := []byte("shared plan " + uuid.NewString())
+	plan := testutil.CreateFile(t, db, userID, "plan.txt", shared)
+	mock := testutil.CreateFile(t, db, userID, "mock.png", []byte("mockup "+uuid.NewString()))
+	copyID := testutil.CreateFile(t, db, userID, "plan copy.txt", shared)
+	root := testutil.CreateFile(t, db, userID, "root.txt", []byte("at the root "+uuid.NewString()))
+	db.Exec(ctx, "UPDATE files SET folder_id = $1 WHERE id = $2", projects.ID, plan)
+	db.Exec(ctx, "UPDATE files SET folder_id = $1 WHERE id IN ($2, $3)", design.ID, mock, copyID)
+
+	size := func(id uuid.UUID) int64 {
+		var size int64
+		db.QueryRow(ctx, "SELECT file_size FROM files WHERE id = $1", id).Scan(&size)
+		return size
+	}
+
+	tests := []struct {
+		name        string
+		folderID    *uuid.UUID
+		folders     int
+		files       int
+		totalSize   int64
+		dedupedSize int64
+	}{
+		{"nested", &projects.ID, 1, 3, 2*size(plan) + size(mock), size(plan) + size(mock)},
+		{"leaf", &design.ID, 0, 2, size(plan) + size(mock), size(plan) + size(mock)},
+		{"empty", &empty.ID, 0, 0, 0, 0},
+		{"root", nil, 3, 4, 2*size(plan) + size(mock) + size(root), size(plan) + size(mock) + size(root)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := service.GetFolderStats(ctx, tt.folderID, userID)
+			if err != nil {
+				t.Fatalf("GetFolderStats failed: %v", err)
+			}
+			if stats.FolderCount != tt.folders || stats.FileCount != tt.files {
+				t.Errorf("Expected %d folders and %d files, got %d and %d", tt.folders, tt.files, stats.FolderCount, stats.FileCount)
+			}
+			if stats.TotalSize != tt.totalSize {
+				t.Errorf("Expected total size %d, got %d", tt.totalSize, stats.TotalSize)
+			}
+			if stats.DedupedSize != tt.dedupedSize {
+				t.Errorf("Expected deduplicated size %d, got %d", tt.dedupedSize, stats.DedupedSize)
+			}
+		})
+	}
+
+	other := testutil.CreateUser(t, db, "snoop", nil)
+	if _, err := service.GetFolderStats(ctx, &projects.ID, other); err == nil {
+		t.Error("Expected another user's folder to be rejected")
+	}
+}
+
+func TestFolderService_GetFolderPath(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewFolderService(db, nil)
+	ctx := context.Background()
+	userID := testutil.CreateUser(t, db, "navigator", nil)
+
+	top, err := service.CreateFolder(ctx, userID, "Work", nil)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	middle, err := service.CreateFolder(ctx, userID, "Clients", &top.ID)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+	leaf, err := service.CreateFolder(ctx, userID, "Acme", &middle.ID)
+	if err != nil {
+		t.Fatalf("CreateFolder failed: %v", err)
+	}
+
+	path, err := service.GetFolderPath(ctx, leaf.ID, userID)
+	if err != nil {
+		t.Fatalf("GetFolderPath failed: %v", err)
+	}
+
+	want := []string{"Work", "Clients", "Acme"}
+	if len(path) != len(want) {
+		t.Fatalf("Expected %d breadcrumb entries, got %d", len(want), len(path))
+	}
+	for i, folder := range path {
+		if folder.Name != want[i] {
+			t.Errorf("Entry %d: expected '%s', got '%s'", i, want[i], folder.Name)
+		}
+	}
+
+	// A corrupt cycle must not loop forever
+	db.Exec(ctx, "UPDATE folders SET parent_id = $1 WHERE id = $2", leaf.ID, top.ID)
+	cyclic, err := service.GetFolderPath(ctx, leaf.ID, userID)
+	if err != nil {
+		t.Fatalf("GetFolderPath with a cycle failed: %v", err)
+	}
+	if len(cyclic) != 3 {
+		t.Errorf("Expected the walk to stop after visiting each folder once, got %d entries", len(cyclic))
+	}
+
+	if _, err := service.GetFolderPath(ctx, leaf.ID, testutil.CreateUser(t, db, "intruder", nil)); err == nil {
+		t.Error("Expected another user's folder to be rejected")
+	}
+}
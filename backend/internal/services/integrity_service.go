@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/storage"
+	"lokr-backend/pkg/hash"
+)
+
+// defaultIntegrityConcurrency is how many objects are fetched and hashed at once
+const defaultIntegrityConcurrency = 4
+
+// Integrity issue kinds, as stored in integrity_issues.issue
+const (
+	IntegrityIssueMissing      = "MISSING"
+	IntegrityIssueHashMismatch = "HASH_MISMATCH"
+)
+
+// IntegrityIssue is stored content that is missing or doesn't hash to its content_hash
+type IntegrityIssue struct {
+	ContentHash string
+	FilePath    string
+	Issue       string
+	ActualHash  string // Empty for missing objects
+}
+
+// IntegrityOptions controls a verification run
+type IntegrityOptions struct {
+	UserID        *uuid.UUID // Only check content this user's files point at
+	SamplePercent float64    // Share of content to check, in (0, 100]; 0 checks everything
+	Concurrency   int        // Objects checked at once; 0 uses the default
+	Record        bool       // Write issues to integrity_issues and clear those that now verify
+	Fix           bool       // Quarantine the files that point at affected content
+}
+
+// IntegrityReport summarises a verification run
+type IntegrityReport struct {
+	Checked     int
+	Failed      int // Objects that couldn't be read for reasons other than being missing
+	Issues      []IntegrityIssue
+	Quarantined int
+}
+
+// IntegrityService re-checks stored objects against the content hashes they are stored under
+type IntegrityService struct {
+	db           *pgxpool.Pool
+	storage      storage.StorageService
+	auditService *AuditService
+	logger       *zap.Logger
+}
+
+func NewIntegrityService(db *pgxpool.Pool, store storage.StorageService, auditService *AuditService, logger *zap.Logger) *IntegrityService {
+	return &IntegrityService{
+		db:           db,
+		storage:      store,
+		auditService: auditService,
+		logger:       logger,
+	}
+}
+
+type storedContent struct {
+	contentHash   string
+	hashAlgorithm string
+	filePath      string
+}
+
+// Verify fetches every stored object, or a random sample of them, recomputes its hash with the
+// algorithm it was stored under and reports the objects that are missing or don't match
+func (s *IntegrityService) Verify(ctx context.Context, opts IntegrityOptions) (*IntegrityReport, error) {
+	if opts.SamplePercent < 0 || opts.SamplePercent > 100 {
+		return nil, fmt.Errorf("sample percentage must be between 0 and 100, got %v", opts.SamplePercent)
+	}
+	contents, err := s.sampleContents(ctx, opts.UserID, opts.SamplePercent)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultIntegrityConcurrency
+	}
+
+	report := &IntegrityReport{}
+	var verified []string
+	var mu sync.Mutex
+	work := make(chan storedContent)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for content := range work {
+				issue, err := s.check(ctx, content)
+				mu.Lock()
+				report.Checked++
+				switch {
+				case err != nil:
+					report.Failed++
+					s.logger.Warn("Failed to verify stored content",
+						zap.String("content_hash", content.contentHash),
+						zap.Error(err))
+				case issue != nil:
+					report.Issues = append(report.Issues, *issue)
+				default:
+					verified = append(verified, content.contentHash)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, content := range contents {
+		select {
+		case work <- content:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(work)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+
+	if opts.Record {
+		if err := s.record(ctx, report.Issues, verified); err != nil {
+			return report, err
+		}
+	}
+	if opts.Fix {
+		for _, issue := range report.Issues {
+			quarantined, err := s.quarantine(ctx, issue, opts.UserID)
+			if err != nil {
+				return report, err
+			}
+			report.Quarantined += quarantined
+		}
+	}
+
+	return report, nil
+}
+
+// sampleContents loads the content records to check, each kept with a probability of percent
+func (s *IntegrityService) sampleContents(ctx context.Context, userID *uuid.UUID, percent float64) ([]storedContent, error) {
+	if percent <= 0 || percent > 100 {
+		percent = 100
+	}
+	query := `
+		SELECT fc.content_hash, fc.hash_algorithm, fc.file_path
+		FROM file_contents fc
+		WHERE random() * 100 < $1
+		AND ($2::uuid IS NULL OR EXISTS (SELECT 1 FROM files f WHERE f.content_hash = fc.content_hash AND f.user_id = $2))`
+	args := []interface{}{percent, userID}
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored content: %w", err)
+	}
+	defer rows.Close()
+
+	var contents []storedContent
+	for rows.Next() {
+		var content storedContent
+		if err := rows.Scan(&content.contentHash, &content.hashAlgorithm, &content.filePath); err != nil {
+			return nil, fmt.Errorf("failed to scan stored content: %w", err)
+		}
+		contents = append(contents, content)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stored content: %w", err)
+	}
+	return contents, nil
+}
+
+// check hashes one object, returning the issue found or nil when it matches
+func (s *IntegrityService) check(ctx context.Context, content storedContent) (*IntegrityIssue, error) {
+	exists, err := s.storage.Exists(ctx, content.filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return &IntegrityIssue{ContentHash: content.contentHash, FilePath: content.filePath, Issue: IntegrityIssueMissing}, nil
+	}
+
+	hasher, err := hash.NewHasher(content.hashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	actualHash, err := storage.Hash(ctx, s.storage, content.filePath, hasher)
+	if err != nil {
+		return nil, err
+	}
+	if actualHash != content.contentHash {
+		return &IntegrityIssue{
+			ContentHash: content.contentHash,
+			FilePath:    content.filePath,
+			Issue:       IntegrityIssueHashMismatch,
+			ActualHash:  actualHash,
+		}, nil
+	}
+	return nil, nil
+}
+
+// record upserts the issues found and clears earlier issues for content that now verifies
+func (s *IntegrityService) record(ctx context.Context, issues []IntegrityIssue, verified []string) error {
+	for _, issue := range issues {
+		var actualHash *string
+		if issue.ActualHash != "" {
+			actualHash = &issue.ActualHash
+		}
+		if _, err := s.db.Exec(ctx, `
+			INSERT INTO integrity_issues (content_hash, file_path, issue, actual_hash, detected_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			ON CONFLICT (content_hash) DO UPDATE
+			SET file_path = EXCLUDED.file_path, issue = EXCLUDED.issue,
+				actual_hash = EXCLUDED.actual_hash, detected_at = EXCLUDED.detected_at`,
+			issue.ContentHash, issue.FilePath, issue.Issue, actualHash); err != nil {
+			return fmt.Errorf("failed to record integrity issue: %w", err)
+		}
+	}
+
+	if len(verified) > 0 {
+		if _, err := s.db.Exec(ctx, "DELETE FROM integrity_issues WHERE content_hash = ANY($1)", verified); err != nil {
+			return fmt.Errorf("failed to clear resolved integrity issues: %w", err)
+		}
+	}
+	return nil
+}
+
+// quarantine marks the active files pointing at affected content as corrupted, so they are no
+// longer served or reused for deduplication. With userID, only that user's files are touched.
+// Returns the number of files quarantined.
+func (s *IntegrityService) quarantine(ctx context.Context, issue IntegrityIssue, userID *uuid.UUID) (int, error) {
+	rows, err := s.db.Query(ctx, `
+		UPDATE files
+		SET status = $1, updated_at = NOW()
+		WHERE content_hash = $2 AND status = $3 AND ($4::uuid IS NULL OR user_id = $4)
+		RETURNING id, user_id, original_name`,
+		domain.FileStatusCorrupted, issue.ContentHash, domain.FileStatusActive, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to quarantine files: %w", err)
+	}
+
+	var files []domain.File
+	for rows.Next() {
+		var file domain.File
+		if err := rows.Scan(&file.ID, &file.UserID, &file.OriginalName); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan quarantined file: %w", err)
+		}
+		files = append(files, file)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to quarantine files: %w", err)
+	}
+
+	reason := "content missing from storage"
+	if issue.Issue == IntegrityIssueHashMismatch {
+		reason = "content does not match its hash"
+	}
+	for _, file := range files {
+		s.logger.Warn("Corrupted file quarantined",
+			zap.String("file_id", file.ID.String()),
+			zap.String("content_hash", issue.ContentHash),
+			zap.String("reason", reason))
+		s.auditService.LogFileQuarantine(ctx, file.UserID, file.ID, file.OriginalName, reason)
+	}
+	return len(files), nil
+}
@@ -0,0 +1,108 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/storage"
+	"lokr-backend/internal/testutil"
+)
+
+func TestIntegrityService_Verify(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	store, storageDir := testutil.NewLocalStorage(t)
+	files := NewSimpleFileService(db, store, nil, nil, zap.NewNop())
+	integrity := NewIntegrityService(db, store, nil, zap.NewNop())
+	ctx := context.Background()
+
+	userID := testutil.CreateUser(t, db, "integrity", nil)
+	contents := map[uuid.UUID][]byte{}
+	upload := func(name string) *domain.File {
+		content := []byte(name + " " + uuid.NewString())
+		cleanupContent(t, db, content)
+		file, err := files.UploadFile(ctx, userID, name, "text/plain", content, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("UploadFile failed: %v", err)
+		}
+		contents[file.ID] = content
+		return file
+	}
+	intact := upload("intact.txt")
+	tampered := upload("tampered.txt")
+	missing := upload("missing.txt")
+
+	// Flip the stored bytes of one object and remove another
+	tamperedPath := filepath.Join(storageDir, storage.ContentPath("", userID.String(), tampered.ContentHash))
+	if err := os.WriteFile(tamperedPath, []byte("bit rot"), 0644); err != nil {
+		t.Fatalf("Failed to tamper with the stored object: %v", err)
+	}
+	if err := os.Remove(filepath.Join(storageDir, storage.ContentPath("", userID.String(), missing.ContentHash))); err != nil {
+		t.Fatalf("Failed to remove the stored object: %v", err)
+	}
+
+	report, err := integrity.Verify(ctx, IntegrityOptions{UserID: &userID, Concurrency: 2, Record: true, Fix: true})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.Checked != 3 || report.Failed != 0 {
+		t.Errorf("Expected 3 objects checked without failures, got %+v", report)
+	}
+	issues := map[string]string{}
+	for _, issue := range report.Issues {
+		issues[issue.ContentHash] = issue.Issue
+	}
+	want := map[string]string{tampered.ContentHash: IntegrityIssueHashMismatch, missing.ContentHash: IntegrityIssueMissing}
+	if len(issues) != len(want) || issues[tampered.ContentHash] != want[tampered.ContentHash] || issues[missing.ContentHash] != want[missing.ContentHash] {
+		t.Errorf("Expected issues %v, got %v", want, issues)
+	}
+	if report.Quarantined != 2 {
+		t.Errorf("Expected 2 files quarantined, got %d", report.Quarantined)
+	}
+
+	for file, status := range map[*domain.File]domain.FileStatus{
+		intact:   domain.FileStatusActive,
+		tampered: domain.FileStatusCorrupted,
+		missing:  domain.FileStatusCorrupted,
+	} {
+		var got domain.FileStatus
+		db.QueryRow(ctx, "SELECT status FROM files WHERE id = $1", file.ID).Scan(&got)
+		if got != status {
+			t.Errorf("Expected %s to be %s, got %s", file.OriginalName, status, got)
+		}
+	}
+
+	var actualHash *string
+	if err := db.QueryRow(ctx, `
+		SELECT actual_hash FROM integrity_issues WHERE content_hash = $1 AND issue = $2`,
+		tampered.ContentHash, IntegrityIssueHashMismatch).Scan(&actualHash); err != nil {
+		t.Fatalf("Expected the mismatch to be recorded: %v", err)
+	}
+	if actualHash == nil || *actualHash == tampered.ContentHash {
+		t.Errorf("Expected the recorded hash of the tampered object, got %v", actualHash)
+	}
+
+	// Once the object is restored, the next run clears the recorded issue
+	if err := os.WriteFile(tamperedPath, contents[tampered.ID], 0644); err != nil {
+		t.Fatalf("Failed to restore the stored object: %v", err)
+	}
+	if _, err := integrity.Verify(ctx, IntegrityOptions{UserID: &userID, Record: true}); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	var remaining int
+	db.QueryRow(ctx, "SELECT COUNT(*) FROM integrity_issues WHERE content_hash = $1", tampered.ContentHash).Scan(&remaining)
+	if remaining != 0 {
+		t.Error("Expected the resolved issue to be cleared")
+	}
+
+	if _, err := integrity.Verify(ctx, IntegrityOptions{SamplePercent: 150}); err == nil {
+		t.Error("Expected a sample over 100 percent to be rejected")
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/h2non/filetype"
+)
+
+// Enterprise settings keys restricting which MIME types members may upload. Each holds an array
+// of types; an entry may end in "/*" to match a whole family, e.g. "image/*".
+const (
+	AllowedMimeTypesSettingKey = "allowed_mime_types"
+	BlockedMimeTypesSettingKey = "blocked_mime_types"
+)
+
+// mimeAliases maps alternative names of a type to the one the detector reports
+var mimeAliases = map[string]string{
+	"application/x-msdownload":     "application/vnd.microsoft.portable-executable",
+	"application/x-msdos-program":  "application/vnd.microsoft.portable-executable",
+	"application/x-dosexec":        "application/vnd.microsoft.portable-executable",
+	"application/exe":              "application/vnd.microsoft.portable-executable",
+	"image/jpg":                    "image/jpeg",
+	"image/pjpeg":                  "image/jpeg",
+	"application/x-pdf":            "application/pdf",
+	"application/x-zip-compressed": "application/zip",
+}
+
+// canonicalMimeType lowercases a MIME type, drops its parameters and resolves aliases
+func canonicalMimeType(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	if canonical, ok := mimeAliases[mediaType]; ok {
+		return canonical
+	}
+	return mediaType
+}
+
+// DetectMimeType returns the type the content's magic bytes identify, ignoring the declared type
+// when they disagree. Formats without a signature (text, CSV, JSON) keep the declared type.
+func DetectMimeType(content []byte, declared string) string {
+	if detected, err := filetype.Match(content); err == nil && detected != filetype.Unknown {
+		return detected.MIME.Value
+	}
+	if declared == "" {
+		return "application/octet-stream"
+	}
+	return declared
+}
+
+// MimePolicy is an enterprise's upload restriction. A type must match Allowed when Allowed is
+// non-empty and must not match Blocked. The zero value allows everything.
+type MimePolicy struct {
+	Allowed []string
+	Blocked []string
+}
+
+// parseMimePolicy reads the allow and deny lists from their raw JSON settings values; a missing or
+// malformed list doesn't restrict anything
+func parseMimePolicy(allowed, blocked []byte) MimePolicy {
+	var policy MimePolicy
+	if len(allowed) > 0 {
+		json.Unmarshal(allowed, &policy.Allowed)
+	}
+	if len(blocked) > 0 {
+		json.Unmarshal(blocked, &policy.Blocked)
+	}
+	return policy
+}
+
+// Allows reports whether the policy permits uploading content of mimeType
+func (p MimePolicy) Allows(mimeType string) bool {
+	mimeType = canonicalMimeType(mimeType)
+	if len(p.Allowed) > 0 && !mimeTypeListed(p.Allowed, mimeType) {
+		return false
+	}
+	return !mimeTypeListed(p.Blocked, mimeType)
+}
+
+// mimeTypeListed reports whether a canonical type matches any entry, honouring "family/*" entries
+func mimeTypeListed(entries []string, mimeType string) bool {
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if family, ok := strings.CutSuffix(entry, "/*"); ok {
+			if strings.HasPrefix(mimeType, family+"/") {
+				return true
+			}
+			continue
+		}
+		if canonicalMimeType(entry) == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// UnsupportedMediaTypeError rejects an upload whose detected type the user's enterprise doesn't allow
+type UnsupportedMediaTypeError struct {
+	MimeType string
+}
+
+func (e *UnsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("file type %s is not allowed", e.MimeType)
+}
+
+// UploadBlocklist is the server-wide upload restriction, applied to everyone before any enterprise
+// policy. A file is refused when its name ends in one of Extensions (".exe") or its type matches
+// one of MimeTypes, which may end in "/*". The zero value blocks nothing.
+type UploadBlocklist struct {
+	Extensions []string
+	MimeTypes  []string
+}
+
+// Check returns a BlockedUploadError when the blocklist refuses a file named filename of mimeType.
+// Trailing dots and spaces, which Windows drops when saving, don't hide an extension.
+func (b UploadBlocklist) Check(filename, mimeType string) error {
+	extension := strings.ToLower(path.Ext(strings.TrimRight(filename, ". ")))
+	if extension != "" {
+		for _, blocked := range b.Extensions {
+			blocked = strings.ToLower(strings.TrimSpace(blocked))
+			if "."+strings.TrimPrefix(blocked, ".") == extension {
+				return &BlockedUploadError{Extension: extension}
+			}
+		}
+	}
+	if mimeType = canonicalMimeType(mimeType); mimeTypeListed(b.MimeTypes, mimeType) {
+		return &BlockedUploadError{MimeType: mimeType}
+	}
+	return nil
+}
+
+// BlockedUploadError rejects an upload the server-wide blocklist refuses, by its name's extension
+// or by its type
+type BlockedUploadError struct {
+	Extension string
+	MimeType  string
+}
+
+func (e *BlockedUploadError) Error() string {
+	if e.Extension != "" {
+		return fmt.Sprintf("%s files are not allowed", e.Extension)
+	}
+	return fmt.Sprintf("file type %s is blocked", e.MimeType)
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+)
+
+type NotificationService struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+	events *EventBroker
+}
+
+func NewNotificationService(db *pgxpool.Pool, logger *zap.Logger) *NotificationService {
+	return &NotificationService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetEventBroker publishes every notification created from now on to events, for real-time
+// subscriptions
+func (s *NotificationService) SetEventBroker(events *EventBroker) {
+	s.events = events
+}
+
+// NotifyFileShared tells recipientID that sharerID shared a file with them. fileID is the
+// recipient's copy. Failures are logged rather than returned so they never fail the share.
+func (s *NotificationService) NotifyFileShared(ctx context.Context, recipientID, sharerID, fileID uuid.UUID, fileName string) {
+	if s == nil {
+		return
+	}
+
+	var sharerName string
+	if err := s.db.QueryRow(ctx, "SELECT name FROM users WHERE id = $1", sharerID).Scan(&sharerName); err != nil {
+		sharerName = "Someone"
+	}
+
+	notification := &domain.Notification{
+		UserID:       recipientID,
+		ActorID:      &sharerID,
+		Type:         domain.NotificationFileShared,
+		ResourceType: "file",
+		ResourceID:   &fileID,
+		Message:      fmt.Sprintf("%s shared %s with you", sharerName, fileName),
+	}
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO notifications (user_id, actor_id, type, resource_type, resource_id, message)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		notification.UserID, notification.ActorID, notification.Type, notification.ResourceType,
+		notification.ResourceID, notification.Message).Scan(&notification.ID, &notification.CreatedAt)
+	if err != nil {
+		RequestLogger(ctx, s.logger).Error("Failed to create share notification",
+			zap.String("recipient_id", recipientID.String()),
+			zap.String("file_id", fileID.String()),
+			zap.Error(err))
+		return
+	}
+
+	notification.Actor = &domain.User{ID: sharerID, Name: sharerName}
+	s.events.Publish(recipientID, Event{Notification: notification})
+}
+
+// GetNotifications returns the user's notifications, newest first
+func (s *NotificationService) GetNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit, offset int) ([]*domain.Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, n.actor_id, n.type, n.resource_type, n.resource_id, n.message,
+		       n.read_at, n.created_at, u.name, u.email
+		FROM notifications n
+		LEFT JOIN users u ON n.actor_id = u.id
+		WHERE n.user_id = $1`
+	if unreadOnly {
+		query += " AND n.read_at IS NULL"
+	}
+	query += " ORDER BY n.created_at DESC LIMIT $2 OFFSET $3"
+
+	rows, err := s.db.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []*domain.Notification{}
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, rows.Err()
+}
+
+// CountUnread returns how many of the user's notifications have not been read
+func (s *NotificationService) CountUnread(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL", userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRead marks one of the user's notifications as read. Notifications belonging to other
+// users are reported as not found.
+func (s *NotificationService) MarkRead(ctx context.Context, notificationID, userID uuid.UUID) (*domain.Notification, error) {
+	notification, err := scanNotification(s.db.QueryRow(ctx, `
+		WITH updated AS (
+			UPDATE notifications
+			SET read_at = COALESCE(read_at, NOW())
+			WHERE id = $1 AND user_id = $2
+			RETURNING *
+		)
+		SELECT n.id, n.user_id, n.actor_id, n.type, n.resource_type, n.resource_id, n.message,
+		       n.read_at, n.created_at, u.name, u.email
+		FROM updated n
+		LEFT JOIN users u ON n.actor_id = u.id`,
+		notificationID, userID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("notification not found")
+		}
+		return nil, fmt.Errorf("failed to mark notification read: %w", err)
+	}
+
+	return notification, nil
+}
+
+func scanNotification(row pgx.Row) (*domain.Notification, error) {
+	notification := &domain.Notification{}
+	var actorName, actorEmail *string
+	err := row.Scan(
+		&notification.ID, &notification.UserID, &notification.ActorID, &notification.Type,
+		&notification.ResourceType, &notification.ResourceID, &notification.Message,
+		&notification.ReadAt, &notification.CreatedAt, &actorName, &actorEmail,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if notification.ActorID != nil && actorName != nil && actorEmail != nil {
+		notification.Actor = &domain.User{
+			ID:    *notification.ActorID,
+			Name:  *actorName,
+			Email: *actorEmail,
+		}
+	}
+
+	return notification, nil
+}
@@ -0,0 +1,77 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/testutil"
+)
+
+func TestNotificationService_ShareNotifiesRecipient(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	notificationService := NewNotificationService(db, zap.NewNop())
+	sharingService := NewFileSharingService(db, nil, notificationService, zap.NewNop())
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	sharer := testutil.CreateUser(t, db, "sharer", &enterpriseID)
+	recipient := testutil.CreateUser(t, db, "recipient", &enterpriseID)
+	fileID := testutil.CreateFile(t, db, sharer, "plan.pdf", []byte("notify "+uuid.NewString()))
+
+	share, err := sharingService.ShareWithUser(ctx, domain.ShareFileInput{
+		FileID:           fileID,
+		SharedWithUserID: recipient,
+		PermissionType:   domain.PermissionView,
+	}, sharer)
+	if err != nil {
+		t.Fatalf("ShareWithUser failed: %v", err)
+	}
+
+	notifications, err := notificationService.GetNotifications(ctx, recipient, true, 20, 0)
+	if err != nil {
+		t.Fatalf("GetNotifications failed: %v", err)
+	}
+	if len(notifications) != 1 {
+		t.Fatalf("Expected exactly 1 unread notification for the recipient, got %d", len(notifications))
+	}
+	notification := notifications[0]
+	if notification.Type != domain.NotificationFileShared {
+		t.Errorf("Expected type %s, got %s", domain.NotificationFileShared, notification.Type)
+	}
+	if notification.ResourceID == nil || *notification.ResourceID != fileID || share.FileID != fileID {
+		t.Errorf("Expected the share and notification to point at the shared file %s, got %s and %v", fileID, share.FileID, notification.ResourceID)
+	}
+	if notification.Actor == nil || notification.Actor.ID != sharer {
+		t.Errorf("Expected the sharer as actor, got %+v", notification.Actor)
+	}
+
+	sharerNotifications, err := notificationService.GetNotifications(ctx, sharer, false, 20, 0)
+	if err != nil {
+		t.Fatalf("GetNotifications failed: %v", err)
+	}
+	if len(sharerNotifications) != 0 {
+		t.Errorf("Expected no notifications for the sharer, got %d", len(sharerNotifications))
+	}
+
+	// Only the recipient can mark it read
+	if _, err := notificationService.MarkRead(ctx, notification.ID, sharer); err == nil {
+		t.Error("Expected marking another user's notification read to fail")
+	}
+
+	read, err := notificationService.MarkRead(ctx, notification.ID, recipient)
+	if err != nil {
+		t.Fatalf("MarkRead failed: %v", err)
+	}
+	if read.ReadAt == nil {
+		t.Error("Expected readAt to be set")
+	}
+	if unread, _ := notificationService.CountUnread(ctx, recipient); unread != 0 {
+		t.Errorf("Expected no unread notifications after marking read, got %d", unread)
+	}
+}
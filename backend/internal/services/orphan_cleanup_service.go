@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/storage"
+)
+
+// defaultOrphanMinAge keeps in-flight uploads safe on paths that insert the content row before
+// the files row that references it
+const defaultOrphanMinAge = time.Hour
+
+// orphanCondition matches file_contents rows (aliased fc) that nothing references any more
+const orphanCondition = `(fc.reference_count <= 0 OR NOT EXISTS (SELECT 1 FROM files f WHERE f.content_hash = fc.content_hash))`
+
+// OrphanCleanupService removes stored content that no file references any more
+type OrphanCleanupService struct {
+	db      *pgxpool.Pool
+	storage storage.StorageService
+	logger  *zap.Logger
+	minAge  time.Duration
+}
+
+func NewOrphanCleanupService(db *pgxpool.Pool, store storage.StorageService, logger *zap.Logger) *OrphanCleanupService {
+	return &OrphanCleanupService{
+		db:      db,
+		storage: store,
+		logger:  logger,
+		minAge:  defaultOrphanMinAge,
+	}
+}
+
+type orphanedContent struct {
+	contentHash  string
+	filePath     string
+	fileSize     int64
+	enterpriseID *uuid.UUID
+}
+
+// CleanupOrphans deletes the content row and then the storage object for every orphaned content
+// record. Returns the number of rows removed.
+func (s *OrphanCleanupService) CleanupOrphans(ctx context.Context) (int, error) {
+	orphans, err := s.findOrphans(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, orphan := range orphans {
+		ok, err := s.removeOrphan(ctx, orphan)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// findOrphans lists the content records nothing has referenced for at least minAge
+func (s *OrphanCleanupService) findOrphans(ctx context.Context) ([]orphanedContent, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT fc.content_hash, fc.file_path, fc.file_size, fc.enterprise_id
+		FROM file_contents fc
+		WHERE `+orphanCondition+` AND fc.created_at < $1`,
+		time.Now().Add(-s.minAge))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orphaned content: %w", err)
+	}
+	defer rows.Close()
+
+	var orphans []orphanedContent
+	for rows.Next() {
+		var orphan orphanedContent
+		if err := rows.Scan(&orphan.contentHash, &orphan.filePath, &orphan.fileSize, &orphan.enterpriseID); err != nil {
+			return nil, fmt.Errorf("failed to scan orphaned content: %w", err)
+		}
+		orphans = append(orphans, orphan)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read orphaned content: %w", err)
+	}
+	return orphans, nil
+}
+
+// removeOrphan deletes an orphaned content record found earlier, unless it has been referenced
+// again since, and then its object. The row is locked before the condition is checked again, so
+// an upload of the same bytes either revives it first and keeps it, or waits and stores the bytes
+// afresh. The object is queued for deletion with the row's removal and only deleted after commit,
+// by which time a failure just leaves it queued. Reports whether the record was removed.
+func (s *OrphanCleanupService) removeOrphan(ctx context.Context, orphan orphanedContent) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	err = tx.QueryRow(ctx, `
+		SELECT TRUE FROM file_contents WHERE content_hash = $1 FOR UPDATE`, orphan.contentHash).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock orphaned content record: %w", err)
+	}
+
+	// A statement of its own sees uploads that committed while the lock was awaited
+	var filePath string
+	var fileSize int64
+	var enterpriseID *uuid.UUID
+	err = tx.QueryRow(ctx, `
+		DELETE FROM file_contents fc
+		WHERE fc.content_hash = $1 AND `+orphanCondition+`
+		RETURNING fc.file_path, fc.file_size, fc.enterprise_id`,
+		orphan.contentHash).Scan(&filePath, &fileSize, &enterpriseID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to delete orphaned content record: %w", err)
+	}
+
+	if err := releaseEnterpriseStorage(ctx, tx, enterpriseID, fileSize); err != nil {
+		return false, err
+	}
+	if err := queueStorageDeletion(ctx, tx, filePath); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit orphaned content removal: %w", err)
+	}
+
+	if _, err := deleteQueuedObject(ctx, s.db, s.storage, s.logger, filePath); err != nil {
+		s.logger.Warn("Failed to delete orphaned content from storage",
+			zap.String("content_hash", orphan.contentHash),
+			zap.Error(err))
+	}
+	// Cached PDF and preview renderings go with their content; they may well not exist
+	for _, rendering := range []string{storage.ConvertedPDFPath(filePath), s.storage.Key(storage.PreviewImagePath(orphan.contentHash))} {
+		if err := s.storage.Delete(ctx, rendering); err != nil {
+			s.logger.Warn("Failed to delete converted content from storage",
+				zap.String("content_hash", orphan.contentHash),
+				zap.Error(err))
+		}
+	}
+	return true, nil
+}
+
+// queueStorageDeletion records that the object at path is to be deleted, in the transaction
+// removing the record that pointed at it when there is one, so the object is never lost track of
+func queueStorageDeletion(ctx context.Context, db contentQuerier, path string) error {
+	if _, err := db.Exec(ctx, `
+		INSERT INTO storage_deletions (file_path, attempts)
+		VALUES ($1, 0)
+		ON CONFLICT (file_path) DO NOTHING`, path); err != nil {
+		return fmt.Errorf("failed to queue storage deletion: %w", err)
+	}
+	return nil
+}
+
+// deleteQueuedObject deletes a queued object unless live content has been stored at its path
+// again, and takes it off the queue. A failed deletion stays queued with its error for
+// RetryStorageDeletions. Reports whether the object was deleted.
+func deleteQueuedObject(ctx context.Context, db *pgxpool.Pool, store storage.StorageService, logger *zap.Logger, path string) (bool, error) {
+	var inUse bool
+	if err := db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM file_contents WHERE file_path = $1)`, path).Scan(&inUse); err != nil {
+		return false, fmt.Errorf("failed to check queued storage deletion: %w", err)
+	}
+
+	if !inUse {
+		if err := store.Delete(ctx, path); err != nil {
+			logger.Error("Failed to delete object from storage, queued for retry",
+				zap.String("file_path", path),
+				zap.Error(err))
+			db.Exec(ctx, `
+				UPDATE storage_deletions
+				SET attempts = attempts + 1, last_error = $2, updated_at = NOW()
+				WHERE file_path = $1`, path, err.Error())
+			return false, nil
+		}
+	}
+
+	if _, err := db.Exec(ctx, "DELETE FROM storage_deletions WHERE file_path = $1", path); err != nil {
+		return false, fmt.Errorf("failed to dequeue storage deletion: %w", err)
+	}
+	return !inUse, nil
+}
+
+// RetryStorageDeletions retries the object deletions queued in storage_deletions. Paths that live
+// content has been stored at again since are dropped from the queue without deleting anything.
+// Returns the number of objects removed.
+func (s *OrphanCleanupService) RetryStorageDeletions(ctx context.Context) (int, error) {
+	rows, err := s.db.Query(ctx, `SELECT file_path FROM storage_deletions ORDER BY created_at`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load queued storage deletions: %w", err)
+	}
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan queued storage deletion: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read queued storage deletions: %w", err)
+	}
+
+	removed := 0
+	for _, path := range paths {
+		deleted, err := deleteQueuedObject(ctx, s.db, s.storage, s.logger, path)
+		if err != nil {
+			return removed, err
+		}
+		if deleted {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// RunOrphanCleanup cleans up orphaned content and retries queued storage deletions immediately
+// and then on every interval until ctx is cancelled
+func (s *OrphanCleanupService) RunOrphanCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := s.CleanupOrphans(ctx)
+		if err != nil {
+			s.logger.Error("Failed to clean up orphaned content", zap.Error(err))
+		} else if removed > 0 {
+			s.logger.Info("Removed orphaned content", zap.Int("removed", removed))
+		}
+
+		retried, err := s.RetryStorageDeletions(ctx)
+		if err != nil {
+			s.logger.Error("Failed to retry storage deletions", zap.Error(err))
+		} else if retried > 0 {
+			s.logger.Info("Removed objects queued for deletion", zap.Int("removed", retried))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,144 @@
+//go:build integration
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/storage"
+	"lokr-backend/internal/testutil"
+)
+
+func TestOrphanCleanupService_CleanupOrphans(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	store, storageDir := testutil.NewLocalStorage(t)
+	service := NewOrphanCleanupService(db, store, zap.NewNop())
+	service.minAge = 0
+
+	userID := testutil.CreateUser(t, db, "orphans", nil)
+
+	// Referenced content: a file row points at it
+	kept := []byte("kept " + uuid.NewString())
+	keptHash := fmt.Sprintf("%x", sha256.Sum256(kept))
+	testutil.CreateFile(t, db, userID, "kept.txt", kept)
+	keptPath := fmt.Sprintf("personal/users/%s/%s", userID, keptHash)
+	if err := store.Store(context.Background(), keptPath, bytes.NewReader(kept), "text/plain"); err != nil {
+		t.Fatalf("Failed to store referenced content: %v", err)
+	}
+
+	// Orphaned content: stored, but no file row and no references
+	orphan := []byte("orphan " + uuid.NewString())
+	orphanHash := fmt.Sprintf("%x", sha256.Sum256(orphan))
+	cleanupContent(t, db, orphan)
+	orphanPath := storage.ContentPath("", userID.String(), orphanHash)
+	if err := store.Store(context.Background(), orphanPath, bytes.NewReader(orphan), "text/plain"); err != nil {
+		t.Fatalf("Failed to store orphaned content: %v", err)
+	}
+	if _, err := db.Exec(context.Background(), `
+		INSERT INTO file_contents (content_hash, file_path, file_size, reference_count, created_at)
+		VALUES ($1, $2, $3, 0, NOW())`,
+		orphanHash, orphanPath, len(orphan)); err != nil {
+		t.Fatalf("Failed to create orphaned content: %v", err)
+	}
+
+	removed, err := service.CleanupOrphans(context.Background())
+	if err != nil {
+		t.Fatalf("CleanupOrphans failed: %v", err)
+	}
+	if removed < 1 {
+		t.Errorf("Expected at least 1 orphan removed, got %d", removed)
+	}
+
+	var count int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM file_contents WHERE content_hash = $1", orphanHash).Scan(&count)
+	if count != 0 {
+		t.Error("Expected the orphaned content row to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(storageDir, orphanPath)); !os.IsNotExist(err) {
+		t.Errorf("Expected the orphaned object to be deleted, got: %v", err)
+	}
+
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM file_contents WHERE content_hash = $1", keptHash).Scan(&count)
+	if count != 1 {
+		t.Error("Expected the referenced content row to be kept")
+	}
+	if _, err := os.Stat(filepath.Join(storageDir, keptPath)); err != nil {
+		t.Errorf("Expected the referenced object to be kept, got: %v", err)
+	}
+
+	// Running again is a no-op for the already-removed orphan
+	if _, err := service.CleanupOrphans(context.Background()); err != nil {
+		t.Fatalf("Second CleanupOrphans failed: %v", err)
+	}
+}
+
+func TestOrphanCleanupService_CleanupOrphans_ReReferenced(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	store, storageDir := testutil.NewLocalStorage(t)
+	service := NewOrphanCleanupService(db, store, zap.NewNop())
+	service.minAge = 0
+
+	userID := testutil.CreateUser(t, db, "orphans-revived", nil)
+
+	content := []byte("revived " + uuid.NewString())
+	contentHash := fmt.Sprintf("%x", sha256.Sum256(content))
+	cleanupContent(t, db, content)
+	contentPath := storage.ContentPath("", userID.String(), contentHash)
+	if err := store.Store(context.Background(), contentPath, bytes.NewReader(content), "text/plain"); err != nil {
+		t.Fatalf("Failed to store orphaned content: %v", err)
+	}
+	if _, err := db.Exec(context.Background(), `
+		INSERT INTO file_contents (content_hash, file_path, file_size, reference_count, created_at)
+		VALUES ($1, $2, $3, 0, NOW())`,
+		contentHash, contentPath, len(content)); err != nil {
+		t.Fatalf("Failed to create orphaned content: %v", err)
+	}
+
+	orphans, err := service.findOrphans(context.Background())
+	if err != nil {
+		t.Fatalf("findOrphans failed: %v", err)
+	}
+	var orphan *orphanedContent
+	for i := range orphans {
+		if orphans[i].contentHash == contentHash {
+			orphan = &orphans[i]
+		}
+	}
+	if orphan == nil {
+		t.Fatal("Expected the unreferenced content to be found as an orphan")
+	}
+
+	// An upload of the same bytes lands between the scan and the removal, bumping the reference
+	// count and adding a file row
+	testutil.CreateFile(t, db, userID, "revived.txt", content)
+
+	removed, err := service.removeOrphan(context.Background(), *orphan)
+	if err != nil {
+		t.Fatalf("removeOrphan failed: %v", err)
+	}
+	if removed {
+		t.Error("Expected re-referenced content not to be removed")
+	}
+
+	var count int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM file_contents WHERE content_hash = $1", contentHash).Scan(&count)
+	if count != 1 {
+		t.Error("Expected the re-referenced content row to be kept")
+	}
+	if _, err := os.Stat(filepath.Join(storageDir, contentPath)); err != nil {
+		t.Errorf("Expected the re-referenced object to be kept, got: %v", err)
+	}
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM storage_deletions WHERE file_path = $1", contentPath).Scan(&count)
+	if count != 0 {
+		t.Error("Expected no deletion to be queued for re-referenced content")
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/scanner"
+	"lokr-backend/internal/storage"
+)
+
+// maxScanDuration bounds a scan that outlives the request that started it
+const maxScanDuration = 5 * time.Minute
+
+// rescanBatchSize limits how many pending files one rescan pass reads back from storage
+const rescanBatchSize = 100
+
+type ScanService struct {
+	db           *pgxpool.Pool
+	storage      storage.StorageService
+	scanner      scanner.Scanner
+	auditService *AuditService
+	logger       *zap.Logger
+	waitTimeout  time.Duration
+}
+
+// NewScanService creates a scan service; waitTimeout is how long an upload waits for a verdict
+func NewScanService(db *pgxpool.Pool, store storage.StorageService, fileScanner scanner.Scanner, auditService *AuditService, logger *zap.Logger, waitTimeout time.Duration) *ScanService {
+	return &ScanService{
+		db:           db,
+		storage:      store,
+		scanner:      fileScanner,
+		auditService: auditService,
+		logger:       logger,
+		waitTimeout:  waitTimeout,
+	}
+}
+
+// Enabled reports whether uploads must be scanned before they become downloadable
+func (s *ScanService) Enabled() bool {
+	if s == nil || s.scanner == nil {
+		return false
+	}
+	_, noop := s.scanner.(scanner.NoopScanner)
+	return !noop
+}
+
+// ScanUpload scans a freshly uploaded file that was stored as PENDING_SCAN. It waits at most
+// waitTimeout for a verdict; slower scans finish in the background and update the file then.
+func (s *ScanService) ScanUpload(ctx context.Context, file *domain.File, content []byte) domain.FileStatus {
+	result := make(chan domain.FileStatus, 1)
+
+	go func() {
+		// Detached from the request so a slow scan still completes after the response is sent
+		scanCtx, cancel := context.WithTimeout(context.Background(), maxScanDuration)
+		defer cancel()
+
+		clean, reason, err := s.scanner.Scan(scanCtx, bytes.NewReader(content))
+		result <- s.applyVerdict(scanCtx, file, clean, reason, err)
+	}()
+
+	select {
+	case status := <-result:
+		return status
+	case <-time.After(s.waitTimeout):
+		RequestLogger(ctx, s.logger).Info("Virus scan still running, continuing in background", zap.String("file_id", file.ID.String()))
+		return domain.FileStatusPendingScan
+	case <-ctx.Done():
+		return domain.FileStatusPendingScan
+	}
+}
+
+// applyVerdict records the scan result on the file. Scanner errors leave the file pending so
+// nothing unscanned becomes downloadable; RescanPending tries it again later.
+func (s *ScanService) applyVerdict(ctx context.Context, file *domain.File, clean bool, reason string, scanErr error) domain.FileStatus {
+	if scanErr != nil {
+		RequestLogger(ctx, s.logger).Error("Virus scan failed", zap.String("file_id", file.ID.String()), zap.Error(scanErr))
+		return domain.FileStatusPendingScan
+	}
+
+	if clean {
+		if err := s.setStatus(ctx, file.ID, domain.FileStatusActive); err != nil {
+			RequestLogger(ctx, s.logger).Error("Failed to activate scanned file", zap.String("file_id", file.ID.String()), zap.Error(err))
+			return domain.FileStatusPendingScan
+		}
+		return domain.FileStatusActive
+	}
+
+	// Deduplicated copies share the same content, so quarantine every file pointing at it
+	_, err := s.db.Exec(ctx, `
+		UPDATE files
+		SET status = $1, updated_at = NOW()
+		WHERE content_hash = $2`,
+		domain.FileStatusInfected, file.ContentHash)
+	if err != nil {
+		RequestLogger(ctx, s.logger).Error("Failed to quarantine infected file", zap.String("file_id", file.ID.String()), zap.Error(err))
+	}
+
+	RequestLogger(ctx, s.logger).Warn("Infected file quarantined",
+		zap.String("file_id", file.ID.String()),
+		zap.String("user_id", file.UserID.String()),
+		zap.String("reason", reason),
+	)
+	s.auditService.LogFileQuarantine(ctx, file.UserID, file.ID, file.OriginalName, reason)
+
+	return domain.FileStatusInfected
+}
+
+// RescanPending scans again the files still pending a verdict after any scan of them must have
+// finished, because the scanner failed or the server stopped mid-scan. Files it still cannot scan
+// stay pending for the next pass. Returns the number of files that got a verdict.
+func (s *ScanService) RescanPending(ctx context.Context) (int, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT f.id, f.user_id, f.original_name, f.content_hash, fc.file_path
+		FROM files f
+		JOIN file_contents fc ON fc.content_hash = f.content_hash
+		WHERE f.status = $1 AND f.updated_at < $2
+		ORDER BY f.updated_at
+		LIMIT $3`,
+		domain.FileStatusPendingScan, time.Now().Add(-maxScanDuration), rescanBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find files pending scan: %w", err)
+	}
+
+	type pendingFile struct {
+		file     *domain.File
+		filePath string
+	}
+	var pending []pendingFile
+	for rows.Next() {
+		file := &domain.File{}
+		var filePath string
+		if err := rows.Scan(&file.ID, &file.UserID, &file.OriginalName, &file.ContentHash, &filePath); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan file pending scan: %w", err)
+		}
+		pending = append(pending, pendingFile{file: file, filePath: filePath})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read files pending scan: %w", err)
+	}
+
+	scanned := 0
+	for _, p := range pending {
+		content, err := storage.ReadAll(ctx, s.storage, p.filePath)
+		if err != nil {
+			s.logger.Error("Failed to read file pending scan", zap.String("file_id", p.file.ID.String()), zap.Error(err))
+			continue
+		}
+
+		scanCtx, cancel := context.WithTimeout(ctx, maxScanDuration)
+		clean, reason, scanErr := s.scanner.Scan(scanCtx, bytes.NewReader(content))
+		status := s.applyVerdict(scanCtx, p.file, clean, reason, scanErr)
+		cancel()
+		if status != domain.FileStatusPendingScan {
+			scanned++
+		}
+	}
+	return scanned, nil
+}
+
+// RunPendingRescan rescans stuck files immediately and then on every interval until ctx is cancelled
+func (s *ScanService) RunPendingRescan(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		scanned, err := s.RescanPending(ctx)
+		if err != nil {
+			s.logger.Error("Failed to rescan files pending scan", zap.Error(err))
+		} else if scanned > 0 {
+			s.logger.Info("Rescanned files pending scan", zap.Int("scanned", scanned))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ScanService) setStatus(ctx context.Context, fileID uuid.UUID, status domain.FileStatus) error {
+	_, err := s.db.Exec(ctx, "UPDATE files SET status = $1, updated_at = NOW() WHERE id = $2", status, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to update file status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+//go:build integration
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/testutil"
+)
+
+// mockScanner returns a fixed verdict
+type mockScanner struct {
+	clean  bool
+	reason string
+}
+
+func (m mockScanner) Scan(ctx context.Context, content io.Reader) (bool, string, error) {
+	io.Copy(io.Discard, content)
+	return m.clean, m.reason, nil
+}
+
+// failingScanner cannot reach the scanning daemon
+type failingScanner struct{}
+
+func (failingScanner) Scan(ctx context.Context, content io.Reader) (bool, string, error) {
+	return false, "", errors.New("clamd unreachable")
+}
+
+func TestScanService_ScanUpload(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	auditService := NewAuditService(db, zap.NewNop())
+	userID := testutil.CreateUser(t, db, "scanner", nil)
+
+	tests := []struct {
+		name       string
+		scanner    mockScanner
+		wantStatus domain.FileStatus
+		wantAudit  int
+	}{
+		{"clean", mockScanner{clean: true}, domain.FileStatusActive, 0},
+		{"infected", mockScanner{clean: false, reason: "Eicar-Test-Signature"}, domain.FileStatusInfected, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte("scan " + tt.name + " " + uuid.NewString())
+			fileID := testutil.CreateFile(t, db, userID, tt.name+".txt", content)
+			db.Exec(context.Background(), "UPDATE files SET status = 'PENDING_SCAN' WHERE id = $1", fileID)
+
+			file := &domain.File{ID: fileID, UserID: userID, OriginalName: tt.name + ".txt"}
+			db.QueryRow(context.Background(), "SELECT content_hash FROM files WHERE id = $1", fileID).Scan(&file.ContentHash)
+
+			service := NewScanService(db, testutil.NewMemoryStorage(), tt.scanner, auditService, zap.NewNop(), 5*time.Second)
+			if status := service.ScanUpload(context.Background(), file, content); status != tt.wantStatus {
+				t.Errorf("Expected ScanUpload to return %s, got %s", tt.wantStatus, status)
+			}
+
+			var status string
+			db.QueryRow(context.Background(), "SELECT status FROM files WHERE id = $1", fileID).Scan(&status)
+			if status != string(tt.wantStatus) {
+				t.Errorf("Expected stored status to be %s, got %s", tt.wantStatus, status)
+			}
+
+			var auditCount int
+			db.QueryRow(context.Background(), `
+				SELECT COUNT(*) FROM audit_logs WHERE resource_id = $1 AND action = $2`,
+				fileID, domain.ActionFileQuarantine).Scan(&auditCount)
+			if auditCount != tt.wantAudit {
+				t.Errorf("Expected %d quarantine audit entries, got %d", tt.wantAudit, auditCount)
+			}
+		})
+	}
+}
+
+func TestScanService_RescanPending(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	store := testutil.NewMemoryStorage()
+	auditService := NewAuditService(db, zap.NewNop())
+	userID := testutil.CreateUser(t, db, "rescan", nil)
+	ctx := context.Background()
+
+	pendingFile := func(name string) (*domain.File, []byte) {
+		content := []byte("rescan " + name + " " + uuid.NewString())
+		fileID := testutil.CreateFile(t, db, userID, name, content)
+		file := &domain.File{ID: fileID, UserID: userID, OriginalName: name}
+		var filePath string
+		db.QueryRow(ctx, `
+			SELECT f.content_hash, fc.file_path FROM files f JOIN file_contents fc ON fc.content_hash = f.content_hash
+			WHERE f.id = $1`, fileID).Scan(&file.ContentHash, &filePath)
+		if err := store.Store(ctx, filePath, bytes.NewReader(content), "text/plain"); err != nil {
+			t.Fatalf("Failed to store content: %v", err)
+		}
+		db.Exec(ctx, "UPDATE files SET status = 'PENDING_SCAN' WHERE id = $1", fileID)
+		return file, content
+	}
+	status := func(fileID uuid.UUID) domain.FileStatus {
+		var status domain.FileStatus
+		db.QueryRow(ctx, "SELECT status FROM files WHERE id = $1", fileID).Scan(&status)
+		return status
+	}
+
+	// The scanner is down when the upload comes in
+	stuck, content := pendingFile("stuck.txt")
+	down := NewScanService(db, store, failingScanner{}, auditService, zap.NewNop(), 5*time.Second)
+	if got := down.ScanUpload(ctx, stuck, content); got != domain.FileStatusPendingScan {
+		t.Fatalf("Expected a failed scan to leave the file pending, got %s", got)
+	}
+	db.Exec(ctx, "UPDATE files SET updated_at = $1 WHERE id = $2", time.Now().Add(-2*maxScanDuration), stuck.ID)
+
+	// A file whose scan may still be running is left to it
+	recent, _ := pendingFile("recent.txt")
+
+	if _, err := down.RescanPending(ctx); err != nil {
+		t.Fatalf("RescanPending failed: %v", err)
+	}
+	if got := status(stuck.ID); got != domain.FileStatusPendingScan {
+		t.Errorf("Expected the file to stay pending while the scanner is down, got %s", got)
+	}
+
+	up := NewScanService(db, store, mockScanner{clean: true}, auditService, zap.NewNop(), 5*time.Second)
+	scanned, err := up.RescanPending(ctx)
+	if err != nil {
+		t.Fatalf("RescanPending failed: %v", err)
+	}
+	if scanned < 1 {
+		t.Errorf("Expected at least 1 file rescanned, got %d", scanned)
+	}
+	if got := status(stuck.ID); got != domain.FileStatusActive {
+		t.Errorf("Expected the stuck file to be activated once the scanner is back, got %s", got)
+	}
+	if got := status(recent.ID); got != domain.FileStatusPendingScan {
+		t.Errorf("Expected the recent file to be left pending, got %s", got)
+	}
+}
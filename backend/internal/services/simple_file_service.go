@@ -1,72 +1,157 @@
 package services
 
 import (
+	"bytes"
 	"context"
-	"crypto/sha256"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/lib/pq"
+	"go.uber.org/zap"
 
 	"lokr-backend/internal/domain"
+	"lokr-backend/internal/storage"
+	"lokr-backend/pkg/hash"
 )
 
+// MaxUploadSizeSettingKey is the enterprise settings key overriding the default upload size limit in bytes
+const MaxUploadSizeSettingKey = "max_upload_size"
+
 type SimpleFileService struct {
-	db      *pgxpool.Pool
-	storage *S3StorageService
+	db           *pgxpool.Pool
+	storage      storage.StorageService
+	scanService  *ScanService
+	auditService *AuditService
+	hasher       hash.Hasher
+	blocklist    UploadBlocklist
+	shortCodes   bool
+	logger       *zap.Logger
 }
 
-func NewSimpleFileService(db *pgxpool.Pool, storage *S3StorageService) *SimpleFileService {
+// NewSimpleFileService creates the file service; scanService may be nil when scanning is disabled
+// and auditService may be nil to skip audit logging
+func NewSimpleFileService(db *pgxpool.Pool, store storage.StorageService, scanService *ScanService, auditService *AuditService, logger *zap.Logger) *SimpleFileService {
 	return &SimpleFileService{
-		db:      db,
-		storage: storage,
+		db:           db,
+		storage:      store,
+		scanService:  scanService,
+		auditService: auditService,
+		hasher:       hash.SHA256,
+		logger:       logger,
+	}
+}
+
+// SetHasher sets the algorithm new content is hashed with, SHA-256 by default. Content stored
+// under another algorithm stays readable but is never deduplicated against new uploads.
+func (s *SimpleFileService) SetHasher(hasher hash.Hasher) {
+	s.hasher = hasher
+}
+
+// SetShortShareCodes makes files made PUBLIC get a short share code as well as a token, like
+// public shares created with short links enabled (see ShareLinkConfig)
+func (s *SimpleFileService) SetShortShareCodes(enabled bool) {
+	s.shortCodes = enabled
+}
+
+// SetUploadBlocklist sets the extensions and types no one may upload; nothing is blocked by default
+func (s *SimpleFileService) SetUploadBlocklist(blocklist UploadBlocklist) {
+	s.blocklist = blocklist
+}
+
+// CheckUpload returns a BlockedUploadError when the blocklist refuses a file named filename of
+// mimeType, so handlers can turn an upload away before its content arrives
+func (s *SimpleFileService) CheckUpload(filename, mimeType string) error {
+	return s.blocklist.Check(filename, mimeType)
+}
+
+// MaxUploadSize returns the largest file in bytes the user may upload: their enterprise's
+// max_upload_size setting when it has one, otherwise defaultLimit
+func (s *SimpleFileService) MaxUploadSize(ctx context.Context, userID uuid.UUID, defaultLimit int64) (int64, error) {
+	var limit int64
+	err := s.db.QueryRow(ctx, `
+		SELECT (e.settings->>'`+MaxUploadSizeSettingKey+`')::bigint
+		FROM users u
+		JOIN enterprises e ON e.id = u.enterprise_id
+		WHERE u.id = $1 AND e.settings->>'`+MaxUploadSizeSettingKey+`' ~ '^[0-9]+$'`, userID).Scan(&limit)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return defaultLimit, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load upload size limit: %w", err)
 	}
+	return limit, nil
 }
 
 func (s *SimpleFileService) UploadFile(ctx context.Context, userID uuid.UUID, filename, mimeType string, content []byte, folderID *uuid.UUID, description *string, tags []string, visibility *domain.FileVisibility) (*domain.File, error) {
+	return s.uploadContent(ctx, userID, filename, mimeType, content, folderID, description, tags, visibility, nil)
+}
+
+// uploadContent stores content as a new file for the user. With encryption the content is
+// ciphertext: it is stored and deduplicated as it is, but can't be sniffed or scanned.
+func (s *SimpleFileService) uploadContent(ctx context.Context, userID uuid.UUID, filename, mimeType string, content []byte, folderID *uuid.UUID, description *string, tags []string, visibility *domain.FileVisibility, encryption *domain.FileEncryption) (*domain.File, error) {
 	// Calculate content hash for deduplication
-	hash := sha256.Sum256(content)
-	contentHash := fmt.Sprintf("%x", hash)
+	contentHash := hash.Sum(s.hasher, content)
 
-	// Get user info to determine enterprise slug (for now, assuming personal files)
-	// In a real implementation, you'd query the user's enterprise info
-	enterpriseSlug := "" // Personal files
+	enterpriseID, slug, policy, err := s.uploaderEnterprise(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Judge and record the type the content really is, not the one the client declared. Only the
+	// client knows what ciphertext decrypts to, so the declared type of encrypted files is kept.
+	if encryption == nil {
+		mimeType = DetectMimeType(content, mimeType)
+	}
+	if err := s.blocklist.Check(filename, mimeType); err != nil {
+		return nil, err
+	}
+	if !policy.Allows(mimeType) {
+		return nil, &UnsupportedMediaTypeError{MimeType: mimeType}
+	}
 
-	// Check if file content already exists (deduplication)
-	var existingRefCount int
-	var existingFilePath string
-	err := s.db.QueryRow(ctx, "SELECT reference_count, file_path FROM file_contents WHERE content_hash = $1", contentHash).Scan(&existingRefCount, &existingFilePath)
+	// The dedup decision, quota reservation and file record commit together. Concurrent uploads of
+	// the same content serialize on the content row, so the reference count is always exact.
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
+	// Insert the content record or take another reference to it; xmax is 0 only for a fresh insert.
+	// Content is only shared with a record hashed the same way, so a digest that happens to equal
+	// one made by another algorithm returns no row rather than someone else's bytes.
 	var filePath string
-	if err != nil && strings.Contains(err.Error(), "no rows") {
-		// Content doesn't exist, store it in S3/local storage
-		storedPath, err := s.storage.StoreFile(ctx, content, enterpriseSlug, userID.String(), contentHash, filename)
-		if err != nil {
-			return nil, fmt.Errorf("failed to store file: %w", err)
-		}
-		filePath = storedPath
+	var isNewContent bool
+	err = tx.QueryRow(ctx, `
+		INSERT INTO file_contents (content_hash, hash_algorithm, file_path, file_size, reference_count, enterprise_id, created_at)
+		VALUES ($1, $2, $3, $4, 1, $5, NOW())
+		ON CONFLICT (content_hash) DO UPDATE SET reference_count = file_contents.reference_count + 1
+		WHERE file_contents.hash_algorithm = EXCLUDED.hash_algorithm
+		RETURNING file_path, (xmax = 0)`,
+		contentHash, s.hasher.Algorithm(), s.storage.Key(storage.ContentPath(slug, userID.String(), contentHash)), len(content), enterpriseID).Scan(&filePath, &isNewContent)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s hash %s is stored under another algorithm", ErrContentHashConflict, s.hasher.Algorithm(), contentHash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record file content: %w", err)
+	}
 
-		// Insert new file content record
-		_, err = s.db.Exec(ctx, `
-			INSERT INTO file_contents (content_hash, file_path, file_size, reference_count, created_at)
-			VALUES ($1, $2, $3, 1, NOW())`,
-			contentHash, filePath, len(content))
-		if err != nil {
-			return nil, fmt.Errorf("failed to create file content: %w", err)
+	if isNewContent {
+		// New bytes count against the enterprise quota; deduplicated uploads are free
+		if err := reserveEnterpriseStorage(ctx, tx, enterpriseID, int64(len(content))); err != nil {
+			return nil, err
 		}
-	} else if err != nil {
-		return nil, fmt.Errorf("failed to check existing content: %w", err)
-	} else {
-		// Content already exists, just increment reference count
-		_, err = s.db.Exec(ctx, "UPDATE file_contents SET reference_count = reference_count + 1 WHERE content_hash = $1", contentHash)
-		if err != nil {
-			return nil, fmt.Errorf("failed to increment reference count: %w", err)
+
+		// Content doesn't exist yet, store it in S3/local storage
+		if err := s.storage.Store(ctx, filePath, bytes.NewReader(content), mimeType); err != nil {
+			return nil, fmt.Errorf("failed to store file: %w", err)
 		}
-		filePath = existingFilePath
 	}
 
 	// Set default visibility if not provided
@@ -96,6 +181,13 @@ func (s *SimpleFileService) UploadFile(ctx context.Context, userID uuid.UUID, fi
 		UpdatedAt:     time.Now(),
 	}
 
+	// Files awaiting a malware verdict are stored as pending and can't be downloaded yet. There is
+	// nothing to scan in ciphertext.
+	status := domain.FileStatusActive
+	if s.scanService.Enabled() && encryption == nil {
+		status = domain.FileStatusPendingScan
+	}
+
 	// Generate share token if public
 	if fileVisibility == domain.VisibilityPublic {
 		shareToken := uuid.New().String()
@@ -103,38 +195,190 @@ func (s *SimpleFileService) UploadFile(ctx context.Context, userID uuid.UUID, fi
 	}
 
 	// Insert file record
-	_, err = s.db.Exec(ctx, `
+	if err := insertFileRecord(ctx, tx, file, status); err != nil {
+		s.discardStoredContent(ctx, isNewContent, filePath)
+		return nil, err
+	}
+	if encryption != nil {
+		if err := insertFileEncryption(ctx, tx, file.ID, encryption); err != nil {
+			s.discardStoredContent(ctx, isNewContent, filePath)
+			return nil, err
+		}
+		file.Encryption = encryption
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		s.discardStoredContent(ctx, isNewContent, filePath)
+		return nil, fmt.Errorf("failed to commit upload: %w", err)
+	}
+
+	if status == domain.FileStatusPendingScan {
+		s.scanService.ScanUpload(ctx, file, content)
+	}
+
+	return file, nil
+}
+
+// ErrContentNotFound is returned when content to reference isn't stored, doesn't have the claimed
+// size, or hasn't been found clean by the malware scanner
+var ErrContentNotFound = errors.New("content not found")
+
+// ErrContentHashConflict is returned when new content hashes to a digest already stored for
+// different content under another algorithm
+var ErrContentHashConflict = errors.New("content hash conflicts with content stored under another algorithm")
+
+// referenceableContent matches a file_contents row (aliased fc) by hash ($1), size ($2) and hash
+// algorithm ($3) that new files may point at: still referenced and with no file of it infected or
+// awaiting a verdict
+const referenceableContent = `fc.content_hash = $1 AND fc.file_size = $2 AND fc.hash_algorithm = $3 AND fc.reference_count > 0
+	AND NOT EXISTS (SELECT 1 FROM files f WHERE f.content_hash = fc.content_hash AND f.status <> 'ACTIVE')`
+
+// ContentExists reports whether content with the given hash, computed with algorithm, and size
+// is already stored, so a client can reference it with ReferenceContent instead of uploading it
+// again
+func (s *SimpleFileService) ContentExists(ctx context.Context, algorithm, contentHash string, size int64) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM file_contents fc WHERE `+referenceableContent+`)`,
+		contentHash, size, algorithm).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check content: %w", err)
+	}
+	return exists, nil
+}
+
+// ReferenceContent creates a file for the user pointing at already stored content, as if the
+// bytes had been uploaded again. The size must match the stored content, so knowing a hash alone
+// isn't enough to claim it. The file takes the type recorded for the content when it was
+// uploaded and is subject to the user's enterprise type restrictions.
+func (s *SimpleFileService) ReferenceContent(ctx context.Context, userID uuid.UUID, algorithm, contentHash string, size int64, filename, mimeType string, folderID *uuid.UUID) (*domain.File, error) {
+	_, _, policy, err := s.uploaderEnterprise(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Take the reference first; the row lock keeps the content from being released meanwhile
+	result, err := tx.Exec(ctx, `
+		UPDATE file_contents fc
+		SET reference_count = fc.reference_count + 1
+		WHERE `+referenceableContent, contentHash, size, algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reference content: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return nil, ErrContentNotFound
+	}
+
+	var storedType string
+	err = tx.QueryRow(ctx, `
+		SELECT mime_type FROM files WHERE content_hash = $1 ORDER BY upload_date LIMIT 1`,
+		contentHash).Scan(&storedType)
+	if err == nil {
+		mimeType = storedType
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get content type: %w", err)
+	}
+	if err := s.blocklist.Check(filename, mimeType); err != nil {
+		return nil, err
+	}
+	if !policy.Allows(mimeType) {
+		return nil, &UnsupportedMediaTypeError{MimeType: mimeType}
+	}
+
+	now := time.Now()
+	file := &domain.File{
+		ID:           uuid.New(),
+		UserID:       userID,
+		FolderID:     folderID,
+		Filename:     generateSafeFilename(filename),
+		OriginalName: filename,
+		MimeType:     mimeType,
+		FileSize:     size,
+		ContentHash:  contentHash,
+		Tags:         pq.StringArray{},
+		Visibility:   domain.VisibilityPrivate,
+		UploadDate:   now,
+		UpdatedAt:    now,
+	}
+	if err := insertFileRecord(ctx, tx, file, domain.FileStatusActive); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit reference: %w", err)
+	}
+
+	return file, nil
+}
+
+// uploaderEnterprise resolves what an upload depends on from the user's enterprise: its id for
+// quota enforcement, its slug for the storage path and its type restrictions. Personal users
+// get a nil id, an empty slug and no restrictions.
+func (s *SimpleFileService) uploaderEnterprise(ctx context.Context, userID uuid.UUID) (*uuid.UUID, string, MimePolicy, error) {
+	var enterpriseID *uuid.UUID
+	var enterpriseSlug *string
+	var allowedTypes, blockedTypes []byte
+	err := s.db.QueryRow(ctx, `
+		SELECT u.enterprise_id, e.slug,
+			   e.settings->'`+AllowedMimeTypesSettingKey+`', e.settings->'`+BlockedMimeTypesSettingKey+`'
+		FROM users u
+		LEFT JOIN enterprises e ON u.enterprise_id = e.id
+		WHERE u.id = $1`, userID).Scan(&enterpriseID, &enterpriseSlug, &allowedTypes, &blockedTypes)
+	if err != nil {
+		return nil, "", MimePolicy{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	slug := ""
+	if enterpriseSlug != nil {
+		slug = *enterpriseSlug
+	}
+	return enterpriseID, slug, parseMimePolicy(allowedTypes, blockedTypes), nil
+}
+
+// insertFileRecord writes a new file row inside tx
+func insertFileRecord(ctx context.Context, tx pgx.Tx, file *domain.File, status domain.FileStatus) error {
+	_, err := tx.Exec(ctx, `
 		INSERT INTO files (id, user_id, folder_id, filename, original_name, mime_type,
 		                  file_size, content_hash, description, tags, visibility,
-		                  share_token, download_count, upload_date, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		                  share_token, download_count, upload_date, updated_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
 		file.ID, file.UserID, file.FolderID, file.Filename, file.OriginalName,
 		file.MimeType, file.FileSize, file.ContentHash, file.Description,
 		file.Tags, file.Visibility, file.ShareToken, file.DownloadCount,
-		file.UploadDate, file.UpdatedAt)
-
+		file.UploadDate, file.UpdatedAt, status)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file record: %w", err)
+		return fmt.Errorf("failed to create file record: %w", err)
 	}
-
-	return file, nil
+	return nil
 }
 
-func (s *SimpleFileService) GetFilesByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.File, error) {
-	fmt.Printf("DEBUG: GetFilesByUserID called with userID=%s, limit=%d, offset=%d\n", userID.String(), limit, offset)
-
+// GetFilesByUserID lists the user's files newest first. When after is set the page starts just
+// past that cursor and offset is ignored; keyset paging stays fast on long lists and does not
+// skip or repeat files when new ones arrive between pages.
+func (s *SimpleFileService) GetFilesByUserID(ctx context.Context, userID uuid.UUID, limit, offset int, after *domain.FileCursor) ([]*domain.File, error) {
 	query := `
 		SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
 		       content_hash, description, tags, visibility, share_token, download_count,
 		       upload_date, updated_at
 		FROM files
-		WHERE user_id = $1
-		ORDER BY upload_date DESC
-		LIMIT $2 OFFSET $3`
+		WHERE user_id = $1 AND status <> 'INFECTED'`
+	args := []interface{}{userID}
+	if after != nil {
+		query += " AND (upload_date, id) < ($2, $3)"
+		args = append(args, after.UploadDate, after.ID)
+		offset = 0
+	}
+	query += fmt.Sprintf(" ORDER BY upload_date DESC, id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
 
-	rows, err := s.db.Query(ctx, query, userID, limit, offset)
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
-		fmt.Printf("DEBUG: Query failed with error: %v\n", err)
 		return nil, fmt.Errorf("failed to query files: %w", err)
 	}
 	defer rows.Close()
@@ -149,25 +393,620 @@ func (s *SimpleFileService) GetFilesByUserID(ctx context.Context, userID uuid.UU
 			&file.UploadDate, &file.UpdatedAt,
 		)
 		if err != nil {
-			fmt.Printf("DEBUG: Scan failed with error: %v\n", err)
 			return nil, fmt.Errorf("failed to scan file: %w", err)
 		}
 		files = append(files, file)
 	}
 
-	fmt.Printf("DEBUG: Returning %d files\n", len(files))
+	RequestLogger(ctx, s.logger).Debug("Queried files",
+		zap.String("user_id", userID.String()),
+		zap.Int("limit", limit),
+		zap.Int("offset", offset),
+		zap.Int("count", len(files)),
+	)
 	return files, nil
 }
 
+// CountFilesByUserID counts the files GetFilesByUserID pages through
+func (s *SimpleFileService) CountFilesByUserID(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM files
+		WHERE user_id = $1 AND status <> 'INFECTED'`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count files: %w", err)
+	}
+	return count, nil
+}
+
+// GetFileByID returns a file owned by the given user
+func (s *SimpleFileService) GetFileByID(ctx context.Context, fileID, userID uuid.UUID) (*domain.File, error) {
+	file := &domain.File{}
+	err := s.db.QueryRow(ctx, `
+		SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
+		       content_hash, description, tags, visibility, share_token, download_count,
+		       upload_date, updated_at
+		FROM files
+		WHERE id = $1 AND user_id = $2`, fileID, userID).Scan(
+		&file.ID, &file.UserID, &file.FolderID, &file.Filename, &file.OriginalName,
+		&file.MimeType, &file.FileSize, &file.ContentHash, &file.Description,
+		&file.Tags, &file.Visibility, &file.ShareToken, &file.DownloadCount,
+		&file.UploadDate, &file.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("file not found or access denied: %w", err)
+	}
+
+	return file, nil
+}
+
+// fileReturningColumns is the files column list scanned by scanFile
+const fileReturningColumns = `id, user_id, folder_id, filename, original_name, mime_type, file_size,
+		       content_hash, description, tags, visibility, share_token, download_count,
+		       upload_date, updated_at`
+
+func scanFile(row pgx.Row) (*domain.File, error) {
+	file := &domain.File{}
+	err := row.Scan(
+		&file.ID, &file.UserID, &file.FolderID, &file.Filename, &file.OriginalName,
+		&file.MimeType, &file.FileSize, &file.ContentHash, &file.Description,
+		&file.Tags, &file.Visibility, &file.ShareToken, &file.DownloadCount,
+		&file.UploadDate, &file.UpdatedAt,
+	)
+	return file, err
+}
+
+// AddTags adds tags to a file the user owns. Tags already on the file are not repeated and
+// existing tags keep their order.
+func (s *SimpleFileService) AddTags(ctx context.Context, fileID, userID uuid.UUID, tags []string) (*domain.File, error) {
+	tags = normalizeTags(tags)
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("at least one tag is required")
+	}
+
+	file, err := scanFile(s.db.QueryRow(ctx, `
+		UPDATE files
+		SET tags = ARRAY(
+			SELECT t FROM unnest(COALESCE(tags, '{}') || $3::text[]) WITH ORDINALITY AS x(t, n)
+			GROUP BY t ORDER BY MIN(n)
+		), updated_at = NOW()
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+fileReturningColumns, fileID, userID, tags))
+	if err != nil {
+		return nil, fmt.Errorf("file not found or access denied: %w", err)
+	}
+
+	return file, nil
+}
+
+// RemoveTags removes tags from a file the user owns, leaving its other tags as they were
+func (s *SimpleFileService) RemoveTags(ctx context.Context, fileID, userID uuid.UUID, tags []string) (*domain.File, error) {
+	tags = normalizeTags(tags)
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("at least one tag is required")
+	}
+
+	file, err := scanFile(s.db.QueryRow(ctx, `
+		UPDATE files
+		SET tags = ARRAY(
+			SELECT t FROM unnest(COALESCE(tags, '{}')) WITH ORDINALITY AS x(t, n)
+			WHERE t <> ALL($3::text[])
+			ORDER BY n
+		), updated_at = NOW()
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+fileReturningColumns, fileID, userID, tags))
+	if err != nil {
+		return nil, fmt.Errorf("file not found or access denied: %w", err)
+	}
+
+	return file, nil
+}
+
+// RenameTag renames a tag on every file the user owns and returns the number of files changed.
+// Files that already carry the new tag end up with it once.
+func (s *SimpleFileService) RenameTag(ctx context.Context, userID uuid.UUID, oldTag, newTag string) (int64, error) {
+	oldTag = strings.TrimSpace(oldTag)
+	newTag = strings.TrimSpace(newTag)
+	if oldTag == "" || newTag == "" {
+		return 0, fmt.Errorf("tag names cannot be empty")
+	}
+
+	result, err := s.db.Exec(ctx, `
+		UPDATE files
+		SET tags = ARRAY(
+			SELECT t FROM unnest(array_replace(tags, $2, $3)) WITH ORDINALITY AS x(t, n)
+			GROUP BY t ORDER BY MIN(n)
+		), updated_at = NOW()
+		WHERE user_id = $1 AND $2 = ANY(tags)`,
+		userID, oldTag, newTag)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rename tag: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// GetFilesByTag lists the user's active files carrying tag, whatever other tags they have, oldest
+// first so pages stay put while new files are tagged
+func (s *SimpleFileService) GetFilesByTag(ctx context.Context, userID uuid.UUID, tag string, limit, offset int) ([]*domain.File, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
+		       content_hash, description, tags, visibility, share_token, download_count,
+		       upload_date, updated_at
+		FROM files
+		WHERE user_id = $1 AND status = 'ACTIVE' AND tags @> ARRAY[$2]::text[]
+		ORDER BY upload_date, id
+		LIMIT $3 OFFSET $4`, userID, strings.TrimSpace(tag), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query files by tag: %w", err)
+	}
+	defer rows.Close()
+
+	files := []*domain.File{}
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	return files, rows.Err()
+}
+
+// CountFilesByTag counts the files GetFilesByTag pages through
+func (s *SimpleFileService) CountFilesByTag(ctx context.Context, userID uuid.UUID, tag string) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM files
+		WHERE user_id = $1 AND status = 'ACTIVE' AND tags @> ARRAY[$2]::text[]`,
+		userID, strings.TrimSpace(tag)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count files by tag: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteFilesByTag deletes every file the user owns that carries tag, whatever its scan status,
+// and returns how many were deleted. Each goes through DeleteFile, so content references, storage
+// and audit logs are handled as for a single delete; it stops at the first failure.
+func (s *SimpleFileService) DeleteFilesByTag(ctx context.Context, userID uuid.UUID, tag string) (int, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return 0, fmt.Errorf("tag cannot be empty")
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id FROM files WHERE user_id = $1 AND tags @> ARRAY[$2]::text[]`, userID, tag)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query files by tag: %w", err)
+	}
+	var fileIDs []uuid.UUID
+	for rows.Next() {
+		var fileID uuid.UUID
+		if err := rows.Scan(&fileID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan file ID: %w", err)
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read files by tag: %w", err)
+	}
+
+	for deleted, fileID := range fileIDs {
+		if err := s.DeleteFile(ctx, fileID, userID); err != nil {
+			return deleted, err
+		}
+	}
+	return len(fileIDs), nil
+}
+
+// likeEscaper escapes the LIKE wildcards in user input so it only matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetUserTags returns the distinct tags on the user's files with how many files carry each, most
+// used first. A non-empty prefix keeps only tags starting with it, ignoring case; a positive limit
+// caps how many are returned.
+func (s *SimpleFileService) GetUserTags(ctx context.Context, userID uuid.UUID, prefix string, limit int) ([]*domain.TagCount, error) {
+	var limitArg interface{}
+	if limit > 0 {
+		limitArg = limit
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT t, COUNT(*)
+		FROM files, unnest(tags) AS t
+		WHERE user_id = $1 AND status <> 'INFECTED' AND t ILIKE $2 || '%'
+		GROUP BY t
+		ORDER BY COUNT(*) DESC, t ASC
+		LIMIT $3`, userID, likeEscaper.Replace(prefix), limitArg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []*domain.TagCount{}
+	for rows.Next() {
+		tag := &domain.TagCount{}
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetDuplicateFiles groups the user's files by content, keeping only content that backs more than
+// one file. Add-to-folder and older shares made metadata copies, so these build up over time. Groups
+// come largest content first.
+func (s *SimpleFileService) GetDuplicateFiles(ctx context.Context, userID uuid.UUID) ([]*domain.DuplicateGroup, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+fileReturningColumns+`
+		FROM files
+		WHERE user_id = $1 AND status <> 'INFECTED'
+		AND content_hash IN (
+			SELECT content_hash
+			FROM files
+			WHERE user_id = $1 AND status <> 'INFECTED'
+			GROUP BY content_hash
+			HAVING COUNT(*) > 1
+		)
+		ORDER BY file_size DESC, content_hash, upload_date ASC, id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate files: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []*domain.DuplicateGroup{}
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+
+		// Rows for one content are adjacent, so a new hash starts a new group
+		if len(groups) == 0 || groups[len(groups)-1].ContentHash != file.ContentHash {
+			groups = append(groups, &domain.DuplicateGroup{
+				ContentHash: file.ContentHash,
+				FileSize:    file.FileSize,
+			})
+		}
+		group := groups[len(groups)-1]
+		group.Files = append(group.Files, file)
+	}
+
+	return groups, rows.Err()
+}
+
+// accessibleFileCondition matches active files (aliased f) that user $1 owns or has been shared,
+// either through an unexpired share of the file or a share of its folder or any folder above it
+const accessibleFileCondition = `f.status = 'ACTIVE' AND (f.user_id = $1 OR EXISTS (
+			SELECT 1 FROM file_shares fs
+			WHERE fs.file_id = f.id AND fs.shared_with_user_id = $1
+			AND (fs.expires_at IS NULL OR fs.expires_at > NOW())) OR EXISTS (
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id FROM folders WHERE id = f.folder_id
+				UNION
+				SELECT p.id, p.parent_id FROM folders p JOIN ancestors a ON p.id = a.parent_id
+			)
+			SELECT 1 FROM folder_shares fsh
+			JOIN ancestors a ON a.id = fsh.folder_id
+			WHERE fsh.shared_by_user_id = f.user_id AND fsh.shared_with_user_id = $1))`
+
+// GetVisibleFile returns an active file that is PUBLIC or, when userID is set, that the user owns or
+// has been shared. Files the caller can't see are ErrFileNotFound, like files that don't exist.
+func (s *SimpleFileService) GetVisibleFile(ctx context.Context, fileID uuid.UUID, userID *uuid.UUID) (*domain.File, error) {
+	file, err := scanFile(s.db.QueryRow(ctx, `
+		SELECT `+fileReturningColumns+`
+		FROM files f
+		WHERE f.id = $2 AND (f.status = 'ACTIVE' AND f.visibility = 'PUBLIC' OR `+accessibleFileCondition+`)`,
+		userID, fileID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	return file, nil
+}
+
+// StarFile adds a file the user owns or has been shared to their favorites. Starring it again
+// changes nothing.
+func (s *SimpleFileService) StarFile(ctx context.Context, fileID, userID uuid.UUID) (*domain.File, error) {
+	file, err := scanFile(s.db.QueryRow(ctx, `
+		SELECT `+fileReturningColumns+`
+		FROM files f
+		WHERE f.id = $2 AND `+accessibleFileCondition, userID, fileID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO file_favorites (user_id, file_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, file_id) DO NOTHING`, userID, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to star file: %w", err)
+	}
+
+	file.IsStarred = true
+	return file, nil
+}
+
+// UnstarFile removes a file from the user's favorites; a file that isn't starred is left alone
+func (s *SimpleFileService) UnstarFile(ctx context.Context, fileID, userID uuid.UUID) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM file_favorites WHERE user_id = $1 AND file_id = $2", userID, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to unstar file: %w", err)
+	}
+	return nil
+}
+
+// GetFavoriteFiles lists the user's starred files, most recently starred first. Files the user can
+// no longer reach, such as ones whose share expired, are left out.
+func (s *SimpleFileService) GetFavoriteFiles(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.File, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT f.id, f.user_id, f.folder_id, f.filename, f.original_name, f.mime_type, f.file_size,
+		       f.content_hash, f.description, f.tags, f.visibility, f.share_token, f.download_count,
+		       f.upload_date, f.updated_at
+		FROM file_favorites ff
+		JOIN files f ON f.id = ff.file_id
+		WHERE ff.user_id = $1 AND `+accessibleFileCondition+`
+		ORDER BY ff.created_at DESC, f.id DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query favorite files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []*domain.File{}
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		file.IsStarred = true
+		files = append(files, file)
+	}
+
+	return files, rows.Err()
+}
+
+// CountFavoriteFiles counts the files GetFavoriteFiles pages through
+func (s *SimpleFileService) CountFavoriteFiles(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM file_favorites ff
+		JOIN files f ON f.id = ff.file_id
+		WHERE ff.user_id = $1 AND `+accessibleFileCondition, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count favorite files: %w", err)
+	}
+	return count, nil
+}
+
+// MarkStarred sets IsStarred on each of the files the user has starred
+func (s *SimpleFileService) MarkStarred(ctx context.Context, userID uuid.UUID, files []*domain.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(files))
+	for i, file := range files {
+		ids[i] = file.ID
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT file_id FROM file_favorites
+		WHERE user_id = $1 AND file_id = ANY($2)`, userID, ids)
+	if err != nil {
+		return fmt.Errorf("failed to query favorites: %w", err)
+	}
+	defer rows.Close()
+
+	starred := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		var fileID uuid.UUID
+		if err := rows.Scan(&fileID); err != nil {
+			return fmt.Errorf("failed to scan favorite: %w", err)
+		}
+		starred[fileID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read favorites: %w", err)
+	}
+
+	for _, file := range files {
+		file.IsStarred = starred[file.ID]
+	}
+	return nil
+}
+
+// normalizeTags trims tags and drops empty and repeated ones, keeping the first occurrence
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// ErrInvalidFileName rejects a new file name that is empty or contains a path separator
+var ErrInvalidFileName = errors.New("invalid file name")
+
+// ErrInvalidVisibility rejects a visibility other than PRIVATE, PUBLIC or SHARED_WITH_USERS
+var ErrInvalidVisibility = errors.New("invalid visibility")
+
+// RenameFile changes the display name of a file the user owns and regenerates its safe filename.
+// Only the metadata changes; the stored content is keyed by hash and stays where it is.
+func (s *SimpleFileService) RenameFile(ctx context.Context, fileID, userID uuid.UUID, newName string) (*domain.File, error) {
+	newName = strings.TrimSpace(newName)
+	if newName == "" || newName == "." || newName == ".." {
+		return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidFileName)
+	}
+	if strings.ContainsAny(newName, `/\`) {
+		return nil, fmt.Errorf("%w: name cannot contain path separators", ErrInvalidFileName)
+	}
+
+	existing, err := s.GetFileByID(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := scanFile(s.db.QueryRow(ctx, `
+		UPDATE files
+		SET original_name = $1, filename = $2, updated_at = NOW()
+		WHERE id = $3 AND user_id = $4
+		RETURNING `+fileReturningColumns,
+		newName, generateSafeFilename(newName), fileID, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename file: %w", err)
+	}
+
+	ipAddress, userAgent := RequestMeta(ctx)
+	s.auditService.LogFileRename(ctx, userID, fileID, existing.OriginalName, newName, ipAddress, userAgent)
+
+	return file, nil
+}
+
+// UpdateFile applies the fields set in req to a file the user owns. An empty description clears
+// it and tags replace the file's tags. The share token and short code follow the visibility: a
+// file made PUBLIC gets a token, and a code when short codes are on, unless it already has them,
+// and a file made anything else loses both, so links handed out while it was public stop working.
+// Filename and FolderID go through RenameFile and MoveFile.
+func (s *SimpleFileService) UpdateFile(ctx context.Context, fileID, userID uuid.UUID, req domain.FileUpdateRequest) (*domain.File, error) {
+	file, err := s.GetFileByID(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Visibility != nil {
+		switch *req.Visibility {
+		case domain.VisibilityPrivate, domain.VisibilityPublic, domain.VisibilitySharedWithUsers:
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidVisibility, *req.Visibility)
+		}
+	}
+
+	if req.Filename != nil {
+		if file, err = s.RenameFile(ctx, fileID, userID, *req.Filename); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Description != nil || req.Tags != nil || req.Visibility != nil {
+		wasPublic := file.ShareToken != nil
+
+		var description *string
+		if req.Description != nil {
+			trimmed := strings.TrimSpace(*req.Description)
+			description = &trimmed
+		}
+		var tags []string
+		if req.Tags != nil {
+			tags = normalizeTags(*req.Tags)
+		}
+		var visibility *string
+		if req.Visibility != nil {
+			value := string(*req.Visibility)
+			visibility = &value
+		}
+
+		// A code another file already holds is replaced by a fresh one
+		for attempt := 1; ; attempt++ {
+			var shareCode *string
+			if s.shortCodes {
+				code, err := generateShareCode()
+				if err != nil {
+					return nil, fmt.Errorf("failed to generate share code: %w", err)
+				}
+				shareCode = &code
+			}
+
+			file, err = scanFile(s.db.QueryRow(ctx, `
+				UPDATE files
+				SET description = CASE WHEN $3::text IS NULL THEN description ELSE NULLIF($3, '') END,
+				    tags = COALESCE($4::text[], tags),
+				    visibility = COALESCE($5::text, visibility),
+				    share_token = CASE
+				        WHEN $5::text IS NULL THEN share_token
+				        WHEN $5 = 'PUBLIC' THEN COALESCE(share_token, $6)
+				        ELSE NULL
+				    END,
+				    share_code = CASE
+				        WHEN $5::text IS NULL THEN share_code
+				        WHEN $5 = 'PUBLIC' THEN COALESCE(share_code, $7)
+				        ELSE NULL
+				    END,
+				    updated_at = NOW()
+				WHERE id = $1 AND user_id = $2
+				RETURNING `+fileReturningColumns,
+				fileID, userID, description, tags, visibility, uuid.New().String(), shareCode))
+			if isShareCodeCollision(err) && attempt < maxShareCodeAttempts {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to update file: %w", err)
+			}
+			break
+		}
+
+		if file.ShareToken != nil && !wasPublic {
+			ipAddress, userAgent := RequestMeta(ctx)
+			s.auditService.LogPublicShare(ctx, userID, fileID, file.OriginalName, *file.ShareToken, ipAddress, userAgent)
+		}
+	}
+
+	if req.FolderID != nil {
+		if file, err = s.MoveFile(ctx, fileID, userID, req.FolderID); err != nil {
+			return nil, err
+		}
+	}
+
+	return file, nil
+}
+
+// MoveFile moves a file into one of its owner's folders, or to the root when newFolderID is nil.
+// Shares and folder references key on the file ID, so they survive the move untouched.
+//
+// Stored content is namespaced by the owner's enterprise (personal/... or enterprises/<slug>/...)
+// and file_contents.enterprise_id decides whose quota the bytes count against. Both are fixed at
+// upload, so a file uploaded before its owner joined or left an enterprise still lives in the old
+// namespace. Moving such a file re-keys its content into the owner's current namespace when this
+// file is the only reference: the object is copied to the new path, the storage charge moves to the
+// new enterprise (failing the move if that would exceed its quota) and the old object is removed.
+// Content that other files also reference stays where it is, since those files still point at it.
 func (s *SimpleFileService) MoveFile(ctx context.Context, fileID, userID uuid.UUID, newFolderID *uuid.UUID) (*domain.File, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	// Verify file ownership
 	var existingFile domain.File
-	err := s.db.QueryRow(ctx, `
+	err = tx.QueryRow(ctx, `
 		SELECT id, user_id, folder_id, filename, original_name, mime_type, file_size,
 		       content_hash, description, tags, visibility, share_token, download_count,
 		       upload_date, updated_at
 		FROM files
-		WHERE id = $1 AND user_id = $2`, fileID, userID).Scan(
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE`, fileID, userID).Scan(
 		&existingFile.ID, &existingFile.UserID, &existingFile.FolderID, &existingFile.Filename, &existingFile.OriginalName,
 		&existingFile.MimeType, &existingFile.FileSize, &existingFile.ContentHash, &existingFile.Description,
 		&existingFile.Tags, &existingFile.Visibility, &existingFile.ShareToken, &existingFile.DownloadCount,
@@ -177,8 +1016,19 @@ func (s *SimpleFileService) MoveFile(ctx context.Context, fileID, userID uuid.UU
 		return nil, fmt.Errorf("file not found or access denied: %w", err)
 	}
 
+	if newFolderID != nil {
+		var ownsFolder bool
+		err = tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM folders WHERE id = $1 AND user_id = $2)", *newFolderID, userID).Scan(&ownsFolder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check folder: %w", err)
+		}
+		if !ownsFolder {
+			return nil, fmt.Errorf("folder not found or access denied")
+		}
+	}
+
 	// Update file's folder_id
-	_, err = s.db.Exec(ctx, `
+	_, err = tx.Exec(ctx, `
 		UPDATE files
 		SET folder_id = $1, updated_at = NOW()
 		WHERE id = $2 AND user_id = $3`,
@@ -187,6 +1037,29 @@ func (s *SimpleFileService) MoveFile(ctx context.Context, fileID, userID uuid.UU
 		return nil, fmt.Errorf("failed to move file: %w", err)
 	}
 
+	oldPath, newPath, err := s.rekeyContent(ctx, tx, &existingFile)
+	if err != nil {
+		return nil, err
+	}
+	if oldPath != "" {
+		if err := queueStorageDeletion(ctx, tx, oldPath); err != nil {
+			s.discardStoredContent(ctx, true, newPath)
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		if newPath != "" {
+			s.discardStoredContent(ctx, true, newPath)
+		}
+		return nil, fmt.Errorf("failed to commit move: %w", err)
+	}
+
+	// The content now lives at its new path, so the old copy can go
+	if oldPath != "" {
+		s.deleteQueuedObject(ctx, oldPath)
+	}
+
 	// Update the existing file object and return it
 	existingFile.FolderID = newFolderID
 	existingFile.UpdatedAt = time.Now()
@@ -194,52 +1067,361 @@ func (s *SimpleFileService) MoveFile(ctx context.Context, fileID, userID uuid.UU
 	return &existingFile, nil
 }
 
+// rekeyContent moves a file's content into its owner's current storage namespace when the file
+// is the content's only reference (see MoveFile). It returns the old and new object paths when
+// the content was copied, or empty strings when nothing had to change.
+func (s *SimpleFileService) rekeyContent(ctx context.Context, tx pgx.Tx, file *domain.File) (string, string, error) {
+	var userEnterpriseID *uuid.UUID
+	var enterpriseSlug *string
+	err := tx.QueryRow(ctx, `
+		SELECT u.enterprise_id, e.slug
+		FROM users u
+		LEFT JOIN enterprises e ON u.enterprise_id = e.id
+		WHERE u.id = $1`, file.UserID).Scan(&userEnterpriseID, &enterpriseSlug)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	var filePath string
+	var fileSize int64
+	var refCount int
+	var contentEnterpriseID *uuid.UUID
+	err = tx.QueryRow(ctx, `
+		SELECT file_path, file_size, reference_count, enterprise_id
+		FROM file_contents
+		WHERE content_hash = $1
+		FOR UPDATE`, file.ContentHash).Scan(&filePath, &fileSize, &refCount, &contentEnterpriseID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get file content: %w", err)
+	}
+
+	sameNamespace := (userEnterpriseID == nil && contentEnterpriseID == nil) ||
+		(userEnterpriseID != nil && contentEnterpriseID != nil && *userEnterpriseID == *contentEnterpriseID)
+	if sameNamespace || refCount > 1 {
+		return "", "", nil
+	}
+
+	slug := ""
+	if enterpriseSlug != nil {
+		slug = *enterpriseSlug
+	}
+	newPath := s.storage.Key(storage.ContentPath(slug, file.UserID.String(), file.ContentHash))
+
+	// Move the storage charge before copying so a full enterprise rejects the move up front
+	if err := releaseEnterpriseStorage(ctx, tx, contentEnterpriseID, fileSize); err != nil {
+		return "", "", err
+	}
+	if err := reserveEnterpriseStorage(ctx, tx, userEnterpriseID, fileSize); err != nil {
+		return "", "", err
+	}
+
+	if newPath == filePath {
+		_, err = tx.Exec(ctx, "UPDATE file_contents SET enterprise_id = $1 WHERE content_hash = $2", userEnterpriseID, file.ContentHash)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to update file content: %w", err)
+		}
+		return "", "", nil
+	}
+
+	content, err := storage.ReadAll(ctx, s.storage, filePath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read file content: %w", err)
+	}
+	if err := s.storage.Store(ctx, newPath, bytes.NewReader(content), file.MimeType); err != nil {
+		return "", "", fmt.Errorf("failed to store file: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE file_contents
+		SET file_path = $1, enterprise_id = $2
+		WHERE content_hash = $3`,
+		newPath, userEnterpriseID, file.ContentHash)
+	if err != nil {
+		s.discardStoredContent(ctx, true, newPath)
+		return "", "", fmt.Errorf("failed to update file content: %w", err)
+	}
+
+	return filePath, newPath, nil
+}
+
 func (s *SimpleFileService) DeleteFile(ctx context.Context, fileID, userID uuid.UUID) error {
-	// Verify file ownership and get file info
-	var file domain.File
-	err := s.db.QueryRow(ctx, `
-		SELECT id, user_id, content_hash
-		FROM files
-		WHERE id = $1 AND user_id = $2`, fileID, userID).Scan(
-		&file.ID, &file.UserID, &file.ContentHash,
-	)
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("file not found or access denied: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	// Delete the file record
-	_, err = s.db.Exec(ctx, "DELETE FROM files WHERE id = $1", fileID)
+	// Verify file ownership and delete the file record, keeping its name for the audit log
+	var contentHash, fileName string
+	err = tx.QueryRow(ctx, `
+		DELETE FROM files
+		WHERE id = $1 AND user_id = $2
+		RETURNING content_hash, original_name`, fileID, userID).Scan(&contentHash, &fileName)
 	if err != nil {
-		return fmt.Errorf("failed to delete file record: %w", err)
+		return fmt.Errorf("file not found or access denied: %w", err)
 	}
 
 	// Decrement reference count and check if we should delete from storage
 	var newRefCount int
 	var filePath string
-	err = s.db.QueryRow(ctx, `
+	var fileSize int64
+	var enterpriseID *uuid.UUID
+	err = tx.QueryRow(ctx, `
 		UPDATE file_contents
 		SET reference_count = reference_count - 1
 		WHERE content_hash = $1
-		RETURNING reference_count, file_path`, file.ContentHash).Scan(&newRefCount, &filePath)
+		RETURNING reference_count, file_path, file_size, enterprise_id`, contentHash).Scan(&newRefCount, &filePath, &fileSize, &enterpriseID)
 
 	if err != nil {
 		return fmt.Errorf("failed to update reference count: %w", err)
 	}
 
-	// If no more references, delete from storage and database
+	// If no more references, delete the content record and give the bytes back to the enterprise
+	if newRefCount <= 0 {
+		_, err = tx.Exec(ctx, "DELETE FROM file_contents WHERE content_hash = $1", contentHash)
+		if err != nil {
+			return fmt.Errorf("failed to delete file content record: %w", err)
+		}
+
+		if err := releaseEnterpriseStorage(ctx, tx, enterpriseID, fileSize); err != nil {
+			return err
+		}
+		if err := queueStorageDeletion(ctx, tx, filePath); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit delete: %w", err)
+	}
+
+	ipAddress, userAgent := RequestMeta(ctx)
+	s.auditService.LogFileDelete(ctx, userID, fileID, fileName, ipAddress, userAgent)
+
+	// Remove the bytes only once the record is gone, unless an upload of the same content has
+	// stored them again since
 	if newRefCount <= 0 {
-		// Delete from S3/local storage
-		err = s.storage.DeleteFile(ctx, filePath)
+		s.deleteQueuedObject(ctx, filePath)
+	}
+
+	return nil
+}
+
+// RecordDownload counts one completed download of a file and adds it to the file's access history.
+// Every download path goes through here so a download is never counted twice or missed. userID is
+// nil for downloads through a public share link.
+func (s *SimpleFileService) RecordDownload(ctx context.Context, fileID uuid.UUID, userID *uuid.UUID, ipAddress string) error {
+	_, err := s.db.Exec(ctx, `
+		WITH counted AS (
+			UPDATE files
+			SET download_count = download_count + 1
+			WHERE id = $1
+			RETURNING id
+		)
+		INSERT INTO file_access_events (file_id, user_id, ip_address, access_type)
+		SELECT id, $2, NULLIF($3, '')::inet, $4 FROM counted`,
+		fileID, userID, ipAddress, domain.FileAccessDownload)
+	if err != nil {
+		return fmt.Errorf("failed to record download: %w", err)
+	}
+	return nil
+}
+
+// RecordPreview adds an inline view of a file to its access history; previews don't count as
+// downloads. userID is nil for previews through a public share link.
+func (s *SimpleFileService) RecordPreview(ctx context.Context, fileID uuid.UUID, userID *uuid.UUID, ipAddress string) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO file_access_events (file_id, user_id, ip_address, access_type)
+		VALUES ($1, $2, NULLIF($3, '')::inet, $4)`,
+		fileID, userID, ipAddress, domain.FileAccessPreview)
+	if err != nil {
+		return fmt.Errorf("failed to record preview: %w", err)
+	}
+	return nil
+}
+
+// GetDownloadStats returns a file's downloads and previews per UTC day over the last `days` days,
+// today included. Days without any access are included with zero counts.
+func (s *SimpleFileService) GetDownloadStats(ctx context.Context, fileID uuid.UUID, days int) ([]*domain.FileAccessDay, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("days must be at least 1")
+	}
+
+	rows, err := s.db.Query(ctx, `
+		WITH days AS (
+			SELECT generate_series(
+				(NOW() AT TIME ZONE 'UTC')::date - ($2::int - 1),
+				(NOW() AT TIME ZONE 'UTC')::date,
+				INTERVAL '1 day'
+			)::date AS day
+		)
+		SELECT d.day,
+		       COUNT(e.id) FILTER (WHERE e.access_type = $3),
+		       COUNT(e.id) FILTER (WHERE e.access_type = $4)
+		FROM days d
+		LEFT JOIN file_access_events e
+			ON e.file_id = $1 AND (e.accessed_at AT TIME ZONE 'UTC')::date = d.day
+		GROUP BY d.day
+		ORDER BY d.day`,
+		fileID, days, domain.FileAccessDownload, domain.FileAccessPreview)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query download stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []*domain.FileAccessDay
+	for rows.Next() {
+		day := &domain.FileAccessDay{}
+		if err := rows.Scan(&day.Date, &day.Downloads, &day.Previews); err != nil {
+			return nil, fmt.Errorf("failed to scan download stats: %w", err)
+		}
+		stats = append(stats, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read download stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetRecentFiles lists the files the user touched most recently: their own files by when they were
+// uploaded, and any file they can reach by when they last downloaded or previewed it, whichever is
+// later. Access comes from file_access_events rather than audit logs, which retention may purge;
+// updated_at isn't used because other people's downloads bump it. Each file is listed once, with
+// LastAccessedAt set to the user's latest access when they have one.
+func (s *SimpleFileService) GetRecentFiles(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.File, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT f.id, f.user_id, f.folder_id, f.filename, f.original_name, f.mime_type, f.file_size,
+		       f.content_hash, f.description, f.tags, f.visibility, f.share_token, f.download_count,
+		       f.upload_date, f.updated_at, access.accessed_at
+		FROM files f
+		LEFT JOIN LATERAL (
+			SELECT MAX(e.accessed_at) AS accessed_at
+			FROM file_access_events e
+			WHERE e.file_id = f.id AND e.user_id = $1
+		) access ON TRUE
+		WHERE (f.user_id = $1 OR access.accessed_at IS NOT NULL) AND `+accessibleFileCondition+`
+		ORDER BY GREATEST(access.accessed_at, CASE WHEN f.user_id = $1 THEN f.upload_date END) DESC, f.id DESC
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []*domain.File{}
+	for rows.Next() {
+		file := &domain.File{}
+		err := rows.Scan(
+			&file.ID, &file.UserID, &file.FolderID, &file.Filename, &file.OriginalName,
+			&file.MimeType, &file.FileSize, &file.ContentHash, &file.Description,
+			&file.Tags, &file.Visibility, &file.ShareToken, &file.DownloadCount,
+			&file.UploadDate, &file.UpdatedAt, &file.LastAccessedAt,
+		)
 		if err != nil {
-			// Log error but don't fail the whole operation
-			fmt.Printf("WARNING: Failed to delete file from storage: %v\n", err)
+			return nil, fmt.Errorf("failed to scan file: %w", err)
 		}
+		files = append(files, file)
+	}
 
-		// Delete from file_contents table
-		_, err = s.db.Exec(ctx, "DELETE FROM file_contents WHERE content_hash = $1", file.ContentHash)
+	return files, rows.Err()
+}
+
+// GetPopularFiles lists the user's files that have been downloaded, most downloaded first.
+// download_count counts every download, including through public share links.
+func (s *SimpleFileService) GetPopularFiles(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.File, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT f.id, f.user_id, f.folder_id, f.filename, f.original_name, f.mime_type, f.file_size,
+		       f.content_hash, f.description, f.tags, f.visibility, f.share_token, f.download_count,
+		       f.upload_date, f.updated_at
+		FROM files f
+		WHERE f.user_id = $1 AND f.status = 'ACTIVE' AND f.download_count > 0
+		ORDER BY f.download_count DESC, f.upload_date DESC, f.id DESC
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query popular files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []*domain.File{}
+	for rows.Next() {
+		file, err := scanFile(rows)
 		if err != nil {
-			return fmt.Errorf("failed to delete file content record: %w", err)
+			return nil, fmt.Errorf("failed to scan file: %w", err)
 		}
+		files = append(files, file)
+	}
+
+	return files, rows.Err()
+}
+
+// discardStoredContent removes bytes stored for an upload whose records were rolled back
+func (s *SimpleFileService) discardStoredContent(ctx context.Context, isNewContent bool, filePath string) {
+	if !isNewContent {
+		return
+	}
+	s.deleteStoredObject(ctx, filePath)
+}
+
+// deleteStoredObject removes an object no record points at any more, such as bytes stored for an
+// upload that was rolled back. It goes through the deletion queue, so the object is kept if live
+// content has been stored at its path meanwhile, and a failure is retried later.
+func (s *SimpleFileService) deleteStoredObject(ctx context.Context, filePath string) {
+	if err := queueStorageDeletion(ctx, s.db, filePath); err != nil {
+		RequestLogger(ctx, s.logger).Error("Failed to queue storage deletion",
+			zap.String("file_path", filePath),
+			zap.Error(err))
+		return
+	}
+	s.deleteQueuedObject(ctx, filePath)
+}
+
+// deleteQueuedObject deletes an object already queued for deletion, logging what goes wrong
+func (s *SimpleFileService) deleteQueuedObject(ctx context.Context, filePath string) {
+	if _, err := deleteQueuedObject(ctx, s.db, s.storage, RequestLogger(ctx, s.logger), filePath); err != nil {
+		RequestLogger(ctx, s.logger).Error("Failed to delete queued object from storage",
+			zap.String("file_path", filePath),
+			zap.Error(err))
+	}
+}
+
+// ErrQuotaExceeded is returned when storing content would take its owner over a storage quota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// reserveEnterpriseStorage atomically adds size to the enterprise's storage_used, failing with
+// ErrQuotaExceeded if it would exceed the quota. Users without an enterprise are not limited here.
+func reserveEnterpriseStorage(ctx context.Context, tx pgx.Tx, enterpriseID *uuid.UUID, size int64) error {
+	if enterpriseID == nil {
+		return nil
+	}
+
+	result, err := tx.Exec(ctx, `
+		UPDATE enterprises
+		SET storage_used = storage_used + $1, updated_at = NOW()
+		WHERE id = $2 AND storage_used + $1 <= storage_quota`,
+		size, *enterpriseID)
+	if err != nil {
+		return fmt.Errorf("failed to update enterprise storage: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("enterprise %w", ErrQuotaExceeded)
+	}
+
+	return nil
+}
+
+// releaseEnterpriseStorage subtracts size from the enterprise's storage_used
+func releaseEnterpriseStorage(ctx context.Context, tx pgx.Tx, enterpriseID *uuid.UUID, size int64) error {
+	if enterpriseID == nil {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `
+		UPDATE enterprises
+		SET storage_used = GREATEST(storage_used - $1, 0), updated_at = NOW()
+		WHERE id = $2`,
+		size, *enterpriseID)
+	if err != nil {
+		return fmt.Errorf("failed to release enterprise storage: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,1232 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/storage"
+	"lokr-backend/internal/testutil"
+	"lokr-backend/pkg/hash"
+)
+
+// newTestFileService builds a file service backed by a temporary local storage directory
+func newTestFileService(t *testing.T, db *pgxpool.Pool) *SimpleFileService {
+	t.Helper()
+
+	store, _ := testutil.NewLocalStorage(t)
+	return NewSimpleFileService(db, store, nil, nil, zap.NewNop())
+}
+
+// cleanupContent removes any files and content rows left behind for content
+func cleanupContent(t *testing.T, db *pgxpool.Pool, content []byte) {
+	hash := fmt.Sprintf("%x", sha256.Sum256(content))
+	t.Cleanup(func() {
+		db.Exec(context.Background(), "DELETE FROM files WHERE content_hash = $1", hash)
+		db.Exec(context.Background(), "DELETE FROM file_contents WHERE content_hash = $1", hash)
+	})
+}
+
+func enterpriseStorageUsed(t *testing.T, db *pgxpool.Pool, enterpriseID uuid.UUID) int64 {
+	t.Helper()
+
+	var used int64
+	if err := db.QueryRow(context.Background(), "SELECT storage_used FROM enterprises WHERE id = $1", enterpriseID).Scan(&used); err != nil {
+		t.Fatalf("Failed to load enterprise storage: %v", err)
+	}
+	return used
+}
+
+func TestSimpleFileService_UploadRejectedAtEnterpriseQuota(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	userID := testutil.CreateUser(t, db, "quota", &enterpriseID)
+	db.Exec(context.Background(), "UPDATE enterprises SET storage_quota = 100, storage_used = 95 WHERE id = $1", enterpriseID)
+
+	content := []byte("over quota " + uuid.NewString())
+	cleanupContent(t, db, content)
+
+	if _, err := service.UploadFile(context.Background(), userID, "big.txt", "text/plain", content, nil, nil, nil, nil); err == nil {
+		t.Fatal("Expected upload beyond the enterprise quota to fail")
+	}
+
+	var fileCount int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM files WHERE user_id = $1", userID).Scan(&fileCount)
+	if fileCount != 0 {
+		t.Errorf("Expected no file record after a rejected upload, got %d", fileCount)
+	}
+	if used := enterpriseStorageUsed(t, db, enterpriseID); used != 95 {
+		t.Errorf("Expected storage used to stay at 95, got %d", used)
+	}
+}
+
+func TestSimpleFileService_EnterpriseStorageAcrossDedup(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	alice := testutil.CreateUser(t, db, "alice", &enterpriseID)
+	bob := testutil.CreateUser(t, db, "bob", &enterpriseID)
+
+	content := []byte("shared bytes " + uuid.NewString())
+	cleanupContent(t, db, content)
+	size := int64(len(content))
+
+	first, err := service.UploadFile(context.Background(), alice, "a.txt", "text/plain", content, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("First upload failed: %v", err)
+	}
+	if used := enterpriseStorageUsed(t, db, enterpriseID); used != size {
+		t.Errorf("Expected storage used to be %d after first upload, got %d", size, used)
+	}
+
+	second, err := service.UploadFile(context.Background(), bob, "b.txt", "text/plain", content, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Duplicate upload failed: %v", err)
+	}
+	if used := enterpriseStorageUsed(t, db, enterpriseID); used != size {
+		t.Errorf("Expected deduplicated upload not to change storage used, got %d", used)
+	}
+
+	if err := service.DeleteFile(context.Background(), first.ID, alice); err != nil {
+		t.Fatalf("Deleting first copy failed: %v", err)
+	}
+	if used := enterpriseStorageUsed(t, db, enterpriseID); used != size {
+		t.Errorf("Expected storage used to stay at %d while a reference remains, got %d", size, used)
+	}
+
+	if err := service.DeleteFile(context.Background(), second.ID, bob); err != nil {
+		t.Fatalf("Deleting last copy failed: %v", err)
+	}
+	if used := enterpriseStorageUsed(t, db, enterpriseID); used != 0 {
+		t.Errorf("Expected storage used to return to 0, got %d", used)
+	}
+}
+
+func TestSimpleFileService_ConcurrentIdenticalUploads(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+	userID := testutil.CreateUser(t, db, "concurrent", nil)
+
+	content := []byte("concurrent bytes " + uuid.NewString())
+	cleanupContent(t, db, content)
+	hash := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	const uploads = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := service.UploadFile(context.Background(), userID, fmt.Sprintf("copy-%d.txt", i), "text/plain", content, nil, nil, nil, nil)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Concurrent upload failed: %v", err)
+		}
+	}
+
+	var refCount int
+	if err := db.QueryRow(context.Background(), "SELECT reference_count FROM file_contents WHERE content_hash = $1", hash).Scan(&refCount); err != nil {
+		t.Fatalf("Failed to load content record: %v", err)
+	}
+	if refCount != uploads {
+		t.Errorf("Expected reference count to be %d, got %d", uploads, refCount)
+	}
+
+	var fileCount int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM files WHERE content_hash = $1", hash).Scan(&fileCount)
+	if fileCount != uploads {
+		t.Errorf("Expected %d file records, got %d", uploads, fileCount)
+	}
+}
+
+func TestSimpleFileService_HashAlgorithms(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	userID := testutil.CreateUser(t, db, "hashing", nil)
+
+	store, _ := testutil.NewLocalStorage(t)
+	sha := NewSimpleFileService(db, store, nil, nil, zap.NewNop())
+	blake := NewSimpleFileService(db, store, nil, nil, zap.NewNop())
+	blakeHasher, err := hash.NewHasher(hash.AlgorithmBLAKE2b256)
+	if err != nil {
+		t.Fatalf("NewHasher failed: %v", err)
+	}
+	blake.SetHasher(blakeHasher)
+
+	content := []byte("hashed two ways " + uuid.NewString())
+	cleanupContent(t, db, content)
+	blakeHash := hash.Sum(blakeHasher, content)
+	t.Cleanup(func() {
+		db.Exec(ctx, "DELETE FROM files WHERE content_hash = $1", blakeHash)
+		db.Exec(ctx, "DELETE FROM file_contents WHERE content_hash = $1", blakeHash)
+	})
+
+	before, err := sha.UploadFile(ctx, userID, "before.txt", "text/plain", content, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("SHA-256 upload failed: %v", err)
+	}
+	after, err := blake.UploadFile(ctx, userID, "after.txt", "text/plain", content, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("BLAKE2b upload failed: %v", err)
+	}
+	if again, err := blake.UploadFile(ctx, userID, "again.txt", "text/plain", content, nil, nil, nil, nil); err != nil || again.ContentHash != blakeHash {
+		t.Fatalf("Expected a second BLAKE2b upload to share %s, got %+v (%v)", blakeHash, again, err)
+	}
+
+	if before.ContentHash != hash.SHA256Hash(content) || after.ContentHash != blakeHash {
+		t.Fatalf("Expected each upload hashed with its algorithm, got %s and %s", before.ContentHash, after.ContentHash)
+	}
+
+	// The same bytes under two algorithms are two content records that coexist
+	for _, want := range []struct {
+		contentHash string
+		algorithm   string
+		references  int
+	}{
+		{before.ContentHash, hash.AlgorithmSHA256, 1},
+		{blakeHash, hash.AlgorithmBLAKE2b256, 2},
+	} {
+		var algorithm string
+		var references int
+		db.QueryRow(ctx, "SELECT hash_algorithm, reference_count FROM file_contents WHERE content_hash = $1", want.contentHash).Scan(&algorithm, &references)
+		if algorithm != want.algorithm || references != want.references {
+			t.Errorf("Expected %s to be %s with %d references, got %q with %d", want.contentHash, want.algorithm, want.references, algorithm, references)
+		}
+	}
+
+	t.Run("lookups stay within an algorithm", func(t *testing.T) {
+		size := int64(len(content))
+		if exists, err := sha.ContentExists(ctx, hash.AlgorithmSHA256, blakeHash, size); err != nil || exists {
+			t.Errorf("Expected a BLAKE2b hash not to match as SHA-256, got %t (%v)", exists, err)
+		}
+		if exists, err := sha.ContentExists(ctx, hash.AlgorithmBLAKE2b256, blakeHash, size); err != nil || !exists {
+			t.Errorf("Expected the BLAKE2b hash to match, got %t (%v)", exists, err)
+		}
+		if _, err := sha.ReferenceContent(ctx, userID, hash.AlgorithmSHA256, blakeHash, size, "claim.txt", "text/plain", nil); !errors.Is(err, ErrContentNotFound) {
+			t.Errorf("Expected ErrContentNotFound, got %v", err)
+		}
+	})
+
+	t.Run("equal digests from different algorithms", func(t *testing.T) {
+		// Stand-in for different content that SHA-256 happened to hash to this BLAKE2b digest
+		other := []byte("colliding " + uuid.NewString())
+		otherHash := hash.Sum(blakeHasher, other)
+		db.Exec(ctx, `
+			INSERT INTO file_contents (content_hash, hash_algorithm, file_path, file_size, reference_count)
+			VALUES ($1, 'sha256', 'personal/users/someone/else', 1, 1)`, otherHash)
+		t.Cleanup(func() { db.Exec(ctx, "DELETE FROM file_contents WHERE content_hash = $1", otherHash) })
+
+		if _, err := blake.UploadFile(ctx, userID, "other.txt", "text/plain", other, nil, nil, nil, nil); !errors.Is(err, ErrContentHashConflict) {
+			t.Fatalf("Expected ErrContentHashConflict, got %v", err)
+		}
+		var references int
+		db.QueryRow(ctx, "SELECT reference_count FROM file_contents WHERE content_hash = $1", otherHash).Scan(&references)
+		if references != 1 {
+			t.Errorf("Expected the SHA-256 record to be left alone, got %d references", references)
+		}
+	})
+}
+
+func TestSimpleFileService_MovePersonalFileIntoEnterprise(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+	ctx := context.Background()
+
+	userID := testutil.CreateUser(t, db, "joiner", nil)
+	content := []byte("personal bytes " + uuid.NewString())
+	cleanupContent(t, db, content)
+	hash := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	file, err := service.UploadFile(ctx, userID, "notes.txt", "text/plain", content, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	shareToken := uuid.NewString()
+	db.Exec(ctx, "UPDATE files SET visibility = 'PUBLIC', share_token = $1 WHERE id = $2", shareToken, file.ID)
+
+	var oldPath string
+	db.QueryRow(ctx, "SELECT file_path FROM file_contents WHERE content_hash = $1", hash).Scan(&oldPath)
+
+	// The owner joins an enterprise after uploading
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	var slug string
+	db.QueryRow(ctx, "SELECT slug FROM enterprises WHERE id = $1", enterpriseID).Scan(&slug)
+	db.Exec(ctx, "UPDATE users SET enterprise_id = $1, enterprise_role = 'MEMBER' WHERE id = $2", enterpriseID, userID)
+
+	folderID := uuid.New()
+	if _, err := db.Exec(ctx, "INSERT INTO folders (id, user_id, name) VALUES ($1, $2, 'work')", folderID, userID); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	moved, err := service.MoveFile(ctx, file.ID, userID, &folderID)
+	if err != nil {
+		t.Fatalf("MoveFile failed: %v", err)
+	}
+	if moved.FolderID == nil || *moved.FolderID != folderID {
+		t.Errorf("Expected file to be in folder %s, got %v", folderID, moved.FolderID)
+	}
+
+	var newPath string
+	var contentEnterpriseID *uuid.UUID
+	db.QueryRow(ctx, "SELECT file_path, enterprise_id FROM file_contents WHERE content_hash = $1", hash).Scan(&newPath, &contentEnterpriseID)
+	wantPath := fmt.Sprintf("enterprises/%s/users/%s/%s", slug, userID, hash)
+	if newPath != wantPath {
+		t.Errorf("Expected content path '%s', got '%s'", wantPath, newPath)
+	}
+	if contentEnterpriseID == nil || *contentEnterpriseID != enterpriseID {
+		t.Errorf("Expected content to belong to enterprise %s, got %v", enterpriseID, contentEnterpriseID)
+	}
+	if used := enterpriseStorageUsed(t, db, enterpriseID); used != int64(len(content)) {
+		t.Errorf("Expected enterprise storage used to be %d, got %d", len(content), used)
+	}
+
+	if stored, err := storage.ReadAll(ctx, service.storage, newPath); err != nil || string(stored) != string(content) {
+		t.Errorf("Expected content at the new path, got error: %v", err)
+	}
+	if _, err := storage.ReadAll(ctx, service.storage, oldPath); err == nil {
+		t.Error("Expected the personal copy to be removed")
+	}
+
+	var token string
+	db.QueryRow(ctx, "SELECT share_token FROM files WHERE id = $1", file.ID).Scan(&token)
+	if token != shareToken {
+		t.Error("Expected the public share to survive the move")
+	}
+}
+
+func TestSimpleFileService_TagMutations(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+	ctx := context.Background()
+	userID := testutil.CreateUser(t, db, "tagger", nil)
+
+	first := testutil.CreateFile(t, db, userID, "first.txt", []byte("tags first "+uuid.NewString()))
+	second := testutil.CreateFile(t, db, userID, "second.txt", []byte("tags second "+uuid.NewString()))
+	db.Exec(ctx, "UPDATE files SET tags = '{draft,work}' WHERE id = $1", first)
+	db.Exec(ctx, "UPDATE files SET tags = '{draft,final}' WHERE id = $1", second)
+
+	t.Run("add de-duplicates", func(t *testing.T) {
+		file, err := service.AddTags(ctx, first, userID, []string{"work", "review", " review ", ""})
+		if err != nil {
+			t.Fatalf("AddTags failed: %v", err)
+		}
+		want := []string{"draft", "work", "review"}
+		if fmt.Sprint([]string(file.Tags)) != fmt.Sprint(want) {
+			t.Errorf("Expected tags %v, got %v", want, file.Tags)
+		}
+	})
+
+	t.Run("remove keeps other tags", func(t *testing.T) {
+		file, err := service.RemoveTags(ctx, first, userID, []string{"work", "missing"})
+		if err != nil {
+			t.Fatalf("RemoveTags failed: %v", err)
+		}
+		want := []string{"draft", "review"}
+		if fmt.Sprint([]string(file.Tags)) != fmt.Sprint(want) {
+			t.Errorf("Expected tags %v, got %v", want, file.Tags)
+		}
+	})
+
+	t.Run("adding present tags is a no-op", func(t *testing.T) {
+		file, err := service.AddTags(ctx, first, userID, []string{"draft", "review"})
+		if err != nil {
+			t.Fatalf("AddTags failed: %v", err)
+		}
+		want := []string{"draft", "review"}
+		if fmt.Sprint([]string(file.Tags)) != fmt.Sprint(want) {
+			t.Errorf("Expected tags %v, got %v", want, file.Tags)
+		}
+	})
+
+	t.Run("removing absent tags is a no-op", func(t *testing.T) {
+		file, err := service.RemoveTags(ctx, first, userID, []string{"missing"})
+		if err != nil {
+			t.Fatalf("RemoveTags failed: %v", err)
+		}
+		want := []string{"draft", "review"}
+		if fmt.Sprint([]string(file.Tags)) != fmt.Sprint(want) {
+			t.Errorf("Expected tags %v, got %v", want, file.Tags)
+		}
+	})
+
+	t.Run("rename updates every file", func(t *testing.T) {
+		updated, err := service.RenameTag(ctx, userID, "draft", "final")
+		if err != nil {
+			t.Fatalf("RenameTag failed: %v", err)
+		}
+		if updated != 2 {
+			t.Errorf("Expected 2 files to be updated, got %d", updated)
+		}
+
+		want := map[uuid.UUID]string{
+			first:  fmt.Sprint([]string{"final", "review"}),
+			second: fmt.Sprint([]string{"final"}),
+		}
+		for fileID, tags := range want {
+			file, err := service.GetFileByID(ctx, fileID, userID)
+			if err != nil {
+				t.Fatalf("GetFileByID failed: %v", err)
+			}
+			if fmt.Sprint([]string(file.Tags)) != tags {
+				t.Errorf("Expected tags %s on %s, got %v", tags, fileID, file.Tags)
+			}
+		}
+	})
+
+	t.Run("counts", func(t *testing.T) {
+		tags, err := service.GetUserTags(ctx, userID, "", 0)
+		if err != nil {
+			t.Fatalf("GetUserTags failed: %v", err)
+		}
+		if len(tags) != 2 || tags[0].Tag != "final" || tags[0].Count != 2 || tags[1].Tag != "review" || tags[1].Count != 1 {
+			t.Errorf("Expected [final:2 review:1], got %+v", tags)
+		}
+	})
+
+	t.Run("other users' files are untouched", func(t *testing.T) {
+		other := testutil.CreateUser(t, db, "other", nil)
+		if _, err := service.AddTags(ctx, first, other, []string{"stolen"}); err == nil {
+			t.Error("Expected tagging another user's file to fail")
+		}
+	})
+}
+
+func TestSimpleFileService_GetUserTags(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+	ctx := context.Background()
+	userID := testutil.CreateUser(t, db, "autocomplete", nil)
+
+	t.Run("no tags", func(t *testing.T) {
+		tags, err := service.GetUserTags(ctx, userID, "", 0)
+		if err != nil {
+			t.Fatalf("GetUserTags failed: %v", err)
+		}
+		if tags == nil || len(tags) != 0 {
+			t.Errorf("Expected an empty tag list, got %v", tags)
+		}
+	})
+
+	// Distinct counts keep the order independent of the database collation
+	files := map[string]string{
+		"a.txt": "{plan,pro_forma,projection,Project}",
+		"b.txt": "{plan,pro_forma,projection}",
+		"c.txt": "{plan,pro_forma}",
+		"d.txt": "{plan}",
+		"e.txt": "{}",
+	}
+	for name, tags := range files {
+		fileID := testutil.CreateFile(t, db, userID, name, []byte("tags "+name+" "+uuid.NewString()))
+		db.Exec(ctx, "UPDATE files SET tags = $1 WHERE id = $2", tags, fileID)
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		limit  int
+		want   string
+	}{
+		{"all tags by frequency", "", 0, "[plan:4 pro_forma:3 projection:2 Project:1]"},
+		{"prefix ignores case", "PROJ", 0, "[projection:2 Project:1]"},
+		{"wildcards match literally", "pro_", 0, "[pro_forma:3]"},
+		{"limit", "p", 2, "[plan:4 pro_forma:3]"},
+		{"no match", "zzz", 0, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := service.GetUserTags(ctx, userID, tt.prefix, tt.limit)
+			if err != nil {
+				t.Fatalf("GetUserTags failed: %v", err)
+			}
+			got := make([]string, len(tags))
+			for i, tag := range tags {
+				got[i] = fmt.Sprintf("%s:%d", tag.Tag, tag.Count)
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("Expected %s, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSimpleFileService_GetDuplicateFiles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+	userID := testutil.CreateUser(t, db, "hoarder", nil)
+
+	shared := []byte("same bytes " + uuid.NewString())
+	original := testutil.CreateFile(t, db, userID, "report.pdf", shared)
+	copyID := testutil.CreateFile(t, db, userID, "report (copy).pdf", shared)
+	testutil.CreateFile(t, db, userID, "unique.txt", []byte("one of a kind "+uuid.NewString()))
+
+	// Someone else holding the same content is not the user's duplicate
+	other := testutil.CreateUser(t, db, "neighbour", nil)
+	testutil.CreateFile(t, db, other, "theirs.pdf", shared)
+
+	groups, err := service.GetDuplicateFiles(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetDuplicateFiles failed: %v", err)
+	}
+
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 duplicate group, got %d", len(groups))
+	}
+	group := groups[0]
+	if group.ContentHash != fmt.Sprintf("%x", sha256.Sum256(shared)) {
+		t.Errorf("Expected the shared content's hash, got %s", group.ContentHash)
+	}
+	if len(group.Files) != 2 {
+		t.Fatalf("Expected 2 files in the group, got %d", len(group.Files))
+	}
+	members := map[uuid.UUID]bool{group.Files[0].ID: true, group.Files[1].ID: true}
+	if !members[original] || !members[copyID] {
+		t.Errorf("Expected the group to hold %s and %s, got %s and %s", original, copyID, group.Files[0].ID, group.Files[1].ID)
+	}
+}
+
+func TestSimpleFileService_UploadEnforcesEnterpriseMimeTypes(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	member := testutil.CreateUser(t, db, "restricted", &enterpriseID)
+	db.Exec(context.Background(), `
+		UPDATE enterprises SET settings = jsonb_build_object($1::text, jsonb_build_array('application/x-msdownload'))
+		WHERE id = $2`, BlockedMimeTypesSettingKey, enterpriseID)
+
+	// A Windows executable, declared as a PDF to slip past a check on the declared type
+	exe := append([]byte("MZ\x90\x00"), []byte("program "+uuid.NewString())...)
+	cleanupContent(t, db, exe)
+
+	_, err := service.UploadFile(context.Background(), member, "invoice.pdf", "application/pdf", exe, nil, nil, nil, nil)
+	var unsupported *UnsupportedMediaTypeError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("Expected an UnsupportedMediaTypeError for the executable, got: %v", err)
+	}
+	if unsupported.MimeType != "application/vnd.microsoft.portable-executable" {
+		t.Errorf("Expected the detected executable type, got %s", unsupported.MimeType)
+	}
+
+	pdf := []byte("%PDF-1.4\n" + uuid.NewString())
+	cleanupContent(t, db, pdf)
+
+	file, err := service.UploadFile(context.Background(), member, "report.pdf", "application/octet-stream", pdf, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected the PDF to be allowed, got: %v", err)
+	}
+	if file.MimeType != "application/pdf" {
+		t.Errorf("Expected the detected type to be stored, got %s", file.MimeType)
+	}
+
+	// Personal users have no restrictions
+	personal := testutil.CreateUser(t, db, "personal", nil)
+	if _, err := service.UploadFile(context.Background(), personal, "tool.exe", "application/x-msdownload", exe, nil, nil, nil, nil); err != nil {
+		t.Errorf("Expected a personal user to upload the executable, got: %v", err)
+	}
+}
+
+func TestSimpleFileService_DeleteFileWritesAuditLog(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	store, _ := testutil.NewLocalStorage(t)
+	service := NewSimpleFileService(db, store, nil, NewAuditService(db, zap.NewNop()), zap.NewNop())
+	userID := testutil.CreateUser(t, db, "deleter", nil)
+
+	content := []byte("delete me " + uuid.NewString())
+	cleanupContent(t, db, content)
+	file, err := service.UploadFile(context.Background(), userID, "minutes.txt", "text/plain", content, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	if err := service.DeleteFile(context.Background(), file.ID, userID); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+
+	var resourceName string
+	if err := db.QueryRow(context.Background(), `
+		SELECT resource_name FROM audit_logs WHERE resource_id = $1 AND action = 'FILE_DELETE'`,
+		file.ID).Scan(&resourceName); err != nil {
+		t.Fatalf("Expected a FILE_DELETE audit entry: %v", err)
+	}
+	if resourceName != "minutes.txt" {
+		t.Errorf("Expected the audit entry to name the deleted file, got '%s'", resourceName)
+	}
+}
+
+func TestSimpleFileService_DeleteFileStorageFailureIsQueued(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	store, storageDir := testutil.NewLocalStorage(t)
+	core, logs := observer.New(zap.WarnLevel)
+	service := NewSimpleFileService(db, store, nil, nil, zap.New(core))
+	userID := testutil.CreateUser(t, db, "unlucky", nil)
+
+	content := []byte("stuck object " + uuid.NewString())
+	cleanupContent(t, db, content)
+	file, err := service.UploadFile(context.Background(), userID, "stuck.txt", "text/plain", content, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	// A non-empty directory where the object was makes the storage delete fail
+	objectPath := storage.ContentPath("", userID.String(), file.ContentHash)
+	t.Cleanup(func() {
+		db.Exec(context.Background(), "DELETE FROM storage_deletions WHERE file_path = $1", objectPath)
+	})
+	fullPath := filepath.Join(storageDir, objectPath)
+	os.Remove(fullPath)
+	os.MkdirAll(filepath.Join(fullPath, "blocker"), 0755)
+
+	if err := service.DeleteFile(context.Background(), file.ID, userID); err != nil {
+		t.Fatalf("Expected the delete to succeed despite the storage failure, got: %v", err)
+	}
+
+	entries := logs.FilterField(zap.String("file_path", objectPath)).All()
+	if len(entries) != 1 || entries[0].Level != zap.ErrorLevel {
+		t.Fatalf("Expected one error log for the failed storage delete, got %d entries", len(entries))
+	}
+
+	var attempts int
+	if err := db.QueryRow(context.Background(), `
+		SELECT attempts FROM storage_deletions WHERE file_path = $1`, objectPath).Scan(&attempts); err != nil {
+		t.Fatalf("Expected the object to be queued for deletion: %v", err)
+	}
+
+	// Once storage recovers, the cleanup job removes the object and empties the queue
+	os.RemoveAll(filepath.Join(fullPath, "blocker"))
+	cleanup := NewOrphanCleanupService(db, store, zap.NewNop())
+	if _, err := cleanup.RetryStorageDeletions(context.Background()); err != nil {
+		t.Fatalf("RetryStorageDeletions failed: %v", err)
+	}
+	if _, err := os.Stat(fullPath); !os.IsNotExist(err) {
+		t.Error("Expected the queued object to be removed")
+	}
+	var queued int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM storage_deletions WHERE file_path = $1", objectPath).Scan(&queued)
+	if queued != 0 {
+		t.Errorf("Expected the queue entry to be removed, found %d", queued)
+	}
+}
+
+func TestSimpleFileService_Favorites(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+	ctx := context.Background()
+
+	userID := testutil.CreateUser(t, db, "collector", nil)
+	owner := testutil.CreateUser(t, db, "owner", nil)
+	ownFile := testutil.CreateFile(t, db, userID, "mine.txt", []byte("favorite own "+uuid.NewString()))
+	sharedFile := testutil.CreateFile(t, db, owner, "theirs.txt", []byte("favorite shared "+uuid.NewString()))
+	testutil.ShareFile(t, db, sharedFile, owner, userID, domain.PermissionView)
+	privateFile := testutil.CreateFile(t, db, owner, "private.txt", []byte("favorite private "+uuid.NewString()))
+
+	content := []byte("favorite deleted " + uuid.NewString())
+	cleanupContent(t, db, content)
+	deletable, err := service.UploadFile(ctx, userID, "old.txt", "text/plain", content, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+
+	favoriteIDs := func() string {
+		t.Helper()
+		files, err := service.GetFavoriteFiles(ctx, userID, 20, 0)
+		if err != nil {
+			t.Fatalf("GetFavoriteFiles failed: %v", err)
+		}
+		count, err := service.CountFavoriteFiles(ctx, userID)
+		if err != nil {
+			t.Fatalf("CountFavoriteFiles failed: %v", err)
+		}
+		if count != len(files) {
+			t.Errorf("Expected the count %d to match the %d listed files", count, len(files))
+		}
+
+		ids := make([]uuid.UUID, len(files))
+		for i, file := range files {
+			if !file.IsStarred {
+				t.Errorf("Expected listed favorite %s to be marked starred", file.ID)
+			}
+			ids[i] = file.ID
+		}
+		return fmt.Sprint(ids)
+	}
+
+	for _, fileID := range []uuid.UUID{ownFile, deletable.ID, sharedFile} {
+		file, err := service.StarFile(ctx, fileID, userID)
+		if err != nil {
+			t.Fatalf("StarFile failed: %v", err)
+		}
+		if !file.IsStarred {
+			t.Error("Expected the starred file to be marked starred")
+		}
+		// Age the earlier favorites so the starred order doesn't depend on timestamp resolution
+		db.Exec(ctx, "UPDATE file_favorites SET created_at = created_at - INTERVAL '1 minute' WHERE user_id = $1 AND file_id <> $2", userID, fileID)
+	}
+
+	t.Run("lists own and shared files, newest first", func(t *testing.T) {
+		if got, want := favoriteIDs(), fmt.Sprint([]uuid.UUID{sharedFile, deletable.ID, ownFile}); got != want {
+			t.Errorf("Expected favorites %s, got %s", want, got)
+		}
+	})
+
+	t.Run("starring twice is a no-op", func(t *testing.T) {
+		if _, err := service.StarFile(ctx, ownFile, userID); err != nil {
+			t.Fatalf("StarFile failed: %v", err)
+		}
+		var rows int
+		db.QueryRow(ctx, "SELECT COUNT(*) FROM file_favorites WHERE user_id = $1 AND file_id = $2", userID, ownFile).Scan(&rows)
+		if rows != 1 {
+			t.Errorf("Expected one favorite row, got %d", rows)
+		}
+	})
+
+	t.Run("cannot star a file without access", func(t *testing.T) {
+		if _, err := service.StarFile(ctx, privateFile, userID); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("Expected ErrFileNotFound, got %v", err)
+		}
+	})
+
+	t.Run("marks starred files", func(t *testing.T) {
+		files := []*domain.File{{ID: ownFile}, {ID: privateFile}}
+		if err := service.MarkStarred(ctx, userID, files); err != nil {
+			t.Fatalf("MarkStarred failed: %v", err)
+		}
+		if !files[0].IsStarred || files[1].IsStarred {
+			t.Errorf("Expected only the starred file to be marked, got %v and %v", files[0].IsStarred, files[1].IsStarred)
+		}
+	})
+
+	t.Run("deleted file leaves favorites", func(t *testing.T) {
+		if err := service.DeleteFile(ctx, deletable.ID, userID); err != nil {
+			t.Fatalf("DeleteFile failed: %v", err)
+		}
+		if got, want := favoriteIDs(), fmt.Sprint([]uuid.UUID{sharedFile, ownFile}); got != want {
+			t.Errorf("Expected favorites %s, got %s", want, got)
+		}
+	})
+
+	t.Run("unstarring", func(t *testing.T) {
+		if err := service.UnstarFile(ctx, sharedFile, userID); err != nil {
+			t.Fatalf("UnstarFile failed: %v", err)
+		}
+		if err := service.UnstarFile(ctx, sharedFile, userID); err != nil {
+			t.Errorf("Expected unstarring a file that isn't starred to succeed, got %v", err)
+		}
+		if got, want := favoriteIDs(), fmt.Sprint([]uuid.UUID{ownFile}); got != want {
+			t.Errorf("Expected favorites %s, got %s", want, got)
+		}
+	})
+}
+
+func TestSimpleFileService_RenameFile(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewSimpleFileService(db, nil, nil, NewAuditService(db, zap.NewNop()), zap.NewNop())
+	ctx := context.Background()
+
+	userID := testutil.CreateUser(t, db, "renamer", nil)
+	fileID := testutil.CreateFile(t, db, userID, "draft.txt", []byte("rename "+uuid.NewString()))
+
+	t.Run("renames", func(t *testing.T) {
+		file, err := service.RenameFile(ctx, fileID, userID, "  Final report.txt ")
+		if err != nil {
+			t.Fatalf("RenameFile failed: %v", err)
+		}
+		if file.OriginalName != "Final report.txt" {
+			t.Errorf("Expected the trimmed name 'Final report.txt', got '%s'", file.OriginalName)
+		}
+		if !strings.HasPrefix(file.Filename, "Final_report_") || !strings.HasSuffix(file.Filename, ".txt") {
+			t.Errorf("Expected a regenerated safe filename, got '%s'", file.Filename)
+		}
+
+		var metadata string
+		if err := db.QueryRow(ctx, `
+			SELECT metadata::text FROM audit_logs WHERE resource_id = $1 AND action = 'FILE_RENAME'`,
+			fileID).Scan(&metadata); err != nil {
+			t.Fatalf("Expected a FILE_RENAME audit entry: %v", err)
+		}
+		if !strings.Contains(metadata, "draft.txt") || !strings.Contains(metadata, "Final report.txt") {
+			t.Errorf("Expected the audit entry to record both names, got %s", metadata)
+		}
+	})
+
+	for _, name := range []string{"", "   ", "..", "reports/q1.txt", `reports\q1.txt`} {
+		t.Run(fmt.Sprintf("rejects %q", name), func(t *testing.T) {
+			if _, err := service.RenameFile(ctx, fileID, userID, name); !errors.Is(err, ErrInvalidFileName) {
+				t.Errorf("Expected ErrInvalidFileName, got %v", err)
+			}
+		})
+	}
+
+	t.Run("rejects another user's file", func(t *testing.T) {
+		other := testutil.CreateUser(t, db, "other", nil)
+		if _, err := service.RenameFile(ctx, fileID, other, "mine.txt"); err == nil {
+			t.Error("Expected renaming another user's file to fail")
+		}
+	})
+}
+
+func TestSimpleFileService_UpdateFile(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewSimpleFileService(db, nil, nil, nil, zap.NewNop())
+	service.SetShortShareCodes(true)
+	ctx := context.Background()
+
+	userID := testutil.CreateUser(t, db, "updater", nil)
+	fileID := testutil.CreateFile(t, db, userID, "plan.txt", []byte("update "+uuid.NewString()))
+
+	visibility := func(v domain.FileVisibility) *domain.FileVisibility { return &v }
+	shareCode := func() *string {
+		var code *string
+		db.QueryRow(ctx, "SELECT share_code FROM files WHERE id = $1", fileID).Scan(&code)
+		return code
+	}
+
+	t.Run("changes the description", func(t *testing.T) {
+		description := "  Quarterly plan "
+		file, err := service.UpdateFile(ctx, fileID, userID, domain.FileUpdateRequest{Description: &description})
+		if err != nil {
+			t.Fatalf("UpdateFile failed: %v", err)
+		}
+		if file.Description == nil || *file.Description != "Quarterly plan" {
+			t.Errorf("Expected description 'Quarterly plan', got %v", file.Description)
+		}
+		if file.Visibility != domain.VisibilityPrivate || file.ShareToken != nil {
+			t.Errorf("Expected the file to stay private without a token, got %s with %v", file.Visibility, file.ShareToken)
+		}
+
+		empty := ""
+		file, err = service.UpdateFile(ctx, fileID, userID, domain.FileUpdateRequest{Description: &empty})
+		if err != nil {
+			t.Fatalf("UpdateFile failed: %v", err)
+		}
+		if file.Description != nil {
+			t.Errorf("Expected an empty description to clear it, got %q", *file.Description)
+		}
+	})
+
+	var token, code string
+	t.Run("making the file public creates a token and a short code", func(t *testing.T) {
+		file, err := service.UpdateFile(ctx, fileID, userID, domain.FileUpdateRequest{Visibility: visibility(domain.VisibilityPublic)})
+		if err != nil {
+			t.Fatalf("UpdateFile failed: %v", err)
+		}
+		if file.Visibility != domain.VisibilityPublic || file.ShareToken == nil || *file.ShareToken == "" {
+			t.Fatalf("Expected a public file with a share token, got %s with %v", file.Visibility, file.ShareToken)
+		}
+		token = *file.ShareToken
+		stored := shareCode()
+		if stored == nil || *stored == "" {
+			t.Fatal("Expected a short share code")
+		}
+		code = *stored
+
+		// Staying public keeps the token already handed out
+		file, err = service.UpdateFile(ctx, fileID, userID, domain.FileUpdateRequest{Visibility: visibility(domain.VisibilityPublic)})
+		if err != nil {
+			t.Fatalf("UpdateFile failed: %v", err)
+		}
+		if file.ShareToken == nil || *file.ShareToken != token {
+			t.Errorf("Expected the token %s to be kept, got %v", token, file.ShareToken)
+		}
+		if stored := shareCode(); stored == nil || *stored != code {
+			t.Errorf("Expected the short code %s to be kept, got %v", code, stored)
+		}
+	})
+
+	t.Run("making the file private clears the token and the short code", func(t *testing.T) {
+		file, err := service.UpdateFile(ctx, fileID, userID, domain.FileUpdateRequest{Visibility: visibility(domain.VisibilityPrivate)})
+		if err != nil {
+			t.Fatalf("UpdateFile failed: %v", err)
+		}
+		if file.Visibility != domain.VisibilityPrivate || file.ShareToken != nil {
+			t.Errorf("Expected a private file without a token, got %s with %v", file.Visibility, file.ShareToken)
+		}
+
+		var stillShared bool
+		db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM files WHERE share_token = $1 OR share_code = $2)", token, code).Scan(&stillShared)
+		if stillShared {
+			t.Error("Expected the old share token and short code to stop matching any file")
+		}
+	})
+
+	t.Run("making the file public again hands out new links", func(t *testing.T) {
+		file, err := service.UpdateFile(ctx, fileID, userID, domain.FileUpdateRequest{Visibility: visibility(domain.VisibilityPublic)})
+		if err != nil {
+			t.Fatalf("UpdateFile failed: %v", err)
+		}
+		if file.ShareToken == nil || *file.ShareToken == token {
+			t.Errorf("Expected a new share token, got %v", file.ShareToken)
+		}
+		if stored := shareCode(); stored == nil || *stored == code {
+			t.Errorf("Expected a new short code, got %v", stored)
+		}
+	})
+
+	t.Run("rejects an unknown visibility", func(t *testing.T) {
+		if _, err := service.UpdateFile(ctx, fileID, userID, domain.FileUpdateRequest{Visibility: visibility("EVERYONE")}); !errors.Is(err, ErrInvalidVisibility) {
+			t.Errorf("Expected an unknown visibility to be rejected with ErrInvalidVisibility, got %v", err)
+		}
+	})
+
+	t.Run("rejects another user's file", func(t *testing.T) {
+		other := testutil.CreateUser(t, db, "other", nil)
+		if _, err := service.UpdateFile(ctx, fileID, other, domain.FileUpdateRequest{Visibility: visibility(domain.VisibilityPublic)}); err == nil {
+			t.Error("Expected updating another user's file to fail")
+		}
+	})
+}
+
+func TestSimpleFileService_GetDownloadStats(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+	ctx := context.Background()
+
+	owner := testutil.CreateUser(t, db, "popular", nil)
+	visitor := testutil.CreateUser(t, db, "visitor", nil)
+	fileID := testutil.CreateFile(t, db, owner, "chart.txt", []byte("chart "+uuid.NewString()))
+
+	// Seeded history: two downloads three days ago, a preview the day before yesterday, and one
+	// download older than the range
+	today := time.Now().UTC().Truncate(24 * time.Hour).Add(12 * time.Hour)
+	seed := func(accessType domain.FileAccessType, at time.Time) {
+		if _, err := db.Exec(ctx, `
+			INSERT INTO file_access_events (file_id, user_id, access_type, accessed_at)
+			VALUES ($1, $2, $3, $4)`, fileID, visitor, accessType, at); err != nil {
+			t.Fatalf("Failed to seed access event: %v", err)
+		}
+	}
+	seed(domain.FileAccessDownload, today.AddDate(0, 0, -3))
+	seed(domain.FileAccessDownload, today.AddDate(0, 0, -3))
+	seed(domain.FileAccessPreview, today.AddDate(0, 0, -2))
+	seed(domain.FileAccessDownload, today.AddDate(0, 0, -10))
+
+	// Today's accesses go through the recording paths, one of them anonymous
+	if err := service.RecordDownload(ctx, fileID, nil, "192.0.2.1"); err != nil {
+		t.Fatalf("RecordDownload failed: %v", err)
+	}
+	if err := service.RecordPreview(ctx, fileID, &visitor, ""); err != nil {
+		t.Fatalf("RecordPreview failed: %v", err)
+	}
+
+	stats, err := service.GetDownloadStats(ctx, fileID, 4)
+	if err != nil {
+		t.Fatalf("GetDownloadStats failed: %v", err)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("Expected 4 daily buckets, got %d", len(stats))
+	}
+
+	want := []struct{ downloads, previews int }{{2, 0}, {0, 1}, {0, 0}, {1, 1}}
+	for i, day := range stats {
+		wantDate := today.AddDate(0, 0, i-3).Format("2006-01-02")
+		if got := day.Date.Format("2006-01-02"); got != wantDate {
+			t.Errorf("Expected bucket %d to be %s, got %s", i, wantDate, got)
+		}
+		if day.Downloads != want[i].downloads || day.Previews != want[i].previews {
+			t.Errorf("Expected %s to have %d downloads and %d previews, got %d and %d",
+				wantDate, want[i].downloads, want[i].previews, day.Downloads, day.Previews)
+		}
+	}
+
+	var downloadCount int
+	db.QueryRow(ctx, "SELECT download_count FROM files WHERE id = $1", fileID).Scan(&downloadCount)
+	if downloadCount != 1 {
+		t.Errorf("Expected only the recorded download to be counted, got %d", downloadCount)
+	}
+}
+
+func TestSimpleFileService_RecentAndPopularFiles(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+	ctx := context.Background()
+
+	owner := testutil.CreateUser(t, db, "recent", nil)
+	stranger := testutil.CreateUser(t, db, "stranger", nil)
+	older := testutil.CreateFile(t, db, owner, "older.txt", []byte("older "+uuid.NewString()))
+	newer := testutil.CreateFile(t, db, owner, "newer.txt", []byte("newer "+uuid.NewString()))
+	unshared := testutil.CreateFile(t, db, stranger, "unshared.txt", []byte("unshared "+uuid.NewString()))
+	db.Exec(ctx, "UPDATE files SET upload_date = NOW() - INTERVAL '2 hours' WHERE id = $1", older)
+	db.Exec(ctx, "UPDATE files SET upload_date = NOW() - INTERVAL '1 hour' WHERE id = $1", newer)
+
+	ids := func(files []*domain.File) []uuid.UUID {
+		var fileIDs []uuid.UUID
+		for _, file := range files {
+			fileIDs = append(fileIDs, file.ID)
+		}
+		return fileIDs
+	}
+
+	recent, err := service.GetRecentFiles(ctx, owner, 10)
+	if err != nil {
+		t.Fatalf("GetRecentFiles failed: %v", err)
+	}
+	if got := ids(recent); !slices.Equal(got, []uuid.UUID{newer, older}) {
+		t.Errorf("Expected the newer upload first, got %v", got)
+	}
+
+	// Downloading the older file brings it to the top; anonymous downloads and files the user
+	// can't reach don't count
+	service.RecordDownload(ctx, older, &owner, "")
+	service.RecordDownload(ctx, newer, nil, "")
+	service.RecordDownload(ctx, newer, nil, "")
+	service.RecordPreview(ctx, unshared, &owner, "")
+
+	recent, err = service.GetRecentFiles(ctx, owner, 10)
+	if err != nil {
+		t.Fatalf("GetRecentFiles failed: %v", err)
+	}
+	if got := ids(recent); !slices.Equal(got, []uuid.UUID{older, newer}) {
+		t.Errorf("Expected the freshly downloaded file first, got %v", got)
+	}
+
+	popular, err := service.GetPopularFiles(ctx, owner, 10)
+	if err != nil {
+		t.Fatalf("GetPopularFiles failed: %v", err)
+	}
+	if got := ids(popular); !slices.Equal(got, []uuid.UUID{newer, older}) {
+		t.Errorf("Expected the most downloaded file first, got %v", got)
+	}
+}
+
+func TestSimpleFileService_RecentFilesLastAccess(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+	ctx := context.Background()
+
+	reader := testutil.CreateUser(t, db, "reader", nil)
+	owner := testutil.CreateUser(t, db, "sharer", nil)
+	own := testutil.CreateFile(t, db, reader, "own.txt", []byte("own "+uuid.NewString()))
+	shared := testutil.CreateFile(t, db, owner, "shared.txt", []byte("shared "+uuid.NewString()))
+	testutil.ShareFile(t, db, shared, owner, reader, domain.PermissionView)
+	db.Exec(ctx, "UPDATE files SET upload_date = NOW() - INTERVAL '3 days' WHERE id = $1", own)
+
+	// accessAt records an access and backdates it, so the order doesn't depend on timing
+	accessAt := func(fileID uuid.UUID, record func(context.Context, uuid.UUID, *uuid.UUID, string) error, ago string) time.Time {
+		t.Helper()
+		if err := record(ctx, fileID, &reader, ""); err != nil {
+			t.Fatalf("Recording access failed: %v", err)
+		}
+		var at time.Time
+		db.QueryRow(ctx, `
+			UPDATE file_access_events SET accessed_at = NOW() - $3::interval
+			WHERE id = (SELECT id FROM file_access_events WHERE file_id = $1 AND user_id = $2 ORDER BY accessed_at DESC, id DESC LIMIT 1)
+			RETURNING accessed_at`, fileID, reader, ago).Scan(&at)
+		return at
+	}
+
+	accessAt(shared, service.RecordDownload, "2 days")
+	latest := accessAt(shared, service.RecordPreview, "1 hour")
+	ownAccess := accessAt(own, service.RecordDownload, "1 day")
+
+	recent, err := service.GetRecentFiles(ctx, reader, 10)
+	if err != nil {
+		t.Fatalf("GetRecentFiles failed: %v", err)
+	}
+	if len(recent) != 2 || recent[0].ID != shared || recent[1].ID != own {
+		t.Fatalf("Expected the shared file once, then the own file, got %+v", recent)
+	}
+	if at := recent[0].LastAccessedAt; at == nil || !at.Equal(latest) {
+		t.Errorf("Expected the shared file's latest access %v, got %v", latest, at)
+	}
+	if at := recent[1].LastAccessedAt; at == nil || !at.Equal(ownAccess) {
+		t.Errorf("Expected the own file's access %v, got %v", ownAccess, at)
+	}
+
+	// Another download of the own file moves it ahead
+	fresh := accessAt(own, service.RecordDownload, "1 minute")
+	recent, err = service.GetRecentFiles(ctx, reader, 10)
+	if err != nil {
+		t.Fatalf("GetRecentFiles failed: %v", err)
+	}
+	if len(recent) != 2 || recent[0].ID != own || !recent[0].LastAccessedAt.Equal(fresh) {
+		t.Errorf("Expected the freshly downloaded file first at %v, got %+v", fresh, recent)
+	}
+}
+
+func TestSimpleFileService_FilesByTag(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := newTestFileService(t, db)
+	ctx := context.Background()
+	owner := testutil.CreateUser(t, db, "tag-owner", nil)
+	other := testutil.CreateUser(t, db, "tag-other", nil)
+
+	tagged := func(userID uuid.UUID, name string, tags ...string) uuid.UUID {
+		fileID := testutil.CreateFile(t, db, userID, name, []byte(name+" "+uuid.NewString()))
+		db.Exec(ctx, "UPDATE files SET tags = $1 WHERE id = $2", tags, fileID)
+		return fileID
+	}
+	exact := tagged(owner, "exact.txt", "q3")
+	superset := tagged(owner, "superset.txt", "finance", "q3")
+	tagged(owner, "prefix.txt", "q3-draft")
+	tagged(owner, "upper.txt", "Q3")
+	tagged(other, "elsewhere.txt", "q3")
+
+	files, err := service.GetFilesByTag(ctx, owner, "q3", 10, 0)
+	if err != nil {
+		t.Fatalf("GetFilesByTag failed: %v", err)
+	}
+	var got []uuid.UUID
+	for _, file := range files {
+		got = append(got, file.ID)
+	}
+	if !slices.Equal(got, []uuid.UUID{exact, superset}) {
+		t.Errorf("Expected only the owner's files tagged exactly q3, got %v", got)
+	}
+	if count, err := service.CountFilesByTag(ctx, owner, "q3"); err != nil || count != 2 {
+		t.Errorf("Expected 2 files tagged q3, got %d, %v", count, err)
+	}
+
+	deleted, err := service.DeleteFilesByTag(ctx, owner, "q3")
+	if err != nil {
+		t.Fatalf("DeleteFilesByTag failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 files deleted, got %d", deleted)
+	}
+	if count, _ := service.CountFilesByTag(ctx, owner, "q3"); count != 0 {
+		t.Errorf("Expected no files tagged q3 after deleting, got %d", count)
+	}
+	if count, _ := service.CountFilesByTag(ctx, other, "q3"); count != 1 {
+		t.Error("Expected another user's tagged file to survive")
+	}
+	if count, _ := service.CountFilesByTag(ctx, owner, "q3-draft"); count != 1 {
+		t.Error("Expected files with other tags to survive")
+	}
+}
+
+func TestSimpleFileService_EncryptedUpload(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	store, _ := testutil.NewLocalStorage(t)
+	service := NewSimpleFileService(db, store, nil, nil, zap.NewNop())
+	ctx := context.Background()
+	userID := testutil.CreateUser(t, db, "cipher", nil)
+
+	// Random-looking bytes the server can't make sense of, as a client cipher would produce
+	ciphertext := []byte("\x8f\x1c\x02ciphertext " + uuid.NewString())
+	cleanupContent(t, db, ciphertext)
+	encryption := domain.FileEncryption{Algorithm: "AES-256-GCM", IV: "q83vEjRWeJCrze8S", WrappedKey: "c2VjcmV0LXdyYXBwZWQta2V5"}
+
+	file, err := service.UploadEncryptedFile(ctx, userID, "tax-return.pdf", "application/pdf", ciphertext, encryption, nil)
+	if err != nil {
+		t.Fatalf("UploadEncryptedFile failed: %v", err)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		if file.MimeType != "application/pdf" || file.ContentHash != fmt.Sprintf("%x", sha256.Sum256(ciphertext)) {
+			t.Errorf("Expected the declared type and the ciphertext's hash, got %s and %s", file.MimeType, file.ContentHash)
+		}
+		stored, err := storage.ReadAll(ctx, store, storage.ContentPath("", userID.String(), file.ContentHash))
+		if err != nil || string(stored) != string(ciphertext) {
+			t.Errorf("Expected the ciphertext to be stored as it was uploaded, got %q (%v)", stored, err)
+		}
+		loaded, err := service.GetEncryption(ctx, file.ID)
+		if err != nil || loaded == nil || *loaded != encryption {
+			t.Errorf("Expected the encryption metadata back, got %+v (%v)", loaded, err)
+		}
+
+		plain := testutil.CreateFile(t, db, userID, "notes.txt", []byte("plain "+uuid.NewString()))
+		files := []*domain.File{{ID: file.ID}, {ID: plain}}
+		if err := service.MarkEncrypted(ctx, files); err != nil {
+			t.Fatalf("MarkEncrypted failed: %v", err)
+		}
+		if files[0].Encryption == nil || files[1].Encryption != nil {
+			t.Errorf("Expected only the encrypted file to be marked, got %+v and %+v", files[0].Encryption, files[1].Encryption)
+		}
+	})
+
+	t.Run("identical ciphertext is stored once", func(t *testing.T) {
+		again, err := service.UploadEncryptedFile(ctx, userID, "tax-return (copy).pdf", "application/pdf", ciphertext, encryption, nil)
+		if err != nil {
+			t.Fatalf("UploadEncryptedFile failed: %v", err)
+		}
+
+		var references int
+		db.QueryRow(ctx, "SELECT reference_count FROM file_contents WHERE content_hash = $1", file.ContentHash).Scan(&references)
+		if references != 2 {
+			t.Errorf("Expected both files to reference one content, got %d references", references)
+		}
+
+		groups, err := service.GetDuplicateFiles(ctx, userID)
+		if err != nil {
+			t.Fatalf("GetDuplicateFiles failed: %v", err)
+		}
+		if len(groups) != 1 || groups[0].ContentHash != file.ContentHash || len(groups[0].Files) != 2 {
+			t.Fatalf("Expected the two uploads grouped as duplicates, got %+v", groups)
+		}
+		members := map[uuid.UUID]bool{groups[0].Files[0].ID: true, groups[0].Files[1].ID: true}
+		if !members[file.ID] || !members[again.ID] {
+			t.Errorf("Expected the group to hold %s and %s", file.ID, again.ID)
+		}
+	})
+
+	t.Run("metadata is validated", func(t *testing.T) {
+		for _, invalid := range []domain.FileEncryption{
+			{IV: encryption.IV, WrappedKey: encryption.WrappedKey},
+			{Algorithm: "AES-256-GCM", IV: "not base64!", WrappedKey: encryption.WrappedKey},
+			{Algorithm: "AES-256-GCM", IV: encryption.IV},
+		} {
+			if _, err := service.UploadEncryptedFile(ctx, userID, "bad.bin", "application/octet-stream", ciphertext, invalid, nil); !errors.Is(err, ErrInvalidEncryption) {
+				t.Errorf("Expected ErrInvalidEncryption for %+v, got %v", invalid, err)
+			}
+		}
+	})
+}
+
+func TestSimpleFileService_DeleteStoredObjectInUse(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	store, storageDir := testutil.NewLocalStorage(t)
+	service := NewSimpleFileService(db, store, nil, nil, zap.NewNop())
+	userID := testutil.CreateUser(t, db, "stored-again", nil)
+
+	content := []byte("stored again " + uuid.NewString())
+	cleanupContent(t, db, content)
+	file, err := service.UploadFile(context.Background(), userID, "again.txt", "text/plain", content, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	objectPath := storage.ContentPath("", userID.String(), file.ContentHash)
+
+	// A delete that lost the race with an upload of the same content finds the path in use again
+	service.deleteStoredObject(context.Background(), objectPath)
+
+	if _, err := os.Stat(filepath.Join(storageDir, objectPath)); err != nil {
+		t.Errorf("Expected the object live content points at to be kept, got: %v", err)
+	}
+	var queued int
+	db.QueryRow(context.Background(), "SELECT COUNT(*) FROM storage_deletions WHERE file_path = $1", objectPath).Scan(&queued)
+	if queued != 0 {
+		t.Errorf("Expected the queue entry to be dropped, found %d", queued)
+	}
+
+	if err := service.DeleteFile(context.Background(), file.ID, userID); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storageDir, objectPath)); !os.IsNotExist(err) {
+		t.Errorf("Expected the object to be deleted with its last file, got: %v", err)
+	}
+}
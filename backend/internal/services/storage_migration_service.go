@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/storage"
+	"lokr-backend/pkg/hash"
+)
+
+// StorageMigrationOptions controls a storage migration run
+type StorageMigrationOptions struct {
+	UserID *uuid.UUID // Only migrate content this user's files point at
+	DryRun bool       // Report what would be copied without writing anything
+}
+
+// StorageMigrationReport summarises a storage migration run
+type StorageMigrationReport struct {
+	Copied       int      // Objects copied, or that would be copied in a dry run
+	Skipped      int      // Objects already at the destination with the right content
+	PathsUpdated int      // Content records whose file_path was rewritten for the destination
+	Failed       []string // Content hashes that couldn't be migrated
+}
+
+// StorageMigrationService copies stored content from one storage backend to another
+type StorageMigrationService struct {
+	db          *pgxpool.Pool
+	source      storage.StorageService
+	destination storage.StorageService
+	logger      *zap.Logger
+}
+
+func NewStorageMigrationService(db *pgxpool.Pool, source, destination storage.StorageService, logger *zap.Logger) *StorageMigrationService {
+	return &StorageMigrationService{
+		db:          db,
+		source:      source,
+		destination: destination,
+		logger:      logger,
+	}
+}
+
+// migratingContent is a content record to copy, with a mime type from one of its files
+type migratingContent struct {
+	contentHash   string
+	hashAlgorithm string
+	filePath      string
+	mimeType      string
+}
+
+// MigrationPath turns a stored path into the key used on every backend: forward slashes and no
+// leading slash or dot, as local paths written on another OS or by hand may have
+func MigrationPath(filePath string) string {
+	cleaned := path.Clean(strings.ReplaceAll(filePath, `\`, "/"))
+	return strings.TrimLeft(strings.TrimPrefix(cleaned, "./"), "/")
+}
+
+// Migrate copies every content object to the destination and verifies the copy against its
+// content hash before pointing the content record at it. Objects already at the destination with
+// the right content are skipped, so an interrupted run can simply be started again. The source
+// objects are left in place until the application has been switched over.
+func (s *StorageMigrationService) Migrate(ctx context.Context, opts StorageMigrationOptions) (*StorageMigrationReport, error) {
+	contents, err := s.migratingContents(ctx, opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StorageMigrationReport{}
+	for _, content := range contents {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		destinationPath := MigrationPath(content.filePath)
+		copied, err := s.migrate(ctx, content, destinationPath, opts.DryRun)
+		if err != nil {
+			s.logger.Warn("Failed to migrate stored content",
+				zap.String("content_hash", content.contentHash),
+				zap.String("file_path", content.filePath),
+				zap.Error(err))
+			report.Failed = append(report.Failed, content.contentHash)
+			continue
+		}
+		if copied {
+			report.Copied++
+		} else {
+			report.Skipped++
+		}
+
+		if destinationPath != content.filePath {
+			if !opts.DryRun {
+				if _, err := s.db.Exec(ctx, `
+					UPDATE file_contents SET file_path = $1 WHERE content_hash = $2 AND file_path = $3`,
+					destinationPath, content.contentHash, content.filePath); err != nil {
+					return report, fmt.Errorf("failed to update content path: %w", err)
+				}
+			}
+			report.PathsUpdated++
+		}
+	}
+
+	return report, nil
+}
+
+// migratingContents loads the content records to migrate
+func (s *StorageMigrationService) migratingContents(ctx context.Context, userID *uuid.UUID) ([]migratingContent, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT fc.content_hash, fc.hash_algorithm, fc.file_path,
+			COALESCE((SELECT f.mime_type FROM files f WHERE f.content_hash = fc.content_hash LIMIT 1), 'application/octet-stream')
+		FROM file_contents fc
+		WHERE $1::uuid IS NULL OR EXISTS (SELECT 1 FROM files f WHERE f.content_hash = fc.content_hash AND f.user_id = $1)
+		ORDER BY fc.created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored content: %w", err)
+	}
+	defer rows.Close()
+
+	var contents []migratingContent
+	for rows.Next() {
+		var content migratingContent
+		if err := rows.Scan(&content.contentHash, &content.hashAlgorithm, &content.filePath, &content.mimeType); err != nil {
+			return nil, fmt.Errorf("failed to scan stored content: %w", err)
+		}
+		contents = append(contents, content)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stored content: %w", err)
+	}
+	return contents, nil
+}
+
+// migrate copies one object unless the destination already has it, reporting whether it copied.
+// A copy that doesn't hash to the content hash is removed again.
+func (s *StorageMigrationService) migrate(ctx context.Context, content migratingContent, destinationPath string, dryRun bool) (bool, error) {
+	hasher, err := hash.NewHasher(content.hashAlgorithm)
+	if err != nil {
+		return false, err
+	}
+
+	exists, err := s.destination.Exists(ctx, destinationPath)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		existing, err := storage.Hash(ctx, s.destination, destinationPath, hasher)
+		if err != nil {
+			return false, err
+		}
+		if existing == content.contentHash {
+			return false, nil
+		}
+	}
+	if dryRun {
+		return true, nil
+	}
+
+	reader, err := s.source.Get(ctx, content.filePath)
+	if err != nil {
+		return false, err
+	}
+	err = s.destination.Store(ctx, destinationPath, reader, content.mimeType)
+	reader.Close()
+	if err != nil {
+		return false, err
+	}
+
+	copied, err := storage.Hash(ctx, s.destination, destinationPath, hasher)
+	if err != nil {
+		return false, err
+	}
+	if copied != content.contentHash {
+		if err := s.destination.Delete(ctx, destinationPath); err != nil {
+			s.logger.Warn("Failed to remove a mismatched copy", zap.String("file_path", destinationPath), zap.Error(err))
+		}
+		return false, fmt.Errorf("copied content hashes to %s, not %s", copied, content.contentHash)
+	}
+	return true, nil
+}
@@ -0,0 +1,119 @@
+//go:build integration
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/storage"
+	"lokr-backend/internal/testutil"
+)
+
+func TestMigrationPath(t *testing.T) {
+	tests := map[string]string{
+		"personal/users/u/h":       "personal/users/u/h",
+		`personal\users\u\h`:       "personal/users/u/h",
+		"./personal/users/u/h":     "personal/users/u/h",
+		"/enterprises/e/users/u/h": "enterprises/e/users/u/h",
+	}
+	for stored, want := range tests {
+		if got := MigrationPath(stored); got != want {
+			t.Errorf("MigrationPath(%q) = %q, want %q", stored, got, want)
+		}
+	}
+}
+
+func TestStorageMigrationService_Migrate(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	source := testutil.NewMemoryStorage()
+	destination := testutil.NewMemoryStorage()
+	files := NewSimpleFileService(db, source, nil, nil, zap.NewNop())
+	ctx := context.Background()
+
+	userID := testutil.CreateUser(t, db, "migrating", nil)
+	upload := func(name string) *domain.File {
+		content := []byte(name + " " + uuid.NewString())
+		cleanupContent(t, db, content)
+		file, err := files.UploadFile(ctx, userID, name, "text/plain", content, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("UploadFile failed: %v", err)
+		}
+		return file
+	}
+	plain := upload("plain.txt")
+	windows := upload("windows.txt")
+	corrupt := upload("corrupt.txt")
+
+	// One object was written by a local backend on Windows; another rotted at the source
+	windowsPath := storage.ContentPath("", userID.String(), windows.ContentHash)
+	stored, _ := source.Object(windowsPath)
+	backslashed := fmt.Sprintf(`personal\users\%s\%s`, userID, windows.ContentHash)
+	source.Store(ctx, backslashed, bytes.NewReader(stored), "text/plain")
+	db.Exec(ctx, "UPDATE file_contents SET file_path = $1 WHERE content_hash = $2", backslashed, windows.ContentHash)
+	corruptPath := storage.ContentPath("", userID.String(), corrupt.ContentHash)
+	source.Store(ctx, corruptPath, bytes.NewReader([]byte("bit rot")), "text/plain")
+
+	migration := NewStorageMigrationService(db, source, destination, zap.NewNop())
+
+	t.Run("dry run writes nothing", func(t *testing.T) {
+		report, err := migration.Migrate(ctx, StorageMigrationOptions{UserID: &userID, DryRun: true})
+		if err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if report.Copied != 3 || report.PathsUpdated != 1 {
+			t.Errorf("Expected 3 copies and 1 path update planned, got %+v", report)
+		}
+		if files, _ := destination.ListFiles(ctx, ""); len(files) != 0 {
+			t.Errorf("Expected nothing written in a dry run, got %d objects", len(files))
+		}
+		var filePath string
+		db.QueryRow(ctx, "SELECT file_path FROM file_contents WHERE content_hash = $1", windows.ContentHash).Scan(&filePath)
+		if filePath != backslashed {
+			t.Errorf("Expected the path to be left alone, got %s", filePath)
+		}
+	})
+
+	t.Run("copies and verifies", func(t *testing.T) {
+		report, err := migration.Migrate(ctx, StorageMigrationOptions{UserID: &userID})
+		if err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if report.Copied != 2 || report.PathsUpdated != 1 || len(report.Failed) != 1 || report.Failed[0] != corrupt.ContentHash {
+			t.Errorf("Expected 2 copies, 1 path update and the corrupt content failed, got %+v", report)
+		}
+
+		for _, file := range []*domain.File{plain, windows} {
+			path := storage.ContentPath("", userID.String(), file.ContentHash)
+			content, ok := destination.Object(path)
+			if !ok || fmt.Sprintf("%x", sha256.Sum256(content)) != file.ContentHash {
+				t.Errorf("Expected %s to be copied intact to %s", file.OriginalName, path)
+			}
+			var filePath string
+			db.QueryRow(ctx, "SELECT file_path FROM file_contents WHERE content_hash = $1", file.ContentHash).Scan(&filePath)
+			if filePath != path {
+				t.Errorf("Expected content path %s, got %s", path, filePath)
+			}
+		}
+		if _, ok := destination.Object(corruptPath); ok {
+			t.Error("Expected the mismatched copy to be removed")
+		}
+	})
+
+	t.Run("re-running skips what was copied", func(t *testing.T) {
+		report, err := migration.Migrate(ctx, StorageMigrationOptions{UserID: &userID})
+		if err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if report.Copied != 0 || report.Skipped != 2 || report.PathsUpdated != 0 {
+			t.Errorf("Expected the 2 migrated objects to be skipped, got %+v", report)
+		}
+	})
+}
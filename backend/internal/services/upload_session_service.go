@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/storage"
+	"lokr-backend/pkg/hash"
+)
+
+const (
+	// DefaultUploadChunkSize applies when a resumable upload doesn't ask for a chunk size
+	DefaultUploadChunkSize = 8 << 20
+
+	// maxUploadChunks bounds how many chunks one upload may be split into
+	maxUploadChunks = 10000
+
+	// uploadSessionTTL is how long a session lives after its last chunk before it is abandoned
+	uploadSessionTTL = 24 * time.Hour
+)
+
+var (
+	// ErrUploadSessionNotFound is returned for a session that doesn't exist, has expired or
+	// belongs to someone else
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+
+	// ErrInvalidChunk is returned for a chunk index outside the upload or a chunk of the wrong length
+	ErrInvalidChunk = errors.New("invalid chunk")
+
+	// ErrContentHashMismatch is returned when assembled content doesn't have the hash the client expected
+	ErrContentHashMismatch = errors.New("content hash does not match")
+)
+
+// IncompleteUploadError is returned when completing an upload that is still missing chunks
+type IncompleteUploadError struct {
+	MissingChunks []int
+}
+
+func (e *IncompleteUploadError) Error() string {
+	return fmt.Sprintf("upload is missing %d chunks", len(e.MissingChunks))
+}
+
+// UploadSessionService runs resumable uploads. A client opens a session, sends the file's chunks in
+// any order and as often as it needs to, asks which chunks arrived after a dropped connection and
+// completes the session once it has them all. Chunks are kept as separate storage objects until
+// then; sessions nobody completes expire and are swept away with their chunks.
+type UploadSessionService struct {
+	db      *pgxpool.Pool
+	storage storage.StorageService
+	files   *SimpleFileService
+	logger  *zap.Logger
+}
+
+func NewUploadSessionService(db *pgxpool.Pool, store storage.StorageService, files *SimpleFileService, logger *zap.Logger) *UploadSessionService {
+	return &UploadSessionService{
+		db:      db,
+		storage: store,
+		files:   files,
+		logger:  logger,
+	}
+}
+
+// Initiate opens a session for uploading fileSize bytes in chunks of chunkSize; zero chunkSize uses
+// DefaultUploadChunkSize. The caller checks fileSize against the user's upload limit.
+func (s *UploadSessionService) Initiate(ctx context.Context, userID uuid.UUID, filename, mimeType string, fileSize, chunkSize int64, folderID *uuid.UUID) (*domain.UploadSession, error) {
+	if strings.TrimSpace(filename) == "" {
+		return nil, ErrInvalidFileName
+	}
+	if err := s.files.CheckUpload(filename, mimeType); err != nil {
+		return nil, err
+	}
+	if fileSize <= 0 || chunkSize < 0 {
+		return nil, fmt.Errorf("%w: file and chunk sizes must be positive", ErrInvalidChunk)
+	}
+	if chunkSize == 0 {
+		chunkSize = DefaultUploadChunkSize
+	}
+	if chunkSize > fileSize {
+		chunkSize = fileSize
+	}
+	chunkCount := (fileSize + chunkSize - 1) / chunkSize
+	if chunkCount > maxUploadChunks {
+		return nil, fmt.Errorf("%w: chunks of %d bytes would split the file into more than %d", ErrInvalidChunk, chunkSize, maxUploadChunks)
+	}
+
+	session := &domain.UploadSession{
+		ID:             uuid.New(),
+		UserID:         userID,
+		Filename:       filename,
+		MimeType:       mimeType,
+		FolderID:       folderID,
+		FileSize:       fileSize,
+		ChunkSize:      chunkSize,
+		ChunkCount:     int(chunkCount),
+		ReceivedChunks: []int{},
+	}
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO upload_sessions (id, user_id, filename, mime_type, folder_id, file_size, chunk_size, chunk_count, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW() + $9::interval)
+		RETURNING created_at, expires_at`,
+		session.ID, userID, filename, mimeType, folderID, fileSize, chunkSize, chunkCount, uploadSessionTTL.String()).
+		Scan(&session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload session: %w", err)
+	}
+
+	return session, nil
+}
+
+// Status returns the user's session with the chunks received so far
+func (s *UploadSessionService) Status(ctx context.Context, userID, sessionID uuid.UUID) (*domain.UploadSession, error) {
+	return s.session(ctx, s.db, userID, sessionID, "")
+}
+
+// PutChunk stores the chunk at index from body, replacing one sent before; sending a chunk again
+// after a dropped connection is safe. The body must hold exactly the chunk's length. Each chunk
+// received extends the session's life.
+func (s *UploadSessionService) PutChunk(ctx context.Context, userID, sessionID uuid.UUID, index int, body io.Reader) error {
+	session, err := s.session(ctx, s.db, userID, sessionID, "")
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= session.ChunkCount {
+		return fmt.Errorf("%w: index %d is outside chunks 0-%d", ErrInvalidChunk, index, session.ChunkCount-1)
+	}
+
+	// Read one byte past the expected length to tell an oversized chunk from an exact one
+	length := session.ChunkLength(index)
+	content, err := io.ReadAll(io.LimitReader(body, length+1))
+	if err != nil {
+		return fmt.Errorf("failed to read chunk: %w", err)
+	}
+	if int64(len(content)) != length {
+		return fmt.Errorf("%w: chunk %d must be %d bytes", ErrInvalidChunk, index, length)
+	}
+
+	if err := s.storage.Store(ctx, s.storage.Key(storage.UploadChunkPath(sessionID.String(), index)), bytes.NewReader(content), "application/octet-stream"); err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+
+	// The session may have completed or expired while the chunk was stored; recording then fails
+	tag, err := s.db.Exec(ctx, `
+		WITH session AS (
+			UPDATE upload_sessions SET expires_at = NOW() + $4::interval
+			WHERE id = $1 AND expires_at > NOW()
+			RETURNING id
+		)
+		INSERT INTO upload_session_chunks (session_id, chunk_index, chunk_size, received_at)
+		SELECT id, $2, $3, NOW() FROM session
+		ON CONFLICT (session_id, chunk_index) DO UPDATE SET chunk_size = EXCLUDED.chunk_size, received_at = NOW()`,
+		sessionID, index, length, uploadSessionTTL.String())
+	if err != nil {
+		return fmt.Errorf("failed to record chunk: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUploadSessionNotFound
+	}
+
+	return nil
+}
+
+// Complete assembles the chunks in order and creates the file through the regular upload path,
+// which hashes, deduplicates and applies quota and type checks. With an expectedHash, a SHA-256 of
+// the whole file, assembled content that doesn't match is refused and the session kept so the
+// client can send chunks again. The session and its chunks are removed once the file exists.
+func (s *UploadSessionService) Complete(ctx context.Context, userID, sessionID uuid.UUID, expectedHash string) (*domain.File, error) {
+	// Holding the session row stops a second completion, and chunks arriving meanwhile, until this one is done
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	session, err := s.session(ctx, tx, userID, sessionID, "FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+	if missing := session.MissingChunks(); len(missing) > 0 {
+		return nil, &IncompleteUploadError{MissingChunks: missing}
+	}
+
+	var content bytes.Buffer
+	content.Grow(int(session.FileSize))
+	for index := 0; index < session.ChunkCount; index++ {
+		chunk, err := storage.ReadAll(ctx, s.storage, s.storage.Key(storage.UploadChunkPath(sessionID.String(), index)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %d: %w", index, err)
+		}
+		if int64(len(chunk)) != session.ChunkLength(index) {
+			return nil, fmt.Errorf("stored chunk %d has %d bytes, expected %d", index, len(chunk), session.ChunkLength(index))
+		}
+		content.Write(chunk)
+	}
+
+	if expectedHash != "" {
+		if contentHash := hash.SHA256Hash(content.Bytes()); !strings.EqualFold(contentHash, expectedHash) {
+			return nil, fmt.Errorf("%w: assembled content has hash %s", ErrContentHashMismatch, contentHash)
+		}
+	}
+
+	file, err := s.files.UploadFile(ctx, userID, session.Filename, session.MimeType, content.Bytes(), session.FolderID, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM upload_sessions WHERE id = $1", sessionID); err != nil {
+		return nil, fmt.Errorf("failed to remove upload session: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// The file exists now; a chunk that can't be removed is only wasted space
+	s.deleteChunks(ctx, session)
+
+	return file, nil
+}
+
+// Abort discards the user's session and the chunks received for it
+func (s *UploadSessionService) Abort(ctx context.Context, userID, sessionID uuid.UUID) error {
+	session, err := s.session(ctx, s.db, userID, sessionID, "")
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(ctx, "DELETE FROM upload_sessions WHERE id = $1", sessionID); err != nil {
+		return fmt.Errorf("failed to remove upload session: %w", err)
+	}
+	s.deleteChunks(ctx, session)
+	return nil
+}
+
+// DeleteExpired removes sessions past their expiry together with their chunks and returns how
+// many were removed
+func (s *UploadSessionService) DeleteExpired(ctx context.Context) (int64, error) {
+	// RETURNING still sees the chunk rows that the delete cascades to
+	rows, err := s.db.Query(ctx, `
+		DELETE FROM upload_sessions s WHERE s.expires_at <= NOW()
+		RETURNING s.id, ARRAY(SELECT c.chunk_index FROM upload_session_chunks c WHERE c.session_id = s.id)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired upload sessions: %w", err)
+	}
+
+	var expired []*domain.UploadSession
+	for rows.Next() {
+		session := &domain.UploadSession{}
+		if err := rows.Scan(&session.ID, &session.ReceivedChunks); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan upload session: %w", err)
+		}
+		expired = append(expired, session)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to delete expired upload sessions: %w", err)
+	}
+
+	for _, session := range expired {
+		s.deleteChunks(ctx, session)
+	}
+
+	return int64(len(expired)), nil
+}
+
+// RunSessionSweeper deletes expired sessions immediately and then on every interval until ctx is cancelled
+func (s *UploadSessionService) RunSessionSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.DeleteExpired(ctx)
+		if err != nil {
+			s.logger.Error("Failed to delete expired upload sessions", zap.Error(err))
+		} else if deleted > 0 {
+			s.logger.Info("Deleted expired upload sessions", zap.Int64("deleted", deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// session loads the user's unexpired session with its received chunks; lock is appended to the
+// session query, e.g. FOR UPDATE
+func (s *UploadSessionService) session(ctx context.Context, q rowQuerier, userID, sessionID uuid.UUID, lock string) (*domain.UploadSession, error) {
+	session := &domain.UploadSession{}
+	err := q.QueryRow(ctx, `
+		SELECT id, user_id, filename, mime_type, folder_id, file_size, chunk_size, chunk_count, created_at, expires_at
+		FROM upload_sessions
+		WHERE id = $1 AND user_id = $2 AND expires_at > NOW() `+lock,
+		sessionID, userID).Scan(
+		&session.ID, &session.UserID, &session.Filename, &session.MimeType, &session.FolderID,
+		&session.FileSize, &session.ChunkSize, &session.ChunkCount, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUploadSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT chunk_index FROM upload_session_chunks WHERE session_id = $1 ORDER BY chunk_index`,
+		sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get received chunks: %w", err)
+	}
+	defer rows.Close()
+
+	session.ReceivedChunks = []int{}
+	for rows.Next() {
+		var index int
+		if err := rows.Scan(&index); err != nil {
+			return nil, fmt.Errorf("failed to scan received chunk: %w", err)
+		}
+		session.ReceivedChunks = append(session.ReceivedChunks, index)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get received chunks: %w", err)
+	}
+
+	return session, nil
+}
+
+// deleteChunks removes the received chunk objects of a session, logging the ones that fail
+func (s *UploadSessionService) deleteChunks(ctx context.Context, session *domain.UploadSession) {
+	for _, index := range session.ReceivedChunks {
+		if err := s.storage.Delete(ctx, s.storage.Key(storage.UploadChunkPath(session.ID.String(), index))); err != nil {
+			RequestLogger(ctx, s.logger).Warn("Failed to delete upload chunk",
+				zap.String("sessionId", session.ID.String()),
+				zap.Int("chunk", index),
+				zap.Error(err))
+		}
+	}
+}
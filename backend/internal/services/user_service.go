@@ -2,22 +2,56 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 
 	"lokr-backend/internal/domain"
 )
 
+// DefaultStorageQuota is the storage quota in bytes of new users, matching the schema default,
+// unless SetDefaultQuota changes it
+const DefaultStorageQuota int64 = 10 << 30
+
+// DefaultMemberQuotaSettingKey is the enterprise settings key giving the storage quota in bytes of
+// users who join the enterprise when they register
+const DefaultMemberQuotaSettingKey = "default_member_quota"
+
 type UserService struct {
-	db *pgxpool.Pool
+	db           *pgxpool.Pool
+	defaultQuota int64
 }
 
 func NewUserService(db *pgxpool.Pool) *UserService {
-	return &UserService{db: db}
+	return &UserService{db: db, defaultQuota: DefaultStorageQuota}
+}
+
+// SetDefaultQuota sets the storage quota in bytes of new users whose enterprise doesn't set one
+func (s *UserService) SetDefaultQuota(quota int64) {
+	s.defaultQuota = quota
+}
+
+// memberQuota is the storage quota of a user registering into the enterprise: its
+// default_member_quota setting when it has one, otherwise the system default
+func (s *UserService) memberQuota(ctx context.Context, q rowQuerier, enterpriseID uuid.UUID) (int64, error) {
+	var quota int64
+	err := q.QueryRow(ctx, `
+		SELECT (settings->>'`+DefaultMemberQuotaSettingKey+`')::bigint
+		FROM enterprises
+		WHERE id = $1 AND settings->>'`+DefaultMemberQuotaSettingKey+`' ~ '^[0-9]+$'`, enterpriseID).Scan(&quota)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s.defaultQuota, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load enterprise quota: %w", err)
+	}
+	return quota, nil
 }
 
 func (s *UserService) CreateUser(email, name, password string) (*domain.User, error) {
@@ -33,7 +67,6 @@ func (s *UserService) CreateUser(email, name, password string) (*domain.User, er
 		PasswordHash:   string(hashedPassword),
 		Role:           domain.RoleUser,
 		StorageUsed:    0,
-		StorageQuota:   10 * 1024 * 1024, // 10MB default
 		EmailVerified:  false,
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
@@ -46,6 +79,11 @@ func (s *UserService) CreateUser(email, name, password string) (*domain.User, er
 		return nil, fmt.Errorf("failed to get default enterprise: %w", err)
 	}
 
+	user.StorageQuota, err = s.memberQuota(context.Background(), s.db, enterpriseID)
+	if err != nil {
+		return nil, err
+	}
+
 	user.EnterpriseID = &enterpriseID
 	enterpriseRole := domain.EnterpriseRole("MEMBER")
 	user.EnterpriseRole = &enterpriseRole
@@ -106,8 +144,271 @@ func (s *UserService) GetUserByID(id uuid.UUID) (*domain.User, error) {
 	return user, nil
 }
 
+// GetUsersByIDs loads several users in one query. Unknown ids are skipped, so the result can be
+// shorter than ids and is in no particular order.
+func (s *UserService) GetUsersByIDs(ids []uuid.UUID) ([]*domain.User, error) {
+	query := `
+		SELECT id, email, name, profile_image, password_hash, role, storage_used, storage_quota,
+		       email_verified, last_login_at, enterprise_id, enterprise_role, created_at, updated_at
+		FROM users WHERE id = ANY($1)`
+
+	rows, err := s.db.Query(context.Background(), query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		user := &domain.User{}
+		err := rows.Scan(
+			&user.ID, &user.Email, &user.Name, &user.ProfileImage, &user.PasswordHash,
+			&user.Role, &user.StorageUsed, &user.StorageQuota, &user.EmailVerified,
+			&user.LastLoginAt, &user.EnterpriseID, &user.EnterpriseRole, &user.CreatedAt, &user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// UpdateQuota sets a user's storage quota; it can't be lowered below what the user already stores.
+// Usage is summed from the user's files in the same statement, as storage_used may lag behind.
+func (s *UserService) UpdateQuota(userID uuid.UUID, quota int64) (*domain.User, error) {
+	if quota < 0 {
+		return nil, fmt.Errorf("storage quota cannot be negative")
+	}
+
+	result, err := s.db.Exec(context.Background(), `
+		UPDATE users SET storage_quota = $1, updated_at = NOW()
+		WHERE id = $2 AND (SELECT COALESCE(SUM(file_size), 0) FROM files WHERE user_id = $2) <= $1`,
+		quota, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update storage quota: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		if _, err := s.GetUserByID(userID); err != nil {
+			return nil, err
+		}
+		var used int64
+		err := s.db.QueryRow(context.Background(),
+			"SELECT COALESCE(SUM(file_size), 0) FROM files WHERE user_id = $1", userID).Scan(&used)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute storage usage: %w", err)
+		}
+		return nil, fmt.Errorf("storage quota cannot be lower than current usage of %d bytes", used)
+	}
+
+	return s.GetUserByID(userID)
+}
+
+// RecomputeStorageUsed sets storage_used to the total size of the files each user owns, for one
+// user or, with a nil userID, for everyone. Usage is counted per file, so content a user uploaded
+// twice counts twice. The totals are computed and written in one statement, so the repair can run
+// alongside uploads. Returns the number of users whose stored value was wrong.
+func (s *UserService) RecomputeStorageUsed(ctx context.Context, userID *uuid.UUID) (int64, error) {
+	result, err := s.db.Exec(ctx, `
+		UPDATE users u
+		SET storage_used = usage.total, updated_at = NOW()
+		FROM (
+			SELECT u.id, COALESCE(SUM(f.file_size), 0) AS total
+			FROM users u
+			LEFT JOIN files f ON f.user_id = u.id
+			WHERE $1::uuid IS NULL OR u.id = $1
+			GROUP BY u.id
+		) usage
+		WHERE u.id = usage.id AND u.storage_used <> usage.total`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to recompute storage used: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// GetSystemStats totals users, files, storage and recent uploads across the whole platform, with
+// the topConsumers users storing the most. Files count whatever their status, as storage_used does.
+func (s *UserService) GetSystemStats(ctx context.Context, topConsumers int) (*domain.SystemStats, error) {
+	stats := &domain.SystemStats{}
+	err := s.db.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM users),
+		       COUNT(*),
+		       COALESCE(SUM(file_size), 0),
+		       (SELECT COALESCE(SUM(fc.file_size), 0) FROM file_contents fc
+		        WHERE EXISTS (SELECT 1 FROM files f WHERE f.content_hash = fc.content_hash)),
+		       COUNT(*) FILTER (WHERE upload_date > NOW() - INTERVAL '24 hours'),
+		       COUNT(*) FILTER (WHERE upload_date > NOW() - INTERVAL '7 days'),
+		       COUNT(*) FILTER (WHERE upload_date > NOW() - INTERVAL '30 days')
+		FROM files`).Scan(
+		&stats.TotalUsers, &stats.TotalFiles, &stats.StorageUsed, &stats.StoredSize,
+		&stats.UploadsLast24Hours, &stats.UploadsLast7Days, &stats.UploadsLast30Days,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system stats: %w", err)
+	}
+
+	stats.DeduplicationSavings = stats.StorageUsed - stats.StoredSize
+	if stats.StorageUsed > 0 {
+		stats.DeduplicationSavingsPercentage = float64(stats.DeduplicationSavings) / float64(stats.StorageUsed) * 100
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id, email, name, storage_used, storage_quota
+		FROM users
+		WHERE storage_used > 0
+		ORDER BY storage_used DESC, id
+		LIMIT $1`, topConsumers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top storage consumers: %w", err)
+	}
+	defer rows.Close()
+
+	stats.TopStorageConsumers = []*domain.User{}
+	for rows.Next() {
+		user := &domain.User{}
+		if err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.StorageUsed, &user.StorageQuota); err != nil {
+			return nil, fmt.Errorf("failed to scan storage consumer: %w", err)
+		}
+		stats.TopStorageConsumers = append(stats.TopStorageConsumers, user)
+	}
+
+	return stats, rows.Err()
+}
+
 func (s *UserService) UpdateLastLogin(userID uuid.UUID) error {
 	query := `UPDATE users SET last_login_at = NOW(), updated_at = NOW() WHERE id = $1`
 	_, err := s.db.Exec(context.Background(), query, userID)
 	return err
-}
\ No newline at end of file
+}
+
+// ErrOIDCAccountConflict is returned when the email an identity provider vouches for belongs to a
+// user already linked to a different identity
+var ErrOIDCAccountConflict = errors.New("account is linked to a different sign-in identity")
+
+// LoginWithOIDC returns the user linked to the identity provider's subject, linking it first to
+// the user with the same email or, when there is none, provisioning a new user. New users have no
+// password, so they can only sign in through the provider. A new user joins the enterprise whose
+// verified domain matches their email's, as a MEMBER, if it has a seat free; existing accounts are
+// never moved into one. Callers must check that the provider verified the email before calling.
+func (s *UserService) LoginWithOIDC(ctx context.Context, issuer, subject, email, name string) (*domain.User, error) {
+	email = strings.TrimSpace(email)
+	if subject == "" || email == "" {
+		return nil, fmt.Errorf("identity has no subject or email")
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var userID uuid.UUID
+	provisioned := false
+	err = tx.QueryRow(ctx, "SELECT id FROM users WHERE oidc_issuer = $1 AND oidc_subject = $2",
+		issuer, subject).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var linkedSubject *string
+		err = tx.QueryRow(ctx, "SELECT id, oidc_subject FROM users WHERE LOWER(email) = LOWER($1) FOR UPDATE",
+			email).Scan(&userID, &linkedSubject)
+		switch {
+		case err == nil && linkedSubject != nil:
+			return nil, ErrOIDCAccountConflict
+		case err == nil:
+			_, err = tx.Exec(ctx, `
+				UPDATE users SET oidc_issuer = $1, oidc_subject = $2, email_verified = TRUE, updated_at = NOW()
+				WHERE id = $3`, issuer, subject, userID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to link identity: %w", err)
+			}
+		case errors.Is(err, pgx.ErrNoRows):
+			userID, err = provisionOIDCUser(ctx, tx, issuer, subject, email, name, s.defaultQuota)
+			if err != nil {
+				return nil, err
+			}
+			provisioned = true
+		default:
+			return nil, fmt.Errorf("failed to look up user: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to look up identity: %w", err)
+	}
+
+	if provisioned {
+		joined, err := joinDomainEnterprise(ctx, tx, userID, email)
+		if err != nil {
+			return nil, err
+		}
+		// A user registering here takes the quota of the enterprise they join
+		if joined != uuid.Nil {
+			quota, err := s.memberQuota(ctx, tx, joined)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := tx.Exec(ctx, "UPDATE users SET storage_quota = $1 WHERE id = $2", quota, userID); err != nil {
+				return nil, fmt.Errorf("failed to set storage quota: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit sign-in: %w", err)
+	}
+
+	return s.GetUserByID(userID)
+}
+
+// provisionOIDCUser creates a password-less user linked to the identity with the given storage
+// quota, named after the email's local part when the provider gives no name
+func provisionOIDCUser(ctx context.Context, tx pgx.Tx, issuer, subject, email, name string, quota int64) (uuid.UUID, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+
+	id := uuid.New()
+	_, err := tx.Exec(ctx, `
+		INSERT INTO users (id, email, name, password_hash, role, storage_quota, email_verified, oidc_issuer, oidc_subject)
+		VALUES ($1, $2, $3, '', $4, $5, TRUE, $6, $7)`,
+		id, email, name, domain.RoleUser, quota, issuer, subject)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return id, nil
+}
+
+// joinDomainEnterprise makes the user a MEMBER of the active enterprise whose verified domain
+// matches their email's, taking one of its seats, and returns the enterprise's id. Nothing happens, and uuid.Nil
+// is returned, when no enterprise matches or it is full.
+func joinDomainEnterprise(ctx context.Context, tx pgx.Tx, userID uuid.UUID, email string) (uuid.UUID, error) {
+	_, emailDomain, ok := strings.Cut(email, "@")
+	if !ok || emailDomain == "" {
+		return uuid.Nil, nil
+	}
+
+	var enterpriseID uuid.UUID
+	err := tx.QueryRow(ctx, `
+		UPDATE enterprises SET current_users = current_users + 1, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM enterprises
+			WHERE LOWER(domain) = LOWER($1) AND domain_verified AND subscription_status = 'ACTIVE'
+			ORDER BY created_at, id
+			LIMIT 1
+		) AND current_users < max_users
+		RETURNING id`, emailDomain).Scan(&enterpriseID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, nil
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to join enterprise: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET enterprise_id = $1, enterprise_role = $2, updated_at = NOW()
+		WHERE id = $3`, enterpriseID, domain.EnterpriseRoleMember, userID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to join enterprise: %w", err)
+	}
+	return enterpriseID, nil
+}
@@ -0,0 +1,147 @@
+//go:build integration
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/testutil"
+)
+
+func TestUserService_RegistrationQuota(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewUserService(db)
+	service.SetDefaultQuota(5 << 20)
+	ctx := context.Background()
+
+	t.Run("new users get the configured default", func(t *testing.T) {
+		user, err := service.CreateUser("quota-"+uuid.NewString()[:8]+"@test.lokr.local", "quota", "password123")
+		if err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+		t.Cleanup(func() { db.Exec(ctx, "DELETE FROM users WHERE id = $1", user.ID) })
+
+		var quota int64
+		db.QueryRow(ctx, "SELECT storage_quota FROM users WHERE id = $1", user.ID).Scan(&quota)
+		if user.StorageQuota != 5<<20 || quota != 5<<20 {
+			t.Errorf("Expected a quota of %d, got %d (stored %d)", 5<<20, user.StorageQuota, quota)
+		}
+	})
+
+	t.Run("members inherit their enterprise's quota", func(t *testing.T) {
+		enterpriseID := testutil.CreateEnterprise(t, db)
+		emailDomain := "quota-" + uuid.NewString()[:8] + ".test"
+		db.Exec(ctx, `
+			UPDATE enterprises SET domain = $1, domain_verified = TRUE, max_users = 10, subscription_status = 'ACTIVE',
+			       settings = jsonb_build_object($2::text, 2048)
+			WHERE id = $3`, emailDomain, DefaultMemberQuotaSettingKey, enterpriseID)
+
+		member, err := service.LoginWithOIDC(ctx, "https://issuer.test", uuid.NewString(), "member@"+emailDomain, "Member")
+		if err != nil {
+			t.Fatalf("LoginWithOIDC failed: %v", err)
+		}
+		t.Cleanup(func() { db.Exec(ctx, "DELETE FROM users WHERE id = $1", member.ID) })
+		if member.EnterpriseID == nil || *member.EnterpriseID != enterpriseID || member.StorageQuota != 2048 {
+			t.Errorf("Expected a member of %s with a quota of 2048, got %v with %d", enterpriseID, member.EnterpriseID, member.StorageQuota)
+		}
+
+		outsider, err := service.LoginWithOIDC(ctx, "https://issuer.test", uuid.NewString(), "outsider@elsewhere-"+emailDomain, "Outsider")
+		if err != nil {
+			t.Fatalf("LoginWithOIDC failed: %v", err)
+		}
+		t.Cleanup(func() { db.Exec(ctx, "DELETE FROM users WHERE id = $1", outsider.ID) })
+		if outsider.StorageQuota != 5<<20 {
+			t.Errorf("Expected a user without an enterprise to get the default quota, got %d", outsider.StorageQuota)
+		}
+	})
+}
+
+func TestUserService_LoginWithOIDC_DomainEnterprise(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewUserService(db)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	emailDomain := "sso-" + uuid.NewString()[:8] + ".test"
+	db.Exec(ctx, `
+		UPDATE enterprises SET domain = $1, max_users = 10, subscription_status = 'ACTIVE'
+		WHERE id = $2`, emailDomain, enterpriseID)
+
+	login := func(t *testing.T, email string) *domain.User {
+		t.Helper()
+		user, err := service.LoginWithOIDC(ctx, "https://issuer.test", uuid.NewString(), email, "SSO")
+		if err != nil {
+			t.Fatalf("LoginWithOIDC failed: %v", err)
+		}
+		t.Cleanup(func() { db.Exec(ctx, "DELETE FROM users WHERE id = $1", user.ID) })
+		return user
+	}
+
+	t.Run("new users do not join through an unverified domain", func(t *testing.T) {
+		user := login(t, "unverified-"+uuid.NewString()[:8]+"@"+emailDomain)
+		if user.EnterpriseID != nil {
+			t.Errorf("Expected no enterprise, got %v", user.EnterpriseID)
+		}
+	})
+
+	db.Exec(ctx, "UPDATE enterprises SET domain_verified = TRUE WHERE id = $1", enterpriseID)
+
+	t.Run("new users join through a verified domain", func(t *testing.T) {
+		user := login(t, "verified-"+uuid.NewString()[:8]+"@"+emailDomain)
+		if user.EnterpriseID == nil || *user.EnterpriseID != enterpriseID {
+			t.Errorf("Expected to join %s, got %v", enterpriseID, user.EnterpriseID)
+		}
+	})
+
+	t.Run("existing accounts are linked without joining", func(t *testing.T) {
+		email := "existing-" + uuid.NewString()[:8] + "@" + emailDomain
+		existingID := uuid.New()
+		if _, err := db.Exec(ctx, `
+			INSERT INTO users (id, email, name, password_hash) VALUES ($1, $2, 'existing', 'x')`,
+			existingID, email); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		t.Cleanup(func() { db.Exec(ctx, "DELETE FROM users WHERE id = $1", existingID) })
+
+		user := login(t, email)
+		if user.ID != existingID {
+			t.Fatalf("Expected the existing account %s to be linked, got %s", existingID, user.ID)
+		}
+		if user.EnterpriseID != nil {
+			t.Errorf("Expected the existing account to stay out of the enterprise, got %v", user.EnterpriseID)
+		}
+	})
+}
+
+func TestUserService_UpdateQuota(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	service := NewUserService(db)
+	userID := testutil.CreateUser(t, db, "quota", nil)
+	db.Exec(context.Background(), "UPDATE users SET storage_quota = 2000 WHERE id = $1", userID)
+	// The fixture leaves storage_used at zero, so the check has to count the file itself
+	testutil.CreateFile(t, db, userID, "quota.bin", bytes.Repeat([]byte("q"), 1000))
+
+	if _, err := service.UpdateQuota(userID, 999); err == nil {
+		t.Error("Expected a quota below the size of the user's files to be rejected")
+	}
+	if _, err := service.UpdateQuota(userID, -1); err == nil {
+		t.Error("Expected a negative quota to be rejected")
+	}
+
+	updated, err := service.UpdateQuota(userID, 1000)
+	if err != nil {
+		t.Fatalf("UpdateQuota failed: %v", err)
+	}
+	stored, err := service.GetUserByID(userID)
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if updated.StorageQuota != 1000 || stored.StorageQuota != 1000 {
+		t.Errorf("Expected the quota to be 1000 and stay so, got %d and %d", updated.StorageQuota, stored.StorageQuota)
+	}
+}
@@ -0,0 +1,435 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+)
+
+const (
+	// defaultWebhookMaxAttempts is how many times a delivery is tried before it is dead-lettered
+	defaultWebhookMaxAttempts = 8
+
+	// defaultWebhookBackoff is the wait before the first retry; it doubles with every failure
+	defaultWebhookBackoff = 30 * time.Second
+
+	// maxWebhookBackoff caps the wait between retries
+	maxWebhookBackoff = 6 * time.Hour
+
+	// webhookBatchSize limits how many deliveries one run sends
+	webhookBatchSize = 50
+
+	// webhookTimeout bounds a single delivery request
+	webhookTimeout = 10 * time.Second
+
+	// webhookLease is how long a claimed batch is hidden from other workers; it outlasts sending
+	// a whole batch to endpoints that all time out
+	webhookLease = 15 * time.Minute
+)
+
+// Headers sent with every webhook delivery
+const (
+	WebhookSignatureHeader = "X-Lokr-Signature" // "sha256=" and the hex HMAC-SHA256 of the body
+	WebhookEventHeader     = "X-Lokr-Event"
+	WebhookDeliveryHeader  = "X-Lokr-Delivery"
+)
+
+var (
+	// ErrWebhookNotFound is returned for a webhook that doesn't exist or belongs to another enterprise
+	ErrWebhookNotFound = errors.New("webhook not found")
+
+	// ErrInvalidWebhookURL is returned for an endpoint that isn't an absolute http(s) URL
+	ErrInvalidWebhookURL = errors.New("webhook URL must be an absolute http or https URL")
+
+	// ErrWebhookAddressBlocked is returned for an endpoint on a loopback, private or link-local
+	// address, such as the cloud metadata service, which a webhook could otherwise be pointed at to
+	// reach the internal network
+	ErrWebhookAddressBlocked = errors.New("webhook URL must not point at a loopback, private or link-local address")
+
+	// ErrInvalidWebhookEvent is returned for a subscribed event that isn't an audit action
+	ErrInvalidWebhookEvent = errors.New("unknown webhook event")
+)
+
+// WebhookService delivers enterprise audit events to the endpoints enterprises register. Events
+// are queued when they are logged and sent by a background worker, so a slow or failing endpoint
+// never holds up the request that caused the event.
+type WebhookService struct {
+	db          *pgxpool.Pool
+	client      *http.Client
+	logger      *zap.Logger
+	maxAttempts int
+	backoff     time.Duration
+
+	// allowPrivate lets endpoints on internal addresses through, for tests against local servers
+	allowPrivate bool
+}
+
+func NewWebhookService(db *pgxpool.Pool, logger *zap.Logger) *WebhookService {
+	s := &WebhookService{
+		db:          db,
+		logger:      logger,
+		maxAttempts: defaultWebhookMaxAttempts,
+		backoff:     defaultWebhookBackoff,
+	}
+
+	// The address is checked again as each delivery connects, since DNS may have changed since the
+	// webhook was registered. Redirects are not followed: they could lead anywhere.
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: s.checkDialAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	s.client = &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return s
+}
+
+// blockedWebhookAddress reports whether addr is one webhooks may not be delivered to: loopback,
+// private, link-local (which includes the cloud metadata service), unspecified or multicast
+func blockedWebhookAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsUnspecified() || addr.IsMulticast()
+}
+
+// checkWebhookHost resolves a webhook endpoint's host and refuses it when any of its addresses is
+// blocked
+func (s *WebhookService) checkWebhookHost(ctx context.Context, host string) error {
+	if s.allowPrivate {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("%w: cannot resolve %s", ErrInvalidWebhookURL, host)
+	}
+	for _, addr := range addrs {
+		if blockedWebhookAddress(addr) {
+			return ErrWebhookAddressBlocked
+		}
+	}
+	return nil
+}
+
+// checkDialAddress refuses a delivery connection to a blocked address
+func (s *WebhookService) checkDialAddress(network, address string, _ syscall.RawConn) error {
+	if s.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || blockedWebhookAddress(addr) {
+		return ErrWebhookAddressBlocked
+	}
+	return nil
+}
+
+// SignWebhookPayload returns the signature header value for body: receivers recompute the
+// HMAC-SHA256 with their webhook's secret and compare
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay is how long to wait after a delivery has failed attempts times
+func (s *WebhookService) webhookRetryDelay(attempts int) time.Duration {
+	delay := s.backoff
+	for i := 1; i < attempts && delay < maxWebhookBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxWebhookBackoff)
+}
+
+// adminEnterprise returns the enterprise the user is an OWNER or ADMIN of
+func (s *WebhookService) adminEnterprise(ctx context.Context, userID uuid.UUID) (uuid.UUID, error) {
+	var enterpriseID *uuid.UUID
+	var role *domain.EnterpriseRole
+	err := s.db.QueryRow(ctx, "SELECT enterprise_id, enterprise_role FROM users WHERE id = $1", userID).Scan(&enterpriseID, &role)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("user not found: %w", err)
+	}
+	if enterpriseID == nil || role == nil || (*role != domain.EnterpriseRoleOwner && *role != domain.EnterpriseRoleAdmin) {
+		return uuid.Nil, ErrPermissionDenied
+	}
+	return *enterpriseID, nil
+}
+
+// CreateWebhook registers an endpoint for the enterprise the caller administers. The endpoint must
+// not resolve to an internal address. events lists the audit actions to deliver; none delivers
+// every action. The returned webhook carries its signing
+// secret, which isn't shown again.
+func (s *WebhookService) CreateWebhook(ctx context.Context, callerID uuid.UUID, endpoint string, events []domain.AuditAction) (*domain.Webhook, error) {
+	enterpriseID, err := s.adminEnterprise(ctx, callerID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+	if err := s.checkWebhookHost(ctx, parsed.Hostname()); err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if !domain.IsKnownAuditAction(event) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidWebhookEvent, event)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	actions := make([]string, len(events))
+	for i, event := range events {
+		actions[i] = string(event)
+	}
+	if events == nil {
+		events = []domain.AuditAction{}
+	}
+
+	webhook := &domain.Webhook{
+		EnterpriseID: enterpriseID,
+		URL:          endpoint,
+		Secret:       hex.EncodeToString(secret),
+		Events:       events,
+		CreatedBy:    &callerID,
+	}
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO enterprise_webhooks (enterprise_id, url, secret, events, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		enterpriseID, endpoint, webhook.Secret, actions, callerID).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// ListWebhooks returns the webhooks of the enterprise the caller administers, without their secrets
+func (s *WebhookService) ListWebhooks(ctx context.Context, callerID uuid.UUID) ([]*domain.Webhook, error) {
+	enterpriseID, err := s.adminEnterprise(ctx, callerID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id, enterprise_id, url, events, created_by, created_at
+		FROM enterprise_webhooks
+		WHERE enterprise_id = $1
+		ORDER BY created_at`, enterpriseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*domain.Webhook{}
+	for rows.Next() {
+		webhook := &domain.Webhook{Events: []domain.AuditAction{}}
+		var actions []string
+		if err := rows.Scan(&webhook.ID, &webhook.EnterpriseID, &webhook.URL, &actions, &webhook.CreatedBy, &webhook.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		for _, action := range actions {
+			webhook.Events = append(webhook.Events, domain.AuditAction(action))
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook removes a webhook of the enterprise the caller administers, with its queued deliveries
+func (s *WebhookService) DeleteWebhook(ctx context.Context, callerID, webhookID uuid.UUID) error {
+	enterpriseID, err := s.adminEnterprise(ctx, callerID)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(ctx, "DELETE FROM enterprise_webhooks WHERE id = $1 AND enterprise_id = $2", webhookID, enterpriseID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Enqueue queues an audit event for every webhook of the acting user's enterprise subscribed to
+// its action. Users outside an enterprise have no webhooks. A nil service queues nothing.
+func (s *WebhookService) Enqueue(ctx context.Context, event *domain.WebhookEvent) error {
+	if s == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+		SELECT w.id, $2::text, $3::jsonb
+		FROM enterprise_webhooks w
+		JOIN users u ON u.enterprise_id = w.enterprise_id
+		WHERE u.id = $1 AND (w.events = '{}' OR $2::text = ANY(w.events))`,
+		event.UserID, string(event.Type), payload)
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// pendingDelivery is a queued event with the endpoint it goes to
+type pendingDelivery struct {
+	id        uuid.UUID
+	eventType string
+	payload   []byte
+	attempts  int
+	url       string
+	secret    string
+}
+
+// DeliverPending sends the deliveries that are due, recording each outcome. A delivery is done
+// when the endpoint answers 2xx; otherwise it is retried with exponential backoff and, after the
+// last attempt, marked DEAD. Returns how many deliveries succeeded.
+func (s *WebhookService) DeliverPending(ctx context.Context) (int, error) {
+	// Claim a batch by pushing it back a lease, so other workers skip it while it is sent and a
+	// worker dying mid-batch only delays it
+	rows, err := s.db.Query(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2::float8 * INTERVAL '1 second'
+		FROM enterprise_webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'PENDING' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING d.id, d.event_type, d.payload, d.attempts, w.url, w.secret`,
+		webhookBatchSize, webhookLease.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim pending deliveries: %w", err)
+	}
+	var deliveries []pendingDelivery
+	for rows.Next() {
+		var delivery pendingDelivery
+		if err := rows.Scan(&delivery.id, &delivery.eventType, &delivery.payload, &delivery.attempts, &delivery.url, &delivery.secret); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read pending deliveries: %w", err)
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		sendErr := s.send(ctx, delivery)
+		if sendErr == nil {
+			delivered++
+			if _, err := s.db.Exec(ctx, `
+				UPDATE webhook_deliveries
+				SET status = 'DELIVERED', attempts = attempts + 1, last_error = NULL, delivered_at = NOW()
+				WHERE id = $1`, delivery.id); err != nil {
+				return delivered, fmt.Errorf("failed to record delivery: %w", err)
+			}
+			continue
+		}
+
+		attempts := delivery.attempts + 1
+		status := domain.WebhookDeliveryPending
+		if attempts >= s.maxAttempts {
+			status = domain.WebhookDeliveryDead
+			s.logger.Warn("Webhook delivery dead-lettered",
+				zap.String("delivery_id", delivery.id.String()),
+				zap.Int("attempts", attempts),
+				zap.Error(sendErr))
+		}
+		if _, err := s.db.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = $2, attempts = $3, last_error = $4, next_attempt_at = NOW() + $5::float8 * INTERVAL '1 second'
+			WHERE id = $1`,
+			delivery.id, string(status), attempts, sendErr.Error(), s.webhookRetryDelay(attempts).Seconds()); err != nil {
+			return delivered, fmt.Errorf("failed to record failed delivery: %w", err)
+		}
+	}
+
+	return delivered, nil
+}
+
+// send POSTs one delivery's payload, signed with its webhook's secret
+func (s *WebhookService) send(ctx context.Context, delivery pendingDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(delivery.payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Lokr-Webhooks")
+	req.Header.Set(WebhookEventHeader, delivery.eventType)
+	req.Header.Set(WebhookDeliveryHeader, delivery.id.String())
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.secret, delivery.payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return nil
+}
+
+// RunDeliveries sends due webhook deliveries every interval until ctx is cancelled
+func (s *WebhookService) RunDeliveries(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		delivered, err := s.DeliverPending(ctx)
+		if err != nil {
+			s.logger.Error("Failed to deliver webhooks", zap.Error(err))
+		} else if delivered > 0 {
+			s.logger.Info("Delivered webhooks", zap.Int("delivered", delivered))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,240 @@
+//go:build integration
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/domain"
+	"lokr-backend/internal/testutil"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	// HMAC-SHA256 test vector from RFC 4231, test case 2
+	signature := SignWebhookPayload("Jefe", []byte("what do ya want for nothing?"))
+	if signature != "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843" {
+		t.Errorf("Unexpected signature %s", signature)
+	}
+	if SignWebhookPayload("other secret", []byte("what do ya want for nothing?")) == signature {
+		t.Error("Expected the signature to depend on the secret")
+	}
+}
+
+// webhookReceiver records the deliveries it is sent and answers them with status
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	bodies   [][]byte
+	requests []*http.Request
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.bodies = append(r.bodies, body)
+	r.requests = append(r.requests, req)
+	r.mu.Unlock()
+	w.WriteHeader(r.status)
+}
+
+func TestWebhookService_Delivery(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	adminID := testutil.CreateUser(t, db, "hooks-admin", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", adminID)
+	memberID := testutil.CreateUser(t, db, "hooks-member", &enterpriseID)
+
+	webhooks := NewWebhookService(db, zap.NewNop())
+	webhooks.maxAttempts = 3
+	webhooks.backoff = 0
+	// The receivers below listen on loopback
+	webhooks.allowPrivate = true
+	audit := NewAuditService(db, zap.NewNop())
+	audit.SetWebhookService(webhooks)
+
+	if _, err := webhooks.CreateWebhook(ctx, memberID, "https://example.com/hook", nil); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected members to be refused, got %v", err)
+	}
+	if _, err := webhooks.CreateWebhook(ctx, adminID, "ftp://example.com/hook", nil); !errors.Is(err, ErrInvalidWebhookURL) {
+		t.Errorf("Expected ErrInvalidWebhookURL, got %v", err)
+	}
+
+	deliveryStatus := func(webhookID uuid.UUID) (string, int) {
+		var status string
+		var attempts int
+		db.QueryRow(ctx, "SELECT status, attempts FROM webhook_deliveries WHERE webhook_id = $1", webhookID).Scan(&status, &attempts)
+		return status, attempts
+	}
+
+	t.Run("delivers signed events it subscribes to", func(t *testing.T) {
+		receiver := &webhookReceiver{status: http.StatusNoContent}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		webhook, err := webhooks.CreateWebhook(ctx, adminID, server.URL, []domain.AuditAction{domain.ActionFileUpload})
+		if err != nil {
+			t.Fatalf("CreateWebhook failed: %v", err)
+		}
+		defer webhooks.DeleteWebhook(ctx, adminID, webhook.ID)
+
+		audit.LogFileUpload(ctx, memberID, uuid.New(), "hooked.txt", "", "")
+		audit.LogFileDownload(ctx, memberID, uuid.New(), "not-subscribed.txt", "", "")
+
+		if _, err := webhooks.DeliverPending(ctx); err != nil {
+			t.Fatalf("DeliverPending failed: %v", err)
+		}
+		if len(receiver.bodies) != 1 {
+			t.Fatalf("Expected 1 delivery, got %d", len(receiver.bodies))
+		}
+		request, body := receiver.requests[0], receiver.bodies[0]
+		if request.Header.Get(WebhookSignatureHeader) != SignWebhookPayload(webhook.Secret, body) {
+			t.Error("Expected the body to be signed with the webhook's secret")
+		}
+		var event domain.WebhookEvent
+		json.Unmarshal(body, &event)
+		if event.Type != domain.ActionFileUpload || event.UserID != memberID || event.ResourceName != "hooked.txt" {
+			t.Errorf("Unexpected event %+v", event)
+		}
+		if status, attempts := deliveryStatus(webhook.ID); status != "DELIVERED" || attempts != 1 {
+			t.Errorf("Expected DELIVERED after 1 attempt, got %s after %d", status, attempts)
+		}
+	})
+
+	t.Run("retries a failing endpoint then dead-letters", func(t *testing.T) {
+		receiver := &webhookReceiver{status: http.StatusInternalServerError}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		webhook, err := webhooks.CreateWebhook(ctx, adminID, server.URL, nil)
+		if err != nil {
+			t.Fatalf("CreateWebhook failed: %v", err)
+		}
+		defer webhooks.DeleteWebhook(ctx, adminID, webhook.ID)
+
+		audit.LogFileDelete(ctx, memberID, uuid.New(), "doomed.txt", "", "")
+
+		for attempt := 1; attempt <= 3; attempt++ {
+			if _, err := webhooks.DeliverPending(ctx); err != nil {
+				t.Fatalf("DeliverPending failed: %v", err)
+			}
+			status, attempts := deliveryStatus(webhook.ID)
+			want := "PENDING"
+			if attempt == 3 {
+				want = "DEAD"
+			}
+			if status != want || attempts != attempt {
+				t.Fatalf("After attempt %d expected %s, got %s after %d attempts", attempt, want, status, attempts)
+			}
+		}
+
+		// Dead deliveries aren't tried again
+		webhooks.DeliverPending(ctx)
+		if len(receiver.bodies) != 3 {
+			t.Errorf("Expected 3 delivery attempts, got %d", len(receiver.bodies))
+		}
+	})
+}
+
+func TestWebhookService_RefusesInternalEndpoints(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+
+	enterpriseID := testutil.CreateEnterprise(t, db)
+	adminID := testutil.CreateUser(t, db, "hooks-admin", &enterpriseID)
+	db.Exec(ctx, "UPDATE users SET enterprise_role = 'ADMIN' WHERE id = $1", adminID)
+	memberID := testutil.CreateUser(t, db, "hooks-member", &enterpriseID)
+
+	webhooks := NewWebhookService(db, zap.NewNop())
+	webhooks.maxAttempts = 1
+	audit := NewAuditService(db, zap.NewNop())
+	audit.SetWebhookService(webhooks)
+
+	for _, endpoint := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/hook",
+		"http://[fd00::1]/hook",
+	} {
+		if _, err := webhooks.CreateWebhook(ctx, adminID, endpoint, nil); !errors.Is(err, ErrWebhookAddressBlocked) {
+			t.Errorf("Expected %s to be refused with ErrWebhookAddressBlocked, got %v", endpoint, err)
+		}
+	}
+
+	if _, err := webhooks.CreateWebhook(ctx, adminID, "https://example.com/hook", []domain.AuditAction{"EVERYTHING"}); !errors.Is(err, ErrInvalidWebhookEvent) {
+		t.Errorf("Expected an unknown event to be refused with ErrInvalidWebhookEvent, got %v", err)
+	}
+
+	deliveryStatus := func(webhookID uuid.UUID) string {
+		var status string
+		db.QueryRow(ctx, "SELECT status FROM webhook_deliveries WHERE webhook_id = $1", webhookID).Scan(&status)
+		return status
+	}
+
+	t.Run("deliveries to an address that turned internal are refused", func(t *testing.T) {
+		receiver := &webhookReceiver{status: http.StatusNoContent}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		// Registered while the check let it through, as when DNS changes after registration
+		webhooks.allowPrivate = true
+		webhook, err := webhooks.CreateWebhook(ctx, adminID, server.URL, nil)
+		webhooks.allowPrivate = false
+		if err != nil {
+			t.Fatalf("CreateWebhook failed: %v", err)
+		}
+		defer webhooks.DeleteWebhook(ctx, adminID, webhook.ID)
+
+		audit.LogFileUpload(ctx, memberID, uuid.New(), "internal.txt", "", "")
+		if _, err := webhooks.DeliverPending(ctx); err != nil {
+			t.Fatalf("DeliverPending failed: %v", err)
+		}
+		if len(receiver.bodies) != 0 {
+			t.Errorf("Expected nothing delivered to a loopback address, got %d", len(receiver.bodies))
+		}
+		if status := deliveryStatus(webhook.ID); status != "DEAD" {
+			t.Errorf("Expected the delivery to fail, got %s", status)
+		}
+	})
+
+	t.Run("redirects are not followed", func(t *testing.T) {
+		target := &webhookReceiver{status: http.StatusNoContent}
+		targetServer := httptest.NewServer(target)
+		defer targetServer.Close()
+		redirector := httptest.NewServer(http.RedirectHandler(targetServer.URL, http.StatusTemporaryRedirect))
+		defer redirector.Close()
+
+		webhooks.allowPrivate = true
+		defer func() { webhooks.allowPrivate = false }()
+		webhook, err := webhooks.CreateWebhook(ctx, adminID, redirector.URL, nil)
+		if err != nil {
+			t.Fatalf("CreateWebhook failed: %v", err)
+		}
+		defer webhooks.DeleteWebhook(ctx, adminID, webhook.ID)
+
+		audit.LogFileUpload(ctx, memberID, uuid.New(), "redirected.txt", "", "")
+		if _, err := webhooks.DeliverPending(ctx); err != nil {
+			t.Fatalf("DeliverPending failed: %v", err)
+		}
+		if len(target.bodies) != 0 {
+			t.Errorf("Expected the redirect not to be followed, got %d deliveries", len(target.bodies))
+		}
+		if status := deliveryStatus(webhook.ID); status != "DEAD" {
+			t.Errorf("Expected a redirect to count as a failed delivery, got %s", status)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package storage
+
+// defaultLocalPath is where the local backend keeps files when STORAGE_PATH is unset
+const defaultLocalPath = "./storage"
+
+// ConfigFromEnv builds the storage configuration from the environment:
+//
+//	STORAGE_BACKEND        s3 or local; without it, s3 when USE_S3=true and local otherwise
+//	STORAGE_PATH           local base directory, ./storage by default
+//	STORAGE_PREFIX         put before every key, e.g. staging, to share a bucket between environments
+//	AWS_REGION             S3 region
+//	S3_BUCKET_NAME         S3 bucket
+//	AWS_ACCESS_KEY_ID      S3 credentials; the default credential chain is used without them
+//	AWS_SECRET_ACCESS_KEY
+//	S3_ENDPOINT            endpoint of an S3-compatible service such as MinIO
+//	S3_USE_PATH_STYLE      true for path-style bucket addressing
+//	S3_SSE_MODE            server-side encryption, AES256 by default or aws:kms
+//	S3_KMS_KEY_ID          KMS key for aws:kms; the AWS managed key is used without it
+func ConfigFromEnv(getenv func(string) string) StorageConfig {
+	backend := getenv("STORAGE_BACKEND")
+	if backend == "" {
+		backend = "local"
+		if getenv("USE_S3") == "true" {
+			backend = "s3"
+		}
+	}
+
+	localPath := getenv("STORAGE_PATH")
+	if localPath == "" {
+		localPath = defaultLocalPath
+	}
+
+	return StorageConfig{
+		Backend: backend,
+		S3: S3Config{
+			Region:          getenv("AWS_REGION"),
+			BucketName:      getenv("S3_BUCKET_NAME"),
+			AccessKeyID:     getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: getenv("AWS_SECRET_ACCESS_KEY"),
+			Endpoint:        getenv("S3_ENDPOINT"),
+			UsePathStyle:    getenv("S3_USE_PATH_STYLE") == "true",
+			SSEMode:         getenv("S3_SSE_MODE"),
+			KMSKeyID:        getenv("S3_KMS_KEY_ID"),
+		},
+		Local:  LocalConfig{BasePath: localPath},
+		Prefix: getenv("STORAGE_PREFIX"),
+	}
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		backend string
+		path    string
+	}{
+		{"defaults to local", map[string]string{}, "local", "./storage"},
+		{"legacy USE_S3", map[string]string{"USE_S3": "true"}, "s3", "./storage"},
+		{"explicit backend wins", map[string]string{"USE_S3": "true", "STORAGE_BACKEND": "local", "STORAGE_PATH": "/data"}, "local", "/data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ConfigFromEnv(func(key string) string { return tt.env[key] })
+			if config.Backend != tt.backend {
+				t.Errorf("Expected backend %s, got %s", tt.backend, config.Backend)
+			}
+			if config.Local.BasePath != tt.path {
+				t.Errorf("Expected path %s, got %s", tt.path, config.Local.BasePath)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnv_S3Endpoint(t *testing.T) {
+	env := map[string]string{"STORAGE_BACKEND": "s3", "S3_ENDPOINT": "http://minio:9000", "S3_USE_PATH_STYLE": "true"}
+	config := ConfigFromEnv(func(key string) string { return env[key] })
+	if config.S3.Endpoint != "http://minio:9000" || !config.S3.UsePathStyle {
+		t.Errorf("Expected the MinIO endpoint with path-style addressing, got %+v", config.S3)
+	}
+}
+
+func TestConfigFromEnv_S3Encryption(t *testing.T) {
+	env := map[string]string{"STORAGE_BACKEND": "s3", "S3_SSE_MODE": "aws:kms", "S3_KMS_KEY_ID": "alias/lokr"}
+	config := ConfigFromEnv(func(key string) string { return env[key] })
+	if config.S3.SSEMode != SSEModeKMS || config.S3.KMSKeyID != "alias/lokr" {
+		t.Errorf("Expected SSE-KMS with alias/lokr, got %+v", config.S3)
+	}
+}
+
+func TestConfigFromEnv_Prefix(t *testing.T) {
+	config := ConfigFromEnv(func(key string) string { return map[string]string{"STORAGE_PREFIX": "staging"}[key] })
+	if config.Prefix != "staging" {
+		t.Errorf("Expected prefix staging, got %q", config.Prefix)
+	}
+}
+
+func TestNewStorageService_Local(t *testing.T) {
+	store, err := NewStorageService(StorageConfig{Backend: "local", Local: LocalConfig{BasePath: t.TempDir()}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewStorageService failed: %v", err)
+	}
+	ctx := context.Background()
+
+	path := ContentPath("", "user", "hash")
+	if err := store.Store(ctx, path, bytes.NewReader([]byte("content")), "text/plain"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	content, err := ReadAll(ctx, store, path)
+	if err != nil || string(content) != "content" {
+		t.Errorf("Expected the stored content back, got %q (%v)", content, err)
+	}
+	if err := store.Delete(ctx, path); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if exists, _ := store.Exists(ctx, path); exists {
+		t.Error("Expected the deleted file to be gone")
+	}
+}
+
+func TestNewStorageService_Unsupported(t *testing.T) {
+	if _, err := NewStorageService(StorageConfig{Backend: "gcs"}, zap.NewNop()); err == nil {
+		t.Error("Expected an unsupported backend to be rejected")
+	}
+}
+
+func TestNewStorageService_Prefix(t *testing.T) {
+	basePath := t.TempDir()
+	newStore := func(prefix string) StorageService {
+		store, err := NewStorageService(StorageConfig{Backend: "local", Local: LocalConfig{BasePath: basePath}, Prefix: prefix}, zap.NewNop())
+		if err != nil {
+			t.Fatalf("NewStorageService failed: %v", err)
+		}
+		return store
+	}
+	staging, production := newStore("/staging/"), newStore("prod")
+	ctx := context.Background()
+
+	key := staging.Key(ContentPath("", "user", "hash"))
+	if key != "staging/personal/users/user/hash" {
+		t.Fatalf("Expected the key to be prefixed, got %q", key)
+	}
+	if err := staging.Store(ctx, key, bytes.NewReader([]byte("staged")), "text/plain"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(basePath, "staging", "personal", "users", "user", "hash")); err != nil {
+		t.Errorf("Expected the object under the prefix: %v", err)
+	}
+
+	// Content stored before the prefix was configured keeps its key and stays readable
+	legacy := ContentPath("", "user", "legacy")
+	if err := newStore("").Store(ctx, legacy, bytes.NewReader([]byte("legacy")), "text/plain"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if content, err := ReadAll(ctx, staging, legacy); err != nil || string(content) != "legacy" {
+		t.Errorf("Expected the unprefixed object to stay readable, got %q (%v)", content, err)
+	}
+
+	// Listing only sees the environment's own objects, under keys that round-trip
+	files, err := staging.ListFiles(ctx, "personal/")
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != key {
+		t.Fatalf("Expected only %s to be listed, got %+v", key, files)
+	}
+	if content, err := ReadAll(ctx, staging, files[0].Path); err != nil || string(content) != "staged" {
+		t.Errorf("Expected the listed key to read back, got %q (%v)", content, err)
+	}
+	if files, _ := production.ListFiles(ctx, ""); len(files) != 0 {
+		t.Errorf("Expected another environment to see none of it, got %+v", files)
+	}
+
+	if err := staging.Delete(ctx, files[0].Path); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if exists, _ := staging.Exists(ctx, key); exists {
+		t.Error("Expected the deleted object to be gone")
+	}
+
+	for _, prefix := range []string{"../escape", "a//b", `env\prod`} {
+		if _, err := NewStorageService(StorageConfig{Backend: "local", Local: LocalConfig{BasePath: basePath}, Prefix: prefix}, zap.NewNop()); err == nil {
+			t.Errorf("Expected prefix %q to be rejected", prefix)
+		}
+	}
+}
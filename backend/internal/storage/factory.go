@@ -7,6 +7,11 @@ import (
 
 // NewStorageService creates a new storage service based on the configuration
 func NewStorageService(config StorageConfig, logger *zap.Logger) (StorageService, error) {
+	prefix, err := parseKeyPrefix(config.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
 	switch config.Backend {
 	case "s3":
 		if config.S3.BucketName == "" {
@@ -16,14 +21,24 @@ func NewStorageService(config StorageConfig, logger *zap.Logger) (StorageService
 			return nil, fmt.Errorf("S3 region is required")
 		}
 
-		return NewS3Storage(config.S3, logger)
+		store, err := NewS3Storage(config.S3, logger)
+		if err != nil {
+			return nil, err
+		}
+		store.keyPrefix = prefix
+		return store, nil
 
 	case "local":
 		if config.Local.BasePath == "" {
 			return nil, fmt.Errorf("local storage base path is required")
 		}
 
-		return NewLocalStorage(config.Local.BasePath, logger)
+		store, err := NewLocalStorage(config.Local.BasePath, logger)
+		if err != nil {
+			return nil, err
+		}
+		store.keyPrefix = prefix
+		return store, nil
 
 	default:
 		return nil, fmt.Errorf("unsupported storage backend: %s", config.Backend)
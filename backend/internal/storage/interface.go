@@ -23,8 +23,21 @@ type StorageService interface {
 	// GetFileInfo retrieves metadata about the file
 	GetFileInfo(ctx context.Context, path string) (*FileInfo, error)
 
-	// ListFiles lists files with the given prefix
+	// ListFiles lists files with the given prefix under the backend's key prefix, at most
+	// MaxListedFiles of them. Their paths are full keys, as Key returns them.
 	ListFiles(ctx context.Context, prefix string) ([]*FileInfo, error)
+
+	// ListFilesPage lists up to maxKeys files like ListFiles, starting after the page that
+	// returned continuationToken ("" for the first page). The returned token fetches the next
+	// page and is empty after the last one.
+	ListFilesPage(ctx context.Context, prefix, continuationToken string, maxKeys int) ([]*FileInfo, string, error)
+
+	// Ping checks that the backend is reachable
+	Ping(ctx context.Context) error
+
+	// Key returns the full key new content at path is stored under, with the backend's
+	// configured prefix. Every other method takes full keys.
+	Key(path string) string
 }
 
 // PresignedURLService defines interface for services that support presigned URLs
@@ -32,8 +45,16 @@ type PresignedURLService interface {
 	// GeneratePresignedURL generates a presigned URL for downloading
 	GeneratePresignedURL(ctx context.Context, path string, expiration time.Duration) (string, error)
 
-	// GenerateUploadPresignedURL generates a presigned URL for uploading
-	GenerateUploadPresignedURL(ctx context.Context, path, mimeType string, expiration time.Duration) (string, error)
+	// GenerateUploadPresignedURL generates a presigned URL for uploading; a positive size is signed
+	// into the request, so the backend refuses a body of another length
+	GenerateUploadPresignedURL(ctx context.Context, path, mimeType string, size int64, expiration time.Duration) (string, error)
+}
+
+// UploadDrainer is implemented by backends that track in-flight uploads, so shutdown can let them
+// finish rather than abandon them half written
+type UploadDrainer interface {
+	// Drain waits for in-flight uploads to finish until ctx is done, then aborts those still running
+	Drain(ctx context.Context) error
 }
 
 // StorageConfig contains configuration for different storage backends
@@ -41,6 +62,7 @@ type StorageConfig struct {
 	Backend string    `json:"backend" validate:"required,oneof=s3 local"`
 	S3      S3Config  `json:"s3,omitempty"`
 	Local   LocalConfig `json:"local,omitempty"`
+	Prefix  string    `json:"prefix,omitempty"` // Put before every key, e.g. the environment name
 }
 
 // LocalConfig contains configuration for local file storage
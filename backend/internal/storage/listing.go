@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	// DefaultListPageSize is the page size ListFilesPage uses for a maxKeys that is not positive,
+	// and the most it returns at once, matching S3's own limit
+	DefaultListPageSize = 1000
+	// MaxListedFiles caps how many files ListFiles collects; larger listings must page
+	MaxListedFiles = 10000
+)
+
+// ErrTooManyFiles is returned by ListFiles when a prefix holds more than MaxListedFiles files
+var ErrTooManyFiles = errors.New("too many files to list at once")
+
+// listPageSize bounds a requested page size to (0, DefaultListPageSize]
+func listPageSize(maxKeys int) int {
+	if maxKeys <= 0 || maxKeys > DefaultListPageSize {
+		return DefaultListPageSize
+	}
+	return maxKeys
+}
+
+// collectPages implements ListFiles on top of a backend's ListFilesPage, failing with
+// ErrTooManyFiles rather than holding an unbounded listing in memory
+func collectPages(ctx context.Context, prefix string, listPage func(ctx context.Context, prefix, continuationToken string, maxKeys int) ([]*FileInfo, string, error)) ([]*FileInfo, error) {
+	var files []*FileInfo
+	token := ""
+	for {
+		page, next, err := listPage(ctx, prefix, token, DefaultListPageSize)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, page...)
+		if len(files) > MaxListedFiles {
+			return nil, fmt.Errorf("%w under %q; page through them with ListFilesPage", ErrTooManyFiles, prefix)
+		}
+		if next == "" {
+			return files, nil
+		}
+		token = next
+	}
+}
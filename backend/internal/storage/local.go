@@ -2,10 +2,13 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -15,6 +18,7 @@ import (
 type LocalStorage struct {
 	basePath string
 	logger   *zap.Logger
+	keyPrefix
 }
 
 // NewLocalStorage creates a new local storage service
@@ -63,9 +67,11 @@ func (l *LocalStorage) Store(ctx context.Context, path string, content io.Reader
 	}
 	defer file.Close()
 
-	// Copy content to file
-	size, err := io.Copy(file, content)
+	// Copy content to file, stopping if the caller gives up; a partial file is removed
+	size, err := io.Copy(file, contextReader{ctx: ctx, r: content})
 	if err != nil {
+		file.Close()
+		os.Remove(fullPath)
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
@@ -83,6 +89,9 @@ func (l *LocalStorage) Get(ctx context.Context, path string) (io.ReadCloser, err
 	l.logger.Info("Retrieving file from local storage",
 		zap.String("path", fullPath))
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	file, err := os.Open(fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -94,7 +103,28 @@ func (l *LocalStorage) Get(ctx context.Context, path string) (io.ReadCloser, err
 	l.logger.Info("Successfully retrieved file from local storage",
 		zap.String("path", fullPath))
 
-	return file, nil
+	// Reads fail once ctx is done, like an S3 body whose request was cancelled
+	return contextReadCloser{contextReader{ctx: ctx, r: file}, file}, nil
+}
+
+// contextReader fails reads once ctx is done, so copying a large file stops when the request that
+// started it is cancelled or times out
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// contextReadCloser is a contextReader that closes the underlying file
+type contextReadCloser struct {
+	contextReader
+	io.Closer
 }
 
 // Delete removes a file from local storage
@@ -157,53 +187,108 @@ func (l *LocalStorage) GetFileInfo(ctx context.Context, path string) (*FileInfo,
 
 // ListFiles lists files in a specific directory
 func (l *LocalStorage) ListFiles(ctx context.Context, prefix string) ([]*FileInfo, error) {
-	fullPrefix := filepath.Join(l.basePath, prefix)
-
 	l.logger.Info("Listing files in local storage",
-		zap.String("prefix", fullPrefix))
+		zap.String("prefix", filepath.Join(l.basePath, l.Key(prefix))))
+
+	files, err := collectPages(ctx, prefix, l.ListFilesPage)
+	if err != nil {
+		return nil, err
+	}
+
+	l.logger.Info("Successfully listed files",
+		zap.String("prefix", prefix),
+		zap.Int("count", len(files)))
+
+	return files, nil
+}
+
+// ListFilesPage lists one page of the files in a directory. Files are walked in path order and
+// the continuation token is the last path returned, so the walk skips straight past it.
+func (l *LocalStorage) ListFilesPage(ctx context.Context, prefix, continuationToken string, maxKeys int) ([]*FileInfo, string, error) {
+	fullPrefix := filepath.Join(l.basePath, l.Key(prefix))
+	maxKeys = listPageSize(maxKeys)
+	after := filepath.FromSlash(continuationToken)
 
 	var files []*FileInfo
+	next := ""
+	err := filepath.WalkDir(fullPrefix, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == fullPrefix && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-	err := filepath.Walk(fullPrefix, func(path string, info os.FileInfo, err error) error {
+		relPath, err := filepath.Rel(l.basePath, path)
 		if err != nil {
 			return err
 		}
 
-		// Skip directories
-		if info.IsDir() {
+		// Skip everything up to the token, including whole directories that sort before it
+		if after != "" && path != fullPrefix && !walksAfter(relPath, after) {
+			if entry.IsDir() && !isAncestor(relPath, after) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
 			return nil
 		}
 
-		// Convert absolute path back to relative path
-		relPath, err := filepath.Rel(l.basePath, path)
+		if len(files) == maxKeys {
+			next = filepath.ToSlash(files[len(files)-1].Path)
+			return filepath.SkipAll
+		}
+
+		info, err := entry.Info()
 		if err != nil {
 			return err
 		}
-
-		mimeType := determineMimeType(path)
 		uploadTime := info.ModTime()
-
 		files = append(files, &FileInfo{
 			Path:         relPath,
 			Size:         info.Size(),
-			MimeType:     mimeType,
+			MimeType:     determineMimeType(path),
 			LastModified: info.ModTime(),
 			UploadedAt:   &uploadTime,
 			ContentHash:  extractContentHashFromPath(relPath),
 		})
-
 		return nil
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to list files: %w", err)
+		return nil, "", fmt.Errorf("failed to list files: %w", err)
 	}
 
-	l.logger.Info("Successfully listed files",
-		zap.String("prefix", prefix),
-		zap.Int("count", len(files)))
+	return files, next, nil
+}
 
-	return files, nil
+// walksAfter reports whether WalkDir visits path after after. It walks each directory's entries
+// in name order, so paths compare element by element rather than as plain strings.
+func walksAfter(path, after string) bool {
+	pathElems := strings.Split(path, string(filepath.Separator))
+	afterElems := strings.Split(after, string(filepath.Separator))
+	for i := 0; i < len(pathElems) && i < len(afterElems); i++ {
+		if pathElems[i] != afterElems[i] {
+			return pathElems[i] > afterElems[i]
+		}
+	}
+	return len(pathElems) > len(afterElems)
+}
+
+// isAncestor reports whether dir is a directory on the way to path
+func isAncestor(dir, path string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// Ping checks that the base directory is still there
+func (l *LocalStorage) Ping(ctx context.Context) error {
+	if _, err := os.Stat(l.basePath); err != nil {
+		return fmt.Errorf("local storage unavailable: %w", err)
+	}
+	return nil
 }
 
 // determineMimeType determines MIME type from file extension
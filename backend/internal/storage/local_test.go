@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestLocalStorage_HonorsCancellation(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := store.Store(ctx, "kept", strings.NewReader("content"), "text/plain"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	reader, err := store.Get(ctx, "kept")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer reader.Close()
+
+	cancel()
+
+	if _, err := io.ReadAll(reader); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected reading after cancellation to fail with context.Canceled, got %v", err)
+	}
+	if _, err := store.Get(ctx, "kept"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Get after cancellation to fail with context.Canceled, got %v", err)
+	}
+	if err := store.Store(ctx, "abandoned", strings.NewReader("content"), "text/plain"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Store after cancellation to fail with context.Canceled, got %v", err)
+	}
+	if exists, _ := store.Exists(context.Background(), "abandoned"); exists {
+		t.Error("Expected a cancelled Store to leave no partial file")
+	}
+}
+
+func TestLocalStorage_ListFilesPage(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	ctx := context.Background()
+
+	// Names where walk order and plain string order differ ("a.txt" sorts before "a/...")
+	paths := []string{"a.txt", "a/b", "a/c/d", "a/c/e", "a-z", "b/f", "b/g/h", "c"}
+	for _, path := range paths {
+		if err := store.Store(ctx, path, strings.NewReader(path), "text/plain"); err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+	}
+	all, err := store.ListFiles(ctx, "")
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+	if len(all) != len(paths) {
+		t.Fatalf("Expected %d files, got %d", len(paths), len(all))
+	}
+
+	for _, pageSize := range []int{1, 3, len(paths), len(paths) + 1} {
+		seen := map[string]bool{}
+		token := ""
+		for pages := 0; ; pages++ {
+			if pages > len(paths) {
+				t.Fatalf("Page size %d: paging didn't finish", pageSize)
+			}
+			page, next, err := store.ListFilesPage(ctx, "", token, pageSize)
+			if err != nil {
+				t.Fatalf("ListFilesPage failed: %v", err)
+			}
+			if len(page) > pageSize {
+				t.Errorf("Page size %d: got a page of %d", pageSize, len(page))
+			}
+			for _, file := range page {
+				if seen[file.Path] {
+					t.Errorf("Page size %d: %s listed twice", pageSize, file.Path)
+				}
+				seen[file.Path] = true
+			}
+			if next == "" {
+				break
+			}
+			token = next
+		}
+		if len(seen) != len(paths) {
+			t.Errorf("Page size %d: expected all %d files across pages, got %d", pageSize, len(paths), len(seen))
+		}
+	}
+
+	if page, next, err := store.ListFilesPage(ctx, "b", "", 1); err != nil || len(page) != 1 || next == "" {
+		t.Errorf("Expected the first of two files under b and a token, got %d files, %q (%v)", len(page), next, err)
+	}
+	if page, next, err := store.ListFilesPage(ctx, "missing", "", 10); err != nil || len(page) != 0 || next != "" {
+		t.Errorf("Expected an empty listing for a missing directory, got %d files, %q (%v)", len(page), next, err)
+	}
+}
+
+func TestCollectPages(t *testing.T) {
+	// pages serves total files in pages, counting how many it was asked for
+	pages := func(total int, calls *int) func(context.Context, string, string, int) ([]*FileInfo, string, error) {
+		return func(ctx context.Context, prefix, token string, maxKeys int) ([]*FileInfo, string, error) {
+			*calls++
+			start := 0
+			if token != "" {
+				fmt.Sscanf(token, "%d", &start)
+			}
+			var files []*FileInfo
+			for i := start; i < total && len(files) < maxKeys; i++ {
+				files = append(files, &FileInfo{Path: fmt.Sprintf("file-%d", i)})
+			}
+			if start+len(files) >= total {
+				return files, "", nil
+			}
+			return files, fmt.Sprintf("%d", start+len(files)), nil
+		}
+	}
+
+	var calls int
+	files, err := collectPages(context.Background(), "", pages(2500, &calls))
+	if err != nil || len(files) != 2500 || calls != 3 {
+		t.Errorf("Expected 2500 files in 3 pages, got %d in %d (%v)", len(files), calls, err)
+	}
+
+	calls = 0
+	if _, err := collectPages(context.Background(), "", pages(MaxListedFiles+1, &calls)); !errors.Is(err, ErrTooManyFiles) {
+		t.Errorf("Expected ErrTooManyFiles, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"lokr-backend/pkg/hash"
+)
+
+// ContentPath returns where deduplicated content is stored: enterprise/user/hash or personal/user/hash
+func ContentPath(enterpriseSlug, userID, contentHash string) string {
+	if enterpriseSlug != "" {
+		return fmt.Sprintf("enterprises/%s/users/%s/%s", enterpriseSlug, userID, contentHash)
+	}
+	return fmt.Sprintf("personal/users/%s/%s", userID, contentHash)
+}
+
+// ConvertedPDFPath returns where the PDF rendering of the content at contentPath is cached
+func ConvertedPDFPath(contentPath string) string {
+	return contentPath + "_pdf"
+}
+
+// PreviewImagePath returns where the first page of the PDF with the given content hash is cached
+// as a PNG
+func PreviewImagePath(contentHash string) string {
+	return fmt.Sprintf("previews/%s/page1.png", contentHash)
+}
+
+// PendingUploadPath returns where a direct-to-storage upload waits until it is finalized.
+// Abandoned uploads are best expired with a bucket lifecycle rule on the uploads/pending/ prefix,
+// under the storage prefix when one is configured.
+func PendingUploadPath(userID, uploadID string) string {
+	return fmt.Sprintf("uploads/pending/%s/%s", userID, uploadID)
+}
+
+// UploadChunkPath returns where one chunk of a resumable upload is kept until the upload completes
+func UploadChunkPath(sessionID string, index int) string {
+	return fmt.Sprintf("uploads/sessions/%s/%d", sessionID, index)
+}
+
+// ReadAll returns the whole content at path
+func ReadAll(ctx context.Context, s StorageService, path string) ([]byte, error) {
+	reader, err := s.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// Hash returns the hex hash of the content at path under the given algorithm, reading it as a
+// stream
+func Hash(ctx context.Context, s StorageService, path string, hasher hash.Hasher) (string, error) {
+	reader, err := s.Get(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	h := hasher.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", fmt.Errorf("failed to read stored content: %w", err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
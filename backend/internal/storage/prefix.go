@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// keyPrefix is put before every key a backend hands out, so environments sharing a bucket keep
+// their objects apart. Keys are stored with their prefix, so objects stored before a prefix was
+// configured, or under another one, stay readable.
+type keyPrefix string
+
+// Key returns the storage key for path under the backend's prefix
+func (p keyPrefix) Key(path string) string {
+	if p == "" {
+		return path
+	}
+	return string(p) + "/" + path
+}
+
+// parseKeyPrefix validates a configured prefix such as "staging" or "env/prod", dropping
+// surrounding slashes
+func parseKeyPrefix(prefix string) (keyPrefix, error) {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return "", nil
+	}
+	for _, element := range strings.Split(prefix, "/") {
+		if element == "" || element == "." || element == ".." || strings.ContainsAny(element, `\`) {
+			return "", fmt.Errorf("invalid storage prefix %q: use slash-separated names such as staging or env/prod", prefix)
+		}
+	}
+	return keyPrefix(prefix), nil
+}
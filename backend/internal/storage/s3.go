@@ -25,8 +25,31 @@ type S3Storage struct {
 	bucketName string
 	logger     *zap.Logger
 	region     string
+	sseMode    types.ServerSideEncryption
+	kmsKeyID   string
+	multipart  multipartAPI
+	partSize   int
+	uploads    uploadTracker
+	keyPrefix
 }
 
+// multipartPartSize is the part size content larger than one part is uploaded in
+const multipartPartSize = 16 << 20
+
+// multipartAPI is the part of the S3 client multipart uploads use
+type multipartAPI interface {
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// Server-side encryption modes for S3Config.SSEMode
+const (
+	SSEModeAES256 = string(types.ServerSideEncryptionAes256)
+	SSEModeKMS    = string(types.ServerSideEncryptionAwsKms)
+)
+
 // S3Config contains configuration for S3 storage
 type S3Config struct {
 	Region          string
@@ -35,66 +58,54 @@ type S3Config struct {
 	SecretAccessKey string
 	Endpoint        string // Optional for S3-compatible services like MinIO
 	UsePathStyle    bool   // For S3-compatible services
+	SSEMode         string // AES256 (the default) or aws:kms
+	KMSKeyID        string // KMS key for aws:kms; the bucket's AWS managed key when empty
+}
+
+// sseMode returns the server-side encryption objects are stored with, rejecting unknown modes
+func (c S3Config) sseMode() (types.ServerSideEncryption, error) {
+	switch c.SSEMode {
+	case "", SSEModeAES256:
+		return types.ServerSideEncryptionAes256, nil
+	case SSEModeKMS:
+		return types.ServerSideEncryptionAwsKms, nil
+	default:
+		return "", fmt.Errorf("unsupported S3 server-side encryption mode %q: use %s or %s", c.SSEMode, SSEModeAES256, SSEModeKMS)
+	}
 }
 
 // NewS3Storage creates a new S3 storage service
 func NewS3Storage(config S3Config, logger *zap.Logger) (*S3Storage, error) {
-	var cfg aws.Config
-	var err error
-
-	if config.Endpoint != "" {
-		// Custom endpoint (e.g., MinIO)
-		customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-			return aws.Endpoint{
-				URL:               config.Endpoint,
-				HostnameImmutable: true,
-				SigningRegion:     config.Region,
-			}, nil
-		})
-
-		cfg, err = awsConfig.LoadDefaultConfig(context.TODO(),
-			awsConfig.WithRegion(config.Region),
-			awsConfig.WithEndpointResolverWithOptions(customResolver),
-			awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-				config.AccessKeyID,
-				config.SecretAccessKey,
-				"",
-			)),
-		)
-	} else {
-		// Standard AWS S3
-		if config.AccessKeyID != "" && config.SecretAccessKey != "" {
-			cfg, err = awsConfig.LoadDefaultConfig(context.TODO(),
-				awsConfig.WithRegion(config.Region),
-				awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-					config.AccessKeyID,
-					config.SecretAccessKey,
-					"",
-				)),
-			)
-		} else {
-			// Use default credential chain (IAM roles, environment variables, etc.)
-			cfg, err = awsConfig.LoadDefaultConfig(context.TODO(),
-				awsConfig.WithRegion(config.Region),
-			)
-		}
+	sseMode, err := config.sseMode()
+	if err != nil {
+		return nil, err
 	}
 
+	loadOptions := []func(*awsConfig.LoadOptions) error{awsConfig.WithRegion(config.Region)}
+	if config.AccessKeyID != "" && config.SecretAccessKey != "" {
+		loadOptions = append(loadOptions, awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			config.AccessKeyID,
+			config.SecretAccessKey,
+			"",
+		)))
+	}
+	// Without static credentials, the default chain (IAM roles, environment variables, etc.) is used
+	cfg, err := awsConfig.LoadDefaultConfig(context.TODO(), loadOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		if config.UsePathStyle {
-			o.UsePathStyle = true
-		}
-	})
+	client := s3.NewFromConfig(cfg, s3ClientOptions(config))
 
 	storage := &S3Storage{
 		client:     client,
 		bucketName: config.BucketName,
 		logger:     logger,
 		region:     config.Region,
+		sseMode:    sseMode,
+		kmsKeyID:   config.KMSKeyID,
+		multipart:  client,
+		partSize:   multipartPartSize,
 	}
 
 	// Verify bucket exists and is accessible
@@ -105,6 +116,17 @@ func NewS3Storage(config S3Config, logger *zap.Logger) (*S3Storage, error) {
 	return storage, nil
 }
 
+// s3ClientOptions points the client at a custom endpoint, such as MinIO, and sets the bucket
+// addressing style those services usually need
+func s3ClientOptions(config S3Config) func(*s3.Options) {
+	return func(o *s3.Options) {
+		if config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.Endpoint)
+		}
+		o.UsePathStyle = config.UsePathStyle
+	}
+}
+
 // GenerateS3Path creates the S3 path for a file based on enterprise and user
 // Path structure: enterprise-slug/user-id/content-hash
 func (s *S3Storage) GenerateS3Path(enterpriseSlug string, userID uuid.UUID, contentHash string) string {
@@ -124,24 +146,23 @@ func (s *S3Storage) Store(ctx context.Context, path string, content io.Reader, m
 		zap.String("mime_type", mimeType),
 		zap.String("bucket", s.bucketName))
 
+	ctx, upload, err := s.uploads.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.uploads.finish(upload)
+
 	// Read content into memory to get size
 	contentBytes, err := io.ReadAll(content)
 	if err != nil {
 		return fmt.Errorf("failed to read content: %w", err)
 	}
 
-	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:        aws.String(s.bucketName),
-		Key:           aws.String(path),
-		Body:          bytes.NewReader(contentBytes),
-		ContentType:   aws.String(mimeType),
-		ContentLength: aws.Int64(int64(len(contentBytes))),
-		ServerSideEncryption: types.ServerSideEncryptionAes256,
-		Metadata: map[string]string{
-			"uploaded-at": time.Now().UTC().Format(time.RFC3339),
-			"content-hash": extractContentHashFromPath(path),
-		},
-	})
+	if s.partSize > 0 && len(contentBytes) > s.partSize {
+		err = s.storeMultipart(ctx, upload, path, contentBytes, mimeType)
+	} else {
+		_, err = s.client.PutObject(ctx, s.putObjectInput(path, contentBytes, mimeType))
+	}
 
 	if err != nil {
 		s.logger.Error("Failed to store file in S3",
@@ -157,6 +178,104 @@ func (s *S3Storage) Store(ctx context.Context, path string, content io.Reader, m
 	return nil
 }
 
+// storeMultipart uploads content larger than one part in parts. A failed upload is aborted, as is
+// one still running when Drain gives up on it, so its parts don't linger in the bucket.
+func (s *S3Storage) storeMultipart(ctx context.Context, upload *trackedUpload, path string, content []byte, mimeType string) error {
+	object := s.putObjectInput(path, content, mimeType)
+	created, err := s.multipart.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               object.Bucket,
+		Key:                  object.Key,
+		ContentType:          object.ContentType,
+		ServerSideEncryption: object.ServerSideEncryption,
+		SSEKMSKeyId:          object.SSEKMSKeyId,
+		Metadata:             object.Metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	upload.onAbort(func(ctx context.Context) error {
+		_, err := s.multipart.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   object.Bucket,
+			Key:      object.Key,
+			UploadId: created.UploadId,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to abort multipart upload of %s: %w", path, err)
+		}
+		s.logger.Info("Aborted multipart upload", zap.String("path", path))
+		return nil
+	})
+
+	var parts []types.CompletedPart
+	for offset, number := 0, int32(1); offset < len(content); offset, number = offset+s.partSize, number+1 {
+		end := min(offset+s.partSize, len(content))
+		part, err := s.multipart.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        object.Bucket,
+			Key:           object.Key,
+			UploadId:      created.UploadId,
+			PartNumber:    aws.Int32(number),
+			Body:          bytes.NewReader(content[offset:end]),
+			ContentLength: aws.Int64(int64(end - offset)),
+		})
+		if err != nil {
+			return s.abortMultipart(ctx, upload, fmt.Errorf("failed to upload part %d: %w", number, err))
+		}
+		parts = append(parts, types.CompletedPart{ETag: part.ETag, PartNumber: aws.Int32(number)})
+	}
+
+	_, err = s.multipart.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          object.Bucket,
+		Key:             object.Key,
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return s.abortMultipart(ctx, upload, fmt.Errorf("failed to complete multipart upload: %w", err))
+	}
+	return nil
+}
+
+// abortMultipart aborts a failed multipart upload, even when ctx was cancelled, and returns cause
+func (s *S3Storage) abortMultipart(ctx context.Context, upload *trackedUpload, cause error) error {
+	abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+
+	if err := upload.abort(abortCtx); err != nil {
+		s.logger.Error("Failed to abort multipart upload", zap.Error(err))
+	}
+	return cause
+}
+
+// Drain waits for in-flight uploads to finish until ctx is done, then aborts those still running.
+// Uploads started after Drain is called fail with ErrShuttingDown.
+func (s *S3Storage) Drain(ctx context.Context) error {
+	return s.uploads.drain(ctx)
+}
+
+// putObjectInput describes storing content at path, encrypted with the configured server-side
+// encryption
+func (s *S3Storage) putObjectInput(path string, content []byte, mimeType string) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(s.bucketName),
+		Key:                  aws.String(path),
+		Body:                 bytes.NewReader(content),
+		ContentType:          aws.String(mimeType),
+		ContentLength:        aws.Int64(int64(len(content))),
+		ServerSideEncryption: types.ServerSideEncryptionAes256,
+		Metadata: map[string]string{
+			"uploaded-at":  time.Now().UTC().Format(time.RFC3339),
+			"content-hash": extractContentHashFromPath(path),
+		},
+	}
+	if s.sseMode == types.ServerSideEncryptionAwsKms {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		if s.kmsKeyID != "" {
+			input.SSEKMSKeyId = aws.String(s.kmsKeyID)
+		}
+	}
+	return input
+}
+
 // Get retrieves a file from S3
 func (s *S3Storage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
 	s.logger.Info("Retrieving file from S3",
@@ -264,27 +383,9 @@ func (s *S3Storage) ListFiles(ctx context.Context, prefix string) ([]*FileInfo,
 	s.logger.Info("Listing files in S3",
 		zap.String("prefix", prefix))
 
-	var files []*FileInfo
-
-	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
-		Bucket: aws.String(s.bucketName),
-		Prefix: aws.String(prefix),
-	})
-
-	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list files: %w", err)
-		}
-
-		for _, obj := range output.Contents {
-			files = append(files, &FileInfo{
-				Path:         aws.ToString(obj.Key),
-				Size:         aws.ToInt64(obj.Size),
-				LastModified: aws.ToTime(obj.LastModified),
-				ETag:         strings.Trim(aws.ToString(obj.ETag), "\""),
-			})
-		}
+	files, err := collectPages(ctx, prefix, s.ListFilesPage)
+	if err != nil {
+		return nil, err
 	}
 
 	s.logger.Info("Successfully listed files",
@@ -294,6 +395,39 @@ func (s *S3Storage) ListFiles(ctx context.Context, prefix string) ([]*FileInfo,
 	return files, nil
 }
 
+// ListFilesPage lists one page of files under prefix; the continuation token is S3's own
+func (s *S3Storage) ListFilesPage(ctx context.Context, prefix, continuationToken string, maxKeys int) ([]*FileInfo, string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucketName),
+		Prefix:  aws.String(s.Key(prefix)),
+		MaxKeys: aws.Int32(int32(listPageSize(maxKeys))),
+	}
+	if continuationToken != "" {
+		input.ContinuationToken = aws.String(continuationToken)
+	}
+
+	output, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list files: %w", err)
+	}
+
+	files := make([]*FileInfo, 0, len(output.Contents))
+	for _, obj := range output.Contents {
+		files = append(files, &FileInfo{
+			Path:         aws.ToString(obj.Key),
+			Size:         aws.ToInt64(obj.Size),
+			LastModified: aws.ToTime(obj.LastModified),
+			ETag:         strings.Trim(aws.ToString(obj.ETag), "\""),
+		})
+	}
+
+	next := ""
+	if aws.ToBool(output.IsTruncated) {
+		next = aws.ToString(output.NextContinuationToken)
+	}
+	return files, next, nil
+}
+
 // GeneratePresignedURL generates a presigned URL for downloading a file
 func (s *S3Storage) GeneratePresignedURL(ctx context.Context, path string, expiration time.Duration) (string, error) {
 	presigner := s3.NewPresignClient(s.client)
@@ -313,14 +447,19 @@ func (s *S3Storage) GeneratePresignedURL(ctx context.Context, path string, expir
 }
 
 // GenerateUploadPresignedURL generates a presigned URL for uploading a file
-func (s *S3Storage) GenerateUploadPresignedURL(ctx context.Context, path, mimeType string, expiration time.Duration) (string, error) {
+func (s *S3Storage) GenerateUploadPresignedURL(ctx context.Context, path, mimeType string, size int64, expiration time.Duration) (string, error) {
 	presigner := s3.NewPresignClient(s.client)
 
-	request, err := presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+	input := &s3.PutObjectInput{
 		Bucket:      aws.String(s.bucketName),
 		Key:         aws.String(path),
 		ContentType: aws.String(mimeType),
-	}, func(opts *s3.PresignOptions) {
+	}
+	if size > 0 {
+		input.ContentLength = aws.Int64(size)
+	}
+
+	request, err := presigner.PresignPutObject(ctx, input, func(opts *s3.PresignOptions) {
 		opts.Expires = expiration
 	})
 
@@ -331,6 +470,17 @@ func (s *S3Storage) GenerateUploadPresignedURL(ctx context.Context, path, mimeTy
 	return request.URL, nil
 }
 
+// Ping checks that the bucket is reachable
+func (s *S3Storage) Ping(ctx context.Context) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucketName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reach bucket: %w", err)
+	}
+	return nil
+}
+
 // ensureBucket ensures the bucket exists and is accessible
 func (s *S3Storage) ensureBucket(ctx context.Context) error {
 	// Check if bucket exists
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+func TestS3ClientOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       S3Config
+		wantEndpoint string
+		wantPath     bool
+	}{
+		{"AWS", S3Config{Region: "us-east-1"}, "", false},
+		{"MinIO", S3Config{Region: "us-east-1", Endpoint: "http://minio:9000", UsePathStyle: true}, "http://minio:9000", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options s3.Options
+			s3ClientOptions(tt.config)(&options)
+
+			var endpoint string
+			if options.BaseEndpoint != nil {
+				endpoint = *options.BaseEndpoint
+			}
+			if endpoint != tt.wantEndpoint {
+				t.Errorf("Expected endpoint %q, got %q", tt.wantEndpoint, endpoint)
+			}
+			if options.UsePathStyle != tt.wantPath {
+				t.Errorf("Expected path-style addressing %v, got %v", tt.wantPath, options.UsePathStyle)
+			}
+		})
+	}
+}
+
+func TestS3Storage_PutObjectEncryption(t *testing.T) {
+	const keyID = "arn:aws:kms:us-east-1:111122223333:key/compliance"
+	tests := []struct {
+		name    string
+		config  S3Config
+		wantSSE types.ServerSideEncryption
+		wantKey string
+	}{
+		{"default", S3Config{}, types.ServerSideEncryptionAes256, ""},
+		{"AES256", S3Config{SSEMode: SSEModeAES256, KMSKeyID: keyID}, types.ServerSideEncryptionAes256, ""},
+		{"KMS with a key", S3Config{SSEMode: SSEModeKMS, KMSKeyID: keyID}, types.ServerSideEncryptionAwsKms, keyID},
+		{"KMS with the managed key", S3Config{SSEMode: SSEModeKMS}, types.ServerSideEncryptionAwsKms, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, err := tt.config.sseMode()
+			if err != nil {
+				t.Fatalf("sseMode failed: %v", err)
+			}
+			store := &S3Storage{bucketName: "bucket", sseMode: mode, kmsKeyID: tt.config.KMSKeyID}
+
+			input := store.putObjectInput("personal/user/hash", []byte("content"), "text/plain")
+			if input.ServerSideEncryption != tt.wantSSE {
+				t.Errorf("Expected encryption %q, got %q", tt.wantSSE, input.ServerSideEncryption)
+			}
+			var key string
+			if input.SSEKMSKeyId != nil {
+				key = *input.SSEKMSKeyId
+			}
+			if key != tt.wantKey {
+				t.Errorf("Expected KMS key %q, got %q", tt.wantKey, key)
+			}
+		})
+	}
+
+	if _, err := (S3Config{SSEMode: "aws:kms:dsse"}).sseMode(); err == nil {
+		t.Error("Expected an unsupported encryption mode to be rejected")
+	}
+}
+
+// fakeMultipart records multipart calls; with block set, UploadPart hangs until its context ends
+type fakeMultipart struct {
+	block   bool
+	started chan struct{}
+
+	mu        sync.Mutex
+	parts     int
+	completed bool
+	aborted   []string
+}
+
+func (f *fakeMultipart) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil
+}
+
+func (f *fakeMultipart) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	if f.block {
+		close(f.started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.parts++
+	return &s3.UploadPartOutput{ETag: aws.String("etag")}, nil
+}
+
+func (f *fakeMultipart) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.completed = true
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeMultipart) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.aborted = append(f.aborted, *params.UploadId)
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func TestS3Storage_DrainAbortsInFlightMultipartUpload(t *testing.T) {
+	client := &fakeMultipart{block: true, started: make(chan struct{})}
+	store := &S3Storage{bucketName: "bucket", logger: zap.NewNop(), multipart: client, partSize: 4}
+
+	stored := make(chan error, 1)
+	go func() {
+		stored <- store.Store(context.Background(), "personal/user/hash", strings.NewReader("ten bytes!"), "text/plain")
+	}()
+	<-client.started
+
+	// The grace period runs out while the part is still uploading
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := store.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the drain to report the expired grace period, got %v", err)
+	}
+
+	select {
+	case err := <-stored:
+		if err == nil {
+			t.Error("Expected the abandoned upload to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Upload kept running after the drain aborted it")
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if len(client.aborted) != 1 || client.aborted[0] != "upload-1" {
+		t.Errorf("Expected the multipart upload to be aborted once, got %v", client.aborted)
+	}
+	if client.completed {
+		t.Error("Expected the aborted upload not to be completed")
+	}
+}
+
+func TestS3Storage_DrainWaitsForUploads(t *testing.T) {
+	client := &fakeMultipart{}
+	store := &S3Storage{bucketName: "bucket", logger: zap.NewNop(), multipart: client, partSize: 4}
+
+	if err := store.Store(context.Background(), "personal/user/hash", strings.NewReader("ten bytes!"), "text/plain"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if client.parts != 3 || !client.completed {
+		t.Errorf("Expected 3 parts and a completed upload, got %d parts, completed %v", client.parts, client.completed)
+	}
+
+	if err := store.Drain(context.Background()); err != nil {
+		t.Errorf("Expected nothing to abort, got %v", err)
+	}
+	if len(client.aborted) != 0 {
+		t.Errorf("Expected no aborted uploads, got %v", client.aborted)
+	}
+
+	err := store.Store(context.Background(), "personal/user/other", strings.NewReader("ten bytes!"), "text/plain")
+	if !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Expected uploads after the drain to be refused, got %v", err)
+	}
+}
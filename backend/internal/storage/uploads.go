@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrShuttingDown is returned for uploads started after the backend began draining
+var ErrShuttingDown = errors.New("storage is shutting down")
+
+// abortTimeout bounds aborting an upload once its own context is gone
+const abortTimeout = 10 * time.Second
+
+// uploadTracker tracks in-flight uploads so shutdown can wait for them, and abort those that
+// outlast the grace period rather than leave their parts behind in the bucket
+type uploadTracker struct {
+	mu       sync.Mutex
+	uploads  map[*trackedUpload]struct{}
+	wg       sync.WaitGroup
+	draining bool
+}
+
+// trackedUpload is one in-flight upload. Its abort function is registered once there is
+// something on the backend to clean up.
+type trackedUpload struct {
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	abortFn func(ctx context.Context) error
+	aborted bool
+}
+
+// begin registers an upload, returning a context that is cancelled if the upload is aborted
+func (t *uploadTracker) begin(ctx context.Context) (context.Context, *trackedUpload, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.draining {
+		return nil, nil, ErrShuttingDown
+	}
+	if t.uploads == nil {
+		t.uploads = make(map[*trackedUpload]struct{})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	upload := &trackedUpload{cancel: cancel}
+	t.uploads[upload] = struct{}{}
+	t.wg.Add(1)
+	return ctx, upload, nil
+}
+
+// finish unregisters an upload once it has completed or failed
+func (t *uploadTracker) finish(upload *trackedUpload) {
+	t.mu.Lock()
+	delete(t.uploads, upload)
+	t.mu.Unlock()
+
+	upload.cancel()
+	t.wg.Done()
+}
+
+// drain refuses new uploads and waits for in-flight ones until ctx is done, then cancels and
+// aborts those still running
+func (t *uploadTracker) drain(ctx context.Context) error {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	remaining := make([]*trackedUpload, 0, len(t.uploads))
+	for upload := range t.uploads {
+		remaining = append(remaining, upload)
+	}
+	t.mu.Unlock()
+
+	abortCtx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+
+	errs := []error{fmt.Errorf("aborted %d uploads still in flight: %w", len(remaining), ctx.Err())}
+	for _, upload := range remaining {
+		upload.cancel()
+		if err := upload.abort(abortCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// onAbort registers how to clean up the upload on the backend
+func (u *trackedUpload) onAbort(abort func(ctx context.Context) error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.abortFn = abort
+}
+
+// abort cleans up the upload on the backend, at most once however many callers try
+func (u *trackedUpload) abort(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.abortFn == nil || u.aborted {
+		return nil
+	}
+	u.aborted = true
+	return u.abortFn(ctx)
+}
@@ -0,0 +1,129 @@
+//go:build integration
+
+package testutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"lokr-backend/internal/domain"
+)
+
+// NewTestDB connects to the integration database, skipping the test when none is configured
+func NewTestDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		databaseURL = os.Getenv("DATABASE_URL")
+	}
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping integration test")
+	}
+
+	pool, err := pgxpool.New(context.Background(), databaseURL)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+// CreateEnterprise inserts an enterprise that is removed when the test finishes
+func CreateEnterprise(t *testing.T, db *pgxpool.Pool) uuid.UUID {
+	t.Helper()
+
+	id := uuid.New()
+	slug := "test-" + id.String()[:8]
+	_, err := db.Exec(context.Background(), `
+		INSERT INTO enterprises (id, name, slug)
+		VALUES ($1, $2, $3)`, id, "Test Enterprise "+slug, slug)
+	if err != nil {
+		t.Fatalf("Failed to create enterprise: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Exec(context.Background(), "DELETE FROM enterprises WHERE id = $1", id)
+	})
+
+	return id
+}
+
+// CreateUser inserts a user, optionally attached to an enterprise, that is removed when the test finishes
+func CreateUser(t *testing.T, db *pgxpool.Pool, name string, enterpriseID *uuid.UUID) uuid.UUID {
+	t.Helper()
+
+	id := uuid.New()
+	email := fmt.Sprintf("%s-%s@test.lokr.local", name, id.String()[:8])
+	_, err := db.Exec(context.Background(), `
+		INSERT INTO users (id, email, name, password_hash, enterprise_id, enterprise_role)
+		VALUES ($1, $2, $3, 'x', $4, CASE WHEN $4::uuid IS NULL THEN NULL ELSE 'MEMBER' END)`,
+		id, email, name, enterpriseID)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Exec(context.Background(), "DELETE FROM users WHERE id = $1", id)
+	})
+
+	return id
+}
+
+// CreateFile inserts a file and its content row for the given user; content should be unique per test
+func CreateFile(t *testing.T, db *pgxpool.Pool, userID uuid.UUID, name string, content []byte) uuid.UUID {
+	t.Helper()
+
+	ctx := context.Background()
+	contentHash := fmt.Sprintf("%x", sha256.Sum256(content))
+	_, err := db.Exec(ctx, `
+		INSERT INTO file_contents (content_hash, file_path, file_size, reference_count, created_at)
+		VALUES ($1, $2, $3, 1, NOW())
+		ON CONFLICT (content_hash) DO UPDATE SET reference_count = file_contents.reference_count + 1`,
+		contentHash, fmt.Sprintf("personal/users/%s/%s", userID, contentHash), len(content))
+	if err != nil {
+		t.Fatalf("Failed to create file content: %v", err)
+	}
+
+	id := uuid.New()
+	_, err = db.Exec(ctx, `
+		INSERT INTO files (id, user_id, filename, original_name, mime_type, file_size, content_hash, visibility)
+		VALUES ($1, $2, $3, $3, 'text/plain', $4, $5, 'PRIVATE')`,
+		id, userID, name, len(content), contentHash)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	// Other files may reference the same content, so clear them all before the content row
+	t.Cleanup(func() {
+		db.Exec(context.Background(), "DELETE FROM files WHERE content_hash = $1", contentHash)
+		db.Exec(context.Background(), "DELETE FROM file_contents WHERE content_hash = $1", contentHash)
+	})
+
+	return id
+}
+
+// ShareFile shares a file with another user; the share goes with the file when it's cleaned up
+func ShareFile(t *testing.T, db *pgxpool.Pool, fileID, sharedBy, sharedWith uuid.UUID, permission domain.PermissionType) {
+	t.Helper()
+
+	_, err := db.Exec(context.Background(), `
+		INSERT INTO file_shares (file_id, shared_by_user_id, shared_with_user_id, permission_type)
+		VALUES ($1, $2, $3, $4)`,
+		fileID, sharedBy, sharedWith, permission)
+	if err != nil {
+		t.Fatalf("Failed to share file: %v", err)
+	}
+}
+
+// UserContext returns a context authenticated as the given user
+func UserContext(userID uuid.UUID) context.Context {
+	return context.WithValue(context.Background(), "userID", userID.String())
+}
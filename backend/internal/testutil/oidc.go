@@ -0,0 +1,122 @@
+package testutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCKeyID is the key ID the fake OIDC provider signs with
+const OIDCKeyID = "test-key"
+
+// OIDCProvider is a fake OpenID Connect provider. It serves a discovery document, its signing key
+// and a token endpoint that answers any code with the ID token set by SetIDToken.
+type OIDCProvider struct {
+	Server   *httptest.Server
+	ClientID string
+
+	key     *rsa.PrivateKey
+	mu      sync.Mutex
+	idToken string
+}
+
+// NewOIDCProvider starts a fake OIDC provider that is shut down when the test finishes
+func NewOIDCProvider(t *testing.T) *OIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	provider := &OIDCProvider{ClientID: "lokr-test-client", key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 provider.Issuer(),
+			"authorization_endpoint": provider.Issuer() + "/authorize",
+			"token_endpoint":         provider.Issuer() + "/token",
+			"jwks_uri":               provider.Issuer() + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": OIDCKeyID,
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("code") == "" {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		provider.mu.Lock()
+		idToken := provider.idToken
+		provider.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+
+	provider.Server = httptest.NewServer(mux)
+	t.Cleanup(provider.Server.Close)
+
+	return provider
+}
+
+// Issuer returns the fake provider's issuer identifier
+func (p *OIDCProvider) Issuer() string {
+	return p.Server.URL
+}
+
+// IDToken signs an ID token for the subject. The issuer, audience and lifetimes are filled in and
+// claims adds to or overrides them.
+func (p *OIDCProvider) IDToken(t *testing.T, subject string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	all := jwt.MapClaims{
+		"iss": p.Issuer(),
+		"aud": p.ClientID,
+		"sub": subject,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		all[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, all)
+	token.Header["kid"] = OIDCKeyID
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		t.Fatalf("Failed to sign ID token: %v", err)
+	}
+	return signed
+}
+
+// SetIDToken sets the ID token the token endpoint returns
+func (p *OIDCProvider) SetIDToken(idToken string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idToken = idToken
+}
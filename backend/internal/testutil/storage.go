@@ -0,0 +1,137 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"lokr-backend/internal/storage"
+)
+
+// NewLocalStorage returns the local storage backend over a temporary directory, and the directory
+func NewLocalStorage(t *testing.T) (storage.StorageService, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	store, err := storage.NewStorageService(storage.StorageConfig{
+		Backend: "local",
+		Local:   storage.LocalConfig{BasePath: dir},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create local storage: %v", err)
+	}
+	return store, dir
+}
+
+// MemoryStorage is a storage backend that keeps objects in memory
+type MemoryStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: map[string][]byte{}}
+}
+
+// Object returns the content stored at path, and whether there is any
+func (m *MemoryStorage) Object(path string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	content, ok := m.objects[path]
+	return content, ok
+}
+
+func (m *MemoryStorage) Store(ctx context.Context, path string, content io.Reader, mimeType string) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[path] = data
+	return nil
+}
+
+func (m *MemoryStorage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	content, ok := m.Object(path)
+	if !ok {
+		return nil, fmt.Errorf("object not found: %s", path)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (m *MemoryStorage) Delete(ctx context.Context, path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, path)
+	return nil
+}
+
+func (m *MemoryStorage) Exists(ctx context.Context, path string) (bool, error) {
+	_, ok := m.Object(path)
+	return ok, nil
+}
+
+func (m *MemoryStorage) GetFileInfo(ctx context.Context, path string) (*storage.FileInfo, error) {
+	content, ok := m.Object(path)
+	if !ok {
+		return nil, fmt.Errorf("object not found: %s", path)
+	}
+	return &storage.FileInfo{Path: path, Size: int64(len(content)), LastModified: time.Now()}, nil
+}
+
+func (m *MemoryStorage) ListFiles(ctx context.Context, prefix string) ([]*storage.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var files []*storage.FileInfo
+	for path, content := range m.objects {
+		if strings.HasPrefix(path, prefix) {
+			files = append(files, &storage.FileInfo{Path: path, Size: int64(len(content))})
+		}
+	}
+	return files, nil
+}
+
+// ListFilesPage pages through the objects in path order; the token is the last path returned
+func (m *MemoryStorage) ListFilesPage(ctx context.Context, prefix, continuationToken string, maxKeys int) ([]*storage.FileInfo, string, error) {
+	if maxKeys <= 0 || maxKeys > storage.DefaultListPageSize {
+		maxKeys = storage.DefaultListPageSize
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var paths []string
+	for path := range m.objects {
+		if strings.HasPrefix(path, prefix) && path > continuationToken {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	next := ""
+	if len(paths) > maxKeys {
+		paths = paths[:maxKeys]
+		next = paths[maxKeys-1]
+	}
+	files := make([]*storage.FileInfo, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, &storage.FileInfo{Path: path, Size: int64(len(m.objects[path]))})
+	}
+	return files, next, nil
+}
+
+func (m *MemoryStorage) Key(path string) string {
+	return path
+}
+
+func (m *MemoryStorage) Ping(ctx context.Context) error {
+	return nil
+}
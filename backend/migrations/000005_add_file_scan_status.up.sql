@@ -0,0 +1,18 @@
+-- Track the malware scan state of each file
+ALTER TABLE files ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE';
+
+ALTER TABLE files ADD CONSTRAINT chk_files_status
+    CHECK (status IN ('ACTIVE', 'PENDING_SCAN', 'INFECTED'));
+
+CREATE INDEX IF NOT EXISTS idx_files_status ON files(status) WHERE status <> 'ACTIVE';
+
+-- Allow quarantine events in the audit log
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS chk_audit_logs_action;
+ALTER TABLE audit_logs ADD CONSTRAINT chk_audit_logs_action
+    CHECK (action IN (
+        'FILE_UPLOAD', 'FILE_DOWNLOAD', 'FILE_PREVIEW', 'FILE_DELETE', 'FILE_MOVE', 'FILE_RENAME',
+        'FILE_QUARANTINE',
+        'FILE_SHARE', 'FILE_UNSHARE', 'PUBLIC_SHARE', 'PUBLIC_UNSHARE',
+        'FOLDER_CREATE', 'FOLDER_DELETE', 'FOLDER_MOVE', 'FOLDER_RENAME',
+        'USER_LOGIN', 'USER_LOGOUT', 'USER_REGISTER'
+    ));
@@ -0,0 +1,11 @@
+DELETE FROM audit_logs WHERE action = 'QUOTA_UPDATE';
+
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS chk_audit_logs_action;
+ALTER TABLE audit_logs ADD CONSTRAINT chk_audit_logs_action
+    CHECK (action IN (
+        'FILE_UPLOAD', 'FILE_DOWNLOAD', 'FILE_PREVIEW', 'FILE_DELETE', 'FILE_MOVE', 'FILE_RENAME',
+        'FILE_QUARANTINE',
+        'FILE_SHARE', 'FILE_UNSHARE', 'PUBLIC_SHARE', 'PUBLIC_UNSHARE',
+        'FOLDER_CREATE', 'FOLDER_DELETE', 'FOLDER_MOVE', 'FOLDER_RENAME',
+        'USER_LOGIN', 'USER_LOGOUT', 'USER_REGISTER'
+    ));
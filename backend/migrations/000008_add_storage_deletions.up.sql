@@ -0,0 +1,8 @@
+-- Storage objects whose deletion failed after their content record was removed, retried in the background
+CREATE TABLE IF NOT EXISTS storage_deletions (
+    file_path TEXT PRIMARY KEY,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
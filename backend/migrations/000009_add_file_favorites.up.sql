@@ -0,0 +1,9 @@
+-- Files a user has starred for quick access; a user may star their own files and files shared with them
+CREATE TABLE IF NOT EXISTS file_favorites (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, file_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_favorites_file_id ON file_favorites(file_id);
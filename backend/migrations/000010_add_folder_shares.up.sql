@@ -0,0 +1,25 @@
+-- Folders shared with another user; a share covers the folder, every subfolder at any depth and
+-- every file inside them, including ones added after the share was made
+CREATE TABLE IF NOT EXISTS folder_shares (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    folder_id UUID NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    shared_by_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shared_with_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission_type VARCHAR(50) NOT NULL DEFAULT 'VIEW' CHECK (permission_type IN ('VIEW', 'DOWNLOAD', 'EDIT', 'DELETE')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE(folder_id, shared_with_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_folder_shares_shared_with ON folder_shares(shared_with_user_id);
+
+-- Allow folder shares in the audit log
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS chk_audit_logs_action;
+ALTER TABLE audit_logs ADD CONSTRAINT chk_audit_logs_action
+    CHECK (action IN (
+        'FILE_UPLOAD', 'FILE_DOWNLOAD', 'FILE_PREVIEW', 'FILE_DELETE', 'FILE_MOVE', 'FILE_RENAME',
+        'FILE_QUARANTINE',
+        'FILE_SHARE', 'FILE_UNSHARE', 'PUBLIC_SHARE', 'PUBLIC_UNSHARE', 'FOLDER_SHARE',
+        'FOLDER_CREATE', 'FOLDER_DELETE', 'FOLDER_MOVE', 'FOLDER_RENAME',
+        'USER_LOGIN', 'USER_LOGOUT', 'USER_REGISTER',
+        'QUOTA_UPDATE'
+    ));
@@ -0,0 +1,6 @@
+-- Link users to an OpenID Connect identity; a provider's subject identifies the account across logins
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_issuer VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc_identity ON users(oidc_issuer, oidc_subject)
+    WHERE oidc_subject IS NOT NULL;
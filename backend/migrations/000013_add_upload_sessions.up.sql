@@ -0,0 +1,24 @@
+-- Resumable uploads: a session collects an upload's chunks until it is completed or expires
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(255) NOT NULL,
+    folder_id UUID REFERENCES folders(id) ON DELETE SET NULL,
+    file_size BIGINT NOT NULL CHECK (file_size > 0),
+    chunk_size BIGINT NOT NULL CHECK (chunk_size > 0),
+    chunk_count INTEGER NOT NULL CHECK (chunk_count > 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
+
+-- Chunks received for a session; each is stored as its own object until the session completes
+CREATE TABLE IF NOT EXISTS upload_session_chunks (
+    session_id UUID NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    chunk_index INTEGER NOT NULL CHECK (chunk_index >= 0),
+    chunk_size BIGINT NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (session_id, chunk_index)
+);
@@ -0,0 +1,8 @@
+-- Keep quarantined files unavailable rather than serving corrupted content
+UPDATE files SET status = 'INFECTED' WHERE status = 'CORRUPTED';
+
+ALTER TABLE files DROP CONSTRAINT IF EXISTS chk_files_status;
+ALTER TABLE files ADD CONSTRAINT chk_files_status
+    CHECK (status IN ('ACTIVE', 'PENDING_SCAN', 'INFECTED'));
+
+DROP TABLE IF EXISTS integrity_issues;
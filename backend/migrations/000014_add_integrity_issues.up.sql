@@ -0,0 +1,13 @@
+-- Stored content that failed integrity verification: missing from storage or not matching its hash
+CREATE TABLE IF NOT EXISTS integrity_issues (
+    content_hash VARCHAR(64) PRIMARY KEY REFERENCES file_contents(content_hash) ON DELETE CASCADE,
+    file_path TEXT NOT NULL,
+    issue VARCHAR(20) NOT NULL CHECK (issue IN ('MISSING', 'HASH_MISMATCH')),
+    actual_hash VARCHAR(64),
+    detected_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Files whose content failed verification can be quarantined
+ALTER TABLE files DROP CONSTRAINT IF EXISTS chk_files_status;
+ALTER TABLE files ADD CONSTRAINT chk_files_status
+    CHECK (status IN ('ACTIVE', 'PENDING_SCAN', 'INFECTED', 'CORRUPTED'));
@@ -0,0 +1,11 @@
+-- Each download or preview of a file, for per-file access charts
+CREATE TABLE IF NOT EXISTS file_access_events (
+    id BIGSERIAL PRIMARY KEY,
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL, -- NULL for public share links
+    ip_address INET,
+    access_type VARCHAR(20) NOT NULL CHECK (access_type IN ('DOWNLOAD', 'PREVIEW')),
+    accessed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_access_events_file_accessed ON file_access_events(file_id, accessed_at);
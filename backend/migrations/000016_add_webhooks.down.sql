@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS webhook_deliveries CASCADE;
+DROP TABLE IF EXISTS enterprise_webhooks CASCADE;
@@ -0,0 +1,30 @@
+-- Outbound webhooks: enterprises register endpoints that are sent their members' audit events
+CREATE TABLE IF NOT EXISTS enterprise_webhooks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    enterprise_id UUID NOT NULL REFERENCES enterprises(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    -- Audit actions to deliver; empty delivers every action
+    events TEXT[] NOT NULL DEFAULT '{}',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_enterprise_webhooks_enterprise_id ON enterprise_webhooks(enterprise_id);
+
+-- One event queued for one webhook. Failed deliveries are retried with backoff until they
+-- succeed or run out of attempts, when they are kept as DEAD for inspection.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    webhook_id UUID NOT NULL REFERENCES enterprise_webhooks(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'DELIVERED', 'DEAD')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'PENDING';
@@ -0,0 +1,8 @@
+-- Edit locks on files. A lock lapses ttl_seconds after it was acquired, so a client that goes away
+-- can't keep a file locked; lapsed rows are ignored and replaced by the next lock.
+CREATE TABLE IF NOT EXISTS file_locks (
+    file_id UUID PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    holder_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    acquired_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ttl_seconds INTEGER NOT NULL CHECK (ttl_seconds > 0)
+);
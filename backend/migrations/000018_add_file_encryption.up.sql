@@ -0,0 +1,9 @@
+-- Files the client encrypted before uploading. The server only ever holds the ciphertext; this is
+-- what the client needs to decrypt it again, opaque to the server. A file without a row is plain.
+CREATE TABLE IF NOT EXISTS file_encryption (
+    file_id UUID PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    algorithm VARCHAR(50) NOT NULL,
+    iv TEXT NOT NULL,
+    wrapped_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
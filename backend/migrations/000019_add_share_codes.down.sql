@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_files_share_code;
+ALTER TABLE files DROP COLUMN IF EXISTS share_code;
@@ -0,0 +1,4 @@
+-- Short codes for public share links (/s/<code>), handed out alongside the long share token when
+-- short links are enabled. The long token keeps working either way.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS share_code VARCHAR(16);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_files_share_code ON files(share_code) WHERE share_code IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE file_contents DROP COLUMN IF EXISTS hash_algorithm;
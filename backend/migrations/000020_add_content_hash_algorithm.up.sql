@@ -0,0 +1,3 @@
+-- The algorithm each content hash was computed with (see CONTENT_HASH_ALGORITHM). Content stored
+-- before it was configurable is SHA-256; deduplication only ever matches hashes of one algorithm.
+ALTER TABLE file_contents ADD COLUMN IF NOT EXISTS hash_algorithm VARCHAR(32) NOT NULL DEFAULT 'sha256';
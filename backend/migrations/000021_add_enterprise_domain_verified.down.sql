@@ -0,0 +1 @@
+ALTER TABLE enterprises DROP COLUMN IF EXISTS domain_verified;
@@ -0,0 +1,4 @@
+-- Whether a platform admin vouched for an enterprise's domain. Only a verified domain lets users
+-- signing in with a matching email join the enterprise on their own; domains set before this
+-- have to be set again by a platform admin.
+ALTER TABLE enterprises ADD COLUMN IF NOT EXISTS domain_verified BOOLEAN NOT NULL DEFAULT FALSE;
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
@@ -12,6 +15,27 @@ import (
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("expired token")
+	// ErrInsecureSecret rejects an HS256 secret that is the well-known default or too short
+	ErrInsecureSecret = errors.New("insecure JWT secret")
+)
+
+// Signing algorithms a JWTManager can use
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+)
+
+// DefaultSecret is the development fallback used when no secret is configured. It is public, so it
+// must never sign tokens in production.
+const DefaultSecret = "your-secret-key-change-in-production"
+
+// MinSecretLength is the shortest HS256 secret, in bytes, accepted by CheckSecret
+const MinSecretLength = 32
+
+// Default token lifetimes, used when JWTConfig leaves them zero
+const (
+	DefaultAccessTokenTTL  = 24 * time.Hour
+	DefaultRefreshTokenTTL = 7 * 24 * time.Hour
 )
 
 // Claims represents the JWT claims
@@ -22,16 +46,177 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// JWTConfig selects how tokens are signed and how long they last. HS256 signs with Secret; RS256
+// signs with PrivateKeyPEM (PKCS#1 or PKCS#8) and verifies with its public half.
+//
+// PreviousSecrets (HS256) and PreviousPublicKeysPEM (RS256) are keys that signed tokens before a
+// rotation. They only verify tokens, so sessions outlive the rotation until their tokens expire.
+type JWTConfig struct {
+	Algorithm             string
+	Secret                string
+	PrivateKeyPEM         []byte
+	PreviousSecrets       []string
+	PreviousPublicKeysPEM [][]byte
+	AccessTokenTTL        time.Duration
+	RefreshTokenTTL       time.Duration
+}
+
+// jwtKey is one key tokens may be signed with. Its id, derived from the key itself, is written to
+// the kid header of the tokens it signs; sign is nil for keys kept only for verification.
+type jwtKey struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
 // JWTManager manages JWT tokens
 type JWTManager struct {
-	secretKey []byte
+	// keys holds the signing key first, then the previous keys in the order they were configured
+	keys       []jwtKey
+	accessTTL  time.Duration
+	refreshTTL time.Duration
 }
 
-// NewJWTManager creates a new JWT manager
+// NewJWTManager creates a JWT manager signing HS256 tokens with the default lifetimes
 func NewJWTManager(secretKey string) *JWTManager {
 	return &JWTManager{
-		secretKey: []byte(secretKey),
+		keys:       []jwtKey{hmacKey(secretKey, true)},
+		accessTTL:  DefaultAccessTokenTTL,
+		refreshTTL: DefaultRefreshTokenTTL,
+	}
+}
+
+// keyID derives a kid from key material. It is a truncated hash, so it names the key without
+// revealing it.
+func keyID(material []byte) string {
+	sum := sha256.Sum256(material)
+	return hex.EncodeToString(sum[:8])
+}
+
+// hmacKey is the HS256 key for secret, able to sign when signing is set
+func hmacKey(secret string, signing bool) jwtKey {
+	key := jwtKey{id: keyID([]byte(secret)), method: jwt.SigningMethodHS256, verify: []byte(secret)}
+	if signing {
+		key.sign = key.verify
 	}
+	return key
+}
+
+// rsaPublicKey is the verification-only RS256 key in publicKeyPEM
+func rsaPublicKey(publicKeyPEM []byte) (jwtKey, error) {
+	publicKey, err := jwt.ParseRSAPublicKeyFromPEM(publicKeyPEM)
+	if err != nil {
+		return jwtKey{}, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return jwtKey{}, err
+	}
+	return jwtKey{id: keyID(der), method: jwt.SigningMethodRS256, verify: publicKey}, nil
+}
+
+// NewJWTManagerFromConfig creates a JWT manager for the configured algorithm and lifetimes. It
+// doesn't judge the secret's strength; see CheckSecret.
+func NewJWTManagerFromConfig(config JWTConfig) (*JWTManager, error) {
+	if config.AccessTokenTTL < 0 || config.RefreshTokenTTL < 0 {
+		return nil, fmt.Errorf("token lifetimes cannot be negative")
+	}
+
+	var manager *JWTManager
+	switch config.Algorithm {
+	case "", AlgorithmHS256:
+		if config.Secret == "" {
+			return nil, fmt.Errorf("HS256 requires a secret")
+		}
+		manager = NewJWTManager(config.Secret)
+	case AlgorithmRS256:
+		if len(config.PrivateKeyPEM) == 0 {
+			return nil, fmt.Errorf("RS256 requires a private key")
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(config.PrivateKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RS256 private key: %w", err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RS256 private key: %w", err)
+		}
+		manager = &JWTManager{
+			keys: []jwtKey{{
+				id:     keyID(der),
+				method: jwt.SigningMethodRS256,
+				sign:   privateKey,
+				verify: &privateKey.PublicKey,
+			}},
+			accessTTL:  DefaultAccessTokenTTL,
+			refreshTTL: DefaultRefreshTokenTTL,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", config.Algorithm)
+	}
+
+	for _, secret := range config.PreviousSecrets {
+		if secret == "" {
+			return nil, fmt.Errorf("previous HS256 secrets cannot be empty")
+		}
+		manager.addVerificationKey(hmacKey(secret, false))
+	}
+	for i, publicKeyPEM := range config.PreviousPublicKeysPEM {
+		key, err := rsaPublicKey(publicKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous RS256 public key %d: %w", i+1, err)
+		}
+		manager.addVerificationKey(key)
+	}
+
+	if config.AccessTokenTTL > 0 {
+		manager.accessTTL = config.AccessTokenTTL
+	}
+	if config.RefreshTokenTTL > 0 {
+		manager.refreshTTL = config.RefreshTokenTTL
+	}
+	return manager, nil
+}
+
+// CheckSecret rejects an HS256 secret that is empty, the public DefaultSecret or shorter than
+// MinSecretLength bytes
+func CheckSecret(secret string) error {
+	if secret == "" || secret == DefaultSecret {
+		return fmt.Errorf("%w: set JWT_SECRET to a random value", ErrInsecureSecret)
+	}
+	if len(secret) < MinSecretLength {
+		return fmt.Errorf("%w: must be at least %d bytes, got %d", ErrInsecureSecret, MinSecretLength, len(secret))
+	}
+	return nil
+}
+
+// addVerificationKey keeps key for verifying tokens, unless a key with the same id is already kept
+func (manager *JWTManager) addVerificationKey(key jwtKey) {
+	for _, existing := range manager.keys {
+		if existing.id == key.id {
+			return
+		}
+	}
+	manager.keys = append(manager.keys, key)
+}
+
+// Algorithm returns the name of the algorithm tokens are signed with
+func (manager *JWTManager) Algorithm() string {
+	return manager.keys[0].method.Alg()
+}
+
+// KeyID returns the kid of the key new tokens are signed with
+func (manager *JWTManager) KeyID() string {
+	return manager.keys[0].id
+}
+
+// sign signs claims with the current key, naming it in the kid header
+func (manager *JWTManager) sign(claims Claims) (string, error) {
+	key := manager.keys[0]
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.sign)
 }
 
 // GenerateToken generates a new JWT token
@@ -43,15 +228,14 @@ func (manager *JWTManager) GenerateToken(userID, email, role string) (string, er
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        uuid.New().String(),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24 hours
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(manager.accessTTL)),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "lokr-api",
 			Subject:   userID,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(manager.secretKey)
+	return manager.sign(claims)
 }
 
 // GenerateRefreshToken generates a refresh token with longer expiration
@@ -61,25 +245,58 @@ func (manager *JWTManager) GenerateRefreshToken(userID string) (string, error) {
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        uuid.New().String(),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7 days
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(manager.refreshTTL)),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "lokr-api",
 			Subject:   userID,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(manager.secretKey)
+	return manager.sign(claims)
 }
 
-// ValidateToken validates and parses a JWT token
+// ValidateToken validates and parses a JWT token. A token naming a known key in its kid header is
+// checked against that key alone; one without a kid, or naming a key no longer kept, is tried
+// against each key in turn.
 func (manager *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	candidates := manager.keys
+	if kid := tokenKeyID(tokenString); kid != "" {
+		for _, key := range manager.keys {
+			if key.id == kid {
+				candidates = []jwtKey{key}
+				break
+			}
+		}
+	}
+
+	err := ErrInvalidToken
+	for _, key := range candidates {
+		var claims *Claims
+		claims, err = key.validate(tokenString)
+		if err == nil || errors.Is(err, ErrExpiredToken) {
+			return claims, err
 		}
-		return manager.secretKey, nil
-	})
+	}
+	return nil, err
+}
+
+// tokenKeyID reads the kid header of a token without verifying it, or returns "" if it has none
+func tokenKeyID(tokenString string) string {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &Claims{})
+	if err != nil {
+		return ""
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+// validate checks a token's signature against key and parses its claims
+func (key jwtKey) validate(tokenString string) (*Claims, error) {
+	// Only the key's own algorithm is accepted, so an HS256 token can't be signed with an RS256
+	// public key posing as a secret
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return key.verify, nil
+	}, jwt.WithValidMethods([]string{key.method.Alg()}))
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// rsaKeyPEM generates a PKCS#1 RSA private key and its PKIX public key, both PEM-encoded
+func rsaKeyPEM(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+}
+
+func TestJWTManager_RS256(t *testing.T) {
+	privatePEM, publicPEM := rsaKeyPEM(t)
+	manager, err := NewJWTManagerFromConfig(JWTConfig{Algorithm: AlgorithmRS256, PrivateKeyPEM: privatePEM})
+	if err != nil {
+		t.Fatalf("NewJWTManagerFromConfig failed: %v", err)
+	}
+
+	token, err := manager.GenerateToken("user-1", "user@example.com", "USER")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	claims, err := manager.ValidateToken(token)
+	if err != nil || claims.UserID != "user-1" {
+		t.Fatalf("Expected the RS256 token to validate, got %+v, %v", claims, err)
+	}
+
+	// An HS256 token keyed with the public key must not pass for an RS256 one
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(publicPEM)
+	if err != nil {
+		t.Fatalf("Failed to sign forged token: %v", err)
+	}
+	if _, err := manager.ValidateToken(forged); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a forged HS256 token to be rejected, got %v", err)
+	}
+
+	// Tokens from an HS256 manager aren't accepted either
+	hsToken, _ := NewJWTManager("another-secret").GenerateToken("user-1", "user@example.com", "USER")
+	if _, err := manager.ValidateToken(hsToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an HS256 token to be rejected, got %v", err)
+	}
+}
+
+func TestJWTManager_Lifetimes(t *testing.T) {
+	manager, err := NewJWTManagerFromConfig(JWTConfig{
+		Secret:          "lifetime-secret",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 48 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewJWTManagerFromConfig failed: %v", err)
+	}
+
+	access, _ := manager.GenerateToken("user-1", "user@example.com", "USER")
+	refresh, _ := manager.GenerateRefreshToken("user-1")
+	for _, tt := range []struct {
+		name  string
+		token string
+		ttl   time.Duration
+	}{
+		{"access", access, 15 * time.Minute},
+		{"refresh", refresh, 48 * time.Hour},
+	} {
+		claims, err := manager.ValidateToken(tt.token)
+		if err != nil {
+			t.Fatalf("%s: ValidateToken failed: %v", tt.name, err)
+		}
+		if lifetime := claims.ExpiresAt.Sub(claims.IssuedAt.Time); lifetime != tt.ttl {
+			t.Errorf("%s: expected a lifetime of %s, got %s", tt.name, tt.ttl, lifetime)
+		}
+	}
+
+	expired, _ := NewJWTManagerFromConfig(JWTConfig{Secret: "lifetime-secret", AccessTokenTTL: time.Nanosecond})
+	token, _ := expired.GenerateToken("user-1", "user@example.com", "USER")
+	time.Sleep(time.Second)
+	if _, err := expired.ValidateToken(token); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("Expected an expired token, got %v", err)
+	}
+}
+
+func TestJWTManager_Rotation(t *testing.T) {
+	old := NewJWTManager("old-rotation-secret")
+	oldToken, _ := old.GenerateToken("user-1", "user@example.com", "USER")
+	oldRefresh, _ := old.GenerateRefreshToken("user-1")
+
+	// Tokens issued before key ids existed carry no kid
+	legacyClaims, _ := old.ValidateToken(oldToken)
+	legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, legacyClaims).SignedString([]byte("old-rotation-secret"))
+	if err != nil {
+		t.Fatalf("Failed to sign legacy token: %v", err)
+	}
+
+	rotated, err := NewJWTManagerFromConfig(JWTConfig{Secret: "new-rotation-secret", PreviousSecrets: []string{"old-rotation-secret"}})
+	if err != nil {
+		t.Fatalf("NewJWTManagerFromConfig failed: %v", err)
+	}
+	if rotated.KeyID() == old.KeyID() {
+		t.Fatal("Expected the new secret to get a different key id")
+	}
+
+	for name, token := range map[string]string{"access": oldToken, "refresh": oldRefresh, "legacy": legacyToken} {
+		if claims, err := rotated.ValidateToken(token); err != nil || claims.UserID != "user-1" {
+			t.Errorf("Expected the old %s token to still verify, got %+v, %v", name, claims, err)
+		}
+	}
+
+	newToken, _ := rotated.GenerateToken("user-2", "other@example.com", "USER")
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	if err != nil || parsed.Header["kid"] != rotated.KeyID() {
+		t.Errorf("Expected new tokens to name the new key, got %v, %v", parsed.Header["kid"], err)
+	}
+	if _, err := old.ValidateToken(newToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected new tokens to be signed with the new secret, got %v", err)
+	}
+	if claims, err := rotated.ValidateToken(newToken); err != nil || claims.UserID != "user-2" {
+		t.Errorf("Expected the new token to verify, got %+v, %v", claims, err)
+	}
+
+	// Once the old secret is dropped its tokens stop working
+	if _, err := NewJWTManager("new-rotation-secret").ValidateToken(oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the old token to be rejected after the old secret is dropped, got %v", err)
+	}
+
+	// A token claiming the new key's kid is checked against that key only
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, legacyClaims)
+	forged.Header["kid"] = rotated.KeyID()
+	forgedToken, _ := forged.SignedString([]byte("old-rotation-secret"))
+	if _, err := rotated.ValidateToken(forgedToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token naming the wrong key to be rejected, got %v", err)
+	}
+}
+
+func TestJWTManager_RS256Rotation(t *testing.T) {
+	oldPrivate, oldPublic := rsaKeyPEM(t)
+	newPrivate, _ := rsaKeyPEM(t)
+
+	old, err := NewJWTManagerFromConfig(JWTConfig{Algorithm: AlgorithmRS256, PrivateKeyPEM: oldPrivate})
+	if err != nil {
+		t.Fatalf("NewJWTManagerFromConfig failed: %v", err)
+	}
+	oldToken, _ := old.GenerateToken("user-1", "user@example.com", "USER")
+
+	rotated, err := NewJWTManagerFromConfig(JWTConfig{
+		Algorithm:             AlgorithmRS256,
+		PrivateKeyPEM:         newPrivate,
+		PreviousPublicKeysPEM: [][]byte{oldPublic},
+	})
+	if err != nil {
+		t.Fatalf("NewJWTManagerFromConfig failed: %v", err)
+	}
+	if claims, err := rotated.ValidateToken(oldToken); err != nil || claims.UserID != "user-1" {
+		t.Errorf("Expected the token signed with the old key to verify, got %+v, %v", claims, err)
+	}
+	newToken, _ := rotated.GenerateToken("user-1", "user@example.com", "USER")
+	if _, err := old.ValidateToken(newToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected new tokens to be signed with the new key, got %v", err)
+	}
+
+	if _, err := NewJWTManagerFromConfig(JWTConfig{Secret: "s", PreviousPublicKeysPEM: [][]byte{[]byte("not a key")}}); err == nil {
+		t.Error("Expected a malformed previous public key to be rejected")
+	}
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig identifies this application to an OpenID Connect provider. For Google the issuer is
+// https://accounts.google.com.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// OIDCIdentity is the user an ID token vouches for
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// jwksRefreshInterval limits how often an unknown key ID makes the provider's keys be fetched again
+const jwksRefreshInterval = time.Minute
+
+// OIDCProvider runs the authorization code flow against an OpenID Connect provider and verifies the
+// RS256 ID tokens it returns against the provider's published keys
+type OIDCProvider struct {
+	config  *oauth2.Config
+	issuer  string
+	jwksURI string
+	client  *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+// idTokenClaims are the ID token claims Lokr reads
+type idTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Nonce         string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// NewOIDCProvider reads the provider's discovery document from the issuer. client makes every
+// request to the provider; nil uses http.DefaultClient.
+func NewOIDCProvider(ctx context.Context, config OIDCConfig, client *http.Client) (*OIDCProvider, error) {
+	if config.Issuer == "" || config.ClientID == "" || config.ClientSecret == "" || config.RedirectURL == "" {
+		return nil, fmt.Errorf("OIDC requires an issuer, client ID, client secret and redirect URL")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	issuer := strings.TrimSuffix(config.Issuer, "/")
+	if err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+	if discovery.Issuer != issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer %q does not match %q", discovery.Issuer, issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document is missing endpoints")
+	}
+
+	return &OIDCProvider{
+		config: &oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  discovery.AuthorizationEndpoint,
+				TokenURL: discovery.TokenEndpoint,
+			},
+		},
+		issuer:  discovery.Issuer,
+		jwksURI: discovery.JWKSURI,
+		client:  client,
+	}, nil
+}
+
+// Issuer returns the provider's issuer identifier; with a subject it names an identity
+func (p *OIDCProvider) Issuer() string {
+	return p.issuer
+}
+
+// AuthCodeURL is where to send the user to sign in. state comes back on the callback; nonce comes
+// back inside the ID token.
+func (p *OIDCProvider) AuthCodeURL(state, nonce string) string {
+	return p.config.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce))
+}
+
+// Exchange trades an authorization code for tokens and returns the identity in the verified ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce string) (*OIDCIdentity, error) {
+	token, err := p.config.Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.client), code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no ID token", ErrInvalidToken)
+	}
+	return p.VerifyIDToken(ctx, rawIDToken, nonce)
+}
+
+// VerifyIDToken checks an ID token's signature against the provider's keys, its issuer, audience,
+// expiry and nonce, and returns the identity it vouches for
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCIdentity, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{AlgorithmRS256}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithLeeway(time.Minute))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	switch {
+	case claims.ExpiresAt == nil:
+		return nil, fmt.Errorf("%w: ID token has no expiry", ErrInvalidToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: ID token has no subject", ErrInvalidToken)
+	case nonce == "" || claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: ID token nonce does not match", ErrInvalidToken)
+	}
+
+	return &OIDCIdentity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// key returns the provider's signing key with the given ID, fetching the key set again when the ID
+// is unknown (providers rotate keys) but no more than once per jwksRefreshInterval
+func (p *OIDCProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, p.client, p.jwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// getJSON fetches url and decodes its JSON body into v
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"lokr-backend/internal/testutil"
+)
+
+func newTestOIDCProvider(t *testing.T) (*OIDCProvider, *testutil.OIDCProvider) {
+	t.Helper()
+
+	fake := testutil.NewOIDCProvider(t)
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		Issuer:       fake.Issuer(),
+		ClientID:     fake.ClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "https://lokr.example/auth/oidc/callback",
+	}, fake.Server.Client())
+	if err != nil {
+		t.Fatalf("NewOIDCProvider failed: %v", err)
+	}
+	return provider, fake
+}
+
+func TestOIDCProvider_AuthCodeURL(t *testing.T) {
+	provider, fake := newTestOIDCProvider(t)
+
+	authURL, err := url.Parse(provider.AuthCodeURL("the-state", "the-nonce"))
+	if err != nil {
+		t.Fatalf("Invalid auth URL: %v", err)
+	}
+	query := authURL.Query()
+	if authURL.Scheme+"://"+authURL.Host+authURL.Path != fake.Issuer()+"/authorize" {
+		t.Errorf("Expected the discovered authorization endpoint, got %s", authURL)
+	}
+	for name, want := range map[string]string{
+		"client_id":     fake.ClientID,
+		"state":         "the-state",
+		"nonce":         "the-nonce",
+		"response_type": "code",
+		"scope":         "openid email profile",
+	} {
+		if got := query.Get(name); got != want {
+			t.Errorf("Expected %s=%q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	provider, fake := newTestOIDCProvider(t)
+
+	fake.SetIDToken(fake.IDToken(t, "subject-1", jwt.MapClaims{
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"name":           "Ada",
+		"nonce":          "n-1",
+	}))
+
+	identity, err := provider.Exchange(context.Background(), "code", "n-1")
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if identity.Subject != "subject-1" || identity.Email != "ada@example.com" || !identity.EmailVerified || identity.Name != "Ada" {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+}
+
+func TestOIDCProvider_VerifyIDTokenRejects(t *testing.T) {
+	provider, fake := newTestOIDCProvider(t)
+	ctx := context.Background()
+
+	other := testutil.NewOIDCProvider(t)
+	other.ClientID = fake.ClientID
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+	}{
+		{"wrong nonce", fake.IDToken(t, "s", jwt.MapClaims{"nonce": "n"}), "other"},
+		{"missing nonce", fake.IDToken(t, "s", nil), "n"},
+		{"wrong audience", fake.IDToken(t, "s", jwt.MapClaims{"nonce": "n", "aud": "someone-else"}), "n"},
+		{"wrong issuer", fake.IDToken(t, "s", jwt.MapClaims{"nonce": "n", "iss": "https://evil.example"}), "n"},
+		{"expired", fake.IDToken(t, "s", jwt.MapClaims{"nonce": "n", "exp": time.Now().Add(-time.Hour).Unix()}), "n"},
+		{"no expiry", fake.IDToken(t, "s", jwt.MapClaims{"nonce": "n", "exp": nil}), "n"},
+		{"no subject", fake.IDToken(t, "", jwt.MapClaims{"nonce": "n"}), "n"},
+		{"signed by another key", other.IDToken(t, "s", jwt.MapClaims{"nonce": "n", "iss": fake.Issuer()}), "n"},
+		{"not a token", "garbage", "n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := provider.VerifyIDToken(ctx, tt.token, tt.nonce); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewOIDCProvider_Config(t *testing.T) {
+	fake := testutil.NewOIDCProvider(t)
+	config := OIDCConfig{
+		Issuer:       fake.Issuer(),
+		ClientID:     fake.ClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "https://lokr.example/auth/oidc/callback",
+	}
+
+	missingSecret := config
+	missingSecret.ClientSecret = ""
+	if _, err := NewOIDCProvider(context.Background(), missingSecret, fake.Server.Client()); err == nil {
+		t.Error("Expected a config without a client secret to be rejected")
+	}
+
+	noDiscovery := config
+	noDiscovery.Issuer = fake.Issuer() + "/tenant"
+	if _, err := NewOIDCProvider(context.Background(), noDiscovery, fake.Server.Client()); err == nil {
+		t.Error("Expected an issuer without a discovery document to be rejected")
+	}
+
+	trailingSlash := config
+	trailingSlash.Issuer = fake.Issuer() + "/"
+	provider, err := NewOIDCProvider(context.Background(), trailingSlash, fake.Server.Client())
+	if err != nil {
+		t.Fatalf("Expected a trailing slash on the issuer to be ignored, got %v", err)
+	}
+	if provider.Issuer() != fake.Issuer() {
+		t.Errorf("Expected issuer %s, got %s", fake.Issuer(), provider.Issuer())
+	}
+}
@@ -2,27 +2,92 @@ package hash
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
+	"hash"
 	"io"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Content hash algorithms, named as they are tagged on stored content
+const (
+	AlgorithmSHA256     = "sha256"
+	AlgorithmSHA512_256 = "sha512-256"
+	AlgorithmBLAKE2b256 = "blake2b-256"
 )
 
+// Hasher computes the content hashes stored content is deduplicated by. Every algorithm yields a
+// 256-bit digest, so hashes keep the 64 hex characters content hashes are stored in.
+type Hasher interface {
+	// Algorithm is the tag stored with content hashed by this hasher
+	Algorithm() string
+	// New starts a hash to stream content through
+	New() hash.Hash
+}
+
+type algorithm struct {
+	name    string
+	newHash func() hash.Hash
+}
+
+func (a algorithm) Algorithm() string { return a.name }
+
+func (a algorithm) New() hash.Hash { return a.newHash() }
+
+// SHA256 is the default hasher, and the algorithm of all content stored before hashes were tagged
+var SHA256 Hasher = algorithm{name: AlgorithmSHA256, newHash: sha256.New}
+
+var hashers = map[string]Hasher{
+	AlgorithmSHA256:     SHA256,
+	AlgorithmSHA512_256: algorithm{name: AlgorithmSHA512_256, newHash: sha512.New512_256},
+	AlgorithmBLAKE2b256: algorithm{name: AlgorithmBLAKE2b256, newHash: func() hash.Hash {
+		h, _ := blake2b.New256(nil) // Only fails for keys over 64 bytes
+		return h
+	}},
+}
+
+// NewHasher returns the hasher for an algorithm tag, case-insensitively; empty means SHA-256
+func NewHasher(name string) (Hasher, error) {
+	if name == "" {
+		return SHA256, nil
+	}
+	hasher, ok := hashers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported content hash algorithm %q", name)
+	}
+	return hasher, nil
+}
+
+// Sum returns the hex hash of data
+func Sum(hasher Hasher, data []byte) string {
+	h := hasher.New()
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// SumReader returns the hex hash of everything read from reader
+func SumReader(hasher Hasher, reader io.Reader) (string, error) {
+	h := hasher.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", fmt.Errorf("failed to calculate hash: %w", err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
 // SHA256Hash calculates SHA-256 hash of data
 func SHA256Hash(data []byte) string {
-	hash := sha256.Sum256(data)
-	return fmt.Sprintf("%x", hash)
+	return Sum(SHA256, data)
 }
 
 // SHA256HashReader calculates SHA-256 hash from a reader
 func SHA256HashReader(reader io.Reader) (string, error) {
-	hasher := sha256.New()
-	if _, err := io.Copy(hasher, reader); err != nil {
-		return "", fmt.Errorf("failed to calculate hash: %w", err)
-	}
-	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+	return SumReader(SHA256, reader)
 }
 
 // ValidateHash validates if provided hash matches data
 func ValidateHash(data []byte, expectedHash string) bool {
 	actualHash := SHA256Hash(data)
 	return actualHash == expectedHash
-}
\ No newline at end of file
+}
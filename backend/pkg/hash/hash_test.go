@@ -0,0 +1,37 @@
+package hash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewHasher(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		want      string
+	}{
+		{"default", "", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"sha256", "SHA256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"sha512-256", AlgorithmSHA512_256, "53048e2681941ef99b2e29b76b4c7dabe4c2d0c634fc6d46e0e2f13107e7af23"},
+		{"blake2b-256", AlgorithmBLAKE2b256, "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher, err := NewHasher(tt.algorithm)
+			if err != nil {
+				t.Fatalf("NewHasher failed: %v", err)
+			}
+			if got := Sum(hasher, []byte("abc")); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+			if got, err := SumReader(hasher, strings.NewReader("abc")); err != nil || got != tt.want {
+				t.Errorf("Expected %s from the reader, got %s (%v)", tt.want, got, err)
+			}
+		})
+	}
+
+	if _, err := NewHasher("md5"); err == nil {
+		t.Error("Expected an unsupported algorithm to be rejected")
+	}
+}
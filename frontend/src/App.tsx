@@ -30,6 +30,7 @@ function App() {
     <Routes>
       {/* Public shared file route - accessible without authentication */}
       <Route path="/shared/:token" element={<SharedFile />} />
+      <Route path="/s/:code" element={<SharedFile />} />
 
       {/* Authenticated routes */}
       {!isAuthenticated ? (
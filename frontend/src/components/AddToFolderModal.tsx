@@ -27,7 +27,7 @@ export const AddToFolderModal: React.FC<AddToFolderModalProps> = ({
   const { data: foldersData, loading: foldersLoading } = useGetMyFoldersQuery()
   const [createFileReference, { loading: creatingReference }] = useCreateFileReferenceMutation()
 
-  const folders = foldersData?.myFolders || []
+  const folders = foldersData?.myFolders?.items || []
 
   const handleAddToFolder = async () => {
     if (!selectedFolderId) {
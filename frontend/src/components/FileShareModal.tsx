@@ -311,9 +311,9 @@ export const FileShareModal: React.FC<FileShareModalProps> = ({ isOpen, onClose,
                     </div>
                   )}
 
-                  {searchUsersData?.searchUsers && searchUsersData.searchUsers.length > 0 && (
+                  {searchUsersData?.searchUsers && searchUsersData.searchUsers.items.length > 0 && (
                     <div className="bg-white border border-gray-200 rounded-lg shadow-sm max-h-40 overflow-y-auto">
-                      {searchUsersData.searchUsers.map((user) => (
+                      {searchUsersData.searchUsers.items.map((user) => (
                         <button
                           key={user.id}
                           onClick={() => handleShareWithUser(user.id, user.email)}
@@ -337,7 +337,7 @@ export const FileShareModal: React.FC<FileShareModalProps> = ({ isOpen, onClose,
                     </div>
                   )}
 
-                  {searchUsersData?.searchUsers && searchUsersData.searchUsers.length === 0 && userSearchQuery && (
+                  {searchUsersData?.searchUsers && searchUsersData.searchUsers.items.length === 0 && userSearchQuery && (
                     <div className="text-center py-4 text-gray-500">
                       <UserPlusIcon className="w-8 h-8 text-gray-300 mx-auto mb-2" />
                       <p className="text-sm">No users found matching "{userSearchQuery}"</p>
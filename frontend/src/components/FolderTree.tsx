@@ -233,7 +233,7 @@ export const FolderTree: React.FC<FolderTreeProps> = ({
     )
   }
 
-  const folders = data?.myFolders || []
+  const folders = data?.myFolders?.items || []
 
   // Root folder drop props
   const rootDropProps = useDroppable(
@@ -256,7 +256,7 @@ export const FolderTreeSimple: React.FC<FolderTreeSimpleProps> = ({
     )
   }
 
-  const folders = data?.myFolders || []
+  const folders = data?.myFolders?.items || []
 
   return (
     <div className="h-full flex flex-col">
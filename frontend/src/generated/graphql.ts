@@ -187,6 +187,13 @@ export type File = {
   visibility: FileVisibility;
 };
 
+export type FileConnection = {
+  __typename?: 'FileConnection';
+  hasNextPage: Scalars['Boolean']['output'];
+  items: Array<File>;
+  totalCount: Scalars['Int']['output'];
+};
+
 export type FileContent = {
   __typename?: 'FileContent';
   contentHash: Scalars['ID']['output'];
@@ -293,6 +300,13 @@ export type Folder = {
   userId: Scalars['ID']['output'];
 };
 
+export type FolderConnection = {
+  __typename?: 'FolderConnection';
+  hasNextPage: Scalars['Boolean']['output'];
+  items: Array<Folder>;
+  totalCount: Scalars['Int']['output'];
+};
+
 export type InviteUserInput = {
   email: Scalars['String']['input'];
   role: EnterpriseRole;
@@ -541,13 +555,13 @@ export type Query = {
   folderReferences: Array<FileReference>;
   me?: Maybe<User>;
   myEnterprise?: Maybe<Enterprise>;
-  myFiles: Array<File>;
-  myFolders: Array<Folder>;
+  myFiles: FileConnection;
+  myFolders: FolderConnection;
   publicFile?: Maybe<File>;
   recentActivity: Array<AuditLog>;
   searchFiles: FileSearchResult;
-  searchUsers: Array<User>;
-  sharedWithMe: Array<File>;
+  searchUsers: UserConnection;
+  sharedWithMe: FileConnection;
   storageStats: StorageStats;
   user?: Maybe<User>;
   users: Array<User>;
@@ -637,6 +651,12 @@ export type QueryMyFilesArgs = {
 };
 
 
+export type QueryMyFoldersArgs = {
+  limit?: InputMaybe<Scalars['Int']['input']>;
+  offset?: InputMaybe<Scalars['Int']['input']>;
+};
+
+
 export type QueryPublicFileArgs = {
   shareToken: Scalars['String']['input'];
 };
@@ -653,7 +673,9 @@ export type QuerySearchFilesArgs = {
 
 
 export type QuerySearchUsersArgs = {
+  allUsers?: InputMaybe<Scalars['Boolean']['input']>;
   limit?: InputMaybe<Scalars['Int']['input']>;
+  offset?: InputMaybe<Scalars['Int']['input']>;
   query: Scalars['String']['input'];
 };
 
@@ -778,6 +800,13 @@ export type User = {
   updatedAt: Scalars['Time']['output'];
 };
 
+export type UserConnection = {
+  __typename?: 'UserConnection';
+  hasNextPage: Scalars['Boolean']['output'];
+  items: Array<User>;
+  totalCount: Scalars['Int']['output'];
+};
+
 export type GetAuditLogsQueryVariables = Exact<{
   limit: Scalars['Int']['input'];
   offset: Scalars['Int']['input'];
@@ -992,7 +1021,7 @@ export type GetMyFilesQueryVariables = Exact<{
 }>;
 
 
-export type GetMyFilesQuery = { __typename?: 'Query', myFiles: Array<{ __typename?: 'File', id: string, userId: string, folderId?: string | null, filename: string, originalName: string, mimeType: string, fileSize: number, contentHash: string, description?: string | null, tags: Array<string>, visibility: FileVisibility, shareToken?: string | null, downloadCount: number, uploadDate: string, updatedAt: string, user?: { __typename?: 'User', id: string, name: string, email: string } | null, folder?: { __typename?: 'Folder', id: string, name: string } | null }> };
+export type GetMyFilesQuery = { __typename?: 'Query', myFiles: { __typename?: 'FileConnection', items: Array<{ __typename?: 'File', id: string, userId: string, folderId?: string | null, filename: string, originalName: string, mimeType: string, fileSize: number, contentHash: string, description?: string | null, tags: Array<string>, visibility: FileVisibility, shareToken?: string | null, downloadCount: number, uploadDate: string, updatedAt: string, user?: { __typename?: 'User', id: string, name: string, email: string } | null, folder?: { __typename?: 'Folder', id: string, name: string } | null }>, totalCount: number, hasNextPage: boolean } };
 
 export type GetFileQueryVariables = Exact<{
   id: Scalars['ID']['input'];
@@ -1014,7 +1043,7 @@ export type GetSharedWithMeQueryVariables = Exact<{
 }>;
 
 
-export type GetSharedWithMeQuery = { __typename?: 'Query', sharedWithMe: Array<{ __typename?: 'File', id: string, userId: string, folderId?: string | null, filename: string, originalName: string, mimeType: string, fileSize: number, contentHash: string, description?: string | null, tags: Array<string>, visibility: FileVisibility, downloadCount: number, uploadDate: string, updatedAt: string, user?: { __typename?: 'User', id: string, name: string, email: string } | null, folder?: { __typename?: 'Folder', id: string, name: string } | null }> };
+export type GetSharedWithMeQuery = { __typename?: 'Query', sharedWithMe: { __typename?: 'FileConnection', items: Array<{ __typename?: 'File', id: string, userId: string, folderId?: string | null, filename: string, originalName: string, mimeType: string, fileSize: number, contentHash: string, description?: string | null, tags: Array<string>, visibility: FileVisibility, downloadCount: number, uploadDate: string, updatedAt: string, user?: { __typename?: 'User', id: string, name: string, email: string } | null, folder?: { __typename?: 'Folder', id: string, name: string } | null }>, totalCount: number, hasNextPage: boolean } };
 
 export type GetPublicFileQueryVariables = Exact<{
   shareToken: Scalars['String']['input'];
@@ -1046,10 +1075,11 @@ export type GetFileShareInfoQuery = { __typename?: 'Query', fileShareInfo: { __t
 export type SearchUsersQueryVariables = Exact<{
   query: Scalars['String']['input'];
   limit?: InputMaybe<Scalars['Int']['input']>;
+  offset?: InputMaybe<Scalars['Int']['input']>;
 }>;
 
 
-export type SearchUsersQuery = { __typename?: 'Query', searchUsers: Array<{ __typename?: 'User', id: string, name: string, email: string }> };
+export type SearchUsersQuery = { __typename?: 'Query', searchUsers: { __typename?: 'UserConnection', totalCount: number, hasNextPage: boolean, items: Array<{ __typename?: 'User', id: string, name: string, email: string }> } };
 
 export type CreateFolderMutationVariables = Exact<{
   input: CreateFolderInput;
@@ -1093,7 +1123,7 @@ export type MoveFileMutation = { __typename?: 'Mutation', moveFile: { __typename
 export type GetMyFoldersQueryVariables = Exact<{ [key: string]: never; }>;
 
 
-export type GetMyFoldersQuery = { __typename?: 'Query', myFolders: Array<{ __typename?: 'Folder', id: string, name: string, parentId?: string | null, createdAt: string, updatedAt: string, children: Array<{ __typename?: 'Folder', id: string, name: string, parentId?: string | null, createdAt: string, updatedAt: string }>, files: Array<{ __typename?: 'File', id: string, filename: string, originalName: string, mimeType: string, fileSize: number, uploadDate: string }> }> };
+export type GetMyFoldersQuery = { __typename?: 'Query', myFolders: { __typename?: 'FolderConnection', items: Array<{ __typename?: 'Folder', id: string, name: string, parentId?: string | null, createdAt: string, updatedAt: string, children: Array<{ __typename?: 'Folder', id: string, name: string, parentId?: string | null, createdAt: string, updatedAt: string }>, files: Array<{ __typename?: 'File', id: string, filename: string, originalName: string, mimeType: string, fileSize: number, uploadDate: string }> }>, totalCount: number, hasNextPage: boolean } };
 
 export type GetFolderContentsQueryVariables = Exact<{
   id: Scalars['ID']['input'];
@@ -2379,30 +2409,34 @@ export type RemovePublicShareMutationOptions = Apollo.BaseMutationOptions<Remove
 export const GetMyFilesDocument = gql`
     query GetMyFiles($limit: Int = 20, $offset: Int = 0) {
   myFiles(limit: $limit, offset: $offset) {
-    id
-    userId
-    folderId
-    filename
-    originalName
-    mimeType
-    fileSize
-    contentHash
-    description
-    tags
-    visibility
-    shareToken
-    downloadCount
-    uploadDate
-    updatedAt
-    user {
+    items {
       id
-      name
-      email
-    }
-    folder {
-      id
-      name
+      userId
+      folderId
+      filename
+      originalName
+      mimeType
+      fileSize
+      contentHash
+      description
+      tags
+      visibility
+      shareToken
+      downloadCount
+      uploadDate
+      updatedAt
+      user {
+        id
+        name
+        email
+      }
+      folder {
+        id
+        name
+      }
     }
+    totalCount
+    hasNextPage
   }
 }
     `;
@@ -2572,29 +2606,33 @@ export type SearchFilesQueryResult = Apollo.QueryResult<SearchFilesQuery, Search
 export const GetSharedWithMeDocument = gql`
     query GetSharedWithMe($limit: Int = 20, $offset: Int = 0) {
   sharedWithMe(limit: $limit, offset: $offset) {
-    id
-    userId
-    folderId
-    filename
-    originalName
-    mimeType
-    fileSize
-    contentHash
-    description
-    tags
-    visibility
-    downloadCount
-    uploadDate
-    updatedAt
-    user {
+    items {
       id
-      name
-      email
-    }
-    folder {
-      id
-      name
+      userId
+      folderId
+      filename
+      originalName
+      mimeType
+      fileSize
+      contentHash
+      description
+      tags
+      visibility
+      downloadCount
+      uploadDate
+      updatedAt
+      user {
+        id
+        name
+        email
+      }
+      folder {
+        id
+        name
+      }
     }
+    totalCount
+    hasNextPage
   }
 }
     `;
@@ -2806,11 +2844,15 @@ export type GetFileShareInfoQueryHookResult = ReturnType<typeof useGetFileShareI
 export type GetFileShareInfoLazyQueryHookResult = ReturnType<typeof useGetFileShareInfoLazyQuery>;
 export type GetFileShareInfoQueryResult = Apollo.QueryResult<GetFileShareInfoQuery, GetFileShareInfoQueryVariables>;
 export const SearchUsersDocument = gql`
-    query SearchUsers($query: String!, $limit: Int = 10) {
-  searchUsers(query: $query, limit: $limit) {
-    id
-    name
-    email
+    query SearchUsers($query: String!, $limit: Int = 10, $offset: Int = 0) {
+  searchUsers(query: $query, limit: $limit, offset: $offset) {
+    items {
+      id
+      name
+      email
+    }
+    totalCount
+    hasNextPage
   }
 }
     `;
@@ -2829,6 +2871,7 @@ export const SearchUsersDocument = gql`
  *   variables: {
  *      query: // value for 'query'
  *      limit: // value for 'limit'
+ *      offset: // value for 'offset'
  *   },
  * });
  */
@@ -3027,26 +3070,30 @@ export type MoveFileMutationOptions = Apollo.BaseMutationOptions<MoveFileMutatio
 export const GetMyFoldersDocument = gql`
     query GetMyFolders {
   myFolders {
-    id
-    name
-    parentId
-    createdAt
-    updatedAt
-    children {
+    items {
       id
       name
       parentId
       createdAt
       updatedAt
+      children {
+        id
+        name
+        parentId
+        createdAt
+        updatedAt
+      }
+      files {
+        id
+        filename
+        originalName
+        mimeType
+        fileSize
+        uploadDate
+      }
     }
-    files {
-      id
-      filename
-      originalName
-      mimeType
-      fileSize
-      uploadDate
-    }
+    totalCount
+    hasNextPage
   }
 }
     `;
@@ -47,6 +47,7 @@ export const AuditLog: React.FC = () => {
       case 'FILE_DELETE':
         return <TrashIcon className="w-4 h-4" />
       case 'FILE_SHARE':
+      case 'USER_SHARE':
       case 'PUBLIC_SHARE':
         return <ShareIcon className="w-4 h-4" />
       case 'FOLDER_CREATE':
@@ -70,6 +71,7 @@ export const AuditLog: React.FC = () => {
       case 'FILE_DELETE':
         return 'text-red-600'
       case 'FILE_SHARE':
+      case 'USER_SHARE':
       case 'PUBLIC_SHARE':
         return 'text-purple-600'
       case 'USER_LOGIN':
@@ -168,6 +170,8 @@ export const AuditLog: React.FC = () => {
                     <option value="FILE_PREVIEW">File Preview</option>
                     <option value="FILE_DELETE">File Delete</option>
                     <option value="FILE_SHARE">File Share</option>
+                    <option value="USER_SHARE">User Share</option>
+                    <option value="SHARE_REVOKED">Share Revoked</option>
                     <option value="PUBLIC_SHARE">Public Share</option>
                     <option value="USER_LOGIN">User Login</option>
                   </select>
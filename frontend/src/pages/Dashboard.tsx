@@ -13,8 +13,8 @@ export const Dashboard: React.FC = () => {
   const [uploadFileMutation] = useUploadFileMutation()
 
   // Calculate shared files count from the files data
-  const sharedFilesCount = filesData?.myFiles?.filter(file => file.visibility !== 'PRIVATE').length || 0
-  const sharedWithMeCount = sharedWithMeData?.sharedWithMe?.length || 0
+  const sharedFilesCount = filesData?.myFiles?.items.filter(file => file.visibility !== 'PRIVATE').length || 0
+  const sharedWithMeCount = sharedWithMeData?.sharedWithMe?.totalCount || 0
 
   const handleFileUpload = async (event: React.ChangeEvent<HTMLInputElement>) => {
     const uploadFiles = event.target.files
@@ -101,7 +101,7 @@ export const Dashboard: React.FC = () => {
               <div className="ml-4">
                 <p className="text-sm font-medium text-gray-500">Total Files</p>
                 <p className="text-2xl font-bold text-gray-900">
-                  {filesLoading ? '...' : filesData?.myFiles?.length || 0}
+                  {filesLoading ? '...' : filesData?.myFiles?.totalCount || 0}
                 </p>
               </div>
             </div>
@@ -198,9 +198,9 @@ export const Dashboard: React.FC = () => {
               <div className="animate-spin rounded-full h-8 w-8 border-b-2 border-blue-600"></div>
               <span className="ml-3 text-gray-600">Loading shared files...</span>
             </div>
-          ) : sharedWithMeData?.sharedWithMe && sharedWithMeData.sharedWithMe.length > 0 ? (
+          ) : sharedWithMeData?.sharedWithMe && sharedWithMeData.sharedWithMe.items.length > 0 ? (
             <div className="space-y-3">
-              {sharedWithMeData.sharedWithMe.slice(0, 5).map((file) => (
+              {sharedWithMeData.sharedWithMe.items.slice(0, 5).map((file) => (
                 <div
                   key={file.id}
                   className="flex items-center justify-between p-3 bg-gray-50 rounded-lg hover:bg-gray-100 transition-colors"
@@ -221,13 +221,13 @@ export const Dashboard: React.FC = () => {
                   </div>
                 </div>
               ))}
-              {sharedWithMeData.sharedWithMe.length > 5 && (
+              {sharedWithMeData.sharedWithMe.totalCount > 5 && (
                 <div className="text-center pt-4">
                   <button
                     onClick={() => window.location.href = '/files?tab=shared'}
                     className="text-blue-600 hover:text-blue-800 text-sm font-medium"
                   >
-                    View all {sharedWithMeData.sharedWithMe.length} shared files →
+                    View all {sharedWithMeData.sharedWithMe.totalCount} shared files →
                   </button>
                 </div>
               )}
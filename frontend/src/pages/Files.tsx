@@ -101,8 +101,8 @@ export const Files: React.FC = () => {
 
   const [deleteFolderMutation] = useDeleteFolderMutation()
 
-  const myFiles = filesData?.myFiles || []
-  const sharedFiles = sharedFilesData?.sharedWithMe || []
+  const myFiles = filesData?.myFiles?.items || []
+  const sharedFiles = sharedFilesData?.sharedWithMe?.items || []
   const folderFiles = folderContentsData?.folderContents?.files || []
 
   // Get files to display based on current view
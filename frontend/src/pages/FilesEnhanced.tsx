@@ -467,8 +467,8 @@ export const FilesEnhanced: React.FC = () => {
   // File operations
   const [deleteFileMutation] = useDeleteFileMutation()
 
-  const myFiles = filesData?.myFiles || []
-  const sharedFiles = sharedFilesData?.sharedWithMe || []
+  const myFiles = filesData?.myFiles?.items || []
+  const sharedFiles = sharedFilesData?.sharedWithMe?.items || []
   const folderReferences = folderReferencesData?.folderReferences || []
 
   const getDisplayFiles = (): FileItem[] => {
@@ -34,8 +34,8 @@ export const FilesMinimal: React.FC = () => {
   // Delete folder mutation
   const [deleteFolderMutation] = useDeleteFolderMutation()
 
-  const myFiles = filesData?.myFiles || []
-  const sharedFiles = sharedFilesData?.sharedWithMe || []
+  const myFiles = filesData?.myFiles?.items || []
+  const sharedFiles = sharedFilesData?.sharedWithMe?.items || []
   const folderFiles = folderContentsData?.folderContents || []
 
   const getDisplayFiles = () => {
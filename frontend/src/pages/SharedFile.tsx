@@ -20,7 +20,10 @@ interface SharedFile {
 }
 
 export const SharedFile: React.FC = () => {
-  const { token } = useParams<{ token: string }>()
+  // Long links are /shared/<token>, short links /s/<code>; the API resolves both the same way
+  const { token: longToken, code } = useParams<{ token: string; code: string }>()
+  const token = longToken || code
+  const sharePath = code ? `s/${code}` : `shared/${longToken}`
   const [file, setFile] = useState<SharedFile | null>(null)
   const [loading, setLoading] = useState(true)
   const [downloading, setDownloading] = useState(false)
@@ -38,7 +41,7 @@ export const SharedFile: React.FC = () => {
 
       // The backend returns file content directly, not JSON
       // Let's check if the token is valid by checking the response headers
-      const response = await fetch(`http://localhost:8080/api/v1/shared/${token}`, {
+      const response = await fetch(`http://localhost:8080/api/v1/${sharePath}`, {
         method: 'HEAD' // Just get headers to check if file exists
       })
 
@@ -90,7 +93,7 @@ export const SharedFile: React.FC = () => {
       setDownloading(true)
 
       // Use the same endpoint but trigger download by creating a download link
-      const downloadUrl = `http://localhost:8080/api/v1/shared/${token}`
+      const downloadUrl = `http://localhost:8080/api/v1/${sharePath}`
 
       // Create download link that will trigger the download
       const a = document.createElement('a')
@@ -113,7 +116,7 @@ export const SharedFile: React.FC = () => {
   const handlePreview = () => {
     if (!token) return
 
-    const previewUrl = `http://localhost:8080/api/v1/shared/${token}/preview`
+    const previewUrl = `http://localhost:8080/api/v1/${sharePath}/preview`
     window.open(previewUrl, '_blank')
   }
 